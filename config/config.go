@@ -50,9 +50,46 @@ const (
 	// DefaultPrometheusPort is the default value for PrometheusConfig.Port if not specified.
 	DefaultPrometheusPort = 8031
 
+	// DefaultOTLPGRPCEndpoint is the default value for OTLPConfig.Endpoint if not specified, when using gRPC.
+	DefaultOTLPGRPCEndpoint = "localhost:4317"
+
+	// DefaultOTLPHTTPEndpoint is the default value for OTLPConfig.Endpoint if not specified, when using HTTP.
+	DefaultOTLPHTTPEndpoint = "http://localhost:4318"
+
+	// AccessLogStdout is the value of MainConfig.AccessLog that means access log lines should be written
+	// to standard output rather than to a file.
+	AccessLogStdout = "stdout"
+
+	// DefaultAccessLogMaxSize is the default value for MainConfig.AccessLogMaxSize if not specified.
+	DefaultAccessLogMaxSize = 100 * 1024 * 1024
+
+	// DefaultAccessLogMaxBackups is the default value for MainConfig.AccessLogMaxBackups if not specified.
+	DefaultAccessLogMaxBackups = 5
+
 	// DefaultBigSegmentsStaleThreshold is the default value for MainConfig.BigSegmentsStaleThreshold if not specified.
 	DefaultBigSegmentsStaleThreshold = time.Minute * 5
 
+	// DefaultClusterHeartbeatInterval is the default value for ClusterConfig.HeartbeatInterval if not specified.
+	DefaultClusterHeartbeatInterval = time.Second * 2
+
+	// DefaultClusterLeaderTimeout is the default value for ClusterConfig.LeaderTimeout if not specified.
+	DefaultClusterLeaderTimeout = time.Second * 10
+
+	// DefaultChangeFeedSize is the default value for MainConfig.ChangeFeedSize if not specified.
+	DefaultChangeFeedSize = 1000
+
+	// DefaultWebhookMaxRetries is the default value for WebhookConfig.MaxRetries if not specified.
+	DefaultWebhookMaxRetries = 3
+
+	// WebhookEventChanges is the value in WebhookConfig.Events for flag and segment changes.
+	WebhookEventChanges = "changes"
+
+	// WebhookEventStatus is the value in WebhookConfig.Events for environment status changes.
+	WebhookEventStatus = "status"
+
+	// DefaultStreamConnectionRetryAfter is the default value for MainConfig.StreamConnectionRetryAfter if not specified.
+	DefaultStreamConnectionRetryAfter = time.Second * 30
+
 	// AutoConfigEnvironmentIDPlaceholder is a string that can appear within
 	// AutoConfigConfig.EnvDataStorePrefix or AutoConfigConfig.EnvDataStoreTableName to indicate that
 	// the environment ID should be substituted at that point.
@@ -92,6 +129,9 @@ const (
 	// credentials to be revoked nearly instantaneously. It is not necessarily a recommendation.
 	// It likely doesn't make sense to use an interval this frequent in production use-cases.
 	minimumCredentialCleanupInterval = 100 * time.Millisecond
+	// Each persistent store consistency check reads all of an environment's data from the database, so we
+	// don't allow it to be done very frequently.
+	minimumStoreConsistencyCheckInterval = time.Second
 )
 
 // DefaultLoggers is the default logging configuration used by Relay.
@@ -120,8 +160,10 @@ type Config struct {
 	Redis       RedisConfig
 	Consul      ConsulConfig
 	DynamoDB    DynamoDBConfig
+	Cluster     ClusterConfig
 	Environment map[string]*EnvConfig
 	Filters     map[string]*FiltersConfig
+	Webhook     map[string]*WebhookConfig
 	Proxy       ProxyConfig
 
 	// Optional configuration for metrics integrations. Note that unlike the other fields in Config,
@@ -144,7 +186,14 @@ type MainConfig struct {
 	StreamURI                        ct.OptURLAbsolute        `conf:"STREAM_URI"`
 	BaseURI                          ct.OptURLAbsolute        `conf:"BASE_URI"`
 	ClientSideBaseURI                ct.OptURLAbsolute        `conf:"CLIENT_SIDE_BASE_URI"`
+	UpstreamRelayURI                 ct.OptURLAbsolute        `conf:"UPSTREAM_RELAY_URI"`
+	AllowDownstreamRelays            bool                     `conf:"ALLOW_DOWNSTREAM_RELAYS"`
+	DownstreamAutoConfigKeys         ct.OptStringList         `conf:"DOWNSTREAM_AUTO_CONFIG_KEYS"`
 	Port                             ct.OptIntGreaterThanZero `conf:"PORT"`
+	Listen                           ct.OptStringList         `conf:"LISTEN"`
+	HTTP2Cleartext                   bool                     `conf:"HTTP2_CLEARTEXT"`
+	HTTP2MaxConcurrentStreams        ct.OptIntGreaterThanZero `conf:"HTTP2_MAX_CONCURRENT_STREAMS"`
+	HTTP2IdleTimeout                 ct.OptDuration           `conf:"HTTP2_IDLE_TIMEOUT"`
 	InitTimeout                      ct.OptDuration           `conf:"INIT_TIMEOUT"`
 	HeartbeatInterval                ct.OptDuration           `conf:"HEARTBEAT_INTERVAL"`
 	MaxClientConnectionTime          ct.OptDuration           `conf:"MAX_CLIENT_CONNECTION_TIME"`
@@ -153,10 +202,28 @@ type MainConfig struct {
 	TLSCert                          string                   `conf:"TLS_CERT"`
 	TLSKey                           string                   `conf:"TLS_KEY"`
 	TLSMinVersion                    OptTLSVersion            `conf:"TLS_MIN_VERSION"`
+	TLSClientCA                      ct.OptStringList         `conf:"TLS_CLIENT_CA"`
 	LogLevel                         OptLogLevel              `conf:"LOG_LEVEL"`
+	LogFormat                        LogFormat                `conf:"LOG_FORMAT"`
+	LogRequests                      bool                     `conf:"LOG_REQUESTS"`
+	AccessLog                        string                   `conf:"ACCESS_LOG"`
+	AccessLogFormat                  AccessLogFormat          `conf:"ACCESS_LOG_FORMAT"`
+	AccessLogMaxSize                 ct.OptBase2Bytes         `conf:"ACCESS_LOG_MAX_SIZE"`
+	AccessLogMaxBackups              ct.OptIntGreaterThanZero `conf:"ACCESS_LOG_MAX_BACKUPS"`
 	BigSegmentsStaleAsDegraded       bool                     `conf:"BIG_SEGMENTS_STALE_AS_DEGRADED"`
 	BigSegmentsStaleThreshold        ct.OptDuration           `conf:"BIG_SEGMENTS_STALE_THRESHOLD"`
 	ExpiredCredentialCleanupInterval ct.OptDuration           `conf:"EXPIRED_CREDENTIAL_CLEANUP_INTERVAL"`
+	MaxStreamConnections             ct.OptIntGreaterThanZero `conf:"MAX_STREAM_CONNECTIONS"`
+	MaxEnvStreamConnections          ct.OptIntGreaterThanZero `conf:"MAX_ENV_STREAM_CONNECTIONS"`
+	ShedOldestStreamConnections      bool                     `conf:"SHED_OLDEST_STREAM_CONNECTIONS"`
+	StreamConnectionRetryAfter       ct.OptDuration           `conf:"STREAM_CONNECTION_RETRY_AFTER"`
+	MaxStreamQueuedEvents            ct.OptIntGreaterThanZero `conf:"MAX_STREAM_QUEUED_EVENTS"`
+	StreamWriteTimeout               ct.OptDuration           `conf:"STREAM_WRITE_TIMEOUT"`
+	DisableStreamCompression         bool                     `conf:"DISABLE_STREAM_COMPRESSION"`
+	AdminKey                         string                   `conf:"ADMIN_KEY"`
+	StaleWhileUnavailable            bool                     `conf:"STALE_WHILE_UNAVAILABLE"`
+	ChangeFeedSize                   ct.OptIntGreaterThanZero `conf:"CHANGE_FEED_SIZE"`
+	ChangeFeedFile                   string                   `conf:"CHANGE_FEED_FILE"`
 }
 
 // AutoConfigConfig contains configuration parameters for the auto-configuration feature.
@@ -204,13 +271,16 @@ type EventsConfig struct {
 // variables, individual fields are not documented here; instead, see the `README.md` section on
 // configuration.
 type RedisConfig struct {
-	Host     string `conf:"REDIS_HOST"`
-	Port     ct.OptIntGreaterThanZero
-	URL      ct.OptURLAbsolute `conf:"REDIS_URL"`
-	LocalTTL ct.OptDuration    `conf:"CACHE_TTL"`
-	TLS      bool              `conf:"REDIS_TLS"`
-	Username string            `conf:"REDIS_USERNAME"`
-	Password string            `conf:"REDIS_PASSWORD"`
+	Host                     string `conf:"REDIS_HOST"`
+	Port                     ct.OptIntGreaterThanZero
+	URL                      ct.OptURLAbsolute `conf:"REDIS_URL"`
+	LocalTTL                 ct.OptDuration    `conf:"CACHE_TTL"`
+	TLS                      bool              `conf:"REDIS_TLS"`
+	Username                 string            `conf:"REDIS_USERNAME"`
+	Password                 string            `conf:"REDIS_PASSWORD"`
+	LeaderElection           bool              `conf:"STORE_LEADER_ELECTION"`
+	ConsistencyCheckInterval ct.OptDuration    `conf:"STORE_CONSISTENCY_CHECK_INTERVAL"`
+	ConsistencyCheckRepair   bool              `conf:"STORE_CONSISTENCY_CHECK_REPAIR"`
 }
 
 // ConsulConfig configures the optional Consul integration.
@@ -223,10 +293,13 @@ type RedisConfig struct {
 // variables, individual fields are not documented here; instead, see the `README.md` section on
 // configuration.
 type ConsulConfig struct {
-	Host      string         `conf:"CONSUL_HOST"`
-	LocalTTL  ct.OptDuration `conf:"CACHE_TTL"`
-	Token     string         `conf:"CONSUL_TOKEN"`
-	TokenFile string         `conf:"CONSUL_TOKEN_FILE"`
+	Host                     string         `conf:"CONSUL_HOST"`
+	LocalTTL                 ct.OptDuration `conf:"CACHE_TTL"`
+	Token                    string         `conf:"CONSUL_TOKEN"`
+	TokenFile                string         `conf:"CONSUL_TOKEN_FILE"`
+	LeaderElection           bool           `conf:"STORE_LEADER_ELECTION"`
+	ConsistencyCheckInterval ct.OptDuration `conf:"STORE_CONSISTENCY_CHECK_INTERVAL"`
+	ConsistencyCheckRepair   bool           `conf:"STORE_CONSISTENCY_CHECK_REPAIR"`
 }
 
 // DynamoDBConfig configures the optional DynamoDB integration, which is used only if Enabled is true.
@@ -237,10 +310,31 @@ type ConsulConfig struct {
 // variables, individual fields are not documented here; instead, see the `README.md` section on
 // configuration.
 type DynamoDBConfig struct {
-	Enabled   bool              `conf:"USE_DYNAMODB"`
-	TableName string            `conf:"DYNAMODB_TABLE"`
-	URL       ct.OptURLAbsolute `conf:"DYNAMODB_URL"`
-	LocalTTL  ct.OptDuration    `conf:"CACHE_TTL"`
+	Enabled                  bool              `conf:"USE_DYNAMODB"`
+	TableName                string            `conf:"DYNAMODB_TABLE"`
+	URL                      ct.OptURLAbsolute `conf:"DYNAMODB_URL"`
+	LocalTTL                 ct.OptDuration    `conf:"CACHE_TTL"`
+	LeaderElection           bool              `conf:"STORE_LEADER_ELECTION"`
+	ConsistencyCheckInterval ct.OptDuration    `conf:"STORE_CONSISTENCY_CHECK_INTERVAL"`
+	ConsistencyCheckRepair   bool              `conf:"STORE_CONSISTENCY_CHECK_REPAIR"`
+}
+
+// ClusterConfig configures the optional cluster mode, which is used only if Enabled is true. In cluster
+// mode, Relay instances that share the same Redis server elect one of themselves for each environment
+// to receive data from LaunchDarkly and pass it along to the others. The Redis server is the one
+// specified by RedisURL, or if that is not set, the one in RedisConfig.
+//
+// This corresponds to the [Cluster] section in the configuration file.
+//
+// Since configuration options can be set either programmatically, or from a file, or from environment
+// variables, individual fields are not documented here; instead, see the `README.md` section on
+// configuration.
+type ClusterConfig struct {
+	Enabled           bool              `conf:"CLUSTER_ENABLED"`
+	NodeID            string            `conf:"CLUSTER_NODE_ID"`
+	RedisURL          ct.OptURLAbsolute `conf:"CLUSTER_REDIS_URL"`
+	HeartbeatInterval ct.OptDuration    `conf:"CLUSTER_HEARTBEAT_INTERVAL"`
+	LeaderTimeout     ct.OptDuration    `conf:"CLUSTER_LEADER_TIMEOUT"`
 }
 
 // EnvConfig describes an environment to be relayed. There may be any number of these.
@@ -252,25 +346,44 @@ type DynamoDBConfig struct {
 // variables, individual fields are not documented here; instead, see the `README.md` section on
 // configuration.
 type EnvConfig struct {
-	SDKKey        SDKKey           // set from env var LD_ENV_envname
-	MobileKey     MobileKey        `conf:"LD_MOBILE_KEY_"`
-	EnvID         EnvironmentID    `conf:"LD_CLIENT_SIDE_ID_"`
-	Prefix        string           `conf:"LD_PREFIX_"`     // used only if Redis, Consul, or DynamoDB is enabled
-	TableName     string           `conf:"LD_TABLE_NAME_"` // used only if DynamoDB is enabled
-	AllowedOrigin ct.OptStringList `conf:"LD_ALLOWED_ORIGIN_"`
-	AllowedHeader ct.OptStringList `conf:"LD_ALLOWED_HEADER_"`
-	SecureMode    bool             `conf:"LD_SECURE_MODE_"`
-	LogLevel      OptLogLevel      `conf:"LD_LOG_LEVEL_"`
-	TTL           ct.OptDuration   `conf:"LD_TTL_"`
-	ProjKey       string           `conf:"LD_PROJ_KEY_"`
-	FilterKey     FilterKey        // injected based on [filters] section
-	Offline       bool             // set to true if this environment was created in offline mode
+	SDKKey                 SDKKey                   // set from env var LD_ENV_envname
+	MobileKey              MobileKey                `conf:"LD_MOBILE_KEY_"`
+	EnvID                  EnvironmentID            `conf:"LD_CLIENT_SIDE_ID_"`
+	Prefix                 string                   `conf:"LD_PREFIX_"`     // used only if Redis, Consul, or DynamoDB is enabled
+	TableName              string                   `conf:"LD_TABLE_NAME_"` // used only if DynamoDB is enabled
+	AllowedOrigin          ct.OptStringList         `conf:"LD_ALLOWED_ORIGIN_"`
+	AllowedHeader          ct.OptStringList         `conf:"LD_ALLOWED_HEADER_"`
+	SecureMode             bool                     `conf:"LD_SECURE_MODE_"`
+	LogLevel               OptLogLevel              `conf:"LD_LOG_LEVEL_"`
+	TTL                    ct.OptDuration           `conf:"LD_TTL_"`
+	MaxStreamConnections   ct.OptIntGreaterThanZero `conf:"LD_MAX_STREAM_CONNECTIONS_"`
+	AllowedClientCertNames ct.OptStringList         `conf:"LD_ALLOWED_CLIENT_CERT_NAMES_"`
+	ProjKey                string                   `conf:"LD_PROJ_KEY_"`
+	FilterKey              FilterKey                // injected based on [filters] section
+	Offline                bool                     // set to true if this environment was created in offline mode
 }
 
 type FiltersConfig struct {
 	Keys ct.OptStringList `conf:"LD_FILTER_KEYS_"`
 }
 
+// WebhookConfig contains configuration parameters for a webhook that Relay calls when flags change or
+// when an environment's status changes.
+//
+// This corresponds to one of the [Webhook "name"] sections in the configuration file. In the
+// Config.Webhook map, each key is a name that is only used in log messages.
+//
+// Since configuration options can be set either programmatically, or from a file, or from environment
+// variables, individual fields are not documented here; instead, see the `README.md` section on
+// configuration.
+type WebhookConfig struct {
+	URL        ct.OptURLAbsolute        `conf:"LD_WEBHOOK_URL_"`
+	Secret     string                   `conf:"LD_WEBHOOK_SECRET_"`
+	Events     ct.OptStringList         `conf:"LD_WEBHOOK_EVENTS_"`
+	FlagKeys   ct.OptStringList         `conf:"LD_WEBHOOK_FLAG_KEYS_"`
+	MaxRetries ct.OptIntGreaterThanZero `conf:"LD_WEBHOOK_MAX_RETRIES_"`
+}
+
 // ProxyConfig represents all the supported proxy options.
 //
 // Since configuration options can be set either programmatically, or from a file, or from environment
@@ -287,11 +400,12 @@ type ProxyConfig struct {
 
 // MetricsConfig contains configurations for optional metrics integrations.
 //
-// This corresponds to the [Datadog], [Stackdriver], and [Prometheus] sections in the configuration file.
+// This corresponds to the [Datadog], [Stackdriver], [Prometheus], and [OTLP] sections in the configuration file.
 type MetricsConfig struct {
 	Datadog     DatadogConfig
 	Stackdriver StackdriverConfig
 	Prometheus  PrometheusConfig
+	OTLP        OTLPConfig
 }
 
 // DatadogConfig configures the optional Datadog integration, which is used only if Enabled is true.
@@ -330,7 +444,27 @@ type StackdriverConfig struct {
 // variables, individual fields are not documented here; instead, see the `README.md` section on
 // configuration.
 type PrometheusConfig struct {
-	Enabled bool                     `conf:"USE_PROMETHEUS"`
-	Prefix  string                   `conf:"PROMETHEUS_PREFIX"`
-	Port    ct.OptIntGreaterThanZero `conf:"PROMETHEUS_PORT"`
+	Enabled          bool                     `conf:"USE_PROMETHEUS"`
+	Prefix           string                   `conf:"PROMETHEUS_PREFIX"`
+	Port             ct.OptIntGreaterThanZero `conf:"PROMETHEUS_PORT"`
+	Native           bool                     `conf:"PROMETHEUS_NATIVE"`
+	UseAdminEndpoint bool                     `conf:"PROMETHEUS_USE_ADMIN_ENDPOINT"`
+}
+
+// OTLPConfig configures the optional OpenTelemetry integration, which sends metrics and traces to an
+// OpenTelemetry collector using the OTLP protocol. It is used only if Enabled is true.
+//
+// This corresponds to the [OTLP] section in the configuration file.
+//
+// Since configuration options can be set either programmatically, or from a file, or from environment
+// variables, individual fields are not documented here; instead, see the `README.md` section on
+// configuration.
+type OTLPConfig struct {
+	Enabled         bool          `conf:"USE_OTLP"`
+	Prefix          string        `conf:"OTLP_PREFIX"`
+	Endpoint        string        `conf:"OTLP_ENDPOINT"`
+	Protocol        OTLPProtocol  `conf:"OTLP_PROTOCOL"`
+	Insecure        bool          `conf:"OTLP_INSECURE"`
+	TraceSampleRate ct.OptFloat64 `conf:"OTLP_TRACE_SAMPLE_RATE"`
+	Header          []string      // special handling in LoadConfigFromEnvironment
 }
@@ -3,6 +3,8 @@ package config
 import (
 	"crypto/tls"
 	"fmt"
+	"net"
+	"strconv"
 	"strings"
 
 	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
@@ -16,6 +18,22 @@ func errBadTLSVersion(s string) error {
 	return fmt.Errorf("%q is not a valid TLS version", s)
 }
 
+func errBadLogFormat(s string) error {
+	return fmt.Errorf("%q is not a valid log format (must be %q or %q)", s, LogFormatText, LogFormatJSON)
+}
+
+func errBadAccessLogFormat(s string) error {
+	return fmt.Errorf("%q is not a valid access log format (must be %q or %q)", s, AccessLogFormatCommon, AccessLogFormatCombined)
+}
+
+func errBadListenAddress(s string) error {
+	return fmt.Errorf("%q is not a valid listen address (must be http://host:port, https://host:port, or unix:///path)", s)
+}
+
+func errBadOTLPProtocol(s string) error {
+	return fmt.Errorf("%q is not a valid OTLP protocol (must be %q or %q)", s, OTLPProtocolGRPC, OTLPProtocolHTTP)
+}
+
 // SDKKey is a type tag to indicate when a string is used as a server-side SDK key for a LaunchDarkly
 // environment.
 type SDKKey string
@@ -256,3 +274,128 @@ func (o OptTLSVersion) String() string {
 		return fmt.Sprintf("unknown (%d)", o.value)
 	}
 }
+
+// OTLPProtocol is the transport that Relay uses to send data to an OpenTelemetry collector. An empty
+// value means the default, OTLPProtocolGRPC.
+type OTLPProtocol string
+
+const (
+	// OTLPProtocolGRPC means that OTLP data is sent with gRPC.
+	OTLPProtocolGRPC OTLPProtocol = "grpc"
+
+	// OTLPProtocolHTTP means that OTLP data is sent as protobuf-encoded HTTP requests.
+	OTLPProtocolHTTP OTLPProtocol = "http"
+)
+
+// UnmarshalText allows the OTLPProtocol type to be set from environment variables. The value must be
+// "grpc" or "http" (case-insensitive), or an empty string.
+func (p *OTLPProtocol) UnmarshalText(data []byte) error {
+	value := OTLPProtocol(strings.ToLower(string(data)))
+	switch value {
+	case "", OTLPProtocolGRPC, OTLPProtocolHTTP:
+		*p = value
+		return nil
+	default:
+		return errBadOTLPProtocol(string(data))
+	}
+}
+
+// LogFormat is the format of Relay's log output. An empty value means the default, LogFormatText.
+type LogFormat string
+
+const (
+	// LogFormatText means that each log message is a line of plain text with a timestamp and level prefix.
+	LogFormatText LogFormat = "text"
+
+	// LogFormatJSON means that each log message is a single-line JSON object.
+	LogFormatJSON LogFormat = "json"
+)
+
+// UnmarshalText allows the LogFormat type to be set from environment variables. The value must be
+// "text" or "json" (case-insensitive), or an empty string.
+func (f *LogFormat) UnmarshalText(data []byte) error {
+	value := LogFormat(strings.ToLower(string(data)))
+	switch value {
+	case "", LogFormatText, LogFormatJSON:
+		*f = value
+		return nil
+	default:
+		return errBadLogFormat(string(data))
+	}
+}
+
+// AccessLogFormat is the format of the lines that Relay writes to its access log. An empty value means the
+// default, AccessLogFormatCombined.
+type AccessLogFormat string
+
+const (
+	// AccessLogFormatCommon is the NCSA Common Log Format.
+	AccessLogFormatCommon AccessLogFormat = "common"
+
+	// AccessLogFormatCombined is the NCSA Combined Log Format, which adds the Referer and User-Agent
+	// headers to the Common Log Format.
+	AccessLogFormatCombined AccessLogFormat = "combined"
+)
+
+// UnmarshalText allows the AccessLogFormat type to be set from environment variables. The value must be
+// "common" or "combined" (case-insensitive), or an empty string.
+func (f *AccessLogFormat) UnmarshalText(data []byte) error {
+	value := AccessLogFormat(strings.ToLower(string(data)))
+	switch value {
+	case "", AccessLogFormatCommon, AccessLogFormatCombined:
+		*f = value
+		return nil
+	default:
+		return errBadAccessLogFormat(string(data))
+	}
+}
+
+// ListenAddress is an address that Relay's HTTP server listens on, as specified by the Listen setting.
+type ListenAddress struct {
+	// Network is "tcp" or "unix", as used by net.Listen.
+	Network string
+
+	// Address is a "host:port" string for TCP, where the host can be empty to mean all interfaces, or
+	// a file path for a Unix domain socket.
+	Address string
+
+	// TLS is true if connections to this address should use TLS.
+	TLS bool
+}
+
+// ParseListenAddress parses an address in the format used by the Listen setting: "http://host:port"
+// for plaintext TCP, "https://host:port" for TCP with TLS, or "unix:///path/to/socket" for a Unix
+// domain socket. The host can be omitted, as in "http://:8030", to listen on all interfaces.
+func ParseListenAddress(s string) (ListenAddress, error) {
+	scheme, rest, ok := strings.Cut(s, "://")
+	if !ok || rest == "" {
+		return ListenAddress{}, errBadListenAddress(s)
+	}
+	switch strings.ToLower(scheme) {
+	case "http", "https":
+		_, portStr, err := net.SplitHostPort(rest)
+		if err != nil {
+			return ListenAddress{}, errBadListenAddress(s)
+		}
+		if port, err := strconv.Atoi(portStr); err != nil || port <= 0 || port > 65535 {
+			return ListenAddress{}, errBadListenAddress(s)
+		}
+		return ListenAddress{Network: "tcp", Address: rest, TLS: strings.EqualFold(scheme, "https")}, nil
+	case "unix":
+		return ListenAddress{Network: "unix", Address: rest}, nil
+	default:
+		return ListenAddress{}, errBadListenAddress(s)
+	}
+}
+
+// String returns the address in the format that is accepted by ParseListenAddress.
+func (a ListenAddress) String() string {
+	switch {
+	case a.Network == "unix":
+		return "unix://" + a.Address
+	case a.TLS:
+		return "https://" + a.Address
+	default:
+		return "http://" + a.Address
+	}
+}
@@ -91,3 +91,38 @@ func TestOptTLSVersion(t *testing.T) {
 		assert.Equal(t, "unknown (9999)", NewOptTLSVersion(9999).String())
 	})
 }
+
+func TestParseListenAddress(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		for _, val := range []struct {
+			s        string
+			expected ListenAddress
+		}{
+			{"http://:8030", ListenAddress{Network: "tcp", Address: ":8030"}},
+			{"http://127.0.0.1:8030", ListenAddress{Network: "tcp", Address: "127.0.0.1:8030"}},
+			{"HTTPS://[::1]:8443", ListenAddress{Network: "tcp", Address: "[::1]:8443", TLS: true}},
+			{"unix:///run/ld-relay.sock", ListenAddress{Network: "unix", Address: "/run/ld-relay.sock"}},
+		} {
+			t.Run(val.s, func(t *testing.T) {
+				a, err := ParseListenAddress(val.s)
+				assert.NoError(t, err)
+				assert.Equal(t, val.expected, a)
+			})
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, s := range []string{"", ":8030", "127.0.0.1:8030", "http://", "http://localhost", "http://:0",
+			"http://:99999", "http://:abc", "unix://", "tcp://:8030"} {
+			a, err := ParseListenAddress(s)
+			assert.Equal(t, errBadListenAddress(s), err, s)
+			assert.Equal(t, ListenAddress{}, a)
+		}
+	})
+
+	t.Run("get string value", func(t *testing.T) {
+		assert.Equal(t, "http://127.0.0.1:8030", ListenAddress{Network: "tcp", Address: "127.0.0.1:8030"}.String())
+		assert.Equal(t, "https://:8443", ListenAddress{Network: "tcp", Address: ":8443", TLS: true}.String())
+		assert.Equal(t, "unix:///run/ld-relay.sock", ListenAddress{Network: "unix", Address: "/run/ld-relay.sock"}.String())
+	})
+}
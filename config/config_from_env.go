@@ -89,6 +89,19 @@ func LoadConfigFromEnvironmentBase(c *Config, loggers ldlog.Loggers) ct.Validati
 		c.Filters[projKey] = &fc
 	}
 
+	for name := range reader.FindPrefixedValues("LD_WEBHOOK_URL_") {
+		var wc WebhookConfig
+		if c.Webhook[name] != nil {
+			wc = *c.Webhook[name]
+		}
+		subReader := reader.WithVarNameSuffix(name)
+		subReader.ReadStruct(&wc, false)
+		if c.Webhook == nil {
+			c.Webhook = make(map[string]*WebhookConfig)
+		}
+		c.Webhook[name] = &wc
+	}
+
 	useRedis := false
 	reader.Read("USE_REDIS", &useRedis)
 	if useRedis || c.Redis.Host != "" || c.Redis.URL.IsDefined() {
@@ -136,6 +149,8 @@ func LoadConfigFromEnvironmentBase(c *Config, loggers ldlog.Loggers) ct.Validati
 		reader.ReadStruct(&c.DynamoDB, false)
 	}
 
+	reader.ReadStruct(&c.Cluster, false)
+
 	reader.ReadStruct(&c.MetricsConfig.Datadog, false)
 	if c.MetricsConfig.Datadog.Enabled {
 		for tagName, tagVal := range reader.FindPrefixedValues("DATADOG_TAG_") {
@@ -147,6 +162,14 @@ func LoadConfigFromEnvironmentBase(c *Config, loggers ldlog.Loggers) ct.Validati
 	reader.ReadStruct(&c.MetricsConfig.Stackdriver, false)
 	reader.ReadStruct(&c.MetricsConfig.Prometheus, false)
 
+	reader.ReadStruct(&c.MetricsConfig.OTLP, false)
+	if c.MetricsConfig.OTLP.Enabled {
+		for headerName, headerVal := range reader.FindPrefixedValues("OTLP_HEADER_") {
+			c.MetricsConfig.OTLP.Header = append(c.MetricsConfig.OTLP.Header, headerName+":"+headerVal)
+		}
+		sort.Strings(c.MetricsConfig.OTLP.Header) // for test determinacy
+	}
+
 	reader.ReadStruct(&c.Proxy, false)
 
 	return reader.Result()
@@ -3,6 +3,7 @@ package config
 import (
 	"errors"
 	"fmt"
+	"path"
 	"strings"
 
 	ct "github.com/launchdarkly/go-configtypes"
@@ -11,12 +12,18 @@ import (
 
 var (
 	errTLSEnabledWithoutCertOrKey      = errors.New("TLS cert and key are required if TLS is enabled")
+	errTLSClientCAWithoutTLS           = errors.New("TLS client CA cannot be specified if TLS is not enabled")
+	errHTTPSListenerWithoutTLS         = errors.New("TLS must be enabled, with a cert and key, to use an https listen address")
+	errNonTLSListenerWithClientCA      = errors.New("http and unix listen addresses cannot be used with a TLS client CA, since their clients cannot present certificates")
+	errUpstreamRelayWithServiceURIs    = errors.New("base, client-side base, stream, and events URIs cannot be specified if an upstream Relay URI is set")
+	errDownstreamAutoConfWithoutRelays = errors.New("downstream auto-configuration keys cannot be specified if downstream Relays are not allowed")
 	errAutoConfPropertiesWithNoKey     = errors.New("must specify auto-configuration key if other auto-configuration properties are set")
 	errAutoConfWithEnvironments        = errors.New("cannot configure specific environments if auto-configuration is enabled")
 	errFileDataWithAutoConf            = errors.New("cannot specify both auto-configuration key and file data source")
 	errOfflineModePropertiesWithNoFile = errors.New("must specify offline mode filename if other offline mode properties are set")
 	errOfflineModeWithEnvironments     = errors.New("cannot configure specific environments if offline mode is enabled")
 	errMaxInboundPayloadSize           = errors.New("max inbound payload size must be greater than zero")
+	errAccessLogMaxSize                = errors.New("access log max size must be greater than zero")
 	errAutoConfWithoutDBDisambig       = errors.New(`when using auto-configuration with database storage, database prefix (or,` +
 		` if using DynamoDB, table name) must be specified and must contain "` + AutoConfigEnvironmentIDPlaceholder + `"`)
 	errRedisURLWithHostAndPort                 = errors.New("please specify Redis URL or host/port, but not both")
@@ -25,13 +32,24 @@ var (
 	errAutoConfWithFilters                     = errors.New("cannot configure filters if auto-configuration is enabled")
 	errMissingProjKey                          = errors.New("when filters are configured, all environments must specify a 'projKey'")
 	errInvalidFileDataSourceMonitoringInterval = fmt.Errorf("file data source monitoring interval must be >= %s", minimumFileDataSourceMonitoringInterval)
+	errOTLPTraceSampleRate                     = errors.New("OTLP trace sample rate must be between 0 and 1")
+	errPrometheusAdminEndpointWithoutNative    = errors.New("the Prometheus admin endpoint requires the native Prometheus exporter to be enabled")
+	errPrometheusAdminEndpointWithoutAdminKey  = errors.New("the Prometheus admin endpoint requires an admin key")
 	errInvalidCredentialCleanupInterval        = fmt.Errorf("expired credential cleanup interval must be >= %s", minimumCredentialCleanupInterval)
+	errClusterWithoutRedis                     = errors.New("cluster mode requires either a cluster Redis URL or a Redis data store to be configured")
+	errClusterWithOfflineMode                  = errors.New("cluster mode cannot be used with offline mode")
+	errClusterLeaderTimeout                    = errors.New("cluster leader timeout must be greater than the cluster heartbeat interval")
+	errInvalidStoreConsistencyCheckInterval    = fmt.Errorf("data store consistency check interval must be >= %s", minimumStoreConsistencyCheckInterval)
 )
 
 func errEnvironmentWithNoSDKKey(envName string) error {
 	return fmt.Errorf("SDK key is required for environment %q", envName)
 }
 
+func errEnvAllowedClientCertNamesWithoutClientCA(envName string) error {
+	return fmt.Errorf("environment %q specifies allowed client certificate names, but TLS client CA is not configured", envName)
+}
+
 func errMultipleDatabases(databases []string) error {
 	return fmt.Errorf("multiple databases are enabled (%s); only one is allowed", strings.Join(databases, ", "))
 }
@@ -55,6 +73,19 @@ func errFilterInvalidKey(projKey string, i int) error {
 	return fmt.Errorf("filter key [%d] for project '%s' is malformed (note: lists are comma-delimited)", i, projKey)
 }
 
+func errWebhookWithoutURL(name string) error {
+	return fmt.Errorf("webhook %q must have a URL", name)
+}
+
+func errWebhookUnknownEvent(name, event string) error {
+	return fmt.Errorf("webhook %q has unknown event type %q (must be %q or %q)", name, event,
+		WebhookEventChanges, WebhookEventStatus)
+}
+
+func errWebhookInvalidFlagKeyPattern(name, pattern string) error {
+	return fmt.Errorf("webhook %q has invalid flag key pattern %q", name, pattern)
+}
+
 func warnEnvWithoutDBDisambiguation(envName string, canUseTableName bool) string {
 	return errEnvWithoutDBDisambiguation(envName, canUseTableName).Error() +
 		"; this would be an error if multiple environments were configured"
@@ -74,18 +105,48 @@ func warnEnvWithoutDBDisambiguation(envName string, canUseTableName bool) string
 func ValidateConfig(c *Config, loggers ldlog.Loggers) error {
 	var result ct.ValidationResult
 
+	validateConfigUpstreamRelay(&result, c)
 	validateConfigDefaultURLs(c)
 	validateConfigTLS(&result, c)
+	validateConfigListen(&result, c)
 	validateConfigEnvironments(&result, c)
 	validateConfigDatabases(&result, c, loggers)
+	validateConfigCluster(&result, c)
 	validateConfigFilters(&result, c)
+	validateConfigWebhooks(&result, c)
 	validateOfflineMode(&result, c)
 	validateCredentialCleanupInterval(&result, c)
 	validateMaxInboundPayloadSize(&result, c)
+	validateAccessLogMaxSize(&result, c)
+	validateConfigOTLP(&result, c)
+	validateConfigPrometheus(&result, c)
 
 	return result.GetError()
 }
 
+func validateConfigUpstreamRelay(result *ct.ValidationResult, c *Config) {
+	if len(c.Main.DownstreamAutoConfigKeys.Values()) != 0 && !c.Main.AllowDownstreamRelays {
+		result.AddError(nil, errDownstreamAutoConfWithoutRelays)
+	}
+	if !c.Main.UpstreamRelayURI.IsDefined() {
+		return
+	}
+	// An upstream Relay provides all of the services that we would otherwise get from LaunchDarkly, so it
+	// replaces all of the service URIs. We allow them to already be set to the same value, since this
+	// function may be called more than once for the same Config.
+	upstream := c.Main.UpstreamRelayURI
+	for _, uri := range []ct.OptURLAbsolute{c.Main.BaseURI, c.Main.ClientSideBaseURI, c.Main.StreamURI, c.Events.EventsURI} {
+		if uri.IsDefined() && uri.String() != upstream.String() {
+			result.AddError(nil, errUpstreamRelayWithServiceURIs)
+			return
+		}
+	}
+	c.Main.BaseURI = upstream
+	c.Main.ClientSideBaseURI = upstream
+	c.Main.StreamURI = upstream
+	c.Events.EventsURI = upstream
+}
+
 func validateConfigDefaultURLs(c *Config) {
 	switch {
 	case !c.Main.BaseURI.IsDefined(),
@@ -115,6 +176,27 @@ func validateConfigTLS(result *ct.ValidationResult, c *Config) {
 	if c.Main.TLSEnabled && (c.Main.TLSCert == "" || c.Main.TLSKey == "") {
 		result.AddError(nil, errTLSEnabledWithoutCertOrKey)
 	}
+	if !c.Main.TLSEnabled && len(c.Main.TLSClientCA.Values()) != 0 {
+		result.AddError(nil, errTLSClientCAWithoutTLS)
+	}
+	for envName, envConfig := range c.Environment {
+		if len(envConfig.AllowedClientCertNames.Values()) != 0 && len(c.Main.TLSClientCA.Values()) == 0 {
+			result.AddError(nil, errEnvAllowedClientCertNamesWithoutClientCA(envName))
+		}
+	}
+}
+
+func validateConfigListen(result *ct.ValidationResult, c *Config) {
+	for _, s := range c.Main.Listen.Values() {
+		address, err := ParseListenAddress(s)
+		if err != nil {
+			result.AddError(nil, err)
+		} else if address.TLS && !c.Main.TLSEnabled {
+			result.AddError(nil, errHTTPSListenerWithoutTLS)
+		} else if !address.TLS && len(c.Main.TLSClientCA.Values()) != 0 {
+			result.AddError(nil, errNonTLSListenerWithClientCA)
+		}
+	}
 }
 
 func validateConfigEnvironments(result *ct.ValidationResult, c *Config) {
@@ -147,6 +229,24 @@ func validateConfigEnvironments(result *ct.ValidationResult, c *Config) {
 	}
 }
 
+func validateConfigWebhooks(result *ct.ValidationResult, c *Config) {
+	for name, wc := range c.Webhook {
+		if !wc.URL.IsDefined() {
+			result.AddError(nil, errWebhookWithoutURL(name))
+		}
+		for _, event := range wc.Events.Values() {
+			if event != WebhookEventChanges && event != WebhookEventStatus {
+				result.AddError(nil, errWebhookUnknownEvent(name, event))
+			}
+		}
+		for _, pattern := range wc.FlagKeys.Values() {
+			if _, err := path.Match(pattern, ""); err != nil {
+				result.AddError(nil, errWebhookInvalidFlagKeyPattern(name, pattern))
+			}
+		}
+	}
+}
+
 func validateConfigFilters(result *ct.ValidationResult, c *Config) {
 	if len(c.Filters) == 0 {
 		return
@@ -218,6 +318,14 @@ func validateMaxInboundPayloadSize(result *ct.ValidationResult, c *Config) {
 	}
 }
 
+func validateAccessLogMaxSize(result *ct.ValidationResult, c *Config) {
+	if c.Main.AccessLogMaxSize.IsDefined() {
+		if c.Main.AccessLogMaxSize.GetOrElse(0) <= 0 {
+			result.AddError(nil, errAccessLogMaxSize)
+		}
+	}
+}
+
 func validateConfigDatabases(result *ct.ValidationResult, c *Config, loggers ldlog.Loggers) {
 	normalizeRedisConfig(result, c)
 
@@ -246,6 +354,14 @@ func validateConfigDatabases(result *ct.ValidationResult, c *Config, loggers ldl
 		}
 	}
 
+	for _, interval := range []ct.OptDuration{
+		c.Redis.ConsistencyCheckInterval, c.Consul.ConsistencyCheckInterval, c.DynamoDB.ConsistencyCheckInterval,
+	} {
+		if interval.IsDefined() && interval.GetOrElse(0) < minimumStoreConsistencyCheckInterval {
+			result.AddError(nil, errInvalidStoreConsistencyCheckInterval)
+		}
+	}
+
 	// When using a database, if there is more than one environment configured, they must be distinguished by
 	// different prefixes (or, when using DynamoDB, you can use different table names). In auto-config mode,
 	// we must assume that there are multiple environments.
@@ -273,6 +389,23 @@ func validateConfigDatabases(result *ct.ValidationResult, c *Config, loggers ldl
 	}
 }
 
+func validateConfigCluster(result *ct.ValidationResult, c *Config) {
+	if !c.Cluster.Enabled {
+		return
+	}
+	// validateConfigDatabases has already converted any Redis host/port settings to a URL
+	if !c.Cluster.RedisURL.IsDefined() && !c.Redis.URL.IsDefined() {
+		result.AddError(nil, errClusterWithoutRedis)
+	}
+	if c.OfflineMode.FileDataSource != "" {
+		result.AddError(nil, errClusterWithOfflineMode)
+	}
+	heartbeatInterval := c.Cluster.HeartbeatInterval.GetOrElse(DefaultClusterHeartbeatInterval)
+	if c.Cluster.LeaderTimeout.GetOrElse(DefaultClusterLeaderTimeout) <= heartbeatInterval {
+		result.AddError(nil, errClusterLeaderTimeout)
+	}
+}
+
 func normalizeRedisConfig(result *ct.ValidationResult, c *Config) {
 	if c.Redis.URL.IsDefined() {
 		if c.Redis.Host != "" || c.Redis.Port.IsDefined() {
@@ -293,3 +426,22 @@ func normalizeRedisConfig(result *ct.ValidationResult, c *Config) {
 		c.Redis.Port = ct.OptIntGreaterThanZero{}
 	}
 }
+
+func validateConfigPrometheus(result *ct.ValidationResult, c *Config) {
+	if c.Prometheus.Enabled && c.Prometheus.UseAdminEndpoint {
+		if !c.Prometheus.Native {
+			result.AddError(nil, errPrometheusAdminEndpointWithoutNative)
+		}
+		if c.Main.AdminKey == "" {
+			result.AddError(nil, errPrometheusAdminEndpointWithoutAdminKey)
+		}
+	}
+}
+
+func validateConfigOTLP(result *ct.ValidationResult, c *Config) {
+	if c.OTLP.TraceSampleRate.IsDefined() {
+		if rate := c.OTLP.TraceSampleRate.GetOrElse(0); rate < 0 || rate > 1 {
+			result.AddError(nil, errOTLPTraceSampleRate)
+		}
+	}
+}
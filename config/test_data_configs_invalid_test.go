@@ -18,6 +18,13 @@ func makeInvalidConfigs() []testDataInvalidConfig {
 		makeInvalidConfigTLSWithNoCert(),
 		makeInvalidConfigTLSWithNoKey(),
 		makeInvalidConfigTLSVersion(),
+		makeInvalidConfigTLSClientCAWithoutTLS(),
+		makeInvalidConfigAllowedClientCertNamesWithoutClientCA(),
+		makeInvalidConfigListenAddress(),
+		makeInvalidConfigHTTPSListenAddressWithoutTLS(),
+		makeInvalidConfigNonTLSListenAddressWithClientCA(),
+		makeInvalidConfigDownstreamAutoConfigKeysWithoutDownstreamRelays(),
+		makeInvalidConfigUpstreamRelayWithServiceURI(),
 		makeInvalidConfigAutoConfKeyWithEnvironments(),
 		makeInvalidConfigAutoConfAllowedOriginWithNoKey(),
 		makeInvalidConfigAutoConfAllowedHeaderWithNoKey(),
@@ -43,6 +50,17 @@ func makeInvalidConfigs() []testDataInvalidConfig {
 		makeInvalidConfigDynamoDBNoPrefixOrTableName(),
 		makeInvalidConfigDynamoDBAutoConfNoPrefixOrTableName(),
 		makeInvalidConfigMultipleDatabases(),
+		makeInvalidConfigClusterWithoutRedis(),
+		makeInvalidConfigClusterLeaderTimeout(),
+		makeInvalidConfigStoreConsistencyCheckInterval(),
+		makeInvalidConfigLogFormat(),
+		makeInvalidConfigAccessLogFormat(),
+		makeInvalidConfigOTLPProtocol(),
+		makeInvalidConfigOTLPTraceSampleRate(),
+		makeInvalidConfigPrometheusAdminEndpointWithoutNative(),
+		makeInvalidConfigPrometheusAdminEndpointWithoutAdminKey(),
+		makeInvalidConfigWebhookUnknownEvent(),
+		makeInvalidConfigWebhookFlagKeyPattern(),
 	}
 }
 
@@ -103,6 +121,100 @@ TLSMinVersion = x
 	return c
 }
 
+func makeInvalidConfigTLSClientCAWithoutTLS() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "TLS client CA without TLS"}
+	c.envVarsError = "TLS client CA cannot be specified if TLS is not enabled"
+	c.envVars = map[string]string{"TLS_CLIENT_CA": "ca.pem"}
+	c.fileContent = `
+[Main]
+TLSClientCA = ca.pem
+`
+	return c
+}
+
+func makeInvalidConfigAllowedClientCertNamesWithoutClientCA() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "allowed client cert names without TLS client CA"}
+	c.envVarsError = `environment "earth" specifies allowed client certificate names, but TLS client CA is not configured`
+	c.envVars = map[string]string{"LD_ENV_earth": "key", "LD_ALLOWED_CLIENT_CERT_NAMES_earth": "service-a"}
+	c.fileContent = `
+[Environment "earth"]
+SDKKey = key
+AllowedClientCertNames = service-a
+`
+	return c
+}
+
+func makeInvalidConfigListenAddress() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "bad listen address"}
+	c.envVarsError = "not a valid listen address"
+	c.envVars = map[string]string{"LISTEN": "http://:8030,localhost:8031"}
+	c.fileContent = `
+[Main]
+Listen = "http://:8030"
+Listen = "localhost:8031"
+`
+	return c
+}
+
+func makeInvalidConfigHTTPSListenAddressWithoutTLS() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "https listen address without TLS"}
+	c.envVarsError = "TLS must be enabled, with a cert and key, to use an https listen address"
+	c.envVars = map[string]string{"LISTEN": "https://:8443"}
+	c.fileContent = `
+[Main]
+Listen = "https://:8443"
+`
+	return c
+}
+
+func makeInvalidConfigNonTLSListenAddressWithClientCA() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "http listen address with TLS client CA"}
+	c.envVarsError = errNonTLSListenerWithClientCA.Error()
+	c.envVars = map[string]string{
+		"TLS_ENABLED":   "true",
+		"TLS_CERT":      "cert",
+		"TLS_KEY":       "key",
+		"TLS_CLIENT_CA": "ca.pem",
+		"LISTEN":        "https://:8443,unix:///tmp/relay.sock",
+	}
+	c.fileContent = `
+[Main]
+TLSEnabled = true
+TLSCert = "cert"
+TLSKey = "key"
+TLSClientCA = "ca.pem"
+Listen = "https://:8443"
+Listen = "unix:///tmp/relay.sock"
+`
+	return c
+}
+
+func makeInvalidConfigDownstreamAutoConfigKeysWithoutDownstreamRelays() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "downstream auto-config keys without downstream Relays"}
+	c.envVarsError = errDownstreamAutoConfWithoutRelays.Error()
+	c.envVars = map[string]string{"DOWNSTREAM_AUTO_CONFIG_KEYS": "rel-abc"}
+	c.fileContent = `
+[Main]
+DownstreamAutoConfigKeys = rel-abc
+`
+	return c
+}
+
+func makeInvalidConfigUpstreamRelayWithServiceURI() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "upstream Relay URI with stream URI"}
+	c.envVarsError = errUpstreamRelayWithServiceURIs.Error()
+	c.envVars = map[string]string{
+		"UPSTREAM_RELAY_URI": "http://regional-relay:8030",
+		"STREAM_URI":         "http://stream",
+	}
+	c.fileContent = `
+[Main]
+UpstreamRelayURI = http://regional-relay:8030
+StreamURI = http://stream
+`
+	return c
+}
+
 func makeInvalidConfigAutoConfKeyWithEnvironments() testDataInvalidConfig {
 	c := testDataInvalidConfig{name: "auto-conf key with environments"}
 	c.envVarsError = errAutoConfWithEnvironments.Error()
@@ -467,3 +579,158 @@ Enabled = true
 `
 	return c
 }
+
+func makeInvalidConfigLogFormat() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "bad log format"}
+	c.envVarsError = "not a valid log format"
+	c.envVars = map[string]string{"LOG_FORMAT": "xml"}
+	c.fileContent = `
+[Main]
+LogFormat = xml
+`
+	return c
+}
+
+func makeInvalidConfigAccessLogFormat() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "bad access log format"}
+	c.envVarsError = "not a valid access log format"
+	c.envVars = map[string]string{"ACCESS_LOG": "stdout", "ACCESS_LOG_FORMAT": "extended"}
+	c.fileContent = `
+[Main]
+AccessLog = stdout
+AccessLogFormat = extended
+`
+	return c
+}
+
+func makeInvalidConfigOTLPProtocol() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "OTLP - bad protocol"}
+	c.envVarsError = "not a valid OTLP protocol"
+	c.envVars = map[string]string{"USE_OTLP": "1", "OTLP_PROTOCOL": "carrier-pigeon"}
+	c.fileContent = `
+[OTLP]
+Enabled = true
+Protocol = carrier-pigeon
+`
+	return c
+}
+
+func makeInvalidConfigOTLPTraceSampleRate() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "OTLP - trace sample rate out of range"}
+	c.envVarsError = errOTLPTraceSampleRate.Error()
+	c.envVars = map[string]string{"USE_OTLP": "1", "OTLP_TRACE_SAMPLE_RATE": "1.5"}
+	c.fileContent = `
+[OTLP]
+Enabled = true
+TraceSampleRate = 1.5
+`
+	return c
+}
+
+func makeInvalidConfigPrometheusAdminEndpointWithoutNative() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "Prometheus admin endpoint without native exporter"}
+	c.envVarsError = errPrometheusAdminEndpointWithoutNative.Error()
+	c.envVars = map[string]string{"ADMIN_KEY": "admin-key", "USE_PROMETHEUS": "1", "PROMETHEUS_USE_ADMIN_ENDPOINT": "1"}
+	c.fileContent = `
+[Main]
+AdminKey = "admin-key"
+
+[Prometheus]
+Enabled = true
+UseAdminEndpoint = true
+`
+	return c
+}
+
+func makeInvalidConfigPrometheusAdminEndpointWithoutAdminKey() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "Prometheus admin endpoint without admin key"}
+	c.envVarsError = errPrometheusAdminEndpointWithoutAdminKey.Error()
+	c.envVars = map[string]string{"USE_PROMETHEUS": "1", "PROMETHEUS_NATIVE": "1", "PROMETHEUS_USE_ADMIN_ENDPOINT": "1"}
+	c.fileContent = `
+[Prometheus]
+Enabled = true
+Native = true
+UseAdminEndpoint = true
+`
+	return c
+}
+
+func makeInvalidConfigWebhookUnknownEvent() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "webhook with unknown event type"}
+	c.envVarsError = errWebhookUnknownEvent("hook", "deploys").Error()
+	c.envVars = map[string]string{
+		"LD_WEBHOOK_URL_hook":    "https://hook",
+		"LD_WEBHOOK_EVENTS_hook": "changes,deploys",
+	}
+	c.fileContent = `
+[Webhook "hook"]
+URL = "https://hook"
+Events = "changes"
+Events = "deploys"
+`
+	return c
+}
+
+func makeInvalidConfigWebhookFlagKeyPattern() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "webhook with invalid flag key pattern"}
+	c.envVarsError = errWebhookInvalidFlagKeyPattern("hook", "billing-[").Error()
+	c.envVars = map[string]string{
+		"LD_WEBHOOK_URL_hook":       "https://hook",
+		"LD_WEBHOOK_FLAG_KEYS_hook": "billing-[",
+	}
+	c.fileContent = `
+[Webhook "hook"]
+URL = "https://hook"
+FlagKeys = "billing-["
+`
+	return c
+}
+
+func makeInvalidConfigClusterWithoutRedis() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "cluster mode without Redis"}
+	c.envVarsError = errClusterWithoutRedis.Error()
+	c.envVars = map[string]string{
+		"CLUSTER_ENABLED": "1",
+	}
+	c.fileContent = `
+[Cluster]
+Enabled = true
+`
+	return c
+}
+
+func makeInvalidConfigClusterLeaderTimeout() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "cluster leader timeout not greater than heartbeat interval"}
+	c.envVarsError = errClusterLeaderTimeout.Error()
+	c.envVars = map[string]string{
+		"USE_REDIS":                  "1",
+		"CLUSTER_ENABLED":            "1",
+		"CLUSTER_HEARTBEAT_INTERVAL": "5s",
+		"CLUSTER_LEADER_TIMEOUT":     "5s",
+	}
+	c.fileContent = `
+[Redis]
+Host = "localhost"
+
+[Cluster]
+Enabled = true
+HeartbeatInterval = 5s
+LeaderTimeout = 5s
+`
+	return c
+}
+
+func makeInvalidConfigStoreConsistencyCheckInterval() testDataInvalidConfig {
+	c := testDataInvalidConfig{name: "store consistency check interval too short"}
+	c.envVarsError = errInvalidStoreConsistencyCheckInterval.Error()
+	c.envVars = map[string]string{
+		"USE_REDIS":                        "1",
+		"STORE_CONSISTENCY_CHECK_INTERVAL": "500ms",
+	}
+	c.fileContent = `
+[Redis]
+Host = "localhost"
+ConsistencyCheckInterval = 500ms
+`
+	return c
+}
@@ -93,7 +93,17 @@ func makeValidConfigs() []testDataValidConfig {
 		makeValidConfigStackdriverAll(),
 		makeValidConfigPrometheusMinimal(),
 		makeValidConfigPrometheusAll(),
+		makeValidConfigPrometheusNativeAdminEndpoint(),
+		makeValidConfigAccessLog(),
+		makeValidConfigTLSClientCerts(),
+		makeValidConfigListen(),
+		makeValidConfigHTTP2(),
+		makeValidConfigUpstreamRelay(),
+		makeValidConfigCluster(),
+		makeValidConfigOTLPMinimal(),
+		makeValidConfigOTLPAll(),
 		makeValidConfigProxy(),
+		makeValidConfigWebhooks(),
 	}
 }
 
@@ -116,9 +126,22 @@ func makeValidConfigAllBaseProperties() testDataValidConfig {
 			TLSKey:                           "key",
 			TLSMinVersion:                    NewOptTLSVersion(tls.VersionTLS12),
 			LogLevel:                         NewOptLogLevel(ldlog.Warn),
+			LogFormat:                        LogFormatJSON,
+			LogRequests:                      true,
 			BigSegmentsStaleAsDegraded:       true,
 			BigSegmentsStaleThreshold:        ct.NewOptDuration(10 * time.Minute),
 			ExpiredCredentialCleanupInterval: ct.NewOptDuration(1 * time.Minute),
+			AdminKey:                         "admin-key",
+			MaxStreamConnections:             mustOptIntGreaterThanZero(10000),
+			MaxEnvStreamConnections:          mustOptIntGreaterThanZero(2000),
+			ShedOldestStreamConnections:      true,
+			StreamConnectionRetryAfter:       ct.NewOptDuration(45 * time.Second),
+			MaxStreamQueuedEvents:            mustOptIntGreaterThanZero(50),
+			StreamWriteTimeout:               ct.NewOptDuration(10 * time.Second),
+			DisableStreamCompression:         true,
+			StaleWhileUnavailable:            true,
+			ChangeFeedSize:                   mustOptIntGreaterThanZero(500),
+			ChangeFeedFile:                   "changes.jsonl",
 		}
 		c.Events = EventsConfig{
 			SendEvents:            true,
@@ -130,12 +153,13 @@ func makeValidConfigAllBaseProperties() testDataValidConfig {
 		}
 		c.Environment = map[string]*EnvConfig{
 			"earth": {
-				SDKKey:    "earth-sdk",
-				MobileKey: "earth-mob",
-				EnvID:     "earth-env",
-				Prefix:    "earth-",
-				TableName: "earth-table",
-				LogLevel:  NewOptLogLevel(ldlog.Debug),
+				SDKKey:               "earth-sdk",
+				MobileKey:            "earth-mob",
+				EnvID:                "earth-env",
+				Prefix:               "earth-",
+				TableName:            "earth-table",
+				LogLevel:             NewOptLogLevel(ldlog.Debug),
+				MaxStreamConnections: mustOptIntGreaterThanZero(500),
 			},
 			"krypton": {
 				SDKKey:        "krypton-sdk",
@@ -166,6 +190,8 @@ func makeValidConfigAllBaseProperties() testDataValidConfig {
 		"TLS_KEY":                             "key",
 		"TLS_MIN_VERSION":                     "1.2",
 		"LOG_LEVEL":                           "warn",
+		"LOG_FORMAT":                          "JSON",
+		"LOG_REQUESTS":                        "1",
 		"BIG_SEGMENTS_STALE_AS_DEGRADED":      "true",
 		"BIG_SEGMENTS_STALE_THRESHOLD":        "10m",
 		"USE_EVENTS":                          "1",
@@ -189,6 +215,18 @@ func makeValidConfigAllBaseProperties() testDataValidConfig {
 		"LD_ALLOWED_HEADER_krypton":           "Timestamp-Valid,Random-Id-Valid",
 		"LD_TTL_krypton":                      "5m",
 		"EXPIRED_CREDENTIAL_CLEANUP_INTERVAL": "1m",
+		"MAX_STREAM_CONNECTIONS":              "10000",
+		"MAX_ENV_STREAM_CONNECTIONS":          "2000",
+		"SHED_OLDEST_STREAM_CONNECTIONS":      "1",
+		"STREAM_CONNECTION_RETRY_AFTER":       "45s",
+		"MAX_STREAM_QUEUED_EVENTS":            "50",
+		"STREAM_WRITE_TIMEOUT":                "10s",
+		"DISABLE_STREAM_COMPRESSION":          "1",
+		"ADMIN_KEY":                           "admin-key",
+		"STALE_WHILE_UNAVAILABLE":             "1",
+		"CHANGE_FEED_SIZE":                    "500",
+		"CHANGE_FEED_FILE":                    "changes.jsonl",
+		"LD_MAX_STREAM_CONNECTIONS_earth":     "500",
 	}
 	c.fileContent = `
 [Main]
@@ -207,9 +245,22 @@ TLSCert = "cert"
 TLSKey = "key"
 TLSMinVersion = "1.2"
 LogLevel = "warn"
+LogFormat = "json"
+LogRequests = 1
 BigSegmentsStaleAsDegraded = 1
 BigSegmentsStaleThreshold = 10m
 ExpiredCredentialCleanupInterval = 1m
+MaxStreamConnections = 10000
+MaxEnvStreamConnections = 2000
+ShedOldestStreamConnections = 1
+StreamConnectionRetryAfter = 45s
+MaxStreamQueuedEvents = 50
+StreamWriteTimeout = 10s
+DisableStreamCompression = 1
+AdminKey = "admin-key"
+StaleWhileUnavailable = 1
+ChangeFeedSize = 500
+ChangeFeedFile = "changes.jsonl"
 
 [Events]
 SendEvents = 1
@@ -225,6 +276,7 @@ EnvId = "earth-env"
 Prefix = "earth-"
 TableName = "earth-table"
 LogLevel = "debug"
+MaxStreamConnections = 500
 
 [Environment "krypton"]
 SdkKey = "krypton-sdk"
@@ -427,21 +479,27 @@ func makeValidConfigRedisAll() testDataValidConfig {
 	c := testDataValidConfig{name: "Redis - all parameters"}
 	c.makeConfig = func(c *Config) {
 		c.Redis = RedisConfig{
-			URL:      newOptURLAbsoluteMustBeValid("redis://redishost:6400"),
-			LocalTTL: ct.NewOptDuration(3 * time.Second),
-			TLS:      true,
-			Password: "pass",
-			Username: "user",
+			URL:                      newOptURLAbsoluteMustBeValid("redis://redishost:6400"),
+			LocalTTL:                 ct.NewOptDuration(3 * time.Second),
+			TLS:                      true,
+			Password:                 "pass",
+			Username:                 "user",
+			LeaderElection:           true,
+			ConsistencyCheckInterval: ct.NewOptDuration(5 * time.Minute),
+			ConsistencyCheckRepair:   true,
 		}
 	}
 	c.envVars = map[string]string{
-		"USE_REDIS":      "1",
-		"REDIS_HOST":     "redishost",
-		"REDIS_PORT":     "6400",
-		"REDIS_TLS":      "1",
-		"REDIS_PASSWORD": "pass",
-		"REDIS_USERNAME": "user",
-		"CACHE_TTL":      "3s",
+		"USE_REDIS":                        "1",
+		"REDIS_HOST":                       "redishost",
+		"REDIS_PORT":                       "6400",
+		"REDIS_TLS":                        "1",
+		"REDIS_PASSWORD":                   "pass",
+		"REDIS_USERNAME":                   "user",
+		"CACHE_TTL":                        "3s",
+		"STORE_LEADER_ELECTION":            "1",
+		"STORE_CONSISTENCY_CHECK_INTERVAL": "5m",
+		"STORE_CONSISTENCY_CHECK_REPAIR":   "1",
 	}
 	c.fileContent = `
 [Redis]
@@ -451,6 +509,9 @@ TLS = 1
 Password = "pass"
 Username = "user"
 LocalTTL = 3s
+LeaderElection = true
+ConsistencyCheckInterval = 5m
+ConsistencyCheckRepair = true
 `
 	return c
 }
@@ -553,19 +614,28 @@ func makeValidConfigConsulAll() testDataValidConfig {
 	c.makeConfig =
 		func(c *Config) {
 			c.Consul = ConsulConfig{
-				Host:     "consulhost",
-				LocalTTL: ct.NewOptDuration(3 * time.Second),
+				Host:                     "consulhost",
+				LocalTTL:                 ct.NewOptDuration(3 * time.Second),
+				LeaderElection:           true,
+				ConsistencyCheckInterval: ct.NewOptDuration(5 * time.Minute),
+				ConsistencyCheckRepair:   true,
 			}
 		}
 	c.envVars = map[string]string{
-		"USE_CONSUL":  "1",
-		"CONSUL_HOST": "consulhost",
-		"CACHE_TTL":   "3s",
+		"USE_CONSUL":                       "1",
+		"CONSUL_HOST":                      "consulhost",
+		"CACHE_TTL":                        "3s",
+		"STORE_LEADER_ELECTION":            "1",
+		"STORE_CONSISTENCY_CHECK_INTERVAL": "5m",
+		"STORE_CONSISTENCY_CHECK_REPAIR":   "1",
 	}
 	c.fileContent = `
 [Consul]
 Host = "consulhost"
 LocalTTL = 3s
+LeaderElection = true
+ConsistencyCheckInterval = 5m
+ConsistencyCheckRepair = true
 `
 	return c
 }
@@ -657,17 +727,23 @@ func makeValidConfigDynamoDBAll() testDataValidConfig {
 	c := testDataValidConfig{name: "DynamoDB - all parameters"}
 	c.makeConfig = func(c *Config) {
 		c.DynamoDB = DynamoDBConfig{
-			Enabled:   true,
-			TableName: "table",
-			URL:       newOptURLAbsoluteMustBeValid("http://localhost:8000"),
-			LocalTTL:  ct.NewOptDuration(3 * time.Second),
+			Enabled:                  true,
+			TableName:                "table",
+			URL:                      newOptURLAbsoluteMustBeValid("http://localhost:8000"),
+			LocalTTL:                 ct.NewOptDuration(3 * time.Second),
+			LeaderElection:           true,
+			ConsistencyCheckInterval: ct.NewOptDuration(5 * time.Minute),
+			ConsistencyCheckRepair:   true,
 		}
 	}
 	c.envVars = map[string]string{
-		"USE_DYNAMODB":   "1",
-		"DYNAMODB_TABLE": "table",
-		"DYNAMODB_URL":   "http://localhost:8000",
-		"CACHE_TTL":      "3s",
+		"USE_DYNAMODB":                     "1",
+		"DYNAMODB_TABLE":                   "table",
+		"DYNAMODB_URL":                     "http://localhost:8000",
+		"CACHE_TTL":                        "3s",
+		"STORE_LEADER_ELECTION":            "1",
+		"STORE_CONSISTENCY_CHECK_INTERVAL": "5m",
+		"STORE_CONSISTENCY_CHECK_REPAIR":   "1",
 	}
 	c.fileContent = `
 [DynamoDB]
@@ -675,6 +751,9 @@ Enabled = true
 TableName = "table"
 URL = "http://localhost:8000"
 LocalTTL = 3s
+LeaderElection = true
+ConsistencyCheckInterval = 5m
+ConsistencyCheckRepair = true
 `
 	return c
 }
@@ -865,6 +944,254 @@ Port = 8333
 	return c
 }
 
+func makeValidConfigPrometheusNativeAdminEndpoint() testDataValidConfig {
+	c := testDataValidConfig{name: "Prometheus - native exporter on admin endpoint"}
+	c.makeConfig = func(c *Config) {
+		c.Main.AdminKey = "admin-key"
+		c.Prometheus = PrometheusConfig{
+			Enabled:          true,
+			Native:           true,
+			UseAdminEndpoint: true,
+		}
+	}
+	c.envVars = map[string]string{
+		"ADMIN_KEY":                     "admin-key",
+		"USE_PROMETHEUS":                "1",
+		"PROMETHEUS_NATIVE":             "1",
+		"PROMETHEUS_USE_ADMIN_ENDPOINT": "1",
+	}
+	c.fileContent = `
+[Main]
+AdminKey = "admin-key"
+
+[Prometheus]
+Enabled = true
+Native = true
+UseAdminEndpoint = true
+`
+	return c
+}
+
+func makeValidConfigAccessLog() testDataValidConfig {
+	maxSize, _ := ct.NewOptBase2BytesFromString("10MiB")
+	c := testDataValidConfig{name: "access log"}
+	c.makeConfig = func(c *Config) {
+		c.Main.AccessLog = "/var/log/relay-access.log"
+		c.Main.AccessLogFormat = AccessLogFormatCommon
+		c.Main.AccessLogMaxSize = maxSize
+		c.Main.AccessLogMaxBackups = mustOptIntGreaterThanZero(3)
+	}
+	c.envVars = map[string]string{
+		"ACCESS_LOG":             "/var/log/relay-access.log",
+		"ACCESS_LOG_FORMAT":      "Common",
+		"ACCESS_LOG_MAX_SIZE":    "10MiB",
+		"ACCESS_LOG_MAX_BACKUPS": "3",
+	}
+	c.fileContent = `
+[Main]
+AccessLog = "/var/log/relay-access.log"
+AccessLogFormat = "common"
+AccessLogMaxSize = 10MiB
+AccessLogMaxBackups = 3
+`
+	return c
+}
+
+func makeValidConfigTLSClientCerts() testDataValidConfig {
+	c := testDataValidConfig{name: "TLS client certificates"}
+	c.makeConfig = func(c *Config) {
+		c.Main.TLSEnabled = true
+		c.Main.TLSCert = "cert"
+		c.Main.TLSKey = "key"
+		c.Main.TLSClientCA = ct.NewOptStringList([]string{"ca1.pem", "ca2.pem"})
+		c.Environment = map[string]*EnvConfig{
+			"earth": {
+				SDKKey:                 SDKKey("earth-sdk"),
+				AllowedClientCertNames: ct.NewOptStringList([]string{"service-a", "spiffe://example/b"}),
+			},
+		}
+	}
+	c.envVars = map[string]string{
+		"TLS_ENABLED":                        "1",
+		"TLS_CERT":                           "cert",
+		"TLS_KEY":                            "key",
+		"TLS_CLIENT_CA":                      "ca1.pem,ca2.pem",
+		"LD_ENV_earth":                       "earth-sdk",
+		"LD_ALLOWED_CLIENT_CERT_NAMES_earth": "service-a,spiffe://example/b",
+	}
+	c.fileContent = `
+[Main]
+TLSEnabled = 1
+TLSCert = "cert"
+TLSKey = "key"
+TLSClientCA = "ca1.pem"
+TLSClientCA = "ca2.pem"
+
+[Environment "earth"]
+SDKKey = "earth-sdk"
+AllowedClientCertNames = "service-a"
+AllowedClientCertNames = "spiffe://example/b"
+`
+	return c
+}
+
+func makeValidConfigListen() testDataValidConfig {
+	c := testDataValidConfig{name: "listen addresses"}
+	c.makeConfig = func(c *Config) {
+		c.Main.Listen = ct.NewOptStringList([]string{"unix:///run/ld-relay.sock", "http://127.0.0.1:8030", "https://:8443"})
+		c.Main.TLSEnabled = true
+		c.Main.TLSCert = "cert"
+		c.Main.TLSKey = "key"
+	}
+	c.envVars = map[string]string{
+		"LISTEN":      "unix:///run/ld-relay.sock,http://127.0.0.1:8030,https://:8443",
+		"TLS_ENABLED": "1",
+		"TLS_CERT":    "cert",
+		"TLS_KEY":     "key",
+	}
+	c.fileContent = `
+[Main]
+Listen = "unix:///run/ld-relay.sock"
+Listen = "http://127.0.0.1:8030"
+Listen = "https://:8443"
+TLSEnabled = 1
+TLSCert = "cert"
+TLSKey = "key"
+`
+	return c
+}
+
+func makeValidConfigHTTP2() testDataValidConfig {
+	c := testDataValidConfig{name: "HTTP/2 settings"}
+	c.makeConfig = func(c *Config) {
+		c.Main.HTTP2Cleartext = true
+		c.Main.HTTP2MaxConcurrentStreams = mustOptIntGreaterThanZero(1000)
+		c.Main.HTTP2IdleTimeout = ct.NewOptDuration(5 * time.Minute)
+	}
+	c.envVars = map[string]string{
+		"HTTP2_CLEARTEXT":              "1",
+		"HTTP2_MAX_CONCURRENT_STREAMS": "1000",
+		"HTTP2_IDLE_TIMEOUT":           "5m",
+	}
+	c.fileContent = `
+[Main]
+HTTP2Cleartext = 1
+HTTP2MaxConcurrentStreams = 1000
+HTTP2IdleTimeout = 5m
+`
+	return c
+}
+
+func makeValidConfigUpstreamRelay() testDataValidConfig {
+	c := testDataValidConfig{name: "upstream Relay"}
+	c.makeConfig = func(c *Config) {
+		upstream := newOptURLAbsoluteMustBeValid("http://regional-relay:8030")
+		c.Main.UpstreamRelayURI = upstream
+		c.Main.BaseURI = upstream
+		c.Main.ClientSideBaseURI = upstream
+		c.Main.StreamURI = upstream
+		c.Events.EventsURI = upstream
+		c.Main.AllowDownstreamRelays = true
+		c.Main.DownstreamAutoConfigKeys = ct.NewOptStringList([]string{"rel-abc", "rel-def"})
+	}
+	c.envVars = map[string]string{
+		"UPSTREAM_RELAY_URI":          "http://regional-relay:8030",
+		"ALLOW_DOWNSTREAM_RELAYS":     "1",
+		"DOWNSTREAM_AUTO_CONFIG_KEYS": "rel-abc,rel-def",
+	}
+	c.fileContent = `
+[Main]
+UpstreamRelayURI = http://regional-relay:8030
+AllowDownstreamRelays = true
+DownstreamAutoConfigKeys = rel-abc
+DownstreamAutoConfigKeys = rel-def
+`
+	return c
+}
+
+func makeValidConfigCluster() testDataValidConfig {
+	c := testDataValidConfig{name: "cluster mode"}
+	c.makeConfig = func(c *Config) {
+		c.Cluster = ClusterConfig{
+			Enabled:           true,
+			NodeID:            "relay-1",
+			RedisURL:          newOptURLAbsoluteMustBeValid("redis://cluster-redis:6379"),
+			HeartbeatInterval: ct.NewOptDuration(time.Second),
+			LeaderTimeout:     ct.NewOptDuration(5 * time.Second),
+		}
+	}
+	c.envVars = map[string]string{
+		"CLUSTER_ENABLED":            "1",
+		"CLUSTER_NODE_ID":            "relay-1",
+		"CLUSTER_REDIS_URL":          "redis://cluster-redis:6379",
+		"CLUSTER_HEARTBEAT_INTERVAL": "1s",
+		"CLUSTER_LEADER_TIMEOUT":     "5s",
+	}
+	c.fileContent = `
+[Cluster]
+Enabled = true
+NodeID = "relay-1"
+RedisURL = "redis://cluster-redis:6379"
+HeartbeatInterval = 1s
+LeaderTimeout = 5s
+`
+	return c
+}
+
+func makeValidConfigOTLPMinimal() testDataValidConfig {
+	c := testDataValidConfig{name: "OTLP - minimal parameters"}
+	c.makeConfig = func(c *Config) {
+		c.OTLP = OTLPConfig{
+			Enabled: true,
+		}
+	}
+	c.envVars = map[string]string{
+		"USE_OTLP": "1",
+	}
+	c.fileContent = `
+[OTLP]
+Enabled = true
+`
+	return c
+}
+
+func makeValidConfigOTLPAll() testDataValidConfig {
+	c := testDataValidConfig{name: "OTLP - all parameters"}
+	c.makeConfig = func(c *Config) {
+		c.OTLP = OTLPConfig{
+			Enabled:         true,
+			Prefix:          "pre-",
+			Endpoint:        "https://collector:4318",
+			Protocol:        OTLPProtocolHTTP,
+			Insecure:        true,
+			TraceSampleRate: ct.NewOptFloat64(0.25),
+			Header:          []string{"header1:value1", "header2:value2"},
+		}
+	}
+	c.envVars = map[string]string{
+		"USE_OTLP":               "1",
+		"OTLP_PREFIX":            "pre-",
+		"OTLP_ENDPOINT":          "https://collector:4318",
+		"OTLP_PROTOCOL":          "HTTP",
+		"OTLP_INSECURE":          "1",
+		"OTLP_TRACE_SAMPLE_RATE": "0.25",
+		"OTLP_HEADER_header1":    "value1",
+		"OTLP_HEADER_header2":    "value2",
+	}
+	c.fileContent = `
+[OTLP]
+Enabled = true
+Prefix = "pre-"
+Endpoint = "https://collector:4318"
+Protocol = "http"
+Insecure = true
+TraceSampleRate = 0.25
+Header = "header1:value1"
+Header = "header2:value2"
+`
+	return c
+}
+
 func makeValidConfigProxy() testDataValidConfig {
 	c := testDataValidConfig{name: "proxy"}
 	c.makeConfig = func(c *Config) {
@@ -896,3 +1223,42 @@ CaCertFiles = "cert"
 `
 	return c
 }
+
+func makeValidConfigWebhooks() testDataValidConfig {
+	c := testDataValidConfig{name: "webhooks"}
+	c.makeConfig = func(c *Config) {
+		c.Webhook = map[string]*WebhookConfig{
+			"alerts": {
+				URL:        newOptURLAbsoluteMustBeValid("https://alerts/hook"),
+				Secret:     "secret",
+				Events:     ct.NewOptStringList([]string{"changes"}),
+				FlagKeys:   ct.NewOptStringList([]string{"billing-*", "checkout"}),
+				MaxRetries: mustOptIntGreaterThanZero(5),
+			},
+			"status": {
+				URL: newOptURLAbsoluteMustBeValid("https://status/hook"),
+			},
+		}
+	}
+	c.envVars = map[string]string{
+		"LD_WEBHOOK_URL_alerts":         "https://alerts/hook",
+		"LD_WEBHOOK_SECRET_alerts":      "secret",
+		"LD_WEBHOOK_EVENTS_alerts":      "changes",
+		"LD_WEBHOOK_FLAG_KEYS_alerts":   "billing-*,checkout",
+		"LD_WEBHOOK_MAX_RETRIES_alerts": "5",
+		"LD_WEBHOOK_URL_status":         "https://status/hook",
+	}
+	c.fileContent = `
+[Webhook "alerts"]
+URL = "https://alerts/hook"
+Secret = "secret"
+Events = "changes"
+FlagKeys = "billing-*"
+FlagKeys = "checkout"
+MaxRetries = 5
+
+[Webhook "status"]
+URL = "https://status/hook"
+`
+	return c
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"github.com/launchdarkly/go-sdk-common/v3/ldtime"
+)
+
+// DataStoreConsistencyCheckRep is the result of a data store consistency check returned by the admin
+// endpoints.
+//
+// This is exported for use in integration test code.
+type DataStoreConsistencyCheckRep struct {
+	Time         ldtime.UnixMillisecondTime `json:"time"`
+	MissingItems []InconsistentItemRep      `json:"missingItems"`
+	StaleItems   []InconsistentItemRep      `json:"staleItems"`
+	Repaired     bool                       `json:"repaired"`
+}
+
+// InconsistentItemRep describes a flag or segment that was missing or out of date in the data store.
+// ActualVersion is omitted if the item was missing.
+//
+// This is exported for use in integration test code.
+type InconsistentItemRep struct {
+	Kind            string `json:"kind"`
+	Key             string `json:"key"`
+	ExpectedVersion int    `json:"expectedVersion"`
+	ActualVersion   int    `json:"actualVersion,omitempty"`
+}
+
+// AdminEnvironmentRep describes an environment in the list returned by the admin endpoints. Name is the
+// name that identifies the environment in the status resource and in the other admin endpoints. The SDK
+// and mobile keys are obscured in the same way as in the status resource.
+//
+// This is exported for use in integration test code.
+type AdminEnvironmentRep struct {
+	Name           string                     `json:"name"`
+	EnvID          string                     `json:"envId,omitempty"`
+	EnvKey         string                     `json:"envKey,omitempty"`
+	EnvName        string                     `json:"envName,omitempty"`
+	ProjKey        string                     `json:"projKey,omitempty"`
+	ProjName       string                     `json:"projName,omitempty"`
+	FilterKey      string                     `json:"filterKey,omitempty"`
+	SDKKey         string                     `json:"sdkKey"`
+	MobileKey      string                     `json:"mobileKey,omitempty"`
+	ExpiringSDKKey string                     `json:"expiringSdkKey,omitempty"`
+	CreationTime   ldtime.UnixMillisecondTime `json:"creationTime"`
+}
@@ -9,28 +9,93 @@ import (
 //
 // This is exported for use in integration test code.
 type StatusRep struct {
-	Environments  map[string]EnvironmentStatusRep `json:"environments"`
-	Status        string                          `json:"status"`
-	Version       string                          `json:"version"`
-	ClientVersion string                          `json:"clientVersion"`
+	Environments    map[string]EnvironmentStatusRep `json:"environments"`
+	Status          string                          `json:"status"`
+	Version         string                          `json:"version"`
+	ClientVersion   string                          `json:"clientVersion"`
+	TLSCertificate  *TLSCertificateStatusRep        `json:"tlsCertificate,omitempty"`
+	Upstream        *UpstreamStatusRep              `json:"upstream,omitempty"`
+	AutoConfig      *AutoConfigStatusRep            `json:"autoConfig,omitempty"`
+	DegradedReasons []StatusReasonRep               `json:"degradedReasons,omitempty"`
+	Warnings        []StatusReasonRep               `json:"warnings,omitempty"`
+}
+
+// StatusReasonRep describes one of the reasons why the status endpoint reports that Relay is degraded, or
+// a problem that is reported as a warning without affecting the overall status. Kind is "dataSource",
+// "dataStore", "bigSegments", "autoConfig", "upstream", or "tlsCertificate". Env is the environment's key
+// in the Environments map, if the reason applies to one environment.
+//
+// This is exported for use in integration test code.
+type StatusReasonRep struct {
+	Env     string `json:"env,omitempty"`
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+}
+
+// AutoConfigStatusRep describes the auto-configuration stream, if auto-configuration is enabled, in the
+// status endpoint response. State has the same meaning as for an SDK data source.
+//
+// This is exported for use in integration test code.
+type AutoConfigStatusRep struct {
+	State      interfaces.DataSourceState `json:"state"`
+	StateSince ldtime.UnixMillisecondTime `json:"stateSince"`
+	LastError  string                     `json:"lastError,omitempty"`
+}
+
+// TLSCertificateStatusRep describes the TLS server certificate, if TLS is enabled, in the status endpoint
+// response. LoadedAt is the time that Relay last loaded the certificate from its file.
+//
+// This is exported for use in integration test code.
+type TLSCertificateStatusRep struct {
+	Subject  string                     `json:"subject"`
+	Expires  ldtime.UnixMillisecondTime `json:"expires"`
+	LoadedAt ldtime.UnixMillisecondTime `json:"loadedAt"`
+}
+
+// UpstreamStatusRep describes the upstream Relay Proxy, if this Relay Proxy gets its data from another
+// Relay Proxy rather than from LaunchDarkly, in the status endpoint response. Status is the overall
+// status reported by the upstream Relay Proxy, or "unreachable" if its status endpoint could not be
+// queried, or "unknown" if it has not been queried yet.
+//
+// This is exported for use in integration test code.
+type UpstreamStatusRep struct {
+	URI         string                     `json:"uri"`
+	Status      string                     `json:"status"`
+	Version     string                     `json:"version,omitempty"`
+	LastChecked ldtime.UnixMillisecondTime `json:"lastChecked,omitempty"`
+	LastError   string                     `json:"lastError,omitempty"`
 }
 
 // EnvironmentStatusRep is the per-environment JSON representation returned by the status endpoint.
 //
 // This is exported for use in integration test code.
 type EnvironmentStatusRep struct {
-	SDKKey           string               `json:"sdkKey"`
-	EnvID            string               `json:"envId,omitempty"`
-	EnvKey           string               `json:"envKey,omitempty"`
-	EnvName          string               `json:"envName,omitempty"`
-	ProjKey          string               `json:"projKey,omitempty"`
-	ProjName         string               `json:"projName,omitempty"`
-	MobileKey        string               `json:"mobileKey,omitempty"`
-	ExpiringSDKKey   string               `json:"expiringSdkKey,omitempty"`
-	Status           string               `json:"status"`
-	ConnectionStatus ConnectionStatusRep  `json:"connectionStatus"`
-	DataStoreStatus  DataStoreStatusRep   `json:"dataStoreStatus"`
-	BigSegmentStatus *BigSegmentStatusRep `json:"bigSegmentStatus,omitempty"`
+	SDKKey           string                `json:"sdkKey"`
+	EnvID            string                `json:"envId,omitempty"`
+	EnvKey           string                `json:"envKey,omitempty"`
+	EnvName          string                `json:"envName,omitempty"`
+	ProjKey          string                `json:"projKey,omitempty"`
+	ProjName         string                `json:"projName,omitempty"`
+	MobileKey        string                `json:"mobileKey,omitempty"`
+	ExpiringSDKKey   string                `json:"expiringSdkKey,omitempty"`
+	Status           string                `json:"status"`
+	ServingStaleData bool                  `json:"servingStaleData,omitempty"`
+	ConnectionStatus ConnectionStatusRep   `json:"connectionStatus"`
+	DataStoreStatus  DataStoreStatusRep    `json:"dataStoreStatus"`
+	BigSegmentStatus *BigSegmentStatusRep  `json:"bigSegmentStatus,omitempty"`
+	EventStats       *EventStatsRep        `json:"eventStats,omitempty"`
+	History          []StatusTransitionRep `json:"history,omitempty"`
+}
+
+// StatusTransitionRep describes a change in the state of an environment's data source or data store.
+// Source is "dataSource" or "dataStore".
+//
+// This is exported for use in integration test code.
+type StatusTransitionRep struct {
+	Time      ldtime.UnixMillisecondTime `json:"time"`
+	Source    string                     `json:"source"`
+	State     string                     `json:"state"`
+	ErrorKind string                     `json:"errorKind,omitempty"`
 }
 
 // BigSegmentStatusRep is the big segment status representation returned by the status endpoint.
@@ -69,4 +134,32 @@ type DataStoreStatusRep struct {
 	DBServer   string                     `json:"dbServer,omitempty"`
 	DBPrefix   string                     `json:"dbPrefix,omitempty"`
 	DBTable    string                     `json:"dbTable,omitempty"`
+
+	Consistency *DataStoreConsistencyRep `json:"consistency,omitempty"`
+}
+
+// DataStoreConsistencyRep summarizes the last consistency check of a persistent data store, in the status
+// endpoint. It is omitted if no check has been done.
+//
+// This is exported for use in integration test code.
+type DataStoreConsistencyRep struct {
+	LastChecked  ldtime.UnixMillisecondTime `json:"lastChecked"`
+	MissingItems int                        `json:"missingItems"`
+	StaleItems   int                        `json:"staleItems"`
+	Repaired     bool                       `json:"repaired"`
+}
+
+// EventStatsRep is the summary of event proxying activity returned by the status endpoint, if the
+// environment is proxying events. The counts are cumulative since the environment was created. The keys of
+// SendFailures and Retries are HTTP status codes, or "network_error".
+//
+// This is exported for use in integration test code.
+type EventStatsRep struct {
+	Received          map[string]int64 `json:"received"`
+	PayloadsForwarded int64            `json:"payloadsForwarded"`
+	EventsForwarded   int64            `json:"eventsForwarded"`
+	Dropped           int64            `json:"dropped"`
+	SendFailures      map[string]int64 `json:"sendFailures"`
+	Retries           map[string]int64 `json:"retries"`
+	QueueDepth        int64            `json:"queueDepth"`
 }
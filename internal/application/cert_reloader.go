@@ -0,0 +1,186 @@
+package application
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+)
+
+const (
+	defaultCertificateMonitoringInterval = 10 * time.Second
+
+	logMsgCertMonitoringStarted = "Monitoring TLS certificate %s and key %s for changes (every %s)"
+	logMsgCertFileStatFailed    = "TLS certificate or key file stat failed: %v"
+	logMsgCertFileChanged       = "TLS certificate or key file has changed; reloading"
+	logMsgCertLoaded            = "Loaded TLS certificate (subject: %s, expires: %s)"
+	logMsgCertReloadFailed      = "TLS certificate reload failed; will keep using the previous certificate (error: %s)"
+)
+
+func errCannotLoadCertificate(certFile, keyFile string, err error) error {
+	return fmt.Errorf("unable to load TLS certificate %s and key %s: %w", certFile, keyFile, err)
+}
+
+// CertificateInfo describes the TLS server certificate that is currently in use.
+type CertificateInfo struct {
+	Subject  string
+	NotAfter time.Time
+	LoadedAt time.Time
+}
+
+// CertificateReloader provides the TLS server certificate for StartHTTPServer, and reloads it whenever
+// the certificate or key file changes, so that a renewed certificate takes effect without restarting
+// Relay or dropping existing connections.
+//
+// Changes are detected by polling the size and modification time of both files. If the files do not
+// contain a valid certificate and key pair-- which might just mean that one has been updated and the
+// other hasn't yet-- the previous certificate continues to be used, and the files are checked again on
+// the next poll.
+type CertificateReloader struct {
+	certFile           string
+	keyFile            string
+	monitoringInterval time.Duration
+	onLoad             func(CertificateInfo)
+	loggers            ldlog.Loggers
+	cert               *tls.Certificate
+	info               CertificateInfo
+	lock               sync.RWMutex
+	closeCh            chan struct{}
+	closeOnce          sync.Once
+}
+
+// NewCertificateReloader loads the initial certificate and key, returning an error if they are not
+// valid, and starts monitoring the files for changes.
+//
+// If onLoad is not nil, it is called after the initial certificate and each reloaded certificate
+// have been loaded.
+func NewCertificateReloader(
+	certFile, keyFile string,
+	monitoringInterval time.Duration, // zero = use the default; we set a nonzero brief interval in unit tests
+	onLoad func(CertificateInfo),
+	loggers ldlog.Loggers,
+) (*CertificateReloader, error) {
+	cr := &CertificateReloader{
+		certFile:           certFile,
+		keyFile:            keyFile,
+		monitoringInterval: monitoringInterval,
+		onLoad:             onLoad,
+		loggers:            loggers,
+		closeCh:            make(chan struct{}),
+	}
+	if cr.monitoringInterval == 0 {
+		cr.monitoringInterval = defaultCertificateMonitoringInterval
+	}
+
+	prevStats, err := cr.statFiles()
+	if err != nil {
+		return nil, errCannotLoadCertificate(certFile, keyFile, err)
+	}
+	if err := cr.load(); err != nil {
+		return nil, errCannotLoadCertificate(certFile, keyFile, err)
+	}
+	go cr.monitorForChanges(prevStats)
+
+	return cr, nil
+}
+
+// GetCertificate returns the current certificate. Its signature matches tls.Config.GetCertificate.
+func (cr *CertificateReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cr.lock.RLock()
+	defer cr.lock.RUnlock()
+	return cr.cert, nil
+}
+
+// GetCertificateInfo returns information about the current certificate.
+func (cr *CertificateReloader) GetCertificateInfo() CertificateInfo {
+	cr.lock.RLock()
+	defer cr.lock.RUnlock()
+	return cr.info
+}
+
+// Close stops monitoring the files for changes.
+func (cr *CertificateReloader) Close() error {
+	cr.closeOnce.Do(func() {
+		close(cr.closeCh)
+	})
+	return nil
+}
+
+func (cr *CertificateReloader) load() error {
+	cert, err := tls.LoadX509KeyPair(cr.certFile, cr.keyFile)
+	if err != nil {
+		return err
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil { // COVERAGE: LoadX509KeyPair would already have failed for a malformed certificate
+		return err
+	}
+	info := CertificateInfo{
+		Subject:  leaf.Subject.String(),
+		NotAfter: leaf.NotAfter,
+		LoadedAt: time.Now(),
+	}
+	cr.lock.Lock()
+	cr.cert = &cert
+	cr.info = info
+	cr.lock.Unlock()
+
+	cr.loggers.Infof(logMsgCertLoaded, info.Subject, info.NotAfter.Format(time.RFC3339))
+	if cr.onLoad != nil {
+		cr.onLoad(info)
+	}
+	return nil
+}
+
+func (cr *CertificateReloader) statFiles() ([2]os.FileInfo, error) {
+	var ret [2]os.FileInfo
+	for i, path := range []string{cr.certFile, cr.keyFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return ret, err
+		}
+		ret[i] = info
+	}
+	return ret, nil
+}
+
+func (cr *CertificateReloader) monitorForChanges(original [2]os.FileInfo) {
+	ticker := time.NewTicker(cr.monitoringInterval)
+	defer ticker.Stop()
+
+	prevStats := original
+
+	cr.loggers.Infof(logMsgCertMonitoringStarted, cr.certFile, cr.keyFile, cr.monitoringInterval)
+
+	for {
+		select {
+		case <-cr.closeCh:
+			return
+		case <-ticker.C:
+			nextStats, err := cr.statFiles()
+			if err != nil {
+				cr.loggers.Errorf(logMsgCertFileStatFailed, err)
+				continue
+			}
+			if !fileMayHaveChanged(prevStats[0], nextStats[0]) && !fileMayHaveChanged(prevStats[1], nextStats[1]) {
+				continue
+			}
+			cr.loggers.Info(logMsgCertFileChanged)
+			if err := cr.load(); err != nil {
+				// Keep the previous file stats, so we'll try again on the next tick in case the
+				// files were in the middle of being updated.
+				cr.loggers.Warnf(logMsgCertReloadFailed, err)
+				continue
+			}
+			prevStats = nextStats
+		}
+	}
+}
+
+func fileMayHaveChanged(oldInfo, newInfo os.FileInfo) bool {
+	return oldInfo.ModTime() != newInfo.ModTime() || oldInfo.Size() != newInfo.Size()
+}
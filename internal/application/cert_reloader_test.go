@@ -0,0 +1,142 @@
+package application
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	"github.com/launchdarkly/go-sdk-common/v3/ldlogtest"
+	helpers "github.com/launchdarkly/go-test-helpers/v3"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testCertMonitoringInterval = 10 * time.Millisecond
+
+func writeTestCertAndKey(t *testing.T, certPath, keyPath, commonName string, notAfter time.Time) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}), 0o600))
+	require.NoError(t, os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+}
+
+func getCertificateCommonName(t *testing.T, cr *CertificateReloader) string {
+	cert, err := cr.GetCertificate(nil)
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+	return leaf.Subject.CommonName
+}
+
+func TestCertificateReloaderLoadsInitialCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	expiry := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+	writeTestCertAndKey(t, certPath, keyPath, "first", expiry)
+
+	loadedCh := make(chan CertificateInfo, 10)
+	cr, err := NewCertificateReloader(certPath, keyPath, testCertMonitoringInterval,
+		func(info CertificateInfo) { loadedCh <- info }, ldlog.NewDisabledLoggers())
+	require.NoError(t, err)
+	defer cr.Close()
+
+	assert.Equal(t, "first", getCertificateCommonName(t, cr))
+	info := cr.GetCertificateInfo()
+	assert.Equal(t, "CN=first", info.Subject)
+	assert.True(t, expiry.Equal(info.NotAfter))
+	assert.Equal(t, info, helpers.RequireValue(t, loadedCh, time.Second))
+}
+
+func TestCertificateReloaderFailsIfInitialCertificateIsInvalid(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+
+	_, err := NewCertificateReloader(certPath, keyPath, testCertMonitoringInterval, nil, ldlog.NewDisabledLoggers())
+	assert.Error(t, err)
+
+	require.NoError(t, os.WriteFile(certPath, []byte("not a cert"), 0o600))
+	require.NoError(t, os.WriteFile(keyPath, []byte("not a key"), 0o600))
+	_, err = NewCertificateReloader(certPath, keyPath, testCertMonitoringInterval, nil, ldlog.NewDisabledLoggers())
+	assert.Error(t, err)
+}
+
+func TestCertificateReloaderReloadsChangedCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	writeTestCertAndKey(t, certPath, keyPath, "first", time.Now().Add(time.Hour))
+
+	loadedCh := make(chan CertificateInfo, 10)
+	cr, err := NewCertificateReloader(certPath, keyPath, testCertMonitoringInterval,
+		func(info CertificateInfo) { loadedCh <- info }, ldlog.NewDisabledLoggers())
+	require.NoError(t, err)
+	defer cr.Close()
+	helpers.RequireValue(t, loadedCh, time.Second)
+
+	newExpiry := time.Now().Add(48 * time.Hour).Truncate(time.Second)
+	writeTestCertAndKey(t, certPath, keyPath, "second", newExpiry)
+
+	info := helpers.RequireValue(t, loadedCh, time.Second)
+	assert.Equal(t, "CN=second", info.Subject)
+	assert.True(t, newExpiry.Equal(info.NotAfter))
+	assert.Equal(t, "second", getCertificateCommonName(t, cr))
+}
+
+func TestCertificateReloaderKeepsPreviousCertificateIfNewOneIsInvalid(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	writeTestCertAndKey(t, certPath, keyPath, "first", time.Now().Add(time.Hour))
+
+	otherDir := t.TempDir()
+	otherCertPath, otherKeyPath := filepath.Join(otherDir, "cert.pem"), filepath.Join(otherDir, "key.pem")
+	writeTestCertAndKey(t, otherCertPath, otherKeyPath, "second", time.Now().Add(time.Hour))
+
+	mockLog := ldlogtest.NewMockLog()
+	loadedCh := make(chan CertificateInfo, 10)
+	cr, err := NewCertificateReloader(certPath, keyPath, testCertMonitoringInterval,
+		func(info CertificateInfo) { loadedCh <- info }, mockLog.Loggers)
+	require.NoError(t, err)
+	defer cr.Close()
+	helpers.RequireValue(t, loadedCh, time.Second)
+
+	// Replace only the certificate, so it no longer matches the key
+	otherCert, err := os.ReadFile(otherCertPath)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(certPath, otherCert, 0o600))
+
+	require.Eventually(t, func() bool {
+		return mockLog.HasMessageMatch(ldlog.Warn, "TLS certificate reload failed")
+	}, time.Second, testCertMonitoringInterval)
+	helpers.AssertNoMoreValues(t, loadedCh, 50*time.Millisecond)
+	assert.Equal(t, "first", getCertificateCommonName(t, cr))
+
+	// Now replace the key too, so the pair is valid again
+	otherKey, err := os.ReadFile(otherKeyPath)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(keyPath, otherKey, 0o600))
+
+	info := helpers.RequireValue(t, loadedCh, time.Second)
+	assert.Equal(t, "CN=second", info.Subject)
+	assert.Equal(t, "second", getCertificateCommonName(t, cr))
+}
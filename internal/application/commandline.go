@@ -11,18 +11,29 @@ import (
 // DefaultConfigPath is the default configuration file path.
 const DefaultConfigPath = "/etc/ld-relay.conf"
 
+// CommandMigrateStore is the command-line subcommand for copying data from one persistent data store to
+// another, instead of running Relay.
+const CommandMigrateStore = "migrate-store"
+
 // Options represents all options that can be set from the command line.
 type Options struct {
+	Command          string
 	ConfigFile       string
 	AllowMissingFile bool
 	UseEnvironment   bool
 	PrintVersion     bool
+	MigrateFromFile  string
+	MigrateToFile    string
 }
 
 func errConfigFileNotFound(filename string) error {
 	return fmt.Errorf("configuration file %q does not exist", filename)
 }
 
+func errMigrateStoreFilesRequired() error {
+	return fmt.Errorf("%s requires both --from and --to configuration files", CommandMigrateStore)
+}
+
 // DescribeConfigSource returns a human-readable phrase describing whether the configuration comes from a
 // file, from variables, or both.
 func (o Options) DescribeConfigSource() string {
@@ -50,7 +61,14 @@ func (o Options) DescribeConfigSource() string {
 // 3. If you specify --from-env, it creates a configuration from environment variables as described in README.
 // 4. If you specify both, the file is loaded first, then it applies changes from variables if any.
 // 5. Omitting all options is equivalent to explicitly specifying --config /etc/ld-relay.conf.
+//
+// If the first argument is "migrate-store", Relay copies data between data stores instead of running, and
+// the options are --from $FILEPATH and --to $FILEPATH, both of which are required.
 func ReadOptions(osArgs []string, errorOutput io.Writer) (Options, error) {
+	if len(osArgs) > 1 && osArgs[1] == CommandMigrateStore {
+		return readMigrateStoreOptions(osArgs, errorOutput)
+	}
+
 	var o Options
 
 	fs := flag.NewFlagSet("", flag.ContinueOnError)
@@ -86,6 +104,30 @@ func ReadOptions(osArgs []string, errorOutput io.Writer) (Options, error) {
 	return o, nil
 }
 
+func readMigrateStoreOptions(osArgs []string, errorOutput io.Writer) (Options, error) {
+	o := Options{Command: CommandMigrateStore}
+
+	fs := flag.NewFlagSet(CommandMigrateStore, flag.ContinueOnError)
+	fs.SetOutput(errorOutput)
+	fs.StringVar(&o.MigrateFromFile, "from", "", "configuration file for the data store to copy from")
+	fs.StringVar(&o.MigrateToFile, "to", "", "configuration file for the data store to copy to")
+	err := fs.Parse(osArgs[2:])
+	if err != nil {
+		return o, err
+	}
+
+	if o.MigrateFromFile == "" || o.MigrateToFile == "" {
+		return o, errMigrateStoreFilesRequired()
+	}
+	for _, filename := range []string{o.MigrateFromFile, o.MigrateToFile} {
+		if _, err := os.Stat(filename); os.IsNotExist(err) {
+			return o, errConfigFileNotFound(filename)
+		}
+	}
+
+	return o, nil
+}
+
 // DescribeRelayVersion returns the same version string unless it is a prerelease build, in
 // which case it is reformatted to change "+xxx" into "(build xxx)".
 func DescribeRelayVersion(version string) string {
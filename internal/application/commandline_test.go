@@ -65,6 +65,37 @@ func TestReadOptions(t *testing.T) {
 	})
 }
 
+func TestReadMigrateStoreOptions(t *testing.T) {
+	appName := "ld-relay"
+
+	t.Run("source and destination files", func(t *testing.T) {
+		helpers.WithTempFile(func(fromFile string) {
+			helpers.WithTempFile(func(toFile string) {
+				opts, err := ReadOptions([]string{appName, CommandMigrateStore, "--from", fromFile, "--to", toFile}, io.Discard)
+				require.NoError(t, err)
+				assert.Equal(t, CommandMigrateStore, opts.Command)
+				assert.Equal(t, fromFile, opts.MigrateFromFile)
+				assert.Equal(t, toFile, opts.MigrateToFile)
+				assert.Equal(t, "", opts.ConfigFile)
+			})
+		})
+	})
+
+	t.Run("missing option", func(t *testing.T) {
+		helpers.WithTempFile(func(fromFile string) {
+			_, err := ReadOptions([]string{appName, CommandMigrateStore, "--from", fromFile}, io.Discard)
+			assert.Equal(t, errMigrateStoreFilesRequired(), err)
+		})
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		helpers.WithTempFile(func(fromFile string) {
+			_, err := ReadOptions([]string{appName, CommandMigrateStore, "--from", fromFile, "--to", "not-a-file"}, io.Discard)
+			assert.Equal(t, errConfigFileNotFound("not-a-file"), err)
+		})
+	})
+}
+
 func TestDescribeRelayVersion(t *testing.T) {
 	assert.Equal(t, "1.2.3", DescribeRelayVersion("1.2.3"))
 	assert.Equal(t, "1.2.3 (build 999)", DescribeRelayVersion("1.2.3+999"))
@@ -2,56 +2,235 @@ package application
 
 import (
 	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"net"
 	"net/http"
+	"os"
 	"time"
 
 	"github.com/launchdarkly/ld-relay/v8/config"
 
 	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
-// StartHTTPServer starts the server, with or without TLS. It returns immediately, starting the server
-// on a separate goroutine; if the server fails to start up, it sends an error to the error channel.
+// HTTP2Options contains optional HTTP/2 settings for StartHTTPServerOnAddresses.
+type HTTP2Options struct {
+	// Cleartext enables HTTP/2 without TLS ("h2c") on addresses that do not use TLS. HTTP/1.1 clients
+	// can still connect to those addresses as before.
+	Cleartext bool
+	// MaxConcurrentStreams is the maximum number of concurrent requests on each HTTP/2 connection,
+	// or zero to use the Go default of 250.
+	MaxConcurrentStreams int
+	// IdleTimeout is how long an HTTP/2 connection with no active requests can remain open, or zero
+	// for no limit.
+	IdleTimeout time.Duration
+}
+
+// StartHTTPServer starts the server listening on the specified port on all interfaces, with or without
+// TLS. It is equivalent to calling StartHTTPServerOnAddresses with a single address.
 func StartHTTPServer(
 	port int,
 	handler http.Handler,
 	tlsEnabled bool,
-	tlsCertFile, tlsKeyFile string,
+	getCertificate func(*tls.ClientHelloInfo) (*tls.Certificate, error),
+	tlsMinVersion uint16,
+	tlsClientCAFiles []string,
+	loggers ldlog.Loggers,
+) (*http.Server, <-chan error) {
+	return StartHTTPServerOnAddresses(
+		[]config.ListenAddress{{Network: "tcp", Address: fmt.Sprintf(":%d", port), TLS: tlsEnabled}},
+		handler,
+		getCertificate,
+		tlsMinVersion,
+		tlsClientCAFiles,
+		HTTP2Options{},
+		loggers,
+	)
+}
+
+// StartHTTPServerOnAddresses starts the server listening on each of the specified addresses. It returns
+// immediately, starting each listener on a separate goroutine; if any listener fails to start up, it
+// sends an error to the error channel.
+//
+// For addresses that use TLS, getCertificate provides the server certificate for each connection;
+// normally this is the GetCertificate method of a CertificateReloader. If tlsClientCAFiles is non-empty,
+// those listeners also require every client to present a certificate that was signed by one of the CA
+// certificates in those files (in PEM format).
+//
+// HTTP/2 is always available for addresses that use TLS; http2Options can enable it for the other
+// addresses as well, and can change its connection settings.
+//
+// If a Unix domain socket file already exists at the specified path, it is replaced.
+func StartHTTPServerOnAddresses(
+	addresses []config.ListenAddress,
+	handler http.Handler,
+	getCertificate func(*tls.ClientHelloInfo) (*tls.Certificate, error),
 	tlsMinVersion uint16,
+	tlsClientCAFiles []string,
+	http2Options HTTP2Options,
 	loggers ldlog.Loggers,
 ) (*http.Server, <-chan error) {
 	srv := &http.Server{
-		Addr:              fmt.Sprintf(":%d", port),
 		Handler:           handler,
 		ReadHeaderTimeout: 10 * time.Second,
 	}
+	if len(addresses) == 1 && addresses[0].Network == "tcp" {
+		srv.Addr = addresses[0].Address
+	}
+
+	var tlsConfigErr error
+	for _, address := range addresses {
+		if address.TLS {
+			srv.TLSConfig, tlsConfigErr = makeServerTLSConfig(getCertificate, tlsMinVersion, tlsClientCAFiles)
+			break
+		}
+	}
 
-	if tlsEnabled && tlsMinVersion != 0 {
-		srv.TLSConfig = &tls.Config{ //nolint:gosec // linter doesn't want to see MinVersion being set to a variable
-			MinVersion: tlsMinVersion,
+	if http2Options != (HTTP2Options{}) {
+		h2s := &http2.Server{
+			MaxConcurrentStreams: uint32(http2Options.MaxConcurrentStreams), //nolint:gosec // the value is always positive
+			IdleTimeout:          http2Options.IdleTimeout,
+		}
+		if http2Options.Cleartext {
+			srv.Handler = makeH2CHandler(handler, h2s)
+		}
+		if srv.TLSConfig != nil && tlsConfigErr == nil {
+			// This replaces the default HTTP/2 setup that ServeTLS would otherwise do
+			tlsConfigErr = http2.ConfigureServer(srv, h2s)
 		}
 	}
 
-	errCh := make(chan error)
+	errCh := make(chan error, len(addresses))
 
-	go func() {
-		var err error
-		loggers.Infof("Starting server listening on port %d\n", port)
-		if tlsEnabled {
-			message := "TLS enabled for server"
-			if tlsMinVersion != 0 {
-				message += fmt.Sprintf(" (minimum TLS version: %s)", config.NewOptTLSVersion(tlsMinVersion).String())
+	for _, address := range addresses {
+		go func(address config.ListenAddress) {
+			loggers.Infof("Starting server listening on %s\n", describeListenAddress(address))
+			if address.TLS {
+				if tlsConfigErr != nil {
+					errCh <- tlsConfigErr
+					return
+				}
+				message := "TLS enabled for server"
+				if tlsMinVersion != 0 {
+					message += fmt.Sprintf(" (minimum TLS version: %s)", config.NewOptTLSVersion(tlsMinVersion).String())
+				}
+				loggers.Info(message)
+				if len(tlsClientCAFiles) != 0 {
+					loggers.Info("TLS client certificates are required")
+				}
+			} else if http2Options.Cleartext {
+				loggers.Info("HTTP/2 without TLS (h2c) enabled for server")
 			}
-			loggers.Info(message)
-			err = srv.ListenAndServeTLS(tlsCertFile, tlsKeyFile)
-		} else {
-			err = srv.ListenAndServe()
-		}
+			listener, err := listen(address)
+			if err != nil {
+				errCh <- err
+				return
+			}
+			if address.TLS {
+				err = srv.ServeTLS(listener, "", "") // the certificate comes from TLSConfig.GetCertificate
+			} else {
+				err = srv.Serve(listener)
+			}
+			if err != nil {
+				errCh <- err
+			}
+		}(address)
+	}
+
+	return srv, errCh
+}
+
+// GetListenAddresses returns the addresses that the server should listen on, as specified by the Listen
+// setting. If that is empty, it returns a single TCP address for the configured port on all interfaces,
+// using TLS if TLS is enabled.
+func GetListenAddresses(mainConfig config.MainConfig) ([]config.ListenAddress, error) {
+	if len(mainConfig.Listen.Values()) == 0 {
+		return []config.ListenAddress{{
+			Network: "tcp",
+			Address: fmt.Sprintf(":%d", mainConfig.Port.GetOrElse(config.DefaultPort)),
+			TLS:     mainConfig.TLSEnabled,
+		}}, nil
+	}
+	ret := make([]config.ListenAddress, 0, len(mainConfig.Listen.Values()))
+	for _, s := range mainConfig.Listen.Values() {
+		address, err := config.ParseListenAddress(s)
 		if err != nil {
-			errCh <- err
+			return nil, err
 		}
-	}()
+		ret = append(ret, address)
+	}
+	return ret, nil
+}
 
-	return srv, errCh
+// GetHTTP2Options returns the HTTP/2 settings from the configuration.
+func GetHTTP2Options(mainConfig config.MainConfig) HTTP2Options {
+	return HTTP2Options{
+		Cleartext:            mainConfig.HTTP2Cleartext,
+		MaxConcurrentStreams: mainConfig.HTTP2MaxConcurrentStreams.GetOrElse(0),
+		IdleTimeout:          mainConfig.HTTP2IdleTimeout.GetOrElse(0),
+	}
+}
+
+// makeH2CHandler accepts h2c connections, either with prior knowledge or with an HTTP/1.1 upgrade
+// request, and passes all other requests to the handler. Upgrade requests that were received over
+// TLS are not treated as h2c, since HTTP/2 over TLS is negotiated during the TLS handshake instead.
+func makeH2CHandler(handler http.Handler, h2s *http2.Server) http.Handler {
+	h2cHandler := h2c.NewHandler(handler, h2s)
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.TLS != nil {
+			handler.ServeHTTP(w, req)
+			return
+		}
+		h2cHandler.ServeHTTP(w, req)
+	})
+}
+
+func listen(address config.ListenAddress) (net.Listener, error) {
+	if address.Network == "unix" {
+		// A socket file left over from a previous run would make Listen fail
+		if info, err := os.Stat(address.Address); err == nil && info.Mode()&os.ModeSocket != 0 {
+			_ = os.Remove(address.Address)
+		}
+	}
+	return net.Listen(address.Network, address.Address)
+}
+
+func describeListenAddress(address config.ListenAddress) string {
+	if address.Network == "unix" {
+		return "Unix socket " + address.Address
+	}
+	if host, port, err := net.SplitHostPort(address.Address); err == nil && host == "" {
+		return "port " + port
+	}
+	return address.Address
+}
+
+func makeServerTLSConfig(
+	getCertificate func(*tls.ClientHelloInfo) (*tls.Certificate, error),
+	tlsMinVersion uint16,
+	tlsClientCAFiles []string,
+) (*tls.Config, error) {
+	tlsConfig := &tls.Config{ //nolint:gosec // linter doesn't want to see MinVersion being set to a variable
+		GetCertificate: getCertificate,
+		MinVersion:     tlsMinVersion,
+	}
+	if len(tlsClientCAFiles) != 0 {
+		pool := x509.NewCertPool()
+		for _, filePath := range tlsClientCAFiles {
+			data, err := os.ReadFile(filePath) //nolint:gosec // the file path comes from the configuration
+			if err != nil {
+				return nil, fmt.Errorf("unable to read TLS client CA file: %w", err)
+			}
+			if !pool.AppendCertsFromPEM(data) {
+				return nil, fmt.Errorf("TLS client CA file %q did not contain any valid PEM certificates", filePath)
+			}
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
 }
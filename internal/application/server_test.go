@@ -1,18 +1,28 @@
 package application
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/tls"
 	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
 	"fmt"
+	"math/big"
 	"net"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/launchdarkly/ld-relay/v8/config"
 	st "github.com/launchdarkly/ld-relay/v8/internal/sharedtest"
 
+	ct "github.com/launchdarkly/go-configtypes"
 	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
 	"github.com/launchdarkly/go-sdk-common/v3/ldlogtest"
 	helpers "github.com/launchdarkly/go-test-helpers/v3"
@@ -20,6 +30,8 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"golang.org/x/net/http2"
 )
 
 func withSelfSignedCert(t *testing.T, action func(certFilePath, keyFilePath string, certPool *x509.CertPool)) {
@@ -40,10 +52,52 @@ func withSelfSignedCert(t *testing.T, action func(certFilePath, keyFilePath stri
 	})
 }
 
+func getCertificateFromFiles(t *testing.T, certFilePath, keyFilePath string) func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cr, err := NewCertificateReloader(certFilePath, keyFilePath, 0, nil, ldlog.NewDisabledLoggers())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = cr.Close() })
+	return cr.GetCertificate
+}
+
+// makeTestCAAndClientCert creates a CA certificate, writes it to caFilePath in PEM format, and returns a
+// client certificate with the specified common name that is signed by that CA.
+func makeTestCAAndClientCert(t *testing.T, caFilePath, clientCommonName string) tls.Certificate {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(caFilePath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}), 0o600))
+	caCert, err := x509.ParseCertificate(caDER)
+	require.NoError(t, err)
+
+	clientKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	clientTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: clientCommonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	clientDER, err := x509.CreateCertificate(rand.Reader, clientTemplate, caCert, &clientKey.PublicKey, caKey)
+	require.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{clientDER}, PrivateKey: clientKey}
+}
+
 func TestStartHTTPServerInsecure(t *testing.T) {
 	port := st.GetAvailablePort(t)
 	mockLog := ldlogtest.NewMockLog()
-	server, errCh := StartHTTPServer(port, httphelpers.HandlerWithStatus(http.StatusOK), false, "", "", 0, mockLog.Loggers)
+	server, errCh := StartHTTPServer(port, httphelpers.HandlerWithStatus(http.StatusOK), false, nil, 0, nil, mockLog.Loggers)
 	require.NotNil(t, server)
 	require.NotNil(t, errCh)
 	require.Eventually(t, func() bool {
@@ -61,7 +115,7 @@ func TestStartHTTPServerSecure(t *testing.T) {
 
 	withSelfSignedCert(t, func(certFilePath, keyFilePath string, certPool *x509.CertPool) {
 		server, errCh := StartHTTPServer(port, httphelpers.HandlerWithStatus(http.StatusOK),
-			true, certFilePath, keyFilePath, 0, mockLog.Loggers)
+			true, getCertificateFromFiles(t, certFilePath, keyFilePath), 0, nil, mockLog.Loggers)
 		require.NotNil(t, server)
 		require.NotNil(t, errCh)
 
@@ -87,7 +141,7 @@ func TestStartHTTPServerSecureWithMinTLSVersion(t *testing.T) {
 
 	withSelfSignedCert(t, func(certFilePath, keyFilePath string, certPool *x509.CertPool) {
 		server, errCh := StartHTTPServer(port, httphelpers.HandlerWithStatus(http.StatusOK),
-			true, certFilePath, keyFilePath, tls.VersionTLS12, mockLog.Loggers)
+			true, getCertificateFromFiles(t, certFilePath, keyFilePath), tls.VersionTLS12, nil, mockLog.Loggers)
 		require.NotNil(t, server)
 		require.NotNil(t, errCh)
 
@@ -110,11 +164,327 @@ func TestStartHTTPServerSecureWithMinTLSVersion(t *testing.T) {
 	})
 }
 
+func TestStartHTTPServerSecureWithClientCertificates(t *testing.T) {
+	port := st.GetAvailablePort(t)
+	mockLog := ldlogtest.NewMockLog()
+
+	withSelfSignedCert(t, func(certFilePath, keyFilePath string, certPool *x509.CertPool) {
+		helpers.WithTempFile(func(caFilePath string) {
+			clientCert := makeTestCAAndClientCert(t, caFilePath, "my-service")
+
+			var clientCommonName string
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				clientCommonName = r.TLS.VerifiedChains[0][0].Subject.CommonName
+			})
+			server, errCh := StartHTTPServer(port, handler,
+				true, getCertificateFromFiles(t, certFilePath, keyFilePath), 0, []string{caFilePath}, mockLog.Loggers)
+			require.NotNil(t, server)
+			require.NotNil(t, errCh)
+
+			clientWithCert := &http.Client{Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{
+					RootCAs:      certPool,
+					Certificates: []tls.Certificate{clientCert},
+				},
+			}}
+			clientWithoutCert := &http.Client{Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{
+					RootCAs: certPool,
+				},
+			}}
+
+			require.Eventually(t, func() bool {
+				resp, err := clientWithCert.Get(fmt.Sprintf("https://127.0.0.1:%d", port))
+				return err == nil && resp.StatusCode == http.StatusOK
+			}, time.Second, time.Millisecond*10)
+			assert.Equal(t, "my-service", clientCommonName)
+
+			_, err := clientWithoutCert.Get(fmt.Sprintf("https://127.0.0.1:%d", port))
+			assert.Error(t, err)
+
+			mockLog.AssertMessageMatch(t, true, ldlog.Info, "TLS client certificates are required")
+		})
+	})
+}
+
+func TestStartHTTPServerWithInvalidClientCAFile(t *testing.T) {
+	port := st.GetAvailablePort(t)
+
+	withSelfSignedCert(t, func(certFilePath, keyFilePath string, certPool *x509.CertPool) {
+		helpers.WithTempFile(func(caFilePath string) {
+			require.NoError(t, os.WriteFile(caFilePath, []byte("not a certificate"), 0o600))
+
+			_, errCh := StartHTTPServer(port, httphelpers.HandlerWithStatus(http.StatusOK),
+				true, getCertificateFromFiles(t, certFilePath, keyFilePath), 0, []string{caFilePath}, ldlog.NewDisabledLoggers())
+			err := helpers.RequireValue(t, errCh, time.Second, "timed out waiting for error")
+			assert.Contains(t, err.Error(), "did not contain any valid PEM certificates")
+		})
+	})
+}
+
 func TestStartHTTPServerPortAlreadyUsed(t *testing.T) {
 	st.WithListenerForAnyPort(t, func(l net.Listener, port int) {
-		_, errCh := StartHTTPServer(port, httphelpers.HandlerWithStatus(200), false, "", "", 0, ldlog.NewDisabledLoggers())
+		_, errCh := StartHTTPServer(port, httphelpers.HandlerWithStatus(200), false, nil, 0, nil, ldlog.NewDisabledLoggers())
 		require.NotNil(t, errCh)
 		err := helpers.RequireValue(t, errCh, time.Second, "timed out waiting for error")
 		assert.NotNil(t, err)
 	})
 }
+
+func TestStartHTTPServerOnMultipleAddresses(t *testing.T) {
+	plainPort := st.GetAvailablePort(t)
+	tlsPort := st.GetAvailablePort(t)
+	socketDir, err := os.MkdirTemp("", "relay-test") // not t.TempDir(), because socket paths have a short length limit
+	require.NoError(t, err)
+	defer os.RemoveAll(socketDir)
+	socketPath := filepath.Join(socketDir, "relay.sock")
+
+	mockLog := ldlogtest.NewMockLog()
+
+	withSelfSignedCert(t, func(certFilePath, keyFilePath string, certPool *x509.CertPool) {
+		addresses := []config.ListenAddress{
+			{Network: "tcp", Address: fmt.Sprintf("127.0.0.1:%d", plainPort)},
+			{Network: "tcp", Address: fmt.Sprintf(":%d", tlsPort), TLS: true},
+		}
+		server, errCh := StartHTTPServerOnAddresses(addresses, httphelpers.HandlerWithStatus(http.StatusOK),
+			getCertificateFromFiles(t, certFilePath, keyFilePath), 0, nil, HTTP2Options{}, mockLog.Loggers)
+		require.NotNil(t, server)
+		defer server.Close()
+
+		plainClient := http.DefaultClient
+		tlsClient := &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				RootCAs: certPool,
+			},
+		}}
+		require.Eventually(t, func() bool {
+			resp, err := plainClient.Get(fmt.Sprintf("http://127.0.0.1:%d", plainPort))
+			return err == nil && resp.StatusCode == http.StatusOK
+		}, time.Second, time.Millisecond*10)
+		require.Eventually(t, func() bool {
+			resp, err := tlsClient.Get(fmt.Sprintf("https://127.0.0.1:%d", tlsPort))
+			return err == nil && resp.StatusCode == http.StatusOK
+		}, time.Second, time.Millisecond*10)
+
+		_, err := plainClient.Get(fmt.Sprintf("http://127.0.0.1:%d", tlsPort))
+		require.NoError(t, err) // Go's TLS server responds to plain HTTP with a 400 error
+		_, err = tlsClient.Get(fmt.Sprintf("https://127.0.0.1:%d", plainPort))
+		assert.Error(t, err)
+
+		mockLog.AssertMessageMatch(t, true, ldlog.Info, fmt.Sprintf("listening on 127.0.0.1:%d", plainPort))
+		mockLog.AssertMessageMatch(t, true, ldlog.Info, fmt.Sprintf("listening on port %d", tlsPort))
+		helpers.AssertNoMoreValues(t, errCh, 0)
+	})
+
+	t.Run("Unix socket", func(t *testing.T) {
+		l, err := net.Listen("unix", socketPath) // leave a stale socket file, as if Relay had crashed
+		require.NoError(t, err)
+		l.(*net.UnixListener).SetUnlinkOnClose(false)
+		require.NoError(t, l.Close())
+
+		addresses := []config.ListenAddress{{Network: "unix", Address: socketPath}}
+		server, _ := StartHTTPServerOnAddresses(addresses, httphelpers.HandlerWithStatus(http.StatusOK),
+			nil, 0, nil, HTTP2Options{}, mockLog.Loggers)
+		require.NotNil(t, server)
+		defer server.Close()
+
+		client := &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", socketPath)
+			},
+		}}
+		require.Eventually(t, func() bool {
+			resp, err := client.Get("http://relay/")
+			return err == nil && resp.StatusCode == http.StatusOK
+		}, time.Second, time.Millisecond*10)
+		mockLog.AssertMessageMatch(t, true, ldlog.Info, "listening on Unix socket "+socketPath)
+	})
+}
+
+func TestStartHTTPServerDoesNotReplaceNonSocketFile(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "not-a-socket")
+	require.NoError(t, os.WriteFile(filePath, []byte("data"), 0o600))
+
+	_, errCh := StartHTTPServerOnAddresses([]config.ListenAddress{{Network: "unix", Address: filePath}},
+		httphelpers.HandlerWithStatus(http.StatusOK), nil, 0, nil, HTTP2Options{}, ldlog.NewDisabledLoggers())
+	err := helpers.RequireValue(t, errCh, time.Second, "timed out waiting for error")
+	assert.Error(t, err)
+	data, _ := os.ReadFile(filePath)
+	assert.Equal(t, "data", string(data))
+}
+
+func makeH2CClient() *http.Client {
+	return &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		},
+	}}
+}
+
+// readHTTP2ServerSettings sends the HTTP/2 client preface on an already-established connection, and
+// returns the settings from the server's initial SETTINGS frame.
+func readHTTP2ServerSettings(t *testing.T, conn net.Conn) map[http2.SettingID]uint32 {
+	_, err := conn.Write([]byte(http2.ClientPreface))
+	require.NoError(t, err)
+	framer := http2.NewFramer(conn, conn)
+	require.NoError(t, framer.WriteSettings())
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	frame, err := framer.ReadFrame()
+	require.NoError(t, err)
+	settingsFrame, ok := frame.(*http2.SettingsFrame)
+	require.True(t, ok, "expected SETTINGS frame, got %T", frame)
+	ret := make(map[http2.SettingID]uint32)
+	require.NoError(t, settingsFrame.ForeachSetting(func(s http2.Setting) error {
+		ret[s.ID] = s.Val
+		return nil
+	}))
+	return ret
+}
+
+func TestStartHTTPServerWithHTTP2Cleartext(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, _ = w.Write([]byte(req.Proto))
+	})
+	getProto := func(t *testing.T, client *http.Client, url string) string {
+		resp, err := client.Get(url)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		return resp.Proto
+	}
+
+	t.Run("enabled", func(t *testing.T) {
+		port := st.GetAvailablePort(t)
+		mockLog := ldlogtest.NewMockLog()
+		addresses := []config.ListenAddress{{Network: "tcp", Address: fmt.Sprintf("127.0.0.1:%d", port)}}
+		server, errCh := StartHTTPServerOnAddresses(addresses, handler, nil, 0, nil,
+			HTTP2Options{Cleartext: true}, mockLog.Loggers)
+		require.NotNil(t, server)
+		defer server.Close()
+		url := fmt.Sprintf("http://127.0.0.1:%d", port)
+
+		require.Eventually(t, func() bool {
+			resp, err := http.Get(url)
+			return err == nil && resp.StatusCode == http.StatusOK
+		}, time.Second, time.Millisecond*10)
+
+		assert.Equal(t, "HTTP/2.0", getProto(t, makeH2CClient(), url))
+		assert.Equal(t, "HTTP/1.1", getProto(t, http.DefaultClient, url))
+		mockLog.AssertMessageMatch(t, true, ldlog.Info, "h2c")
+		helpers.AssertNoMoreValues(t, errCh, 0)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		port := st.GetAvailablePort(t)
+		mockLog := ldlogtest.NewMockLog()
+		server, _ := StartHTTPServer(port, handler, false, nil, 0, nil, mockLog.Loggers)
+		require.NotNil(t, server)
+		defer server.Close()
+		url := fmt.Sprintf("http://127.0.0.1:%d", port)
+
+		require.Eventually(t, func() bool {
+			resp, err := http.Get(url)
+			return err == nil && resp.StatusCode == http.StatusOK
+		}, time.Second, time.Millisecond*10)
+
+		_, err := makeH2CClient().Get(url)
+		assert.Error(t, err)
+		mockLog.AssertMessageMatch(t, false, ldlog.Info, "h2c")
+	})
+}
+
+func TestStartHTTPServerWithHTTP2Settings(t *testing.T) {
+	options := HTTP2Options{Cleartext: true, MaxConcurrentStreams: 1000, IdleTimeout: time.Minute}
+
+	t.Run("without TLS", func(t *testing.T) {
+		port := st.GetAvailablePort(t)
+		addresses := []config.ListenAddress{{Network: "tcp", Address: fmt.Sprintf("127.0.0.1:%d", port)}}
+		server, _ := StartHTTPServerOnAddresses(addresses, httphelpers.HandlerWithStatus(http.StatusOK),
+			nil, 0, nil, options, ldlog.NewDisabledLoggers())
+		require.NotNil(t, server)
+		defer server.Close()
+
+		var conn net.Conn
+		require.Eventually(t, func() bool {
+			var err error
+			conn, err = net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+			return err == nil
+		}, time.Second, time.Millisecond*10)
+		defer conn.Close()
+
+		settings := readHTTP2ServerSettings(t, conn)
+		assert.Equal(t, uint32(1000), settings[http2.SettingMaxConcurrentStreams])
+	})
+
+	t.Run("with TLS", func(t *testing.T) {
+		port := st.GetAvailablePort(t)
+		withSelfSignedCert(t, func(certFilePath, keyFilePath string, certPool *x509.CertPool) {
+			addresses := []config.ListenAddress{{Network: "tcp", Address: fmt.Sprintf("127.0.0.1:%d", port), TLS: true}}
+			server, _ := StartHTTPServerOnAddresses(addresses, httphelpers.HandlerWithStatus(http.StatusOK),
+				getCertificateFromFiles(t, certFilePath, keyFilePath), 0, nil, options, ldlog.NewDisabledLoggers())
+			require.NotNil(t, server)
+			defer server.Close()
+
+			var conn *tls.Conn
+			require.Eventually(t, func() bool {
+				var err error
+				conn, err = tls.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", port),
+					&tls.Config{RootCAs: certPool, NextProtos: []string{http2.NextProtoTLS}})
+				return err == nil
+			}, time.Second, time.Millisecond*10)
+			defer conn.Close()
+			require.Equal(t, http2.NextProtoTLS, conn.ConnectionState().NegotiatedProtocol)
+
+			settings := readHTTP2ServerSettings(t, conn)
+			assert.Equal(t, uint32(1000), settings[http2.SettingMaxConcurrentStreams])
+		})
+	})
+}
+
+func TestGetHTTP2Options(t *testing.T) {
+	assert.Equal(t, HTTP2Options{}, GetHTTP2Options(config.MainConfig{}))
+
+	maxStreams, _ := ct.NewOptIntGreaterThanZero(1000)
+	assert.Equal(t,
+		HTTP2Options{Cleartext: true, MaxConcurrentStreams: 1000, IdleTimeout: time.Minute},
+		GetHTTP2Options(config.MainConfig{
+			HTTP2Cleartext:            true,
+			HTTP2MaxConcurrentStreams: maxStreams,
+			HTTP2IdleTimeout:          ct.NewOptDuration(time.Minute),
+		}),
+	)
+}
+
+func TestGetListenAddresses(t *testing.T) {
+	t.Run("default", func(t *testing.T) {
+		addresses, err := GetListenAddresses(config.MainConfig{})
+		require.NoError(t, err)
+		assert.Equal(t, []config.ListenAddress{{Network: "tcp", Address: fmt.Sprintf(":%d", config.DefaultPort)}}, addresses)
+	})
+
+	t.Run("port and TLS", func(t *testing.T) {
+		port, _ := ct.NewOptIntGreaterThanZero(9000)
+		addresses, err := GetListenAddresses(config.MainConfig{Port: port, TLSEnabled: true})
+		require.NoError(t, err)
+		assert.Equal(t, []config.ListenAddress{{Network: "tcp", Address: ":9000", TLS: true}}, addresses)
+	})
+
+	t.Run("listen addresses override port", func(t *testing.T) {
+		port, _ := ct.NewOptIntGreaterThanZero(9000)
+		addresses, err := GetListenAddresses(config.MainConfig{
+			Port:   port,
+			Listen: ct.NewOptStringList([]string{"unix:///run/ld-relay.sock", "http://127.0.0.1:8030"}),
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []config.ListenAddress{
+			{Network: "unix", Address: "/run/ld-relay.sock"},
+			{Network: "tcp", Address: "127.0.0.1:8030"},
+		}, addresses)
+	})
+
+	t.Run("invalid address", func(t *testing.T) {
+		_, err := GetListenAddresses(config.MainConfig{Listen: ct.NewOptStringList([]string{"localhost:8030"})})
+		assert.Error(t, err)
+	})
+}
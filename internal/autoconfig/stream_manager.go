@@ -13,6 +13,7 @@ import (
 
 	es "github.com/launchdarkly/eventsource"
 	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	"github.com/launchdarkly/go-server-sdk/v7/interfaces"
 	"github.com/launchdarkly/ld-relay/v8/config"
 	"github.com/launchdarkly/ld-relay/v8/internal/envfactory"
 	"github.com/launchdarkly/ld-relay/v8/internal/httpconfig"
@@ -53,11 +54,22 @@ type StreamManager struct {
 	loggers           ldlog.Loggers
 	halt              chan struct{}
 	closeOnce         sync.Once
+	status            Status
+	statusLock        sync.Mutex
 
 	envReceiver    *MessageReceiver[envfactory.EnvironmentRep]
 	filterReceiver *MessageReceiver[envfactory.FilterRep]
 }
 
+// Status describes the state of the auto-configuration stream. The states have the same meanings as for an
+// SDK data source: INITIALIZING until the first full set of environments is received, VALID after that,
+// INTERRUPTED if the stream fails after it was VALID, and OFF if it has permanently failed or was closed.
+type Status struct {
+	State      interfaces.DataSourceState
+	StateSince time.Time
+	LastError  string
+}
+
 // NewStreamManager creates a StreamManager, but does not start the connection.
 func NewStreamManager(
 	key config.AutoConfigKey,
@@ -83,6 +95,7 @@ func NewStreamManager(
 		initialRetryDelay: initialRetryDelay,
 		loggers:           loggers,
 		halt:              make(chan struct{}),
+		status:            Status{State: interfaces.DataSourceStateInitializing, StateSince: time.Now()},
 	}
 
 	// Enforces ordering constraints on the SSE messages that are sent from the server, allowing the MessageHandler
@@ -113,9 +126,47 @@ func (s *StreamManager) Start() <-chan error {
 func (s *StreamManager) Close() {
 	s.closeOnce.Do(func() {
 		close(s.halt)
+		s.setState(interfaces.DataSourceStateOff)
 	})
 }
 
+// GetStatus returns the current state of the stream.
+func (s *StreamManager) GetStatus() Status {
+	s.statusLock.Lock()
+	defer s.statusLock.Unlock()
+	return s.status
+}
+
+func (s *StreamManager) setState(state interfaces.DataSourceState) {
+	s.statusLock.Lock()
+	defer s.statusLock.Unlock()
+	if s.status.State != state {
+		s.status.State = state
+		s.status.StateSince = time.Now()
+	}
+}
+
+// setError records an error. The stream becomes INTERRUPTED if it was VALID, or OFF if the error is permanent.
+// Errors after the stream is OFF are ignored, so that the error that stopped it is not overwritten.
+func (s *StreamManager) setError(err error, permanent bool) {
+	s.statusLock.Lock()
+	defer s.statusLock.Unlock()
+	if s.status.State == interfaces.DataSourceStateOff {
+		return
+	}
+	s.status.LastError = err.Error()
+	newState := s.status.State
+	if permanent {
+		newState = interfaces.DataSourceStateOff
+	} else if newState == interfaces.DataSourceStateValid {
+		newState = interfaces.DataSourceStateInterrupted
+	}
+	if newState != s.status.State {
+		s.status.State = newState
+		s.status.StateSince = time.Now()
+	}
+}
+
 func (s *StreamManager) subscribe(readyCh chan<- error) {
 	var readyOnce sync.Once
 	signalReady := func(err error) { readyOnce.Do(func() { readyCh <- err }) }
@@ -124,14 +175,18 @@ func (s *StreamManager) subscribe(readyCh chan<- error) {
 		if se, ok := err.(es.SubscriptionError); ok {
 			if se.Code == 401 || se.Code == 403 {
 				s.loggers.Error(logMsgBadKey)
-				signalReady(errors.New("invalid auto-configuration key"))
+				keyErr := errors.New("invalid auto-configuration key")
+				s.setError(keyErr, true)
+				signalReady(keyErr)
 				return es.StreamErrorHandlerResult{CloseNow: true}
 			}
 			s.loggers.Warnf(logMsgStreamHTTPError, se.Code)
+			s.setError(se, false)
 			return es.StreamErrorHandlerResult{CloseNow: false}
 		}
 
 		s.loggers.Warnf(logMsgStreamOtherError, err)
+		s.setError(err, false)
 		return es.StreamErrorHandlerResult{CloseNow: false}
 	}
 
@@ -143,6 +198,7 @@ func (s *StreamManager) subscribe(readyCh chan<- error) {
 	rpacEndpoint, err := url.JoinPath(s.uri.String(), autoConfigStreamPath)
 	if err != nil {
 		s.loggers.Errorf(logMsgBadURL, err)
+		s.setError(err, true)
 		signalReady(err)
 		return
 	}
@@ -170,6 +226,7 @@ func (s *StreamManager) subscribe(readyCh chan<- error) {
 
 	if err != nil {
 		s.loggers.Errorf(logMsgStreamOtherError, err)
+		s.setError(err, true)
 		signalReady(err)
 		return
 	}
@@ -227,6 +284,9 @@ func (s *StreamManager) consumeStream(stream *es.Stream) {
 					s.loggers.Infof(logMsgWrongPath, PutEvent, putMessage.Path)
 					break
 				}
+				// This is set first so that the stream is already VALID when the handler is told that all of
+				// the environments have been received.
+				s.setState(interfaces.DataSourceStateValid)
 				s.handlePut(putMessage.Data)
 
 			case PatchEvent:
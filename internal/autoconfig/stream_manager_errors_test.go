@@ -10,6 +10,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	"github.com/launchdarkly/go-server-sdk/v7/interfaces"
 	helpers "github.com/launchdarkly/go-test-helpers/v3"
 	"github.com/launchdarkly/go-test-helpers/v3/httphelpers"
 )
@@ -97,6 +98,10 @@ func errorShouldCauseReconnect(t *testing.T, errorProducingHandler http.Handler,
 		msg := p.requireMessage()
 		assert.NotNil(t, msg.add)
 		p.requireReceivedAllMessage()
+		assert.Eventually(t, func() bool {
+			return p.streamManager.GetStatus().State == interfaces.DataSourceStateValid
+		}, time.Second, 10*time.Millisecond)
+		assert.NotEmpty(t, p.streamManager.GetStatus().LastError)
 	})
 }
 
@@ -134,7 +139,40 @@ func TestNoReconnectAfterUnrecoverableHTTPError(t *testing.T) {
 				case <-time.After(time.Millisecond * 200):
 					p.mockLog.AssertMessageMatch(t, true, ldlog.Error, "Invalid auto-configuration key")
 				}
+				status := p.streamManager.GetStatus()
+				assert.Equal(t, interfaces.DataSourceStateOff, status.State)
+				assert.Equal(t, "invalid auto-configuration key", status.LastError)
 			})
 		})
 	}
 }
+
+func TestStatusIsInterruptedIfStreamFailsAfterItWasValid(t *testing.T) {
+	initialEvent := makeEnvPutEvent(testEnv1)
+	streamHandler, stream := httphelpers.SSEHandler(&initialEvent)
+	defer stream.Close()
+	handler := httphelpers.SequentialHandler(
+		streamHandler,                      // first request will get this
+		httphelpers.HandlerWithStatus(503), // all requests after reconnect will get this
+	)
+	streamManagerTestWithStreamHandler(t, handler, stream, func(p streamManagerTestParams) {
+		assert.Equal(t, interfaces.DataSourceStateInitializing, p.streamManager.GetStatus().State)
+
+		p.startStream()
+		<-p.requestsCh
+		_ = p.requireMessage()
+		p.requireReceivedAllMessage()
+		assert.Eventually(t, func() bool {
+			return p.streamManager.GetStatus().State == interfaces.DataSourceStateValid
+		}, time.Second, 10*time.Millisecond)
+
+		stream.EndAll()
+		assert.Eventually(t, func() bool {
+			return p.streamManager.GetStatus().State == interfaces.DataSourceStateInterrupted
+		}, time.Second, 10*time.Millisecond)
+		assert.NotEmpty(t, p.streamManager.GetStatus().LastError)
+
+		p.streamManager.Close()
+		assert.Equal(t, interfaces.DataSourceStateOff, p.streamManager.GetStatus().State)
+	})
+}
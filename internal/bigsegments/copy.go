@@ -0,0 +1,204 @@
+package bigsegments
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// copyPatchMaxMemberships is the maximum number of membership changes in each patch that CopyBigSegmentData
+// applies to the destination store, so that we do not build very large database transactions.
+const copyPatchMaxMemberships = 1000
+
+var (
+	errBigSegmentDestinationNotEmpty     = errors.New("destination already contains big segment data")
+	errBigSegmentDestinationNotSupported = errors.New("destination data store does not support big segments")
+	errBigSegmentDestinationModified     = errors.New("destination big segment data was modified while it was being copied")
+)
+
+// BigSegmentCopyResult describes the big segment data that was copied by CopyBigSegmentData.
+type BigSegmentCopyResult struct {
+	// Cursor is the synchronization cursor, which identifies the last set of changes that were received
+	// from LaunchDarkly.
+	Cursor string
+
+	// Users is the number of users who are included in or excluded from at least one big segment.
+	Users int
+
+	// Memberships is the total number of inclusions and exclusions.
+	Memberships int
+}
+
+// CopyBigSegmentData copies all of an environment's big segment data from one store to another, and then
+// verifies that the destination store contains the same data as the source. The destination store must
+// not already contain any big segment data for the environment.
+//
+// The destination may be nil if the destination data store does not support big segments, in which case
+// this is only an error if there is any big segment data to be copied.
+func CopyBigSegmentData(from, to BigSegmentStore) (BigSegmentCopyResult, error) {
+	var result BigSegmentCopyResult
+
+	cursor, err := from.getCursor()
+	if err != nil {
+		return result, err
+	}
+	memberships, err := from.getMemberships()
+	if err != nil {
+		return result, err
+	}
+	syncTime, err := from.GetSynchronizedOn()
+	if err != nil {
+		return result, err
+	}
+	result.Cursor = cursor
+	result.Users = len(memberships)
+	for _, m := range memberships {
+		result.Memberships += len(m.Included) + len(m.Excluded)
+	}
+	if cursor == "" && len(memberships) == 0 {
+		return result, nil
+	}
+	if to == nil {
+		return result, errBigSegmentDestinationNotSupported
+	}
+
+	destCursor, err := to.getCursor()
+	if err != nil {
+		return result, err
+	}
+	destMemberships, err := to.getMemberships()
+	if err != nil {
+		return result, err
+	}
+	if destCursor != "" || len(destMemberships) != 0 {
+		return result, errBigSegmentDestinationNotEmpty
+	}
+
+	// As in the initial synchronization from LaunchDarkly, the patches that add the data all have an empty
+	// version, and then a final patch with no changes sets the cursor.
+	patches := makePatchesForMemberships(memberships)
+	if cursor != "" {
+		patches = append(patches, bigSegmentPatch{Version: cursor})
+	}
+	for _, patch := range patches {
+		applied, err := to.applyPatch(patch)
+		if err != nil {
+			return result, err
+		}
+		if !applied {
+			return result, errBigSegmentDestinationModified
+		}
+	}
+	if syncTime.IsDefined() {
+		if err := to.setSynchronizedOn(syncTime); err != nil {
+			return result, err
+		}
+	}
+
+	copiedCursor, err := to.getCursor()
+	if err != nil {
+		return result, err
+	}
+	if copiedCursor != cursor {
+		return result, fmt.Errorf("big segment cursor in destination is %q, expected %q", copiedCursor, cursor)
+	}
+	copiedMemberships, err := to.getMemberships()
+	if err != nil {
+		return result, err
+	}
+	if mismatches := countMembershipMismatches(memberships, copiedMemberships); mismatches != 0 {
+		return result, fmt.Errorf("big segment data in destination does not match source for %d users", mismatches)
+	}
+	return result, nil
+}
+
+func makePatchesForMemberships(memberships map[string]bigSegmentUserMembership) []bigSegmentPatch {
+	changesBySegment := make(map[string]*bigSegmentPatchChanges)
+	getChanges := func(segmentID string) *bigSegmentPatchChanges {
+		changes := changesBySegment[segmentID]
+		if changes == nil {
+			changes = &bigSegmentPatchChanges{}
+			changesBySegment[segmentID] = changes
+		}
+		return changes
+	}
+	userHashKeys := make([]string, 0, len(memberships))
+	for userHashKey := range memberships {
+		userHashKeys = append(userHashKeys, userHashKey)
+	}
+	sort.Strings(userHashKeys)
+	for _, userHashKey := range userHashKeys {
+		m := memberships[userHashKey]
+		for _, segmentID := range m.Included {
+			changes := getChanges(segmentID)
+			changes.Included.Add = append(changes.Included.Add, userHashKey)
+		}
+		for _, segmentID := range m.Excluded {
+			changes := getChanges(segmentID)
+			changes.Excluded.Add = append(changes.Excluded.Add, userHashKey)
+		}
+	}
+
+	segmentIDs := make([]string, 0, len(changesBySegment))
+	for segmentID := range changesBySegment {
+		segmentIDs = append(segmentIDs, segmentID)
+	}
+	sort.Strings(segmentIDs)
+	var patches []bigSegmentPatch
+	for _, segmentID := range segmentIDs {
+		changes := changesBySegment[segmentID]
+		for _, included := range splitKeys(changes.Included.Add) {
+			patches = append(patches, bigSegmentPatch{SegmentID: segmentID,
+				Changes: bigSegmentPatchChanges{Included: bigSegmentPatchChangesMutations{Add: included}}})
+		}
+		for _, excluded := range splitKeys(changes.Excluded.Add) {
+			patches = append(patches, bigSegmentPatch{SegmentID: segmentID,
+				Changes: bigSegmentPatchChanges{Excluded: bigSegmentPatchChangesMutations{Add: excluded}}})
+		}
+	}
+	return patches
+}
+
+func splitKeys(keys []string) [][]string {
+	var ret [][]string
+	for len(keys) > copyPatchMaxMemberships {
+		ret = append(ret, keys[:copyPatchMaxMemberships])
+		keys = keys[copyPatchMaxMemberships:]
+	}
+	if len(keys) != 0 {
+		ret = append(ret, keys)
+	}
+	return ret
+}
+
+func countMembershipMismatches(expected, actual map[string]bigSegmentUserMembership) int {
+	mismatches := 0
+	for userHashKey, m := range expected {
+		a, ok := actual[userHashKey]
+		if !ok || !sameSegmentIDs(m.Included, a.Included) || !sameSegmentIDs(m.Excluded, a.Excluded) {
+			mismatches++
+		}
+	}
+	for userHashKey := range actual {
+		if _, ok := expected[userHashKey]; !ok {
+			mismatches++
+		}
+	}
+	return mismatches
+}
+
+func sameSegmentIDs(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	sortedA := append([]string(nil), a...)
+	sortedB := append([]string(nil), b...)
+	sort.Strings(sortedA)
+	sort.Strings(sortedB)
+	for i := range sortedA {
+		if sortedA[i] != sortedB[i] {
+			return false
+		}
+	}
+	return true
+}
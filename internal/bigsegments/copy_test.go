@@ -0,0 +1,189 @@
+package bigsegments
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldtime"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryBigSegmentStore is a simple implementation of BigSegmentStore with the same patch semantics as
+// the real stores.
+type memoryBigSegmentStore struct {
+	cursor     string
+	included   map[string]map[string]bool // user hash key -> segment IDs
+	excluded   map[string]map[string]bool
+	syncTime   ldtime.UnixMillisecondTime
+	patchCount int
+	lock       sync.Mutex
+}
+
+func newMemoryBigSegmentStore() *memoryBigSegmentStore {
+	return &memoryBigSegmentStore{
+		included: make(map[string]map[string]bool),
+		excluded: make(map[string]map[string]bool),
+	}
+}
+
+func (s *memoryBigSegmentStore) applyPatch(patch bigSegmentPatch) (bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.cursor != patch.PreviousVersion {
+		return false, nil
+	}
+	s.patchCount++
+	s.cursor = patch.Version
+	update := func(sets map[string]map[string]bool, mutations bigSegmentPatchChangesMutations) {
+		for _, user := range mutations.Add {
+			if sets[user] == nil {
+				sets[user] = make(map[string]bool)
+			}
+			sets[user][patch.SegmentID] = true
+		}
+		for _, user := range mutations.Remove {
+			delete(sets[user], patch.SegmentID)
+			if len(sets[user]) == 0 {
+				delete(sets, user)
+			}
+		}
+	}
+	update(s.included, patch.Changes.Included)
+	update(s.excluded, patch.Changes.Excluded)
+	return true, nil
+}
+
+func (s *memoryBigSegmentStore) getCursor() (string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.cursor, nil
+}
+
+func (s *memoryBigSegmentStore) getMemberships() (map[string]bigSegmentUserMembership, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	ret := make(map[string]bigSegmentUserMembership)
+	for user, segmentIDs := range s.included {
+		m := ret[user]
+		for segmentID := range segmentIDs {
+			m.Included = append(m.Included, segmentID)
+		}
+		ret[user] = m
+	}
+	for user, segmentIDs := range s.excluded {
+		m := ret[user]
+		for segmentID := range segmentIDs {
+			m.Excluded = append(m.Excluded, segmentID)
+		}
+		ret[user] = m
+	}
+	return ret, nil
+}
+
+func (s *memoryBigSegmentStore) setSynchronizedOn(synchronizedOn ldtime.UnixMillisecondTime) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.syncTime = synchronizedOn
+	return nil
+}
+
+func (s *memoryBigSegmentStore) GetSynchronizedOn() (ldtime.UnixMillisecondTime, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.syncTime, nil
+}
+
+func (s *memoryBigSegmentStore) Close() error { return nil }
+
+func TestCopyBigSegmentData(t *testing.T) {
+	from := newMemoryBigSegmentStore()
+	patch1 := newPatchBuilder("segment.g1", "", "").
+		addIncludes("user1", "user2").addExcludes("user3").build()
+	patch2 := newPatchBuilder("segment.g2", "cursor1", "").
+		addIncludes("user1").removeIncludes("user2").build()
+	for _, p := range []bigSegmentPatch{patch1, patch2} {
+		applied, err := from.applyPatch(p)
+		require.NoError(t, err)
+		require.True(t, applied)
+	}
+	require.NoError(t, from.setSynchronizedOn(ldtime.UnixMillisecondTime(12345)))
+
+	to := newMemoryBigSegmentStore()
+	result, err := CopyBigSegmentData(from, to)
+	require.NoError(t, err)
+	assert.Equal(t, BigSegmentCopyResult{Cursor: "cursor1", Users: 3, Memberships: 4}, result)
+
+	expected, _ := from.getMemberships()
+	actual, _ := to.getMemberships()
+	assert.Equal(t, 0, countMembershipMismatches(expected, actual))
+	assert.Equal(t, "cursor1", to.cursor)
+	assert.Equal(t, ldtime.UnixMillisecondTime(12345), to.syncTime)
+}
+
+func TestCopyBigSegmentDataSplitsLargeSegmentsIntoSeveralPatches(t *testing.T) {
+	from := newMemoryBigSegmentStore()
+	builder := newPatchBuilder("segment.g1", "cursor1", "")
+	userCount := copyPatchMaxMemberships*2 + 1
+	for i := 0; i < userCount; i++ {
+		builder.addIncludes(strconv.Itoa(i))
+	}
+	_, err := from.applyPatch(builder.build())
+	require.NoError(t, err)
+
+	to := newMemoryBigSegmentStore()
+	result, err := CopyBigSegmentData(from, to)
+	require.NoError(t, err)
+	assert.Equal(t, userCount, result.Users)
+	assert.Equal(t, 4, to.patchCount, "expected 3 patches of memberships plus one to set the cursor")
+}
+
+func TestCopyBigSegmentDataDoesNothingIfSourceIsEmpty(t *testing.T) {
+	to := newMemoryBigSegmentStore()
+	result, err := CopyBigSegmentData(newMemoryBigSegmentStore(), to)
+	require.NoError(t, err)
+	assert.Equal(t, BigSegmentCopyResult{}, result)
+	assert.Equal(t, 0, to.patchCount)
+}
+
+func TestCopyBigSegmentDataFailsIfDestinationIsNotEmpty(t *testing.T) {
+	from, to := newMemoryBigSegmentStore(), newMemoryBigSegmentStore()
+	for _, s := range []*memoryBigSegmentStore{from, to} {
+		_, err := s.applyPatch(newPatchBuilder("segment.g1", "cursor1", "").addIncludes("user1").build())
+		require.NoError(t, err)
+	}
+
+	_, err := CopyBigSegmentData(from, to)
+	assert.Equal(t, errBigSegmentDestinationNotEmpty, err)
+	assert.Equal(t, 1, to.patchCount)
+}
+
+// modifiedBigSegmentStore simulates another process writing to the destination during a copy, by
+// rejecting every patch as if its PreviousVersion did not match.
+type modifiedBigSegmentStore struct {
+	*memoryBigSegmentStore
+}
+
+func (s modifiedBigSegmentStore) applyPatch(bigSegmentPatch) (bool, error) { return false, nil }
+
+func TestCopyBigSegmentDataFailsIfDestinationIsModified(t *testing.T) {
+	from := newMemoryBigSegmentStore()
+	_, err := from.applyPatch(newPatchBuilder("segment.g1", "cursor1", "").addIncludes("user1").build())
+	require.NoError(t, err)
+
+	_, err = CopyBigSegmentData(from, modifiedBigSegmentStore{newMemoryBigSegmentStore()})
+	assert.Equal(t, errBigSegmentDestinationModified, err)
+}
+
+func TestCopyBigSegmentDataWithNoDestinationStore(t *testing.T) {
+	_, err := CopyBigSegmentData(newMemoryBigSegmentStore(), nil)
+	assert.NoError(t, err)
+
+	from := newMemoryBigSegmentStore()
+	_, err = from.applyPatch(newPatchBuilder("segment.g1", "cursor1", "").addIncludes("user1").build())
+	require.NoError(t, err)
+	_, err = CopyBigSegmentData(from, nil)
+	assert.Equal(t, errBigSegmentDestinationNotSupported, err)
+}
@@ -22,3 +22,9 @@ type bigSegmentPatch struct {
 	PreviousVersion string                 `json:"previousVersion"`
 	Changes         bigSegmentPatchChanges `json:"changes"`
 }
+
+// bigSegmentUserMembership lists the big segments that explicitly include or exclude a user.
+type bigSegmentUserMembership struct {
+	Included []string
+	Excluded []string
+}
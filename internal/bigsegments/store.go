@@ -23,6 +23,8 @@ type BigSegmentStore interface {
 	applyPatch(patch bigSegmentPatch) (bool, error)
 	// getCursor loads the synchronization cursor from the external store.
 	getCursor() (string, error)
+	// getMemberships loads all of the membership data from the external store, keyed by user hash key.
+	getMemberships() (map[string]bigSegmentUserMembership, error)
 	// setSynchronizedOn stores the synchronization time in the external store
 	setSynchronizedOn(synchronizedOn ldtime.UnixMillisecondTime) error
 	// GetSynchronizedOn returns the synchronization time from the external store.
@@ -75,6 +77,10 @@ func (s *nullBigSegmentStore) applyPatch(patch bigSegmentPatch) (bool, error) {
 
 func (s *nullBigSegmentStore) getCursor() (string, error) { return "", nil }
 
+func (s *nullBigSegmentStore) getMemberships() (map[string]bigSegmentUserMembership, error) {
+	return nil, nil
+}
+
 func (s *nullBigSegmentStore) setSynchronizedOn(synchronizedOn ldtime.UnixMillisecondTime) error {
 	return nil
 }
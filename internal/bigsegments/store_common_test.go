@@ -156,6 +156,31 @@ func testGenericAll(
 		})
 	})
 
+	t.Run("getMemberships", func(t *testing.T) {
+		withBigSegmentStore(t, func(store BigSegmentStore, operations bigSegmentOperations) {
+			memberships, err := store.getMemberships()
+			require.NoError(t, err)
+			assert.Len(t, memberships, 0)
+
+			for _, patch := range []bigSegmentPatch{patch1, patch2, patch3} {
+				success, err := store.applyPatch(patch)
+				require.NoError(t, err)
+				require.True(t, success)
+			}
+
+			memberships, err = store.getMemberships()
+			require.NoError(t, err)
+			assert.Equal(t, map[string]bigSegmentUserMembership{
+				"included2": {Included: []string{"segment.g1"}},
+				"included3": {Included: []string{"segment.g1"}},
+				"included4": {Included: []string{"segment.g1"}},
+				"excluded2": {Excluded: []string{"segment.g1"}},
+				"excluded3": {Excluded: []string{"segment.g1"}},
+				"excluded4": {Excluded: []string{"segment.g1"}},
+			}, memberships)
+		})
+	})
+
 	t.Run("patchLarge", func(t *testing.T) {
 		withBigSegmentStore(t, func(store BigSegmentStore, operations bigSegmentOperations) {
 			userCount := 50
@@ -243,6 +243,44 @@ func (store *dynamoDBBigSegmentStore) getCursor() (string, error) {
 	return "", nil
 }
 
+func (store *dynamoDBBigSegmentStore) getMemberships() (map[string]bigSegmentUserMembership, error) {
+	memberships := make(map[string]bigSegmentUserMembership)
+	paginator := dynamodb.NewQueryPaginator(store.client, &dynamodb.QueryInput{
+		TableName:              aws.String(store.table),
+		ConsistentRead:         aws.Bool(true),
+		KeyConditionExpression: aws.String("#0 = :0"),
+		ExpressionAttributeNames: map[string]string{
+			"#0": tablePartitionKey,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":0": attrValueOfString(dynamoDBUserDataKey(store.prefix)),
+		},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(store.context)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range page.Items {
+			userKey, ok := item[tableSortKey].(*types.AttributeValueMemberS)
+			if !ok {
+				continue
+			}
+			var m bigSegmentUserMembership
+			if included, ok := item[dynamoDBIncludedAttr].(*types.AttributeValueMemberSS); ok {
+				m.Included = included.Value
+			}
+			if excluded, ok := item[dynamoDBExcludedAttr].(*types.AttributeValueMemberSS); ok {
+				m.Excluded = excluded.Value
+			}
+			if len(m.Included) != 0 || len(m.Excluded) != 0 {
+				memberships[userKey.Value] = m
+			}
+		}
+	}
+	return memberships, nil
+}
+
 func (store *dynamoDBBigSegmentStore) setSynchronizedOn(synchronizedOn ldtime.UnixMillisecondTime) error {
 	bigSegmentsMetadataKeyWithPrefix := dynamoDBMetadataKey(store.prefix)
 	unixMilliseconds := strconv.FormatUint(uint64(synchronizedOn), 10)
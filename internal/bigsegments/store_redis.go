@@ -2,9 +2,9 @@ package bigsegments
 
 import (
 	"context"
-	"crypto/tls"
 	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/launchdarkly/ld-relay/v8/config"
 	"github.com/launchdarkly/ld-relay/v8/internal/sdks"
@@ -15,6 +15,9 @@ import (
 	"github.com/go-redis/redis/v8"
 )
 
+// redisScanCount is the number of keys that we ask Redis to examine in each SCAN call.
+const redisScanCount = 1000
+
 func redisLockKey(prefix string) string {
 	return fmt.Sprintf("%s:big_segments_lock", prefix)
 }
@@ -49,38 +52,15 @@ func newRedisBigSegmentStore(
 	checkOnStartup bool,
 	loggers ldlog.Loggers,
 ) (*redisBigSegmentStore, error) {
-	redisURL, prefix := sdks.GetRedisBasicProperties(redisConfig, envConfig)
-
-	opts := redis.UniversalOptions{}
+	_, prefix := sdks.GetRedisBasicProperties(redisConfig, envConfig)
 
-	// Relay's Redis configuration allows setting the server address either as a URL or as a
-	// host & port, but our config validation logic simplifies this so that it is always a URL.
-	// However, it is still possible to set the Password and TLS options separately from the
-	// URL, so we still need to check for those.
-	parsed, err := redis.ParseURL(redisURL)
+	opts, err := sdks.GetRedisClientOptions(redisConfig)
 	if err != nil {
 		return nil, err
 	}
-	opts.DB = parsed.DB
-	opts.Addrs = []string{parsed.Addr}
-	opts.Username = parsed.Username
-	opts.Password = parsed.Password
-	opts.TLSConfig = parsed.TLSConfig
-	if redisConfig.Password != "" {
-		opts.Password = redisConfig.Password
-	}
-	if redisConfig.Username != "" {
-		opts.Username = redisConfig.Username
-	}
-	if redisConfig.TLS && opts.TLSConfig == nil {
-		opts.TLSConfig = &tls.Config{
-			ServerName: redisConfig.URL.Get().Hostname(),
-			MinVersion: tls.VersionTLS12,
-		}
-	}
 
 	store := redisBigSegmentStore{
-		client:  redis.NewUniversalClient(&opts),
+		client:  redis.NewUniversalClient(opts),
 		prefix:  prefix,
 		loggers: loggers,
 	}
@@ -181,6 +161,40 @@ func (r *redisBigSegmentStore) getCursor() (string, error) {
 	return cursor, nil
 }
 
+func (r *redisBigSegmentStore) getMemberships() (map[string]bigSegmentUserMembership, error) {
+	ctx := context.Background()
+	memberships := make(map[string]bigSegmentUserMembership)
+	for _, included := range []bool{true, false} {
+		keyPrefix := redisExcludeKey(r.prefix, "")
+		if included {
+			keyPrefix = redisIncludeKey(r.prefix, "")
+		}
+		iter := r.client.Scan(ctx, 0, keyPrefix+"*", redisScanCount).Iterator()
+		for iter.Next(ctx) {
+			key := iter.Val()
+			segmentIDs, err := r.client.SMembers(ctx, key).Result()
+			if err != nil {
+				return nil, err
+			}
+			if len(segmentIDs) == 0 {
+				continue
+			}
+			userHashKey := strings.TrimPrefix(key, keyPrefix)
+			m := memberships[userHashKey]
+			if included {
+				m.Included = segmentIDs
+			} else {
+				m.Excluded = segmentIDs
+			}
+			memberships[userHashKey] = m
+		}
+		if err := iter.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return memberships, nil
+}
+
 func (r *redisBigSegmentStore) setSynchronizedOn(synchronizedOn ldtime.UnixMillisecondTime) error {
 	unixMilliseconds := strconv.FormatUint(uint64(synchronizedOn), 10)
 	return r.client.Set(context.Background(), redisSynchronizedKey(r.prefix), unixMilliseconds, 0).Err()
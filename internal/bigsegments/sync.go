@@ -2,6 +2,7 @@ package bigsegments
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -27,6 +28,8 @@ const (
 	segmentUpdatesChannelBufferSize = 20
 )
 
+var errResyncRequested = errors.New("resync was requested")
+
 // BigSegmentSynchronizer synchronizes big segment state for a given environment.
 type BigSegmentSynchronizer interface {
 	// Start begins synchronization of an environment.
@@ -50,6 +53,13 @@ type BigSegmentSynchronizer interface {
 	// synchronizer.
 	SegmentUpdatesCh() <-chan UpdatesSummary
 
+	// Resync makes the synchronizer disconnect from LaunchDarkly, poll for any updates that it does not
+	// have yet, and reconnect.
+	//
+	// This method does not block. If the synchronizer has not been started, or has been closed, this has
+	// no effect.
+	Resync()
+
 	// Close ends synchronization of an environment.
 	//
 	// This method does not block.
@@ -89,6 +99,7 @@ type defaultBigSegmentSynchronizer struct {
 	sdkKey              config.SDKKey
 	streamRetryInterval time.Duration
 	segmentUpdatesChan  chan UpdatesSummary
+	resyncChan          chan struct{}
 	hasSynced           bool
 	syncedLock          sync.RWMutex
 	startOnce           sync.Once
@@ -138,6 +149,7 @@ func newDefaultBigSegmentSynchronizer(
 		sdkKey:              sdkKey,
 		streamRetryInterval: defaultStreamRetryInterval,
 		segmentUpdatesChan:  make(chan UpdatesSummary, segmentUpdatesChannelBufferSize),
+		resyncChan:          make(chan struct{}, 1),
 		closeChan:           make(chan struct{}),
 		loggers:             loggers,
 	}
@@ -176,6 +188,13 @@ func (s *defaultBigSegmentSynchronizer) SegmentUpdatesCh() <-chan UpdatesSummary
 	return s.segmentUpdatesChan
 }
 
+func (s *defaultBigSegmentSynchronizer) Resync() {
+	select {
+	case s.resyncChan <- struct{}{}:
+	default: // a resync is already pending
+	}
+}
+
 func (s *defaultBigSegmentSynchronizer) Close() {
 	// If we haven't yet started, we still need to close the updates channel; calling
 	// startOnce.Do also ensures that Start() will have no effect after this
@@ -193,6 +212,11 @@ func (s *defaultBigSegmentSynchronizer) syncSupervisor() {
 	isRetry := false
 	for {
 		err := s.sync(isRetry)
+		if err == errResyncRequested {
+			s.loggers.Info("Resynchronizing")
+			isRetry = false
+			continue
+		}
 		if err != nil {
 			s.loggers.Error("Synchronization failed:", err)
 			if statusError, ok := err.(httpStatusError); ok {
@@ -209,6 +233,7 @@ func (s *defaultBigSegmentSynchronizer) syncSupervisor() {
 			close(s.segmentUpdatesChan)
 			return
 		case <-timer.C:
+		case <-s.resyncChan: // no need to keep waiting, since we've been asked to try again now
 		}
 		isRetry = true
 	}
@@ -216,6 +241,10 @@ func (s *defaultBigSegmentSynchronizer) syncSupervisor() {
 
 func (s *defaultBigSegmentSynchronizer) sync(isRetry bool) error {
 	s.loggers.Debug("Polling for big segment updates")
+	select {
+	case <-s.resyncChan: // we're about to poll anyway, so any pending resync request is satisfied
+	default:
+	}
 	segmentsUpdated := make(segmentChangesSummary)
 	for {
 	SyncLoop:
@@ -399,6 +428,9 @@ func (s *defaultBigSegmentSynchronizer) consumeStream(stream *es.Stream) error {
 			if err != nil {
 				return err
 			}
+		case <-s.resyncChan:
+			timer.Stop()
+			return errResyncRequested
 		case <-s.closeChan:
 			timer.Stop()
 			return nil
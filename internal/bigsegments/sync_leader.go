@@ -0,0 +1,172 @@
+package bigsegments
+
+import (
+	"sync"
+	"time"
+)
+
+// defaultFollowerPollInterval is how often a LeaderOnlySynchronizer that is not the writer checks whether
+// the writer has updated the big segment data.
+const defaultFollowerPollInterval = time.Second * 5
+
+// LeaderOnlySynchronizer is a BigSegmentSynchronizer that only synchronizes while this Relay instance is
+// the elected writer for an environment's persistent store, so that when several Relay instances share
+// a database, only one of them updates the big segment data. It creates a new synchronizer each time
+// this instance becomes the writer, since a synchronizer cannot be restarted after it has been closed.
+//
+// While this instance is not the writer, it polls the store's synchronization cursor instead, and sends
+// an UpdatesSummary with no segment keys to SegmentUpdatesCh whenever the writer has changed the data,
+// so that this instance still knows when to invalidate its cached big segment state.
+type LeaderOnlySynchronizer struct {
+	makeSynchronizer func() BigSegmentSynchronizer
+	store            BigSegmentStore
+	pollInterval     time.Duration
+	current          BigSegmentSynchronizer
+	stopFollowing    chan struct{}
+	started          bool
+	leader           bool
+	closed           bool
+	updatesCh        chan UpdatesSummary
+	forwarders       sync.WaitGroup
+	lock             sync.Mutex
+}
+
+// NewLeaderOnlySynchronizer creates a LeaderOnlySynchronizer that uses the specified function to create
+// the underlying synchronizer, and the specified store to detect updates made by the writer. Initially
+// this instance is not the writer.
+func NewLeaderOnlySynchronizer(
+	makeSynchronizer func() BigSegmentSynchronizer,
+	store BigSegmentStore,
+) *LeaderOnlySynchronizer {
+	return &LeaderOnlySynchronizer{
+		makeSynchronizer: makeSynchronizer,
+		store:            store,
+		pollInterval:     defaultFollowerPollInterval,
+		updatesCh:        make(chan UpdatesSummary, segmentUpdatesChannelBufferSize),
+	}
+}
+
+// Start implements BigSegmentSynchronizer.Start. Synchronization does not begin until this instance is
+// the writer.
+func (s *LeaderOnlySynchronizer) Start() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.started = true
+	s.update()
+}
+
+// SetLeader specifies whether this instance is currently the writer.
+func (s *LeaderOnlySynchronizer) SetLeader(leader bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.leader = leader
+	s.update()
+}
+
+// HasSynced implements BigSegmentSynchronizer.HasSynced. It returns false if this instance is not the
+// writer, since it is then not the one that is synchronizing.
+func (s *LeaderOnlySynchronizer) HasSynced() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.current != nil && s.current.HasSynced()
+}
+
+// SegmentUpdatesCh implements BigSegmentSynchronizer.SegmentUpdatesCh. It receives the updates from
+// each underlying synchronizer, and the updates made by another writer while this instance is not the
+// writer.
+func (s *LeaderOnlySynchronizer) SegmentUpdatesCh() <-chan UpdatesSummary {
+	return s.updatesCh
+}
+
+// Resync implements BigSegmentSynchronizer.Resync. It has no effect if this instance is not the writer.
+func (s *LeaderOnlySynchronizer) Resync() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.current != nil {
+		s.current.Resync()
+	}
+}
+
+// Close implements BigSegmentSynchronizer.Close.
+func (s *LeaderOnlySynchronizer) Close() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	s.update()
+	go func() {
+		s.forwarders.Wait()
+		close(s.updatesCh)
+	}()
+}
+
+func (s *LeaderOnlySynchronizer) update() {
+	shouldRun := s.started && s.leader && !s.closed
+	shouldFollow := s.started && !s.leader && !s.closed
+	switch {
+	case shouldFollow && s.stopFollowing == nil:
+		s.stopFollowing = make(chan struct{})
+		s.forwarders.Add(1)
+		go s.follow(s.stopFollowing)
+	case !shouldFollow && s.stopFollowing != nil:
+		close(s.stopFollowing)
+		s.stopFollowing = nil
+	}
+	switch {
+	case shouldRun && s.current == nil:
+		s.current = s.makeSynchronizer()
+		if ch := s.current.SegmentUpdatesCh(); ch != nil {
+			s.forwarders.Add(1)
+			go func() {
+				defer s.forwarders.Done()
+				for summary := range ch {
+					s.updatesCh <- summary
+				}
+			}()
+		}
+		s.current.Start()
+	case !shouldRun && s.current != nil:
+		s.current.Close()
+		s.current = nil
+	}
+}
+
+// follow polls the store's cursor until stopCh is closed, and sends an UpdatesSummary whenever the cursor
+// changes. Errors are ignored, since the store's status is reported separately; if the cursor could not be
+// read before, the next successful poll is treated as a change, since the data may have changed meanwhile.
+func (s *LeaderOnlySynchronizer) follow(stopCh <-chan struct{}) {
+	defer s.forwarders.Done()
+	lastCursor, err := s.store.getCursor()
+	known := err == nil
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+		}
+		cursor, err := s.store.getCursor()
+		if err != nil {
+			continue
+		}
+		if known && cursor == lastCursor {
+			continue
+		}
+		lastCursor, known = cursor, true
+		select {
+		case <-stopCh:
+			// This instance became the writer while we were polling, so the change is no longer ours to
+			// report; checking this first means we can't send it just because both channels are ready.
+			return
+		default:
+		}
+		select {
+		case s.updatesCh <- UpdatesSummary{}:
+		case <-stopCh:
+			return
+		}
+	}
+}
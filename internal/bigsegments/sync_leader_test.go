@@ -0,0 +1,175 @@
+package bigsegments
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	helpers "github.com/launchdarkly/go-test-helpers/v3"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockSynchronizer struct {
+	started   bool
+	closed    bool
+	resyncs   int
+	updatesCh chan UpdatesSummary
+	lock      sync.Mutex
+}
+
+func (s *mockSynchronizer) Start() {
+	s.lock.Lock()
+	s.started = true
+	s.lock.Unlock()
+}
+
+func (s *mockSynchronizer) HasSynced() bool {
+	return s.isStarted()
+}
+
+func (s *mockSynchronizer) SegmentUpdatesCh() <-chan UpdatesSummary {
+	return s.updatesCh
+}
+
+func (s *mockSynchronizer) Resync() {
+	s.lock.Lock()
+	s.resyncs++
+	s.lock.Unlock()
+}
+
+func (s *mockSynchronizer) getResyncs() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.resyncs
+}
+
+func (s *mockSynchronizer) Close() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.updatesCh)
+	}
+}
+
+func (s *mockSynchronizer) isStarted() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.started && !s.closed
+}
+
+func makeLeaderOnlySynchronizerWithMocks() (*LeaderOnlySynchronizer, func() []*mockSynchronizer) {
+	return makeLeaderOnlySynchronizerWithStore(NewNullBigSegmentStore())
+}
+
+func makeLeaderOnlySynchronizerWithStore(store BigSegmentStore) (*LeaderOnlySynchronizer, func() []*mockSynchronizer) {
+	var created []*mockSynchronizer
+	var lock sync.Mutex
+	s := NewLeaderOnlySynchronizer(func() BigSegmentSynchronizer {
+		lock.Lock()
+		defer lock.Unlock()
+		m := &mockSynchronizer{updatesCh: make(chan UpdatesSummary, 10)}
+		created = append(created, m)
+		return m
+	}, store)
+	s.pollInterval = time.Millisecond * 10
+	return s, func() []*mockSynchronizer {
+		lock.Lock()
+		defer lock.Unlock()
+		return append([]*mockSynchronizer(nil), created...)
+	}
+}
+
+func TestLeaderOnlySynchronizerRunsOnlyWhenStartedAndLeader(t *testing.T) {
+	s, getCreated := makeLeaderOnlySynchronizerWithMocks()
+	defer s.Close()
+
+	s.Start()
+	assert.Len(t, getCreated(), 0)
+	assert.False(t, s.HasSynced())
+
+	s.SetLeader(true)
+	require.Len(t, getCreated(), 1)
+	assert.True(t, getCreated()[0].isStarted())
+	assert.True(t, s.HasSynced())
+
+	s.SetLeader(false)
+	assert.False(t, getCreated()[0].isStarted())
+	assert.False(t, s.HasSynced())
+
+	s.SetLeader(true)
+	require.Len(t, getCreated(), 2, "should create a new synchronizer since they cannot be restarted")
+	assert.True(t, getCreated()[1].isStarted())
+}
+
+func TestLeaderOnlySynchronizerDoesNotRunBeforeStart(t *testing.T) {
+	s, getCreated := makeLeaderOnlySynchronizerWithMocks()
+	defer s.Close()
+
+	s.SetLeader(true)
+	assert.Len(t, getCreated(), 0)
+
+	s.Start()
+	require.Len(t, getCreated(), 1)
+	assert.True(t, getCreated()[0].isStarted())
+}
+
+func TestLeaderOnlySynchronizerResyncsOnlyWhenLeader(t *testing.T) {
+	s, getCreated := makeLeaderOnlySynchronizerWithMocks()
+	defer s.Close()
+
+	s.Start()
+	s.Resync() // has no effect, since there's no underlying synchronizer yet
+
+	s.SetLeader(true)
+	require.Len(t, getCreated(), 1)
+	s.Resync()
+	assert.Equal(t, 1, getCreated()[0].getResyncs())
+}
+
+func TestLeaderOnlySynchronizerForwardsUpdates(t *testing.T) {
+	s, getCreated := makeLeaderOnlySynchronizerWithMocks()
+	s.Start()
+	s.SetLeader(true)
+	summary := UpdatesSummary{SegmentKeysUpdated: []string{"segment1"}}
+	getCreated()[0].updatesCh <- summary
+	assert.Equal(t, summary, helpers.RequireValue(t, s.SegmentUpdatesCh(), time.Second))
+
+	s.Close()
+	assert.False(t, getCreated()[0].isStarted())
+	helpers.AssertChannelClosed(t, s.SegmentUpdatesCh(), time.Second)
+}
+
+func TestLeaderOnlySynchronizerClosesUpdatesChannelIfNeverStarted(t *testing.T) {
+	s, _ := makeLeaderOnlySynchronizerWithMocks()
+	s.Close()
+	helpers.AssertChannelClosed(t, s.SegmentUpdatesCh(), time.Second)
+
+	s.Start()
+	s.SetLeader(true)
+	assert.False(t, s.HasSynced())
+}
+
+func TestLeaderOnlySynchronizerReportsUpdatesFromAnotherWriter(t *testing.T) {
+	store := newMemoryBigSegmentStore()
+	s, getCreated := makeLeaderOnlySynchronizerWithStore(store)
+	defer s.Close()
+
+	s.Start()
+	assert.Len(t, getCreated(), 0)
+	helpers.AssertNoMoreValues(t, s.SegmentUpdatesCh(), time.Millisecond*50,
+		"should not report an update if the cursor is unchanged")
+
+	_, err := store.applyPatch(newPatchBuilder("segment1", "1", "").addIncludes("user1").build())
+	require.NoError(t, err)
+	assert.Equal(t, UpdatesSummary{}, helpers.RequireValue(t, s.SegmentUpdatesCh(), time.Second))
+	helpers.AssertNoMoreValues(t, s.SegmentUpdatesCh(), time.Millisecond*50)
+
+	s.SetLeader(true)
+	_, err = store.applyPatch(newPatchBuilder("segment1", "2", "1").addIncludes("user2").build())
+	require.NoError(t, err)
+	helpers.AssertNoMoreValues(t, s.SegmentUpdatesCh(), time.Millisecond*50,
+		"should not poll the store while this instance is the writer")
+}
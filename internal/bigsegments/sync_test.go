@@ -48,6 +48,10 @@ func (s *bigSegmentStoreMock) getCursor() (string, error) {
 	return s.cursor, nil
 }
 
+func (s *bigSegmentStoreMock) getMemberships() (map[string]bigSegmentUserMembership, error) {
+	return nil, nil
+}
+
 func (s *bigSegmentStoreMock) setSynchronizedOn(synchronizedOn ldtime.UnixMillisecondTime) error {
 	s.syncTimeCh <- synchronizedOn
 
@@ -534,3 +538,64 @@ func TestSyncRetryIfStreamFails(t *testing.T) {
 		})
 	})
 }
+
+func TestSyncResyncPollsAndReconnectsStream(t *testing.T) {
+	mockLog := ldlogtest.NewMockLog()
+	mockLog.Loggers.SetMinLevel(ldlog.Debug)
+	defer mockLog.DumpIfTestFailed(t)
+
+	patch1 := newPatchBuilder("segment.g1", "1", "").build()
+	patch2 := newPatchBuilder("segment.g1", "2", "1").build()
+
+	pollHandler, requestsCh := httphelpers.RecordingHandler(
+		httphelpers.SequentialHandler(
+			httphelpers.HandlerWithJSONResponse([]bigSegmentPatch{patch1}, nil), // poll 1: initial connection
+			httphelpers.HandlerWithJSONResponse([]bigSegmentPatch{}, nil),       // poll 2: completion of poll 1
+			httphelpers.HandlerWithJSONResponse([]bigSegmentPatch{}, nil),       // poll 3: done in conjunction with stream 1
+			httphelpers.HandlerWithJSONResponse([]bigSegmentPatch{patch2}, nil), // poll 4: after resync
+			httphelpers.HandlerWithJSONResponse([]bigSegmentPatch{}, nil),       // poll 5: completion of poll 4
+			httphelpers.HandlerWithJSONResponse([]bigSegmentPatch{}, nil),       // poll 6: done in conjunction with stream 2
+		),
+	)
+
+	sseHandler1, _ := httphelpers.SSEHandler(nil)
+	sseHandler2, _ := httphelpers.SSEHandler(nil)
+	streamsHandler, streamRequestsCh := httphelpers.RecordingHandler(
+		httphelpers.SequentialHandler(sseHandler1, sseHandler2),
+	)
+
+	httphelpers.WithServer(pollHandler, func(pollServer *httptest.Server) {
+		httphelpers.WithServer(streamsHandler, func(streamServer *httptest.Server) {
+			storeMock := newBigSegmentStoreMock()
+			defer storeMock.Close()
+
+			segmentSync := newDefaultBigSegmentSynchronizer(sharedtest.MakeBasicHTTPConfig(), storeMock,
+				pollServer.URL, streamServer.URL, config.EnvironmentID("env-xyz"), testSDKKey, mockLog.Loggers, "")
+			defer segmentSync.Close()
+			segmentSync.Start()
+			go func() {
+				for range segmentSync.SegmentUpdatesCh() {
+				}
+			}()
+
+			for i := 0; i < 3; i++ {
+				helpers.RequireValue(t, requestsCh, time.Second)
+			}
+			requirePatch(t, storeMock, patch1)
+			helpers.RequireValue(t, streamRequestsCh, time.Second)
+			helpers.RequireValue(t, storeMock.syncTimeCh, time.Second)
+
+			segmentSync.Resync()
+
+			pollReq4 := helpers.RequireValue(t, requestsCh, time.Second)
+			assertPollRequest(t, pollReq4, patch1.Version)
+			requirePatch(t, storeMock, patch2)
+			helpers.RequireValue(t, requestsCh, time.Second)
+			helpers.RequireValue(t, requestsCh, time.Second)
+			streamReq2 := helpers.RequireValue(t, streamRequestsCh, time.Second)
+			assertStreamRequest(t, streamReq2)
+
+			mockLog.AssertMessageMatch(t, true, ldlog.Info, "Resynchronizing")
+		})
+	})
+}
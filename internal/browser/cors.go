@@ -11,6 +11,11 @@ import (
 const (
 	// DefaultAllowedOrigin is the default origin string to use in CORS response headers.
 	DefaultAllowedOrigin = "*"
+
+	// StaleDataHeader is added to responses with the value "true" if the environment is serving data from
+	// the data store that may be out of date. It is defined here so that it can be included in the
+	// exposed headers for CORS requests.
+	StaleDataHeader = "X-LD-Relay-Stale-Data"
 )
 
 type corsContextKeyType string
@@ -33,6 +38,12 @@ var DefaultAllowedHeaders = strings.Join([]string{ //nolint:gochecknoglobals
 	events.TagsHeader,
 }, ",")
 
+// ExposedHeaders is the value of the CORS header Access-Control-Expose-Headers.
+var ExposedHeaders = strings.Join([]string{ //nolint:gochecknoglobals
+	"Date",
+	StaleDataHeader,
+}, ",")
+
 // CORSContext represents a scope that has a specific set of allowed origins for CORS requests. This
 // can be attached to a request context with WithCORSContext().
 type CORSContext interface {
@@ -68,5 +79,5 @@ func SetCORSHeaders(w http.ResponseWriter, origin string, extraAllowedHeaders []
 		allAllowedHeaders = allAllowedHeaders + "," + strings.Join(extraAllowedHeaders, ",")
 	}
 	w.Header().Set("Access-Control-Allow-Headers", allAllowedHeaders)
-	w.Header().Set("Access-Control-Expose-Headers", "Date")
+	w.Header().Set("Access-Control-Expose-Headers", ExposedHeaders)
 }
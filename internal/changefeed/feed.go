@@ -0,0 +1,202 @@
+package changefeed
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/launchdarkly/eventsource"
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	"github.com/launchdarkly/go-sdk-common/v3/ldtime"
+)
+
+const sseChannel = "changes"
+
+// Source describes how Relay learned of a change.
+type Source string
+
+const (
+	// SourcePut means that the change was found by comparing a full data set (a "put" event) with the
+	// previous one.
+	SourcePut Source = "put"
+
+	// SourcePatch means that the change was received as a single item update (a "patch" or "delete" event).
+	SourcePatch Source = "patch"
+)
+
+// Change describes a flag or segment change that Relay received. This is also the JSON representation that
+// is returned by the admin endpoints and written to the change feed file.
+type Change struct {
+	// ID is a sequence number that identifies the change within this Relay instance's feed.
+	ID uint64 `json:"id"`
+
+	// Time is when Relay received the change.
+	Time ldtime.UnixMillisecondTime `json:"time"`
+
+	// Environment is the environment's display name.
+	Environment string `json:"env"`
+
+	// Kind is the kind of data: "features" or "segments".
+	Kind string `json:"kind"`
+
+	// Key is the flag or segment key.
+	Key string `json:"key"`
+
+	// OldVersion is the version that Relay had before, or zero if the item is new.
+	OldVersion int `json:"oldVersion,omitempty"`
+
+	// NewVersion is the version that Relay received. It is zero if a full data set no longer contains
+	// the item at all.
+	NewVersion int `json:"newVersion"`
+
+	// Deleted is true if the item was deleted.
+	Deleted bool `json:"deleted,omitempty"`
+
+	// Source describes how Relay learned of the change.
+	Source Source `json:"source"`
+}
+
+// Feed keeps the most recent changes from all environments in a fixed-size ring buffer, and optionally
+// writes every change as a line of JSON to a file. It also provides an SSE stream of changes, which
+// begins with the changes that are in the buffer; since a change can be added to the buffer just before
+// a client connects and then also be published to it, clients should ignore any change whose ID they
+// have already seen.
+type Feed struct {
+	changes   []Change // ring buffer; next is the position of the oldest change once it is full
+	next      int
+	lastID    uint64
+	sink      io.Writer
+	server    *eventsource.Server
+	listeners []func(Change)
+	loggers   ldlog.Loggers
+	mu        sync.Mutex
+	// Add holds emitLock from assigning the ID until it has finished emitting the change, so that the
+	// sink, the SSE stream, and the listeners always see changes in ID order.
+	emitLock sync.Mutex
+	// Add holds closeLock for reading while it publishes, so that Close cannot shut down the SSE server
+	// during a Publish call. This can't be mu, because Publish waits for the SSE server's goroutine, and
+	// that goroutine calls GetChanges when a client connects.
+	closed    bool
+	closeLock sync.RWMutex
+}
+
+type changeEvent struct {
+	change Change
+	data   []byte
+}
+
+type feedRepository struct {
+	feed *Feed
+}
+
+// NewFeed creates a Feed that keeps up to capacity changes in memory. If sink is not nil, each change is
+// also written to it.
+func NewFeed(capacity int, sink io.Writer, loggers ldlog.Loggers) *Feed {
+	server := eventsource.NewServer()
+	server.ReplayAll = true
+	f := &Feed{
+		changes: make([]Change, 0, capacity),
+		sink:    sink,
+		server:  server,
+		loggers: loggers,
+	}
+	server.Register(sseChannel, feedRepository{f})
+	return f
+}
+
+// Add assigns the next ID to a change, and records it.
+func (f *Feed) Add(change Change) {
+	f.emitLock.Lock()
+	defer f.emitLock.Unlock()
+
+	f.mu.Lock()
+	f.lastID++
+	change.ID = f.lastID
+	if len(f.changes) < cap(f.changes) {
+		f.changes = append(f.changes, change)
+	} else {
+		f.changes[f.next] = change
+		f.next = (f.next + 1) % len(f.changes)
+	}
+	listeners := f.listeners
+	f.mu.Unlock()
+
+	// The SSE server would block forever if we published to it after closing it.
+	f.closeLock.RLock()
+	defer f.closeLock.RUnlock()
+	if f.closed {
+		return
+	}
+	event := makeChangeEvent(change)
+	if f.sink != nil {
+		if _, err := f.sink.Write(append(event.data, '\n')); err != nil {
+			f.loggers.Errorf("Unable to write to change feed file: %s", err)
+		}
+	}
+	f.server.Publish([]string{sseChannel}, event)
+	for _, listener := range listeners {
+		listener(change)
+	}
+}
+
+// AddListener registers a function to be called synchronously for each change that is added to the feed
+// before it is closed. The function should not block, and must not add changes to the feed.
+func (f *Feed) AddListener(listener func(Change)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.listeners = append(f.listeners, listener)
+}
+
+// GetChanges returns the changes in the buffer whose IDs are greater than afterID, oldest first.
+func (f *Feed) GetChanges(afterID uint64) []Change {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ret := make([]Change, 0, len(f.changes))
+	for i := range f.changes {
+		change := f.changes[(f.next+i)%len(f.changes)]
+		if change.ID > afterID {
+			ret = append(ret, change)
+		}
+	}
+	return ret
+}
+
+// Handler returns an HTTP handler for an SSE stream of changes. If the request has a Last-Event-ID
+// header, the stream begins with the buffered changes after that ID; otherwise, it begins with all of
+// the buffered changes.
+func (f *Feed) Handler() http.Handler {
+	return f.server.Handler(sseChannel)
+}
+
+// Close disconnects all SSE clients. Changes that are added after this are kept in the buffer, but are
+// not written to the sink.
+func (f *Feed) Close() {
+	f.closeLock.Lock()
+	defer f.closeLock.Unlock()
+	if !f.closed {
+		f.closed = true
+		f.server.Close()
+	}
+}
+
+func makeChangeEvent(change Change) changeEvent {
+	data, _ := json.Marshal(change)
+	return changeEvent{change: change, data: data}
+}
+
+func (e changeEvent) Event() string { return "change" }
+func (e changeEvent) Id() string    { return strconv.FormatUint(e.change.ID, 10) } //nolint:golint,stylecheck
+func (e changeEvent) Data() string  { return string(e.data) }
+
+func (r feedRepository) Replay(channel, id string) chan eventsource.Event {
+	afterID, _ := strconv.ParseUint(id, 10, 64) // an invalid ID is treated as no ID
+	changes := r.feed.GetChanges(afterID)
+	ch := make(chan eventsource.Event, len(changes))
+	for _, change := range changes {
+		ch <- makeChangeEvent(change)
+	}
+	close(ch)
+	return ch
+}
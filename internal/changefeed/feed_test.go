@@ -0,0 +1,126 @@
+package changefeed
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/eventsource"
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFeedKeepsMostRecentChanges(t *testing.T) {
+	feed := NewFeed(3, nil, ldlog.NewDisabledLoggers())
+	defer feed.Close()
+	assert.Empty(t, feed.GetChanges(0))
+
+	for _, key := range []string{"a", "b", "c", "d", "e"} {
+		feed.Add(Change{Key: key})
+	}
+
+	changes := feed.GetChanges(0)
+	require.Len(t, changes, 3)
+	assert.Equal(t, Change{ID: 3, Key: "c"}, changes[0])
+	assert.Equal(t, Change{ID: 4, Key: "d"}, changes[1])
+	assert.Equal(t, Change{ID: 5, Key: "e"}, changes[2])
+
+	assert.Equal(t, []Change{{ID: 5, Key: "e"}}, feed.GetChanges(4))
+	assert.Empty(t, feed.GetChanges(5))
+}
+
+func TestFeedWritesChangesToSink(t *testing.T) {
+	var buf bytes.Buffer
+	feed := NewFeed(10, &buf, ldlog.NewDisabledLoggers())
+	defer feed.Close()
+
+	feed.Add(Change{Environment: "env1", Kind: "features", Key: "flag1", OldVersion: 1, NewVersion: 2, Source: SourcePatch})
+	feed.Add(Change{Environment: "env1", Kind: "segments", Key: "segment1", NewVersion: 1, Source: SourcePut})
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	require.Len(t, lines, 2)
+	assert.JSONEq(t, `{"id": 1, "time": 0, "env": "env1", "kind": "features", "key": "flag1",
+		"oldVersion": 1, "newVersion": 2, "source": "patch"}`, lines[0])
+	assert.JSONEq(t, `{"id": 2, "time": 0, "env": "env1", "kind": "segments", "key": "segment1",
+		"newVersion": 1, "source": "put"}`, lines[1])
+}
+
+func TestFeedStreamsBufferedAndNewChanges(t *testing.T) {
+	feed := NewFeed(10, nil, ldlog.NewDisabledLoggers())
+	defer feed.Close()
+	feed.Add(Change{Key: "a"})
+	feed.Add(Change{Key: "b"})
+
+	server := httptest.NewServer(feed.Handler())
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	req.Header.Set("Last-Event-ID", "1")
+	stream, err := eventsource.SubscribeWithRequest("", req)
+	require.NoError(t, err)
+	defer stream.Close()
+
+	requireChange := func(expectedKey string) {
+		select {
+		case event := <-stream.Events:
+			var change Change
+			require.NoError(t, json.Unmarshal([]byte(event.Data()), &change))
+			assert.Equal(t, "change", event.Event())
+			assert.Equal(t, expectedKey, change.Key)
+		case <-time.After(time.Second):
+			require.Fail(t, "timed out waiting for change", expectedKey)
+		}
+	}
+
+	requireChange("b")
+	feed.Add(Change{Key: "c"})
+	requireChange("c")
+}
+
+func TestFeedCallsListeners(t *testing.T) {
+	feed := NewFeed(10, nil, ldlog.NewDisabledLoggers())
+	var received []Change
+	feed.AddListener(func(change Change) { received = append(received, change) })
+
+	feed.Add(Change{Key: "a"})
+	feed.Close()
+	feed.Add(Change{Key: "b"})
+
+	assert.Equal(t, []Change{{ID: 1, Key: "a"}}, received)
+}
+
+func TestFeedEmitsConcurrentChangesInIDOrder(t *testing.T) {
+	var buf bytes.Buffer
+	feed := NewFeed(10, &buf, ldlog.NewDisabledLoggers())
+	defer feed.Close()
+	var received []uint64
+	feed.AddListener(func(change Change) { received = append(received, change.ID) })
+
+	const numChanges = 100
+	var wg sync.WaitGroup
+	for i := 0; i < numChanges; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			feed.Add(Change{Key: "a"})
+		}()
+	}
+	wg.Wait()
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	require.Len(t, lines, numChanges)
+	require.Len(t, received, numChanges)
+	for i, line := range lines {
+		var change Change
+		require.NoError(t, json.Unmarshal([]byte(line), &change))
+		assert.Equal(t, uint64(i+1), change.ID)
+		assert.Equal(t, uint64(i+1), received[i])
+	}
+}
@@ -0,0 +1,3 @@
+// Package changefeed keeps a record of the flag and segment changes that Relay has received, so that
+// they can be correlated with other events on a particular Relay instance.
+package changefeed
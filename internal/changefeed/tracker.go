@@ -0,0 +1,111 @@
+package changefeed
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldtime"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoretypes"
+)
+
+// Tracker detects the flag and segment changes for one environment, and adds them to a Feed. It keeps
+// the version of every item it has seen, so that it can report the old version of a changed item and
+// find the differences between one full data set and the next.
+//
+// The first full data set that a Tracker receives is not reported as changes, since it is just the
+// data that Relay started with.
+type Tracker struct {
+	feed           *Feed
+	getEnvironment func() string
+	items          map[string]map[string]itemState // kind name -> key -> state
+	hasData        bool
+	mu             sync.Mutex
+}
+
+type itemState struct {
+	version int
+	deleted bool
+}
+
+// NewTracker creates a Tracker. The getEnvironment function provides the environment name for each change,
+// since that can change while Relay is running.
+func NewTracker(feed *Feed, getEnvironment func() string) *Tracker {
+	return &Tracker{
+		feed:           feed,
+		getEnvironment: getEnvironment,
+		items:          make(map[string]map[string]itemState),
+	}
+}
+
+// RecordAllData compares a full data set with the previous data, and reports any items that were added,
+// changed, or removed.
+func (t *Tracker) RecordAllData(allData []ldstoretypes.Collection) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	newItems := make(map[string]map[string]itemState, len(allData))
+	var changes []Change
+	for _, coll := range allData {
+		kind := coll.Kind.GetName()
+		oldItems := t.items[kind]
+		items := make(map[string]itemState, len(coll.Items))
+		for _, item := range coll.Items {
+			state := itemState{version: item.Item.Version, deleted: item.Item.Item == nil}
+			items[item.Key] = state
+			oldState, found := oldItems[item.Key]
+			if state == oldState || (!found && state.deleted) {
+				continue
+			}
+			changes = append(changes, Change{Kind: kind, Key: item.Key, OldVersion: oldState.version,
+				NewVersion: state.version, Deleted: state.deleted})
+		}
+		newItems[kind] = items
+	}
+	for kind, oldItems := range t.items {
+		var removedKeys []string
+		for key, oldState := range oldItems {
+			if _, found := newItems[kind][key]; !found && !oldState.deleted {
+				removedKeys = append(removedKeys, key)
+			}
+		}
+		sort.Strings(removedKeys)
+		for _, key := range removedKeys {
+			changes = append(changes, Change{Kind: kind, Key: key, OldVersion: oldItems[key].version, Deleted: true})
+		}
+	}
+	t.items = newItems
+
+	if !t.hasData {
+		t.hasData = true
+		return
+	}
+	for _, change := range changes {
+		t.add(change, SourcePut)
+	}
+}
+
+// RecordItem reports a single item update, unless Relay already had the same or a later version of the
+// item.
+func (t *Tracker) RecordItem(kind ldstoretypes.DataKind, key string, item ldstoretypes.ItemDescriptor) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	items := t.items[kind.GetName()]
+	if items == nil {
+		items = make(map[string]itemState)
+		t.items[kind.GetName()] = items
+	}
+	oldVersion := items[key].version
+	if item.Version <= oldVersion {
+		return
+	}
+	items[key] = itemState{version: item.Version, deleted: item.Item == nil}
+	t.add(Change{Kind: kind.GetName(), Key: key, OldVersion: oldVersion, NewVersion: item.Version,
+		Deleted: item.Item == nil}, SourcePatch)
+}
+
+func (t *Tracker) add(change Change, source Source) {
+	change.Time = ldtime.UnixMillisFromTime(time.Now())
+	change.Environment = t.getEnvironment()
+	change.Source = source
+	t.feed.Add(change)
+}
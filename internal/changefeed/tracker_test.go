@@ -0,0 +1,89 @@
+package changefeed
+
+import (
+	"testing"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	"github.com/launchdarkly/go-server-sdk-evaluation/v3/ldbuilders"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoreimpl"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoretypes"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func flagItem(key string, version int) ldstoretypes.KeyedItemDescriptor {
+	flag := ldbuilders.NewFlagBuilder(key).Version(version).Build()
+	return ldstoretypes.KeyedItemDescriptor{Key: key, Item: ldstoretypes.ItemDescriptor{Version: version, Item: &flag}}
+}
+
+func deletedItem(key string, version int) ldstoretypes.KeyedItemDescriptor {
+	return ldstoretypes.KeyedItemDescriptor{Key: key, Item: ldstoretypes.ItemDescriptor{Version: version}}
+}
+
+func flagData(items ...ldstoretypes.KeyedItemDescriptor) []ldstoretypes.Collection {
+	return []ldstoretypes.Collection{{Kind: ldstoreimpl.Features(), Items: items}}
+}
+
+// getChanges returns the changes in the feed without the properties that vary from one test run to another.
+func getChanges(feed *Feed) []Change {
+	changes := feed.GetChanges(0)
+	for i := range changes {
+		changes[i].ID = 0
+		changes[i].Time = 0
+	}
+	return changes
+}
+
+func makeTracker() (*Tracker, *Feed) {
+	feed := NewFeed(100, nil, ldlog.NewDisabledLoggers())
+	return NewTracker(feed, func() string { return "env1" }), feed
+}
+
+func TestTrackerDoesNotReportInitialData(t *testing.T) {
+	tracker, feed := makeTracker()
+	defer feed.Close()
+
+	tracker.RecordAllData(flagData(flagItem("flag1", 1), flagItem("flag2", 1)))
+	assert.Empty(t, feed.GetChanges(0))
+}
+
+func TestTrackerReportsDifferencesBetweenFullDataSets(t *testing.T) {
+	tracker, feed := makeTracker()
+	defer feed.Close()
+
+	tracker.RecordAllData(flagData(flagItem("flag1", 1), flagItem("flag2", 1), flagItem("flag3", 1),
+		deletedItem("flag4", 2)))
+	tracker.RecordAllData(flagData(flagItem("flag1", 1), flagItem("flag2", 2), deletedItem("flag5", 3),
+		flagItem("flag6", 1)))
+
+	assert.Equal(t, []Change{
+		{Environment: "env1", Kind: "features", Key: "flag2", OldVersion: 1, NewVersion: 2, Source: SourcePut},
+		{Environment: "env1", Kind: "features", Key: "flag6", NewVersion: 1, Source: SourcePut},
+		{Environment: "env1", Kind: "features", Key: "flag3", OldVersion: 1, Deleted: true, Source: SourcePut},
+	}, getChanges(feed))
+}
+
+func TestTrackerReportsSingleItemUpdates(t *testing.T) {
+	tracker, feed := makeTracker()
+	defer feed.Close()
+
+	tracker.RecordAllData(flagData(flagItem("flag1", 1)))
+	item := flagItem("flag1", 2)
+	tracker.RecordItem(ldstoreimpl.Features(), item.Key, item.Item)
+	tracker.RecordItem(ldstoreimpl.Features(), item.Key, item.Item) // same version, not reported
+	deleted := deletedItem("flag1", 3)
+	tracker.RecordItem(ldstoreimpl.Features(), deleted.Key, deleted.Item)
+	newItem := flagItem("flag2", 1)
+	tracker.RecordItem(ldstoreimpl.Features(), newItem.Key, newItem.Item)
+
+	assert.Equal(t, []Change{
+		{Environment: "env1", Kind: "features", Key: "flag1", OldVersion: 1, NewVersion: 2, Source: SourcePatch},
+		{Environment: "env1", Kind: "features", Key: "flag1", OldVersion: 2, NewVersion: 3, Deleted: true,
+			Source: SourcePatch},
+		{Environment: "env1", Kind: "features", Key: "flag2", NewVersion: 1, Source: SourcePatch},
+	}, getChanges(feed))
+
+	// The next full data set is compared with the data including the single item updates.
+	tracker.RecordAllData(flagData(deletedItem("flag1", 3), flagItem("flag2", 1)))
+	assert.Len(t, feed.GetChanges(0), 3)
+}
@@ -0,0 +1,59 @@
+package cluster
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"time"
+)
+
+// LeaseStore is a shared service that nodes use to decide which of them is responsible for something,
+// by holding a named lease that expires unless it is renewed. Implementations must be safe for
+// concurrent use.
+type LeaseStore interface {
+	// TryAcquireLease attempts to acquire the named lease for the specified node, or to extend it if the
+	// node already holds it. It returns true if the node holds the lease for at least the specified time
+	// from now, or false if another node holds it.
+	TryAcquireLease(ctx context.Context, name, nodeID string, ttl time.Duration) (bool, error)
+
+	// ReleaseLease gives up the named lease, if the specified node holds it.
+	ReleaseLease(ctx context.Context, name, nodeID string) error
+
+	// Close releases any resources used by the LeaseStore.
+	Close() error
+}
+
+// Backend is the shared service that the nodes in a cluster use to elect leaders and to send messages
+// to each other. Implementations must be safe for concurrent use.
+type Backend interface {
+	LeaseStore
+
+	// Publish sends a message to all current subscribers of a channel, including the sender if it is
+	// subscribed. Delivery is not guaranteed.
+	Publish(ctx context.Context, channel string, message []byte) error
+
+	// Subscribe starts receiving messages from a channel.
+	Subscribe(ctx context.Context, channel string) (Subscription, error)
+}
+
+// Subscription is a channel subscription created by Backend.Subscribe.
+type Subscription interface {
+	// Messages returns a channel that receives each message. It is closed when the Subscription is closed.
+	Messages() <-chan []byte
+
+	// Close stops the subscription.
+	Close() error
+}
+
+// DefaultNodeID returns a node identifier that is made from the host name and a random suffix, so that
+// it is unique even if several Relay instances are running on the same host.
+func DefaultNodeID() string {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "relay"
+	}
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+	return hostname + "-" + hex.EncodeToString(suffix)
+}
@@ -0,0 +1,116 @@
+package cluster
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// InMemoryBackend is a Backend that only works within a single process. It is used in tests to
+// simulate several nodes that share a Redis server.
+type InMemoryBackend struct {
+	leases        map[string]inMemoryLease
+	subscriptions map[string]map[*inMemorySubscription]struct{}
+	lock          sync.Mutex
+}
+
+type inMemoryLease struct {
+	nodeID  string
+	expires time.Time
+}
+
+type inMemorySubscription struct {
+	backend   *InMemoryBackend
+	channel   string
+	messageCh chan []byte
+	closeOnce sync.Once
+}
+
+// NewInMemoryBackend creates an InMemoryBackend.
+func NewInMemoryBackend() *InMemoryBackend {
+	return &InMemoryBackend{
+		leases:        make(map[string]inMemoryLease),
+		subscriptions: make(map[string]map[*inMemorySubscription]struct{}),
+	}
+}
+
+// TryAcquireLease implements Backend.TryAcquireLease.
+func (b *InMemoryBackend) TryAcquireLease(ctx context.Context, name, nodeID string, ttl time.Duration) (bool, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	now := time.Now()
+	if lease, ok := b.leases[name]; ok && lease.nodeID != nodeID && lease.expires.After(now) {
+		return false, nil
+	}
+	b.leases[name] = inMemoryLease{nodeID: nodeID, expires: now.Add(ttl)}
+	return true, nil
+}
+
+// ReleaseLease implements Backend.ReleaseLease.
+func (b *InMemoryBackend) ReleaseLease(ctx context.Context, name, nodeID string) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if lease, ok := b.leases[name]; ok && lease.nodeID == nodeID {
+		delete(b.leases, name)
+	}
+	return nil
+}
+
+// GetLeaseHolder returns the node that currently holds the named lease, or "" if none does.
+func (b *InMemoryBackend) GetLeaseHolder(name string) string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if lease, ok := b.leases[name]; ok && lease.expires.After(time.Now()) {
+		return lease.nodeID
+	}
+	return ""
+}
+
+// Publish implements Backend.Publish. Like Redis pub/sub, it drops messages for a subscriber that is
+// not keeping up.
+func (b *InMemoryBackend) Publish(ctx context.Context, channel string, message []byte) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for s := range b.subscriptions[channel] {
+		select {
+		case s.messageCh <- message:
+		default:
+		}
+	}
+	return nil
+}
+
+// Subscribe implements Backend.Subscribe.
+func (b *InMemoryBackend) Subscribe(ctx context.Context, channel string) (Subscription, error) {
+	s := &inMemorySubscription{
+		backend:   b,
+		channel:   channel,
+		messageCh: make(chan []byte, 100),
+	}
+	b.lock.Lock()
+	if b.subscriptions[channel] == nil {
+		b.subscriptions[channel] = make(map[*inMemorySubscription]struct{})
+	}
+	b.subscriptions[channel][s] = struct{}{}
+	b.lock.Unlock()
+	return s, nil
+}
+
+// Close implements Backend.Close.
+func (b *InMemoryBackend) Close() error {
+	return nil
+}
+
+func (s *inMemorySubscription) Messages() <-chan []byte {
+	return s.messageCh
+}
+
+func (s *inMemorySubscription) Close() error {
+	s.closeOnce.Do(func() {
+		s.backend.lock.Lock()
+		delete(s.backend.subscriptions[s.channel], s)
+		close(s.messageCh)
+		s.backend.lock.Unlock()
+	})
+	return nil
+}
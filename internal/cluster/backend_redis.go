@@ -0,0 +1,158 @@
+package cluster
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v8/config"
+	"github.com/launchdarkly/ld-relay/v8/internal/sdks"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// All Redis keys and channels used by cluster mode start with this prefix. They do not use the
+// environment prefixes from the configuration, since the channel names already identify environments.
+const redisKeyPrefix = "ld-relay-cluster"
+
+// This script acquires the lease if no one holds it, or extends it if the same node already holds it,
+// as a single atomic operation.
+//
+//nolint:gochecknoglobals
+var redisAcquireLeaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	redis.call("PEXPIRE", KEYS[1], ARGV[2])
+	return 1
+end
+if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	return 1
+end
+return 0
+`)
+
+// This script deletes the lease only if it is still held by the same node.
+//
+//nolint:gochecknoglobals
+var redisReleaseLeaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+type redisBackend struct {
+	client       redis.UniversalClient
+	keyPrefix    string
+	sharedClient bool // true if the client belongs to a redisLeaseClient
+}
+
+type redisSubscription struct {
+	pubsub    *redis.PubSub
+	messageCh chan []byte
+	closeCh   chan struct{}
+	closeOnce sync.Once
+}
+
+// NewRedisBackend creates a Backend that uses Redis keys with expiration times for leases, and Redis
+// pub/sub for messages.
+func NewRedisBackend(redisConfig config.RedisConfig) (Backend, error) {
+	opts, err := sdks.GetRedisClientOptions(redisConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &redisBackend{client: redis.NewUniversalClient(opts), keyPrefix: redisKeyPrefix}, nil
+}
+
+type redisLeaseClient struct {
+	client      redis.UniversalClient
+	redisConfig config.RedisConfig
+}
+
+// NewRedisLeaseClient creates a DataStoreLeaseClient whose lease stores use Redis keys with expiration
+// times. Unlike the leases of NewRedisBackend, these keys start with the environment's prefix, so that
+// leases for data that is stored under that prefix are kept alongside it.
+func NewRedisLeaseClient(redisConfig config.RedisConfig) (DataStoreLeaseClient, error) {
+	opts, err := sdks.GetRedisClientOptions(redisConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &redisLeaseClient{client: redis.NewUniversalClient(opts), redisConfig: redisConfig}, nil
+}
+
+func (c *redisLeaseClient) LeaseStore(envConfig config.EnvConfig) LeaseStore {
+	_, prefix := sdks.GetRedisBasicProperties(c.redisConfig, envConfig)
+	return &redisBackend{client: c.client, keyPrefix: prefix + ":relay", sharedClient: true}
+}
+
+func (c *redisLeaseClient) Close() error {
+	return c.client.Close()
+}
+
+func (b *redisBackend) leaseKey(name string) string {
+	return b.keyPrefix + ":lease:" + name
+}
+
+func (b *redisBackend) channelName(channel string) string {
+	return b.keyPrefix + ":" + channel
+}
+
+func (b *redisBackend) TryAcquireLease(ctx context.Context, name, nodeID string, ttl time.Duration) (bool, error) {
+	result, err := redisAcquireLeaseScript.Run(ctx, b.client, []string{b.leaseKey(name)},
+		nodeID, ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, err
+	}
+	return result == 1, nil
+}
+
+func (b *redisBackend) ReleaseLease(ctx context.Context, name, nodeID string) error {
+	return redisReleaseLeaseScript.Run(ctx, b.client, []string{b.leaseKey(name)}, nodeID).Err()
+}
+
+func (b *redisBackend) Publish(ctx context.Context, channel string, message []byte) error {
+	return b.client.Publish(ctx, b.channelName(channel), message).Err()
+}
+
+func (b *redisBackend) Subscribe(ctx context.Context, channel string) (Subscription, error) {
+	pubsub := b.client.Subscribe(ctx, b.channelName(channel))
+	// Wait for the subscription to be confirmed, so that we don't miss any messages that are published
+	// after this method returns. If the connection is lost later, go-redis resubscribes automatically.
+	if _, err := pubsub.Receive(ctx); err != nil {
+		_ = pubsub.Close()
+		return nil, err
+	}
+	s := &redisSubscription{
+		pubsub:    pubsub,
+		messageCh: make(chan []byte, 100),
+		closeCh:   make(chan struct{}),
+	}
+	go func() {
+		defer close(s.messageCh)
+		for m := range pubsub.Channel() {
+			select {
+			case s.messageCh <- []byte(m.Payload):
+			case <-s.closeCh:
+				return
+			}
+		}
+	}()
+	return s, nil
+}
+
+func (b *redisBackend) Close() error {
+	if b.sharedClient {
+		return nil
+	}
+	return b.client.Close()
+}
+
+func (s *redisSubscription) Messages() <-chan []byte {
+	return s.messageCh
+}
+
+func (s *redisSubscription) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.closeCh)
+	})
+	return s.pubsub.Close()
+}
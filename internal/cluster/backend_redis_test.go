@@ -0,0 +1,51 @@
+//go:build redis_unit_tests
+
+package cluster
+
+import (
+	"context"
+	"testing"
+
+	"github.com/launchdarkly/ld-relay/v8/config"
+
+	ct "github.com/launchdarkly/go-configtypes"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisBackend(t *testing.T) {
+	testBackend(t, func(t *testing.T) Backend {
+		var redisConfig config.RedisConfig
+		redisConfig.URL, _ = ct.NewOptURLAbsoluteFromString("redis://127.0.0.1:6379")
+		backend, err := NewRedisBackend(redisConfig)
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = backend.Close() })
+
+		client := backend.(*redisBackend).client
+		keys, err := client.Keys(context.Background(), redisKeyPrefix+":*").Result()
+		require.NoError(t, err)
+		if len(keys) > 0 {
+			require.NoError(t, client.Del(context.Background(), keys...).Err())
+		}
+		return backend
+	})
+}
+
+func TestRedisLeaseStore(t *testing.T) {
+	testLeaseStore(t, func(t *testing.T) LeaseStore {
+		var redisConfig config.RedisConfig
+		redisConfig.URL, _ = ct.NewOptURLAbsoluteFromString("redis://127.0.0.1:6379")
+		leaseClient, err := NewRedisLeaseClient(redisConfig)
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = leaseClient.Close() })
+		store := leaseClient.LeaseStore(config.EnvConfig{Prefix: "test-prefix"})
+
+		client := store.(*redisBackend).client
+		keys, err := client.Keys(context.Background(), "test-prefix:relay:*").Result()
+		require.NoError(t, err)
+		if len(keys) > 0 {
+			require.NoError(t, client.Del(context.Background(), keys...).Err())
+		}
+		return store
+	})
+}
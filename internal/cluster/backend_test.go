@@ -0,0 +1,103 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	helpers "github.com/launchdarkly/go-test-helpers/v3"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testLeaseStore runs the same tests against any LeaseStore implementation.
+func testLeaseStore(t *testing.T, makeLeaseStore func(t *testing.T) LeaseStore) {
+	ctx := context.Background()
+
+	t.Run("lease can only be held by one node", func(t *testing.T) {
+		backend := makeLeaseStore(t)
+		held, err := backend.TryAcquireLease(ctx, "lease1", "node1", time.Minute)
+		require.NoError(t, err)
+		assert.True(t, held)
+
+		held, err = backend.TryAcquireLease(ctx, "lease1", "node2", time.Minute)
+		require.NoError(t, err)
+		assert.False(t, held)
+
+		held, err = backend.TryAcquireLease(ctx, "lease1", "node1", time.Minute)
+		require.NoError(t, err)
+		assert.True(t, held, "holder should be able to renew the lease")
+
+		held, err = backend.TryAcquireLease(ctx, "lease2", "node2", time.Minute)
+		require.NoError(t, err)
+		assert.True(t, held, "leases with different names should be independent")
+	})
+
+	t.Run("lease can be acquired after it expires", func(t *testing.T) {
+		backend := makeLeaseStore(t)
+		held, err := backend.TryAcquireLease(ctx, "lease1", "node1", 50*time.Millisecond)
+		require.NoError(t, err)
+		require.True(t, held)
+
+		require.Eventually(t, func() bool {
+			held, err := backend.TryAcquireLease(ctx, "lease1", "node2", time.Minute)
+			return err == nil && held
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("lease can only be released by holder", func(t *testing.T) {
+		backend := makeLeaseStore(t)
+		held, err := backend.TryAcquireLease(ctx, "lease1", "node1", time.Minute)
+		require.NoError(t, err)
+		require.True(t, held)
+
+		require.NoError(t, backend.ReleaseLease(ctx, "lease1", "node2"))
+		held, err = backend.TryAcquireLease(ctx, "lease1", "node2", time.Minute)
+		require.NoError(t, err)
+		assert.False(t, held)
+
+		require.NoError(t, backend.ReleaseLease(ctx, "lease1", "node1"))
+		held, err = backend.TryAcquireLease(ctx, "lease1", "node2", time.Minute)
+		require.NoError(t, err)
+		assert.True(t, held)
+	})
+}
+
+// testBackend runs the same tests against any Backend implementation.
+func testBackend(t *testing.T, makeBackend func(t *testing.T) Backend) {
+	ctx := context.Background()
+
+	testLeaseStore(t, func(t *testing.T) LeaseStore { return makeBackend(t) })
+
+	t.Run("messages are delivered to subscribers of the channel", func(t *testing.T) {
+		backend := makeBackend(t)
+		sub1, err := backend.Subscribe(ctx, "channel1")
+		require.NoError(t, err)
+		defer sub1.Close()
+		sub2, err := backend.Subscribe(ctx, "channel1")
+		require.NoError(t, err)
+		defer sub2.Close()
+		otherSub, err := backend.Subscribe(ctx, "channel2")
+		require.NoError(t, err)
+		defer otherSub.Close()
+
+		require.NoError(t, backend.Publish(ctx, "channel1", []byte("hello")))
+
+		assert.Equal(t, []byte("hello"), helpers.RequireValue(t, sub1.Messages(), time.Second))
+		assert.Equal(t, []byte("hello"), helpers.RequireValue(t, sub2.Messages(), time.Second))
+		helpers.AssertNoMoreValues(t, otherSub.Messages(), 50*time.Millisecond)
+	})
+
+	t.Run("messages channel is closed when subscription is closed", func(t *testing.T) {
+		backend := makeBackend(t)
+		sub, err := backend.Subscribe(ctx, "channel1")
+		require.NoError(t, err)
+		require.NoError(t, sub.Close())
+		helpers.AssertChannelClosed(t, sub.Messages(), time.Second)
+	})
+}
+
+func TestInMemoryBackend(t *testing.T) {
+	testBackend(t, func(t *testing.T) Backend { return NewInMemoryBackend() })
+}
@@ -0,0 +1,678 @@
+package cluster
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	"github.com/launchdarkly/go-server-sdk/v7/interfaces"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoreimpl"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoretypes"
+)
+
+const (
+	backendOperationTimeout = 5 * time.Second
+
+	logMsgBecameLeader       = "Node %s is now the cluster leader for this environment; connecting to LaunchDarkly"
+	logMsgLostLeadership     = "Node %s is no longer the cluster leader for this environment"
+	logMsgFollowingLeader    = "Receiving data for this environment from cluster leader %s"
+	logMsgLeaderTimedOut     = "No heartbeat from cluster leader %q for %s; will take over if possible"
+	logMsgSubscribeFailed    = "Unable to subscribe to cluster updates; connecting to LaunchDarkly directly until the cluster is available"
+	logMsgLeaseFailed        = "Unable to check the cluster leader lease; connecting to LaunchDarkly directly until the cluster is available"
+	logMsgBackendError       = "Cluster operation failed: %s"
+	logMsgBackendRecovered   = "Cluster operations are succeeding again"
+	logMsgInvalidMessage     = "Received invalid cluster message: %s"
+	logMsgUpstreamBuildError = "Unable to create data source: %s"
+	errMsgLeaderTimedOut     = "no heartbeat from cluster leader"
+)
+
+// DataSourceOptions contains the parameters for DataSource.
+type DataSourceOptions struct {
+	// Backend is the service that the nodes in the cluster share.
+	Backend Backend
+
+	// NodeID uniquely identifies this node in the cluster.
+	NodeID string
+
+	// FilterKey is the environment's payload filter key, if any. Environments with different filters
+	// have different leaders.
+	FilterKey string
+
+	// HeartbeatInterval is how often the leader sends a heartbeat and renews its lease.
+	HeartbeatInterval time.Duration
+
+	// LeaderTimeout is how long followers wait without a heartbeat before trying to take over as leader.
+	// It is also the duration of the leader's lease.
+	LeaderTimeout time.Duration
+}
+
+type dataSourceConfigurer struct {
+	upstream  subsystems.ComponentConfigurer[subsystems.DataSource]
+	options   DataSourceOptions
+	instances *nodeInstances
+}
+
+// nodeInstances counts the data sources on this node for each channel. When Relay restarts the SDK client
+// for an environment, the new client's data source is started before the old one is closed, and they have
+// the same node ID; so the old one must not release the lease, or tell the other nodes that it has resigned,
+// when the new one is still using it.
+type nodeInstances struct {
+	counts map[string]int
+	lock   sync.Mutex
+}
+
+// clusterDataSource is the SDK data source for an environment in cluster mode.
+//
+// When it starts, it tries to acquire the environment's lease. If it succeeds, this node is the leader: it
+// starts the upstream data source (normally the SDK's streaming data source), and publishes all of the
+// updates it gets from that source, as well as regular heartbeats. Otherwise this node is a follower: it
+// applies the updates that it receives from the leader, and if the leader's heartbeats stop, it tries to
+// acquire the lease so it can take over.
+//
+// Delivery of messages is not guaranteed, so every update and heartbeat from the leader has a sequence
+// number. If a follower sees a gap in the sequence, it asks the leader to send the full data set again.
+// Since the full data set can be large, followers space out these requests, and the leader sends it at most
+// once per heartbeat interval no matter how many nodes ask for it. That message is addressed to the nodes
+// that asked for it; the others only use its sequence number, so that one node joining the cluster doesn't
+// make every node reload its data and resend it to all of its SDK connections.
+type clusterDataSource struct {
+	upstream      subsystems.ComponentConfigurer[subsystems.DataSource]
+	options       DataSourceOptions
+	instances     *nodeInstances
+	clientContext subsystems.ClientContext
+	sink          subsystems.DataSourceUpdateSink
+	channel       string
+	loggers       ldlog.Loggers
+
+	// These fields are only accessed from the run goroutine.
+	subscription  Subscription
+	leader        *leaderState
+	leaderID      string
+	lastHeartbeat time.Time
+	lastSequence  uint64
+	interrupted   bool
+	syncDelay     time.Duration
+	nextSync      time.Time
+	syncPending   bool
+
+	initialized    bool
+	backendFailing bool
+	started        bool
+	readyCh        chan<- struct{}
+	readyOnce      sync.Once
+	closeCh        chan struct{}
+	closeOnce      sync.Once
+	doneCh         chan struct{}
+	lock           sync.Mutex
+}
+
+type leaderState struct {
+	dataSource subsystems.DataSource
+	sink       *leaderUpdateSink
+}
+
+// leaderUpdateSink receives updates from the upstream data source while this node is the leader. It
+// passes them along to the SDK as usual, and also publishes them to the other nodes. It keeps its own copy
+// of the data so that it can send the full data set to any follower that asks for it.
+type leaderUpdateSink struct {
+	owner          *clusterDataSource
+	data           map[ldstoretypes.DataKind]map[string]ldstoretypes.ItemDescriptor
+	sequence       uint64
+	active         bool
+	lastPublishAll time.Time
+	syncRequesters []string
+	lock           sync.Mutex
+}
+
+// DataSource returns an SDK data source configuration for cluster mode, which wraps the configuration of
+// the data source that Relay would otherwise use. Only one node in the cluster at a time uses the wrapped
+// data source for each environment; the other nodes get the same data from that node.
+func DataSource(
+	upstream subsystems.ComponentConfigurer[subsystems.DataSource],
+	options DataSourceOptions,
+) subsystems.ComponentConfigurer[subsystems.DataSource] {
+	return dataSourceConfigurer{
+		upstream:  upstream,
+		options:   options,
+		instances: &nodeInstances{counts: make(map[string]int)},
+	}
+}
+
+// EnvironmentChannel returns the name that nodes use for an environment's lease and channel. It is based
+// on a hash of the SDK key, so that the key itself is not visible to anyone who can see the Redis data.
+func EnvironmentChannel(sdkKey, filterKey string) string {
+	hash := sha256.Sum256([]byte(sdkKey))
+	name := hex.EncodeToString(hash[:])
+	if filterKey != "" {
+		name += "/" + filterKey
+	}
+	return name
+}
+
+func (c dataSourceConfigurer) Build(context subsystems.ClientContext) (subsystems.DataSource, error) {
+	channel := EnvironmentChannel(context.GetSDKKey(), c.options.FilterKey)
+	c.instances.add(channel)
+	return &clusterDataSource{
+		upstream:      c.upstream,
+		options:       c.options,
+		instances:     c.instances,
+		clientContext: context,
+		sink:          context.GetDataSourceUpdateSink(),
+		channel:       channel,
+		loggers:       context.GetLogging().Loggers,
+		closeCh:       make(chan struct{}),
+		doneCh:        make(chan struct{}),
+	}, nil
+}
+
+func (n *nodeInstances) add(channel string) {
+	n.lock.Lock()
+	n.counts[channel]++
+	n.lock.Unlock()
+}
+
+// remove returns true if there are no other data sources for the channel.
+func (n *nodeInstances) remove(channel string) bool {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.counts[channel]--
+	if n.counts[channel] > 0 {
+		return false
+	}
+	delete(n.counts, channel)
+	return true
+}
+
+func (ds *clusterDataSource) IsInitialized() bool {
+	ds.lock.Lock()
+	defer ds.lock.Unlock()
+	return ds.initialized
+}
+
+func (ds *clusterDataSource) Start(closeWhenReady chan<- struct{}) {
+	ds.lock.Lock()
+	ds.started = true
+	ds.readyCh = closeWhenReady
+	ds.lock.Unlock()
+	go ds.run()
+}
+
+func (ds *clusterDataSource) Close() error {
+	ds.lock.Lock()
+	started := ds.started
+	ds.lock.Unlock()
+	ds.closeOnce.Do(func() {
+		if !started {
+			ds.instances.remove(ds.channel) // otherwise the run goroutine does this
+		}
+		close(ds.closeCh)
+	})
+	if started {
+		<-ds.doneCh
+	}
+	return nil
+}
+
+func (ds *clusterDataSource) run() {
+	defer close(ds.doneCh)
+
+	ds.lastHeartbeat = time.Now() // gives any existing leader time to send a heartbeat before we try to take over
+	if ds.subscribe() {
+		if !ds.tryBecomeLeader() {
+			ds.requestSync()
+		}
+	} else {
+		// If we can't use the cluster at all, the best we can do is to get the data ourselves.
+		ds.loggers.Error(logMsgSubscribeFailed)
+		ds.startLeading()
+	}
+
+	ticker := time.NewTicker(ds.options.HeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		var messages <-chan []byte
+		if ds.subscription != nil {
+			messages = ds.subscription.Messages()
+		}
+		select {
+		case <-ds.closeCh:
+			last := ds.instances.remove(ds.channel)
+			if ds.leader != nil {
+				ds.stopLeading()
+				if last {
+					ds.releaseLease()
+					ds.publish(message{Type: messageTypeResign})
+				}
+			}
+			if ds.subscription != nil {
+				_ = ds.subscription.Close()
+			}
+			return
+		case data, ok := <-messages:
+			if !ok {
+				ds.subscription = nil // we'll try to subscribe again on the next tick
+				continue
+			}
+			ds.handleMessage(data)
+		case <-ticker.C:
+			ds.handleTick()
+		}
+	}
+}
+
+func (ds *clusterDataSource) subscribe() bool {
+	ctx, cancel := context.WithTimeout(context.Background(), backendOperationTimeout)
+	defer cancel()
+	subscription, err := ds.options.Backend.Subscribe(ctx, ds.channel)
+	if err != nil {
+		ds.onBackendResult(err)
+		return false
+	}
+	ds.subscription = subscription
+	return true
+}
+
+func (ds *clusterDataSource) handleTick() {
+	subscribed := ds.subscription != nil || ds.subscribe()
+	if ds.leader != nil {
+		held, err := ds.acquireLease()
+		switch {
+		case err != nil:
+			// If the backend is unavailable, other nodes can't take over either, so keep going.
+		case !held:
+			ds.stopLeading()
+			ds.lastHeartbeat = time.Now()
+		default:
+			ds.leader.sink.publishHeartbeat()
+			ds.leader.sink.publishAllIfPending()
+		}
+		return
+	}
+	// If we have lost our connection to the backend, we won't receive any heartbeats, so the leader will
+	// time out. The subscription may still look open while that happens, since the Redis client keeps
+	// trying to reconnect.
+	if time.Since(ds.lastHeartbeat) > ds.options.LeaderTimeout {
+		if !ds.interrupted {
+			ds.loggers.Warnf(logMsgLeaderTimedOut, ds.leaderID, ds.options.LeaderTimeout)
+			ds.interrupted = true
+			ds.sink.UpdateStatus(interfaces.DataSourceStateInterrupted, interfaces.DataSourceErrorInfo{
+				Kind:    interfaces.DataSourceErrorKindUnknown,
+				Message: errMsgLeaderTimedOut,
+				Time:    time.Now(),
+			})
+		}
+		// As at startup, if we can't use the cluster at all, the best we can do is to get the data
+		// ourselves. Once the cluster is available again, we'll find out whether another node has the
+		// lease when we try to renew it.
+		held, err := ds.acquireLease()
+		switch {
+		case held:
+			ds.startLeading()
+		case err != nil:
+			ds.loggers.Error(logMsgLeaseFailed)
+			ds.startLeading()
+		case !subscribed:
+			ds.loggers.Error(logMsgSubscribeFailed)
+			ds.startLeading()
+		}
+		return
+	}
+	if subscribed && (!ds.IsInitialized() || ds.syncPending) {
+		ds.requestSync()
+	}
+}
+
+// requestSync asks the leader to send the full data set. Requests are spaced out, starting at the
+// heartbeat interval and doubling up to the leader timeout, until we receive the data; a request that
+// is too soon is deferred to a later tick.
+func (ds *clusterDataSource) requestSync() {
+	now := time.Now()
+	if now.Before(ds.nextSync) {
+		ds.syncPending = true
+		return
+	}
+	ds.syncPending = false
+	ds.publish(message{Type: messageTypeSync})
+	ds.syncDelay *= 2
+	if ds.syncDelay == 0 {
+		ds.syncDelay = ds.options.HeartbeatInterval
+	}
+	if ds.syncDelay > ds.options.LeaderTimeout {
+		ds.syncDelay = ds.options.LeaderTimeout
+	}
+	ds.nextSync = now.Add(ds.syncDelay)
+}
+
+// awaitingSync returns true if we have asked the leader for the full data set, and haven't received it yet.
+func (ds *clusterDataSource) awaitingSync() bool {
+	return ds.syncDelay != 0 || ds.syncPending
+}
+
+func (ds *clusterDataSource) resetSync() {
+	ds.syncDelay = 0
+	ds.nextSync = time.Time{}
+	ds.syncPending = false
+}
+
+func (ds *clusterDataSource) handleMessage(data []byte) {
+	var m message
+	if err := json.Unmarshal(data, &m); err != nil {
+		ds.loggers.Warnf(logMsgInvalidMessage, err)
+		return
+	}
+	if m.NodeID == ds.options.NodeID {
+		return // Redis delivers our own messages to us too
+	}
+
+	switch m.Type {
+	case messageTypeSync:
+		if ds.leader != nil {
+			ds.leader.sink.requestPublishAll(m.NodeID, ds.options.HeartbeatInterval)
+		}
+		return
+	case messageTypeResign:
+		if ds.leader == nil {
+			ds.lastHeartbeat = time.Now()
+			ds.tryBecomeLeader()
+		}
+		return
+	}
+
+	if ds.leader != nil {
+		// Another node thinks that it is the leader. That can happen briefly if our lease expired without
+		// our knowing it; we'll find out when we try to renew it.
+		return
+	}
+
+	if m.Type == messageTypePut && ds.IsInitialized() && !ds.awaitingSync() &&
+		(!m.isFor(ds.options.NodeID) || m.NodeID == ds.leaderID && m.Sequence == ds.lastSequence) {
+		// We already have this data, or another node asked for it. Either way, we only need its sequence
+		// number, as in a heartbeat; initializing the store again would resend all of the data to every SDK
+		// connection for no reason.
+		m.Type = messageTypeHeartbeat
+	}
+
+	ds.lastHeartbeat = time.Now()
+	needSync := false
+	if m.NodeID != ds.leaderID {
+		ds.leaderID = m.NodeID
+		ds.loggers.Infof(logMsgFollowingLeader, m.NodeID)
+		needSync = m.Type != messageTypePut
+	} else if m.Type == messageTypeHeartbeat && m.Sequence != ds.lastSequence ||
+		m.Type == messageTypePatch && m.Sequence != ds.lastSequence+1 {
+		needSync = true // we must have missed an update
+	}
+	ds.lastSequence = m.Sequence
+
+	switch m.Type {
+	case messageTypePut:
+		allData, err := m.getAllData()
+		if err != nil {
+			ds.loggers.Warnf(logMsgInvalidMessage, err)
+			needSync = true
+			break
+		}
+		ds.resetSync()
+		if ds.sink.Init(allData) {
+			ds.setInitialized()
+			ds.interrupted = false
+			ds.sink.UpdateStatus(interfaces.DataSourceStateValid, interfaces.DataSourceErrorInfo{})
+		}
+	case messageTypePatch:
+		kind, item, err := m.getItem()
+		if err != nil {
+			ds.loggers.Warnf(logMsgInvalidMessage, err)
+			needSync = true
+			break
+		}
+		ds.sink.Upsert(kind, m.Key, item)
+	}
+
+	if ds.interrupted && ds.IsInitialized() {
+		ds.interrupted = false
+		ds.sink.UpdateStatus(interfaces.DataSourceStateValid, interfaces.DataSourceErrorInfo{})
+	}
+	if needSync {
+		ds.requestSync()
+	}
+}
+
+func (ds *clusterDataSource) acquireLease() (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), backendOperationTimeout)
+	defer cancel()
+	held, err := ds.options.Backend.TryAcquireLease(ctx, ds.channel, ds.options.NodeID, ds.options.LeaderTimeout)
+	ds.onBackendResult(err)
+	return held, err
+}
+
+func (ds *clusterDataSource) releaseLease() {
+	ctx, cancel := context.WithTimeout(context.Background(), backendOperationTimeout)
+	defer cancel()
+	ds.onBackendResult(ds.options.Backend.ReleaseLease(ctx, ds.channel, ds.options.NodeID))
+}
+
+func (ds *clusterDataSource) tryBecomeLeader() bool {
+	if held, err := ds.acquireLease(); err != nil || !held {
+		return false
+	}
+	ds.startLeading()
+	return true
+}
+
+func (ds *clusterDataSource) startLeading() {
+	ds.loggers.Infof(logMsgBecameLeader, ds.options.NodeID)
+	ds.leaderID = ds.options.NodeID
+
+	sink := &leaderUpdateSink{owner: ds, active: true}
+	upstreamContext := subsystems.BasicClientContext{
+		SDKKey:               ds.clientContext.GetSDKKey(),
+		ApplicationInfo:      ds.clientContext.GetApplicationInfo(),
+		HTTP:                 ds.clientContext.GetHTTP(),
+		Logging:              ds.clientContext.GetLogging(),
+		Offline:              ds.clientContext.GetOffline(),
+		ServiceEndpoints:     ds.clientContext.GetServiceEndpoints(),
+		DataSourceUpdateSink: sink,
+		DataStoreUpdateSink:  ds.clientContext.GetDataStoreUpdateSink(),
+	}
+	dataSource, err := ds.upstream.Build(upstreamContext)
+	if err != nil {
+		ds.loggers.Errorf(logMsgUpstreamBuildError, err)
+		ds.sink.UpdateStatus(interfaces.DataSourceStateOff, interfaces.DataSourceErrorInfo{
+			Kind:    interfaces.DataSourceErrorKindUnknown,
+			Message: err.Error(),
+			Time:    time.Now(),
+		})
+		ds.setReady()
+		return
+	}
+	ds.leader = &leaderState{dataSource: dataSource, sink: sink}
+	ds.interrupted = false
+
+	upstreamReadyCh := make(chan struct{})
+	dataSource.Start(upstreamReadyCh)
+	go func() {
+		select {
+		case <-upstreamReadyCh:
+			ds.setReady()
+		case <-ds.closeCh:
+		}
+	}()
+
+	sink.publishHeartbeat()
+}
+
+func (ds *clusterDataSource) stopLeading() {
+	ds.loggers.Infof(logMsgLostLeadership, ds.options.NodeID)
+	// Deactivate the sink first, so that the upstream data source can't publish anything, or change
+	// our status to Off, as it shuts down.
+	ds.leader.sink.deactivate()
+	_ = ds.leader.dataSource.Close()
+	ds.leader = nil
+}
+
+func (ds *clusterDataSource) setInitialized() {
+	ds.lock.Lock()
+	ds.initialized = true
+	ds.lock.Unlock()
+	ds.setReady()
+}
+
+func (ds *clusterDataSource) setReady() {
+	ds.readyOnce.Do(func() {
+		close(ds.readyCh)
+	})
+}
+
+func (ds *clusterDataSource) publish(m message) {
+	m.NodeID = ds.options.NodeID
+	data, err := json.Marshal(m)
+	if err != nil { // COVERAGE: can't happen, all the fields are serializable
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), backendOperationTimeout)
+	defer cancel()
+	ds.onBackendResult(ds.options.Backend.Publish(ctx, ds.channel, data))
+}
+
+// onBackendResult logs the first of a series of backend errors as a warning, and subsequent ones only at
+// debug level, since if the backend is down we'll be getting an error on every heartbeat.
+func (ds *clusterDataSource) onBackendResult(err error) {
+	ds.lock.Lock()
+	wasFailing := ds.backendFailing
+	ds.backendFailing = err != nil
+	ds.lock.Unlock()
+	switch {
+	case err != nil && !wasFailing:
+		ds.loggers.Warnf(logMsgBackendError, err)
+	case err != nil:
+		ds.loggers.Debugf(logMsgBackendError, err)
+	case wasFailing:
+		ds.loggers.Info(logMsgBackendRecovered)
+	}
+}
+
+func (s *leaderUpdateSink) Init(allData []ldstoretypes.Collection) bool {
+	s.lock.Lock()
+	if !s.active {
+		s.lock.Unlock()
+		return false
+	}
+	s.data = make(map[ldstoretypes.DataKind]map[string]ldstoretypes.ItemDescriptor)
+	for _, coll := range allData {
+		items := make(map[string]ldstoretypes.ItemDescriptor, len(coll.Items))
+		for _, keyedItem := range coll.Items {
+			items[keyedItem.Key] = keyedItem.Item
+		}
+		s.data[coll.Kind] = items
+	}
+	s.sequence++
+	// We publish while holding the lock so that the other nodes receive updates in the same order
+	s.publishAllLocked(nil)
+	s.lock.Unlock()
+
+	if !s.owner.sink.Init(allData) {
+		return false
+	}
+	s.owner.setInitialized()
+	return true
+}
+
+func (s *leaderUpdateSink) Upsert(kind ldstoretypes.DataKind, key string, item ldstoretypes.ItemDescriptor) bool {
+	s.lock.Lock()
+	if !s.active {
+		s.lock.Unlock()
+		return false
+	}
+	if s.data != nil {
+		if s.data[kind] == nil {
+			s.data[kind] = make(map[string]ldstoretypes.ItemDescriptor)
+		}
+		if existing, ok := s.data[kind][key]; !ok || existing.Version < item.Version {
+			s.data[kind][key] = item
+		}
+	}
+	s.sequence++
+	m := makePatchMessage(kind, key, item)
+	m.Sequence = s.sequence
+	s.owner.publish(m)
+	s.lock.Unlock()
+
+	return s.owner.sink.Upsert(kind, key, item)
+}
+
+func (s *leaderUpdateSink) UpdateStatus(newState interfaces.DataSourceState, newError interfaces.DataSourceErrorInfo) {
+	s.lock.Lock()
+	active := s.active
+	s.lock.Unlock()
+	if active {
+		s.owner.sink.UpdateStatus(newState, newError)
+	}
+}
+
+func (s *leaderUpdateSink) GetDataStoreStatusProvider() interfaces.DataStoreStatusProvider {
+	return s.owner.sink.GetDataStoreStatusProvider()
+}
+
+// requestPublishAll sends the full data set to a follower that asked for it, unless it was already
+// published within minInterval; in that case it is sent by publishAllIfPending on a later heartbeat, so
+// that all of the requests in the meantime are answered by one message.
+func (s *leaderUpdateSink) requestPublishAll(nodeID string, minInterval time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if !s.active {
+		return
+	}
+	if !containsNode(s.syncRequesters, nodeID) {
+		s.syncRequesters = append(s.syncRequesters, nodeID)
+	}
+	if time.Since(s.lastPublishAll) < minInterval {
+		return
+	}
+	s.publishAllLocked(s.syncRequesters)
+}
+
+func (s *leaderUpdateSink) publishAllIfPending() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.active && len(s.syncRequesters) != 0 {
+		s.publishAllLocked(s.syncRequesters)
+	}
+}
+
+// publishAllLocked sends the full data set to the specified nodes, or to every node if to is nil.
+func (s *leaderUpdateSink) publishAllLocked(to []string) {
+	if s.data == nil {
+		return // we haven't received the data from LaunchDarkly yet
+	}
+	var allData []ldstoretypes.Collection
+	for _, kind := range ldstoreimpl.AllKinds() {
+		coll := ldstoretypes.Collection{Kind: kind}
+		for key, item := range s.data[kind] {
+			coll.Items = append(coll.Items, ldstoretypes.KeyedItemDescriptor{Key: key, Item: item})
+		}
+		allData = append(allData, coll)
+	}
+	m := makePutMessage(allData)
+	m.Sequence, m.To = s.sequence, to
+	s.owner.publish(m)
+	s.lastPublishAll = time.Now()
+	s.syncRequesters = nil
+}
+
+func (s *leaderUpdateSink) publishHeartbeat() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.owner.publish(message{Type: messageTypeHeartbeat, Sequence: s.sequence})
+}
+
+func (s *leaderUpdateSink) deactivate() {
+	s.lock.Lock()
+	s.active = false
+	s.lock.Unlock()
+}
@@ -0,0 +1,666 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	"github.com/launchdarkly/go-sdk-common/v3/ldvalue"
+	"github.com/launchdarkly/go-server-sdk-evaluation/v3/ldbuilders"
+	"github.com/launchdarkly/go-server-sdk/v7/interfaces"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoreimpl"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoretypes"
+	"github.com/launchdarkly/go-server-sdk/v7/testhelpers/ldtestdata"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testSDKKey            = "sdk-key"
+	testHeartbeatInterval = 10 * time.Millisecond
+	testLeaderTimeout     = 100 * time.Millisecond
+	testWaitTime          = time.Second
+)
+
+var errTestBackendDown = errors.New("backend is down")
+
+type testUpdateSink struct {
+	data      map[ldstoretypes.DataKind]map[string]ldstoretypes.ItemDescriptor
+	initCount int
+	statusCh  chan interfaces.DataSourceState
+	lock      sync.Mutex
+}
+
+type testNode struct {
+	id         string
+	sink       *testUpdateSink
+	dataSource subsystems.DataSource
+	readyCh    chan struct{}
+}
+
+// unreliableBackend simulates a node losing its connection to the backend.
+type unreliableBackend struct {
+	Backend
+	failing       atomic.Bool
+	subscriptions []Subscription
+	lock          sync.Mutex
+}
+
+func newTestUpdateSink() *testUpdateSink {
+	return &testUpdateSink{statusCh: make(chan interfaces.DataSourceState, 100)}
+}
+
+func (s *testUpdateSink) Init(allData []ldstoretypes.Collection) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.initCount++
+	s.data = make(map[ldstoretypes.DataKind]map[string]ldstoretypes.ItemDescriptor)
+	for _, coll := range allData {
+		s.data[coll.Kind] = make(map[string]ldstoretypes.ItemDescriptor)
+		for _, item := range coll.Items {
+			s.data[coll.Kind][item.Key] = item.Item
+		}
+	}
+	return true
+}
+
+func (s *testUpdateSink) Upsert(kind ldstoretypes.DataKind, key string, item ldstoretypes.ItemDescriptor) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.data == nil {
+		return false
+	}
+	if s.data[kind] == nil {
+		s.data[kind] = make(map[string]ldstoretypes.ItemDescriptor)
+	}
+	if existing, ok := s.data[kind][key]; !ok || existing.Version < item.Version {
+		s.data[kind][key] = item
+	}
+	return true
+}
+
+func (s *testUpdateSink) UpdateStatus(newState interfaces.DataSourceState, newError interfaces.DataSourceErrorInfo) {
+	s.statusCh <- newState
+}
+
+func (s *testUpdateSink) GetDataStoreStatusProvider() interfaces.DataStoreStatusProvider {
+	return nil
+}
+
+// getVersion returns the version of an item, or -1 if it does not exist, or 0 if the sink has no data.
+func (s *testUpdateSink) getVersion(kind ldstoretypes.DataKind, key string) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.data == nil {
+		return 0
+	}
+	if item, ok := s.data[kind][key]; ok {
+		return item.Version
+	}
+	return -1
+}
+
+func (s *testUpdateSink) getInitCount() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.initCount
+}
+
+func (s *testUpdateSink) requireVersion(t *testing.T, kind ldstoretypes.DataKind, key string, version int) {
+	require.Eventually(t, func() bool { return s.getVersion(kind, key) == version }, testWaitTime,
+		time.Millisecond, "timed out waiting for %s %q to have version %d", kind, key, version)
+}
+
+func (s *testUpdateSink) requireStatus(t *testing.T, state interfaces.DataSourceState) {
+	deadline := time.After(testWaitTime)
+	for {
+		select {
+		case received := <-s.statusCh:
+			if received == state {
+				return
+			}
+		case <-deadline:
+			require.Fail(t, "timed out waiting for data source status", "expected %s", state)
+		}
+	}
+}
+
+func (b *unreliableBackend) TryAcquireLease(ctx context.Context, name, nodeID string, ttl time.Duration) (bool, error) {
+	if b.failing.Load() {
+		return false, errTestBackendDown
+	}
+	return b.Backend.TryAcquireLease(ctx, name, nodeID, ttl)
+}
+
+func (b *unreliableBackend) Publish(ctx context.Context, channel string, message []byte) error {
+	if b.failing.Load() {
+		return errTestBackendDown
+	}
+	return b.Backend.Publish(ctx, channel, message)
+}
+
+func (b *unreliableBackend) Subscribe(ctx context.Context, channel string) (Subscription, error) {
+	if b.failing.Load() {
+		return nil, errTestBackendDown
+	}
+	sub, err := b.Backend.Subscribe(ctx, channel)
+	if err == nil {
+		b.lock.Lock()
+		b.subscriptions = append(b.subscriptions, sub)
+		b.lock.Unlock()
+	}
+	return sub, err
+}
+
+// disconnect makes the backend fail, and closes any existing subscriptions as if the connection was lost.
+func (b *unreliableBackend) disconnect() {
+	b.failing.Store(true)
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for _, sub := range b.subscriptions {
+		_ = sub.Close()
+	}
+	b.subscriptions = nil
+}
+
+func startTestNode(
+	t *testing.T,
+	backend Backend,
+	upstream subsystems.ComponentConfigurer[subsystems.DataSource],
+	nodeID string,
+) *testNode {
+	sink := newTestUpdateSink()
+	configurer := DataSource(upstream, DataSourceOptions{
+		Backend:           backend,
+		NodeID:            nodeID,
+		HeartbeatInterval: testHeartbeatInterval,
+		LeaderTimeout:     testLeaderTimeout,
+	})
+	dataSource, err := configurer.Build(subsystems.BasicClientContext{
+		SDKKey:               testSDKKey,
+		Logging:              subsystems.LoggingConfiguration{Loggers: ldlog.NewDisabledLoggers()},
+		DataSourceUpdateSink: sink,
+	})
+	require.NoError(t, err)
+	n := &testNode{id: nodeID, sink: sink, dataSource: dataSource, readyCh: make(chan struct{})}
+	dataSource.Start(n.readyCh)
+	t.Cleanup(func() { _ = dataSource.Close() })
+	return n
+}
+
+func (n *testNode) requireReady(t *testing.T) {
+	select {
+	case <-n.readyCh:
+	case <-time.After(testWaitTime):
+		require.Fail(t, "timed out waiting for data source to be ready", "node %s", n.id)
+	}
+}
+
+func requireLeader(t *testing.T, backend *InMemoryBackend, nodeID string) {
+	channel := EnvironmentChannel(testSDKKey, "")
+	require.Eventually(t, func() bool { return backend.GetLeaseHolder(channel) == nodeID }, testWaitTime,
+		time.Millisecond, "timed out waiting for %s to be the leader", nodeID)
+}
+
+// subscribeToMessages lets a test see the messages that nodes are sending, or act as a leader itself.
+func subscribeToMessages(t *testing.T, backend Backend) <-chan message {
+	sub, err := backend.Subscribe(context.Background(), EnvironmentChannel(testSDKKey, ""))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = sub.Close() })
+	ch := make(chan message, 100)
+	go func() {
+		for data := range sub.Messages() {
+			var m message
+			if json.Unmarshal(data, &m) == nil {
+				select {
+				case ch <- m:
+				default: // the test isn't reading any more messages
+				}
+			}
+		}
+	}()
+	return ch
+}
+
+func publishMessage(t *testing.T, backend Backend, m message) {
+	data, err := json.Marshal(m)
+	require.NoError(t, err)
+	require.NoError(t, backend.Publish(context.Background(), EnvironmentChannel(testSDKKey, ""), data))
+}
+
+func requireMessageOfType(t *testing.T, messagesCh <-chan message, messageType string) message {
+	deadline := time.After(testWaitTime)
+	for {
+		select {
+		case m := <-messagesCh:
+			if m.Type == messageType {
+				return m
+			}
+		case <-deadline:
+			require.Fail(t, "timed out waiting for message", "type %q", messageType)
+		}
+	}
+}
+
+func assertNoMessageOfType(t *testing.T, messagesCh <-chan message, messageType string) {
+	deadline := time.After(50 * time.Millisecond)
+	for {
+		select {
+		case m := <-messagesCh:
+			assert.NotEqual(t, messageType, m.Type)
+		case <-deadline:
+			return
+		}
+	}
+}
+
+func TestEnvironmentChannel(t *testing.T) {
+	assert.Len(t, EnvironmentChannel(testSDKKey, ""), 64)
+	assert.NotContains(t, EnvironmentChannel(testSDKKey, ""), testSDKKey)
+	assert.NotEqual(t, EnvironmentChannel(testSDKKey, ""), EnvironmentChannel("other-key", ""))
+	assert.Equal(t, EnvironmentChannel(testSDKKey, "")+"/filter1", EnvironmentChannel(testSDKKey, "filter1"))
+}
+
+func TestFirstNodeBecomesLeader(t *testing.T) {
+	backend := NewInMemoryBackend()
+	td := ldtestdata.DataSource()
+	td.Update(td.Flag("flag1"))
+
+	node := startTestNode(t, backend, td, "node1")
+
+	node.requireReady(t)
+	assert.True(t, node.dataSource.IsInitialized())
+	node.sink.requireStatus(t, interfaces.DataSourceStateValid)
+	assert.Equal(t, 1, node.sink.getVersion(ldstoreimpl.Features(), "flag1"))
+	requireLeader(t, backend, "node1")
+
+	td.Update(td.Flag("flag1"))
+	node.sink.requireVersion(t, ldstoreimpl.Features(), "flag1", 2)
+}
+
+func TestFollowerReceivesDataFromLeader(t *testing.T) {
+	backend := NewInMemoryBackend()
+	td := ldtestdata.DataSource()
+	td.Update(td.Flag("flag1"))
+
+	leader := startTestNode(t, backend, td, "node1")
+	leader.requireReady(t)
+	requireLeader(t, backend, "node1")
+
+	follower := startTestNode(t, backend, td, "node2")
+	follower.requireReady(t)
+	assert.True(t, follower.dataSource.IsInitialized())
+	follower.sink.requireStatus(t, interfaces.DataSourceStateValid)
+	assert.Equal(t, 1, follower.sink.getVersion(ldstoreimpl.Features(), "flag1"))
+
+	td.Update(td.Flag("flag1"))
+	td.Update(td.Flag("flag2"))
+	td.UsePreconfiguredSegment(ldbuilders.NewSegmentBuilder("segment1").Build())
+	follower.sink.requireVersion(t, ldstoreimpl.Features(), "flag1", 2)
+	follower.sink.requireVersion(t, ldstoreimpl.Features(), "flag2", 1)
+	follower.sink.requireVersion(t, ldstoreimpl.Segments(), "segment1", 1)
+
+	assert.Equal(t, "node1", backend.GetLeaseHolder(EnvironmentChannel(testSDKKey, "")))
+}
+
+func TestFollowerTakesOverWhenLeaderShutsDown(t *testing.T) {
+	backend := NewInMemoryBackend()
+	td := ldtestdata.DataSource()
+	td.Update(td.Flag("flag1"))
+
+	leader := startTestNode(t, backend, td, "node1")
+	requireLeader(t, backend, "node1")
+	follower := startTestNode(t, backend, td, "node2")
+	follower.requireReady(t)
+
+	require.NoError(t, leader.dataSource.Close())
+
+	// The leader resigns, so the follower doesn't need to wait for the leader timeout
+	requireLeader(t, backend, "node2")
+	td.Update(td.Flag("flag1"))
+	follower.sink.requireVersion(t, ldstoreimpl.Features(), "flag1", 2)
+}
+
+func TestFollowerTakesOverWhenLeaderHeartbeatsStop(t *testing.T) {
+	backend := NewInMemoryBackend()
+	leaderBackend := &unreliableBackend{Backend: backend}
+	td := ldtestdata.DataSource()
+	td.Update(td.Flag("flag1"))
+
+	leader := startTestNode(t, leaderBackend, td, "node1")
+	requireLeader(t, backend, "node1")
+	follower := startTestNode(t, backend, td, "node2")
+	follower.requireReady(t)
+
+	leaderBackend.failing.Store(true)
+
+	requireLeader(t, backend, "node2")
+	td.Update(td.Flag("flag1"))
+	follower.sink.requireVersion(t, ldstoreimpl.Features(), "flag1", 2)
+
+	// When the old leader can reach the backend again, it finds out that it has lost the lease, and
+	// becomes a follower of the new leader.
+	leaderBackend.failing.Store(false)
+	messagesCh := subscribeToMessages(t, backend)
+	requireMessageOfType(t, messagesCh, messageTypeSync)
+	td.Update(td.Flag("flag1"))
+	leader.sink.requireVersion(t, ldstoreimpl.Features(), "flag1", 3)
+	assert.Equal(t, "node2", backend.GetLeaseHolder(EnvironmentChannel(testSDKKey, "")))
+}
+
+func TestFollowerRequestsFullDataAfterMissedUpdate(t *testing.T) {
+	backend := NewInMemoryBackend()
+	channel := EnvironmentChannel(testSDKKey, "")
+	held, err := backend.TryAcquireLease(context.Background(), channel, "fake-leader", time.Hour)
+	require.NoError(t, err)
+	require.True(t, held)
+	messagesCh := subscribeToMessages(t, backend)
+
+	follower := startTestNode(t, backend, ldtestdata.DataSource(), "node1")
+	requireMessageOfType(t, messagesCh, messageTypeSync)
+
+	flag1 := ldbuilders.NewFlagBuilder("flag1").Version(1).Build()
+	put := makePutMessage([]ldstoretypes.Collection{
+		{Kind: ldstoreimpl.Features(), Items: []ldstoretypes.KeyedItemDescriptor{
+			{Key: "flag1", Item: ldstoretypes.ItemDescriptor{Version: 1, Item: &flag1}},
+		}},
+	})
+	put.NodeID, put.Sequence = "fake-leader", 1
+	publishMessage(t, backend, put)
+	follower.requireReady(t)
+	assert.Equal(t, 1, follower.sink.getVersion(ldstoreimpl.Features(), "flag1"))
+
+	flag1v2 := ldbuilders.NewFlagBuilder("flag1").Version(2).Build()
+	patch := makePatchMessage(ldstoreimpl.Features(), "flag1", ldstoretypes.ItemDescriptor{Version: 2, Item: &flag1v2})
+	patch.NodeID, patch.Sequence = "fake-leader", 2
+	publishMessage(t, backend, patch)
+	follower.sink.requireVersion(t, ldstoreimpl.Features(), "flag1", 2)
+	assertNoMessageOfType(t, messagesCh, messageTypeSync)
+
+	deleted := makePatchMessage(ldstoreimpl.Features(), "flag1", ldstoretypes.ItemDescriptor{Version: 4})
+	deleted.NodeID, deleted.Sequence = "fake-leader", 4 // there was no sequence number 3
+	publishMessage(t, backend, deleted)
+	follower.sink.requireVersion(t, ldstoreimpl.Features(), "flag1", 4)
+	requireMessageOfType(t, messagesCh, messageTypeSync)
+}
+
+func TestFollowerReportsInterruptionWhenLeaderHeartbeatsStop(t *testing.T) {
+	backend := NewInMemoryBackend()
+	channel := EnvironmentChannel(testSDKKey, "")
+	held, err := backend.TryAcquireLease(context.Background(), channel, "fake-leader", time.Hour)
+	require.NoError(t, err)
+	require.True(t, held)
+
+	messagesCh := subscribeToMessages(t, backend)
+
+	follower := startTestNode(t, backend, ldtestdata.DataSource(), "node1")
+	requireMessageOfType(t, messagesCh, messageTypeSync)
+	put := makePutMessage(nil)
+	put.NodeID, put.Sequence = "fake-leader", 1
+	publishMessage(t, backend, put)
+	follower.requireReady(t)
+	follower.sink.requireStatus(t, interfaces.DataSourceStateValid)
+
+	// The fake leader doesn't send any heartbeats, and still holds the lease so the follower can't take over
+	follower.sink.requireStatus(t, interfaces.DataSourceStateInterrupted)
+	assert.Equal(t, "fake-leader", backend.GetLeaseHolder(channel))
+
+	publishMessage(t, backend, message{Type: messageTypeHeartbeat, NodeID: "fake-leader", Sequence: 1})
+	follower.sink.requireStatus(t, interfaces.DataSourceStateValid)
+}
+
+func TestFollowerFallsBackToDirectConnectionWhenBackendIsLost(t *testing.T) {
+	for _, closeSubscription := range []bool{true, false} {
+		// The real Redis client keeps a subscription open while it tries to reconnect, so we can't count on
+		// the subscription being closed; either way, the follower stops hearing from the leader and can't
+		// reach the lease.
+		name := "subscription stays open"
+		if closeSubscription {
+			name = "subscription is closed"
+		}
+		t.Run(name, func(t *testing.T) {
+			backend := NewInMemoryBackend()
+			channel := EnvironmentChannel(testSDKKey, "")
+			held, err := backend.TryAcquireLease(context.Background(), channel, "fake-leader", time.Hour)
+			require.NoError(t, err)
+			require.True(t, held)
+			followerBackend := &unreliableBackend{Backend: backend}
+			td := ldtestdata.DataSource()
+			td.Update(td.Flag("flag1"))
+
+			messagesCh := subscribeToMessages(t, backend)
+			follower := startTestNode(t, followerBackend, td, "node1")
+			requireMessageOfType(t, messagesCh, messageTypeSync)
+			put := makePutMessage(nil)
+			put.NodeID, put.Sequence = "fake-leader", 1
+			publishMessage(t, backend, put)
+			follower.requireReady(t)
+			follower.sink.requireStatus(t, interfaces.DataSourceStateValid)
+
+			if closeSubscription {
+				followerBackend.disconnect()
+			} else {
+				followerBackend.failing.Store(true)
+			}
+			follower.sink.requireStatus(t, interfaces.DataSourceStateInterrupted)
+			follower.sink.requireVersion(t, ldstoreimpl.Features(), "flag1", 1)
+			td.Update(td.Flag("flag1"))
+			follower.sink.requireVersion(t, ldstoreimpl.Features(), "flag1", 2)
+			assert.Equal(t, "fake-leader", backend.GetLeaseHolder(channel))
+		})
+	}
+}
+
+func TestReplacedDataSourceDoesNotReleaseLeaseOnClose(t *testing.T) {
+	backend := NewInMemoryBackend()
+	channel := EnvironmentChannel(testSDKKey, "")
+	td := ldtestdata.DataSource()
+	td.Update(td.Flag("flag1"))
+	configurer := DataSource(td, DataSourceOptions{
+		Backend:           backend,
+		NodeID:            "node1",
+		HeartbeatInterval: testHeartbeatInterval,
+		LeaderTimeout:     testLeaderTimeout,
+	})
+	start := func() *testNode {
+		n := &testNode{id: "node1", sink: newTestUpdateSink(), readyCh: make(chan struct{})}
+		var err error
+		n.dataSource, err = configurer.Build(subsystems.BasicClientContext{
+			SDKKey:               testSDKKey,
+			Logging:              subsystems.LoggingConfiguration{Loggers: ldlog.NewDisabledLoggers()},
+			DataSourceUpdateSink: n.sink,
+		})
+		require.NoError(t, err)
+		n.dataSource.Start(n.readyCh)
+		t.Cleanup(func() { _ = n.dataSource.Close() })
+		return n
+	}
+
+	// This is what happens when Relay restarts an SDK client: a new data source for the same node starts,
+	// and then the old one is closed.
+	oldNode := start()
+	oldNode.requireReady(t)
+	requireLeader(t, backend, "node1")
+	newNode := start()
+	newNode.requireReady(t)
+	messagesCh := subscribeToMessages(t, backend)
+
+	require.NoError(t, oldNode.dataSource.Close())
+	assertNoMessageOfType(t, messagesCh, messageTypeResign)
+	assert.Equal(t, "node1", backend.GetLeaseHolder(channel))
+	td.Update(td.Flag("flag1"))
+	newNode.sink.requireVersion(t, ldstoreimpl.Features(), "flag1", 2)
+
+	// When the last one is closed, it does resign.
+	require.NoError(t, newNode.dataSource.Close())
+	requireMessageOfType(t, messagesCh, messageTypeResign)
+	assert.Equal(t, "", backend.GetLeaseHolder(channel))
+}
+
+func TestLeaderLimitsFullDataRequests(t *testing.T) {
+	backend := NewInMemoryBackend()
+	td := ldtestdata.DataSource()
+	td.Update(td.Flag("flag1"))
+
+	leader := startTestNode(t, backend, td, "node1")
+	leader.requireReady(t)
+	requireLeader(t, backend, "node1")
+	messagesCh := subscribeToMessages(t, backend)
+
+	for i := 0; i < 20; i++ {
+		publishMessage(t, backend, message{Type: messageTypeSync, NodeID: "node2"})
+	}
+	requireMessageOfType(t, messagesCh, messageTypePut)
+
+	puts := 1
+	deadline := time.After(testHeartbeatInterval * 5)
+	for done := false; !done; {
+		select {
+		case m := <-messagesCh:
+			if m.Type == messageTypePut {
+				puts++
+			}
+		case <-deadline:
+			done = true
+		}
+	}
+	assert.LessOrEqual(t, puts, 2, "the leader should answer requests within one heartbeat interval with one message")
+}
+
+func TestFollowerDoesNotReinitializeWhenAnotherNodeRequestsFullData(t *testing.T) {
+	backend := NewInMemoryBackend()
+	td := ldtestdata.DataSource()
+	td.Update(td.Flag("flag1"))
+
+	leader := startTestNode(t, backend, td, "node1")
+	leader.requireReady(t)
+	requireLeader(t, backend, "node1")
+	follower := startTestNode(t, backend, td, "node2")
+	follower.requireReady(t)
+	messagesCh := subscribeToMessages(t, backend)
+	initCount := follower.sink.getInitCount()
+
+	publishMessage(t, backend, message{Type: messageTypeSync, NodeID: "node3"})
+	put := requireMessageOfType(t, messagesCh, messageTypePut)
+	assert.Contains(t, put.To, "node3")
+
+	// Make sure the follower has processed the put, by waiting for an update that was sent after it
+	td.Update(td.Flag("flag1"))
+	follower.sink.requireVersion(t, ldstoreimpl.Features(), "flag1", 2)
+	assert.Equal(t, initCount, follower.sink.getInitCount())
+	assertNoMessageOfType(t, messagesCh, messageTypeSync)
+}
+
+func TestFollowerDoesNotReinitializeForFullDataItAlreadyHas(t *testing.T) {
+	backend := NewInMemoryBackend()
+	channel := EnvironmentChannel(testSDKKey, "")
+	held, err := backend.TryAcquireLease(context.Background(), channel, "fake-leader", time.Hour)
+	require.NoError(t, err)
+	require.True(t, held)
+	messagesCh := subscribeToMessages(t, backend)
+
+	follower := startTestNode(t, backend, ldtestdata.DataSource(), "node1")
+	requireMessageOfType(t, messagesCh, messageTypeSync)
+
+	flag1 := ldbuilders.NewFlagBuilder("flag1").Version(1).Build()
+	put := makePutMessage([]ldstoretypes.Collection{
+		{Kind: ldstoreimpl.Features(), Items: []ldstoretypes.KeyedItemDescriptor{
+			{Key: "flag1", Item: ldstoretypes.ItemDescriptor{Version: 1, Item: &flag1}},
+		}},
+	})
+	put.NodeID, put.Sequence = "fake-leader", 1
+	publishMessage(t, backend, put)
+	follower.requireReady(t)
+	require.Equal(t, 1, follower.sink.getInitCount())
+
+	// An older leader that doesn't address its messages sends the same data to every node
+	publishMessage(t, backend, put)
+	flag2 := ldbuilders.NewFlagBuilder("flag2").Version(1).Build()
+	patch := makePatchMessage(ldstoreimpl.Features(), "flag2", ldstoretypes.ItemDescriptor{Version: 1, Item: &flag2})
+	patch.NodeID, patch.Sequence = "fake-leader", 2
+	publishMessage(t, backend, patch)
+	follower.sink.requireVersion(t, ldstoreimpl.Features(), "flag2", 1)
+	assert.Equal(t, 1, follower.sink.getInitCount())
+
+	// New data from LaunchDarkly has a new sequence number, so the follower does use it
+	put.Sequence = 3
+	publishMessage(t, backend, put)
+	require.Eventually(t, func() bool { return follower.sink.getInitCount() == 2 }, testWaitTime, time.Millisecond)
+	assertNoMessageOfType(t, messagesCh, messageTypeSync)
+}
+
+func TestFollowerBacksOffFullDataRequests(t *testing.T) {
+	backend := NewInMemoryBackend()
+	channel := EnvironmentChannel(testSDKKey, "")
+	held, err := backend.TryAcquireLease(context.Background(), channel, "fake-leader", time.Hour)
+	require.NoError(t, err)
+	require.True(t, held)
+	messagesCh := subscribeToMessages(t, backend)
+
+	_ = startTestNode(t, backend, ldtestdata.DataSource(), "node1")
+	requireMessageOfType(t, messagesCh, messageTypeSync)
+
+	// The fake leader keeps sending heartbeats, so the follower doesn't time out, but it never sends the
+	// data, so the follower keeps asking for it.
+	syncs := 1
+	ticker := time.NewTicker(testHeartbeatInterval)
+	defer ticker.Stop()
+	deadline := time.After(testLeaderTimeout * 3)
+	for done := false; !done; {
+		select {
+		case <-ticker.C:
+			publishMessage(t, backend, message{Type: messageTypeHeartbeat, NodeID: "fake-leader"})
+		case m := <-messagesCh:
+			if m.Type == messageTypeSync {
+				syncs++
+			}
+		case <-deadline:
+			done = true
+		}
+	}
+	assert.Greater(t, syncs, 1)
+	assert.Less(t, syncs, 10, "the follower should not ask for the data on every heartbeat")
+}
+
+func TestMessagesRoundTrip(t *testing.T) {
+	flag := ldbuilders.NewFlagBuilder("flag1").Version(1).Variations(ldvalue.Bool(true)).Build()
+	segment := ldbuilders.NewSegmentBuilder("segment1").Version(2).Build()
+	allData := []ldstoretypes.Collection{
+		{Kind: ldstoreimpl.Features(), Items: []ldstoretypes.KeyedItemDescriptor{
+			{Key: "flag1", Item: ldstoretypes.ItemDescriptor{Version: 1, Item: &flag}},
+			{Key: "flag2", Item: ldstoretypes.ItemDescriptor{Version: 3}},
+		}},
+		{Kind: ldstoreimpl.Segments(), Items: []ldstoretypes.KeyedItemDescriptor{
+			{Key: "segment1", Item: ldstoretypes.ItemDescriptor{Version: 2, Item: &segment}},
+		}},
+	}
+
+	data, err := json.Marshal(makePutMessage(allData))
+	require.NoError(t, err)
+	var put message
+	require.NoError(t, json.Unmarshal(data, &put))
+	received, err := put.getAllData()
+	require.NoError(t, err)
+	require.Len(t, received, 2)
+	assert.ElementsMatch(t, allData[0].Items, received[0].Items)
+	assert.ElementsMatch(t, allData[1].Items, received[1].Items)
+
+	data, err = json.Marshal(makePatchMessage(ldstoreimpl.Segments(), "segment1", allData[1].Items[0].Item))
+	require.NoError(t, err)
+	var patch message
+	require.NoError(t, json.Unmarshal(data, &patch))
+	kind, item, err := patch.getItem()
+	require.NoError(t, err)
+	assert.Equal(t, ldstoreimpl.Segments(), kind)
+	assert.Equal(t, allData[1].Items[0].Item, item)
+
+	_, _, err = message{Type: messageTypePatch, Kind: "unknown"}.getItem()
+	assert.Error(t, err)
+}
@@ -0,0 +1,175 @@
+package cluster
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v8/config"
+	"github.com/launchdarkly/ld-relay/v8/internal/sdks"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+)
+
+const (
+	logMsgElectorGainedLease = "Node %s now holds the %q lease"
+	logMsgElectorLostLease   = "Node %s no longer holds the %q lease"
+	logMsgElectorError       = "Unable to acquire or renew the %q lease: %s"
+	logMsgElectorRecovered   = "Lease operations for %q are succeeding again"
+)
+
+// DataStoreLeaseClient is a connection to the database that holds the persistent data stores, which
+// all environments share for the leases that decide which node writes to their data store.
+type DataStoreLeaseClient interface {
+	// LeaseStore returns a LeaseStore that keeps an environment's leases in the same place as its data.
+	// It uses the client's connection, so closing it does not close the connection.
+	LeaseStore(envConfig config.EnvConfig) LeaseStore
+
+	// Close releases the connection.
+	Close() error
+}
+
+// NewDataStoreLeaseClient creates a DataStoreLeaseClient for the database that is configured for
+// persistent data stores, or returns nil if leader election is not enabled for that database.
+func NewDataStoreLeaseClient(allConfig config.Config) (DataStoreLeaseClient, error) {
+	if !sdks.IsDataStoreLeaderElectionEnabled(allConfig) {
+		return nil, nil
+	}
+	switch {
+	case allConfig.Redis.URL.IsDefined():
+		return NewRedisLeaseClient(allConfig.Redis)
+	case allConfig.Consul.Host != "":
+		return NewConsulLeaseClient(allConfig.Consul)
+	default:
+		return NewDynamoDBLeaseClient(allConfig.DynamoDB)
+	}
+}
+
+// Elector keeps trying to acquire a lease for this node, and renews it for as long as it holds it.
+//
+// If a renewal fails because the lease store is unavailable, the Elector assumes that the node still
+// holds the lease for as long as it is sure that the lease has not expired, since no other node can
+// acquire it before then.
+type Elector struct {
+	store         LeaseStore
+	name          string
+	nodeID        string
+	renewInterval time.Duration
+	ttl           time.Duration
+	onChange      func(isLeader bool)
+	loggers       ldlog.Loggers
+	isLeader      bool
+	leaderUntil   time.Time
+	failing       bool
+	lock          sync.Mutex
+	closeCh       chan struct{}
+	closeOnce     sync.Once
+	doneCh        chan struct{}
+}
+
+// NewElector creates an Elector and starts trying to acquire the lease. Each time the node acquires or
+// loses the lease, the Elector calls onChange on its own goroutine.
+func NewElector(
+	store LeaseStore,
+	name string,
+	nodeID string,
+	renewInterval time.Duration,
+	ttl time.Duration,
+	onChange func(isLeader bool),
+	loggers ldlog.Loggers,
+) *Elector {
+	e := &Elector{
+		store:         store,
+		name:          name,
+		nodeID:        nodeID,
+		renewInterval: renewInterval,
+		ttl:           ttl,
+		onChange:      onChange,
+		loggers:       loggers,
+		closeCh:       make(chan struct{}),
+		doneCh:        make(chan struct{}),
+	}
+	go e.run()
+	return e
+}
+
+// IsLeader returns true if this node currently holds the lease.
+func (e *Elector) IsLeader() bool {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.isLeader
+}
+
+// Close stops trying to acquire the lease, and releases it if this node holds it. It does not call
+// onChange.
+func (e *Elector) Close() {
+	e.closeOnce.Do(func() {
+		close(e.closeCh)
+		<-e.doneCh
+		e.lock.Lock()
+		wasLeader := e.isLeader
+		e.isLeader = false
+		e.lock.Unlock()
+		if wasLeader {
+			ctx, cancel := context.WithTimeout(context.Background(), backendOperationTimeout)
+			defer cancel()
+			if err := e.store.ReleaseLease(ctx, e.name, e.nodeID); err != nil {
+				e.loggers.Warnf(logMsgElectorError, e.name, err)
+			}
+		}
+	})
+}
+
+func (e *Elector) run() {
+	defer close(e.doneCh)
+	ticker := time.NewTicker(e.renewInterval)
+	defer ticker.Stop()
+	for {
+		e.tryAcquire()
+		select {
+		case <-e.closeCh:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (e *Elector) tryAcquire() {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), backendOperationTimeout)
+	held, err := e.store.TryAcquireLease(ctx, e.name, e.nodeID, e.ttl)
+	cancel()
+
+	e.lock.Lock()
+	wasLeader, wasFailing := e.isLeader, e.failing
+	e.failing = err != nil
+	switch {
+	case err == nil:
+		e.isLeader = held
+		if held {
+			e.leaderUntil = start.Add(e.ttl)
+		}
+	case e.isLeader && time.Now().Add(e.renewInterval).After(e.leaderUntil):
+		// We won't try again until the next interval, and the lease might have expired by then
+		e.isLeader = false
+	}
+	isLeader := e.isLeader
+	e.lock.Unlock()
+
+	switch {
+	case err != nil && !wasFailing:
+		e.loggers.Warnf(logMsgElectorError, e.name, err)
+	case err != nil:
+		e.loggers.Debugf(logMsgElectorError, e.name, err)
+	case wasFailing:
+		e.loggers.Infof(logMsgElectorRecovered, e.name)
+	}
+	if isLeader != wasLeader {
+		if isLeader {
+			e.loggers.Infof(logMsgElectorGainedLease, e.nodeID, e.name)
+		} else {
+			e.loggers.Infof(logMsgElectorLostLease, e.nodeID, e.name)
+		}
+		e.onChange(isLeader)
+	}
+}
@@ -0,0 +1,118 @@
+package cluster
+
+import (
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v8/config"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	helpers "github.com/launchdarkly/go-test-helpers/v3"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testElectorRenewInterval = 10 * time.Millisecond
+	testElectorTTL           = 100 * time.Millisecond
+)
+
+func startTestElector(t *testing.T, store LeaseStore, nodeID string) (*Elector, <-chan bool) {
+	changesCh := make(chan bool, 10)
+	e := NewElector(store, "test-lease", nodeID, testElectorRenewInterval, testElectorTTL,
+		func(isLeader bool) { changesCh <- isLeader }, ldlog.NewDisabledLoggers())
+	t.Cleanup(e.Close)
+	return e, changesCh
+}
+
+func TestElectorAcquiresLeaseIfAvailable(t *testing.T) {
+	backend := NewInMemoryBackend()
+	e1, changes1 := startTestElector(t, backend, "node1")
+	assert.True(t, helpers.RequireValue(t, changes1, time.Second))
+	assert.True(t, e1.IsLeader())
+	assert.Equal(t, "node1", backend.GetLeaseHolder("test-lease"))
+
+	e2, changes2 := startTestElector(t, backend, "node2")
+	helpers.AssertNoMoreValues(t, changes2, testElectorTTL*2)
+	assert.False(t, e2.IsLeader())
+	assert.True(t, e1.IsLeader())
+}
+
+func TestElectorReleasesLeaseWhenClosed(t *testing.T) {
+	backend := NewInMemoryBackend()
+	e1, changes1 := startTestElector(t, backend, "node1")
+	require.True(t, helpers.RequireValue(t, changes1, time.Second))
+	e2, changes2 := startTestElector(t, backend, "node2")
+
+	e1.Close()
+	helpers.AssertNoMoreValues(t, changes1, time.Millisecond*50, "onChange should not be called after Close")
+	assert.False(t, e1.IsLeader())
+
+	// The lease was released, so node2 can take over without waiting for the TTL
+	assert.True(t, helpers.RequireValue(t, changes2, testElectorTTL/2))
+	assert.True(t, e2.IsLeader())
+}
+
+func TestElectorKeepsLeaseDuringBriefOutageButNotAfterTTL(t *testing.T) {
+	backend := &unreliableBackend{Backend: NewInMemoryBackend()}
+	e, changes := startTestElector(t, backend, "node1")
+	require.True(t, helpers.RequireValue(t, changes, time.Second))
+
+	backend.failing.Store(true)
+	helpers.AssertNoMoreValues(t, changes, testElectorTTL/2)
+	assert.True(t, e.IsLeader())
+
+	assert.False(t, helpers.RequireValue(t, changes, testElectorTTL))
+	assert.False(t, e.IsLeader())
+
+	backend.failing.Store(false)
+	assert.True(t, helpers.RequireValue(t, changes, time.Second))
+}
+
+func TestNewDataStoreLeaseClient(t *testing.T) {
+	t.Run("returns nil if leader election is not enabled", func(t *testing.T) {
+		var c config.Config
+		c.Consul.Host = "consulhost"
+		client, err := NewDataStoreLeaseClient(c)
+		require.NoError(t, err)
+		assert.Nil(t, client)
+	})
+
+	t.Run("Consul", func(t *testing.T) {
+		var c config.Config
+		c.Consul.Host = "consulhost"
+		c.Consul.LeaderElection = true
+		client, err := NewDataStoreLeaseClient(c)
+		require.NoError(t, err)
+		defer client.Close()
+		require.IsType(t, &consulLeaseClient{}, client)
+
+		store1 := client.LeaseStore(config.EnvConfig{Prefix: "abc"})
+		store2 := client.LeaseStore(config.EnvConfig{Prefix: "def"})
+		require.IsType(t, &consulLeaseStore{}, store1)
+		assert.Equal(t, "abc/relay/leases/", store1.(*consulLeaseStore).keyPrefix)
+		assert.Equal(t, "def/relay/leases/", store2.(*consulLeaseStore).keyPrefix)
+		assert.Same(t, store1.(*consulLeaseStore).client, store2.(*consulLeaseStore).client)
+	})
+
+	t.Run("DynamoDB", func(t *testing.T) {
+		var c config.Config
+		c.DynamoDB.Enabled = true
+		c.DynamoDB.TableName = "table"
+		c.DynamoDB.LeaderElection = true
+		client, err := NewDataStoreLeaseClient(c)
+		require.NoError(t, err)
+		defer client.Close()
+		require.IsType(t, &dynamoDBLeaseClient{}, client)
+
+		store1 := client.LeaseStore(config.EnvConfig{Prefix: "abc"})
+		store2 := client.LeaseStore(config.EnvConfig{Prefix: "def", TableName: "othertable"})
+		require.IsType(t, &dynamoDBLeaseStore{}, store1)
+		assert.Equal(t, "table", store1.(*dynamoDBLeaseStore).table)
+		assert.Equal(t, "abc:relay_leases", store1.(*dynamoDBLeaseStore).namespace)
+		assert.Equal(t, "othertable", store2.(*dynamoDBLeaseStore).table)
+		assert.Equal(t, "def:relay_leases", store2.(*dynamoDBLeaseStore).namespace)
+		assert.Same(t, store1.(*dynamoDBLeaseStore).client, store2.(*dynamoDBLeaseStore).client)
+	})
+}
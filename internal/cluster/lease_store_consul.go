@@ -0,0 +1,104 @@
+package cluster
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v8/config"
+
+	ldconsul "github.com/launchdarkly/go-server-sdk-consul/v3"
+
+	consul "github.com/hashicorp/consul/api"
+)
+
+type consulLeaseStore struct {
+	client    *consul.Client
+	keyPrefix string
+	sessionID string
+	lock      sync.Mutex
+}
+
+type consulLeaseClient struct {
+	client *consul.Client
+}
+
+// NewConsulLeaseClient creates a DataStoreLeaseClient whose lease stores use Consul sessions. Each lease
+// is a key that is locked by a session belonging to the node that holds it; the session has the lease's
+// TTL, and renewing the lease renews the session. If the session expires, Consul releases the lock.
+func NewConsulLeaseClient(dbConfig config.ConsulConfig) (DataStoreLeaseClient, error) {
+	consulConfig := consul.DefaultConfig()
+	consulConfig.Address = dbConfig.Host
+	if dbConfig.Token != "" {
+		consulConfig.Token = dbConfig.Token
+	} else if dbConfig.TokenFile != "" {
+		consulConfig.TokenFile = dbConfig.TokenFile
+	}
+	client, err := consul.NewClient(consulConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &consulLeaseClient{client: client}, nil
+}
+
+// Each environment's lease store has its own session, since the environments' leases are independent.
+func (c *consulLeaseClient) LeaseStore(envConfig config.EnvConfig) LeaseStore {
+	prefix := envConfig.Prefix
+	if prefix == "" {
+		prefix = ldconsul.DefaultPrefix
+	}
+	return &consulLeaseStore{client: c.client, keyPrefix: prefix + "/relay/leases/"}
+}
+
+func (c *consulLeaseClient) Close() error {
+	return nil
+}
+
+func (c *consulLeaseStore) TryAcquireLease(ctx context.Context, name, nodeID string, ttl time.Duration) (bool, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	options := (&consul.WriteOptions{}).WithContext(ctx)
+	if c.sessionID != "" {
+		entry, _, err := c.client.Session().Renew(c.sessionID, options)
+		if err != nil {
+			return false, err
+		}
+		if entry == nil { // the session has expired, so Consul has released any lock that it held
+			c.sessionID = ""
+		}
+	}
+	if c.sessionID == "" {
+		id, _, err := c.client.Session().CreateNoChecks(&consul.SessionEntry{
+			Name:     "ld-relay " + nodeID,
+			TTL:      ttl.String(),
+			Behavior: consul.SessionBehaviorDelete,
+		}, options)
+		if err != nil {
+			return false, err
+		}
+		c.sessionID = id
+	}
+	// Acquiring a lock that this session already holds succeeds, so this also works for renewals.
+	acquired, _, err := c.client.KV().Acquire(&consul.KVPair{
+		Key:     c.keyPrefix + name,
+		Value:   []byte(nodeID),
+		Session: c.sessionID,
+	}, options)
+	return acquired, err
+}
+
+func (c *consulLeaseStore) ReleaseLease(ctx context.Context, name, nodeID string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.sessionID == "" {
+		return nil
+	}
+	// Destroying the session deletes the key if the session holds it, because of SessionBehaviorDelete.
+	_, err := c.client.Session().Destroy(c.sessionID, (&consul.WriteOptions{}).WithContext(ctx))
+	c.sessionID = ""
+	return err
+}
+
+func (c *consulLeaseStore) Close() error {
+	return nil
+}
@@ -0,0 +1,127 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v8/config"
+	"github.com/launchdarkly/ld-relay/v8/internal/sdks"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	// These are the key attributes that the SDK's DynamoDB data store requires the table to have.
+	dynamoDBPartitionKey = "namespace"
+	dynamoDBSortKey      = "key"
+
+	dynamoDBLeaseNamespace  = "relay_leases"
+	dynamoDBLeaseHolderAttr = "holder"
+	dynamoDBLeaseExpiryAttr = "expiresAt"
+)
+
+type dynamoDBLeaseStore struct {
+	client    *dynamodb.Client
+	table     string
+	namespace string
+}
+
+type dynamoDBLeaseClient struct {
+	client   *dynamodb.Client
+	dbConfig config.DynamoDBConfig
+}
+
+// NewDynamoDBLeaseClient creates a DataStoreLeaseClient whose lease stores use conditional writes to items
+// in the DynamoDB table that holds an environment's data. Each lease is an item whose expiration time is
+// set by the node that holds it, so the nodes' clocks should be reasonably well synchronized.
+func NewDynamoDBLeaseClient(dbConfig config.DynamoDBConfig) (DataStoreLeaseClient, error) {
+	endpoint, _, _ := sdks.GetDynamoDBBasicProperties(dbConfig, config.EnvConfig{})
+	awsConfig, err := awsconfig.LoadDefaultConfig(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	var optFns []func(*dynamodb.Options)
+	if endpoint != nil {
+		optFns = append(optFns, func(o *dynamodb.Options) {
+			o.BaseEndpoint = endpoint
+		})
+	}
+	return &dynamoDBLeaseClient{client: dynamodb.NewFromConfig(awsConfig, optFns...), dbConfig: dbConfig}, nil
+}
+
+func (c *dynamoDBLeaseClient) LeaseStore(envConfig config.EnvConfig) LeaseStore {
+	_, table, prefix := sdks.GetDynamoDBBasicProperties(c.dbConfig, envConfig)
+	namespace := dynamoDBLeaseNamespace
+	if prefix != "" {
+		namespace = prefix + ":" + namespace
+	}
+	return &dynamoDBLeaseStore{client: c.client, table: table, namespace: namespace}
+}
+
+func (c *dynamoDBLeaseClient) Close() error {
+	return nil
+}
+
+func (d *dynamoDBLeaseStore) TryAcquireLease(ctx context.Context, name, nodeID string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	_, err := d.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(d.table),
+		Item: map[string]types.AttributeValue{
+			dynamoDBPartitionKey:    &types.AttributeValueMemberS{Value: d.namespace},
+			dynamoDBSortKey:         &types.AttributeValueMemberS{Value: name},
+			dynamoDBLeaseHolderAttr: &types.AttributeValueMemberS{Value: nodeID},
+			dynamoDBLeaseExpiryAttr: dynamoDBTimeValue(now.Add(ttl)),
+		},
+		ConditionExpression: aws.String("attribute_not_exists(#namespace) OR #holder = :holder OR #expiresAt < :now"),
+		ExpressionAttributeNames: map[string]string{
+			"#namespace": dynamoDBPartitionKey,
+			"#holder":    dynamoDBLeaseHolderAttr,
+			"#expiresAt": dynamoDBLeaseExpiryAttr,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":holder": &types.AttributeValueMemberS{Value: nodeID},
+			":now":    dynamoDBTimeValue(now),
+		},
+	})
+	if err != nil {
+		var conditionFailed *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionFailed) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (d *dynamoDBLeaseStore) ReleaseLease(ctx context.Context, name, nodeID string) error {
+	_, err := d.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(d.table),
+		Key: map[string]types.AttributeValue{
+			dynamoDBPartitionKey: &types.AttributeValueMemberS{Value: d.namespace},
+			dynamoDBSortKey:      &types.AttributeValueMemberS{Value: name},
+		},
+		ConditionExpression:      aws.String("#holder = :holder"),
+		ExpressionAttributeNames: map[string]string{"#holder": dynamoDBLeaseHolderAttr},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":holder": &types.AttributeValueMemberS{Value: nodeID},
+		},
+	})
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		return nil // some other node holds the lease now, so there is nothing to release
+	}
+	return err
+}
+
+func (d *dynamoDBLeaseStore) Close() error {
+	return nil
+}
+
+func dynamoDBTimeValue(t time.Time) types.AttributeValue {
+	return &types.AttributeValueMemberN{Value: strconv.FormatInt(t.UnixMilli(), 10)}
+}
@@ -0,0 +1,101 @@
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoreimpl"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoretypes"
+)
+
+// These are the types of messages that nodes send on an environment's channel.
+const (
+	// A put message contains the full data set. The leader sends it to every node whenever it receives a
+	// full data set from LaunchDarkly; in response to a sync message, it sends it only to the nodes that
+	// asked for it.
+	messageTypePut = "put"
+
+	// A patch message contains a single new or deleted item.
+	messageTypePatch = "patch"
+
+	// The leader sends a heartbeat message at regular intervals, so followers know it is still active.
+	messageTypeHeartbeat = "heartbeat"
+
+	// A follower sends a sync message to ask the leader for the full data set, when it starts up or if it
+	// has not received any data yet.
+	messageTypeSync = "sync"
+
+	// The leader sends a resign message when it shuts down, so followers can elect a new leader without
+	// waiting for the leader timeout.
+	messageTypeResign = "resign"
+)
+
+type message struct {
+	Type     string                                `json:"type"`
+	NodeID   string                                `json:"node"`
+	Sequence uint64                                `json:"seq,omitempty"`
+	Data     map[string]map[string]json.RawMessage `json:"data,omitempty"`
+	Kind     string                                `json:"kind,omitempty"`
+	Key      string                                `json:"key,omitempty"`
+	Item     json.RawMessage                       `json:"item,omitempty"`
+	To       []string                              `json:"to,omitempty"`
+}
+
+func makePutMessage(allData []ldstoretypes.Collection) message {
+	m := message{Type: messageTypePut, Data: make(map[string]map[string]json.RawMessage)}
+	for _, coll := range allData {
+		items := make(map[string]json.RawMessage, len(coll.Items))
+		for _, keyedItem := range coll.Items {
+			items[keyedItem.Key] = coll.Kind.Serialize(keyedItem.Item)
+		}
+		m.Data[coll.Kind.GetName()] = items
+	}
+	return m
+}
+
+func makePatchMessage(kind ldstoretypes.DataKind, key string, item ldstoretypes.ItemDescriptor) message {
+	return message{Type: messageTypePatch, Kind: kind.GetName(), Key: key, Item: kind.Serialize(item)}
+}
+
+// isFor returns true if the message was sent to every node, or to this one.
+func (m message) isFor(nodeID string) bool {
+	return len(m.To) == 0 || containsNode(m.To, nodeID)
+}
+
+func containsNode(nodeIDs []string, nodeID string) bool {
+	for _, id := range nodeIDs {
+		if id == nodeID {
+			return true
+		}
+	}
+	return false
+}
+
+func (m message) getAllData() ([]ldstoretypes.Collection, error) {
+	var allData []ldstoretypes.Collection
+	for _, kind := range ldstoreimpl.AllKinds() {
+		coll := ldstoretypes.Collection{Kind: kind}
+		for key, data := range m.Data[kind.GetName()] {
+			item, err := kind.Deserialize(data)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s item %q: %w", kind.GetName(), key, err)
+			}
+			coll.Items = append(coll.Items, ldstoretypes.KeyedItemDescriptor{Key: key, Item: item})
+		}
+		allData = append(allData, coll)
+	}
+	return allData, nil
+}
+
+func (m message) getItem() (ldstoretypes.DataKind, ldstoretypes.ItemDescriptor, error) {
+	for _, kind := range ldstoreimpl.AllKinds() {
+		if kind.GetName() == m.Kind {
+			item, err := kind.Deserialize(m.Item)
+			if err != nil {
+				return nil, item, fmt.Errorf("invalid %s item %q: %w", m.Kind, m.Key, err)
+			}
+			return kind, item, nil
+		}
+	}
+	return nil, ldstoretypes.ItemDescriptor{}, fmt.Errorf("unknown data kind %q", m.Kind)
+}
@@ -0,0 +1,4 @@
+// Package cluster implements Relay's optional cluster mode, in which Relay instances that share a
+// Redis server elect one instance per environment to receive data from LaunchDarkly, and that instance
+// passes the data along to the others.
+package cluster
@@ -0,0 +1,112 @@
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+)
+
+// Encoding is a value of the Content-Encoding header.
+type Encoding string
+
+const (
+	// Identity means that the content is not compressed.
+	Identity Encoding = ""
+
+	// Gzip is gzip compression.
+	Gzip Encoding = "gzip"
+
+	// Brotli is Brotli compression.
+	Brotli Encoding = "br"
+)
+
+// MinSize is the smallest response body that we will bother compressing. Below this, the framing
+// overhead of the compression format can make the response bigger rather than smaller.
+const MinSize = 1024
+
+var (
+	gzipWriters   = sync.Pool{New: func() interface{} { return gzip.NewWriter(nil) }}   //nolint:gochecknoglobals
+	brotliWriters = sync.Pool{New: func() interface{} { return brotli.NewWriter(nil) }} //nolint:gochecknoglobals
+)
+
+// Negotiate chooses an encoding based on the value of an Accept-Encoding request header. The
+// supported encodings are listed in order of preference; if the client gives more than one of them
+// the same quality value, the first one wins. If the client accepts none of them, it returns Identity.
+func Negotiate(acceptEncoding string, supported ...Encoding) Encoding {
+	if acceptEncoding == "" {
+		return Identity
+	}
+	best, bestQ := Identity, 0.0
+	for _, enc := range supported {
+		if q := qualityOf(acceptEncoding, enc); q > bestQ {
+			best, bestQ = enc, q
+		}
+	}
+	return best
+}
+
+// returns the quality value the client gave to the encoding, which is zero if it was not listed
+func qualityOf(acceptEncoding string, enc Encoding) float64 {
+	wildcard := 0.0
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case string(enc):
+			return q
+		case "*":
+			wildcard = q
+		}
+	}
+	return wildcard
+}
+
+// Compress returns the data compressed with the specified encoding. For Identity, it returns the
+// data unchanged.
+func Compress(enc Encoding, data []byte) []byte {
+	var buf bytes.Buffer
+	switch enc {
+	case Gzip:
+		w := gzipWriters.Get().(*gzip.Writer)
+		w.Reset(&buf)
+		_, _ = w.Write(data) // writing to a bytes.Buffer can't fail
+		_ = w.Close()
+		gzipWriters.Put(w)
+	case Brotli:
+		w := brotliWriters.Get().(*brotli.Writer)
+		w.Reset(&buf)
+		_, _ = w.Write(data)
+		_ = w.Close()
+		brotliWriters.Put(w)
+	default:
+		return data
+	}
+	return buf.Bytes()
+}
+
+// WriteResponse writes a successful response body, compressing it if the request's Accept-Encoding
+// header allows and the body is big enough to be worth it. The caller should already have set any
+// other response headers.
+func WriteResponse(w http.ResponseWriter, req *http.Request, body []byte) {
+	w.Header().Add("Vary", "Accept-Encoding")
+	if len(body) >= MinSize {
+		if enc := Negotiate(req.Header.Get("Accept-Encoding"), Brotli, Gzip); enc != Identity {
+			w.Header().Set("Content-Encoding", string(enc))
+			body = Compress(enc, body)
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body)
+}
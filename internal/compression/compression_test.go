@@ -0,0 +1,101 @@
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiate(t *testing.T) {
+	for _, p := range []struct {
+		header   string
+		expected Encoding
+	}{
+		{"", Identity},
+		{"identity", Identity},
+		{"gzip", Gzip},
+		{"br", Brotli},
+		{"gzip, deflate, br", Brotli},
+		{"GZIP", Gzip},
+		{"gzip;q=1.0, br;q=0.5", Gzip},
+		{"br;q=0", Identity},
+		{"br;q=0, gzip", Gzip},
+		{"*", Brotli},
+		{"*;q=0.5, gzip", Gzip},
+		{"gzip;q=nonsense", Identity},
+	} {
+		t.Run(p.header, func(t *testing.T) {
+			assert.Equal(t, p.expected, Negotiate(p.header, Brotli, Gzip))
+		})
+	}
+}
+
+func TestCompress(t *testing.T) {
+	data := []byte(strings.Repeat("abcdefg", 1000))
+
+	t.Run("gzip", func(t *testing.T) {
+		r, err := gzip.NewReader(bytes.NewReader(Compress(Gzip, data)))
+		require.NoError(t, err)
+		decoded, err := io.ReadAll(r)
+		require.NoError(t, err)
+		assert.Equal(t, data, decoded)
+	})
+
+	t.Run("brotli", func(t *testing.T) {
+		decoded, err := io.ReadAll(brotli.NewReader(bytes.NewReader(Compress(Brotli, data))))
+		require.NoError(t, err)
+		assert.Equal(t, data, decoded)
+	})
+
+	t.Run("identity", func(t *testing.T) {
+		assert.Equal(t, data, Compress(Identity, data))
+	})
+}
+
+func TestWriteResponse(t *testing.T) {
+	bigBody := []byte(strings.Repeat("x", MinSize))
+
+	t.Run("compresses if client accepts it", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		WriteResponse(w, req, bigBody)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+		assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+		r, err := gzip.NewReader(w.Body)
+		require.NoError(t, err)
+		decoded, err := io.ReadAll(r)
+		require.NoError(t, err)
+		assert.Equal(t, bigBody, decoded)
+	})
+
+	t.Run("does not compress if client does not accept it", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "", nil)
+		w := httptest.NewRecorder()
+		WriteResponse(w, req, bigBody)
+
+		assert.Equal(t, "", w.Header().Get("Content-Encoding"))
+		assert.Equal(t, bigBody, w.Body.Bytes())
+	})
+
+	t.Run("does not compress small body", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		WriteResponse(w, req, []byte("{}"))
+
+		assert.Equal(t, "", w.Header().Get("Content-Encoding"))
+		assert.Equal(t, "{}", w.Body.String())
+	})
+}
@@ -0,0 +1,2 @@
+// Package compression contains internal helpers for compressing HTTP response bodies.
+package compression
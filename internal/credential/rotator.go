@@ -122,6 +122,20 @@ func (r *Rotator) DeprecatedCredentials() []SDKCredential {
 	return r.deprecatedCredentials()
 }
 
+// EarliestDeprecatedExpiry returns the time when the next deprecated SDK key will expire, or false if
+// there are no deprecated SDK keys.
+func (r *Rotator) EarliestDeprecatedExpiry() (time.Time, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var earliest time.Time
+	for _, expiry := range r.deprecatedSdkKeys {
+		if earliest.IsZero() || expiry.Before(earliest) {
+			earliest = expiry
+		}
+	}
+	return earliest, !earliest.IsZero()
+}
+
 // AllCredentials returns the primary and deprecated credentials as one list.
 func (r *Rotator) AllCredentials() []SDKCredential {
 	r.mu.RLock()
@@ -175,6 +175,30 @@ func TestSDKKeyDeprecation(t *testing.T) {
 	assert.ElementsMatch(t, []SDKCredential{key1}, expirations)
 }
 
+func TestEarliestDeprecatedExpiry(t *testing.T) {
+	mockLog := ldlogtest.NewMockLog()
+	rotator := NewRotator(mockLog.Loggers)
+
+	const (
+		key1 = config.SDKKey("key1")
+		key2 = config.SDKKey("key2")
+		key3 = config.SDKKey("key3")
+	)
+
+	start := time.Unix(10000, 0)
+	rotator.Initialize([]SDKCredential{key1})
+
+	_, ok := rotator.EarliestDeprecatedExpiry()
+	assert.False(t, ok)
+
+	rotator.RotateWithGrace(key2, NewGracePeriod(key1, start.Add(2*time.Minute), start))
+	rotator.RotateWithGrace(key3, NewGracePeriod(key2, start.Add(1*time.Minute), start))
+
+	expiry, ok := rotator.EarliestDeprecatedExpiry()
+	assert.True(t, ok)
+	assert.Equal(t, start.Add(1*time.Minute), expiry)
+}
+
 func TestManyConcurrentSDKKeyDeprecation(t *testing.T) {
 	mockLog := ldlogtest.NewMockLog()
 	rotator := NewRotator(mockLog.Loggers)
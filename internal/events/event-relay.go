@@ -33,9 +33,11 @@ const defaultEventQueueCleanupInterval = time.Hour
 type EventDispatcher struct {
 	analyticsEndpoints  map[basictypes.SDKKind]*analyticsEventEndpointDispatcher
 	diagnosticEndpoints map[basictypes.SDKKind]*diagnosticEventEndpointDispatcher
+	stats               *eventPipelineStats
 }
 
 type analyticsEventEndpointDispatcher struct {
+	sdkKind                   basictypes.SDKKind
 	config                    c.EventsConfig
 	httpClient                *http.Client
 	httpConfig                httpconfig.HTTPConfig
@@ -45,6 +47,7 @@ type analyticsEventEndpointDispatcher struct {
 	summarizingRelay          *eventSummarizingRelay
 	storeAdapter              *store.SSERelayDataStoreAdapter
 	eventQueueCleanupInterval time.Duration
+	stats                     *eventPipelineStats
 	loggers                   ldlog.Loggers
 	mu                        sync.Mutex
 }
@@ -81,6 +84,7 @@ func (r *analyticsEventEndpointDispatcher) dispatch(w http.ResponseWriter, req *
 		}
 
 		metadata := GetEventPayloadMetadata(req)
+		r.stats.received(r.sdkKind, len(evts))
 
 		r.loggers.Debugf("Received %d events (v%d) to be proxied to %s", len(evts), metadata.SchemaVersion, r.remotePath)
 		if metadata.SchemaVersion < SummaryEventsSchemaVersion {
@@ -170,7 +174,7 @@ func (r *analyticsEventEndpointDispatcher) getVerbatimRelay() *eventVerbatimRela
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	if r.verbatimRelay == nil {
-		r.verbatimRelay = newEventVerbatimRelay(r.authKey, r.config, r.httpConfig, r.loggers, r.remotePath)
+		r.verbatimRelay = newEventVerbatimRelay(r.authKey, r.config, r.httpConfig, r.loggers, r.remotePath, r.stats)
 	}
 	return r.verbatimRelay
 }
@@ -180,7 +184,7 @@ func (r *analyticsEventEndpointDispatcher) getSummarizingRelay() *eventSummarizi
 	defer r.mu.Unlock()
 	if r.summarizingRelay == nil {
 		r.summarizingRelay = newEventSummarizingRelay(r.config, r.httpConfig, r.authKey, r.storeAdapter,
-			r.loggers, r.remotePath, r.eventQueueCleanupInterval)
+			r.loggers, r.remotePath, r.eventQueueCleanupInterval, r.stats)
 	}
 	return r.summarizingRelay
 }
@@ -197,6 +201,8 @@ func (r *analyticsEventEndpointDispatcher) flush() { //nolint:unused // used onl
 }
 
 // NewEventDispatcher creates a handler for relaying events to LaunchDarkly for an environment
+//
+// If metricsRecorder is not nil, it is notified of the same event activity that is reported by GetStats.
 func NewEventDispatcher(
 	sdkKey c.SDKKey,
 	mobileKey c.MobileKey,
@@ -205,31 +211,40 @@ func NewEventDispatcher(
 	config c.EventsConfig,
 	httpConfig httpconfig.HTTPConfig,
 	storeAdapter *store.SSERelayDataStoreAdapter,
+	metricsRecorder EventMetricsRecorder,
 	eventQueueCleanupInterval time.Duration, // normally zero to use the default; overridden in tests
 ) *EventDispatcher {
+	stats := newEventPipelineStats(metricsRecorder)
 	ep := &EventDispatcher{
 		analyticsEndpoints: map[basictypes.SDKKind]*analyticsEventEndpointDispatcher{
-			basictypes.ServerSDK: newAnalyticsEventEndpointDispatcher(sdkKey,
-				config, httpConfig, storeAdapter, loggers, "/bulk", eventQueueCleanupInterval),
+			basictypes.ServerSDK: newAnalyticsEventEndpointDispatcher(basictypes.ServerSDK, sdkKey,
+				config, httpConfig, storeAdapter, loggers, "/bulk", eventQueueCleanupInterval, stats),
 		},
 		diagnosticEndpoints: map[basictypes.SDKKind]*diagnosticEventEndpointDispatcher{
 			basictypes.ServerSDK: newDiagnosticEventEndpointDispatcher(config, httpConfig, loggers, "/diagnostic"),
 		},
+		stats: stats,
 	}
 	if mobileKey.Defined() {
-		ep.analyticsEndpoints[basictypes.MobileSDK] = newAnalyticsEventEndpointDispatcher(mobileKey,
-			config, httpConfig, storeAdapter, loggers, "/mobile", eventQueueCleanupInterval)
+		ep.analyticsEndpoints[basictypes.MobileSDK] = newAnalyticsEventEndpointDispatcher(basictypes.MobileSDK, mobileKey,
+			config, httpConfig, storeAdapter, loggers, "/mobile", eventQueueCleanupInterval, stats)
 		ep.diagnosticEndpoints[basictypes.MobileSDK] = newDiagnosticEventEndpointDispatcher(config, httpConfig, loggers, "/mobile/events/diagnostic")
 	}
 	if envID.Defined() {
-		ep.analyticsEndpoints[basictypes.JSClientSDK] = newAnalyticsEventEndpointDispatcher(envID, config, httpConfig, storeAdapter, loggers,
-			"/events/bulk/"+string(envID), eventQueueCleanupInterval)
+		ep.analyticsEndpoints[basictypes.JSClientSDK] = newAnalyticsEventEndpointDispatcher(basictypes.JSClientSDK, envID,
+			config, httpConfig, storeAdapter, loggers, "/events/bulk/"+string(envID), eventQueueCleanupInterval, stats)
 		ep.diagnosticEndpoints[basictypes.JSClientSDK] = newDiagnosticEventEndpointDispatcher(config, httpConfig, loggers,
 			"/events/diagnostic/"+string(envID))
 	}
 	return ep
 }
 
+// GetStats returns the counts of analytics events that have passed through this environment's event
+// pipeline since it was created.
+func (r *EventDispatcher) GetStats() EventStats {
+	return r.stats.snapshot()
+}
+
 // Close shuts down any goroutines/channels being used by the EventDispatcher.
 func (r *EventDispatcher) Close() {
 	for _, e := range r.analyticsEndpoints {
@@ -273,6 +288,7 @@ func newDiagnosticEventEndpointDispatcher(
 }
 
 func newAnalyticsEventEndpointDispatcher(
+	sdkKind basictypes.SDKKind,
 	authKey credential.SDKCredential,
 	config c.EventsConfig,
 	httpConfig httpconfig.HTTPConfig,
@@ -280,8 +296,10 @@ func newAnalyticsEventEndpointDispatcher(
 	loggers ldlog.Loggers,
 	remotePath string,
 	eventQueueCleanupInterval time.Duration,
+	stats *eventPipelineStats,
 ) *analyticsEventEndpointDispatcher {
 	return &analyticsEventEndpointDispatcher{
+		sdkKind:                   sdkKind,
 		authKey:                   authKey,
 		config:                    config,
 		httpClient:                httpConfig.Client(),
@@ -290,6 +308,7 @@ func newAnalyticsEventEndpointDispatcher(
 		loggers:                   loggers,
 		remotePath:                remotePath,
 		eventQueueCleanupInterval: eventQueueCleanupInterval,
+		stats:                     stats,
 	}
 }
 
@@ -299,12 +318,14 @@ func newEventVerbatimRelay(
 	httpConfig httpconfig.HTTPConfig,
 	loggers ldlog.Loggers,
 	remotePath string,
+	stats *eventPipelineStats,
 ) *eventVerbatimRelay {
 	eventsURI := getEventsURI(config)
 	opts := []OptionType{
 		OptionCapacity(config.Capacity.GetOrElse(c.DefaultEventCapacity)),
 		OptionBaseURI(eventsURI),
 		OptionURIPath(remotePath),
+		optionStats{stats},
 	}
 
 	opts = append(opts, OptionFlushInterval(config.FlushInterval.GetOrElse(c.DefaultEventsFlushInterval)))
@@ -68,6 +68,8 @@ var allTestEndpoints = []testEndpointInfo{testServerEndpointInfo, testMobileEndp
 
 type eventRelayTestOptions struct {
 	eventQueueCleanupInterval time.Duration
+	metricsRecorder           EventMetricsRecorder
+	responseStatus            int
 }
 
 type eventRelayTestParams struct {
@@ -101,7 +103,11 @@ func eventRelayTestWithOptions(
 
 	store := st.NewInMemoryStore()
 
-	handler, requestsCh := httphelpers.RecordingHandler(httphelpers.HandlerWithStatus(202))
+	responseStatus := opts.responseStatus
+	if responseStatus == 0 {
+		responseStatus = 202
+	}
+	handler, requestsCh := httphelpers.RecordingHandler(httphelpers.HandlerWithStatus(responseStatus))
 	httphelpers.WithServer(handler, func(server *httptest.Server) {
 		eventsConfig.SendEvents = true
 		if !eventsConfig.FlushInterval.IsDefined() {
@@ -118,6 +124,7 @@ func eventRelayTestWithOptions(
 			eventsConfig,
 			httpConfig,
 			makeStoreAdapterWithExistingStore(store),
+			opts.metricsRecorder,
 			opts.eventQueueCleanupInterval,
 		)
 		defer dispatcher.Close()
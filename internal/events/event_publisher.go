@@ -106,6 +106,7 @@ type HTTPEventPublisher struct {
 	queues     map[EventPayloadMetadata]*publisherQueue
 	capacity   int
 	overflowed bool
+	stats      *eventPipelineStats
 	lock       sync.RWMutex
 }
 
@@ -159,6 +160,17 @@ func (o OptionCapacity) apply(p *HTTPEventPublisher) error {
 	return nil
 }
 
+// optionStats specifies where to count the events that pass through the publisher. This is unexported
+// because only the EventDispatcher's publishers are counted.
+type optionStats struct {
+	stats *eventPipelineStats
+}
+
+func (o optionStats) apply(p *HTTPEventPublisher) error {
+	p.stats = o.stats
+	return nil
+}
+
 // NewHTTPEventPublisher creates a new HTTPEventPublisher.
 func NewHTTPEventPublisher(authKey credential.SDKCredential, httpConfig httpconfig.HTTPConfig, loggers ldlog.Loggers, options ...OptionType) (*HTTPEventPublisher, error) {
 	closer := make(chan struct{})
@@ -215,10 +227,16 @@ func NewHTTPEventPublisher(authKey credential.SDKCredential, httpConfig httpconf
 					p.loggers.Warnf("Discarding in-memory and all future events due to unrecoverable failure when sending events.")
 					ticker.Stop()
 					// Ensure we free up as much memory as we can by clearing any pending events
+					queued := p.queuedCount()
+					p.stats.dropped(queued)
+					p.stats.queueDepthChanged(-queued)
 					p.queues = make(map[EventPayloadMetadata]*publisherQueue)
 					p.disabled = true
 				case e := <-inputQueue:
 					if p.disabled {
+						if batch, ok := e.(eventBatch); ok {
+							p.stats.dropped(len(batch.events))
+						}
 						continue
 					}
 
@@ -261,6 +279,16 @@ func (p *HTTPEventPublisher) append(batch eventBatch) {
 		p.overflowed = false
 	}
 	queue.events = append(queue.events, batch.events[:taken]...)
+	p.stats.dropped(len(batch.events) - taken)
+	p.stats.queueDepthChanged(taken)
+}
+
+func (p *HTTPEventPublisher) queuedCount() int {
+	count := 0
+	for _, queue := range p.queues {
+		count += len(queue.events)
+	}
+	return count
 }
 
 func (p *HTTPEventPublisher) ReplaceCredential(newCredential credential.SDKCredential) { //nolint:golint // method is already documented in interface
@@ -312,6 +340,7 @@ func (p *HTTPEventPublisher) flush() {
 		}
 		payload, err := json.Marshal(queue.events)
 		queue.events = queue.events[0:0]
+		p.stats.queueDepthChanged(-count)
 		if discardingUnusedBuffers {
 			p.queues[metadata] = queue
 		}
@@ -350,7 +379,7 @@ func (p *HTTPEventPublisher) flush() {
 				Loggers:           p.loggers,
 				EnableCompression: true,
 			}
-			result := ldevents.SendEventDataWithRetry(sendConfig, ldevents.AnalyticsEventDataKind, p.uriPath, payload, count)
+			result := sendEventDataWithStats(sendConfig, ldevents.AnalyticsEventDataKind, p.uriPath, payload, count, p.stats)
 			p.wg.Done()
 			if result.MustShutDown {
 				p.disableQueue <- struct{}{}
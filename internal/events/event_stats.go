@@ -0,0 +1,221 @@
+package events
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/launchdarkly/ld-relay/v8/internal/basictypes"
+
+	ldevents "github.com/launchdarkly/go-sdk-events/v3"
+)
+
+// networkErrorStatusKey is used in place of an HTTP status code when an event post failed without
+// getting any response.
+const networkErrorStatusKey = "network_error"
+
+// EventMetricsRecorder receives notifications about events passing through the event pipeline, so that
+// they can be exported as metrics. The events package does not call the metrics package directly,
+// because the metrics package depends on this one.
+type EventMetricsRecorder interface {
+	// EventsReceived is called when an SDK posts analytics events to Relay.
+	EventsReceived(sdkKind basictypes.SDKKind, count int)
+	// EventsForwarded is called when a payload of events has been delivered to LaunchDarkly.
+	EventsForwarded(count int)
+	// EventsDropped is called when events are discarded because a queue is full or event sending has
+	// been disabled.
+	EventsDropped(count int)
+	// EventSendFailed is called for each failed post attempt, including ones that will be retried.
+	EventSendFailed(statusKey string)
+	// EventSendRetried is called when a post is retried after a failure with the specified status.
+	EventSendRetried(statusKey string)
+	// EventQueueDepth is called with the total number of events waiting to be sent for the environment
+	// whenever that number changes.
+	EventQueueDepth(depth int)
+}
+
+// EventStats is a snapshot of the event pipeline counters for one environment, since it was created.
+//
+// Status keys in SendFailures and Retries are HTTP status codes, or "network_error" if there was no
+// response.
+type EventStats struct {
+	Received          map[basictypes.SDKKind]int64
+	PayloadsForwarded int64
+	EventsForwarded   int64
+	Dropped           int64
+	SendFailures      map[string]int64
+	Retries           map[string]int64
+	QueueDepth        int64
+}
+
+// eventPipelineStats accumulates the counters for EventStats, and passes the same information on to an
+// EventMetricsRecorder if there is one. All of its methods are safe to call on a nil pointer, which is
+// what we use for publishers whose activity shouldn't be counted, such as the one for Relay's own
+// usage metrics.
+type eventPipelineStats struct {
+	recorder EventMetricsRecorder
+	stats    EventStats
+	lock     sync.Mutex
+}
+
+func newEventPipelineStats(recorder EventMetricsRecorder) *eventPipelineStats {
+	return &eventPipelineStats{
+		recorder: recorder,
+		stats: EventStats{
+			Received:     make(map[basictypes.SDKKind]int64),
+			SendFailures: make(map[string]int64),
+			Retries:      make(map[string]int64),
+		},
+	}
+}
+
+func (s *eventPipelineStats) received(sdkKind basictypes.SDKKind, count int) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	s.stats.Received[sdkKind] += int64(count)
+	s.lock.Unlock()
+	if s.recorder != nil {
+		s.recorder.EventsReceived(sdkKind, count)
+	}
+}
+
+func (s *eventPipelineStats) forwarded(count int) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	s.stats.PayloadsForwarded++
+	s.stats.EventsForwarded += int64(count)
+	s.lock.Unlock()
+	if s.recorder != nil {
+		s.recorder.EventsForwarded(count)
+	}
+}
+
+func (s *eventPipelineStats) dropped(count int) {
+	if s == nil || count <= 0 {
+		return
+	}
+	s.lock.Lock()
+	s.stats.Dropped += int64(count)
+	s.lock.Unlock()
+	if s.recorder != nil {
+		s.recorder.EventsDropped(count)
+	}
+}
+
+func (s *eventPipelineStats) sendFailed(statusKey string) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	s.stats.SendFailures[statusKey]++
+	s.lock.Unlock()
+	if s.recorder != nil {
+		s.recorder.EventSendFailed(statusKey)
+	}
+}
+
+func (s *eventPipelineStats) retried(statusKey string) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	s.stats.Retries[statusKey]++
+	s.lock.Unlock()
+	if s.recorder != nil {
+		s.recorder.EventSendRetried(statusKey)
+	}
+}
+
+// queueDepthChanged adjusts the environment's total queue depth. Each publisher reports only the change
+// in its own queue, since an environment can have several publishers.
+func (s *eventPipelineStats) queueDepthChanged(delta int) {
+	if s == nil || delta == 0 {
+		return
+	}
+	s.lock.Lock()
+	s.stats.QueueDepth += int64(delta)
+	depth := s.stats.QueueDepth
+	s.lock.Unlock()
+	if s.recorder != nil {
+		s.recorder.EventQueueDepth(int(depth))
+	}
+}
+
+func (s *eventPipelineStats) snapshot() EventStats {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	ret := s.stats
+	ret.Received = make(map[basictypes.SDKKind]int64, len(s.stats.Received))
+	for k, v := range s.stats.Received {
+		ret.Received[k] = v
+	}
+	ret.SendFailures = copyStatusCounts(s.stats.SendFailures)
+	ret.Retries = copyStatusCounts(s.stats.Retries)
+	return ret
+}
+
+func copyStatusCounts(m map[string]int64) map[string]int64 {
+	ret := make(map[string]int64, len(m))
+	for k, v := range m {
+		ret[k] = v
+	}
+	return ret
+}
+
+// sendEventDataWithStats is a wrapper for ldevents.SendEventDataWithRetry that counts the outcome of each
+// HTTP attempt. SendEventDataWithRetry doesn't report individual attempts, so we observe them through the
+// HTTP client's transport.
+func sendEventDataWithStats(
+	config ldevents.EventSenderConfiguration,
+	kind ldevents.EventDataKind,
+	path string,
+	data []byte,
+	count int,
+	stats *eventPipelineStats,
+) ldevents.EventSenderResult {
+	if stats == nil || config.Client == nil {
+		return ldevents.SendEventDataWithRetry(config, kind, path, data, count)
+	}
+	client := *config.Client
+	client.Transport = &attemptObservingTransport{base: client.Transport, stats: stats}
+	config.Client = &client
+	result := ldevents.SendEventDataWithRetry(config, kind, path, data, count)
+	if result.Success {
+		stats.forwarded(count)
+	}
+	return result
+}
+
+// attemptObservingTransport is used for a single call to SendEventDataWithRetry, so every request after
+// the first one is a retry.
+type attemptObservingTransport struct {
+	base           http.RoundTripper
+	stats          *eventPipelineStats
+	lastFailureKey string
+}
+
+func (t *attemptObservingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.lastFailureKey != "" {
+		t.stats.retried(t.lastFailureKey)
+	}
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(req)
+	switch {
+	case err != nil:
+		t.lastFailureKey = networkErrorStatusKey
+	case resp.StatusCode >= 400:
+		t.lastFailureKey = strconv.Itoa(resp.StatusCode)
+	default:
+		t.lastFailureKey = ""
+		return resp, err
+	}
+	t.stats.sendFailed(t.lastFailureKey)
+	return resp, err
+}
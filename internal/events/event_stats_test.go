@@ -0,0 +1,184 @@
+package events
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v8/config"
+	"github.com/launchdarkly/ld-relay/v8/internal/basictypes"
+	st "github.com/launchdarkly/ld-relay/v8/internal/sharedtest"
+
+	"github.com/launchdarkly/go-configtypes"
+	ldevents "github.com/launchdarkly/go-sdk-events/v3"
+	helpers "github.com/launchdarkly/go-test-helpers/v3"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordedEventMetrics struct {
+	received   map[basictypes.SDKKind]int
+	forwarded  int
+	dropped    int
+	failures   map[string]int
+	retries    map[string]int
+	queueDepth int
+	lock       sync.Mutex
+}
+
+func newRecordedEventMetrics() *recordedEventMetrics {
+	return &recordedEventMetrics{
+		received: make(map[basictypes.SDKKind]int),
+		failures: make(map[string]int),
+		retries:  make(map[string]int),
+	}
+}
+
+func (r *recordedEventMetrics) EventsReceived(sdkKind basictypes.SDKKind, count int) {
+	r.lock.Lock()
+	r.received[sdkKind] += count
+	r.lock.Unlock()
+}
+
+func (r *recordedEventMetrics) EventsForwarded(count int) {
+	r.lock.Lock()
+	r.forwarded += count
+	r.lock.Unlock()
+}
+
+func (r *recordedEventMetrics) EventsDropped(count int) {
+	r.lock.Lock()
+	r.dropped += count
+	r.lock.Unlock()
+}
+
+func (r *recordedEventMetrics) EventSendFailed(statusKey string) {
+	r.lock.Lock()
+	r.failures[statusKey]++
+	r.lock.Unlock()
+}
+
+func (r *recordedEventMetrics) EventSendRetried(statusKey string) {
+	r.lock.Lock()
+	r.retries[statusKey]++
+	r.lock.Unlock()
+}
+
+func (r *recordedEventMetrics) EventQueueDepth(depth int) {
+	r.lock.Lock()
+	r.queueDepth = depth
+	r.lock.Unlock()
+}
+
+func postEventsToDispatcher(t *testing.T, dispatcher *EventDispatcher, sdkKind basictypes.SDKKind, body string) {
+	req := st.BuildRequest("POST", "/", []byte(body), headersWithEventSchema(CurrentEventsSchemaVersion))
+	handler := dispatcher.GetHandler(sdkKind, ldevents.AnalyticsEventDataKind)
+	require.NotNil(t, handler)
+	w := httptest.NewRecorder()
+	handler(w, req)
+	require.Equal(t, http.StatusAccepted, w.Result().StatusCode)
+}
+
+func TestEventStatsCountsForwardedEvents(t *testing.T) {
+	recorder := newRecordedEventMetrics()
+	opts := eventRelayTestOptions{metricsRecorder: recorder}
+	eventRelayTestWithOptions(t, st.EnvWithAllCredentials, config.EventsConfig{}, opts, func(p eventRelayTestParams) {
+		postEventsToDispatcher(t, p.dispatcher, basictypes.ServerSDK, `["a","b"]`)
+		postEventsToDispatcher(t, p.dispatcher, basictypes.MobileSDK, `["c"]`)
+
+		require.Eventually(t, func() bool { return p.dispatcher.GetStats().QueueDepth == 3 }, time.Second, time.Millisecond*10)
+
+		p.dispatcher.flush()
+		helpers.RequireValue(t, p.requestsCh, time.Second)
+		helpers.RequireValue(t, p.requestsCh, time.Second)
+
+		require.Eventually(t, func() bool { return p.dispatcher.GetStats().EventsForwarded == 3 }, time.Second, time.Millisecond*10)
+		stats := p.dispatcher.GetStats()
+		assert.Equal(t, map[basictypes.SDKKind]int64{basictypes.ServerSDK: 2, basictypes.MobileSDK: 1}, stats.Received)
+		assert.Equal(t, int64(2), stats.PayloadsForwarded)
+		assert.Equal(t, int64(0), stats.Dropped)
+		assert.Equal(t, int64(0), stats.QueueDepth)
+		assert.Len(t, stats.SendFailures, 0)
+		assert.Len(t, stats.Retries, 0)
+
+		recorder.lock.Lock()
+		defer recorder.lock.Unlock()
+		assert.Equal(t, map[basictypes.SDKKind]int{basictypes.ServerSDK: 2, basictypes.MobileSDK: 1}, recorder.received)
+		assert.Equal(t, 3, recorder.forwarded)
+		assert.Equal(t, 0, recorder.queueDepth)
+	})
+}
+
+func TestEventStatsCountsDroppedEvents(t *testing.T) {
+	recorder := newRecordedEventMetrics()
+	opts := eventRelayTestOptions{metricsRecorder: recorder}
+	var eventsConfig config.EventsConfig
+	eventsConfig.Capacity, _ = configtypes.NewOptIntGreaterThanZero(2)
+	eventRelayTestWithOptions(t, st.EnvWithAllCredentials, eventsConfig, opts, func(p eventRelayTestParams) {
+		postEventsToDispatcher(t, p.dispatcher, basictypes.ServerSDK, `["a","b","c"]`)
+
+		require.Eventually(t, func() bool { return p.dispatcher.GetStats().Dropped == 1 }, time.Second, time.Millisecond*10)
+		assert.Equal(t, int64(2), p.dispatcher.GetStats().QueueDepth)
+
+		recorder.lock.Lock()
+		defer recorder.lock.Unlock()
+		assert.Equal(t, 1, recorder.dropped)
+		assert.Equal(t, 2, recorder.queueDepth)
+	})
+}
+
+func TestEventStatsCountsFailuresAndRetries(t *testing.T) {
+	recorder := newRecordedEventMetrics()
+	opts := eventRelayTestOptions{metricsRecorder: recorder, responseStatus: http.StatusServiceUnavailable}
+	eventRelayTestWithOptions(t, st.EnvWithAllCredentials, config.EventsConfig{}, opts, func(p eventRelayTestParams) {
+		postEventsToDispatcher(t, p.dispatcher, basictypes.ServerSDK, `["a"]`)
+		require.Eventually(t, func() bool { return p.dispatcher.GetStats().QueueDepth == 1 }, time.Second, time.Millisecond*10)
+		p.dispatcher.flush()
+
+		// A recoverable error is retried once, after a delay
+		helpers.RequireValue(t, p.requestsCh, time.Second)
+		helpers.RequireValue(t, p.requestsCh, time.Second*3)
+
+		require.Eventually(t, func() bool { return p.dispatcher.GetStats().SendFailures["503"] == 2 }, time.Second, time.Millisecond*10)
+		stats := p.dispatcher.GetStats()
+		assert.Equal(t, map[string]int64{"503": 1}, stats.Retries)
+		assert.Equal(t, int64(0), stats.PayloadsForwarded)
+
+		recorder.lock.Lock()
+		defer recorder.lock.Unlock()
+		assert.Equal(t, map[string]int{"503": 2}, recorder.failures)
+		assert.Equal(t, map[string]int{"503": 1}, recorder.retries)
+	})
+}
+
+func TestEventStatsRecordsNetworkErrors(t *testing.T) {
+	stats := newEventPipelineStats(nil)
+	transport := &attemptObservingTransport{
+		base:  roundTripperFunc(func(*http.Request) (*http.Response, error) { return nil, http.ErrHandlerTimeout }),
+		stats: stats,
+	}
+	req, _ := http.NewRequest("POST", "http://localhost", nil)
+	_, err := transport.RoundTrip(req)
+	assert.Error(t, err)
+	_, _ = transport.RoundTrip(req)
+	snapshot := stats.snapshot()
+	assert.Equal(t, map[string]int64{networkErrorStatusKey: 2}, snapshot.SendFailures)
+	assert.Equal(t, map[string]int64{networkErrorStatusKey: 1}, snapshot.Retries)
+}
+
+func TestEventPipelineStatsMethodsAreSafeOnNil(t *testing.T) {
+	var stats *eventPipelineStats
+	stats.received(basictypes.ServerSDK, 1)
+	stats.forwarded(1)
+	stats.dropped(1)
+	stats.sendFailed("500")
+	stats.retried("500")
+	stats.queueDepthChanged(1)
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
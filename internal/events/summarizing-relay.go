@@ -30,6 +30,11 @@ import (
 // Like HTTPEventPublisher, this supports proxying events in separate payloads if we received them
 // with different request metadata (e.g. tags). To do this, we have to maintain a separate
 // EventProcessor instance for each unique metadata set we've seen.
+//
+// The EventProcessor discards events without telling us when its buffer is full, so we enforce the
+// configured capacity ourselves before passing events to it; that way we can count the dropped events
+// and report the queue depth the same way HTTPEventPublisher does. Only events that take up a place in
+// the output buffer are counted. Feature events that only contribute to summary data are not.
 type eventSummarizingRelay struct {
 	queues       map[EventPayloadMetadata]*eventSummarizingRelayQueue
 	authKey      credential.SDKCredential
@@ -37,9 +42,11 @@ type eventSummarizingRelay struct {
 	baseHeaders  http.Header
 	storeAdapter *store.SSERelayDataStoreAdapter
 	eventsConfig ldevents.EventsConfiguration
+	capacity     int
 	baseURI      string
 	remotePath   string
 	loggers      ldlog.Loggers
+	stats        *eventPipelineStats
 	closer       chan struct{}
 	lock         sync.Mutex
 	closeOnce    sync.Once
@@ -52,9 +59,17 @@ type eventSummarizingRelayQueue struct {
 	active         bool
 }
 
+// delegatingEventSender also keeps track of how many events are waiting in the EventProcessor that it
+// belongs to, since it is the only component that sees when the EventProcessor sends them.
 type delegatingEventSender struct {
-	wrapped ldevents.EventSender
-	lock    sync.Mutex
+	wrapped    ldevents.EventSender
+	stats      *eventPipelineStats
+	loggers    ldlog.Loggers
+	capacity   int
+	pending    int
+	overflowed bool
+	disabled   bool
+	lock       sync.Mutex
 }
 
 func newEventSummarizingRelay(
@@ -65,9 +80,13 @@ func newEventSummarizingRelay(
 	loggers ldlog.Loggers,
 	remotePath string,
 	eventQueueCleanupInterval time.Duration,
+	stats *eventPipelineStats,
 ) *eventSummarizingRelay {
+	capacity := config.Capacity.GetOrElse(c.DefaultEventCapacity)
 	eventsConfig := ldevents.EventsConfiguration{
-		Capacity:              config.Capacity.GetOrElse(c.DefaultEventCapacity),
+		// The EventProcessor's own buffer also has to hold the index events that it generates, so we
+		// give it room for those in addition to the events that we have accepted; see acceptEvent().
+		Capacity:              capacity * 2,
 		FlushInterval:         config.FlushInterval.GetOrElse(c.DefaultEventsFlushInterval),
 		Loggers:               loggers,
 		UserKeysCapacity:      ldcomponents.DefaultContextKeysCapacity,
@@ -85,9 +104,11 @@ func newEventSummarizingRelay(
 		baseHeaders:  baseHeaders,
 		storeAdapter: storeAdapter,
 		eventsConfig: eventsConfig,
+		capacity:     capacity,
 		baseURI:      getEventsURI(config),
 		remotePath:   remotePath,
 		loggers:      loggers,
+		stats:        stats,
 		closer:       make(chan struct{}),
 	}
 	go er.runPeriodicCleanupTaskUntilClosed(eventQueueCleanupInterval)
@@ -104,7 +125,10 @@ func (er *eventSummarizingRelay) enqueue(metadata EventPayloadMetadata, rawEvent
 	queue := er.queues[metadata]
 	if queue == nil {
 		sender := &delegatingEventSender{
-			wrapped: makeEventSender(er.httpClient, er.baseURI, er.remotePath, er.baseHeaders, er.authKey, metadata, er.loggers),
+			wrapped:  makeEventSender(er.httpClient, er.baseURI, er.remotePath, er.baseHeaders, er.authKey, metadata, er.loggers, er.stats),
+			stats:    er.stats,
+			loggers:  er.loggers,
+			capacity: er.capacity,
 		}
 		eventsConfig := er.eventsConfig
 		eventsConfig.EventSender = sender
@@ -124,7 +148,7 @@ func (er *eventSummarizingRelay) enqueue(metadata EventPayloadMetadata, rawEvent
 			er.loggers.Errorf("Error in event processing, event was discarded: %s", err)
 			continue
 		}
-		_ = er.dispatchEvent(queue.eventProcessor, oldEvent, rawEvent, metadata.SchemaVersion)
+		_ = er.dispatchEvent(queue, oldEvent, rawEvent, metadata.SchemaVersion)
 	}
 }
 
@@ -146,7 +170,7 @@ func (er *eventSummarizingRelay) replaceCredential(newCredential credential.SDKC
 		er.authKey = newCredential
 		for metadata, queue := range er.queues {
 			// See comment on makeEventSender() about why we create a new one in this situation.
-			sender := makeEventSender(er.httpClient, er.baseURI, er.remotePath, er.baseHeaders, newCredential, metadata, er.loggers)
+			sender := makeEventSender(er.httpClient, er.baseURI, er.remotePath, er.baseHeaders, newCredential, metadata, er.loggers, er.stats)
 			queue.eventSender.setWrapped(sender)
 		}
 	}
@@ -154,17 +178,21 @@ func (er *eventSummarizingRelay) replaceCredential(newCredential credential.SDKC
 }
 
 func (er *eventSummarizingRelay) dispatchEvent(
-	ep ldevents.EventProcessor,
+	queue *eventSummarizingRelayQueue,
 	oldEvent oldevents.OldEvent,
 	rawEvent []byte,
 	schemaVersion int,
 ) error {
+	ep := queue.eventProcessor
 	switch e := oldEvent.(type) {
 	case oldevents.FeatureEvent:
 		evalData, err := oldevents.TranslateFeatureEvent(e, schemaVersion, er.storeAdapter.GetStore())
 		if err != nil {
 			return err
 		}
+		if (evalData.RequireFullEvent || evalData.DebugEventsUntilDate != 0) && !queue.eventSender.acceptEvent() {
+			return nil
+		}
 		ep.RecordEvaluation(evalData)
 
 	case oldevents.CustomEvent:
@@ -172,20 +200,26 @@ func (er *eventSummarizingRelay) dispatchEvent(
 		if err != nil {
 			return err
 		}
-		ep.RecordCustomEvent(customData)
+		if queue.eventSender.acceptEvent() {
+			ep.RecordCustomEvent(customData)
+		}
 
 	case oldevents.IdentifyEvent:
 		identifyData, err := oldevents.TranslateIdentifyEvent(e)
 		if err != nil {
 			return err
 		}
-		ep.RecordIdentifyEvent(identifyData)
+		if queue.eventSender.acceptEvent() {
+			ep.RecordIdentifyEvent(identifyData)
+		}
 
 	case oldevents.UntranslatedEvent:
 		// We use this for alias events, and anything else we don't recognize. We can't do any kind of
 		// post-processing on such things, so we'll just throw them into the output as-is and let
 		// event-recorder decide what to do with them.
-		ep.RecordRawEvent(rawEvent)
+		if queue.eventSender.acceptEvent() {
+			ep.RecordRawEvent(rawEvent)
+		}
 	}
 	return nil
 }
@@ -215,6 +249,7 @@ func (er *eventSummarizingRelay) runPeriodicCleanupTaskUntilClosed(cleanupInterv
 			er.lock.Unlock()
 			for _, queue := range queues {
 				_ = queue.eventProcessor.Close()
+				queue.eventSender.discardPending()
 			}
 			return
 
@@ -242,6 +277,7 @@ func (er *eventSummarizingRelay) runPeriodicCleanupTaskUntilClosed(cleanupInterv
 			for _, queue := range unused {
 				er.loggers.Debugf("Shutting down inactive summarizing relay for %+v", queue.metadata)
 				_ = queue.eventProcessor.Close()
+				queue.eventSender.discardPending()
 			}
 		}
 	}
@@ -256,8 +292,57 @@ func (er *eventSummarizingRelay) close() {
 func (d *delegatingEventSender) SendEventData(kind ldevents.EventDataKind, data []byte, count int) ldevents.EventSenderResult {
 	d.lock.Lock()
 	sender := d.wrapped
+	sent := 0
+	if kind == ldevents.AnalyticsEventDataKind {
+		// The EventProcessor sends everything it had buffered in one payload, so none of the events we
+		// accepted are waiting anymore.
+		sent, d.pending = d.pending, 0
+	}
 	d.lock.Unlock()
-	return sender.SendEventData(kind, data, count)
+	d.stats.queueDepthChanged(-sent)
+	result := sender.SendEventData(kind, data, count)
+	if result.MustShutDown {
+		// The EventProcessor will silently discard all events from now on.
+		d.lock.Lock()
+		d.disabled = true
+		d.lock.Unlock()
+	}
+	return result
+}
+
+// acceptEvent is called before passing an event to the EventProcessor that will take up a place in its
+// output buffer. It returns false, and counts the event as dropped, if the buffer is already full.
+func (d *delegatingEventSender) acceptEvent() bool {
+	d.lock.Lock()
+	if d.disabled {
+		d.lock.Unlock()
+		d.stats.dropped(1)
+		return false
+	}
+	if d.pending >= d.capacity {
+		if !d.overflowed {
+			d.loggers.Warnf("Exceeded event queue capacity of %d. Increase capacity to avoid dropping events.", d.capacity)
+			d.overflowed = true
+		}
+		d.lock.Unlock()
+		d.stats.dropped(1)
+		return false
+	}
+	d.pending++
+	d.overflowed = false
+	d.lock.Unlock()
+	d.stats.queueDepthChanged(1)
+	return true
+}
+
+// discardPending is called after the EventProcessor has been closed, to remove any events that it did
+// not send from the queue depth.
+func (d *delegatingEventSender) discardPending() {
+	d.lock.Lock()
+	discarded := d.pending
+	d.pending = 0
+	d.lock.Unlock()
+	d.stats.queueDepthChanged(-discarded)
 }
 
 func (d *delegatingEventSender) setWrapped(newWrappedSender ldevents.EventSender) {
@@ -282,6 +367,7 @@ func makeEventSender(
 	authKey credential.SDKCredential,
 	metadata EventPayloadMetadata,
 	loggers ldlog.Loggers,
+	stats *eventPipelineStats,
 ) ldevents.EventSender {
 	headers := make(http.Header)
 	for k, v := range baseHeaders {
@@ -302,14 +388,19 @@ func makeEventSender(
 			EnableCompression: true,
 		},
 		remotePath: remotePath,
+		stats:      stats,
 	}
 }
 
 type eventSenderWithOverridePath struct {
 	config     ldevents.EventSenderConfiguration
 	remotePath string
+	stats      *eventPipelineStats
 }
 
 func (e *eventSenderWithOverridePath) SendEventData(kind ldevents.EventDataKind, data []byte, eventCount int) ldevents.EventSenderResult {
-	return ldevents.SendEventDataWithRetry(e.config, kind, e.remotePath, data, eventCount)
+	if kind != ldevents.AnalyticsEventDataKind {
+		return ldevents.SendEventDataWithRetry(e.config, kind, e.remotePath, data, eventCount)
+	}
+	return sendEventDataWithStats(e.config, kind, e.remotePath, data, eventCount, e.stats)
 }
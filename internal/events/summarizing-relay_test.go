@@ -13,6 +13,7 @@ import (
 	st "github.com/launchdarkly/ld-relay/v8/internal/sharedtest"
 	"github.com/launchdarkly/ld-relay/v8/internal/util"
 
+	"github.com/launchdarkly/go-configtypes"
 	ldevents "github.com/launchdarkly/go-sdk-events/v3"
 	helpers "github.com/launchdarkly/go-test-helpers/v3"
 	"github.com/launchdarkly/go-test-helpers/v3/httphelpers"
@@ -192,3 +193,34 @@ func TestSummarizingRelayPeriodicallyClosesInactiveEventProcessors(t *testing.T)
 		)))
 	})
 }
+
+func TestSummarizingRelayCountsDroppedEventsAndQueueDepth(t *testing.T) {
+	customEvent := `{"kind": "custom", "creationDate": 1000, "key": "eventkey", "user": { "key": "userkey" }}`
+	payload := `[` + customEvent + `,` + customEvent + `,` + customEvent + `]`
+	headers := headersWithEventSchema(0)
+	headers.Set(EventUnsummarizedHeader, "true")
+
+	recorder := newRecordedEventMetrics()
+	opts := eventRelayTestOptions{metricsRecorder: recorder}
+	var eventsConfig config.EventsConfig
+	eventsConfig.Capacity, _ = configtypes.NewOptIntGreaterThanZero(2)
+	eventRelayTestWithOptions(t, st.EnvMain, eventsConfig, opts, func(p eventRelayTestParams) {
+		req := st.BuildRequest("POST", "/", []byte(payload), headers)
+		p.dispatcher.GetHandler(basictypes.ServerSDK, ldevents.AnalyticsEventDataKind)(httptest.NewRecorder(), req)
+
+		stats := p.dispatcher.GetStats()
+		assert.Equal(t, int64(1), stats.Dropped)
+		assert.Equal(t, int64(2), stats.QueueDepth)
+
+		p.dispatcher.flush()
+		expectSummarizedPayloadRequest(t, p.requestsCh)
+
+		require.Eventually(t, func() bool { return p.dispatcher.GetStats().EventsForwarded > 0 }, time.Second, time.Millisecond*10)
+		assert.Equal(t, int64(0), p.dispatcher.GetStats().QueueDepth)
+
+		recorder.lock.Lock()
+		defer recorder.lock.Unlock()
+		assert.Equal(t, 1, recorder.dropped)
+		assert.Equal(t, 0, recorder.queueDepth)
+	})
+}
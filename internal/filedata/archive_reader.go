@@ -105,6 +105,32 @@ func (ar *archiveReader) Close() {
 	_ = os.RemoveAll(ar.dirPath)
 }
 
+// ReadArchive reads all of the environments in a data file. Unlike ArchiveManager, it does not monitor the
+// file for changes; it is for tools that only need the current contents.
+func ReadArchive(filePath string) ([]ArchiveEnvironment, error) {
+	if _, err := os.Stat(filePath); err != nil {
+		return nil, errCannotOpenArchiveFile(filePath, err)
+	}
+	ar, err := newArchiveReader(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer ar.Close()
+	ret := make([]ArchiveEnvironment, 0, len(ar.GetEnvironmentIDs()))
+	for _, envID := range ar.GetEnvironmentIDs() {
+		metadata, err := ar.GetEnvironmentMetadata(envID)
+		if err != nil {
+			return nil, err
+		}
+		sdkData, err := ar.GetEnvironmentSDKData(envID)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, ArchiveEnvironment{Params: metadata.params, SDKData: sdkData})
+	}
+	return ret, nil
+}
+
 // GetEnvironmentIDs returns all of the environment IDs contained in the archive. These are detected
 // by simply looking for all filenames in the format "$ENVID.json".
 func (ar *archiveReader) GetEnvironmentIDs() []config.EnvironmentID {
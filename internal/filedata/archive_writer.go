@@ -0,0 +1,134 @@
+package filedata
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/md5" //nolint:gosec // we're not using this weak algorithm for authentication, only for detecting file changes
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/launchdarkly/ld-relay/v8/config"
+	"github.com/launchdarkly/ld-relay/v8/internal/envfactory"
+
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoreimpl"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoretypes"
+)
+
+// ArchiveWriter creates a data file in the same format that the file data source reads. Environments are
+// written to a temporary directory as they are added, and then Write puts them all in the archive file.
+type ArchiveWriter struct {
+	dirPath        string
+	environmentIDs []config.EnvironmentID
+}
+
+// NewArchiveWriter creates an ArchiveWriter with no environments.
+func NewArchiveWriter() (*ArchiveWriter, error) {
+	dirPath, err := os.MkdirTemp("", "ld-relay-")
+	if err != nil {
+		return nil, err // COVERAGE: can't cause this condition in unit tests (unexpected OS error)
+	}
+	return &ArchiveWriter{dirPath: dirPath}, nil
+}
+
+// AddEnvironment writes the metadata and SDK data for an environment. If the environment was already
+// added, it is replaced.
+func (aw *ArchiveWriter) AddEnvironment(rep envfactory.EnvironmentRep, allData []ldstoretypes.SerializedCollection) error {
+	sdkData := make(map[string]map[string]json.RawMessage, len(allData))
+	for _, coll := range allData {
+		kindName := coll.Kind.GetName()
+		if coll.Kind == ldstoreimpl.Features() {
+			kindName = "flags"
+		}
+		items := make(map[string]json.RawMessage, len(coll.Items))
+		for _, item := range coll.Items {
+			serialized := item.Item.SerializedItem
+			if serialized == nil {
+				// Some data stores represent a deleted item with only a version, but the file needs a placeholder
+				serialized = coll.Kind.Serialize(ldstoretypes.ItemDescriptor{Version: item.Item.Version})
+			}
+			items[item.Key] = serialized
+		}
+		sdkData[kindName] = items
+	}
+	sdkDataJSON, err := json.Marshal(sdkData)
+	if err != nil {
+		return err // COVERAGE: can't happen, all of the values are valid JSON
+	}
+	dataHash := md5.Sum(sdkDataJSON) //nolint:gosec // see above
+	metadataJSON, err := json.Marshal(archiveEnvironmentRep{Env: rep, DataID: hex.EncodeToString(dataHash[:])})
+	if err != nil {
+		return err // COVERAGE: can't happen, all of the fields are serializable
+	}
+	if err := os.WriteFile(envSDKDataFilePath(aw.dirPath, rep.EnvID), sdkDataJSON, 0600); err != nil {
+		return err
+	}
+	if err := os.WriteFile(envMetadataFilePath(aw.dirPath, rep.EnvID), metadataJSON, 0600); err != nil {
+		return err
+	}
+	for _, envID := range aw.environmentIDs {
+		if envID == rep.EnvID {
+			return nil
+		}
+	}
+	aw.environmentIDs = append(aw.environmentIDs, rep.EnvID)
+	return nil
+}
+
+// GetEnvironmentSDKData reads back the SDK data that was written for an environment.
+func (aw *ArchiveWriter) GetEnvironmentSDKData(envID config.EnvironmentID) ([]ldstoretypes.Collection, error) {
+	return (&archiveReader{dirPath: aw.dirPath}).GetEnvironmentSDKData(envID)
+}
+
+// Write creates a compressed archive file containing all of the environments that have been added.
+func (aw *ArchiveWriter) Write(filePath string) error {
+	checksum, err := computeEnvironmentsChecksum(aw.dirPath, aw.environmentIDs)
+	if err != nil {
+		return err // COVERAGE: can't cause this condition in unit tests
+	}
+	if err := os.WriteFile(checksumFilePath(aw.dirPath), checksum, 0600); err != nil {
+		return err
+	}
+	fileNames := []string{environmentsChecksumFileName}
+	for _, envID := range aw.environmentIDs {
+		fileNames = append(fileNames, filepath.Base(envMetadataFilePath(aw.dirPath, envID)),
+			filepath.Base(envSDKDataFilePath(aw.dirPath, envID)))
+	}
+
+	f, err := os.OpenFile(filepath.Clean(filePath), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	gw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gw)
+	for _, name := range fileNames {
+		if err = addFileToTar(tw, aw.dirPath, name); err != nil {
+			break
+		}
+	}
+	for _, closer := range []io.Closer{tw, gw, f} {
+		if closeErr := closer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// Close disposes of the temporary directory that was created by this ArchiveWriter.
+func (aw *ArchiveWriter) Close() {
+	_ = os.RemoveAll(aw.dirPath)
+}
+
+func addFileToTar(tw *tar.Writer, dirPath, name string) error {
+	data, err := os.ReadFile(filepath.Join(dirPath, name))
+	if err != nil {
+		return err // COVERAGE: can't cause this condition in unit tests
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(data))}); err != nil {
+		return err // COVERAGE: can't cause this condition in unit tests
+	}
+	_, err = tw.Write(data)
+	return err
+}
@@ -0,0 +1,100 @@
+package filedata
+
+import (
+	"encoding/json"
+	"sort"
+	"testing"
+
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoreimpl"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoretypes"
+	helpers "github.com/launchdarkly/go-test-helpers/v3"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (te testEnv) serializedSDKData(t *testing.T) []ldstoretypes.SerializedCollection {
+	var ret []ldstoretypes.SerializedCollection
+	for kindName, items := range te.sdkData {
+		kind := ldstoreimpl.Segments()
+		if kindName == "flags" {
+			kind = ldstoreimpl.Features()
+		}
+		coll := ldstoretypes.SerializedCollection{Kind: kind}
+		for key, item := range items {
+			data, err := json.Marshal(item)
+			require.NoError(t, err)
+			coll.Items = append(coll.Items, ldstoretypes.KeyedSerializedItemDescriptor{
+				Key: key, Item: ldstoretypes.SerializedItemDescriptor{SerializedItem: data},
+			})
+		}
+		ret = append(ret, coll)
+	}
+	return ret
+}
+
+func TestWriteArchive(t *testing.T) {
+	helpers.WithTempFile(func(filePath string) {
+		aw, err := NewArchiveWriter()
+		require.NoError(t, err)
+		defer aw.Close()
+		for _, te := range allTestEnvs {
+			require.NoError(t, aw.AddEnvironment(te.rep, te.serializedSDKData(t)))
+		}
+		sdkData, err := aw.GetEnvironmentSDKData(testEnv2.id())
+		require.NoError(t, err)
+		verifyEnvironmentSDKData(t, testEnv2, sdkData)
+
+		require.NoError(t, aw.Write(filePath))
+
+		envs, err := ReadArchive(filePath)
+		require.NoError(t, err)
+		require.Len(t, envs, len(allTestEnvs))
+		sort.Slice(envs, func(i, j int) bool { return envs[i].Params.EnvID < envs[j].Params.EnvID })
+		for i, te := range sortTestEnvs(allTestEnvs) {
+			verifyEnvironmentData(t, te, envs[i])
+		}
+	})
+}
+
+func TestWriteArchiveReplacesEnvironment(t *testing.T) {
+	helpers.WithTempFile(func(filePath string) {
+		aw, err := NewArchiveWriter()
+		require.NoError(t, err)
+		defer aw.Close()
+		require.NoError(t, aw.AddEnvironment(testEnv1.rep, nil))
+		require.NoError(t, aw.AddEnvironment(testEnv1.rep, testEnv1.serializedSDKData(t)))
+		require.NoError(t, aw.Write(filePath))
+
+		envs, err := ReadArchive(filePath)
+		require.NoError(t, err)
+		require.Len(t, envs, 1)
+		verifyEnvironmentData(t, testEnv1, envs[0])
+	})
+}
+
+func TestWriteArchiveWritesPlaceholderForDeletedItem(t *testing.T) {
+	helpers.WithTempFile(func(filePath string) {
+		aw, err := NewArchiveWriter()
+		require.NoError(t, err)
+		defer aw.Close()
+		require.NoError(t, aw.AddEnvironment(testEnv1.rep, []ldstoretypes.SerializedCollection{
+			{Kind: ldstoreimpl.Features(), Items: []ldstoretypes.KeyedSerializedItemDescriptor{
+				{Key: "deletedFlag", Item: ldstoretypes.SerializedItemDescriptor{Version: 3, Deleted: true}},
+			}},
+		}))
+		require.NoError(t, aw.Write(filePath))
+
+		envs, err := ReadArchive(filePath)
+		require.NoError(t, err)
+		require.Len(t, envs, 1)
+		require.Len(t, envs[0].SDKData, 1)
+		assert.Equal(t, []ldstoretypes.KeyedItemDescriptor{{Key: "deletedFlag", Item: ldstoretypes.ItemDescriptor{Version: 3}}},
+			envs[0].SDKData[0].Items)
+	})
+}
+
+func TestReadArchiveFileNotFound(t *testing.T) {
+	_, err := ReadArchive("no-such-file")
+	assert.Error(t, err)
+}
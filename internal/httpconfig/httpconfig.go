@@ -4,6 +4,7 @@ package httpconfig
 import (
 	"errors"
 	"net/http"
+	"strings"
 
 	"github.com/launchdarkly/ld-relay/v8/internal/credential"
 
@@ -14,6 +15,9 @@ import (
 	"github.com/launchdarkly/go-server-sdk/v7/ldhttp"
 	"github.com/launchdarkly/go-server-sdk/v7/ldntlm"
 	"github.com/launchdarkly/go-server-sdk/v7/subsystems"
+
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/plugin/ochttp/propagation/tracecontext"
 )
 
 var (
@@ -89,3 +93,38 @@ func NewHTTPConfig(proxyConfig config.ProxyConfig, authKey credential.SDKCredent
 func (c HTTPConfig) Client() *http.Client {
 	return c.SDKHTTPConfig.CreateHTTPClient()
 }
+
+// WithTracing returns a copy of the HTTPConfig whose HTTP clients, including those used by the SDK, create a
+// client span for each request and send a W3C traceparent header to the LaunchDarkly services. Stream
+// requests are not traced, since their spans would stay open for as long as the stream is connected.
+func (c HTTPConfig) WithTracing() HTTPConfig {
+	baseFactory := c.SDKHTTPConfig.CreateHTTPClient
+	c.SDKHTTPConfig.CreateHTTPClient = func() *http.Client {
+		client := baseFactory()
+		client.Transport = tracingTransport{
+			base:   client.Transport,
+			traced: &ochttp.Transport{Base: client.Transport, Propagation: &tracecontext.HTTPFormat{}},
+		}
+		return client
+	}
+	c.SDKHTTPConfigFactory.HTTPClientFactory(c.SDKHTTPConfig.CreateHTTPClient)
+	return c
+}
+
+// tracingTransport sends polling requests, event posts, and other short requests through the ochttp
+// transport, and stream requests directly through the base transport.
+type tracingTransport struct {
+	base   http.RoundTripper
+	traced http.RoundTripper
+}
+
+func (t tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if strings.Contains(req.Header.Get("Accept"), "text/event-stream") {
+		base := t.base
+		if base == nil {
+			base = http.DefaultTransport // same default as ochttp.Transport
+		}
+		return base.RoundTrip(req)
+	}
+	return t.traced.RoundTrip(req)
+}
@@ -0,0 +1,143 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const accessLogTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// AccessLogMiddleware writes a line to out for each HTTP request, in the NCSA Common Log Format, or the
+// Combined Log Format if combined is true. Unlike RequestLoggerMiddleware, this does not depend on the
+// log level.
+//
+// The standard fields are followed by "duration=" with the response time, and "requestId=" if the
+// request went through RequestIDMiddleware. For streaming responses, a line ending in "stream=open" is
+// written when the stream starts, and a line ending in "stream=closed" with the total bytes and duration
+// is written when it ends.
+//
+// The user field of the standard format shows the last few characters of the Authorization header, if
+// any, rather than the whole credential.
+func AccessLogMiddleware(out io.Writer, combined bool) func(http.Handler) http.Handler {
+	var lock sync.Mutex // ensures that lines from concurrent requests can't be interleaved
+	writeLine := func(line string) {
+		lock.Lock()
+		defer lock.Unlock()
+		_, _ = io.WriteString(out, line)
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			aw := &accessLogResponseWriter{
+				ResponseWriter: w,
+				request:        req,
+				combined:       combined,
+				startTime:      time.Now(),
+				writeLine:      writeLine,
+			}
+			next.ServeHTTP(aw, req)
+			if aw.status == 0 {
+				aw.status = http.StatusOK
+			}
+			aw.log(false)
+		})
+	}
+}
+
+type accessLogResponseWriter struct {
+	http.ResponseWriter
+	request      *http.Request
+	combined     bool
+	startTime    time.Time
+	writeLine    func(string)
+	status       int
+	streaming    bool
+	bytesWritten int64
+}
+
+func (w *accessLogResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+		if strings.Contains(w.Header().Get("Content-Type"), "text/event-stream") {
+			w.streaming = true
+			w.log(true) // for streams, we log the start of the request as well as the end
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *accessLogResponseWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseWriter.Write(data)
+	w.bytesWritten += int64(n)
+	return n, err
+}
+
+func (w *accessLogResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *accessLogResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// log writes an access log line. For a stream, it is called with streamStart=true when the stream starts,
+// and again with streamStart=false when it ends.
+func (w *accessLogResponseWriter) log(streamStart bool) {
+	req := w.request
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	user := "-"
+	if authHeader := req.Header.Get("Authorization"); authHeader != "" {
+		user = strings.ReplaceAll(maskAuthorization(authHeader), " ", "_")
+	}
+	size := "-"
+	if w.bytesWritten > 0 {
+		size = strconv.FormatInt(w.bytesWritten, 10)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s - %s [%s] %s %d %s",
+		host,
+		user,
+		w.startTime.Format(accessLogTimeFormat),
+		quoteAccessLogValue(req.Method+" "+req.URL.RequestURI()+" "+req.Proto),
+		w.status,
+		size,
+	)
+	if w.combined {
+		fmt.Fprintf(&b, " %s %s", quoteAccessLogValue(req.Referer()), quoteAccessLogValue(req.UserAgent()))
+	}
+	if !streamStart {
+		fmt.Fprintf(&b, " duration=%s", time.Since(w.startTime).Round(time.Microsecond))
+	}
+	if id := GetRequestID(req.Context()); id != "" {
+		fmt.Fprintf(&b, " requestId=%s", id)
+	}
+	switch {
+	case streamStart:
+		b.WriteString(" stream=open")
+	case w.streaming:
+		b.WriteString(" stream=closed")
+	}
+	b.WriteString("\n")
+	w.writeLine(b.String())
+}
+
+func quoteAccessLogValue(s string) string {
+	if s == "" {
+		return `"-"`
+	}
+	return strconv.Quote(s)
+}
@@ -0,0 +1,74 @@
+package logging
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func serveWithAccessLog(t *testing.T, combined bool, handler http.HandlerFunc, req *http.Request) []string {
+	var out bytes.Buffer
+	h := RequestIDMiddleware(AccessLogMiddleware(&out, combined)(handler))
+	req.RemoteAddr = "10.0.0.1:5555"
+	req.Header.Set(RequestIDHeader, "my-request")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	return strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+}
+
+func TestAccessLogCommonFormat(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/sdk/latest-all?filter=x", nil)
+	req.Header.Set("Authorization", "sdk-abcdefghij")
+	lines := serveWithAccessLog(t, false, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("abc"))
+	}, req)
+
+	require.Len(t, lines, 1)
+	assert.Regexp(t,
+		`^10\.0\.0\.1 - \*fghij \[\d\d/\w+/\d{4}:\d\d:\d\d:\d\d [+-]\d{4}\] "GET /sdk/latest-all\?filter=x HTTP/1\.1" 200 3 duration=\S+ requestId=my-request$`,
+		lines[0])
+	assert.NotContains(t, lines[0], "sdk-abcdefghij")
+}
+
+func TestAccessLogCombinedFormat(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/sdk/evalx/contexts/xyz", nil)
+	req.Header.Set("User-Agent", `Agent "007"`)
+	lines := serveWithAccessLog(t, true, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}, req)
+
+	require.Len(t, lines, 1)
+	assert.Regexp(t,
+		`^10\.0\.0\.1 - - \[.*\] "GET /sdk/evalx/contexts/xyz HTTP/1\.1" 404 - "-" "Agent \\"007\\"" duration=\S+ requestId=my-request$`,
+		lines[0])
+}
+
+func TestAccessLogStream(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/all", nil)
+	lines := serveWithAccessLog(t, false, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("event: put\n"))
+		w.(http.Flusher).Flush()
+	}, req)
+
+	require.Len(t, lines, 2)
+	assert.Regexp(t, `"GET /all HTTP/1\.1" 200 - requestId=my-request stream=open$`, lines[0])
+	assert.Regexp(t, `"GET /all HTTP/1\.1" 200 11 duration=\S+ requestId=my-request stream=closed$`, lines[1])
+}
+
+func TestAccessLogStreamClosedWithoutData(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/all", nil)
+	lines := serveWithAccessLog(t, false, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+	}, req)
+
+	require.Len(t, lines, 2)
+	assert.Regexp(t, `"GET /all HTTP/1\.1" 200 - requestId=my-request stream=open$`, lines[0])
+	assert.Regexp(t, `"GET /all HTTP/1\.1" 200 - duration=\S+ requestId=my-request stream=closed$`, lines[1])
+}
@@ -0,0 +1,37 @@
+package logging
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Field is a named value to be included in a log message.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// Fields is a list of named values that can be passed, along with the message text, to any of the
+// ldlog.Loggers methods that do not take a format string (Debug, Info, Warn, Error). In JSON log format
+// each field becomes a property of the log record. In text format they are written as "key=value" pairs,
+// in the order they were specified.
+type Fields []Field
+
+// Flag is a Field value for a boolean property. In text format, a true Flag is written as "(key)" rather
+// than "key=true", and a false one is omitted; in JSON format it is a boolean property.
+type Flag bool
+
+// String returns the text representation of the fields.
+func (f Fields) String() string {
+	parts := make([]string, 0, len(f))
+	for _, field := range f {
+		if flag, ok := field.Value.(Flag); ok {
+			if flag {
+				parts = append(parts, "("+field.Key+")")
+			}
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s=%v", field.Key, field.Value))
+	}
+	return strings.Join(parts, " ")
+}
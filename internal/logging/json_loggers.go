@@ -0,0 +1,119 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+)
+
+// MakeJSONLoggers returns a Loggers instance that writes each message as a single-line JSON object.
+// Output goes to stdout, except Error level which goes to stderr. Debug level is disabled.
+//
+// Each record has the properties "time", "level", and "msg". A prefix in square brackets at the start
+// of the message, such as the "[env: ...1234]" prefix of per-environment messages, is turned into a
+// property ("env") rather than being left in the message text. Any Fields passed to the logger are added
+// as properties after those.
+func MakeJSONLoggers() ldlog.Loggers {
+	return makeJSONLoggersWithOutput(os.Stdout, os.Stderr)
+}
+
+func makeJSONLoggersWithOutput(out, errOut io.Writer) ldlog.Loggers {
+	loggers := ldlog.NewDefaultLoggers()
+	outLock, errOutLock := &sync.Mutex{}, &sync.Mutex{}
+	for _, level := range []ldlog.LogLevel{ldlog.Debug, ldlog.Info, ldlog.Warn} {
+		loggers.SetBaseLoggerForLevel(level, jsonLogger{level: level, out: out, lock: outLock})
+	}
+	loggers.SetBaseLoggerForLevel(ldlog.Error, jsonLogger{level: ldlog.Error, out: errOut, lock: errOutLock})
+	loggers.SetMinLevel(ldlog.Info)
+	return loggers
+}
+
+// jsonLogger is an ldlog.BaseLogger for a single level. ldlog.Loggers always adds a "LEVEL:" prefix to
+// the text that it passes to the base logger, so we strip that off and use the level property instead.
+type jsonLogger struct {
+	level ldlog.LogLevel
+	out   io.Writer
+	lock  *sync.Mutex
+}
+
+func (l jsonLogger) Println(values ...interface{}) {
+	var text []string
+	var fields Fields
+	for _, v := range values {
+		if f, ok := v.(Fields); ok {
+			fields = append(fields, f...)
+		} else {
+			text = append(text, fmt.Sprint(v))
+		}
+	}
+	l.write(strings.Join(text, " "), fields)
+}
+
+func (l jsonLogger) Printf(format string, values ...interface{}) {
+	l.write(fmt.Sprintf(format, values...), nil)
+}
+
+func (l jsonLogger) write(text string, fields Fields) {
+	text = strings.TrimSpace(text)
+	text = strings.TrimSpace(strings.TrimPrefix(text, strings.ToUpper(l.level.Name())+":"))
+
+	var prefixFields Fields
+	for strings.HasPrefix(text, "[") {
+		end := strings.Index(text, "]")
+		if end < 0 {
+			break
+		}
+		name, value, ok := strings.Cut(text[1:end], ":")
+		if !ok {
+			break
+		}
+		prefixFields = append(prefixFields, Field{Key: strings.TrimSpace(name), Value: strings.TrimSpace(value)})
+		text = strings.TrimSpace(text[end+1:])
+	}
+	if len(fields) > 0 {
+		// A message like "Request: method=GET" in text format is just "Request" with a method property
+		text = strings.TrimSuffix(text, ":")
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	writeJSONProperty(&buf, "time", time.Now().UTC().Format(time.RFC3339Nano))
+	buf.WriteByte(',')
+	writeJSONProperty(&buf, "level", strings.ToLower(l.level.Name()))
+	for _, f := range prefixFields {
+		buf.WriteByte(',')
+		writeJSONProperty(&buf, f.Key, f.Value)
+	}
+	buf.WriteByte(',')
+	writeJSONProperty(&buf, "msg", text)
+	for _, f := range fields {
+		buf.WriteByte(',')
+		writeJSONProperty(&buf, f.Key, f.Value)
+	}
+	buf.WriteString("}\n")
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	_, _ = l.out.Write(buf.Bytes())
+}
+
+func writeJSONProperty(buf *bytes.Buffer, key string, value interface{}) {
+	keyData, _ := json.Marshal(key)
+	buf.Write(keyData)
+	buf.WriteByte(':')
+	if d, ok := value.(time.Duration); ok {
+		value = float64(d) / float64(time.Millisecond) // durations are always logged in milliseconds
+	}
+	valueData, err := json.Marshal(value)
+	if err != nil {
+		valueData, _ = json.Marshal(fmt.Sprint(value))
+	}
+	buf.Write(valueData)
+}
@@ -0,0 +1,92 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseJSONLogLines(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var ret []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var record map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &record), line)
+		ret = append(ret, record)
+	}
+	return ret
+}
+
+func TestJSONLoggers(t *testing.T) {
+	assert.Equal(t, ldlog.Info, MakeJSONLoggers().GetMinLevel())
+
+	t.Run("formatted message", func(t *testing.T) {
+		var out, errOut bytes.Buffer
+		loggers := makeJSONLoggersWithOutput(&out, &errOut)
+		loggers.Infof("hello %s", "world")
+
+		records := parseJSONLogLines(t, &out)
+		require.Len(t, records, 1)
+		assert.Equal(t, "info", records[0]["level"])
+		assert.Equal(t, "hello world", records[0]["msg"])
+		_, err := time.Parse(time.RFC3339Nano, records[0]["time"].(string))
+		assert.NoError(t, err)
+		assert.Equal(t, 0, errOut.Len())
+	})
+
+	t.Run("errors go to separate output", func(t *testing.T) {
+		var out, errOut bytes.Buffer
+		loggers := makeJSONLoggersWithOutput(&out, &errOut)
+		loggers.Error("oops")
+
+		records := parseJSONLogLines(t, &errOut)
+		require.Len(t, records, 1)
+		assert.Equal(t, "error", records[0]["level"])
+		assert.Equal(t, "oops", records[0]["msg"])
+		assert.Equal(t, 0, out.Len())
+	})
+
+	t.Run("prefix becomes a property", func(t *testing.T) {
+		var out, errOut bytes.Buffer
+		loggers := makeJSONLoggersWithOutput(&out, &errOut)
+		loggers.SetPrefix("[env: ...1234]")
+		loggers.Warnf("something [in brackets]")
+
+		records := parseJSONLogLines(t, &out)
+		require.Len(t, records, 1)
+		assert.Equal(t, "warn", records[0]["level"])
+		assert.Equal(t, "...1234", records[0]["env"])
+		assert.Equal(t, "something [in brackets]", records[0]["msg"])
+	})
+
+	t.Run("fields", func(t *testing.T) {
+		var out, errOut bytes.Buffer
+		loggers := makeJSONLoggersWithOutput(&out, &errOut)
+		loggers.SetMinLevel(ldlog.Debug)
+		loggers.Debug("Request:", Fields{
+			{Key: "status", Value: 200},
+			{Key: "route", Value: "/sdk/latest-all"},
+			{Key: "duration", Value: time.Millisecond * 1500},
+			{Key: "streaming", Value: Flag(true)},
+		})
+
+		assert.Regexp(t, `"msg":"Request","status":200,"route":"/sdk/latest-all","duration":1500,"streaming":true}`, out.String())
+		records := parseJSONLogLines(t, &out)
+		require.Len(t, records, 1)
+		assert.Equal(t, "debug", records[0]["level"])
+	})
+}
+
+func TestFieldsString(t *testing.T) {
+	f := Fields{{Key: "a", Value: 1}, {Key: "b", Value: "x"}}
+	assert.Equal(t, "a=1 b=x", f.String())
+
+	f = Fields{{Key: "a", Value: 1}, {Key: "streaming", Value: Flag(true)}, {Key: "other", Value: Flag(false)}}
+	assert.Equal(t, "a=1 (streaming)", f.String())
+}
@@ -0,0 +1,92 @@
+package logging
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/pborman/uuid"
+)
+
+// RequestIDHeader is the header that a caller can use to specify a request ID, which is then included in
+// Relay's log output for the request. Relay echoes the ID in the same response header, generating a new
+// one if the request did not have one.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength prevents a caller from putting an arbitrarily large value into our logs.
+const maxRequestIDLength = 200
+
+type requestInfoKeyType string
+
+const requestInfoKey requestInfoKeyType = "RequestInfo"
+
+// requestInfo is attached to the request context by RequestIDMiddleware. It is a pointer, so that
+// middleware further down the chain (such as the one that selects an environment) can add fields that
+// will be visible to RequestLoggerMiddleware after the handler returns.
+type requestInfo struct {
+	id     string
+	fields Fields
+	lock   sync.Mutex
+}
+
+// RequestIDMiddleware assigns an ID to each HTTP request. If the request has an X-Request-ID header with
+// a reasonable value, that value is used; otherwise a new random ID is generated. The ID is set in the
+// same response header, and can be obtained from the request context with GetRequestID.
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		id := req.Header.Get(RequestIDHeader)
+		if !isValidRequestID(id) {
+			id = uuid.New()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		info := &requestInfo{id: id}
+		next.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), requestInfoKey, info)))
+	})
+}
+
+// GetRequestID returns the ID that RequestIDMiddleware assigned to this HTTP request, or an empty string
+// if none was assigned.
+func GetRequestID(ctx context.Context) string {
+	if info := getRequestInfo(ctx); info != nil {
+		return info.id
+	}
+	return ""
+}
+
+// AddRequestLogFields adds fields that will be included in the request log message for this HTTP request.
+// It has no effect if the request did not go through RequestIDMiddleware.
+func AddRequestLogFields(ctx context.Context, fields ...Field) {
+	if info := getRequestInfo(ctx); info != nil {
+		info.lock.Lock()
+		info.fields = append(info.fields, fields...)
+		info.lock.Unlock()
+	}
+}
+
+func getRequestLogFields(ctx context.Context) Fields {
+	if info := getRequestInfo(ctx); info != nil {
+		info.lock.Lock()
+		defer info.lock.Unlock()
+		return append(Fields{{Key: "requestId", Value: info.id}}, info.fields...)
+	}
+	return nil
+}
+
+func getRequestInfo(ctx context.Context) *requestInfo {
+	if info, ok := ctx.Value(requestInfoKey).(*requestInfo); ok {
+		return info
+	}
+	return nil
+}
+
+func isValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, ch := range id {
+		if ch <= ' ' || ch > '~' { // printable ASCII without spaces, so it can't break up a text log line
+			return false
+		}
+	}
+	return true
+}
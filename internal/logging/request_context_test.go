@@ -0,0 +1,53 @@
+package logging
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	serveWithRequestID := func(headerValue string) (string, string) {
+		var idInContext string
+		handler := RequestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			idInContext = GetRequestID(r.Context())
+		}))
+		req, _ := http.NewRequest("GET", "/url", nil)
+		if headerValue != "" {
+			req.Header.Set(RequestIDHeader, headerValue)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return idInContext, rr.Result().Header.Get(RequestIDHeader)
+	}
+
+	t.Run("uses ID from request", func(t *testing.T) {
+		idInContext, idInResponse := serveWithRequestID("abc-123")
+		assert.Equal(t, "abc-123", idInContext)
+		assert.Equal(t, "abc-123", idInResponse)
+	})
+
+	t.Run("generates ID if none in request", func(t *testing.T) {
+		idInContext, idInResponse := serveWithRequestID("")
+		assert.NotEqual(t, "", idInContext)
+		assert.Equal(t, idInContext, idInResponse)
+	})
+
+	t.Run("generates ID if request ID is invalid", func(t *testing.T) {
+		for _, badID := range []string{"has spaces", "bad\nchars", strings.Repeat("x", maxRequestIDLength+1)} {
+			idInContext, idInResponse := serveWithRequestID(badID)
+			assert.NotEqual(t, badID, idInContext)
+			assert.Equal(t, idInContext, idInResponse)
+		}
+	})
+}
+
+func TestRequestLogFieldsWithoutRequestID(t *testing.T) {
+	AddRequestLogFields(context.Background(), Field{Key: "a", Value: 1}) // should not panic
+	assert.Equal(t, "", GetRequestID(context.Background()))
+	assert.Nil(t, getRequestLogFields(context.Background()))
+}
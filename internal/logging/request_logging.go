@@ -3,15 +3,28 @@ package logging
 import (
 	"net/http"
 	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
 
 	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
 )
 
 // RequestLoggerMiddleware decorates a Handler with debug-level logging of all requests.
+//
+// If the request went through RequestIDMiddleware, the log messages also include the request ID and any
+// fields that were added with AddRequestLogFields.
 func RequestLoggerMiddleware(loggers ldlog.Loggers) func(http.Handler) http.Handler {
+	return RequestLoggerMiddlewareAtLevel(loggers, ldlog.Debug)
+}
+
+// RequestLoggerMiddlewareAtLevel is the same as RequestLoggerMiddleware, but logs requests at the
+// specified level.
+func RequestLoggerMiddlewareAtLevel(loggers ldlog.Loggers, level ldlog.LogLevel) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-			wrappedWriter := loggingHTTPResponseWriter{loggers: loggers, writer: w, request: req}
+			wrappedWriter := loggingHTTPResponseWriter{logger: loggers.ForLevel(level), writer: w, request: req,
+				startTime: time.Now()}
 			next.ServeHTTP(&wrappedWriter, req)
 			wrappedWriter.logRequest()
 		})
@@ -19,12 +32,13 @@ func RequestLoggerMiddleware(loggers ldlog.Loggers) func(http.Handler) http.Hand
 }
 
 type loggingHTTPResponseWriter struct {
-	loggers      ldlog.Loggers
+	logger       ldlog.BaseLogger
 	writer       http.ResponseWriter
 	request      *http.Request
 	statusCode   int
 	streaming    bool
 	bytesWritten uint64
+	startTime    time.Time
 }
 
 func (w *loggingHTTPResponseWriter) Header() http.Header {
@@ -51,38 +65,54 @@ func (w *loggingHTTPResponseWriter) WriteHeader(statusCode int) {
 func (w *loggingHTTPResponseWriter) logRequest() {
 	authStr := "n/a"
 	if authHeader := w.request.Header.Get("Authorization"); authHeader != "" {
-		if len(authHeader) > 5 {
-			authStr = "*" + authHeader[len(authHeader)-5:]
-		} else {
-			authStr = authHeader
-		}
+		authStr = maskAuthorization(authHeader)
+	}
+	var route string
+	if r := mux.CurrentRoute(w.request); r != nil {
+		route, _ = r.GetPathTemplate()
+	}
+	extraFields := getRequestLogFields(w.request.Context())
+	if route != "" {
+		extraFields = append(extraFields, Field{Key: "route", Value: route})
 	}
 	if w.streaming {
 		if w.bytesWritten == 0 {
 			// starting stream
-			w.loggers.Debugf("Request: method=%s url=%s auth=%s status=%d (streaming)",
-				w.request.Method,
-				w.request.URL,
-				authStr,
-				w.statusCode,
-			)
+			w.logger.Println("Request:", append(Fields{
+				{Key: "method", Value: w.request.Method},
+				{Key: "url", Value: w.request.URL.String()},
+				{Key: "auth", Value: authStr},
+				{Key: "status", Value: w.statusCode},
+				{Key: "streaming", Value: Flag(true)},
+			}, extraFields...))
 		} else {
 			// ending stream
-			w.loggers.Debugf("Stream closed: url=%s auth=%s bytes=%d",
-				w.request.URL,
-				authStr,
-				w.bytesWritten,
-			)
+			w.logger.Println("Stream closed:", append(Fields{
+				{Key: "url", Value: w.request.URL.String()},
+				{Key: "auth", Value: authStr},
+				{Key: "bytes", Value: w.bytesWritten},
+				{Key: "duration", Value: time.Since(w.startTime)},
+			}, extraFields...))
 		}
 	} else {
-		w.loggers.Debugf("Request: method=%s url=%s auth=%s status=%d bytes=%d",
-			w.request.Method,
-			w.request.URL,
-			authStr,
-			w.statusCode,
-			w.bytesWritten,
-		)
+		w.logger.Println("Request:", append(Fields{
+			{Key: "method", Value: w.request.Method},
+			{Key: "url", Value: w.request.URL.String()},
+			{Key: "auth", Value: authStr},
+			{Key: "status", Value: w.statusCode},
+			{Key: "bytes", Value: w.bytesWritten},
+			{Key: "duration", Value: time.Since(w.startTime)},
+		}, extraFields...))
+	}
+}
+
+// maskAuthorization returns just enough of an Authorization header value to distinguish between
+// credentials in log output, without revealing the credential.
+func maskAuthorization(authHeader string) string {
+	if len(authHeader) > 5 {
+		return "*" + authHeader[len(authHeader)-5:]
 	}
+	return authHeader
 }
 
 // In order to substitute loggingHTTPResponseWriter for the default http.ResponseWriter,
@@ -93,3 +123,9 @@ func (w *loggingHTTPResponseWriter) Flush() {
 		f.Flush()
 	}
 }
+
+// Unwrap allows http.ResponseController to reach the underlying ResponseWriter, so that stream write
+// deadlines still work when request logging is enabled.
+func (w *loggingHTTPResponseWriter) Unwrap() http.ResponseWriter {
+	return w.writer
+}
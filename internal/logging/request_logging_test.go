@@ -69,3 +69,34 @@ func TestRequestLoggerMiddlewareAuth(t *testing.T) {
 	mockLog.AssertMessageMatch(t, true, ldlog.Debug, "Request: method=GET url=/url auth=\\*fghij status=200 bytes=3")
 	mockLog.AssertMessageMatch(t, true, ldlog.Debug, "Request: method=GET url=/url auth=abcd status=200 bytes=3")
 }
+
+func TestRequestLoggerMiddlewareIncludesRequestFields(t *testing.T) {
+	mockLog := ldlogtest.NewMockLog()
+	mockLog.Loggers.SetMinLevel(ldlog.Debug)
+	handler := RequestIDMiddleware(RequestLoggerMiddleware(mockLog.Loggers)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		AddRequestLogFields(r.Context(), Field{Key: "envName", Value: "production"})
+		w.WriteHeader(200)
+	})))
+
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/url", nil)
+	req.Header.Set(RequestIDHeader, "my-request")
+	handler.ServeHTTP(rr, req)
+
+	mockLog.AssertMessageMatch(t, true, ldlog.Debug,
+		"Request: method=GET url=/url auth=n/a status=200 bytes=0 duration=\\S+ requestId=my-request envName=production")
+}
+
+func TestRequestLoggerMiddlewareAtLevel(t *testing.T) {
+	mockLog := ldlogtest.NewMockLog()
+	handler := RequestLoggerMiddlewareAtLevel(mockLog.Loggers, ldlog.Info)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(200)
+	}))
+
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/url", nil)
+	handler.ServeHTTP(rr, req)
+
+	mockLog.AssertMessageMatch(t, true, ldlog.Info, "Request: method=GET url=/url auth=n/a status=200 bytes=0")
+	assert.Len(t, mockLog.GetOutput(ldlog.Debug), 0)
+}
@@ -0,0 +1,65 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readFileOrEmpty(path string) string {
+	data, _ := os.ReadFile(path)
+	return string(data)
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	require.NoError(t, os.WriteFile(path, []byte("old\n"), 0o600))
+
+	f, err := NewRotatingFile(path, 10, 2)
+	require.NoError(t, err)
+	defer f.Close()
+
+	for _, line := range []string{"aaaa\n", "bbbb\n", "cccc\n", "dddd\n", "eeee\n"} {
+		_, err := f.Write([]byte(line))
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, "dddd\neeee\n", readFileOrEmpty(path))
+	assert.Equal(t, "bbbb\ncccc\n", readFileOrEmpty(path+".1"))
+	assert.Equal(t, "old\naaaa\n", readFileOrEmpty(path+".2"))
+
+	_, err = f.Write([]byte("ffff\n"))
+	require.NoError(t, err)
+
+	assert.Equal(t, "ffff\n", readFileOrEmpty(path))
+	assert.Equal(t, "dddd\neeee\n", readFileOrEmpty(path+".1"))
+	assert.Equal(t, "bbbb\ncccc\n", readFileOrEmpty(path+".2"))
+	assert.NoFileExists(t, path+".3") // the oldest backup was discarded
+}
+
+func TestRotatingFileWithNoBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	f, err := NewRotatingFile(path, 5, 0)
+	require.NoError(t, err)
+	defer f.Close()
+
+	_, _ = f.Write([]byte("aaaa\n"))
+	_, _ = f.Write([]byte("bbbb\n"))
+
+	assert.Equal(t, "bbbb\n", readFileOrEmpty(path))
+	assert.NoFileExists(t, path+".1")
+}
+
+func TestRotatingFileWriteAfterClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	f, err := NewRotatingFile(path, 100, 1)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	require.NoError(t, f.Close())
+
+	_, err = f.Write([]byte("x"))
+	assert.ErrorIs(t, err, os.ErrClosed)
+}
@@ -13,6 +13,9 @@ const (
 	mobileTagValue  = "mobile"
 	serverTagValue  = "server"
 
+	missingItemsTagValue = "missing"
+	staleItemsTagValue   = "stale"
+
 	connMeasureName        = "connections"
 	privateConnMeasureName = "internal_connections"
 
@@ -23,6 +26,24 @@ const (
 
 	requestMeasureName = "requests"
 
+	slowStreamConsumersMeasureName = "slow_stream_consumers"
+
+	requestDurationMeasureName    = "request_duration"
+	storeReadDurationMeasureName  = "store_read_duration"
+	evaluationDurationMeasureName = "evaluation_duration"
+
+	eventsReceivedMeasureName         = "events_received"
+	eventsForwardedMeasureName        = "events_forwarded"
+	eventPayloadsForwardedMeasureName = "event_payloads_forwarded"
+	eventsDroppedMeasureName          = "events_dropped"
+	eventSendFailuresMeasureName      = "event_send_failures"
+	eventSendRetriesMeasureName       = "event_send_retries"
+	eventQueueDepthMeasureName        = "event_queue_depth"
+
+	tlsCertificateExpiryMeasureName = "tls_certificate_expiry"
+
+	storeInconsistentItemsMeasureName = "store_inconsistent_items"
+
 	defaultFlushInterval = time.Minute
 )
 
@@ -33,7 +54,15 @@ var (
 	routeTagKey, _            = tag.NewKey("route")            //nolint:gochecknoglobals
 	methodTagKey, _           = tag.NewKey("method")           //nolint:gochecknoglobals
 	envNameTagKey, _          = tag.NewKey("env")              //nolint:gochecknoglobals
+	reasonTagKey, _           = tag.NewKey("reason")           //nolint:gochecknoglobals
+	statusCodeTagKey, _       = tag.NewKey("statusCode")       //nolint:gochecknoglobals
+	dataKindTagKey, _         = tag.NewKey("dataKind")         //nolint:gochecknoglobals
 
 	publicTags  = []tag.Key{platformCategoryTagKey, userAgentTagKey, envNameTagKey}                //nolint:gochecknoglobals
 	privateTags = []tag.Key{platformCategoryTagKey, userAgentTagKey, relayIDTagKey, envNameTagKey} //nolint:gochecknoglobals
+
+	// Bucket boundaries, in milliseconds, for all of the duration measures. These range from the time it
+	// takes to read from an in-memory cache to the time it might take to query a slow database.
+	durationBucketsMillis = []float64{ //nolint:gochecknoglobals
+		0.1, 0.25, 0.5, 1, 2.5, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000}
 )
@@ -2,9 +2,14 @@ package metrics
 
 import (
 	"context"
+	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/launchdarkly/ld-relay/v8/internal/basictypes"
 	"github.com/launchdarkly/ld-relay/v8/internal/logging"
 
+	"go.opencensus.io/plugin/ochttp/propagation/tracecontext"
 	"go.opencensus.io/stats"
 	"go.opencensus.io/tag"
 	"go.opencensus.io/trace"
@@ -23,37 +28,80 @@ var (
 	newConnMeasure = stats.Int64(newConnMeasureName, "total number of connections", stats.UnitDimensionless)
 	requestMeasure = stats.Int64(requestMeasureName, "Number of hits to a route", stats.UnitDimensionless)
 
+	slowStreamConsumersMeasure = stats.Int64(slowStreamConsumersMeasureName,
+		"number of stream connections closed because the client was not keeping up", stats.UnitDimensionless)
+
+	requestDurationMeasure = stats.Float64(requestDurationMeasureName,
+		"time taken to respond to a request", stats.UnitMilliseconds)
+	storeReadDurationMeasure = stats.Float64(storeReadDurationMeasureName,
+		"time taken to read all items of one kind from the data store", stats.UnitMilliseconds)
+	evaluationDurationMeasure = stats.Float64(evaluationDurationMeasureName,
+		"time taken to evaluate all flags for one client-side request", stats.UnitMilliseconds)
+
+	eventsReceivedMeasure = stats.Int64(eventsReceivedMeasureName,
+		"number of analytics events received from SDKs", stats.UnitDimensionless)
+	eventsForwardedMeasure = stats.Int64(eventsForwardedMeasureName,
+		"number of analytics events delivered to LaunchDarkly", stats.UnitDimensionless)
+	eventPayloadsForwardedMeasure = stats.Int64(eventPayloadsForwardedMeasureName,
+		"number of event payloads delivered to LaunchDarkly", stats.UnitDimensionless)
+	eventsDroppedMeasure = stats.Int64(eventsDroppedMeasureName,
+		"number of analytics events discarded because the queue was full or sending was disabled", stats.UnitDimensionless)
+	eventSendFailuresMeasure = stats.Int64(eventSendFailuresMeasureName,
+		"number of failed attempts to deliver an event payload", stats.UnitDimensionless)
+	eventSendRetriesMeasure = stats.Int64(eventSendRetriesMeasureName,
+		"number of retried attempts to deliver an event payload", stats.UnitDimensionless)
+	eventQueueDepthMeasure = stats.Int64(eventQueueDepthMeasureName,
+		"number of analytics events waiting to be delivered", stats.UnitDimensionless)
+
+	tlsCertificateExpiryMeasure = stats.Int64(tlsCertificateExpiryMeasureName,
+		"expiration time of the TLS server certificate, in seconds since the Unix epoch", stats.UnitSeconds)
+
+	storeInconsistentItemsMeasure = stats.Int64(storeInconsistentItemsMeasureName,
+		"number of flags and segments that were missing or out of date in the persistent data store", stats.UnitDimensionless)
+
 	// For internal event exporter
 	privateConnMeasure            = stats.Int64(privateConnMeasureName, "current number of connections", stats.UnitDimensionless)
 	privateNewConnMeasure         = stats.Int64(privateNewConnMeasureName, "total number of connections", stats.UnitDimensionless)
 	privatePollingRequestsMeasure = stats.Int64(privatePollingRequestsMeasureName, "total number of polling requests made", stats.UnitDimensionless)
 
 	// BrowserConns is a Measure representing the current number of active stream connections from browsers.
-	BrowserConns = Measure{measures: []*stats.Int64Measure{connMeasure, privateConnMeasure}, tags: makeBrowserTags()}
+	BrowserConns = Measure{measures: []*stats.Int64Measure{connMeasure, privateConnMeasure}, tags: makeBrowserTags(), native: nativeConnections}
 
 	// MobileConns is a Measure representing the current number of active stream connections from mobile SDKs.
-	MobileConns = Measure{measures: []*stats.Int64Measure{connMeasure, privateConnMeasure}, tags: makeMobileTags()}
+	MobileConns = Measure{measures: []*stats.Int64Measure{connMeasure, privateConnMeasure}, tags: makeMobileTags(), native: nativeConnections}
 
 	// ServerConns is a is a Measure representing the current number of active stream connections from server-side SDKs.
-	ServerConns = Measure{measures: []*stats.Int64Measure{connMeasure, privateConnMeasure}, tags: makeServerTags()}
+	ServerConns = Measure{measures: []*stats.Int64Measure{connMeasure, privateConnMeasure}, tags: makeServerTags(), native: nativeConnections}
 
 	// NewBrowserConns is a Measure representing the cumulative number of stream connections from browsers.
-	NewBrowserConns = Measure{measures: []*stats.Int64Measure{newConnMeasure, privateNewConnMeasure}, tags: makeBrowserTags()}
+	NewBrowserConns = Measure{measures: []*stats.Int64Measure{newConnMeasure, privateNewConnMeasure}, tags: makeBrowserTags(), native: nativeNewConnections}
 
 	// NewMobileConns is a Measure representing the cumulative number of stream connections from mobile SDKs.
-	NewMobileConns = Measure{measures: []*stats.Int64Measure{newConnMeasure, privateNewConnMeasure}, tags: makeMobileTags()}
+	NewMobileConns = Measure{measures: []*stats.Int64Measure{newConnMeasure, privateNewConnMeasure}, tags: makeMobileTags(), native: nativeNewConnections}
 
 	// NewServerConns is a Measure representing the cumulative number of stream connections from server-side SDKs.
-	NewServerConns = Measure{measures: []*stats.Int64Measure{newConnMeasure, privateNewConnMeasure}, tags: makeServerTags()}
+	NewServerConns = Measure{measures: []*stats.Int64Measure{newConnMeasure, privateNewConnMeasure}, tags: makeServerTags(), native: nativeNewConnections}
 
 	// BrowserRequests is a Measure representing the number of HTTP requests from browsers.
-	BrowserRequests = Measure{measures: []*stats.Int64Measure{requestMeasure}, tags: makeBrowserTags()}
+	BrowserRequests = Measure{measures: []*stats.Int64Measure{requestMeasure}, tags: makeBrowserTags(), native: nativeRequests}
 
 	// MobileRequests is a Measure representing the number of HTTP requests from mobile SDKs.
-	MobileRequests = Measure{measures: []*stats.Int64Measure{requestMeasure}, tags: makeMobileTags()}
+	MobileRequests = Measure{measures: []*stats.Int64Measure{requestMeasure}, tags: makeMobileTags(), native: nativeRequests}
 
 	// ServerRequests is a Measure representing the number of HTTP requests from server-side SDKs.
-	ServerRequests = Measure{measures: []*stats.Int64Measure{requestMeasure}, tags: makeServerTags()}
+	ServerRequests = Measure{measures: []*stats.Int64Measure{requestMeasure}, tags: makeServerTags(), native: nativeRequests}
+
+	// BrowserSlowStreamConsumers is a Measure representing the number of browser stream connections that
+	// were closed because the client was not keeping up.
+	BrowserSlowStreamConsumers = Measure{measures: []*stats.Int64Measure{slowStreamConsumersMeasure}, tags: makeBrowserTags()}
+
+	// MobileSlowStreamConsumers is a Measure representing the number of mobile stream connections that
+	// were closed because the client was not keeping up.
+	MobileSlowStreamConsumers = Measure{measures: []*stats.Int64Measure{slowStreamConsumersMeasure}, tags: makeMobileTags()}
+
+	// ServerSlowStreamConsumers is a Measure representing the number of server-side stream connections that
+	// were closed because the client was not keeping up.
+	ServerSlowStreamConsumers = Measure{measures: []*stats.Int64Measure{slowStreamConsumersMeasure}, tags: makeServerTags()}
 
 	// PollingRequests is a Measure representing the total number of polling style requests received from server-side SDKs.
 	PollingRequests = Measure{measures: []*stats.Int64Measure{privatePollingRequestsMeasure}, tags: makeServerTags()}
@@ -63,6 +111,7 @@ var (
 type Measure struct {
 	measures []*stats.Int64Measure
 	tags     []tag.Mutator
+	native   *nativeMetric
 }
 
 func makeBrowserTags() []tag.Mutator {
@@ -77,6 +126,17 @@ func makeServerTags() []tag.Mutator {
 	return []tag.Mutator{tag.Insert(platformCategoryTagKey, serverTagValue)}
 }
 
+func makeTagsForSDKKind(sdkKind basictypes.SDKKind) []tag.Mutator {
+	switch sdkKind {
+	case basictypes.JSClientSDK:
+		return makeBrowserTags()
+	case basictypes.MobileSDK:
+		return makeMobileTags()
+	default:
+		return makeServerTags()
+	}
+}
+
 // WithGauge increments the specified metric before running the function and then decrements it (for use with
 // the active connection metrics).
 func WithGauge(ctx context.Context, userAgent string, f func(), measure Measure) {
@@ -89,6 +149,11 @@ func WithGauge(ctx context.Context, userAgent string, f func(), measure Measure)
 			stats.Record(ctx, m.M(1))
 			defer stats.Record(ctx, m.M(-1))
 		}
+		if nativeMetricsEnabled.Load() { // so the decrement can't happen without the increment
+			ctx, _ := tag.New(ctx, measure.tags...)
+			measure.native.record(ctx, 1)
+			defer measure.native.record(ctx, -1)
+		}
 	}
 	f()
 }
@@ -103,6 +168,8 @@ func WithCount(ctx context.Context, userAgent string, f func(), measure Measure)
 			ctx, _ := tag.New(ctx, measure.tags...)
 			stats.Record(ctx, m.M(1))
 		}
+		ctx, _ := tag.New(ctx, measure.tags...)
+		measure.native.record(ctx, 1)
 	}
 	f()
 }
@@ -115,8 +182,137 @@ func WithRouteCount(ctx context.Context, userAgent, route, method string, f func
 	} else {
 		ctx = tagCtx
 	}
-	ctx, span := trace.StartSpan(ctx, route)
+	var span *trace.Span
+	if parent, ok := ctx.Value(remoteSpanContextKey{}).(trace.SpanContext); ok {
+		ctx, span = trace.StartSpanWithRemoteParent(ctx, route, parent, trace.WithSpanKind(trace.SpanKindServer))
+	} else {
+		ctx, span = trace.StartSpan(ctx, route, trace.WithSpanKind(trace.SpanKindServer))
+	}
+	span.AddAttributes(trace.StringAttribute("http.method", method), trace.StringAttribute("http.route", route))
 	defer span.End()
 
 	WithCount(ctx, userAgent, f, measure)
 }
+
+// RecordRequestDuration records how long it took to respond to a request, tagged with the route, method, and
+// status code, and with the SDK kind of the specified Measure (such as ServerRequests).
+func RecordRequestDuration(ctx context.Context, route, method string, statusCode int, duration time.Duration,
+	measure Measure) {
+	mutators := append([]tag.Mutator{
+		tag.Insert(routeTagKey, sanitizeTagValue(route)),
+		tag.Insert(methodTagKey, sanitizeTagValue(method)),
+		tag.Insert(statusCodeTagKey, strconv.Itoa(statusCode)),
+	}, measure.tags...)
+	recordDuration(ctx, requestDurationMeasure, duration, mutators...)
+	if ctx, err := tag.New(ctx, mutators...); err == nil {
+		nativeRequestDuration.record(ctx, float64(duration)/float64(time.Millisecond))
+	}
+}
+
+// RecordStoreReadDuration records how long it took to read all items of one kind, such as "features", from
+// the data store.
+func RecordStoreReadDuration(ctx context.Context, dataKind string, duration time.Duration) {
+	recordDuration(ctx, storeReadDurationMeasure, duration, tag.Insert(dataKindTagKey, sanitizeTagValue(dataKind)))
+}
+
+// RecordEvaluationDuration records how long it took to evaluate all flags for one client-side request. This
+// does not include the time taken to read the flags from the data store, which is recorded separately with
+// RecordStoreReadDuration.
+func RecordEvaluationDuration(ctx context.Context, sdkKind basictypes.SDKKind, duration time.Duration) {
+	recordDuration(ctx, evaluationDurationMeasure, duration, makeTagsForSDKKind(sdkKind)...)
+}
+
+func recordDuration(ctx context.Context, measure *stats.Float64Measure, duration time.Duration, mutators ...tag.Mutator) {
+	ctx, err := tag.New(ctx, mutators...)
+	if err != nil { // COVERAGE: can't make this happen in unit tests
+		logging.GetGlobalContextLoggers(ctx).Errorf(`Failed to create tags: %s`, err)
+		return
+	}
+	stats.Record(ctx, measure.M(float64(duration)/float64(time.Millisecond)))
+}
+
+// RecordEventsReceived records the number of analytics events that an SDK posted to Relay.
+func RecordEventsReceived(ctx context.Context, sdkKind basictypes.SDKKind, count int) {
+	recordInt64(ctx, eventsReceivedMeasure, int64(count), makeTagsForSDKKind(sdkKind)...)
+}
+
+// RecordEventsForwarded records that a payload of the specified number of events was delivered to LaunchDarkly.
+func RecordEventsForwarded(ctx context.Context, count int) {
+	recordInt64(ctx, eventPayloadsForwardedMeasure, 1)
+	recordInt64(ctx, eventsForwardedMeasure, int64(count))
+}
+
+// RecordEventsDropped records the number of analytics events that Relay discarded without delivering them.
+func RecordEventsDropped(ctx context.Context, count int) {
+	recordInt64(ctx, eventsDroppedMeasure, int64(count))
+}
+
+// RecordEventSendFailure records a failed attempt to deliver an event payload. The status key is an HTTP
+// status code, or a description of the error if there was no response.
+func RecordEventSendFailure(ctx context.Context, statusKey string) {
+	recordInt64(ctx, eventSendFailuresMeasure, 1, tag.Insert(statusCodeTagKey, sanitizeTagValue(statusKey)))
+}
+
+// RecordEventSendRetry records that an event payload was sent again after a failure with the specified
+// status key.
+func RecordEventSendRetry(ctx context.Context, statusKey string) {
+	recordInt64(ctx, eventSendRetriesMeasure, 1, tag.Insert(statusCodeTagKey, sanitizeTagValue(statusKey)))
+}
+
+// RecordEventQueueDepth records the current number of analytics events waiting to be delivered.
+func RecordEventQueueDepth(ctx context.Context, depth int) {
+	recordInt64(ctx, eventQueueDepthMeasure, int64(depth))
+}
+
+// RecordTLSCertificateExpiry records the expiration time of the TLS server certificate that is currently in use.
+func RecordTLSCertificateExpiry(ctx context.Context, expiry time.Time) {
+	recordInt64(ctx, tlsCertificateExpiryMeasure, expiry.Unix())
+}
+
+// RecordStoreInconsistentItems records the result of the last consistency check of the persistent data
+// store: the number of items that were missing from it, and the number that had an older version than
+// the one Relay received.
+func RecordStoreInconsistentItems(ctx context.Context, missing, stale int) {
+	recordInt64(ctx, storeInconsistentItemsMeasure, int64(missing), tag.Insert(reasonTagKey, missingItemsTagValue))
+	recordInt64(ctx, storeInconsistentItemsMeasure, int64(stale), tag.Insert(reasonTagKey, staleItemsTagValue))
+}
+
+func recordInt64(ctx context.Context, measure *stats.Int64Measure, value int64, mutators ...tag.Mutator) {
+	if len(mutators) > 0 {
+		var err error
+		if ctx, err = tag.New(ctx, mutators...); err != nil { // COVERAGE: can't make this happen in unit tests
+			logging.GetGlobalContextLoggers(ctx).Errorf(`Failed to create tags: %s`, err)
+			return
+		}
+	}
+	stats.Record(ctx, measure.M(value))
+}
+
+type remoteSpanContextKey struct{}
+
+// WithRequestTraceContext returns a context that carries the W3C trace context, if any, from the headers
+// of an incoming request, so that the span started by WithRouteCount will be a child of the caller's span.
+func WithRequestTraceContext(ctx context.Context, req *http.Request) context.Context {
+	format := tracecontext.HTTPFormat{}
+	if parent, ok := format.SpanContextFromRequest(req); ok {
+		return context.WithValue(ctx, remoteSpanContextKey{}, parent)
+	}
+	return ctx
+}
+
+// RecordWithReason records a single-unit increment for the specified metric, tagged with a reason string
+// (for instance, why a stream connection was closed).
+func RecordWithReason(ctx context.Context, userAgent, reason string, measure Measure) {
+	ctx, err := tag.New(ctx,
+		tag.Insert(userAgentTagKey, sanitizeTagValue(userAgent)),
+		tag.Insert(reasonTagKey, sanitizeTagValue(reason)),
+	)
+	if err != nil { // COVERAGE: can't make this happen in unit tests
+		logging.GetGlobalContextLoggers(ctx).Errorf(`Failed to create tags: %s`, err)
+		return
+	}
+	for _, m := range measure.measures {
+		ctx, _ := tag.New(ctx, measure.tags...)
+		stats.Record(ctx, m.M(1))
+	}
+}
@@ -107,6 +107,12 @@ func (m *Manager) Close() {
 	})
 }
 
+// GetOpenCensusContext returns the Context that should be used for OpenCensus operations that are not
+// related to any particular environment.
+func (m *Manager) GetOpenCensusContext() context.Context {
+	return m.openCensusCtx
+}
+
 // AddEnvironment creates a new EnvironmentManager with its own OpenCensus context that includes
 // a tag for the environment name, and registers its exporter.
 func (m *Manager) AddEnvironment(envName string, publisher events.EventPublisher) (*EnvironmentManager, error) {
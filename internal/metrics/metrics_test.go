@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/launchdarkly/ld-relay/v8/config"
+	"github.com/launchdarkly/ld-relay/v8/internal/basictypes"
 	st "github.com/launchdarkly/ld-relay/v8/internal/sharedtest"
 
 	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
@@ -187,6 +188,115 @@ func TestWithRouteCount(t *testing.T) {
 	})
 }
 
+func TestRecordRequestDuration(t *testing.T) {
+	testWithExporter(t, func(p testWithExporterParams) {
+		RecordRequestDuration(p.env.GetOpenCensusContext(), "someRoute", "GET", 404, time.Millisecond, MobileRequests)
+		p.exporter.AwaitData(t, time.Second, p.mockLog.Loggers, func(d st.TestMetricsData) bool {
+			return d.HasRow(requestDurationView.Name, st.TestMetricsRow{
+				Tags: map[string]string{
+					"env":              p.envName,
+					"method":           "GET",
+					"platformCategory": "mobile",
+					"route":            "someRoute",
+					"statusCode":       "404",
+				},
+				Count: 1,
+			})
+		})
+	})
+}
+
+func TestRecordStoreReadDuration(t *testing.T) {
+	testWithExporter(t, func(p testWithExporterParams) {
+		RecordStoreReadDuration(p.env.GetOpenCensusContext(), "features", time.Millisecond)
+		RecordStoreReadDuration(p.env.GetOpenCensusContext(), "features", time.Millisecond*2)
+		p.exporter.AwaitData(t, time.Second, p.mockLog.Loggers, func(d st.TestMetricsData) bool {
+			return d.HasRow(storeReadDurationView.Name, st.TestMetricsRow{
+				Tags:  map[string]string{"env": p.envName, "dataKind": "features"},
+				Count: 2,
+			})
+		})
+	})
+}
+
+func TestRecordEvaluationDuration(t *testing.T) {
+	for _, tt := range []struct {
+		sdkKind  basictypes.SDKKind
+		category string
+	}{
+		{basictypes.JSClientSDK, "browser"},
+		{basictypes.MobileSDK, "mobile"},
+		{basictypes.ServerSDK, "server"},
+	} {
+		t.Run(string(tt.sdkKind), func(t *testing.T) {
+			testWithExporter(t, func(p testWithExporterParams) {
+				RecordEvaluationDuration(p.env.GetOpenCensusContext(), tt.sdkKind, time.Millisecond)
+				p.exporter.AwaitData(t, time.Second, p.mockLog.Loggers, func(d st.TestMetricsData) bool {
+					return d.HasRow(evaluationDurationView.Name, st.TestMetricsRow{
+						Tags:  map[string]string{"env": p.envName, "platformCategory": tt.category},
+						Count: 1,
+					})
+				})
+			})
+		})
+	}
+}
+
+func TestRecordEventPipelineMetrics(t *testing.T) {
+	testWithExporter(t, func(p testWithExporterParams) {
+		ctx := p.env.GetOpenCensusContext()
+		RecordEventsReceived(ctx, basictypes.MobileSDK, 3)
+		RecordEventsForwarded(ctx, 2)
+		RecordEventsDropped(ctx, 1)
+		RecordEventSendFailure(ctx, "503")
+		RecordEventSendRetry(ctx, "503")
+		RecordEventQueueDepth(ctx, 4)
+		envTags := map[string]string{"env": p.envName}
+		statusTags := map[string]string{"env": p.envName, "statusCode": "503"}
+		p.exporter.AwaitData(t, time.Second, p.mockLog.Loggers, func(d st.TestMetricsData) bool {
+			return d.HasRow(eventsReceivedView.Name, st.TestMetricsRow{
+				Tags: map[string]string{"env": p.envName, "platformCategory": "mobile"},
+				Sum:  3,
+			}) &&
+				d.HasRow(eventsForwardedView.Name, st.TestMetricsRow{Tags: envTags, Sum: 2}) &&
+				d.HasRow(eventPayloadsForwardedView.Name, st.TestMetricsRow{Tags: envTags, Sum: 1}) &&
+				d.HasRow(eventsDroppedView.Name, st.TestMetricsRow{Tags: envTags, Sum: 1}) &&
+				d.HasRow(eventSendFailuresView.Name, st.TestMetricsRow{Tags: statusTags, Sum: 1}) &&
+				d.HasRow(eventSendRetriesView.Name, st.TestMetricsRow{Tags: statusTags, Sum: 1}) &&
+				d.HasRow(eventQueueDepthView.Name, st.TestMetricsRow{Tags: envTags, Sum: 4})
+		})
+	})
+}
+
+func TestRecordTLSCertificateExpiry(t *testing.T) {
+	testWithExporter(t, func(p testWithExporterParams) {
+		expiry := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+		RecordTLSCertificateExpiry(p.env.GetOpenCensusContext(), expiry)
+		p.exporter.AwaitData(t, time.Second, p.mockLog.Loggers, func(d st.TestMetricsData) bool {
+			return d.HasRow(tlsCertificateExpiryView.Name, st.TestMetricsRow{
+				Tags: map[string]string{},
+				Sum:  float64(expiry.Unix()),
+			})
+		})
+	})
+}
+
+func TestRecordStoreInconsistentItems(t *testing.T) {
+	testWithExporter(t, func(p testWithExporterParams) {
+		RecordStoreInconsistentItems(p.env.GetOpenCensusContext(), 2, 1)
+		p.exporter.AwaitData(t, time.Second, p.mockLog.Loggers, func(d st.TestMetricsData) bool {
+			return d.HasRow(storeInconsistentItemsView.Name, st.TestMetricsRow{
+				Tags: map[string]string{"env": p.envName, "reason": "missing"},
+				Sum:  2,
+			}) &&
+				d.HasRow(storeInconsistentItemsView.Name, st.TestMetricsRow{
+					Tags: map[string]string{"env": p.envName, "reason": "stale"},
+					Sum:  1,
+				})
+		})
+	})
+}
+
 func TestSanitizeTagValue(t *testing.T) {
 	assert.Equal(t, "abc", sanitizeTagValue("abc"))
 	assert.Equal(t, "_", sanitizeTagValue(""))
@@ -0,0 +1,486 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v8/config"
+	"github.com/launchdarkly/ld-relay/v8/relay/version"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"go.opencensus.io/trace"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+// The OTLP exporter sends our OpenCensus metrics and traces to an OpenTelemetry collector. OpenCensus
+// gives us the latest data for each view at its reporting interval, and each sampled span as it ends; we
+// convert these to the OTLP data model and send them in batches.
+
+const (
+	otlpServiceName     = "ld-relay"
+	otlpFlushInterval   = time.Second * 10
+	otlpSendTimeout     = time.Second * 10
+	otlpMaxQueuedSpans  = 2048
+	otlpHTTPMetricsPath = "/v1/metrics"
+	otlpHTTPTracesPath  = "/v1/traces"
+)
+
+func errOTLPBadHeader(header string) error {
+	return fmt.Errorf("OTLP header %q must be in the format \"name:value\"", header)
+}
+
+func errOTLPHTTPStatus(status int) error {
+	return fmt.Errorf("OTLP collector returned HTTP status %d", status)
+}
+
+var otlpExporterType exporterType = otlpExporterTypeImpl{} //nolint:gochecknoglobals
+
+type otlpExporterTypeImpl struct{}
+
+// otlpSender abstracts the difference between the gRPC and HTTP transports.
+type otlpSender interface {
+	sendMetrics(context.Context, *colmetricspb.ExportMetricsServiceRequest) error
+	sendTraces(context.Context, *coltracepb.ExportTraceServiceRequest) error
+	close() error
+}
+
+type otlpExporterImpl struct {
+	sender        otlpSender
+	prefix        string
+	resource      *resourcepb.Resource
+	scope         *commonpb.InstrumentationScope
+	sampleRate    float64
+	hasSampleRate bool
+	loggers       ldlog.Loggers
+	metrics       map[string]*metricspb.Metric
+	spans         []*tracepb.Span
+	droppedSpans  int
+	lock          sync.Mutex
+	closeCh       chan struct{}
+	doneCh        chan struct{}
+}
+
+func (o otlpExporterTypeImpl) getName() string {
+	return "OTLP"
+}
+
+func (o otlpExporterTypeImpl) createExporterIfEnabled(
+	mc config.MetricsConfig,
+	loggers ldlog.Loggers,
+) (exporter, error) {
+	if !mc.OTLP.Enabled {
+		return nil, nil
+	}
+
+	headers := make(map[string]string)
+	for _, h := range mc.OTLP.Header {
+		name, value, ok := strings.Cut(h, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, errOTLPBadHeader(h)
+		}
+		headers[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+
+	var sender otlpSender
+	var err error
+	if mc.OTLP.Protocol == config.OTLPProtocolHTTP {
+		sender = newOTLPHTTPSender(mc.OTLP.Endpoint, mc.OTLP.Insecure, headers)
+	} else {
+		sender, err = newOTLPGRPCSender(mc.OTLP.Endpoint, mc.OTLP.Insecure, headers)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return newOTLPExporter(sender, mc.OTLP, loggers), nil
+}
+
+func newOTLPExporter(sender otlpSender, oc config.OTLPConfig, loggers ldlog.Loggers) *otlpExporterImpl {
+	return &otlpExporterImpl{
+		sender: sender,
+		prefix: getPrefix(oc.Prefix),
+		resource: &resourcepb.Resource{
+			Attributes: []*commonpb.KeyValue{
+				otlpStringAttribute("service.name", otlpServiceName),
+				otlpStringAttribute("service.version", version.Version),
+			},
+		},
+		scope:         &commonpb.InstrumentationScope{Name: otlpServiceName, Version: version.Version},
+		sampleRate:    oc.TraceSampleRate.GetOrElse(0),
+		hasSampleRate: oc.TraceSampleRate.IsDefined(),
+		loggers:       loggers,
+		metrics:       make(map[string]*metricspb.Metric),
+		closeCh:       make(chan struct{}),
+		doneCh:        make(chan struct{}),
+	}
+}
+
+func (o *otlpExporterImpl) register() error {
+	view.RegisterExporter(o)
+	trace.RegisterExporter(o)
+	if o.hasSampleRate {
+		trace.ApplyConfig(trace.Config{DefaultSampler: trace.ProbabilitySampler(o.sampleRate)})
+	}
+	go o.run()
+	return nil
+}
+
+func (o *otlpExporterImpl) close() error {
+	view.UnregisterExporter(o)
+	trace.UnregisterExporter(o)
+	select {
+	case <-o.closeCh: // already closed
+	default:
+		close(o.closeCh)
+		select {
+		case <-o.doneCh:
+		case <-time.After(otlpSendTimeout * 2): // COVERAGE: can't make this happen in unit tests
+		}
+	}
+	return o.sender.close()
+}
+
+func (o *otlpExporterImpl) run() {
+	defer close(o.doneCh)
+	ticker := time.NewTicker(otlpFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			o.flush()
+		case <-o.closeCh:
+			o.flush() // send whatever we have before shutting down
+			return
+		}
+	}
+}
+
+// ExportView is called by OpenCensus with the current data for a view. Since all of our views are
+// cumulative, only the latest data for each view needs to be sent.
+func (o *otlpExporterImpl) ExportView(vd *view.Data) {
+	m := otlpMetricFromViewData(o.prefix, vd)
+	if m == nil {
+		return
+	}
+	o.lock.Lock()
+	o.metrics[m.Name] = m
+	o.lock.Unlock()
+}
+
+// ExportSpan is called by OpenCensus for each sampled span when it ends.
+func (o *otlpExporterImpl) ExportSpan(sd *trace.SpanData) {
+	span := otlpSpanFromSpanData(sd)
+	o.lock.Lock()
+	if len(o.spans) >= otlpMaxQueuedSpans {
+		o.droppedSpans++
+	} else {
+		o.spans = append(o.spans, span)
+	}
+	o.lock.Unlock()
+}
+
+func (o *otlpExporterImpl) flush() {
+	o.lock.Lock()
+	metrics := make([]*metricspb.Metric, 0, len(o.metrics))
+	for _, m := range o.metrics {
+		metrics = append(metrics, m)
+	}
+	o.metrics = make(map[string]*metricspb.Metric)
+	spans := o.spans
+	o.spans = nil
+	droppedSpans := o.droppedSpans
+	o.droppedSpans = 0
+	o.lock.Unlock()
+
+	if droppedSpans > 0 {
+		o.loggers.Warnf("Dropped %d trace spans because the OTLP exporter could not keep up", droppedSpans)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), otlpSendTimeout)
+	defer cancel()
+
+	if len(metrics) > 0 {
+		sort.Slice(metrics, func(i, j int) bool { return metrics[i].Name < metrics[j].Name })
+		err := o.sender.sendMetrics(ctx, &colmetricspb.ExportMetricsServiceRequest{
+			ResourceMetrics: []*metricspb.ResourceMetrics{{
+				Resource:     o.resource,
+				ScopeMetrics: []*metricspb.ScopeMetrics{{Scope: o.scope, Metrics: metrics}},
+			}},
+		})
+		if err != nil {
+			o.loggers.Warnf("Failed to send metrics to OTLP collector: %s", err)
+		}
+	}
+	if len(spans) > 0 {
+		err := o.sender.sendTraces(ctx, &coltracepb.ExportTraceServiceRequest{
+			ResourceSpans: []*tracepb.ResourceSpans{{
+				Resource:   o.resource,
+				ScopeSpans: []*tracepb.ScopeSpans{{Scope: o.scope, Spans: spans}},
+			}},
+		})
+		if err != nil {
+			o.loggers.Warnf("Failed to send traces to OTLP collector: %s", err)
+		}
+	}
+}
+
+// Converts OpenCensus view data to an OTLP metric. Count views become monotonic sums; sum views, which
+// we use for gauges such as the number of open connections, become non-monotonic sums.
+func otlpMetricFromViewData(prefix string, vd *view.Data) *metricspb.Metric {
+	m := &metricspb.Metric{
+		Name:        prefix + "_" + vd.View.Name,
+		Description: vd.View.Description,
+		Unit:        vd.View.Measure.Unit(),
+	}
+	start, end := uint64(vd.Start.UnixNano()), uint64(vd.End.UnixNano()) //nolint:gosec // times are never negative
+	numberPoint := func(row *view.Row) *metricspb.NumberDataPoint {
+		return &metricspb.NumberDataPoint{
+			Attributes:        otlpAttributesFromTags(row.Tags),
+			StartTimeUnixNano: start,
+			TimeUnixNano:      end,
+		}
+	}
+	switch vd.View.Aggregation.Type {
+	case view.AggTypeCount, view.AggTypeSum:
+		sum := &metricspb.Sum{
+			AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+			IsMonotonic:            vd.View.Aggregation.Type == view.AggTypeCount,
+		}
+		for _, row := range vd.Rows {
+			point := numberPoint(row)
+			switch data := row.Data.(type) {
+			case *view.CountData:
+				point.Value = &metricspb.NumberDataPoint_AsInt{AsInt: data.Value}
+			case *view.SumData:
+				point.Value = &metricspb.NumberDataPoint_AsDouble{AsDouble: data.Value}
+			default:
+				continue
+			}
+			sum.DataPoints = append(sum.DataPoints, point)
+		}
+		m.Data = &metricspb.Metric_Sum{Sum: sum}
+	case view.AggTypeLastValue:
+		gauge := &metricspb.Gauge{}
+		for _, row := range vd.Rows {
+			if data, ok := row.Data.(*view.LastValueData); ok {
+				point := numberPoint(row)
+				point.Value = &metricspb.NumberDataPoint_AsDouble{AsDouble: data.Value}
+				gauge.DataPoints = append(gauge.DataPoints, point)
+			}
+		}
+		m.Data = &metricspb.Metric_Gauge{Gauge: gauge}
+	case view.AggTypeDistribution:
+		histogram := &metricspb.Histogram{
+			AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+		}
+		for _, row := range vd.Rows {
+			data, ok := row.Data.(*view.DistributionData)
+			if !ok {
+				continue
+			}
+			point := &metricspb.HistogramDataPoint{
+				Attributes:        otlpAttributesFromTags(row.Tags),
+				StartTimeUnixNano: start,
+				TimeUnixNano:      end,
+				Count:             uint64(data.Count), //nolint:gosec // counts are never negative
+				ExplicitBounds:    vd.View.Aggregation.Buckets,
+			}
+			if data.Count > 0 {
+				sum, minValue, maxValue := data.Mean*float64(data.Count), data.Min, data.Max
+				point.Sum, point.Min, point.Max = &sum, &minValue, &maxValue
+			}
+			for _, n := range data.CountPerBucket {
+				point.BucketCounts = append(point.BucketCounts, uint64(n)) //nolint:gosec // counts are never negative
+			}
+			histogram.DataPoints = append(histogram.DataPoints, point)
+		}
+		m.Data = &metricspb.Metric_Histogram{Histogram: histogram}
+	default:
+		return nil // COVERAGE: we don't define any other kind of view
+	}
+	return m
+}
+
+func otlpSpanFromSpanData(sd *trace.SpanData) *tracepb.Span {
+	traceID, spanID := sd.TraceID, sd.SpanID
+	span := &tracepb.Span{
+		TraceId:           traceID[:],
+		SpanId:            spanID[:],
+		Name:              sd.Name,
+		StartTimeUnixNano: uint64(sd.StartTime.UnixNano()), //nolint:gosec // times are never negative
+		EndTimeUnixNano:   uint64(sd.EndTime.UnixNano()),   //nolint:gosec // times are never negative
+	}
+	if sd.ParentSpanID != (trace.SpanID{}) {
+		parentSpanID := sd.ParentSpanID
+		span.ParentSpanId = parentSpanID[:]
+	}
+	switch sd.SpanKind {
+	case trace.SpanKindServer:
+		span.Kind = tracepb.Span_SPAN_KIND_SERVER
+	case trace.SpanKindClient:
+		span.Kind = tracepb.Span_SPAN_KIND_CLIENT
+	default:
+		span.Kind = tracepb.Span_SPAN_KIND_INTERNAL
+	}
+	keys := make([]string, 0, len(sd.Attributes))
+	for k := range sd.Attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		span.Attributes = append(span.Attributes, &commonpb.KeyValue{Key: k, Value: otlpAnyValue(sd.Attributes[k])})
+	}
+	if sd.Status.Code != trace.StatusCodeOK {
+		span.Status = &tracepb.Status{Code: tracepb.Status_STATUS_CODE_ERROR, Message: sd.Status.Message}
+	}
+	return span
+}
+
+func otlpAttributesFromTags(tags []tag.Tag) []*commonpb.KeyValue {
+	ret := make([]*commonpb.KeyValue, 0, len(tags))
+	for _, t := range tags {
+		ret = append(ret, otlpStringAttribute(t.Key.Name(), t.Value))
+	}
+	return ret
+}
+
+func otlpStringAttribute(key, value string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: key, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}}}
+}
+
+func otlpAnyValue(value interface{}) *commonpb.AnyValue {
+	switch v := value.(type) {
+	case bool:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: v}}
+	case int64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: v}}
+	case float64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: v}}
+	default:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: fmt.Sprint(v)}}
+	}
+}
+
+type otlpGRPCSender struct {
+	conn    *grpc.ClientConn
+	metrics colmetricspb.MetricsServiceClient
+	traces  coltracepb.TraceServiceClient
+	headers metadata.MD
+}
+
+func newOTLPGRPCSender(endpoint string, plaintext bool, headers map[string]string) (*otlpGRPCSender, error) {
+	if endpoint == "" {
+		endpoint = config.DefaultOTLPGRPCEndpoint
+	}
+	creds := credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12})
+	if plaintext {
+		creds = insecure.NewCredentials()
+	}
+	// This does not actually connect yet, so it only fails if the options are invalid.
+	conn, err := grpc.Dial(endpoint, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, err // COVERAGE: can't make this happen in unit tests
+	}
+	return &otlpGRPCSender{
+		conn:    conn,
+		metrics: colmetricspb.NewMetricsServiceClient(conn),
+		traces:  coltracepb.NewTraceServiceClient(conn),
+		headers: metadata.New(headers),
+	}, nil
+}
+
+func (s *otlpGRPCSender) sendMetrics(ctx context.Context, req *colmetricspb.ExportMetricsServiceRequest) error {
+	_, err := s.metrics.Export(metadata.NewOutgoingContext(ctx, s.headers), req)
+	return err
+}
+
+func (s *otlpGRPCSender) sendTraces(ctx context.Context, req *coltracepb.ExportTraceServiceRequest) error {
+	_, err := s.traces.Export(metadata.NewOutgoingContext(ctx, s.headers), req)
+	return err
+}
+
+func (s *otlpGRPCSender) close() error {
+	return s.conn.Close()
+}
+
+type otlpHTTPSender struct {
+	client  *http.Client
+	baseURL string
+	headers map[string]string
+}
+
+func newOTLPHTTPSender(endpoint string, plaintext bool, headers map[string]string) *otlpHTTPSender {
+	if endpoint == "" {
+		endpoint = config.DefaultOTLPHTTPEndpoint
+	} else if !strings.Contains(endpoint, "://") {
+		if plaintext {
+			endpoint = "http://" + endpoint
+		} else {
+			endpoint = "https://" + endpoint
+		}
+	}
+	return &otlpHTTPSender{
+		client:  &http.Client{Timeout: otlpSendTimeout},
+		baseURL: strings.TrimSuffix(endpoint, "/"),
+		headers: headers,
+	}
+}
+
+func (s *otlpHTTPSender) sendMetrics(ctx context.Context, req *colmetricspb.ExportMetricsServiceRequest) error {
+	return s.post(ctx, otlpHTTPMetricsPath, req)
+}
+
+func (s *otlpHTTPSender) sendTraces(ctx context.Context, req *coltracepb.ExportTraceServiceRequest) error {
+	return s.post(ctx, otlpHTTPTracesPath, req)
+}
+
+func (s *otlpHTTPSender) post(ctx context.Context, path string, message proto.Message) error {
+	body, err := proto.Marshal(message)
+	if err != nil {
+		return err // COVERAGE: can't make this happen in unit tests
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	for name, value := range s.headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errOTLPHTTPStatus(resp.StatusCode)
+	}
+	return nil
+}
+
+func (s *otlpHTTPSender) close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,286 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v8/config"
+
+	ct "github.com/launchdarkly/go-configtypes"
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	"github.com/launchdarkly/go-sdk-common/v3/ldlogtest"
+	"github.com/launchdarkly/go-test-helpers/v3/httphelpers"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"go.opencensus.io/trace"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOTLPExporterType(t *testing.T) {
+	exporterType := otlpExporterType
+
+	t.Run("name", func(t *testing.T) {
+		assert.Equal(t, "OTLP", exporterType.getName())
+	})
+
+	t.Run("included in allExporterTypes", func(t *testing.T) {
+		assert.Contains(t, allExporterTypes(), exporterType)
+	})
+
+	t.Run("does not create exporter if OTLP is disabled", func(t *testing.T) {
+		var mc config.MetricsConfig
+		e, err := exporterType.createExporterIfEnabled(mc, ldlog.NewDisabledLoggers())
+		require.NoError(t, err)
+		assert.Nil(t, e)
+	})
+
+	t.Run("creates exporter if OTLP is enabled", func(t *testing.T) {
+		for _, protocol := range []config.OTLPProtocol{config.OTLPProtocolGRPC, config.OTLPProtocolHTTP} {
+			t.Run(string(protocol), func(t *testing.T) {
+				var mc config.MetricsConfig
+				mc.OTLP.Enabled = true
+				mc.OTLP.Protocol = protocol
+				e, err := exporterType.createExporterIfEnabled(mc, ldlog.NewDisabledLoggers())
+				require.NoError(t, err)
+				require.NotNil(t, e)
+				assert.NoError(t, e.close())
+			})
+		}
+	})
+
+	t.Run("returns error for malformed header", func(t *testing.T) {
+		var mc config.MetricsConfig
+		mc.OTLP.Enabled = true
+		mc.OTLP.Header = []string{"no-colon"}
+		e, err := exporterType.createExporterIfEnabled(mc, ldlog.NewDisabledLoggers())
+		assert.Error(t, err)
+		assert.Nil(t, e)
+	})
+
+	t.Run("registers exporter without errors", func(t *testing.T) {
+		var mc config.MetricsConfig
+		mc.OTLP.Enabled = true
+		e, err := exporterType.createExporterIfEnabled(mc, ldlog.NewDisabledLoggers())
+		require.NoError(t, err)
+		require.NotNil(t, e)
+		defer e.close()
+		assert.NoError(t, e.register())
+	})
+}
+
+func TestOTLPHTTPSenderPostsProtobufWithHeaders(t *testing.T) {
+	handler, requestsCh := httphelpers.RecordingHandler(httphelpers.HandlerWithStatus(http.StatusOK))
+	httphelpers.WithServer(handler, func(server *httptest.Server) {
+		var mc config.MetricsConfig
+		mc.OTLP.Enabled = true
+		mc.OTLP.Protocol = config.OTLPProtocolHTTP
+		mc.OTLP.Endpoint = server.URL
+		mc.OTLP.Header = []string{"api-key: xyz"}
+		e, err := otlpExporterType.createExporterIfEnabled(mc, ldlog.NewDisabledLoggers())
+		require.NoError(t, err)
+		exporter := e.(*otlpExporterImpl)
+		defer exporter.close()
+
+		m := stats.Int64("otlp_test_measure", "", stats.UnitDimensionless)
+		vd := &view.Data{
+			View:  &view.View{Name: "otlp_test", Measure: m, Aggregation: view.Count()},
+			Rows:  []*view.Row{{Data: &view.CountData{Value: 3}}},
+			Start: time.Now(),
+			End:   time.Now(),
+		}
+		exporter.ExportView(vd)
+		exporter.ExportSpan(&trace.SpanData{Name: "span"})
+		exporter.flush()
+
+		r1 := <-requestsCh
+		assert.Equal(t, otlpHTTPMetricsPath, r1.Request.URL.Path)
+		assert.Equal(t, "application/x-protobuf", r1.Request.Header.Get("Content-Type"))
+		assert.Equal(t, "xyz", r1.Request.Header.Get("Api-Key"))
+		var metricsReq colmetricspb.ExportMetricsServiceRequest
+		require.NoError(t, proto.Unmarshal(r1.Body, &metricsReq))
+		metrics := metricsReq.ResourceMetrics[0].ScopeMetrics[0].Metrics
+		require.Len(t, metrics, 1)
+		assert.Equal(t, "launchdarkly_relay_otlp_test", metrics[0].Name)
+		assert.Equal(t, int64(3), metrics[0].GetSum().DataPoints[0].GetAsInt())
+
+		r2 := <-requestsCh
+		assert.Equal(t, otlpHTTPTracesPath, r2.Request.URL.Path)
+		var traceReq coltracepb.ExportTraceServiceRequest
+		require.NoError(t, proto.Unmarshal(r2.Body, &traceReq))
+		spans := traceReq.ResourceSpans[0].ScopeSpans[0].Spans
+		require.Len(t, spans, 1)
+		assert.Equal(t, "span", spans[0].Name)
+	})
+}
+
+func TestOTLPExporterLogsSendFailure(t *testing.T) {
+	mockLog := ldlogtest.NewMockLog()
+	handler := httphelpers.HandlerWithStatus(http.StatusServiceUnavailable)
+	httphelpers.WithServer(handler, func(server *httptest.Server) {
+		sender := newOTLPHTTPSender(server.URL, true, nil)
+		exporter := newOTLPExporter(sender, config.OTLPConfig{}, mockLog.Loggers)
+		exporter.ExportSpan(&trace.SpanData{Name: "span"})
+		exporter.flush()
+		mockLog.AssertMessageMatch(t, true, ldlog.Warn, "Failed to send traces to OTLP collector.*503")
+	})
+}
+
+func TestOTLPExporterDropsSpansBeyondQueueLimit(t *testing.T) {
+	mockLog := ldlogtest.NewMockLog()
+	handler := httphelpers.HandlerWithStatus(http.StatusOK)
+	httphelpers.WithServer(handler, func(server *httptest.Server) {
+		exporter := newOTLPExporter(newOTLPHTTPSender(server.URL, true, nil), config.OTLPConfig{}, mockLog.Loggers)
+		for i := 0; i < otlpMaxQueuedSpans+5; i++ {
+			exporter.ExportSpan(&trace.SpanData{Name: "span"})
+		}
+		assert.Len(t, exporter.spans, otlpMaxQueuedSpans)
+		exporter.flush()
+		mockLog.AssertMessageMatch(t, true, ldlog.Warn, "Dropped 5 trace spans")
+	})
+}
+
+func TestOTLPHTTPSenderEndpointScheme(t *testing.T) {
+	assert.Equal(t, config.DefaultOTLPHTTPEndpoint, newOTLPHTTPSender("", false, nil).baseURL)
+	assert.Equal(t, "http://collector:4318", newOTLPHTTPSender("collector:4318", true, nil).baseURL)
+	assert.Equal(t, "https://collector:4318", newOTLPHTTPSender("collector:4318/", false, nil).baseURL)
+	assert.Equal(t, "http://collector", newOTLPHTTPSender("http://collector", false, nil).baseURL)
+}
+
+func TestOTLPMetricFromViewData(t *testing.T) {
+	m := stats.Float64("otlp_conversion_measure", "", stats.UnitMilliseconds)
+	key := tag.MustNewKey("env")
+	start, end := time.Unix(100, 0), time.Unix(200, 0)
+	makeData := func(agg *view.Aggregation, data view.AggregationData) *view.Data {
+		return &view.Data{
+			View:  &view.View{Name: "v", Description: "desc", Measure: m, Aggregation: agg},
+			Rows:  []*view.Row{{Tags: []tag.Tag{{Key: key, Value: "prod"}}, Data: data}},
+			Start: start,
+			End:   end,
+		}
+	}
+
+	t.Run("count", func(t *testing.T) {
+		metric := otlpMetricFromViewData("p", makeData(view.Count(), &view.CountData{Value: 2}))
+		assert.Equal(t, "p_v", metric.Name)
+		assert.Equal(t, "desc", metric.Description)
+		assert.Equal(t, stats.UnitMilliseconds, metric.Unit)
+		sum := metric.GetSum()
+		require.NotNil(t, sum)
+		assert.True(t, sum.IsMonotonic)
+		assert.Equal(t, metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE, sum.AggregationTemporality)
+		point := sum.DataPoints[0]
+		assert.Equal(t, int64(2), point.GetAsInt())
+		assert.Equal(t, uint64(start.UnixNano()), point.StartTimeUnixNano)
+		assert.Equal(t, uint64(end.UnixNano()), point.TimeUnixNano)
+		assert.Equal(t, "env", point.Attributes[0].Key)
+		assert.Equal(t, "prod", point.Attributes[0].Value.GetStringValue())
+	})
+
+	t.Run("sum", func(t *testing.T) {
+		metric := otlpMetricFromViewData("p", makeData(view.Sum(), &view.SumData{Value: 1.5}))
+		sum := metric.GetSum()
+		require.NotNil(t, sum)
+		assert.False(t, sum.IsMonotonic)
+		assert.Equal(t, 1.5, sum.DataPoints[0].GetAsDouble())
+	})
+
+	t.Run("last value", func(t *testing.T) {
+		metric := otlpMetricFromViewData("p", makeData(view.LastValue(), &view.LastValueData{Value: 4}))
+		gauge := metric.GetGauge()
+		require.NotNil(t, gauge)
+		assert.Equal(t, float64(4), gauge.DataPoints[0].GetAsDouble())
+	})
+
+	t.Run("distribution", func(t *testing.T) {
+		data := &view.DistributionData{Count: 4, Min: 1, Max: 30, Mean: 10, CountPerBucket: []int64{1, 2, 1}}
+		metric := otlpMetricFromViewData("p", makeData(view.Distribution(5, 20), data))
+		histogram := metric.GetHistogram()
+		require.NotNil(t, histogram)
+		point := histogram.DataPoints[0]
+		assert.Equal(t, uint64(4), point.Count)
+		assert.Equal(t, []float64{5, 20}, point.ExplicitBounds)
+		assert.Equal(t, []uint64{1, 2, 1}, point.BucketCounts)
+		assert.Equal(t, float64(40), point.GetSum())
+		assert.Equal(t, float64(1), point.GetMin())
+		assert.Equal(t, float64(30), point.GetMax())
+	})
+}
+
+func TestOTLPSpanFromSpanData(t *testing.T) {
+	sd := &trace.SpanData{
+		SpanContext: trace.SpanContext{
+			TraceID: trace.TraceID{1, 2, 3},
+			SpanID:  trace.SpanID{4, 5, 6},
+		},
+		ParentSpanID: trace.SpanID{7, 8, 9},
+		SpanKind:     trace.SpanKindServer,
+		Name:         "/sdk/latest-all",
+		StartTime:    time.Unix(100, 0),
+		EndTime:      time.Unix(101, 0),
+		Attributes:   map[string]interface{}{"b": true, "i": int64(3), "s": "x"},
+		Status:       trace.Status{Code: trace.StatusCodeUnavailable, Message: "oops"},
+	}
+	span := otlpSpanFromSpanData(sd)
+	assert.Equal(t, []byte{1, 2, 3, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}, span.TraceId)
+	assert.Equal(t, []byte{4, 5, 6, 0, 0, 0, 0, 0}, span.SpanId)
+	assert.Equal(t, []byte{7, 8, 9, 0, 0, 0, 0, 0}, span.ParentSpanId)
+	assert.Equal(t, tracepb.Span_SPAN_KIND_SERVER, span.Kind)
+	assert.Equal(t, "/sdk/latest-all", span.Name)
+	assert.Equal(t, uint64(time.Unix(101, 0).UnixNano()), span.EndTimeUnixNano)
+	require.Len(t, span.Attributes, 3)
+	assert.True(t, span.Attributes[0].Value.GetBoolValue())
+	assert.Equal(t, int64(3), span.Attributes[1].Value.GetIntValue())
+	assert.Equal(t, "x", span.Attributes[2].Value.GetStringValue())
+	assert.Equal(t, tracepb.Status_STATUS_CODE_ERROR, span.Status.Code)
+	assert.Equal(t, "oops", span.Status.Message)
+
+	rootSpan := otlpSpanFromSpanData(&trace.SpanData{Name: "root"})
+	assert.Nil(t, rootSpan.ParentSpanId)
+	assert.Equal(t, tracepb.Span_SPAN_KIND_INTERNAL, rootSpan.Kind)
+	assert.Nil(t, rootSpan.Status)
+}
+
+func TestWithRequestTraceContext(t *testing.T) {
+	t.Run("uses parent from traceparent header", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "http://localhost", nil)
+		req.Header.Set("traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+		ctx := WithRequestTraceContext(req.Context(), req)
+		parent, ok := ctx.Value(remoteSpanContextKey{}).(trace.SpanContext)
+		require.True(t, ok)
+		assert.Equal(t, "0af7651916cd43dd8448eb211c80319c", parent.TraceID.String())
+		assert.Equal(t, "b7ad6b7169203331", parent.SpanID.String())
+	})
+
+	t.Run("leaves context unchanged without traceparent header", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "http://localhost", nil)
+		ctx := WithRequestTraceContext(req.Context(), req)
+		assert.Nil(t, ctx.Value(remoteSpanContextKey{}))
+	})
+}
+
+func TestOTLPExporterAppliesTraceSampleRate(t *testing.T) {
+	var mc config.MetricsConfig
+	mc.OTLP.Enabled = true
+	mc.OTLP.TraceSampleRate = ct.NewOptFloat64(1)
+	e, err := otlpExporterType.createExporterIfEnabled(mc, ldlog.NewDisabledLoggers())
+	require.NoError(t, err)
+	defer e.close()
+	require.NoError(t, e.register())
+	defer trace.ApplyConfig(trace.Config{DefaultSampler: trace.ProbabilitySampler(1e-4)})
+
+	_, span := trace.StartSpan(context.Background(), "sampled")
+	assert.True(t, span.SpanContext().IsSampled())
+	span.End()
+}
@@ -37,8 +37,8 @@ func (p prometheusExporterTypeImpl) createExporterIfEnabled(
 	mc config.MetricsConfig,
 	loggers ldlog.Loggers,
 ) (exporter, error) {
-	if !mc.Prometheus.Enabled {
-		return nil, nil
+	if !mc.Prometheus.Enabled || mc.Prometheus.Native {
+		return nil, nil // the native exporter is created separately by NewNativePrometheusExporter
 	}
 
 	port := mc.Prometheus.Port.GetOrElse(config.DefaultPrometheusPort)
@@ -0,0 +1,308 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v8/config"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	"github.com/launchdarkly/go-server-sdk/v7/interfaces"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opencensus.io/tag"
+)
+
+// EnvironmentState is a snapshot of the state of one environment, as reported by the native Prometheus
+// exporter. Properties that do not apply to the environment are nil.
+type EnvironmentState struct {
+	// Name is the environment's name in the status resource, which is used as the "env" label.
+	Name string
+
+	// Connections is the number of current stream connections, by the kind of stream.
+	Connections map[string]int
+
+	// DataSourceState is the state of the SDK client's data source, or nil if there is no client yet.
+	DataSourceState *interfaces.DataSourceState
+
+	// DataStoreAvailable is true if the data store is working, or nil if there is no client yet.
+	DataStoreAvailable *bool
+
+	// BigSegmentsStale is true if big segment data is potentially stale, or nil if big segments are not
+	// being used.
+	BigSegmentsStale *bool
+
+	// SDKKeyExpiry is the time when the earliest deprecated SDK key expires, or nil if there is none.
+	SDKKeyExpiry *time.Time
+
+	// EventQueueDepth is the number of events waiting to be forwarded, or nil if events are not forwarded.
+	EventQueueDepth *int64
+
+	// SDKInitDuration is how long the SDK client took to initialize, or nil if it has not initialized.
+	SDKInitDuration *time.Duration
+}
+
+var allDataSourceStates = []interfaces.DataSourceState{ //nolint:gochecknoglobals
+	interfaces.DataSourceStateInitializing,
+	interfaces.DataSourceStateValid,
+	interfaces.DataSourceStateInterrupted,
+	interfaces.DataSourceStateOff,
+}
+
+// These are the native equivalents of the OpenCensus request and connection metrics, with the same names
+// and labels, so that dashboards built on the OpenCensus Prometheus exporter's output keep working in
+// native mode. They are updated by the same functions that record the OpenCensus measures, such as WithCount
+// and RecordRequestDuration, but only after a NativePrometheusExporter has been created; otherwise nothing
+// would ever read them. The tag keys are not taken from the shared slices in constants.go, because
+// registering an OpenCensus view sorts its tag keys in place.
+var (
+	nativeMetricsEnabled atomic.Bool //nolint:gochecknoglobals
+
+	nativeConnections = newNativeGauge(connMeasureName, //nolint:gochecknoglobals
+		"Current number of stream connections.", platformCategoryTagKey, userAgentTagKey, envNameTagKey)
+	nativeNewConnections = newNativeCounter(newConnMeasureName, //nolint:gochecknoglobals
+		"Total number of stream connections.", platformCategoryTagKey, userAgentTagKey, envNameTagKey)
+	nativeRequests = newNativeCounter(requestMeasureName, //nolint:gochecknoglobals
+		"Number of hits to a route.", platformCategoryTagKey, userAgentTagKey, envNameTagKey, routeTagKey, methodTagKey)
+	nativeRequestDuration = newNativeHistogram(requestDurationMeasureName, //nolint:gochecknoglobals
+		"Time taken to respond to a request, in milliseconds.", durationBucketsMillis,
+		platformCategoryTagKey, envNameTagKey, routeTagKey, methodTagKey, statusCodeTagKey)
+)
+
+// NativePrometheusExporter provides Prometheus metrics directly from a client_golang registry, rather than
+// going through OpenCensus. Besides the Go runtime and process metrics, it reports gauges for the state of
+// each environment, which are computed from the getEnvironments function every time the metrics are scraped.
+// It also reports native versions of the OpenCensus request and connection metrics; the other OpenCensus
+// metrics are not exported to Prometheus in native mode, although other exporters still receive them.
+//
+// The metrics are served on the configured Prometheus port, unless PrometheusConfig.UseAdminEndpoint is
+// true; in that case, Relay serves Handler() on its admin endpoint instead.
+type NativePrometheusExporter struct {
+	handler  http.Handler
+	server   *http.Server
+	listener net.Listener
+	loggers  ldlog.Loggers
+}
+
+type environmentCollector struct {
+	getEnvironments    func() []EnvironmentState
+	connections        *prometheus.Desc
+	dataSourceState    *prometheus.Desc
+	dataStoreAvailable *prometheus.Desc
+	bigSegmentsStale   *prometheus.Desc
+	sdkKeyExpiry       *prometheus.Desc
+	eventQueueDepth    *prometheus.Desc
+	sdkInitDuration    *prometheus.Desc
+}
+
+// NewNativePrometheusExporter creates a NativePrometheusExporter, and starts its listener unless it is
+// configured to use the admin endpoint.
+func NewNativePrometheusExporter(
+	pc config.PrometheusConfig,
+	getEnvironments func() []EnvironmentState,
+	loggers ldlog.Loggers,
+) (*NativePrometheusExporter, error) {
+	registry := prometheus.NewRegistry()
+	if err := registry.Register(collectors.NewGoCollector()); err != nil {
+		return nil, err // COVERAGE: can't make this happen in unit tests
+	}
+	if err := registry.Register(collectors.NewProcessCollector(collectors.ProcessCollectorOpts{})); err != nil {
+		return nil, err // COVERAGE: can't make this happen in unit tests
+	}
+	if err := registry.Register(newEnvironmentCollector(getPrefix(pc.Prefix), getEnvironments)); err != nil {
+		return nil, err // COVERAGE: can't make this happen in unit tests
+	}
+	prefixedRegistry := prometheus.WrapRegistererWithPrefix(getPrefix(pc.Prefix)+"_", registry)
+	for _, n := range []*nativeMetric{nativeConnections, nativeNewConnections, nativeRequests, nativeRequestDuration} {
+		if err := prefixedRegistry.Register(n.collector); err != nil {
+			return nil, err // COVERAGE: can't make this happen in unit tests
+		}
+	}
+	nativeMetricsEnabled.Store(true)
+	e := &NativePrometheusExporter{
+		handler: promhttp.HandlerFor(registry, promhttp.HandlerOpts{
+			ErrorLog: promErrorLogger{loggers},
+		}),
+		loggers: loggers,
+	}
+	if pc.UseAdminEndpoint {
+		return e, nil
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", e.handler)
+	e.server = &http.Server{
+		Addr:              fmt.Sprintf(":%d", pc.Port.GetOrElse(config.DefaultPrometheusPort)),
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	// Separate Listen and Serve here instead of calling ListenAndServe() so that we can immediately
+	// detect if the port isn't available
+	listener, err := net.Listen("tcp", e.server.Addr)
+	if err != nil {
+		return nil, errPrometheusListenerFailed(err)
+	}
+	e.listener = listener
+	go func() {
+		err := e.server.Serve(listener)
+		if err != http.ErrServerClosed { // Serve never returns a nil error value
+			loggers.Error(errPrometheusListenerFailed(err)) // COVERAGE: can't make this happen in unit tests
+		}
+	}()
+	loggers.Info("Successfully started native Prometheus metrics exporter")
+	return e, nil
+}
+
+// Handler returns the HTTP handler for the metrics.
+func (e *NativePrometheusExporter) Handler() http.Handler {
+	return e.handler
+}
+
+// Close stops the listener, if any.
+func (e *NativePrometheusExporter) Close() error {
+	if e.server == nil {
+		return nil
+	}
+	err := e.server.Close()
+	_ = e.listener.Close()
+	return err
+}
+
+func newEnvironmentCollector(prefix string, getEnvironments func() []EnvironmentState) *environmentCollector {
+	envLabel := []string{"env"}
+	desc := func(name, help string, labels []string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(prefix, "environment", name), help, labels, nil)
+	}
+	return &environmentCollector{
+		getEnvironments: getEnvironments,
+		connections: desc("connections", "Number of current stream connections from SDKs.",
+			[]string{"env", "kind"}),
+		dataSourceState: desc("data_source_state",
+			"1 if the SDK client's data source is in this state, otherwise 0.", []string{"env", "state"}),
+		dataStoreAvailable: desc("data_store_available",
+			"1 if the data store is available, 0 if it is not.", envLabel),
+		bigSegmentsStale: desc("big_segments_stale",
+			"1 if big segment data is potentially stale, otherwise 0.", envLabel),
+		sdkKeyExpiry: desc("sdk_key_expiry_timestamp_seconds",
+			"Time when the earliest deprecated SDK key expires, in seconds since the epoch.", envLabel),
+		eventQueueDepth: desc("event_queue_depth",
+			"Number of analytics events waiting to be forwarded.", envLabel),
+		sdkInitDuration: desc("sdk_init_duration_seconds",
+			"How long the SDK client took to initialize.", envLabel),
+	}
+}
+
+func (c *environmentCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.connections
+	ch <- c.dataSourceState
+	ch <- c.dataStoreAvailable
+	ch <- c.bigSegmentsStale
+	ch <- c.sdkKeyExpiry
+	ch <- c.eventQueueDepth
+	ch <- c.sdkInitDuration
+}
+
+func (c *environmentCollector) Collect(ch chan<- prometheus.Metric) {
+	gauge := func(desc *prometheus.Desc, value float64, labels ...string) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value, labels...)
+	}
+	for _, env := range c.getEnvironments() {
+		for kind, count := range env.Connections {
+			gauge(c.connections, float64(count), env.Name, kind)
+		}
+		if env.DataSourceState != nil {
+			for _, state := range allDataSourceStates {
+				gauge(c.dataSourceState, boolToFloat(state == *env.DataSourceState), env.Name, string(state))
+			}
+		}
+		if env.DataStoreAvailable != nil {
+			gauge(c.dataStoreAvailable, boolToFloat(*env.DataStoreAvailable), env.Name)
+		}
+		if env.BigSegmentsStale != nil {
+			gauge(c.bigSegmentsStale, boolToFloat(*env.BigSegmentsStale), env.Name)
+		}
+		if env.SDKKeyExpiry != nil {
+			gauge(c.sdkKeyExpiry, float64(env.SDKKeyExpiry.UnixMilli())/1000, env.Name)
+		}
+		if env.EventQueueDepth != nil {
+			gauge(c.eventQueueDepth, float64(*env.EventQueueDepth), env.Name)
+		}
+		if env.SDKInitDuration != nil {
+			gauge(c.sdkInitDuration, env.SDKInitDuration.Seconds(), env.Name)
+		}
+	}
+}
+
+func boolToFloat(value bool) float64 {
+	if value {
+		return 1
+	}
+	return 0
+}
+
+// nativeMetric is a labeled Prometheus metric whose label values are taken from the OpenCensus tags in
+// a Context, so that it can be updated wherever the corresponding OpenCensus measure is recorded.
+type nativeMetric struct {
+	collector prometheus.Collector
+	labelKeys []tag.Key
+	update    func(value float64, labelValues ...string)
+}
+
+func newNativeGauge(name, help string, labelKeys ...tag.Key) *nativeMetric {
+	vec := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: name, Help: help}, labelNames(labelKeys))
+	return &nativeMetric{collector: vec, labelKeys: labelKeys, update: func(value float64, labelValues ...string) {
+		vec.WithLabelValues(labelValues...).Add(value)
+	}}
+}
+
+func newNativeCounter(name, help string, labelKeys ...tag.Key) *nativeMetric {
+	vec := prometheus.NewCounterVec(prometheus.CounterOpts{Name: name, Help: help}, labelNames(labelKeys))
+	return &nativeMetric{collector: vec, labelKeys: labelKeys, update: func(value float64, labelValues ...string) {
+		vec.WithLabelValues(labelValues...).Add(value)
+	}}
+}
+
+func newNativeHistogram(name, help string, buckets []float64, labelKeys ...tag.Key) *nativeMetric {
+	vec := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: name, Help: help, Buckets: buckets},
+		labelNames(labelKeys))
+	return &nativeMetric{collector: vec, labelKeys: labelKeys, update: func(value float64, labelValues ...string) {
+		vec.WithLabelValues(labelValues...).Observe(value)
+	}}
+}
+
+func labelNames(keys []tag.Key) []string {
+	ret := make([]string, 0, len(keys))
+	for _, k := range keys {
+		ret = append(ret, k.Name())
+	}
+	return ret
+}
+
+// record updates the metric, if native metrics are enabled, with label values from the OpenCensus tags
+// in the Context. A tag that is not set becomes an empty label value, as it does in OpenCensus.
+func (n *nativeMetric) record(ctx context.Context, value float64) {
+	if n == nil || !nativeMetricsEnabled.Load() {
+		return
+	}
+	tags := tag.FromContext(ctx)
+	labelValues := make([]string, 0, len(n.labelKeys))
+	for _, k := range n.labelKeys {
+		v, _ := tags.Value(k)
+		labelValues = append(labelValues, v)
+	}
+	n.update(value, labelValues...)
+}
+
+type promErrorLogger struct {
+	loggers ldlog.Loggers
+}
+
+func (l promErrorLogger) Println(v ...interface{}) { // COVERAGE: can't make this happen in unit tests
+	l.loggers.Error(append([]interface{}{"Prometheus exporter error:"}, v...)...)
+}
@@ -0,0 +1,156 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v8/config"
+	st "github.com/launchdarkly/ld-relay/v8/internal/sharedtest"
+
+	ct "github.com/launchdarkly/go-configtypes"
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	"github.com/launchdarkly/go-server-sdk/v7/interfaces"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+)
+
+func getNativeMetricsOutput(t *testing.T, e *NativePrometheusExporter) string {
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	w := httptest.NewRecorder()
+	e.Handler().ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Result().StatusCode)
+	body, _ := io.ReadAll(w.Result().Body)
+	return string(body)
+}
+
+func TestNativePrometheusExporter(t *testing.T) {
+	adminEndpointConfig := config.PrometheusConfig{Enabled: true, Native: true, UseAdminEndpoint: true}
+
+	t.Run("reports environment gauges", func(t *testing.T) {
+		valid := interfaces.DataSourceStateValid
+		available, stale := true, false
+		expiry := time.Unix(1700000000, 0)
+		queueDepth := int64(7)
+		initDuration := 1500 * time.Millisecond
+		envs := []EnvironmentState{
+			{
+				Name:               "env1",
+				Connections:        map[string]int{"server": 2, "mobile-ping": 1},
+				DataSourceState:    &valid,
+				DataStoreAvailable: &available,
+				BigSegmentsStale:   &stale,
+				SDKKeyExpiry:       &expiry,
+				EventQueueDepth:    &queueDepth,
+				SDKInitDuration:    &initDuration,
+			},
+			{Name: "env2"},
+		}
+		e, err := NewNativePrometheusExporter(adminEndpointConfig,
+			func() []EnvironmentState { return envs }, ldlog.NewDisabledLoggers())
+		require.NoError(t, err)
+		defer e.Close()
+
+		output := getNativeMetricsOutput(t, e)
+		for _, line := range []string{
+			`launchdarkly_relay_environment_connections{env="env1",kind="server"} 2`,
+			`launchdarkly_relay_environment_connections{env="env1",kind="mobile-ping"} 1`,
+			`launchdarkly_relay_environment_data_source_state{env="env1",state="VALID"} 1`,
+			`launchdarkly_relay_environment_data_source_state{env="env1",state="INTERRUPTED"} 0`,
+			`launchdarkly_relay_environment_data_store_available{env="env1"} 1`,
+			`launchdarkly_relay_environment_big_segments_stale{env="env1"} 0`,
+			`launchdarkly_relay_environment_sdk_key_expiry_timestamp_seconds{env="env1"} 1.7e+09`,
+			`launchdarkly_relay_environment_event_queue_depth{env="env1"} 7`,
+			`launchdarkly_relay_environment_sdk_init_duration_seconds{env="env1"} 1.5`,
+			"go_goroutines",
+		} {
+			assert.Contains(t, output, line)
+		}
+		assert.NotContains(t, output, `env="env2"`) // no properties apply to it
+		assert.NotContains(t, output, "opencensus")
+	})
+
+	t.Run("reports request and connection metrics", func(t *testing.T) {
+		e, err := NewNativePrometheusExporter(adminEndpointConfig,
+			func() []EnvironmentState { return nil }, ldlog.NewDisabledLoggers())
+		require.NoError(t, err)
+		defer e.Close()
+
+		ctx, _ := tag.New(context.Background(), tag.Insert(envNameTagKey, "native-env"))
+		WithRouteCount(ctx, "agent", "someRoute", "GET", func() {}, ServerRequests)
+		RecordRequestDuration(ctx, "someRoute", "GET", 200, 2*time.Millisecond, ServerRequests)
+		WithCount(ctx, "agent", func() {}, NewMobileConns)
+		WithGauge(ctx, "agent", func() {
+			assert.Contains(t, getNativeMetricsOutput(t, e),
+				`launchdarkly_relay_connections{env="native-env",platformCategory="browser",userAgent="agent"} 1`)
+		}, BrowserConns)
+
+		output := getNativeMetricsOutput(t, e)
+		for _, line := range []string{
+			`launchdarkly_relay_requests{env="native-env",method="GET",platformCategory="server",route="someRoute",userAgent="agent"} 1`,
+			`launchdarkly_relay_request_duration_bucket{env="native-env",method="GET",platformCategory="server",route="someRoute",statusCode="200",le="2.5"} 1`,
+			`launchdarkly_relay_request_duration_sum{env="native-env",method="GET",platformCategory="server",route="someRoute",statusCode="200"} 2`,
+			`launchdarkly_relay_newconnections{env="native-env",platformCategory="mobile",userAgent="agent"} 1`,
+			`launchdarkly_relay_connections{env="native-env",platformCategory="browser",userAgent="agent"} 0`,
+		} {
+			assert.Contains(t, output, line)
+		}
+	})
+
+	t.Run("does not report other OpenCensus metrics", func(t *testing.T) {
+		require.NoError(t, view.Register(storeReadDurationView))
+		defer view.Unregister(storeReadDurationView)
+		e, err := NewNativePrometheusExporter(adminEndpointConfig,
+			func() []EnvironmentState { return nil }, ldlog.NewDisabledLoggers())
+		require.NoError(t, err)
+		defer e.Close()
+
+		RecordStoreReadDuration(context.Background(), "features", time.Millisecond)
+		assert.NotContains(t, getNativeMetricsOutput(t, e), storeReadDurationMeasureName)
+	})
+
+	t.Run("uses custom prefix", func(t *testing.T) {
+		pc := adminEndpointConfig
+		pc.Prefix = "myprefix"
+		e, err := NewNativePrometheusExporter(pc,
+			func() []EnvironmentState {
+				return []EnvironmentState{{Name: "env1", Connections: map[string]int{"server": 1}}}
+			},
+			ldlog.NewDisabledLoggers())
+		require.NoError(t, err)
+		defer e.Close()
+
+		assert.Contains(t, getNativeMetricsOutput(t, e), `myprefix_environment_connections{env="env1",kind="server"} 1`)
+	})
+
+	t.Run("listens on custom port", func(t *testing.T) {
+		availablePort := st.GetAvailablePort(t)
+		pc := config.PrometheusConfig{Enabled: true, Native: true}
+		pc.Port, _ = ct.NewOptIntGreaterThanZero(availablePort)
+		e, err := NewNativePrometheusExporter(pc, func() []EnvironmentState { return nil }, ldlog.NewDisabledLoggers())
+		require.NoError(t, err)
+		defer e.Close()
+
+		resp, err := http.DefaultClient.Get(fmt.Sprintf("http://localhost:%d/metrics", availablePort))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("returns error if port is unavailable", func(t *testing.T) {
+		st.WithListenerForAnyPort(t, func(l net.Listener, usedPort int) {
+			pc := config.PrometheusConfig{Enabled: true, Native: true}
+			pc.Port, _ = ct.NewOptIntGreaterThanZero(usedPort)
+			_, err := NewNativePrometheusExporter(pc, func() []EnvironmentState { return nil }, ldlog.NewDisabledLoggers())
+			assert.Error(t, err)
+		})
+	})
+}
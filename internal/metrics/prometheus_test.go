@@ -44,6 +44,15 @@ func TestPrometheusExporterType(t *testing.T) {
 		e.close()
 	})
 
+	t.Run("does not create exporter if native Prometheus exporter is enabled", func(t *testing.T) {
+		var mc config.MetricsConfig
+		mc.Prometheus.Enabled = true
+		mc.Prometheus.Native = true
+		e, err := exporterType.createExporterIfEnabled(mc, ldlog.NewDisabledLoggers())
+		require.NoError(t, err)
+		assert.Nil(t, e)
+	})
+
 	// There's currently no way to make prometheus.NewExporter fail for bad options
 
 	t.Run("registers exporter without errors", func(t *testing.T) {
@@ -4,6 +4,7 @@ import (
 	"sync"
 
 	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
 )
 
 var (
@@ -22,6 +23,70 @@ var (
 		Aggregation: view.Count(),
 		TagKeys:     append(publicTags, routeTagKey, methodTagKey),
 	}
+	slowStreamConsumersView *view.View = &view.View{ //nolint:gochecknoglobals
+		Measure:     slowStreamConsumersMeasure,
+		Aggregation: view.Count(),
+		TagKeys:     append(publicTags, reasonTagKey),
+	}
+	requestDurationView *view.View = &view.View{ //nolint:gochecknoglobals
+		Measure:     requestDurationMeasure,
+		Aggregation: view.Distribution(durationBucketsMillis...),
+		TagKeys:     []tag.Key{platformCategoryTagKey, envNameTagKey, routeTagKey, methodTagKey, statusCodeTagKey},
+	}
+	storeReadDurationView *view.View = &view.View{ //nolint:gochecknoglobals
+		Measure:     storeReadDurationMeasure,
+		Aggregation: view.Distribution(durationBucketsMillis...),
+		TagKeys:     []tag.Key{envNameTagKey, dataKindTagKey},
+	}
+	evaluationDurationView *view.View = &view.View{ //nolint:gochecknoglobals
+		Measure:     evaluationDurationMeasure,
+		Aggregation: view.Distribution(durationBucketsMillis...),
+		TagKeys:     []tag.Key{platformCategoryTagKey, envNameTagKey},
+	}
+	eventsReceivedView *view.View = &view.View{ //nolint:gochecknoglobals
+		Measure:     eventsReceivedMeasure,
+		Aggregation: view.Sum(),
+		TagKeys:     []tag.Key{platformCategoryTagKey, envNameTagKey},
+	}
+	eventsForwardedView *view.View = &view.View{ //nolint:gochecknoglobals
+		Measure:     eventsForwardedMeasure,
+		Aggregation: view.Sum(),
+		TagKeys:     []tag.Key{envNameTagKey},
+	}
+	eventPayloadsForwardedView *view.View = &view.View{ //nolint:gochecknoglobals
+		Measure:     eventPayloadsForwardedMeasure,
+		Aggregation: view.Sum(),
+		TagKeys:     []tag.Key{envNameTagKey},
+	}
+	eventsDroppedView *view.View = &view.View{ //nolint:gochecknoglobals
+		Measure:     eventsDroppedMeasure,
+		Aggregation: view.Sum(),
+		TagKeys:     []tag.Key{envNameTagKey},
+	}
+	eventSendFailuresView *view.View = &view.View{ //nolint:gochecknoglobals
+		Measure:     eventSendFailuresMeasure,
+		Aggregation: view.Sum(),
+		TagKeys:     []tag.Key{envNameTagKey, statusCodeTagKey},
+	}
+	eventSendRetriesView *view.View = &view.View{ //nolint:gochecknoglobals
+		Measure:     eventSendRetriesMeasure,
+		Aggregation: view.Sum(),
+		TagKeys:     []tag.Key{envNameTagKey, statusCodeTagKey},
+	}
+	eventQueueDepthView *view.View = &view.View{ //nolint:gochecknoglobals
+		Measure:     eventQueueDepthMeasure,
+		Aggregation: view.LastValue(),
+		TagKeys:     []tag.Key{envNameTagKey},
+	}
+	tlsCertificateExpiryView *view.View = &view.View{ //nolint:gochecknoglobals
+		Measure:     tlsCertificateExpiryMeasure,
+		Aggregation: view.LastValue(),
+	}
+	storeInconsistentItemsView *view.View = &view.View{ //nolint:gochecknoglobals
+		Measure:     storeInconsistentItemsMeasure,
+		Aggregation: view.LastValue(),
+		TagKeys:     []tag.Key{envNameTagKey, reasonTagKey},
+	}
 	privateConnView *view.View = &view.View{ //nolint:gochecknoglobals
 		Measure:     privateConnMeasure,
 		Aggregation: view.Sum(),
@@ -43,7 +108,11 @@ var (
 )
 
 func getPublicViews() []*view.View {
-	return []*view.View{publicConnView, publicNewConnView, requestView}
+	return []*view.View{publicConnView, publicNewConnView, requestView, slowStreamConsumersView,
+		requestDurationView, storeReadDurationView, evaluationDurationView,
+		eventsReceivedView, eventsForwardedView, eventPayloadsForwardedView, eventsDroppedView,
+		eventSendFailuresView, eventSendRetriesView, eventQueueDepthView, tlsCertificateExpiryView,
+		storeInconsistentItemsView}
 }
 
 func getPrivateViews() []*view.View {
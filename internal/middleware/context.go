@@ -20,6 +20,9 @@ type EnvContextInfo struct {
 
 	// Credential is the SDK key, mobile key, or environment ID that was used in the request.
 	Credential credential.SDKCredential
+
+	// RequestID is the ID that was assigned to the request by logging.RequestIDMiddleware, if any.
+	RequestID string
 }
 
 // GetEnvContextInfo returns the EnvContextInfo that is attached to the specified Context (normally
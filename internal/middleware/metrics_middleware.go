@@ -2,8 +2,11 @@ package middleware
 
 import (
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/launchdarkly/ld-relay/v8/internal/metrics"
+	"github.com/launchdarkly/ld-relay/v8/internal/streams"
 
 	"github.com/gorilla/mux"
 )
@@ -28,22 +31,42 @@ func withGauge(handler http.Handler, measure metrics.Measure) http.Handler {
 	})
 }
 
+func withSlowConsumerCount(handler http.Handler, measure metrics.Measure) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ctx := GetEnvContextInfo(req.Context())
+		userAgent := getUserAgent(req)
+		listener := func(e streams.SlowConsumerEviction) {
+			ctx.Env.GetLoggers().Warnf(
+				"Closed stream connection from slow client (reason: %s, queued events: %d, last write time: %s)",
+				e.Reason, e.QueuedEvents, e.WriteLatency)
+			metrics.RecordWithReason(ctx.Env.GetMetricsContext(), userAgent, string(e.Reason), measure)
+		}
+		handler.ServeHTTP(w, req.WithContext(streams.WithSlowConsumerListener(req.Context(), listener)))
+	})
+}
+
 // CountMobileConns is a middleware function that increments the total number of mobile connections,
-// and also increments the number of active mobile connections until the handler ends.
+// and also increments the number of active mobile connections until the handler ends. It also counts
+// connections that are closed for not keeping up with the stream.
 func CountMobileConns(handler http.Handler) http.Handler {
-	return withCount(withGauge(handler, metrics.MobileConns), metrics.NewMobileConns)
+	return withCount(withGauge(withSlowConsumerCount(handler, metrics.MobileSlowStreamConsumers),
+		metrics.MobileConns), metrics.NewMobileConns)
 }
 
 // CountBrowserConns is a middleware function that increments the total number of browser connections,
-// and also increments the number of active browser connections until the handler ends.
+// and also increments the number of active browser connections until the handler ends. It also counts
+// connections that are closed for not keeping up with the stream.
 func CountBrowserConns(handler http.Handler) http.Handler {
-	return withCount(withGauge(handler, metrics.BrowserConns), metrics.NewBrowserConns)
+	return withCount(withGauge(withSlowConsumerCount(handler, metrics.BrowserSlowStreamConsumers),
+		metrics.BrowserConns), metrics.NewBrowserConns)
 }
 
 // CountServerConns is a middleware function that increments the total number of server-side connections,
-// and also increments the number of active server-side connections until the handler ends.
+// and also increments the number of active server-side connections until the handler ends. It also counts
+// connections that are closed for not keeping up with the stream.
 func CountServerConns(handler http.Handler) http.Handler {
-	return withCount(withGauge(handler, metrics.ServerConns), metrics.NewServerConns)
+	return withCount(withGauge(withSlowConsumerCount(handler, metrics.ServerSlowStreamConsumers),
+		metrics.ServerConns), metrics.NewServerConns)
 }
 
 // PollingRequestCount is a middleware function that increments the total number of server-side polling requests.
@@ -51,7 +74,9 @@ func PollingRequestCount(handler http.Handler) http.Handler {
 	return withCount(handler, metrics.PollingRequests)
 }
 
-// RequestCount is a middleware function that increments the specified metric for each request.
+// RequestCount is a middleware function that increments the specified metric for each request. It also
+// records how long it took to respond to the request, except for streaming requests, whose duration is
+// just the lifetime of the connection.
 func RequestCount(measure metrics.Measure) mux.MiddlewareFunc {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
@@ -59,9 +84,56 @@ func RequestCount(measure metrics.Measure) mux.MiddlewareFunc {
 			userAgent := getUserAgent(req)
 			// Ignoring internal routing error that would have been ignored anyway
 			route, _ := mux.CurrentRoute(req).GetPathTemplate()
-			metrics.WithRouteCount(ctx.Env.GetMetricsContext(), userAgent, route, req.Method, func() {
-				next.ServeHTTP(w, req)
+			metricsCtx := metrics.WithRequestTraceContext(ctx.Env.GetMetricsContext(), req)
+			sw := &statusRecordingResponseWriter{ResponseWriter: w}
+			startTime := time.Now()
+			metrics.WithRouteCount(metricsCtx, userAgent, route, req.Method, func() {
+				next.ServeHTTP(sw, req)
 			}, measure)
+			if !sw.streaming {
+				metrics.RecordRequestDuration(ctx.Env.GetMetricsContext(), route, req.Method, sw.getStatus(),
+					time.Since(startTime), measure)
+			}
 		})
 	}
 }
+
+// statusRecordingResponseWriter remembers the response status and whether the response is a stream. It
+// implements Unwrap so that http.ResponseController can still reach the underlying connection.
+type statusRecordingResponseWriter struct {
+	http.ResponseWriter
+	status    int
+	streaming bool
+}
+
+func (w *statusRecordingResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+		w.streaming = strings.Contains(w.Header().Get("Content-Type"), "text/event-stream")
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusRecordingResponseWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *statusRecordingResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *statusRecordingResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *statusRecordingResponseWriter) getStatus() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
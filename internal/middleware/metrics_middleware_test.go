@@ -15,6 +15,7 @@ import (
 	"github.com/launchdarkly/go-sdk-common/v3/ldlogtest"
 
 	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -167,5 +168,50 @@ func testCountRequests(t *testing.T, measure metrics.Measure, category string) {
 				Count: 2,
 			})
 		})
+
+		p.exporter.AwaitData(t, time.Second, p.mockLog.Loggers, func(d st.TestMetricsData) bool {
+			return d.HasRow("request_duration", st.TestMetricsRow{
+				Tags: map[string]string{
+					"env":              p.envName,
+					"method":           "GET",
+					"route":            "_test-route",
+					"platformCategory": category,
+					"statusCode":       "200",
+				},
+				Count: 2,
+			})
+		})
+	})
+}
+
+func TestStatusRecordingResponseWriter(t *testing.T) {
+	t.Run("defaults to 200", func(t *testing.T) {
+		w := &statusRecordingResponseWriter{ResponseWriter: httptest.NewRecorder()}
+		assert.Equal(t, http.StatusOK, w.getStatus())
+		_, _ = w.Write([]byte("x"))
+		assert.Equal(t, http.StatusOK, w.getStatus())
+		assert.False(t, w.streaming)
+	})
+
+	t.Run("records first status", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		w := &statusRecordingResponseWriter{ResponseWriter: rr}
+		w.WriteHeader(http.StatusServiceUnavailable)
+		assert.Equal(t, http.StatusServiceUnavailable, w.getStatus())
+		assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	})
+
+	t.Run("detects stream", func(t *testing.T) {
+		w := &statusRecordingResponseWriter{ResponseWriter: httptest.NewRecorder()}
+		w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		assert.True(t, w.streaming)
+	})
+
+	t.Run("can be unwrapped", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		w := &statusRecordingResponseWriter{ResponseWriter: rr}
+		assert.NoError(t, http.NewResponseController(w).Flush())
+		assert.True(t, rr.Flushed)
 	})
 }
@@ -1,11 +1,16 @@
 package middleware
 
 import (
+	"context"
+	"crypto/subtle"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	ct "github.com/launchdarkly/go-configtypes"
 
@@ -15,6 +20,7 @@ import (
 	"github.com/launchdarkly/ld-relay/v8/config"
 	"github.com/launchdarkly/ld-relay/v8/internal/basictypes"
 	"github.com/launchdarkly/ld-relay/v8/internal/browser"
+	"github.com/launchdarkly/ld-relay/v8/internal/logging"
 	"github.com/launchdarkly/ld-relay/v8/internal/relayenv"
 	"github.com/launchdarkly/ld-relay/v8/internal/sdks"
 
@@ -28,11 +34,20 @@ const (
 	userAgentHeader   = "user-agent"
 	ldUserAgentHeader = "X-LaunchDarkly-User-Agent"
 
-	httpStatusMessageInvalidEnvCredential  = "Relay Proxy does not recognize the client credential (missing or invalid Authorization header)"
-	httpStatusMessageNotFullyConfigured    = "Relay Proxy is not yet fully initialized, does not have list of environments yet"
-	httpStatusMessagePayloadFilterNotFound = "Relay Proxy recognizes the provided credential, but the payload filter was not found"
-	httpStatusMessageMissingEnvURLParam    = "URL did not contain an environment ID"
-	httpStatusMessageSDKClientNotInited    = "client was not initialized"
+	// StaleDataHeader is added to responses with the value "true" if the environment is serving data from
+	// the data store that may be out of date, because the SDK client has not initialized; see
+	// relayenv.EnvContext.IsServingStaleData.
+	StaleDataHeader = browser.StaleDataHeader
+
+	httpStatusMessageInvalidEnvCredential     = "Relay Proxy does not recognize the client credential (missing or invalid Authorization header)"
+	httpStatusMessageNotFullyConfigured       = "Relay Proxy is not yet fully initialized, does not have list of environments yet"
+	httpStatusMessagePayloadFilterNotFound    = "Relay Proxy recognizes the provided credential, but the payload filter was not found"
+	httpStatusMessageMissingEnvURLParam       = "URL did not contain an environment ID"
+	httpStatusMessageSDKClientNotInited       = "client was not initialized"
+	httpStatusMessageTooManyStreamConnections = "Relay Proxy has reached its limit of concurrent stream connections"
+	httpStatusMessageClientCertNotAllowed     = "TLS client certificate is not allowed to access this environment"
+	httpStatusMessageInvalidAdminKey          = "Relay Proxy does not recognize the admin key (missing or invalid Authorization header)"
+	httpStatusMessageInvalidAutoConfigKey     = "Relay Proxy does not recognize the auto-configuration key (missing or invalid Authorization header)"
 )
 
 var (
@@ -74,6 +89,46 @@ func Chain(middlewares ...mux.MiddlewareFunc) mux.MiddlewareFunc {
 	}
 }
 
+// RequireAdminKey creates a middleware function that rejects the request with a 401 error unless its
+// Authorization header is the configured admin key.
+func RequireAdminKey(adminKey string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if adminKey == "" ||
+				subtle.ConstantTimeCompare([]byte(req.Header.Get("Authorization")), []byte(adminKey)) != 1 {
+				w.WriteHeader(http.StatusUnauthorized)
+				_, _ = w.Write([]byte(httpStatusMessageInvalidAdminKey))
+				return
+			}
+			next.ServeHTTP(w, req)
+		})
+	}
+}
+
+// RequireAutoConfigKey creates a middleware function that rejects requests with a 401 status unless the
+// Authorization header is one of the specified auto-configuration keys. This is for auto-configuration
+// requests that Relay passes through to LaunchDarkly on behalf of downstream Relays.
+func RequireAutoConfigKey(keys []string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			authKey := []byte(req.Header.Get("Authorization"))
+			matched := false
+			for _, key := range keys {
+				// check every key, so the response time doesn't reveal which one matched
+				if key != "" && subtle.ConstantTimeCompare(authKey, []byte(key)) == 1 {
+					matched = true
+				}
+			}
+			if !matched {
+				w.WriteHeader(http.StatusUnauthorized)
+				_, _ = w.Write([]byte(httpStatusMessageInvalidAutoConfigKey))
+				return
+			}
+			next.ServeHTTP(w, req)
+		})
+	}
+}
+
 // SelectEnvironmentByAuthorizationKey creates a middleware function that attempts to authenticate the request
 // using the appropriate kind of credential for the basictypes.SDKKind. If successful, it updates the request context
 // so GetEnvContextInfo will return environment information. If not successful, it returns an error response.
@@ -116,16 +171,33 @@ func SelectEnvironmentByAuthorizationKey(sdkKind basictypes.SDKKind, envs RelayE
 				return
 			}
 
+			if allowedNames := clientCtx.GetAllowedClientCertNames(); len(allowedNames) != 0 &&
+				!clientCertMatchesAnyName(req, allowedNames) {
+				w.WriteHeader(http.StatusForbidden)
+				_, _ = w.Write([]byte(httpStatusMessageClientCertNotAllowed))
+				return
+			}
+
 			if clientCtx.GetClient() == nil {
 				w.WriteHeader(http.StatusServiceUnavailable)
 				_, _ = w.Write([]byte(httpStatusMessageSDKClientNotInited))
 				return
 			}
 
+			if clientCtx.IsServingStaleData() {
+				w.Header().Set(StaleDataHeader, "true")
+			}
+
 			contextInfo := EnvContextInfo{
 				Env:        clientCtx,
 				Credential: credential,
+				RequestID:  logging.GetRequestID(req.Context()),
 			}
+			logging.AddRequestLogFields(req.Context(),
+				logging.Field{Key: "sdkKind", Value: string(sdkKind)},
+				logging.Field{Key: "envId", Value: string(relayenv.GetEnvironmentID(clientCtx))},
+				logging.Field{Key: "envName", Value: clientCtx.GetIdentifiers().GetDisplayName()},
+			)
 			req = req.WithContext(WithEnvContextInfo(req.Context(), contextInfo))
 			if sdkKind == basictypes.JSClientSDK {
 				req = req.WithContext(browser.WithCORSContext(req.Context(), clientCtx.GetJSClientContext()))
@@ -135,6 +207,36 @@ func SelectEnvironmentByAuthorizationKey(sdkKind basictypes.SDKKind, envs RelayE
 	}
 }
 
+// clientCertMatchesAnyName returns true if the request was made over TLS with a client certificate that
+// the server verified, and the certificate's subject common name or one of its subject alternative names
+// (DNS name, URI, email address, or IP address) is exactly equal to one of the specified names.
+func clientCertMatchesAnyName(req *http.Request, names []string) bool {
+	if req.TLS == nil || len(req.TLS.VerifiedChains) == 0 || len(req.TLS.VerifiedChains[0]) == 0 {
+		return false
+	}
+	cert := req.TLS.VerifiedChains[0][0]
+	certNames := []string{cert.Subject.CommonName}
+	certNames = append(certNames, cert.DNSNames...)
+	certNames = append(certNames, cert.EmailAddresses...)
+	for _, u := range cert.URIs {
+		certNames = append(certNames, u.String())
+	}
+	for _, ip := range cert.IPAddresses {
+		certNames = append(certNames, ip.String())
+	}
+	for _, certName := range certNames {
+		if certName == "" {
+			continue
+		}
+		for _, name := range names {
+			if certName == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // CORS is a middleware function that sets the appropriate CORS headers on a browser response
 // (not counting Access-Control-Allow-Methods, which is set by gorilla/mux's CORS middleware
 // based on the route handlers we've defined).
@@ -183,6 +285,42 @@ func Streaming(next http.Handler) http.Handler {
 	})
 }
 
+// LimitStreamConnections creates a middleware function that enforces the environment's limits on
+// concurrent stream connections, as defined by its streams.ConnectionLimiter.
+//
+// If there is no room for the connection, the request fails with a 503 status and a Retry-After header
+// derived from retryAfter. If the limiter is configured to shed old connections instead, the new connection
+// is accepted and the oldest one is closed; that connection's request context is cancelled, so its SSE
+// handler exits just as it would for a client disconnect.
+//
+// CORS preflight (OPTIONS) requests do not open a stream, so they are not counted against the limits.
+func LimitStreamConnections(retryAfter time.Duration) mux.MiddlewareFunc {
+	retryAfterSeconds := strconv.Itoa(int(math.Ceil(retryAfter.Seconds())))
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if req.Method == http.MethodOptions {
+				next.ServeHTTP(w, req)
+				return
+			}
+			envInfo := GetEnvContextInfo(req.Context())
+			limiter := envInfo.Env.GetStreamConnectionLimiter()
+			ctx, cancel := context.WithCancel(req.Context())
+			defer cancel()
+			release, ok := limiter.Acquire(cancel)
+			if !ok {
+				envInfo.Env.GetLoggers().Debug("Rejected stream connection: limit exceeded",
+					logging.Fields{{Key: "requestId", Value: envInfo.RequestID}})
+				w.Header().Set("Retry-After", retryAfterSeconds)
+				w.WriteHeader(http.StatusServiceUnavailable)
+				_, _ = w.Write([]byte(httpStatusMessageTooManyStreamConnections))
+				return
+			}
+			defer release()
+			next.ServeHTTP(w, req.WithContext(ctx))
+		})
+	}
+}
+
 // ContextFromBase64 decodes a base64-encoded go-server-sdk evaluation context.
 // If any decoding/unmarshaling errors occur, or the decoded context is invalid by the rules of the Go SDK, an error is returned.
 func ContextFromBase64(base64Context string) (ldcontext.Context, error) {
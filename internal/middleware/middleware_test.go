@@ -1,6 +1,9 @@
 package middleware
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/base64"
 	"errors"
 	"net/http"
@@ -17,12 +20,15 @@ import (
 
 	"github.com/launchdarkly/ld-relay/v8/internal/basictypes"
 	"github.com/launchdarkly/ld-relay/v8/internal/browser"
+	"github.com/launchdarkly/ld-relay/v8/internal/logging"
 	"github.com/launchdarkly/ld-relay/v8/internal/relayenv"
 	st "github.com/launchdarkly/ld-relay/v8/internal/sharedtest"
 	"github.com/launchdarkly/ld-relay/v8/internal/sharedtest/testclient"
 	"github.com/launchdarkly/ld-relay/v8/internal/sharedtest/testenv"
 
 	"github.com/launchdarkly/go-sdk-common/v3/ldcontext"
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	"github.com/launchdarkly/go-sdk-common/v3/ldlogtest"
 
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
@@ -101,6 +107,27 @@ func (t testEnvironments) GetAllEnvironments() []relayenv.EnvContext {
 	return ret
 }
 
+type testEnvWithAllowedClientCertNames struct {
+	relayenv.EnvContext
+	names []string
+}
+
+func (e testEnvWithAllowedClientCertNames) GetAllowedClientCertNames() []string { return e.names }
+
+type testEnvServingStaleData struct {
+	relayenv.EnvContext
+}
+
+func (e testEnvServingStaleData) IsServingStaleData() bool { return true }
+
+func withVerifiedClientCert(req *http.Request, cert *x509.Certificate) *http.Request {
+	req.TLS = &tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{cert},
+		VerifiedChains:   [][]*x509.Certificate{{cert}},
+	}
+	return req
+}
+
 type testCORSContext struct {
 	origins []string
 	headers []string
@@ -149,6 +176,66 @@ func TestGetUserAgent(t *testing.T) {
 	})
 }
 
+func TestRequireAdminKey(t *testing.T) {
+	handler := RequireAdminKey("admin-key")(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	for _, p := range []struct {
+		name   string
+		key    string
+		status int
+	}{
+		{"valid key", "admin-key", http.StatusNoContent},
+		{"invalid key", "wrong-key", http.StatusUnauthorized},
+		{"no key", "", http.StatusUnauthorized},
+	} {
+		t.Run(p.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/", nil)
+			if p.key != "" {
+				req.Header.Set("Authorization", p.key)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			assert.Equal(t, p.status, w.Result().StatusCode)
+		})
+	}
+
+	t.Run("empty admin key never matches", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/", nil)
+		w := httptest.NewRecorder()
+		RequireAdminKey("")(handler).ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Result().StatusCode)
+	})
+}
+
+func TestRequireAutoConfigKey(t *testing.T) {
+	handler := RequireAutoConfigKey([]string{"rel-abc", "rel-def"})(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	for _, p := range []struct {
+		name   string
+		key    string
+		status int
+	}{
+		{"first key", "rel-abc", http.StatusNoContent},
+		{"second key", "rel-def", http.StatusNoContent},
+		{"invalid key", "rel-xyz", http.StatusUnauthorized},
+		{"no key", "", http.StatusUnauthorized},
+	} {
+		t.Run(p.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/", nil)
+			if p.key != "" {
+				req.Header.Set("Authorization", p.key)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			assert.Equal(t, p.status, w.Result().StatusCode)
+		})
+	}
+}
+
 func TestSelectEnvironmentByAuthorizationKey(t *testing.T) {
 	env1 := testenv.NewTestEnvContext("env1", false, nil)
 	env2 := testenv.NewTestEnvContext("env2", false, nil)
@@ -282,6 +369,30 @@ func TestSelectEnvironmentByAuthorizationKey(t *testing.T) {
 		assert.Equal(t, env2, <-envCh)
 	})
 
+	t.Run("adds request ID and request log fields", func(t *testing.T) {
+		envs := testEnvironments{
+			envs: map[sdkauth.ScopedCredential]relayenv.EnvContext{
+				sdkauth.New(st.EnvMain.Config.SDKKey): env1,
+			},
+		}
+		selector := SelectEnvironmentByAuthorizationKey(basictypes.ServerSDK, envs)
+		mockLog := ldlogtest.NewMockLog()
+		mockLog.Loggers.SetMinLevel(ldlog.Debug)
+		requestIDCh := make(chan string, 1)
+		handler := logging.RequestIDMiddleware(logging.RequestLoggerMiddleware(mockLog.Loggers)(
+			selector(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				requestIDCh <- GetEnvContextInfo(req.Context()).RequestID
+			}))))
+
+		req := buildPreRoutedRequestWithAuth(st.EnvMain.Config.SDKKey)
+		req.Header.Set(logging.RequestIDHeader, "my-request")
+		resp, _ := st.DoRequest(req, handler)
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "my-request", <-requestIDCh)
+		mockLog.AssertMessageMatch(t, true, ldlog.Debug, "requestId=my-request sdkKind=server envId= envName=env1")
+	})
+
 	t.Run("rejects unknown SDK key", func(t *testing.T) {
 		envs := testEnvironments{
 			envs: map[sdkauth.ScopedCredential]relayenv.EnvContext{sdkauth.New(st.EnvMain.Config.SDKKey): env1},
@@ -382,6 +493,89 @@ func TestSelectEnvironmentByAuthorizationKey(t *testing.T) {
 
 		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
 	})
+
+	t.Run("client certificate names", func(t *testing.T) {
+		restrictedEnv := testEnvWithAllowedClientCertNames{
+			EnvContext: env1,
+			names:      []string{"service-a", "spiffe://example/service-b"},
+		}
+		envs := testEnvironments{
+			envs: map[sdkauth.ScopedCredential]relayenv.EnvContext{
+				sdkauth.New(st.EnvMain.Config.SDKKey): restrictedEnv,
+			},
+		}
+		selector := SelectEnvironmentByAuthorizationKey(basictypes.ServerSDK, envs)
+		serviceBURI, _ := url.Parse("spiffe://example/service-b")
+
+		t.Run("allows certificate with matching common name", func(t *testing.T) {
+			req := withVerifiedClientCert(buildPreRoutedRequestWithAuth(st.EnvMain.Config.SDKKey),
+				&x509.Certificate{Subject: pkix.Name{CommonName: "service-a"}})
+			resp, _ := st.DoRequest(req, selector(nullHandler()))
+
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+		})
+
+		t.Run("allows certificate with matching subject alternative name", func(t *testing.T) {
+			req := withVerifiedClientCert(buildPreRoutedRequestWithAuth(st.EnvMain.Config.SDKKey),
+				&x509.Certificate{Subject: pkix.Name{CommonName: "other"}, URIs: []*url.URL{serviceBURI}})
+			resp, _ := st.DoRequest(req, selector(nullHandler()))
+
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+		})
+
+		t.Run("rejects certificate with no matching name", func(t *testing.T) {
+			req := withVerifiedClientCert(buildPreRoutedRequestWithAuth(st.EnvMain.Config.SDKKey),
+				&x509.Certificate{Subject: pkix.Name{CommonName: "service-c"}, DNSNames: []string{"service-c.example"}})
+			resp, body := st.DoRequest(req, selector(nullHandler()))
+
+			assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+			assert.Equal(t, httpStatusMessageClientCertNotAllowed, string(body))
+		})
+
+		t.Run("rejects request without verified certificate", func(t *testing.T) {
+			req := buildPreRoutedRequestWithAuth(st.EnvMain.Config.SDKKey)
+			req.TLS = &tls.ConnectionState{
+				PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: "service-a"}}},
+			}
+			resp, _ := st.DoRequest(req, selector(nullHandler()))
+
+			assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+		})
+
+		t.Run("still requires valid credential", func(t *testing.T) {
+			req := withVerifiedClientCert(buildPreRoutedRequestWithAuth(st.UndefinedSDKKey),
+				&x509.Certificate{Subject: pkix.Name{CommonName: "service-a"}})
+			resp, _ := st.DoRequest(req, selector(nullHandler()))
+
+			assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		})
+	})
+
+	t.Run("stale data header", func(t *testing.T) {
+		t.Run("is not added if environment has current data", func(t *testing.T) {
+			envs := testEnvironments{
+				envs: map[sdkauth.ScopedCredential]relayenv.EnvContext{sdkauth.New(st.EnvMain.Config.SDKKey): env1},
+			}
+			selector := SelectEnvironmentByAuthorizationKey(basictypes.ServerSDK, envs)
+			resp, _ := st.DoRequest(buildPreRoutedRequestWithAuth(st.EnvMain.Config.SDKKey), selector(nullHandler()))
+
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, "", resp.Header.Get(StaleDataHeader))
+		})
+
+		t.Run("is added if environment is serving stale data", func(t *testing.T) {
+			envs := testEnvironments{
+				envs: map[sdkauth.ScopedCredential]relayenv.EnvContext{
+					sdkauth.New(st.EnvMain.Config.SDKKey): testEnvServingStaleData{env1},
+				},
+			}
+			selector := SelectEnvironmentByAuthorizationKey(basictypes.ServerSDK, envs)
+			resp, _ := st.DoRequest(buildPreRoutedRequestWithAuth(st.EnvMain.Config.SDKKey), selector(nullHandler()))
+
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, "true", resp.Header.Get(StaleDataHeader))
+		})
+	})
 }
 
 func TestCORSMiddlewareSetsCorrectDefaultHeaders(t *testing.T) {
@@ -394,7 +588,7 @@ func TestCORSMiddlewareSetsCorrectDefaultHeaders(t *testing.T) {
 	assert.Equal(t, "false", resp.Result().Header.Get("Access-Control-Allow-Credentials"))
 	assert.Equal(t, "300", resp.Result().Header.Get("Access-Control-Max-Age"))
 	assert.Equal(t, browser.DefaultAllowedHeaders, resp.Result().Header.Get("Access-Control-Allow-Headers"))
-	assert.Equal(t, "Date", resp.Result().Header.Get("Access-Control-Expose-Headers"))
+	assert.Equal(t, "Date,"+StaleDataHeader, resp.Result().Header.Get("Access-Control-Expose-Headers"))
 }
 
 func TestCORSMiddlewareSetsCorrectDefaultHeadersWhenRequestHasOrigin(t *testing.T) {
@@ -0,0 +1,118 @@
+package migration
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v8/config"
+	"github.com/launchdarkly/ld-relay/v8/internal/autoconfig"
+	"github.com/launchdarkly/ld-relay/v8/internal/envfactory"
+	"github.com/launchdarkly/ld-relay/v8/internal/httpconfig"
+	"github.com/launchdarkly/ld-relay/v8/internal/projmanager"
+	"github.com/launchdarkly/ld-relay/v8/relay/version"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+)
+
+const (
+	// This is the same auto-configuration protocol version that Relay uses, so that we get the same
+	// environments, including the ones for payload filters.
+	autoConfigProtocolVersion = 2
+
+	autoConfigTimeout = time.Minute
+)
+
+var errAutoConfigTimeout = errors.New("timed out waiting for the auto-configuration environments from LaunchDarkly")
+
+// autoConfigEnvironmentsFunc gets the environments that LaunchDarkly provides for a configuration that
+// uses an auto-configuration key.
+type autoConfigEnvironmentsFunc func(allConfig config.Config, loggers ldlog.Loggers) ([]envfactory.EnvironmentParams, error)
+
+// autoConfigEnvironmentCollector is an implementation of projmanager.AutoConfigActions that keeps track of
+// the environments from the auto-configuration stream, until it has received all of them.
+type autoConfigEnvironmentCollector struct {
+	envs     map[scopedEnvironmentID]envfactory.EnvironmentParams
+	doneCh   chan struct{}
+	doneOnce sync.Once
+	lock     sync.Mutex
+}
+
+type scopedEnvironmentID struct {
+	envID     config.EnvironmentID
+	filterKey config.FilterKey
+}
+
+// getAutoConfigEnvironments connects to the auto-configuration stream just long enough to get the current
+// list of environments, in the same way as Relay does when it starts up.
+func getAutoConfigEnvironments(allConfig config.Config, loggers ldlog.Loggers) ([]envfactory.EnvironmentParams, error) {
+	httpConfig, err := httpconfig.NewHTTPConfig(allConfig.Proxy, allConfig.AutoConfig.Key,
+		"LDRelay/"+version.Version, loggers)
+	if err != nil {
+		return nil, err
+	}
+	collector := &autoConfigEnvironmentCollector{
+		envs:   make(map[scopedEnvironmentID]envfactory.EnvironmentParams),
+		doneCh: make(chan struct{}),
+	}
+	stream := autoconfig.NewStreamManager(
+		allConfig.AutoConfig.Key,
+		allConfig.Main.StreamURI.Get(),
+		projmanager.NewProjectRouter(collector, loggers),
+		httpConfig,
+		0,
+		autoConfigProtocolVersion,
+		loggers,
+	)
+	defer stream.Close()
+
+	startCh := stream.Start()
+	timeout := time.After(autoConfigTimeout)
+	for {
+		select {
+		case err := <-startCh:
+			if err != nil {
+				return nil, err
+			}
+			startCh = nil
+		case <-collector.doneCh:
+			return collector.getEnvironments(), nil
+		case <-timeout:
+			return nil, errAutoConfigTimeout
+		}
+	}
+}
+
+func (c *autoConfigEnvironmentCollector) AddEnvironment(params envfactory.EnvironmentParams) {
+	c.lock.Lock()
+	c.envs[scopedEnvironmentID{params.EnvID, params.Identifiers.FilterKey}] = params
+	c.lock.Unlock()
+}
+
+func (c *autoConfigEnvironmentCollector) UpdateEnvironment(params envfactory.EnvironmentParams) {
+	c.AddEnvironment(params)
+}
+
+func (c *autoConfigEnvironmentCollector) DeleteEnvironment(id config.EnvironmentID, filter config.FilterKey) {
+	c.lock.Lock()
+	delete(c.envs, scopedEnvironmentID{id, filter})
+	c.lock.Unlock()
+}
+
+func (c *autoConfigEnvironmentCollector) ReceivedAllEnvironments() {
+	c.doneOnce.Do(func() { close(c.doneCh) })
+}
+
+func (c *autoConfigEnvironmentCollector) getEnvironments() []envfactory.EnvironmentParams {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	ret := make([]envfactory.EnvironmentParams, 0, len(c.envs))
+	for _, params := range c.envs {
+		ret = append(ret, params)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Identifiers.GetDisplayName() < ret[j].Identifiers.GetDisplayName()
+	})
+	return ret
+}
@@ -0,0 +1,64 @@
+package migration
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/launchdarkly/ld-relay/v8/config"
+	"github.com/launchdarkly/ld-relay/v8/internal/autoconfig"
+	"github.com/launchdarkly/ld-relay/v8/internal/envfactory"
+
+	ct "github.com/launchdarkly/go-configtypes"
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	"github.com/launchdarkly/go-test-helpers/v3/httphelpers"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func makeAutoConfigTestConfig(t *testing.T, streamURI string) config.Config {
+	var c config.Config
+	c.AutoConfig.Key = "auto-config-key"
+	var err error
+	c.Main.StreamURI, err = ct.NewOptURLAbsoluteFromString(streamURI)
+	require.NoError(t, err)
+	return c
+}
+
+func TestGetAutoConfigEnvironments(t *testing.T) {
+	env1 := envfactory.EnvironmentRep{EnvID: "id1", EnvName: "env1", ProjKey: "proj1", ProjName: "project1",
+		SDKKey: envfactory.SDKKeyRep{Value: "sdk-key1"}, Version: 1}
+	env2 := envfactory.EnvironmentRep{EnvID: "id2", EnvName: "env2", ProjKey: "proj2", ProjName: "project2",
+		SDKKey: envfactory.SDKKeyRep{Value: "sdk-key2"}, Version: 1}
+	filter := envfactory.FilterRep{ProjKey: "proj1", FilterKey: "filter1", Version: 1}
+	data, err := json.Marshal(map[string]interface{}{
+		"path": "/",
+		"data": map[string]interface{}{
+			"environments": map[string]interface{}{"id1": env1, "id2": env2},
+			"filters":      map[string]interface{}{"proj1.filter1": filter},
+		},
+	})
+	require.NoError(t, err)
+	handler, stream := httphelpers.SSEHandler(&httphelpers.SSEEvent{Event: autoconfig.PutEvent, Data: string(data)})
+	defer stream.Close()
+
+	httphelpers.WithServer(handler, func(server *httptest.Server) {
+		envs, err := getAutoConfigEnvironments(makeAutoConfigTestConfig(t, server.URL), ldlog.NewDisabledLoggers())
+		require.NoError(t, err)
+		assert.Equal(t, []envfactory.EnvironmentParams{
+			env1.ToParams(),
+			env1.ToParams().WithFilter("filter1"),
+			env2.ToParams(),
+		}, envs)
+	})
+}
+
+func TestGetAutoConfigEnvironmentsWithInvalidKey(t *testing.T) {
+	handler := httphelpers.HandlerWithStatus(http.StatusUnauthorized)
+	httphelpers.WithServer(handler, func(server *httptest.Server) {
+		_, err := getAutoConfigEnvironments(makeAutoConfigTestConfig(t, server.URL), ldlog.NewDisabledLoggers())
+		assert.Error(t, err)
+	})
+}
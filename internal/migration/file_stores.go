@@ -0,0 +1,101 @@
+package migration
+
+import (
+	"errors"
+
+	"github.com/launchdarkly/ld-relay/v8/internal/envfactory"
+	"github.com/launchdarkly/ld-relay/v8/internal/filedata"
+
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoretypes"
+)
+
+var errReadOnlyDataFile = errors.New("the source data file cannot be modified")
+
+// fileSourceStore is a read-only PersistentDataStore for one environment's data from a data file, so that
+// the data can be copied and verified in the same way as data from a database.
+type fileSourceStore struct {
+	sdkData []ldstoretypes.Collection
+}
+
+// fileDestinationStore is a PersistentDataStore that adds one environment to a data file. The data is
+// read back from what was written, so the usual verification checks what will be in the file.
+type fileDestinationStore struct {
+	writer *filedata.ArchiveWriter
+	rep    envfactory.EnvironmentRep
+	inited bool
+}
+
+func (s *fileSourceStore) Init([]ldstoretypes.SerializedCollection) error {
+	return errReadOnlyDataFile
+}
+
+func (s *fileSourceStore) Get(ldstoretypes.DataKind, string) (ldstoretypes.SerializedItemDescriptor, error) {
+	return ldstoretypes.SerializedItemDescriptor{}, errors.New("not implemented")
+}
+
+func (s *fileSourceStore) GetAll(kind ldstoretypes.DataKind) ([]ldstoretypes.KeyedSerializedItemDescriptor, error) {
+	return serializeItems(kind, s.sdkData), nil
+}
+
+func (s *fileSourceStore) Upsert(ldstoretypes.DataKind, string, ldstoretypes.SerializedItemDescriptor) (bool, error) {
+	return false, errReadOnlyDataFile
+}
+
+func (s *fileSourceStore) IsInitialized() bool { return true }
+
+func (s *fileSourceStore) IsStoreAvailable() bool { return true }
+
+func (s *fileSourceStore) Close() error { return nil }
+
+func (s *fileDestinationStore) Init(allData []ldstoretypes.SerializedCollection) error {
+	if err := s.writer.AddEnvironment(s.rep, allData); err != nil {
+		return err
+	}
+	s.inited = true
+	return nil
+}
+
+func (s *fileDestinationStore) Get(ldstoretypes.DataKind, string) (ldstoretypes.SerializedItemDescriptor, error) {
+	return ldstoretypes.SerializedItemDescriptor{}, errors.New("not implemented")
+}
+
+func (s *fileDestinationStore) GetAll(kind ldstoretypes.DataKind) ([]ldstoretypes.KeyedSerializedItemDescriptor, error) {
+	if !s.inited {
+		return nil, nil
+	}
+	sdkData, err := s.writer.GetEnvironmentSDKData(s.rep.EnvID)
+	if err != nil {
+		return nil, err
+	}
+	return serializeItems(kind, sdkData), nil
+}
+
+func (s *fileDestinationStore) Upsert(ldstoretypes.DataKind, string, ldstoretypes.SerializedItemDescriptor) (bool, error) {
+	return false, errors.New("not implemented")
+}
+
+func (s *fileDestinationStore) IsInitialized() bool { return s.inited }
+
+func (s *fileDestinationStore) IsStoreAvailable() bool { return true }
+
+func (s *fileDestinationStore) Close() error { return nil }
+
+func serializeItems(kind ldstoretypes.DataKind, allData []ldstoretypes.Collection) []ldstoretypes.KeyedSerializedItemDescriptor {
+	var ret []ldstoretypes.KeyedSerializedItemDescriptor
+	for _, coll := range allData {
+		if coll.Kind != kind {
+			continue
+		}
+		for _, item := range coll.Items {
+			ret = append(ret, ldstoretypes.KeyedSerializedItemDescriptor{
+				Key: item.Key,
+				Item: ldstoretypes.SerializedItemDescriptor{
+					Version:        item.Item.Version,
+					Deleted:        item.Item.Item == nil,
+					SerializedItem: kind.Serialize(item.Item),
+				},
+			})
+		}
+	}
+	return ret
+}
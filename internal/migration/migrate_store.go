@@ -0,0 +1,461 @@
+package migration
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v8/config"
+	"github.com/launchdarkly/ld-relay/v8/internal/bigsegments"
+	"github.com/launchdarkly/ld-relay/v8/internal/envfactory"
+	"github.com/launchdarkly/ld-relay/v8/internal/filedata"
+	"github.com/launchdarkly/ld-relay/v8/internal/relayenv"
+	"github.com/launchdarkly/ld-relay/v8/internal/sdks"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoreimpl"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoretypes"
+)
+
+const (
+	logMsgSkippedFilteredEnvironment = "Not copying environment %q, because payload filters are not supported in a data file"
+)
+
+var (
+	errNoEnvironments       = errors.New("the source configuration does not specify any environments")
+	errNoPersistentStore    = errors.New("no persistent data store is configured")
+	errSameStore            = errors.New("the source and destination data stores are the same")
+	errSourceNotInitialized = errors.New("the source data store does not contain any data for this environment")
+)
+
+func errEnvironmentNotInDestination(name string) error {
+	return fmt.Errorf("environment %q is not in the destination configuration", name)
+}
+
+func errNoEnvironmentID(name string) error {
+	return fmt.Errorf("environment %q must have an envId to be written to a data file", name)
+}
+
+func errDataFileIncomplete(expected, actual int) error {
+	return fmt.Errorf("the data file should contain %d environments, but it contains %d", expected, actual)
+}
+
+func errEnvironment(name string, err error) error {
+	return fmt.Errorf("environment %q: %w", name, err)
+}
+
+func errItemsDoNotMatch(kind ldstoretypes.DataKind, count int) error {
+	return fmt.Errorf("%d %s in the destination data store do not match the source", count, kind.GetName())
+}
+
+// EnvironmentResult describes the data that MigrateStore copied for one environment.
+type EnvironmentResult struct {
+	// Name is the environment name from the configuration.
+	Name string
+
+	// Flags is the number of feature flags that were copied, including deleted flag placeholders.
+	Flags int
+
+	// Segments is the number of segments that were copied, including deleted segment placeholders.
+	Segments int
+
+	// BigSegments describes the big segment data that was copied, if any.
+	BigSegments bigsegments.BigSegmentCopyResult
+}
+
+// environment is an environment to be copied, with the configuration that Relay would use for it.
+type environment struct {
+	config config.EnvConfig
+	params envfactory.EnvironmentParams
+
+	// sdkData is the environment's data, if it was read from a data file.
+	sdkData []ldstoretypes.Collection
+}
+
+type environmentPair struct {
+	from, to environment
+}
+
+// persistentDataStoreFactory creates the persistent data store for an environment.
+type persistentDataStoreFactory func(
+	allConfig config.Config,
+	envConfig config.EnvConfig,
+	loggers ldlog.Loggers,
+) (subsystems.PersistentDataStore, sdks.DataStoreEnvironmentInfo, error)
+
+// MigrateStore copies the flags, segments, and big segment data for each environment in the source
+// configuration from the data store described by that configuration to the data store described by
+// the destination configuration, and then verifies that the destination contains the same items and
+// versions as the source.
+//
+// The environments are the ones that Relay would have with each configuration: either the ones that
+// it lists, or the ones that LaunchDarkly provides for its auto-configuration key, or the ones in its
+// offline mode data file. If both configurations list their environments, each environment must be
+// present with the same name in both, since the environment properties can determine things like the
+// database key prefix; otherwise, environments are matched by SDK key.
+//
+// A configuration that sets an offline mode data file, but no database, refers to the data file
+// itself. As a source, the data file is read; as a destination, a new data file is written with all of
+// the source environments, which can then be used in offline mode.
+//
+// The source data store is not modified. Flags and segments in the destination data store are
+// replaced; big segment data is only copied if the destination does not already have any.
+func MigrateStore(from, to config.Config, loggers ldlog.Loggers) ([]EnvironmentResult, error) {
+	return migrateStore(from, to, makePersistentDataStore, bigsegments.DefaultBigSegmentStoreFactory,
+		getAutoConfigEnvironments, loggers)
+}
+
+func migrateStore(
+	from, to config.Config,
+	storeFactory persistentDataStoreFactory,
+	bigSegmentStoreFactory bigsegments.BigSegmentStoreFactory,
+	autoConfigEnvironments autoConfigEnvironmentsFunc,
+	loggers ldlog.Loggers,
+) ([]EnvironmentResult, error) {
+	fromEnvs, err := getEnvironments(from, autoConfigEnvironments, loggers)
+	if err != nil {
+		return nil, err
+	}
+	if len(fromEnvs) == 0 {
+		return nil, errNoEnvironments
+	}
+
+	var pairs []environmentPair
+	var fileWriter *filedata.ArchiveWriter
+	if isDataFile(to) {
+		if fileWriter, err = filedata.NewArchiveWriter(); err != nil {
+			return nil, err
+		}
+		defer fileWriter.Close()
+		for _, env := range fromEnvs {
+			if env.params.Identifiers.FilterKey != "" {
+				loggers.Warnf(logMsgSkippedFilteredEnvironment, env.params.Identifiers.GetDisplayName())
+				continue
+			}
+			if env.params.EnvID == "" {
+				return nil, errNoEnvironmentID(env.params.Identifiers.GetDisplayName())
+			}
+			pairs = append(pairs, environmentPair{from: env, to: env})
+		}
+	} else {
+		toEnvs, err := getEnvironments(to, autoConfigEnvironments, loggers)
+		if err != nil {
+			return nil, err
+		}
+		if pairs, err = matchEnvironments(fromEnvs, toEnvs, listsEnvironments(from) && listsEnvironments(to)); err != nil {
+			return nil, err
+		}
+	}
+
+	results := make([]EnvironmentResult, 0, len(pairs))
+	for _, pair := range pairs {
+		name := pair.from.params.Identifiers.GetDisplayName()
+		result, err := migrateEnvironment(name, pair.from, pair.to, from, to, fileWriter,
+			storeFactory, bigSegmentStoreFactory, loggers)
+		if err != nil {
+			return results, errEnvironment(name, err)
+		}
+		loggers.Infof("Copied environment %q: %d flags, %d segments, %d big segment memberships for %d users",
+			name, result.Flags, result.Segments, result.BigSegments.Memberships, result.BigSegments.Users)
+		results = append(results, result)
+	}
+
+	if fileWriter != nil {
+		if err := fileWriter.Write(to.OfflineMode.FileDataSource); err != nil {
+			return results, err
+		}
+		written, err := filedata.ReadArchive(to.OfflineMode.FileDataSource)
+		if err != nil {
+			return results, err
+		}
+		if len(written) != len(results) {
+			return results, errDataFileIncomplete(len(results), len(written))
+		}
+	}
+	return results, nil
+}
+
+// isDataFile returns true if a configuration refers to an offline mode data file rather than a database.
+// If it sets both, the database is used, and the data file only determines which environments there are.
+func isDataFile(c config.Config) bool {
+	return c.OfflineMode.FileDataSource != "" &&
+		!c.Redis.URL.IsDefined() && c.Consul.Host == "" && !c.DynamoDB.Enabled
+}
+
+// listsEnvironments returns true if the environments are listed in the configuration, rather than being
+// provided by auto-configuration or a data file.
+func listsEnvironments(c config.Config) bool {
+	return c.OfflineMode.FileDataSource == "" && c.AutoConfig.Key == ""
+}
+
+// getEnvironments returns the environments that Relay would have with a configuration, sorted by name,
+// with the same environment configurations that Relay would use.
+func getEnvironments(
+	c config.Config,
+	autoConfigEnvironments autoConfigEnvironmentsFunc,
+	loggers ldlog.Loggers,
+) ([]environment, error) {
+	var ret []environment
+	switch {
+	case c.OfflineMode.FileDataSource != "":
+		archiveEnvs, err := filedata.ReadArchive(c.OfflineMode.FileDataSource)
+		if err != nil {
+			return nil, err
+		}
+		factory := envfactory.NewEnvConfigFactoryForOfflineMode(c.OfflineMode)
+		for _, archiveEnv := range archiveEnvs {
+			ret = append(ret, environment{config: factory.MakeEnvironmentConfig(archiveEnv.Params),
+				params: archiveEnv.Params, sdkData: archiveEnv.SDKData})
+		}
+	case c.AutoConfig.Key != "":
+		paramsList, err := autoConfigEnvironments(c, loggers)
+		if err != nil {
+			return nil, err
+		}
+		factory := envfactory.NewEnvConfigFactoryForAutoConfig(c.AutoConfig)
+		for _, params := range paramsList {
+			ret = append(ret, environment{config: factory.MakeEnvironmentConfig(params), params: params})
+		}
+	default:
+		for name, envConfig := range c.Environment {
+			ret = append(ret, environment{
+				config: *envConfig,
+				params: envfactory.EnvironmentParams{
+					EnvID:       envConfig.EnvID,
+					Identifiers: relayenv.EnvIdentifiers{ConfiguredName: name, FilterKey: envConfig.FilterKey},
+					SDKKey:      envConfig.SDKKey,
+					MobileKey:   envConfig.MobileKey,
+					TTL:         envConfig.TTL.GetOrElse(0),
+					SecureMode:  envConfig.SecureMode,
+				},
+			})
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].params.Identifiers.GetDisplayName() < ret[j].params.Identifiers.GetDisplayName()
+	})
+	return ret, nil
+}
+
+// matchEnvironments finds the destination environment for each source environment, either by name, or
+// by SDK key and payload filter.
+func matchEnvironments(fromEnvs, toEnvs []environment, byName bool) ([]environmentPair, error) {
+	type envKey struct {
+		name      string
+		sdkKey    config.SDKKey
+		filterKey config.FilterKey
+	}
+	keyOf := func(env environment) envKey {
+		if byName {
+			return envKey{name: env.params.Identifiers.GetDisplayName()}
+		}
+		return envKey{sdkKey: env.params.SDKKey, filterKey: env.params.Identifiers.FilterKey}
+	}
+	toEnvsByKey := make(map[envKey]environment, len(toEnvs))
+	for _, env := range toEnvs {
+		toEnvsByKey[keyOf(env)] = env
+	}
+	pairs := make([]environmentPair, 0, len(fromEnvs))
+	for _, env := range fromEnvs {
+		toEnv, ok := toEnvsByKey[keyOf(env)]
+		if !ok {
+			return nil, errEnvironmentNotInDestination(env.params.Identifiers.GetDisplayName())
+		}
+		pairs = append(pairs, environmentPair{from: env, to: toEnv})
+	}
+	return pairs, nil
+}
+
+func migrateEnvironment(
+	name string,
+	fromEnv, toEnv environment,
+	from, to config.Config,
+	fileWriter *filedata.ArchiveWriter,
+	storeFactory persistentDataStoreFactory,
+	bigSegmentStoreFactory bigsegments.BigSegmentStoreFactory,
+	loggers ldlog.Loggers,
+) (EnvironmentResult, error) {
+	result := EnvironmentResult{Name: name}
+
+	fromStore, fromInfo, err := openStore(from, fromEnv, nil, storeFactory, loggers)
+	if err != nil {
+		return result, err
+	}
+	defer fromStore.Close() //nolint:errcheck
+	toStore, toInfo, err := openStore(to, toEnv, fileWriter, storeFactory, loggers)
+	if err != nil {
+		return result, err
+	}
+	defer toStore.Close() //nolint:errcheck
+	if fromInfo == toInfo {
+		return result, errSameStore
+	}
+
+	counts, err := copyItems(fromStore, toStore)
+	if err != nil {
+		return result, err
+	}
+	result.Flags = counts[ldstoreimpl.Features()]
+	result.Segments = counts[ldstoreimpl.Segments()]
+
+	fromBigSegments, err := bigSegmentStoreFactory(fromEnv.config, from, loggers)
+	if err != nil {
+		return result, err
+	}
+	if fromBigSegments == nil {
+		return result, nil
+	}
+	defer fromBigSegments.Close() //nolint:errcheck
+	toBigSegments, err := bigSegmentStoreFactory(toEnv.config, to, loggers)
+	if err != nil {
+		return result, err
+	}
+	if toBigSegments != nil {
+		defer toBigSegments.Close() //nolint:errcheck
+	}
+	result.BigSegments, err = bigsegments.CopyBigSegmentData(fromBigSegments, toBigSegments)
+	return result, err
+}
+
+// openStore returns the data store for an environment: either the database, or the data file. A data
+// file destination is written by fileWriter.
+func openStore(
+	allConfig config.Config,
+	env environment,
+	fileWriter *filedata.ArchiveWriter,
+	storeFactory persistentDataStoreFactory,
+	loggers ldlog.Loggers,
+) (subsystems.PersistentDataStore, sdks.DataStoreEnvironmentInfo, error) {
+	if !isDataFile(allConfig) {
+		return storeFactory(allConfig, env.config, loggers)
+	}
+	storeInfo := sdks.DataStoreEnvironmentInfo{DBType: "file", DBServer: allConfig.OfflineMode.FileDataSource,
+		DBPrefix: string(env.params.EnvID)}
+	if fileWriter == nil {
+		return &fileSourceStore{sdkData: env.sdkData}, storeInfo, nil
+	}
+	return &fileDestinationStore{writer: fileWriter, rep: makeEnvironmentRep(env.params)}, storeInfo, nil
+}
+
+// makeEnvironmentRep returns the properties of an environment as they are stored in a data file.
+func makeEnvironmentRep(params envfactory.EnvironmentParams) envfactory.EnvironmentRep {
+	envName := params.Identifiers.EnvName
+	if envName == "" {
+		envName = params.Identifiers.ConfiguredName
+	}
+	return envfactory.EnvironmentRep{
+		EnvID:      params.EnvID,
+		EnvKey:     params.Identifiers.EnvKey,
+		EnvName:    envName,
+		MobKey:     params.MobileKey,
+		ProjKey:    params.Identifiers.ProjKey,
+		ProjName:   params.Identifiers.ProjName,
+		SDKKey:     envfactory.SDKKeyRep{Value: params.SDKKey},
+		DefaultTTL: int(params.TTL / time.Minute),
+		SecureMode: params.SecureMode,
+		Version:    1,
+	}
+}
+
+// copyItems copies all flags and segments from one store to another, verifies that the destination now
+// has the same items and versions, and returns the number of items of each kind.
+func copyItems(from, to subsystems.PersistentDataStore) (map[ldstoretypes.DataKind]int, error) {
+	if !from.IsInitialized() {
+		return nil, errSourceNotInitialized
+	}
+
+	// Segments are written before flags, since flags can refer to segments; the SDK uses the same order
+	// when it initializes a data store.
+	kinds := []ldstoretypes.DataKind{ldstoreimpl.Segments(), ldstoreimpl.Features()}
+	allData := make([]ldstoretypes.SerializedCollection, 0, len(kinds))
+	versions := make(map[ldstoretypes.DataKind]map[string]int, len(kinds))
+	for _, kind := range kinds {
+		items, err := from.GetAll(kind)
+		if err != nil {
+			return nil, err
+		}
+		if versions[kind], err = getItemVersions(kind, items); err != nil {
+			return nil, err
+		}
+		allData = append(allData, ldstoretypes.SerializedCollection{Kind: kind, Items: items})
+	}
+
+	if err := to.Init(allData); err != nil {
+		return nil, err
+	}
+
+	counts := make(map[ldstoretypes.DataKind]int, len(kinds))
+	for _, kind := range kinds {
+		items, err := to.GetAll(kind)
+		if err != nil {
+			return nil, err
+		}
+		copiedVersions, err := getItemVersions(kind, items)
+		if err != nil {
+			return nil, err
+		}
+		mismatches := 0
+		for key, version := range versions[kind] {
+			if copiedVersion, ok := copiedVersions[key]; !ok || copiedVersion != version {
+				mismatches++
+			}
+		}
+		for key := range copiedVersions {
+			if _, ok := versions[kind][key]; !ok {
+				mismatches++
+			}
+		}
+		if mismatches != 0 {
+			return nil, errItemsDoNotMatch(kind, mismatches)
+		}
+		counts[kind] = len(items)
+	}
+	return counts, nil
+}
+
+// getItemVersions returns the version of each item, as a map of keys to versions. Some data store
+// implementations do not store the version separately from the serialized item, so we parse the item
+// to find the version, and we also fill in the Version property of the item descriptor so that it is
+// available to data stores that do need it.
+func getItemVersions(kind ldstoretypes.DataKind, items []ldstoretypes.KeyedSerializedItemDescriptor) (map[string]int, error) {
+	versions := make(map[string]int, len(items))
+	for i, item := range items {
+		if item.Item.SerializedItem != nil {
+			parsed, err := kind.Deserialize(item.Item.SerializedItem)
+			if err != nil {
+				return nil, fmt.Errorf("unable to parse %s item %q: %w", kind.GetName(), item.Key, err)
+			}
+			items[i].Item.Version = parsed.Version
+			items[i].Item.Deleted = parsed.Item == nil
+		}
+		versions[item.Key] = items[i].Item.Version
+	}
+	return versions, nil
+}
+
+// makePersistentDataStore creates the same persistent data store that Relay would use for an environment,
+// but without the SDK's caching layer.
+func makePersistentDataStore(
+	allConfig config.Config,
+	envConfig config.EnvConfig,
+	loggers ldlog.Loggers,
+) (subsystems.PersistentDataStore, sdks.DataStoreEnvironmentInfo, error) {
+	var factory subsystems.ComponentConfigurer[subsystems.PersistentDataStore]
+	_, storeInfo, err := sdks.ConfigureDataStore(allConfig, envConfig,
+		func(f subsystems.ComponentConfigurer[subsystems.PersistentDataStore]) subsystems.ComponentConfigurer[subsystems.PersistentDataStore] {
+			factory = f
+			return f
+		}, loggers)
+	if err != nil {
+		return nil, storeInfo, err
+	}
+	if factory == nil {
+		return nil, storeInfo, errNoPersistentStore
+	}
+	store, err := factory.Build(subsystems.BasicClientContext{
+		Logging: subsystems.LoggingConfiguration{Loggers: loggers},
+	})
+	return store, storeInfo, err
+}
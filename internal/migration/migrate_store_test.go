@@ -0,0 +1,364 @@
+package migration
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/launchdarkly/ld-relay/v8/config"
+	"github.com/launchdarkly/ld-relay/v8/internal/bigsegments"
+	"github.com/launchdarkly/ld-relay/v8/internal/envfactory"
+	"github.com/launchdarkly/ld-relay/v8/internal/filedata"
+	"github.com/launchdarkly/ld-relay/v8/internal/relayenv"
+	"github.com/launchdarkly/ld-relay/v8/internal/sdks"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	"github.com/launchdarkly/go-server-sdk-evaluation/v3/ldbuilders"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoreimpl"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoretypes"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryPersistentStore is a simple PersistentDataStore. Like the Redis and Consul stores, it does not
+// return item versions separately from the serialized items.
+type memoryPersistentStore struct {
+	data        map[ldstoretypes.DataKind]map[string][]byte
+	inited      bool
+	dropOnWrite string
+	lock        sync.Mutex
+}
+
+func newMemoryPersistentStore() *memoryPersistentStore {
+	return &memoryPersistentStore{data: make(map[ldstoretypes.DataKind]map[string][]byte)}
+}
+
+func (s *memoryPersistentStore) Init(allData []ldstoretypes.SerializedCollection) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.data = make(map[ldstoretypes.DataKind]map[string][]byte)
+	for _, coll := range allData {
+		s.data[coll.Kind] = make(map[string][]byte)
+		for _, item := range coll.Items {
+			if item.Key != s.dropOnWrite {
+				s.data[coll.Kind][item.Key] = item.Item.SerializedItem
+			}
+		}
+	}
+	s.inited = true
+	return nil
+}
+
+func (s *memoryPersistentStore) Upsert(
+	kind ldstoretypes.DataKind,
+	key string,
+	item ldstoretypes.SerializedItemDescriptor,
+) (bool, error) {
+	return false, errors.New("not implemented")
+}
+
+func (s *memoryPersistentStore) Get(kind ldstoretypes.DataKind, key string) (ldstoretypes.SerializedItemDescriptor, error) {
+	return ldstoretypes.SerializedItemDescriptor{}, errors.New("not implemented")
+}
+
+func (s *memoryPersistentStore) GetAll(kind ldstoretypes.DataKind) ([]ldstoretypes.KeyedSerializedItemDescriptor, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var ret []ldstoretypes.KeyedSerializedItemDescriptor
+	for key, data := range s.data[kind] {
+		ret = append(ret, ldstoretypes.KeyedSerializedItemDescriptor{
+			Key:  key,
+			Item: ldstoretypes.SerializedItemDescriptor{SerializedItem: data},
+		})
+	}
+	return ret, nil
+}
+
+func (s *memoryPersistentStore) IsInitialized() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.inited
+}
+
+func (s *memoryPersistentStore) IsStoreAvailable() bool { return true }
+
+func (s *memoryPersistentStore) Close() error { return nil }
+
+func (s *memoryPersistentStore) getVersions(kind ldstoretypes.DataKind) map[string]int {
+	items, _ := s.GetAll(kind)
+	versions, _ := getItemVersions(kind, items)
+	return versions
+}
+
+// testStores provides a memoryPersistentStore for each database server named in the configuration; we use
+// the Consul host property for this, because it doesn't require any other properties to be set.
+type testStores map[string]*memoryPersistentStore
+
+func (ts testStores) factory(
+	allConfig config.Config,
+	envConfig config.EnvConfig,
+	loggers ldlog.Loggers,
+) (subsystems.PersistentDataStore, sdks.DataStoreEnvironmentInfo, error) {
+	store := ts[allConfig.Consul.Host]
+	if store == nil {
+		return nil, sdks.DataStoreEnvironmentInfo{}, errNoPersistentStore
+	}
+	return store, sdks.DataStoreEnvironmentInfo{DBType: "consul", DBServer: allConfig.Consul.Host,
+		DBPrefix: envConfig.Prefix}, nil
+}
+
+func noBigSegmentStore(config.EnvConfig, config.Config, ldlog.Loggers) (bigsegments.BigSegmentStore, error) {
+	return nil, nil
+}
+
+func makeTestConfig(host string, envNames ...string) config.Config {
+	var c config.Config
+	c.Consul.Host = host
+	c.Environment = make(map[string]*config.EnvConfig)
+	for _, name := range envNames {
+		c.Environment[name] = &config.EnvConfig{Prefix: name}
+	}
+	return c
+}
+
+// makeTestConfigWithKeys is like makeTestConfig, but gives each environment an SDK key and environment ID
+// that are based on its name, so that it can be matched with an environment that has a different name.
+func makeTestConfigWithKeys(host string, envNames ...string) config.Config {
+	c := makeTestConfig(host, envNames...)
+	for name, envConfig := range c.Environment {
+		envConfig.SDKKey = config.SDKKey("sdk-key-" + name)
+		envConfig.EnvID = config.EnvironmentID("id-" + name)
+	}
+	return c
+}
+
+func makeDataFileConfig(filePath string) config.Config {
+	var c config.Config
+	c.OfflineMode.FileDataSource = filePath
+	return c
+}
+
+func writeDataFile(t *testing.T, filePath string, envIDs ...string) {
+	writer, err := filedata.NewArchiveWriter()
+	require.NoError(t, err)
+	defer writer.Close()
+	source := makeSourceStore(t)
+	var allData []ldstoretypes.SerializedCollection
+	for _, kind := range ldstoreimpl.AllKinds() {
+		items, err := source.GetAll(kind)
+		require.NoError(t, err)
+		allData = append(allData, ldstoretypes.SerializedCollection{Kind: kind, Items: items})
+	}
+	for _, id := range envIDs {
+		require.NoError(t, writer.AddEnvironment(envfactory.EnvironmentRep{
+			EnvID:    config.EnvironmentID(id),
+			ProjName: "project",
+			EnvName:  id,
+			SDKKey:   envfactory.SDKKeyRep{Value: config.SDKKey("sdk-key-" + id)},
+		}, allData))
+	}
+	require.NoError(t, writer.Write(filePath))
+}
+
+func makeSourceStore(t *testing.T) *memoryPersistentStore {
+	flag1 := ldbuilders.NewFlagBuilder("flag1").Version(3).Build()
+	segment1 := ldbuilders.NewSegmentBuilder("segment1").Version(2).Build()
+	store := newMemoryPersistentStore()
+	require.NoError(t, store.Init([]ldstoretypes.SerializedCollection{
+		{Kind: ldstoreimpl.Segments(), Items: []ldstoretypes.KeyedSerializedItemDescriptor{
+			{Key: "segment1", Item: ldstoretypes.SerializedItemDescriptor{
+				SerializedItem: ldstoreimpl.Segments().Serialize(ldstoretypes.ItemDescriptor{Version: 2, Item: &segment1}),
+			}},
+		}},
+		{Kind: ldstoreimpl.Features(), Items: []ldstoretypes.KeyedSerializedItemDescriptor{
+			{Key: "flag1", Item: ldstoretypes.SerializedItemDescriptor{
+				SerializedItem: ldstoreimpl.Features().Serialize(ldstoretypes.ItemDescriptor{Version: 3, Item: &flag1}),
+			}},
+			{Key: "flag2", Item: ldstoretypes.SerializedItemDescriptor{
+				SerializedItem: ldstoreimpl.Features().Serialize(ldstoretypes.ItemDescriptor{Version: 4, Item: nil}),
+			}},
+		}},
+	}))
+	return store
+}
+
+func TestMigrateStoreCopiesAllItems(t *testing.T) {
+	stores := testStores{"source": makeSourceStore(t), "dest": newMemoryPersistentStore()}
+
+	results, err := migrateStore(makeTestConfig("source", "env1"), makeTestConfig("dest", "env1"),
+		stores.factory, noBigSegmentStore, nil, ldlog.NewDisabledLoggers())
+	require.NoError(t, err)
+	assert.Equal(t, []EnvironmentResult{{Name: "env1", Flags: 2, Segments: 1}}, results)
+
+	assert.True(t, stores["dest"].IsInitialized())
+	assert.Equal(t, map[string]int{"flag1": 3, "flag2": 4}, stores["dest"].getVersions(ldstoreimpl.Features()))
+	assert.Equal(t, map[string]int{"segment1": 2}, stores["dest"].getVersions(ldstoreimpl.Segments()))
+}
+
+func TestMigrateStoreCopiesBigSegments(t *testing.T) {
+	stores := testStores{"source": makeSourceStore(t), "dest": newMemoryPersistentStore()}
+	var requested []string
+	bigSegmentStoreFactory := func(envConfig config.EnvConfig, allConfig config.Config, _ ldlog.Loggers) (
+		bigsegments.BigSegmentStore, error) {
+		requested = append(requested, allConfig.Consul.Host+":"+envConfig.Prefix)
+		return bigsegments.NewNullBigSegmentStore(), nil
+	}
+
+	results, err := migrateStore(makeTestConfig("source", "env1"), makeTestConfig("dest", "env1"),
+		stores.factory, bigSegmentStoreFactory, nil, ldlog.NewDisabledLoggers())
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, bigsegments.BigSegmentCopyResult{}, results[0].BigSegments)
+	assert.Equal(t, []string{"source:env1", "dest:env1"}, requested)
+}
+
+func TestMigrateStoreFromDataFile(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "data.tar.gz")
+	writeDataFile(t, filePath, "env1")
+	stores := testStores{"dest": newMemoryPersistentStore()}
+
+	// The environment is matched by SDK key, since the data file doesn't use the configured names
+	results, err := migrateStore(makeDataFileConfig(filePath), makeTestConfigWithKeys("dest", "env1"),
+		stores.factory, noBigSegmentStore, nil, ldlog.NewDisabledLoggers())
+	require.NoError(t, err)
+	assert.Equal(t, []EnvironmentResult{{Name: "project env1", Flags: 2, Segments: 1}}, results)
+	assert.Equal(t, map[string]int{"flag1": 3, "flag2": 4}, stores["dest"].getVersions(ldstoreimpl.Features()))
+	assert.Equal(t, map[string]int{"segment1": 2}, stores["dest"].getVersions(ldstoreimpl.Segments()))
+}
+
+func TestMigrateStoreToDataFile(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "data.tar.gz")
+	source := makeTestConfigWithKeys("source", "env1")
+	source.Environment["env1"].SecureMode = true
+	stores := testStores{"source": makeSourceStore(t)}
+
+	results, err := migrateStore(source, makeDataFileConfig(filePath),
+		stores.factory, noBigSegmentStore, nil, ldlog.NewDisabledLoggers())
+	require.NoError(t, err)
+	assert.Equal(t, []EnvironmentResult{{Name: "env1", Flags: 2, Segments: 1}}, results)
+
+	envs, err := filedata.ReadArchive(filePath)
+	require.NoError(t, err)
+	require.Len(t, envs, 1)
+	assert.Equal(t, envfactory.EnvironmentParams{
+		EnvID:       "id-env1",
+		Identifiers: relayenv.EnvIdentifiers{EnvName: "env1"},
+		SDKKey:      "sdk-key-env1",
+		SecureMode:  true,
+	}, envs[0].Params)
+
+	// The file can be used as a source in the same way
+	stores["dest"] = newMemoryPersistentStore()
+	_, err = migrateStore(makeDataFileConfig(filePath), makeTestConfigWithKeys("dest", "env1"),
+		stores.factory, noBigSegmentStore, nil, ldlog.NewDisabledLoggers())
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"flag1": 3, "flag2": 4}, stores["dest"].getVersions(ldstoreimpl.Features()))
+	assert.Equal(t, map[string]int{"segment1": 2}, stores["dest"].getVersions(ldstoreimpl.Segments()))
+}
+
+func TestMigrateStoreUsesDatabaseWhenDataFileConfigAlsoHasOne(t *testing.T) {
+	// In offline mode with a database, Relay puts the data file's environments in the database, using the
+	// offline mode prefix setting; so that is the data store for this configuration.
+	filePath := filepath.Join(t.TempDir(), "data.tar.gz")
+	writeDataFile(t, filePath, "env1")
+	source := makeDataFileConfig(filePath)
+	source.Consul.Host = "source"
+	source.OfflineMode.EnvDatastorePrefix = "relay-" + config.AutoConfigEnvironmentIDPlaceholder
+	stores := testStores{"source": newMemoryPersistentStore(), "dest": newMemoryPersistentStore()}
+
+	_, err := migrateStore(source, makeTestConfigWithKeys("dest", "env1"),
+		stores.factory, noBigSegmentStore, nil, ldlog.NewDisabledLoggers())
+	assert.Equal(t, errEnvironment("project env1", errSourceNotInitialized), err)
+}
+
+func TestMigrateStoreWithAutoConfig(t *testing.T) {
+	autoConfigEnvironments := func(c config.Config, _ ldlog.Loggers) ([]envfactory.EnvironmentParams, error) {
+		assert.Equal(t, config.AutoConfigKey("auto-config-key"), c.AutoConfig.Key)
+		return []envfactory.EnvironmentParams{{
+			EnvID:       "id-env1",
+			Identifiers: relayenv.EnvIdentifiers{ProjName: "project", EnvName: "env1"},
+			SDKKey:      "sdk-key-env1",
+		}}, nil
+	}
+	var prefixes []string
+	stores := testStores{"source": makeSourceStore(t), "dest": newMemoryPersistentStore()}
+	storeFactory := func(allConfig config.Config, envConfig config.EnvConfig, loggers ldlog.Loggers) (
+		subsystems.PersistentDataStore, sdks.DataStoreEnvironmentInfo, error) {
+		prefixes = append(prefixes, allConfig.Consul.Host+":"+envConfig.Prefix)
+		return stores.factory(allConfig, envConfig, loggers)
+	}
+
+	source := makeTestConfig("source")
+	source.AutoConfig.Key = "auto-config-key"
+	source.AutoConfig.EnvDatastorePrefix = "relay-" + config.AutoConfigEnvironmentIDPlaceholder
+	results, err := migrateStore(source, makeTestConfigWithKeys("dest", "env1"),
+		storeFactory, noBigSegmentStore, autoConfigEnvironments, ldlog.NewDisabledLoggers())
+	require.NoError(t, err)
+	assert.Equal(t, []EnvironmentResult{{Name: "project env1", Flags: 2, Segments: 1}}, results)
+	assert.Equal(t, []string{"source:relay-id-env1", "dest:env1"}, prefixes)
+}
+
+func TestMigrateStoreErrors(t *testing.T) {
+	t.Run("environment not in destination by SDK key", func(t *testing.T) {
+		filePath := filepath.Join(t.TempDir(), "data.tar.gz")
+		writeDataFile(t, filePath, "env1", "env2")
+		_, err := migrateStore(makeDataFileConfig(filePath), makeTestConfigWithKeys("dest", "env1"),
+			testStores{}.factory, noBigSegmentStore, nil, ldlog.NewDisabledLoggers())
+		assert.Equal(t, errEnvironmentNotInDestination("project env2"), err)
+	})
+
+	t.Run("no environment ID for data file", func(t *testing.T) {
+		stores := testStores{"source": makeSourceStore(t)}
+		_, err := migrateStore(makeTestConfig("source", "env1"), makeDataFileConfig(filepath.Join(t.TempDir(), "data.tar.gz")),
+			stores.factory, noBigSegmentStore, nil, ldlog.NewDisabledLoggers())
+		assert.Equal(t, errNoEnvironmentID("env1"), err)
+	})
+
+	t.Run("auto-configuration failed", func(t *testing.T) {
+		autoConfigErr := errors.New("invalid auto-configuration key")
+		source := makeTestConfig("source")
+		source.AutoConfig.Key = "auto-config-key"
+		_, err := migrateStore(source, makeTestConfig("dest"), testStores{}.factory, noBigSegmentStore,
+			func(config.Config, ldlog.Loggers) ([]envfactory.EnvironmentParams, error) { return nil, autoConfigErr },
+			ldlog.NewDisabledLoggers())
+		assert.Equal(t, autoConfigErr, err)
+	})
+
+	t.Run("no environments", func(t *testing.T) {
+		_, err := migrateStore(makeTestConfig("source"), makeTestConfig("dest"),
+			testStores{}.factory, noBigSegmentStore, nil, ldlog.NewDisabledLoggers())
+		assert.Equal(t, errNoEnvironments, err)
+	})
+
+	t.Run("environment not in destination", func(t *testing.T) {
+		_, err := migrateStore(makeTestConfig("source", "env1", "env2"), makeTestConfig("dest", "env1"),
+			testStores{}.factory, noBigSegmentStore, nil, ldlog.NewDisabledLoggers())
+		assert.Equal(t, errEnvironmentNotInDestination("env2"), err)
+	})
+
+	t.Run("same store", func(t *testing.T) {
+		stores := testStores{"source": makeSourceStore(t)}
+		_, err := migrateStore(makeTestConfig("source", "env1"), makeTestConfig("source", "env1"),
+			stores.factory, noBigSegmentStore, nil, ldlog.NewDisabledLoggers())
+		assert.Equal(t, errEnvironment("env1", errSameStore), err)
+	})
+
+	t.Run("source not initialized", func(t *testing.T) {
+		stores := testStores{"source": newMemoryPersistentStore(), "dest": newMemoryPersistentStore()}
+		_, err := migrateStore(makeTestConfig("source", "env1"), makeTestConfig("dest", "env1"),
+			stores.factory, noBigSegmentStore, nil, ldlog.NewDisabledLoggers())
+		assert.Equal(t, errEnvironment("env1", errSourceNotInitialized), err)
+		assert.False(t, stores["dest"].IsInitialized())
+	})
+
+	t.Run("destination does not match after copying", func(t *testing.T) {
+		stores := testStores{"source": makeSourceStore(t), "dest": newMemoryPersistentStore()}
+		stores["dest"].dropOnWrite = "flag2"
+		_, err := migrateStore(makeTestConfig("source", "env1"), makeTestConfig("dest", "env1"),
+			stores.factory, noBigSegmentStore, nil, ldlog.NewDisabledLoggers())
+		assert.Equal(t, errEnvironment("env1", errItemsDoNotMatch(ldstoreimpl.Features(), 1)), err)
+	})
+}
@@ -0,0 +1,3 @@
+// Package migration implements Relay's migrate-store command, which copies the data for each environment
+// from one persistent data store to another.
+package migration
@@ -2,6 +2,7 @@ package relayenv
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -14,12 +15,16 @@ import (
 	"github.com/launchdarkly/ld-relay/v8/internal/bigsegments"
 	"github.com/launchdarkly/ld-relay/v8/internal/events"
 	"github.com/launchdarkly/ld-relay/v8/internal/sdks"
+	"github.com/launchdarkly/ld-relay/v8/internal/store"
 	"github.com/launchdarkly/ld-relay/v8/internal/streams"
 
 	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
 	ldeval "github.com/launchdarkly/go-server-sdk-evaluation/v3"
 )
 
+// ErrClientRestartInProgress is returned by EnvContext.RestartClient if the previous restart has not finished.
+var ErrClientRestartInProgress = errors.New("the SDK client is already being restarted")
+
 // CredentialUpdate specifies the primary credential of a given credential kind for an environment.
 // For example, an environment may have a primary SDK key and a primary mobile key at the same time; each would
 // be specified in individual CredentialUpdate objects.
@@ -95,6 +100,16 @@ type EnvContext interface {
 	// yet complete.
 	GetStore() subsystems.DataStore
 
+	// GetDataHash returns an opaque string that identifies the environment's current flag and segment data,
+	// and is the same on any Relay instance that has the same data; or an empty string if this is not known
+	// (for instance, in daemon mode, where another process is updating the data store).
+	GetDataHash() string
+
+	// GetDataChangesSince returns the flags and segments that have changed since the environment's data had
+	// the specified version, along with the current version. It returns false if it can't provide this
+	// information, in which case the caller should provide all of the data instead.
+	GetDataChangesSince(version string) ([]store.DataChange, string, bool)
+
 	// GetEvaluator returns an instance of the evaluation engine for evaluating feature flags in this environment.
 	// This is nil if initialization is not yet complete.
 	GetEvaluator() ldeval.Evaluator
@@ -111,6 +126,14 @@ type EnvContext interface {
 	// environment. If there is none, it returns a handler for a 404 status (not nil).
 	GetStreamHandler(streams.StreamProvider, credential.SDKCredential) http.Handler
 
+	// GetStreamConnectionLimiter returns the object that enforces limits on concurrent stream connections
+	// for this environment.
+	GetStreamConnectionLimiter() *streams.ConnectionLimiter
+
+	// GetStreamSubscriberCounts returns the number of currently connected stream subscribers for each kind
+	// of stream, across all of this environment's credentials.
+	GetStreamSubscriberCounts() map[streams.StreamProvider]int
+
 	// GetEventDispatcher returns the object that proxies events for this environment.
 	GetEventDispatcher() *events.EventDispatcher
 
@@ -130,6 +153,11 @@ type EnvContext interface {
 	// GetInitError returns an error if initialization has failed, or nil otherwise.
 	GetInitError() error
 
+	// IsServingStaleData returns true if StaleWhileUnavailable is enabled, and the SDK client has not yet
+	// initialized, but the data store has data from earlier- for instance, in a persistent store that was
+	// populated by a previous Relay instance. In that case Relay serves that data, which may be out of date.
+	IsServingStaleData() bool
+
 	// IsSecureMode returns true if client-side evaluation requests for this environment must have a valid
 	// secure mode hash.
 	IsSecureMode() bool
@@ -137,12 +165,49 @@ type EnvContext interface {
 	// SetSecureMode changes the secure mode setting.
 	SetSecureMode(bool)
 
+	// GetAllowedClientCertNames returns the names that a verified TLS client certificate must match, in
+	// its subject common name or subject alternative names, for requests to this environment. If it is
+	// empty, any client is allowed.
+	GetAllowedClientCertNames() []string
+
 	// GetCreationTime returns the time that this EnvContext was created.
 	GetCreationTime() time.Time
 
+	// GetSDKKeyExpiry returns the time when the earliest deprecated SDK key for this environment expires,
+	// or false if there is no deprecated SDK key.
+	GetSDKKeyExpiry() (time.Time, bool)
+
+	// GetSDKInitDuration returns how long the SDK client took to initialize, or false if it has not
+	// successfully initialized.
+	GetSDKInitDuration() (time.Duration, bool)
+
 	// GetDataStoreInfo returns information about the environment's data store.
 	GetDataStoreInfo() sdks.DataStoreEnvironmentInfo
 
+	// CheckDataStoreConsistency compares the contents of the environment's persistent data store with the
+	// latest data that Relay has received, and optionally rewrites the data store if they differ. Repairs
+	// are skipped if another Relay instance is the data store's writer. It returns an error if consistency
+	// checks are not enabled for this environment, or if the check could not be done.
+	CheckDataStoreConsistency(repair bool) (store.ConsistencyCheckResult, error)
+
+	// GetDataStoreConsistency returns the result of the last data store consistency check, or false if
+	// there has not been one.
+	GetDataStoreConsistency() (store.ConsistencyCheckResult, bool)
+
+	// GetStatusHistory returns the most recent transitions of the SDK client's data source and data store
+	// states, oldest first. The first transitions are the states that the client started with.
+	GetStatusHistory() []StatusTransition
+
+	// RestartClient replaces the environment's SDK client with a new one, so that all data is requested
+	// from LaunchDarkly again. The old client and its data continue to be used until the new one has
+	// initialized, and are kept if it fails to initialize. It returns an error in offline mode, where there
+	// is no connection to LaunchDarkly, or ErrClientRestartInProgress if a restart has not finished yet.
+	RestartClient() error
+
+	// ResyncBigSegments causes the big segment synchronizer to request all big segment data again. It
+	// returns an error if big segments are not configured for this environment.
+	ResyncBigSegments() error
+
 	// FlushMetricsEvents is used in testing to ensure that metrics events are delivered promptly.
 	FlushMetricsEvents()
 }
@@ -2,6 +2,7 @@ package relayenv
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"sync"
@@ -13,6 +14,8 @@ import (
 
 	"github.com/launchdarkly/ld-relay/v8/config"
 	"github.com/launchdarkly/ld-relay/v8/internal/bigsegments"
+	"github.com/launchdarkly/ld-relay/v8/internal/changefeed"
+	"github.com/launchdarkly/ld-relay/v8/internal/cluster"
 	"github.com/launchdarkly/ld-relay/v8/internal/events"
 	"github.com/launchdarkly/ld-relay/v8/internal/httpconfig"
 	"github.com/launchdarkly/ld-relay/v8/internal/metrics"
@@ -50,6 +53,27 @@ const (
 	// expiry was received) will be cleaned up on an interval with this granularity. This means the environment won't accept
 	// connections for this credential, and it will shut down the SDK client associated with that credential.
 	defaultCredentialCleanupInterval = 1 * time.Minute
+
+	// When persistent store leader election is enabled, the Relay instance that writes to an environment's
+	// store holds a lease with this name, renewing it at this interval. If it stops renewing the lease,
+	// another instance can take over after the TTL. Consul does not allow session TTLs of less than 10s.
+	storeWriterLeaseName          = "store-writer"
+	storeWriterLeaseRenewInterval = 5 * time.Second
+	storeWriterLeaseTTL           = 15 * time.Second
+
+	// When StaleWhileUnavailable is enabled, this is how often we check whether either the SDK client has
+	// initialized or the data store already has data, while waiting for one of those things to happen.
+	staleDataPollInterval = 100 * time.Millisecond
+)
+
+const (
+	logMsgStoreConsistencyCheckFailed = "Unable to check data store consistency: %s"
+)
+
+var (
+	errDataStoreConsistencyCheckNotEnabled = errors.New("data store consistency checks are not enabled for this environment")
+	errCannotRestartClientInOfflineMode    = errors.New("the SDK client cannot be restarted in offline mode")
+	errBigSegmentsNotConfigured            = errors.New("big segments are not configured for this environment")
 )
 
 func errInitPublisher(err error) error {
@@ -75,8 +99,16 @@ type EnvContextImplParams struct {
 	DataStoreFactory                 subsystems.ComponentConfigurer[subsystems.DataStore]
 	DataStoreInfo                    sdks.DataStoreEnvironmentInfo
 	StreamProviders                  []streams.StreamProvider
+	StreamConnectionLimiter          *streams.ConnectionLimiter
 	JSClientContext                  JSClientContext
 	MetricsManager                   *metrics.Manager
+	ClusterBackend                   cluster.Backend // nil if cluster mode is not enabled
+	ClusterNodeID                    string
+	StoreWriteGate                   *store.PersistentStoreWriteGate // nil if store leader election is not enabled
+	StoreLeaseClient                 cluster.DataStoreLeaseClient    // nil if store leader election is not enabled
+	StoreChecker                     *store.PersistentStoreChecker   // nil if data store consistency checks are not enabled
+	ChangeFeed                       *changefeed.Feed                // nil in tests that don't need it
+	StatusChangeHandler              StatusChangeHandler             // nil if status changes are not being reported
 	BigSegmentStoreFactory           bigsegments.BigSegmentStoreFactory
 	BigSegmentSynchronizerFactory    bigsegments.BigSegmentSynchronizerFactory
 	SDKBigSegmentsConfigFactory      subsystems.ComponentConfigurer[subsystems.BigSegmentsConfiguration] // set only in tests
@@ -97,16 +129,25 @@ type envContextImpl struct {
 	envStreams                *streams.EnvStreams
 	streamProviders           []streams.StreamProvider
 	handlers                  map[streams.StreamProvider]map[credential.SDKCredential]http.Handler
+	streamConnLimiter         *streams.ConnectionLimiter
 	jsContext                 JSClientContext
 	evaluator                 ldeval.Evaluator
 	eventDispatcher           *events.EventDispatcher
 	bigSegmentSync            bigsegments.BigSegmentSynchronizer
+	storeElector              *cluster.Elector
+	storeLeaseStore           cluster.LeaseStore
+	storeWriteGate            *store.PersistentStoreWriteGate
+	storeChecker              *store.PersistentStoreChecker
+	changeTracker             *changefeed.Tracker
+	stopCheckingStore         chan struct{}
+	doneCheckingStore         chan struct{}
 	bigSegmentStore           bigsegments.BigSegmentStore
 	bigSegmentsExist          bool
 	sdkBigSegments            *ldstoreimpl.BigSegmentStoreWrapper
 	sdkConfig                 ld.Config
 	sdkClientFactory          sdks.ClientFactoryFunc
 	sdkInitTimeout            time.Duration
+	staleWhileUnavailable     bool
 	metricsManager            *metrics.Manager
 	metricsEnv                *metrics.EnvironmentManager
 	metricsEventPub           events.EventPublisher
@@ -115,12 +156,21 @@ type envContextImpl struct {
 	ttl                       time.Duration
 	initErr                   error
 	creationTime              time.Time
+	sdkInitDuration           time.Duration
+	sdkInitialized            bool
 	filterKey                 config.FilterKey
 	keyRotator                *credential.Rotator
 	stopMonitoringCredentials chan struct{}
 	doneMonitoringCredentials chan struct{}
+	statusHistory             *statusHistory
+	statusChangeHandler       StatusChangeHandler
+	disconnectedStatusTime    time.Duration
+	lastEnvStatus             *EnvStatus
+	envStatusLock             sync.Mutex
 	connectionMapper          ConnectionMapper
 	offline                   bool
+	allowedClientCertNames    []string
+	restarting                bool
 	closed                    bool
 }
 
@@ -171,6 +221,9 @@ func NewEnvContext(
 	if err != nil {
 		return nil, err
 	}
+	if allConfig.MetricsConfig.OTLP.Enabled {
+		httpConfig = httpConfig.WithTracing()
+	}
 
 	envContext := &envContextImpl{
 		identifiers:               params.Identifiers,
@@ -182,6 +235,7 @@ func NewEnvContext(
 		jsContext:                 params.JSClientContext,
 		sdkClientFactory:          params.ClientFactory,
 		sdkInitTimeout:            allConfig.Main.InitTimeout.GetOrElse(config.DefaultInitTimeout),
+		staleWhileUnavailable:     allConfig.Main.StaleWhileUnavailable,
 		metricsManager:            params.MetricsManager,
 		globalLoggers:             params.Loggers,
 		ttl:                       envConfig.TTL.GetOrElse(0),
@@ -191,8 +245,27 @@ func NewEnvContext(
 		keyRotator:                credential.NewRotator(params.Loggers),
 		stopMonitoringCredentials: make(chan struct{}),
 		doneMonitoringCredentials: make(chan struct{}),
+		statusHistory:             newStatusHistory(),
+		statusChangeHandler:       params.StatusChangeHandler,
+		disconnectedStatusTime:    allConfig.Main.DisconnectedStatusTime.GetOrElse(config.DefaultDisconnectedStatusTime),
 		connectionMapper:          params.ConnectionMapper,
+		storeWriteGate:            params.StoreWriteGate,
+		storeChecker:              params.StoreChecker,
 		offline:                   envConfig.Offline,
+		allowedClientCertNames:    envConfig.AllowedClientCertNames.Values(),
+	}
+
+	if params.ChangeFeed != nil {
+		envContext.changeTracker = changefeed.NewTracker(params.ChangeFeed, func() string {
+			return envContext.GetIdentifiers().GetDisplayName()
+		})
+	}
+
+	maxEnvStreamConns := envConfig.MaxStreamConnections.GetOrElse(allConfig.Main.MaxEnvStreamConnections.GetOrElse(0))
+	if params.StreamConnectionLimiter != nil {
+		envContext.streamConnLimiter = params.StreamConnectionLimiter.NewChild(maxEnvStreamConns)
+	} else {
+		envContext.streamConnLimiter = streams.NewConnectionLimiter(maxEnvStreamConns, allConfig.Main.ShedOldestStreamConnections)
 	}
 
 	envContext.keyRotator.Initialize([]credential.SDKCredential{
@@ -205,6 +278,7 @@ func NewEnvContext(
 	if bigSegmentStoreFactory == nil {
 		bigSegmentStoreFactory = bigsegments.DefaultBigSegmentStoreFactory
 	}
+	var leaderOnlySync *bigsegments.LeaderOnlySynchronizer
 	bigSegmentStore, err := bigSegmentStoreFactory(envConfig, allConfig, envLoggers)
 	if err != nil {
 		return nil, err
@@ -217,9 +291,19 @@ func NewEnvContext(
 		if factory == nil {
 			factory = bigsegments.DefaultBigSegmentSynchronizerFactory
 		}
-		envContext.bigSegmentSync = factory(
-			httpConfig, bigSegmentStore, allConfig.Main.BaseURI.String(), allConfig.Main.StreamURI.String(),
-			envConfig.EnvID, envConfig.SDKKey, envLoggers, logPrefix)
+		makeSynchronizer := func() bigsegments.BigSegmentSynchronizer {
+			return factory(
+				httpConfig, bigSegmentStore, allConfig.Main.BaseURI.String(), allConfig.Main.StreamURI.String(),
+				envConfig.EnvID, envConfig.SDKKey, envLoggers, logPrefix)
+		}
+		if params.StoreWriteGate != nil {
+			// Only the Relay instance that writes to the persistent store should update big segments; the
+			// others watch the store for updates, so that they still clear their caches and notify clients
+			leaderOnlySync = bigsegments.NewLeaderOnlySynchronizer(makeSynchronizer, bigSegmentStore)
+			envContext.bigSegmentSync = leaderOnlySync
+		} else {
+			envContext.bigSegmentSync = makeSynchronizer()
+		}
 		thingsToCleanUp.AddFunc(envContext.bigSegmentSync.Close)
 		segmentUpdateCh := envContext.bigSegmentSync.SegmentUpdatesCh()
 		if segmentUpdateCh != nil {
@@ -247,6 +331,30 @@ func NewEnvContext(
 		// envContextStreamUpdates methods.
 	}
 
+	if params.StoreWriteGate != nil && params.StoreLeaseClient != nil {
+		leaseStore := params.StoreLeaseClient.LeaseStore(envConfig)
+		envContext.storeLeaseStore = leaseStore
+		storeWriteGate := params.StoreWriteGate
+		envContext.storeElector = cluster.NewElector(
+			leaseStore,
+			storeWriterLeaseName,
+			params.ClusterNodeID,
+			storeWriterLeaseRenewInterval,
+			storeWriterLeaseTTL,
+			func(isLeader bool) {
+				storeWriteGate.SetWriter(isLeader)
+				if leaderOnlySync != nil {
+					leaderOnlySync.SetLeader(isLeader)
+				}
+			},
+			envLoggers,
+		)
+		thingsToCleanUp.AddFunc(func() {
+			envContext.storeElector.Close()
+			_ = leaseStore.Close()
+		})
+	}
+
 	envStreams := streams.NewEnvStreams(
 		params.StreamProviders,
 		envContextStoreQueries{envContext},
@@ -299,6 +407,7 @@ func NewEnvContext(
 				allConfig.Events,
 				httpConfig,
 				storeAdapter,
+				eventMetricsRecorder{envContext},
 				0, // 0 here means "use the default interval for any periodic cleanup task you may need to run"
 			)
 		}
@@ -334,12 +443,23 @@ func NewEnvContext(
 	}
 	envContext.metricsEnv = em
 
-	disconnectedStatusTime := allConfig.Main.DisconnectedStatusTime.GetOrElse(config.DefaultDisconnectedStatusTime)
-
-	dataSource := ldcomponents.StreamingDataSource()
+	streamingDataSource := ldcomponents.StreamingDataSource()
 
 	if params.EnvConfig.FilterKey != "" {
-		dataSource.PayloadFilter(string(params.EnvConfig.FilterKey))
+		streamingDataSource.PayloadFilter(string(params.EnvConfig.FilterKey))
+	}
+
+	var dataSource subsystems.ComponentConfigurer[subsystems.DataSource] = streamingDataSource
+	if params.ClusterBackend != nil {
+		// In cluster mode, only the node that is elected as the leader for this environment uses the
+		// streaming data source; the others get the data from the leader.
+		dataSource = cluster.DataSource(streamingDataSource, cluster.DataSourceOptions{
+			Backend:           params.ClusterBackend,
+			NodeID:            params.ClusterNodeID,
+			FilterKey:         string(params.EnvConfig.FilterKey),
+			HeartbeatInterval: allConfig.Cluster.HeartbeatInterval.GetOrElse(config.DefaultClusterHeartbeatInterval),
+			LeaderTimeout:     allConfig.Cluster.LeaderTimeout.GetOrElse(config.DefaultClusterLeaderTimeout),
+		})
 	}
 
 	envContext.sdkConfig = ld.Config{
@@ -350,7 +470,7 @@ func NewEnvContext(
 		HTTP:             httpConfig.SDKHTTPConfigFactory,
 		Logging: ldcomponents.Logging().
 			Loggers(envLoggers).
-			LogDataSourceOutageAsErrorAfter(disconnectedStatusTime),
+			LogDataSourceOutageAsErrorAfter(envContext.disconnectedStatusTime),
 		ServiceEndpoints: interfaces.ServiceEndpoints{
 			Streaming: streamURI,
 			Events:    eventsURI,
@@ -402,6 +522,13 @@ func NewEnvContext(
 	}
 	go envContext.cleanupExpiredCredentials(cleanupInterval)
 
+	checkInterval, repair := sdks.GetDataStoreConsistencyCheckSettings(allConfig)
+	if params.StoreChecker != nil && checkInterval > 0 {
+		envContext.stopCheckingStore = make(chan struct{})
+		envContext.doneCheckingStore = make(chan struct{})
+		go envContext.checkDataStoreConsistencyPeriodically(checkInterval, repair)
+	}
+
 	thingsToCleanUp.Clear() // we've succeeded so we do not want to throw away these things
 
 	return envContext, nil
@@ -421,6 +548,112 @@ func (c *envContextImpl) cleanupExpiredCredentials(interval time.Duration) {
 	}
 }
 
+// recordStatusHistory adds the SDK client's current statuses to the status history, and then starts a
+// goroutine that adds every change that the client reports until the client is closed. Changes are ignored
+// once the client is no longer the environment's current client, so that shutting down a replaced client
+// is not recorded. The same goroutine reports changes to the environment's overall status.
+func (c *envContextImpl) recordStatusHistory(client sdks.LDClientContext) {
+	sourceCh := client.AddDataSourceStatusListener()
+	storeCh := client.AddDataStoreStatusListener()
+	sourceStatus, storeStatus := client.GetDataSourceStatus(), client.GetDataStoreStatus()
+	c.statusHistory.record(sourceStatus, storeStatus)
+	untilDisconnected := c.updateEnvStatus(client, sourceStatus, storeStatus)
+	go func() {
+		// An outage only makes the environment disconnected once it has lasted long enough, and the client
+		// won't tell us when that happens, so we check again at that time.
+		var disconnectedCh <-chan time.Time
+		if untilDisconnected > 0 {
+			disconnectedCh = time.After(untilDisconnected)
+		}
+		for sourceCh != nil || storeCh != nil {
+			select {
+			case status, ok := <-sourceCh:
+				if !ok {
+					sourceCh = nil
+					continue
+				}
+				sourceStatus = status
+				if c.GetClient() != client {
+					continue
+				}
+				c.statusHistory.recordDataSource(status)
+			case status, ok := <-storeCh:
+				if !ok {
+					storeCh = nil
+					continue
+				}
+				storeStatus = status
+				if c.GetClient() != client {
+					continue
+				}
+				c.statusHistory.recordDataStore(status)
+			case <-disconnectedCh:
+				if c.GetClient() != client {
+					continue
+				}
+			}
+			disconnectedCh = nil
+			if untilDisconnected := c.updateEnvStatus(client, sourceStatus, storeStatus); untilDisconnected > 0 {
+				disconnectedCh = time.After(untilDisconnected)
+			}
+		}
+	}()
+}
+
+// updateEnvStatus computes the environment's status in the same way as the status resource, from the
+// statuses that the SDK client reported, and reports it to the status change handler if it has changed.
+// It returns how long it will be until a data source outage has lasted long enough for the environment to
+// be disconnected, or zero if there is no outage that could do so.
+func (c *envContextImpl) updateEnvStatus(
+	client sdks.LDClientContext,
+	sourceStatus interfaces.DataSourceStatus,
+	storeStatus sdks.DataStoreStatusInfo,
+) time.Duration {
+	if c.statusChangeHandler == nil {
+		return 0
+	}
+	status := EnvStatus{Connected: client.Initialized(), DataStoreState: "VALID"}
+	var untilDisconnected time.Duration
+	if status.Connected && sourceStatus.State != interfaces.DataSourceStateValid {
+		untilDisconnected = c.disconnectedStatusTime - time.Since(sourceStatus.StateSince)
+		if untilDisconnected <= 0 {
+			status.Connected = false
+			untilDisconnected = 0
+		}
+	}
+	if !storeStatus.Available {
+		status.DataStoreState = "INTERRUPTED"
+	}
+
+	c.envStatusLock.Lock()
+	oldStatus := c.lastEnvStatus
+	c.lastEnvStatus = &status
+	c.envStatusLock.Unlock()
+	if oldStatus != nil && *oldStatus != status {
+		c.statusChangeHandler(c, *oldStatus, status)
+	}
+	return untilDisconnected
+}
+
+func (c *envContextImpl) checkDataStoreConsistencyPeriodically(interval time.Duration, repair bool) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if !c.storeChecker.HasData() {
+				continue // there is nothing to check until the first full data set has been received
+			}
+			if _, err := c.CheckDataStoreConsistency(repair); err != nil {
+				c.loggers.Warnf(logMsgStoreConsistencyCheckFailed, err)
+			}
+		case <-c.stopCheckingStore:
+			close(c.doneCheckingStore)
+			return
+		}
+	}
+}
+
 func (c *envContextImpl) addCredential(newCredential credential.SDKCredential) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -482,7 +715,14 @@ func (c *envContextImpl) removeCredential(oldCredential credential.SDKCredential
 }
 
 func (c *envContextImpl) startSDKClient(sdkKey config.SDKKey, readyCh chan<- EnvContext, suppressErrors bool) {
-	client, err := c.sdkClientFactory(sdkKey, c.sdkConfig, c.sdkInitTimeout)
+	timeout := c.sdkInitTimeout
+	if c.staleWhileUnavailable {
+		// In this mode, we don't let the SDK wait for initialization; we do our own waiting below, so that
+		// the environment can be used right away if the data store already has data.
+		timeout = 0
+	}
+	startTime := time.Now()
+	client, err := c.sdkClientFactory(sdkKey, c.sdkConfig, timeout)
 	c.mu.Lock()
 	name := c.identifiers.GetDisplayName()
 	if client != nil {
@@ -491,26 +731,29 @@ func (c *envContextImpl) startSDKClient(sdkKey config.SDKKey, readyCh chan<- Env
 		// The data store instance is created by the SDK when it creates the client. Now that
 		// we have a data store, we can finish setting up the Evaluator that we'll use for this
 		// environment.
-		store := c.storeAdapter.GetStore()
-		dataProvider := ldstoreimpl.NewDataStoreEvaluatorDataProvider(store, c.loggers)
-		evalOptions := []ldeval.EvaluatorOption{
-			// We're setting EnableSecondaryKey because we may be doing evaluations for client-side SDKs that
-			// are sending old-style user data with the "secondary" attribute. This option doesn't affect
-			// evaluations done for newer client-side SDKs that send contexts.
-			ldeval.EvaluatorOptionEnableSecondaryKey(true),
-		}
-		if c.sdkBigSegments != nil {
-			evalOptions = append(evalOptions, ldeval.EvaluatorOptionBigSegmentProvider(c.sdkBigSegments))
-		}
-		c.evaluator = ldeval.NewEvaluatorWithOptions(dataProvider, evalOptions...)
+		c.evaluator = c.makeEvaluator(c.storeAdapter.GetStore())
 	}
 	c.initErr = err
 	c.mu.Unlock()
+	if client != nil {
+		c.recordStatusHistory(client) // so that the status history starts with the client's initial states
+	}
+
+	servingStaleData := false
+	if client != nil && err == nil && c.staleWhileUnavailable {
+		servingStaleData, err = c.awaitInitializationOrStaleData(client)
+		c.mu.Lock()
+		c.initErr = err
+		c.mu.Unlock()
+	}
 
 	if err != nil {
 		if suppressErrors {
 			c.globalLoggers.Warnf("Ignoring error initializing LaunchDarkly client for %q: %+v",
 				name, err)
+			if client != nil {
+				go c.awaitLateInitialization(client, sdkKey, startTime, false)
+			}
 		} else {
 			c.globalLoggers.Errorf("Error initializing LaunchDarkly client for %q: %+v",
 				name, err)
@@ -519,7 +762,15 @@ func (c *envContextImpl) startSDKClient(sdkKey config.SDKKey, readyCh chan<- Env
 			}
 			return
 		}
+	} else if servingStaleData {
+		c.globalLoggers.Warnf("LaunchDarkly client for %q (SDK key %s) has not initialized yet; serving the last known data from the data store until it does",
+			name, sdkKey.Masked())
+		go c.awaitLateInitialization(client, sdkKey, startTime, true)
 	} else {
+		c.mu.Lock()
+		c.sdkInitDuration = time.Since(startTime)
+		c.sdkInitialized = true
+		c.mu.Unlock()
 		c.globalLoggers.Infof("Initialized LaunchDarkly client for %q (SDK key %s)", name, sdkKey.Masked())
 	}
 	if readyCh != nil {
@@ -527,6 +778,90 @@ func (c *envContextImpl) startSDKClient(sdkKey config.SDKKey, readyCh chan<- Env
 	}
 }
 
+func (c *envContextImpl) makeEvaluator(store subsystems.DataStore) ldeval.Evaluator {
+	dataProvider := ldstoreimpl.NewDataStoreEvaluatorDataProvider(store, c.loggers)
+	evalOptions := []ldeval.EvaluatorOption{
+		// We're setting EnableSecondaryKey because we may be doing evaluations for client-side SDKs that
+		// are sending old-style user data with the "secondary" attribute. This option doesn't affect
+		// evaluations done for newer client-side SDKs that send contexts.
+		ldeval.EvaluatorOptionEnableSecondaryKey(true),
+	}
+	if c.sdkBigSegments != nil {
+		evalOptions = append(evalOptions, ldeval.EvaluatorOptionBigSegmentProvider(c.sdkBigSegments))
+	}
+	return ldeval.NewEvaluatorWithOptions(dataProvider, evalOptions...)
+}
+
+// awaitInitializationOrStaleData is used instead of the SDK's own initialization timeout when StaleWhileUnavailable
+// is enabled. It waits until either the SDK client has initialized, or the data store has data that we can
+// serve in the meantime- returning true in the latter case. Otherwise it returns the same errors that the
+// SDK would: ld.ErrInitializationFailed if the SDK has given up, or ld.ErrInitializationTimeout.
+func (c *envContextImpl) awaitInitializationOrStaleData(client sdks.LDClientContext) (bool, error) {
+	deadline := time.NewTimer(c.sdkInitTimeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(staleDataPollInterval)
+	defer ticker.Stop()
+	for {
+		if client.Initialized() {
+			return false, nil
+		}
+		if store := c.GetStore(); store != nil && store.IsInitialized() {
+			return true, nil
+		}
+		if client.GetDataSourceStatus().State == interfaces.DataSourceStateOff {
+			return false, ld.ErrInitializationFailed
+		}
+		select {
+		case <-ticker.C:
+		case <-deadline.C:
+			return false, ld.ErrInitializationTimeout
+		}
+	}
+}
+
+// awaitLateInitialization is used when startSDKClient has finished without the SDK client being initialized,
+// either because we are serving stale data or because an initialization error was ignored. It waits until
+// the client does initialize, and then records the initialization time. It gives up if the client is
+// closed or replaced, or if the SDK has given up.
+func (c *envContextImpl) awaitLateInitialization(
+	client sdks.LDClientContext,
+	sdkKey config.SDKKey,
+	startTime time.Time,
+	servingStaleData bool,
+) {
+	ticker := time.NewTicker(staleDataPollInterval)
+	defer ticker.Stop()
+	for !client.Initialized() {
+		if c.GetClient() != client || client.GetDataSourceStatus().State == interfaces.DataSourceStateOff {
+			return
+		}
+		<-ticker.C
+	}
+	// The data source status changes to VALID when the client initializes, so its timestamp is more
+	// accurate than the time when we noticed.
+	initTime := time.Now()
+	if status := client.GetDataSourceStatus(); status.State == interfaces.DataSourceStateValid &&
+		!status.StateSince.IsZero() {
+		initTime = status.StateSince
+	}
+	c.mu.Lock()
+	if c.closed || c.clients[sdkKey] != client {
+		c.mu.Unlock()
+		return
+	}
+	c.sdkInitDuration = initTime.Sub(startTime)
+	c.sdkInitialized = true
+	c.initErr = nil
+	name := c.identifiers.GetDisplayName()
+	c.mu.Unlock()
+	if servingStaleData {
+		c.globalLoggers.Infof("LaunchDarkly client for %q (SDK key %s) has initialized; no longer serving stale data",
+			name, sdkKey.Masked())
+	} else {
+		c.globalLoggers.Infof("Initialized LaunchDarkly client for %q (SDK key %s)", name, sdkKey.Masked())
+	}
+}
+
 func (c *envContextImpl) GetPayloadFilter() config.FilterKey {
 	return c.filterKey
 }
@@ -592,6 +927,14 @@ func (c *envContextImpl) GetStore() subsystems.DataStore {
 	return c.storeAdapter.GetStore()
 }
 
+func (c *envContextImpl) GetDataHash() string {
+	return c.storeAdapter.GetDataHash()
+}
+
+func (c *envContextImpl) GetDataChangesSince(version string) ([]store.DataChange, string, bool) {
+	return c.storeAdapter.GetChangesSince(version)
+}
+
 func (c *envContextImpl) GetEvaluator() ldeval.Evaluator {
 	c.mu.RLock()
 	ret := c.evaluator
@@ -628,6 +971,10 @@ func invalidStreamHandler(w http.ResponseWriter, req *http.Request) {
 	w.WriteHeader(http.StatusNotFound)
 }
 
+func (c *envContextImpl) GetStreamConnectionLimiter() *streams.ConnectionLimiter {
+	return c.streamConnLimiter
+}
+
 func (c *envContextImpl) GetEventDispatcher() *events.EventDispatcher {
 	return c.eventDispatcher
 }
@@ -671,6 +1018,18 @@ func (c *envContextImpl) GetInitError() error {
 	return c.initErr
 }
 
+func (c *envContextImpl) IsServingStaleData() bool {
+	if !c.staleWhileUnavailable {
+		return false
+	}
+	client := c.GetClient()
+	if client == nil || client.Initialized() {
+		return false
+	}
+	store := c.GetStore()
+	return store != nil && store.IsInitialized()
+}
+
 func (c *envContextImpl) IsSecureMode() bool {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
@@ -678,6 +1037,10 @@ func (c *envContextImpl) IsSecureMode() bool {
 	return c.secureMode
 }
 
+func (c *envContextImpl) GetAllowedClientCertNames() []string {
+	return c.allowedClientCertNames
+}
+
 func (c *envContextImpl) SetSecureMode(secureMode bool) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -689,10 +1052,159 @@ func (c *envContextImpl) GetDataStoreInfo() sdks.DataStoreEnvironmentInfo {
 	return c.dataStoreInfo
 }
 
+func (c *envContextImpl) CheckDataStoreConsistency(repair bool) (store.ConsistencyCheckResult, error) {
+	if c.storeChecker == nil {
+		return store.ConsistencyCheckResult{}, errDataStoreConsistencyCheckNotEnabled
+	}
+	// When several Relay instances share the data store, only the one that is currently its writer
+	// should rewrite it.
+	if repair && c.storeWriteGate != nil && !c.storeWriteGate.IsWriter() {
+		repair = false
+	}
+	result, err := c.storeChecker.Check(repair)
+	if err != nil {
+		return result, err
+	}
+	if !result.IsConsistent() {
+		action := "it was not repaired"
+		if result.Repaired {
+			action = "all data has been rewritten to the data store"
+		}
+		c.loggers.Warnf("Data store is missing %d items and has %d out-of-date items; %s",
+			len(result.MissingItems), len(result.StaleItems), action)
+	}
+	metrics.RecordStoreInconsistentItems(c.GetMetricsContext(), len(result.MissingItems), len(result.StaleItems))
+	return result, nil
+}
+
+func (c *envContextImpl) GetDataStoreConsistency() (store.ConsistencyCheckResult, bool) {
+	if c.storeChecker == nil {
+		return store.ConsistencyCheckResult{}, false
+	}
+	return c.storeChecker.GetLastResult()
+}
+
+func (c *envContextImpl) GetStatusHistory() []StatusTransition {
+	return c.statusHistory.get()
+}
+
+func (c *envContextImpl) GetStreamSubscriberCounts() map[streams.StreamProvider]int {
+	return c.envStreams.GetSubscriberCounts()
+}
+
+func (c *envContextImpl) RestartClient() error {
+	if c.offline {
+		return errCannotRestartClientInOfflineMode
+	}
+	sdkKey := c.keyRotator.SDKKey()
+	c.mu.Lock()
+	if c.restarting {
+		c.mu.Unlock()
+		return ErrClientRestartInProgress
+	}
+	c.restarting = true
+	oldClient := c.clients[sdkKey]
+	c.mu.Unlock()
+	c.loggers.Infof("Restarting LaunchDarkly client (SDK key %s)", sdkKey.Masked())
+	go c.restartSDKClient(sdkKey, oldClient)
+	return nil
+}
+
+// restartSDKClient creates a new SDK client, with its own data store, and uses it in place of oldClient once
+// it has initialized. Until then, the old client and store continue to be used; if the new client fails to
+// initialize, it is discarded and the old one is kept.
+func (c *envContextImpl) restartSDKClient(sdkKey config.SDKKey, oldClient sdks.LDClientContext) {
+	defer func() {
+		c.mu.Lock()
+		c.restarting = false
+		c.mu.Unlock()
+	}()
+
+	// The new client gets its own data store, and the components that wrap a persistent store keep using
+	// the current one, until we know that the new client has initialized.
+	storeAdapter := c.storeAdapter.NewReplacement()
+	c.beginStoreReplacement()
+	sdkConfig := c.sdkConfig
+	sdkConfig.DataStore = storeAdapter
+	startTime := time.Now()
+	client, err := c.sdkClientFactory(sdkKey, sdkConfig, c.sdkInitTimeout)
+	if err == nil && !client.Initialized() {
+		err = ld.ErrInitializationFailed
+	}
+	if err != nil {
+		c.finishStoreReplacement(false)
+		if client != nil {
+			_ = client.Close()
+		}
+		c.loggers.Errorf("Error restarting LaunchDarkly client (SDK key %s), keeping the existing client: %+v",
+			sdkKey.Masked(), err)
+		return
+	}
+
+	c.mu.Lock()
+	if c.closed || c.clients[sdkKey] != oldClient {
+		// The environment was closed, or the SDK key was changed, while we were waiting.
+		c.finishStoreReplacement(false)
+		c.mu.Unlock()
+		_ = client.Close()
+		return
+	}
+	c.clients[sdkKey] = client
+	c.storeAdapter.ReplaceStore(storeAdapter)
+	c.finishStoreReplacement(true)
+	c.evaluator = c.makeEvaluator(c.storeAdapter.GetStore())
+	c.initErr = nil
+	c.sdkInitDuration = time.Since(startTime)
+	c.sdkInitialized = true
+	c.mu.Unlock()
+
+	if oldClient != nil {
+		_ = oldClient.Close()
+	}
+	c.recordStatusHistory(client)
+	c.loggers.Infof("Restarted LaunchDarkly client (SDK key %s)", sdkKey.Masked())
+}
+
+func (c *envContextImpl) beginStoreReplacement() {
+	if c.storeWriteGate != nil {
+		c.storeWriteGate.BeginReplacement()
+	}
+	if c.storeChecker != nil {
+		c.storeChecker.BeginReplacement()
+	}
+}
+
+func (c *envContextImpl) finishStoreReplacement(keep bool) {
+	if c.storeWriteGate != nil {
+		c.storeWriteGate.FinishReplacement(keep)
+	}
+	if c.storeChecker != nil {
+		c.storeChecker.FinishReplacement(keep)
+	}
+}
+
+func (c *envContextImpl) ResyncBigSegments() error {
+	if c.bigSegmentSync == nil {
+		return errBigSegmentsNotConfigured
+	}
+	c.bigSegmentSync.Resync()
+	return nil
+}
+
 func (c *envContextImpl) GetCreationTime() time.Time {
 	return c.creationTime
 }
 
+func (c *envContextImpl) GetSDKKeyExpiry() (time.Time, bool) {
+	return c.keyRotator.EarliestDeprecatedExpiry()
+}
+
+func (c *envContextImpl) GetSDKInitDuration() (time.Duration, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.sdkInitDuration, c.sdkInitialized
+}
+
 func (c *envContextImpl) FlushMetricsEvents() {
 	if c.metricsEnv != nil && c.metricsEventPub != nil {
 		c.metricsEnv.FlushEventsExporter()
@@ -716,6 +1228,11 @@ func (c *envContextImpl) Close() error {
 	close(c.stopMonitoringCredentials)
 	<-c.doneMonitoringCredentials
 
+	if c.stopCheckingStore != nil {
+		close(c.stopCheckingStore)
+		<-c.doneCheckingStore
+	}
+
 	_ = c.envStreams.Close()
 
 	if c.metricsManager != nil && c.metricsEnv != nil {
@@ -727,6 +1244,12 @@ func (c *envContextImpl) Close() error {
 	if c.eventDispatcher != nil {
 		c.eventDispatcher.Close()
 	}
+	if c.storeElector != nil {
+		// This releases the lease, so we do it only after the SDK clients have been closed and can no
+		// longer write to the store.
+		c.storeElector.Close()
+		_ = c.storeLeaseStore.Close()
+	}
 	if c.bigSegmentSync != nil {
 		c.bigSegmentSync.Close()
 	}
@@ -767,8 +1290,12 @@ func (q envContextStoreQueries) GetAll(kind ldstoretypes.DataKind) ([]ldstoretyp
 
 func (u *envContextStreamUpdates) SendAllDataUpdate(allData []ldstoretypes.Collection) {
 	// We use this delegator, rather than sending updates directory to context.envStreams, so that we
-	// can detect the presence of a big segment and turn on the big segment synchronizer as needed.
+	// can detect the presence of a big segment and turn on the big segment synchronizer as needed, and
+	// record changes in the change feed.
 	u.context.envStreams.SendAllDataUpdate(allData)
+	if u.context.changeTracker != nil {
+		u.context.changeTracker.RecordAllData(allData)
+	}
 	if u.context.bigSegmentSync == nil {
 		return
 	}
@@ -792,6 +1319,9 @@ func (u *envContextStreamUpdates) SendAllDataUpdate(allData []ldstoretypes.Colle
 func (u *envContextStreamUpdates) SendSingleItemUpdate(kind ldstoretypes.DataKind, key string, item ldstoretypes.ItemDescriptor) {
 	// See comments in SendAllDataUpdate.
 	u.context.envStreams.SendSingleItemUpdate(kind, key, item)
+	if u.context.changeTracker != nil {
+		u.context.changeTracker.RecordItem(kind, key, item)
+	}
 	if u.context.bigSegmentSync == nil {
 		return
 	}
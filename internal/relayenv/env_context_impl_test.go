@@ -8,6 +8,7 @@ import (
 	"regexp"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -20,12 +21,15 @@ import (
 	"github.com/launchdarkly/ld-relay/v8/config"
 	"github.com/launchdarkly/ld-relay/v8/internal/basictypes"
 	"github.com/launchdarkly/ld-relay/v8/internal/bigsegments"
+	"github.com/launchdarkly/ld-relay/v8/internal/changefeed"
+	"github.com/launchdarkly/ld-relay/v8/internal/cluster"
 	"github.com/launchdarkly/ld-relay/v8/internal/events"
 	"github.com/launchdarkly/ld-relay/v8/internal/httpconfig"
 	"github.com/launchdarkly/ld-relay/v8/internal/metrics"
 	"github.com/launchdarkly/ld-relay/v8/internal/sdks"
 	st "github.com/launchdarkly/ld-relay/v8/internal/sharedtest"
 	"github.com/launchdarkly/ld-relay/v8/internal/sharedtest/testclient"
+	"github.com/launchdarkly/ld-relay/v8/internal/store"
 	"github.com/launchdarkly/ld-relay/v8/internal/streams"
 
 	"github.com/launchdarkly/go-configtypes"
@@ -34,6 +38,8 @@ import (
 	"github.com/launchdarkly/go-sdk-common/v3/ldvalue"
 	ldevents "github.com/launchdarkly/go-sdk-events/v3"
 	"github.com/launchdarkly/go-server-sdk-evaluation/v3/ldbuilders"
+	ld "github.com/launchdarkly/go-server-sdk/v7"
+	"github.com/launchdarkly/go-server-sdk/v7/interfaces"
 	"github.com/launchdarkly/go-server-sdk/v7/ldcomponents"
 	"github.com/launchdarkly/go-server-sdk/v7/subsystems"
 	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoreimpl"
@@ -110,6 +116,10 @@ func TestConstructorBasicProperties(t *testing.T) {
 	assert.Equal(t, env, requireEnvReady(t, readyCh))
 	assert.Equal(t, env.GetClient(), requireClientReady(t, clientCh))
 	assert.Nil(t, env.GetInitError())
+	_, initialized := env.GetSDKInitDuration()
+	assert.True(t, initialized)
+	_, hasExpiry := env.GetSDKKeyExpiry()
+	assert.False(t, hasExpiry)
 
 	assert.NotNil(t, env.GetStore())
 }
@@ -296,6 +306,228 @@ func TestChangeSDKKey(t *testing.T) {
 
 }
 
+func TestRestartClient(t *testing.T) {
+	envConfig := st.EnvMain.Config
+	readyCh := make(chan EnvContext, 1)
+
+	clientCh := make(chan *testclient.FakeLDClient, 1)
+	clientFactory := testclient.FakeLDClientFactoryWithChannel(true, clientCh)
+
+	mockLog := ldlogtest.NewMockLog()
+	defer mockLog.DumpIfTestFailed(t)
+
+	env := makeBasicEnv(t, envConfig, clientFactory, mockLog.Loggers, readyCh)
+	defer env.Close()
+
+	assert.Equal(t, env, requireEnvReady(t, readyCh))
+	client1 := requireClientReady(t, clientCh)
+	store1 := env.GetStore()
+
+	require.NoError(t, env.RestartClient())
+
+	client2 := requireClientReady(t, clientCh)
+	assert.NotEqual(t, client1, client2)
+	if !helpers.AssertChannelClosed(t, client1.CloseCh, 1*time.Second, "old client should have been closed") {
+		t.FailNow()
+	}
+	assert.Equal(t, env.GetClient(), client2)
+	assert.NotSame(t, store1, env.GetStore())
+	assert.Equal(t, []credential.SDKCredential{envConfig.SDKKey}, env.GetCredentials())
+}
+
+func TestRestartClientKeepsOldClientIfNewClientDoesNotInitialize(t *testing.T) {
+	envConfig := st.EnvMain.Config
+	readyCh := make(chan EnvContext, 1)
+
+	clientCh := make(chan *testclient.FakeLDClient, 1)
+	initializedFactory := testclient.FakeLDClientFactoryWithChannel(true, clientCh)
+	uninitializedFactory := testclient.FakeLDClientFactoryWithChannel(false, clientCh)
+	var calls atomic.Int32
+	clientFactory := func(sdkKey config.SDKKey, config ld.Config, timeout time.Duration) (sdks.LDClientContext, error) {
+		if calls.Add(1) == 1 {
+			return initializedFactory(sdkKey, config, timeout)
+		}
+		return uninitializedFactory(sdkKey, config, timeout)
+	}
+
+	mockLog := ldlogtest.NewMockLog()
+	defer mockLog.DumpIfTestFailed(t)
+
+	env := makeBasicEnv(t, envConfig, clientFactory, mockLog.Loggers, readyCh)
+	defer env.Close()
+
+	requireEnvReady(t, readyCh)
+	client1 := requireClientReady(t, clientCh)
+	store1 := env.GetStore()
+
+	require.NoError(t, env.RestartClient())
+
+	client2 := requireClientReady(t, clientCh)
+	if !helpers.AssertChannelClosed(t, client2.CloseCh, 1*time.Second, "new client should have been closed") {
+		t.FailNow()
+	}
+	helpers.AssertChannelNotClosed(t, client1.CloseCh, 0, "old client should not have been closed")
+	assert.Equal(t, client1, env.GetClient())
+	assert.Same(t, store1, env.GetStore())
+	assert.Nil(t, env.GetInitError())
+}
+
+func TestRestartClientWhileRestartIsInProgress(t *testing.T) {
+	envConfig := st.EnvMain.Config
+	readyCh := make(chan EnvContext, 1)
+
+	clientCh := make(chan *testclient.FakeLDClient, 1)
+	fakeFactory := testclient.FakeLDClientFactoryWithChannel(true, clientCh)
+	startedCh, releaseCh := make(chan struct{}, 1), make(chan struct{})
+	var calls atomic.Int32
+	clientFactory := func(sdkKey config.SDKKey, config ld.Config, timeout time.Duration) (sdks.LDClientContext, error) {
+		if calls.Add(1) > 1 {
+			startedCh <- struct{}{}
+			<-releaseCh
+		}
+		return fakeFactory(sdkKey, config, timeout)
+	}
+
+	mockLog := ldlogtest.NewMockLog()
+	defer mockLog.DumpIfTestFailed(t)
+
+	env := makeBasicEnv(t, envConfig, clientFactory, mockLog.Loggers, readyCh)
+	defer env.Close()
+
+	requireEnvReady(t, readyCh)
+	client1 := requireClientReady(t, clientCh)
+
+	require.NoError(t, env.RestartClient())
+	helpers.RequireValue(t, startedCh, time.Second, "timed out waiting for restart")
+	assert.Equal(t, ErrClientRestartInProgress, env.RestartClient())
+
+	close(releaseCh)
+	client2 := requireClientReady(t, clientCh)
+	client1.AwaitClose(t, time.Second)
+	assert.Equal(t, client2, env.GetClient())
+
+	// Once the restart has finished, another one can be started.
+	assert.Eventually(t, func() bool { return env.RestartClient() == nil }, time.Second, time.Millisecond*10)
+	helpers.RequireValue(t, startedCh, time.Second, "timed out waiting for restart")
+	client3 := requireClientReady(t, clientCh)
+	client2.AwaitClose(t, time.Second)
+	assert.Equal(t, client3, env.GetClient())
+}
+
+func TestStatusHistory(t *testing.T) {
+	envConfig := st.EnvMain.Config
+	readyCh := make(chan EnvContext, 1)
+
+	clientCh := make(chan *testclient.FakeLDClient, 1)
+	clientFactory := testclient.FakeLDClientFactoryWithChannel(true, clientCh)
+
+	env := makeBasicEnv(t, envConfig, clientFactory, ldlog.NewDisabledLoggers(), readyCh)
+	defer env.Close()
+	requireEnvReady(t, readyCh)
+	client := requireClientReady(t, clientCh)
+
+	requireHistoryLen := func(n int) []StatusTransition {
+		require.Eventually(t, func() bool { return len(env.GetStatusHistory()) == n }, time.Second, time.Millisecond)
+		return env.GetStatusHistory()
+	}
+
+	history := requireHistoryLen(2)
+	assert.Equal(t, StatusSourceDataSource, history[0].Source)
+	assert.Equal(t, "VALID", history[0].State)
+	assert.Equal(t, StatusSourceDataStore, history[1].Source)
+	assert.Equal(t, "VALID", history[1].State)
+
+	errorTime := time.Now()
+	client.SetDataSourceStatus(interfaces.DataSourceStatus{
+		State:      interfaces.DataSourceStateInterrupted,
+		StateSince: errorTime,
+		LastError:  interfaces.DataSourceErrorInfo{Kind: interfaces.DataSourceErrorKindErrorResponse},
+	})
+	history = requireHistoryLen(3)
+	assert.Equal(t, StatusTransition{Time: errorTime, Source: StatusSourceDataSource, State: "INTERRUPTED",
+		ErrorKind: "ERROR_RESPONSE"}, history[2])
+
+	// A state that lasts for only a moment is still recorded, since transitions come from the SDK's
+	// status listeners rather than from checking the status periodically.
+	storeErrorTime := time.Now()
+	client.SetDataStoreStatus(sdks.DataStoreStatusInfo{Available: false, LastUpdated: storeErrorTime})
+	client.SetDataStoreStatus(sdks.DataStoreStatusInfo{Available: true, LastUpdated: storeErrorTime})
+	history = requireHistoryLen(5)
+	assert.Equal(t, StatusTransition{Time: storeErrorTime, Source: StatusSourceDataStore, State: "INTERRUPTED"},
+		history[3])
+	assert.Equal(t, StatusTransition{Time: storeErrorTime, Source: StatusSourceDataStore, State: "VALID"},
+		history[4])
+}
+
+func TestStatusChangeHandler(t *testing.T) {
+	type statusChange struct {
+		env      EnvContext
+		old, new EnvStatus
+	}
+	changesCh := make(chan statusChange, 10)
+	readyCh := make(chan EnvContext, 1)
+	clientCh := make(chan *testclient.FakeLDClient, 1)
+
+	var allConfig config.Config
+	allConfig.Main.DisconnectedStatusTime = configtypes.NewOptDuration(100 * time.Millisecond)
+	env, err := NewEnvContext(EnvContextImplParams{
+		Identifiers:      EnvIdentifiers{ConfiguredName: envName},
+		EnvConfig:        st.EnvMain.Config,
+		AllConfig:        allConfig,
+		ClientFactory:    testclient.FakeLDClientFactoryWithChannel(true, clientCh),
+		Loggers:          ldlog.NewDisabledLoggers(),
+		ConnectionMapper: mockConnectionMapper{},
+		StatusChangeHandler: func(changedEnv EnvContext, oldStatus, newStatus EnvStatus) {
+			changesCh <- statusChange{changedEnv, oldStatus, newStatus}
+		},
+	}, readyCh)
+	require.NoError(t, err)
+	defer env.Close()
+	requireEnvReady(t, readyCh)
+	client := requireClientReady(t, clientCh)
+
+	connected := EnvStatus{Connected: true, DataStoreState: "VALID"}
+	disconnected := EnvStatus{Connected: false, DataStoreState: "VALID"}
+	storeInterrupted := EnvStatus{Connected: true, DataStoreState: "INTERRUPTED"}
+
+	t.Run("initial status is not reported", func(t *testing.T) {
+		helpers.AssertNoMoreValues(t, changesCh, 50*time.Millisecond)
+	})
+
+	t.Run("brief data store outage", func(t *testing.T) {
+		client.SetDataStoreStatus(sdks.DataStoreStatusInfo{Available: false, LastUpdated: time.Now()})
+		client.SetDataStoreStatus(sdks.DataStoreStatusInfo{Available: true, LastUpdated: time.Now()})
+		assert.Equal(t, statusChange{env, connected, storeInterrupted}, helpers.RequireValue(t, changesCh, time.Second))
+		assert.Equal(t, statusChange{env, storeInterrupted, connected}, helpers.RequireValue(t, changesCh, time.Second))
+	})
+
+	t.Run("data source outage is reported once it lasts for the disconnected status time", func(t *testing.T) {
+		client.SetDataSourceStatus(interfaces.DataSourceStatus{State: interfaces.DataSourceStateInterrupted,
+			StateSince: time.Now()})
+		helpers.AssertNoMoreValues(t, changesCh, 50*time.Millisecond)
+		assert.Equal(t, statusChange{env, connected, disconnected}, helpers.RequireValue(t, changesCh, time.Second))
+
+		client.SetDataSourceStatus(interfaces.DataSourceStatus{State: interfaces.DataSourceStateValid,
+			StateSince: time.Now()})
+		client.SetDataSourceStatus(interfaces.DataSourceStatus{State: interfaces.DataSourceStateInterrupted,
+			StateSince: time.Now().Add(-time.Second)})
+		assert.Equal(t, statusChange{env, disconnected, connected}, helpers.RequireValue(t, changesCh, time.Second))
+		assert.Equal(t, statusChange{env, connected, disconnected}, helpers.RequireValue(t, changesCh, time.Second))
+	})
+}
+
+func TestRestartClientInOfflineMode(t *testing.T) {
+	envConfig := st.EnvMain.Config
+	envConfig.Offline = true
+	readyCh := make(chan EnvContext, 1)
+
+	env := makeBasicEnv(t, envConfig, testclient.FakeLDClientFactory(true), ldlog.NewDisabledLoggers(), readyCh)
+	defer env.Close()
+	requireEnvReady(t, readyCh)
+
+	assert.Equal(t, errCannotRestartClientInOfflineMode, env.RestartClient())
+}
+
 func TestSDKClientCreationFails(t *testing.T) {
 	envConfig := st.EnvWithAllCredentials.Config
 	envConfig.TTL = configtypes.NewOptDuration(time.Hour)
@@ -534,6 +766,39 @@ func TestBigSegmentsSynchronizerIsCreatedIfBigSegmentStoreExists(t *testing.T) {
 	assert.True(t, fakeSynchronizerFactory.synchronizer.isClosed())
 }
 
+func TestResyncBigSegments(t *testing.T) {
+	fakeBigSegmentStoreFactory := func(config.EnvConfig, config.Config, ldlog.Loggers) (bigsegments.BigSegmentStore, error) {
+		return bigsegments.NewNullBigSegmentStore(), nil
+	}
+	fakeSynchronizerFactory := &mockBigSegmentSynchronizerFactory{}
+
+	env, err := NewEnvContext(EnvContextImplParams{
+		Identifiers:                   EnvIdentifiers{ConfiguredName: st.EnvMain.Name},
+		EnvConfig:                     st.EnvMain.Config,
+		BigSegmentStoreFactory:        fakeBigSegmentStoreFactory,
+		BigSegmentSynchronizerFactory: fakeSynchronizerFactory.create,
+		ClientFactory:                 testclient.FakeLDClientFactory(true),
+		SDKBigSegmentsConfigFactory: ldcomponents.BigSegments(
+			st.ExistingInstance[subsystems.BigSegmentStore](&st.NoOpSDKBigSegmentStore{}),
+		),
+		Loggers: ldlog.NewDisabledLoggers(),
+	}, nil)
+	require.NoError(t, err)
+	defer env.Close()
+
+	require.NoError(t, env.ResyncBigSegments())
+	assert.Equal(t, 1, fakeSynchronizerFactory.getSynchronizer().getResyncs())
+}
+
+func TestResyncBigSegmentsNotConfigured(t *testing.T) {
+	readyCh := make(chan EnvContext, 1)
+	env := makeBasicEnv(t, st.EnvMain.Config, testclient.FakeLDClientFactory(true), ldlog.NewDisabledLoggers(), readyCh)
+	defer env.Close()
+	requireEnvReady(t, readyCh)
+
+	assert.Equal(t, errBigSegmentsNotConfigured, env.ResyncBigSegments())
+}
+
 func TestBigSegmentsSynchronizerIsStartedByFullDataUpdateWithBigSegment(t *testing.T) {
 	envConfig := st.EnvMain.Config
 	allConfig := config.Config{}
@@ -662,7 +927,7 @@ func TestReceivingBigSegmentsUpdateCausesClientSideInvalidationEvent(t *testing.
 	mockLog := ldlogtest.NewMockLog()
 	defer mockLog.DumpIfTestFailed(t)
 
-	jsClientStreams := streams.NewStreamProvider(basictypes.JSClientPingStream, time.Hour)
+	jsClientStreams := streams.NewStreamProvider(basictypes.JSClientPingStream, streams.StreamProviderOptions{MaxConnTime: time.Hour})
 	sdkStartedCh := make(chan EnvContext)
 	env, err := NewEnvContext(EnvContextImplParams{
 		Identifiers:                   EnvIdentifiers{ConfiguredName: st.EnvMain.Name},
@@ -715,8 +980,71 @@ func flushMetricsEvents(c *envContextImpl) {
 	}
 }
 
+func TestStoreLeaderElection(t *testing.T) {
+	leaseStore := cluster.NewInMemoryBackend()
+	fakeBigSegmentStoreFactory := func(config.EnvConfig, config.Config, ldlog.Loggers) (bigsegments.BigSegmentStore, error) {
+		return bigsegments.NewNullBigSegmentStore(), nil
+	}
+	bigSegmentData := []ldstoretypes.Collection{
+		{
+			Kind: ldstoreimpl.Segments(),
+			Items: []ldstoretypes.KeyedItemDescriptor{
+				{Key: "s1", Item: st.SegmentDesc(ldbuilders.NewSegmentBuilder("s1").Unbounded(true).Generation(1).Build())},
+			},
+		},
+	}
+
+	makeEnv := func(nodeID string) (EnvContext, *store.PersistentStoreWriteGate, *mockBigSegmentSynchronizerFactory) {
+		mockLog := ldlogtest.NewMockLog()
+		t.Cleanup(func() { mockLog.DumpIfTestFailed(t) })
+		gate := store.NewPersistentStoreWriteGate()
+		synchronizerFactory := &mockBigSegmentSynchronizerFactory{}
+		env, err := NewEnvContext(EnvContextImplParams{
+			Identifiers:                   EnvIdentifiers{ConfiguredName: st.EnvMain.Name},
+			EnvConfig:                     st.EnvMain.Config,
+			ClientFactory:                 testclient.FakeLDClientFactory(true),
+			ClusterNodeID:                 nodeID,
+			StoreWriteGate:                gate,
+			StoreLeaseClient:              fakeStoreLeaseClient{leaseStore},
+			BigSegmentStoreFactory:        fakeBigSegmentStoreFactory,
+			BigSegmentSynchronizerFactory: synchronizerFactory.create,
+			SDKBigSegmentsConfigFactory: ldcomponents.BigSegments(
+				st.ExistingInstance[subsystems.BigSegmentStore](&st.NoOpSDKBigSegmentStore{}),
+			),
+			Loggers: mockLog.Loggers,
+		}, nil)
+		require.NoError(t, err)
+		env.(*envContextImpl).storeAdapter.GetUpdates().SendAllDataUpdate(bigSegmentData)
+		return env, gate, synchronizerFactory
+	}
+
+	env1, gate1, synchronizerFactory1 := makeEnv("node1")
+	require.Eventually(t, gate1.IsWriter, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "node1", leaseStore.GetLeaseHolder(storeWriterLeaseName))
+	require.Eventually(t, func() bool { return synchronizerFactory1.getSynchronizer() != nil }, time.Second, 10*time.Millisecond)
+	assert.True(t, synchronizerFactory1.getSynchronizer().isStarted())
+
+	env2, gate2, synchronizerFactory2 := makeEnv("node2")
+	defer env2.Close()
+	assert.Never(t, gate2.IsWriter, 100*time.Millisecond, 10*time.Millisecond)
+	assert.Nil(t, synchronizerFactory2.getSynchronizer(), "big segment synchronizer should only run on the writer")
+
+	require.NoError(t, env1.Close())
+	assert.Equal(t, "", leaseStore.GetLeaseHolder(storeWriterLeaseName))
+	assert.True(t, synchronizerFactory1.getSynchronizer().isClosed())
+}
+
+type fakeStoreLeaseClient struct {
+	store cluster.LeaseStore
+}
+
+func (c fakeStoreLeaseClient) LeaseStore(config.EnvConfig) cluster.LeaseStore { return c.store }
+
+func (c fakeStoreLeaseClient) Close() error { return nil }
+
 type mockBigSegmentSynchronizerFactory struct {
 	synchronizer *mockBigSegmentSynchronizer
+	lock         sync.Mutex
 }
 
 func (f *mockBigSegmentSynchronizerFactory) create(
@@ -729,13 +1057,24 @@ func (f *mockBigSegmentSynchronizerFactory) create(
 	loggers ldlog.Loggers,
 	logPrefix string,
 ) bigsegments.BigSegmentSynchronizer {
+	f.lock.Lock()
+	defer f.lock.Unlock()
 	f.synchronizer = &mockBigSegmentSynchronizer{updateCh: make(chan bigsegments.UpdatesSummary)}
 	return f.synchronizer
 }
 
+// getSynchronizer is used instead of accessing the synchronizer field directly, in tests where the
+// synchronizer is created on another goroutine.
+func (f *mockBigSegmentSynchronizerFactory) getSynchronizer() *mockBigSegmentSynchronizer {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.synchronizer
+}
+
 type mockBigSegmentSynchronizer struct {
 	started  bool
 	closed   bool
+	resyncs  int
 	updateCh chan bigsegments.UpdatesSummary
 	lock     sync.Mutex
 }
@@ -754,6 +1093,18 @@ func (s *mockBigSegmentSynchronizer) SegmentUpdatesCh() <-chan bigsegments.Updat
 	return s.updateCh
 }
 
+func (s *mockBigSegmentSynchronizer) Resync() {
+	s.lock.Lock()
+	s.resyncs++
+	s.lock.Unlock()
+}
+
+func (s *mockBigSegmentSynchronizer) getResyncs() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.resyncs
+}
+
 func (s *mockBigSegmentSynchronizer) Close() {
 	s.lock.Lock()
 	s.closed = true
@@ -771,3 +1122,283 @@ func (s *mockBigSegmentSynchronizer) isClosed() bool {
 	defer s.lock.Unlock()
 	return s.closed
 }
+
+// mockPersistentDataStore is a simple PersistentDataStore that stores item versions separately from the
+// serialized items, as the DynamoDB store does.
+type mockPersistentDataStore struct {
+	data   map[ldstoretypes.DataKind]map[string]ldstoretypes.SerializedItemDescriptor
+	inited bool
+	lock   sync.Mutex
+}
+
+func newMockPersistentDataStore() *mockPersistentDataStore {
+	return &mockPersistentDataStore{data: make(map[ldstoretypes.DataKind]map[string]ldstoretypes.SerializedItemDescriptor)}
+}
+
+func (m *mockPersistentDataStore) Init(allData []ldstoretypes.SerializedCollection) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.data = make(map[ldstoretypes.DataKind]map[string]ldstoretypes.SerializedItemDescriptor)
+	for _, coll := range allData {
+		m.data[coll.Kind] = make(map[string]ldstoretypes.SerializedItemDescriptor)
+		for _, item := range coll.Items {
+			m.data[coll.Kind][item.Key] = item.Item
+		}
+	}
+	m.inited = true
+	return nil
+}
+
+func (m *mockPersistentDataStore) Upsert(
+	kind ldstoretypes.DataKind,
+	key string,
+	item ldstoretypes.SerializedItemDescriptor,
+) (bool, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.data[kind] == nil {
+		m.data[kind] = make(map[string]ldstoretypes.SerializedItemDescriptor)
+	}
+	if old, ok := m.data[kind][key]; ok && old.Version >= item.Version {
+		return false, nil
+	}
+	m.data[kind][key] = item
+	return true, nil
+}
+
+func (m *mockPersistentDataStore) Get(kind ldstoretypes.DataKind, key string) (ldstoretypes.SerializedItemDescriptor, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if item, ok := m.data[kind][key]; ok {
+		return item, nil
+	}
+	return ldstoretypes.SerializedItemDescriptor{}.NotFound(), nil
+}
+
+func (m *mockPersistentDataStore) GetAll(kind ldstoretypes.DataKind) ([]ldstoretypes.KeyedSerializedItemDescriptor, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	var ret []ldstoretypes.KeyedSerializedItemDescriptor
+	for key, item := range m.data[kind] {
+		ret = append(ret, ldstoretypes.KeyedSerializedItemDescriptor{Key: key, Item: item})
+	}
+	return ret, nil
+}
+
+func (m *mockPersistentDataStore) IsInitialized() bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.inited
+}
+
+func (m *mockPersistentDataStore) IsStoreAvailable() bool { return true }
+
+func (m *mockPersistentDataStore) Close() error { return nil }
+
+func (m *mockPersistentDataStore) deleteItem(kind ldstoretypes.DataKind, key string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	delete(m.data[kind], key)
+}
+
+func (m *mockPersistentDataStore) hasItem(kind ldstoretypes.DataKind, key string) bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	_, ok := m.data[kind][key]
+	return ok
+}
+
+func TestDataStoreConsistencyCheckNotEnabled(t *testing.T) {
+	readyCh := make(chan EnvContext, 1)
+	env := makeBasicEnv(t, st.EnvMain.Config, testclient.FakeLDClientFactory(true), ldlog.NewDisabledLoggers(), readyCh)
+	defer env.Close()
+	requireEnvReady(t, readyCh)
+
+	_, err := env.CheckDataStoreConsistency(false)
+	assert.Equal(t, errDataStoreConsistencyCheckNotEnabled, err)
+	_, ok := env.GetDataStoreConsistency()
+	assert.False(t, ok)
+}
+
+func TestDataStoreConsistencyCheck(t *testing.T) {
+	mockLog := ldlogtest.NewMockLog()
+	defer mockLog.DumpIfTestFailed(t)
+	persistentStore := newMockPersistentDataStore()
+	checker := store.NewPersistentStoreChecker()
+	redisURL, _ := configtypes.NewOptURLAbsoluteFromString("redis://localhost:6379")
+	readyCh := make(chan EnvContext, 1)
+
+	env, err := NewEnvContext(EnvContextImplParams{
+		Identifiers: EnvIdentifiers{ConfiguredName: envName},
+		EnvConfig:   st.EnvMain.Config,
+		AllConfig: config.Config{Redis: config.RedisConfig{
+			URL:                      redisURL,
+			ConsistencyCheckInterval: configtypes.NewOptDuration(10 * time.Millisecond),
+		}},
+		ClientFactory: testclient.FakeLDClientFactory(true),
+		DataStoreFactory: ldcomponents.PersistentDataStore(
+			checker.Wrap(st.ExistingInstance[subsystems.PersistentDataStore](persistentStore)),
+		).NoCaching(),
+		StoreChecker: checker,
+		BigSegmentStoreFactory: func(config.EnvConfig, config.Config, ldlog.Loggers) (bigsegments.BigSegmentStore, error) {
+			return nil, nil
+		},
+		Loggers: mockLog.Loggers,
+	}, readyCh)
+	require.NoError(t, err)
+	defer env.Close()
+	requireEnvReady(t, readyCh)
+
+	time.Sleep(50 * time.Millisecond) // let several check intervals pass before there is any data
+	_, ok := env.GetDataStoreConsistency()
+	assert.False(t, ok)
+
+	flag := ldbuilders.NewFlagBuilder("flag1").Version(2).Build()
+	require.NoError(t, env.GetStore().Init([]ldstoretypes.Collection{
+		{Kind: ldstoreimpl.Features(), Items: []ldstoretypes.KeyedItemDescriptor{{Key: flag.Key, Item: st.FlagDesc(flag)}}},
+	}))
+	persistentStore.deleteItem(ldstoreimpl.Features(), flag.Key)
+
+	require.Eventually(t, func() bool {
+		result, ok := env.GetDataStoreConsistency()
+		return ok && len(result.MissingItems) == 1
+	}, time.Second, 10*time.Millisecond, "periodic check should have found the missing item")
+	result, _ := env.GetDataStoreConsistency()
+	assert.False(t, result.Repaired, "should not repair the store if repair is not enabled")
+	assert.False(t, persistentStore.hasItem(ldstoreimpl.Features(), flag.Key))
+	mockLog.AssertMessageMatch(t, true, ldlog.Warn, "Data store is missing 1 items and has 0 out-of-date items")
+
+	result, err = env.CheckDataStoreConsistency(true)
+	require.NoError(t, err)
+	assert.Equal(t, []store.InconsistentItem{{Kind: "features", Key: flag.Key, ExpectedVersion: 2}}, result.MissingItems)
+	assert.True(t, result.Repaired)
+	assert.True(t, persistentStore.hasItem(ldstoreimpl.Features(), flag.Key))
+	mockLog.AssertMessageMatch(t, false, ldlog.Warn, "Unable to check data store consistency")
+}
+
+func TestStaleWhileUnavailable(t *testing.T) {
+	makeEnvWithLoggers := func(t *testing.T, staleWhileUnavailable bool, persistentStore *mockPersistentDataStore,
+		clientCh chan *testclient.FakeLDClient, readyCh chan EnvContext, loggers ldlog.Loggers) EnvContext {
+		allConfig := config.Config{Main: config.MainConfig{
+			StaleWhileUnavailable: staleWhileUnavailable,
+			InitTimeout:           configtypes.NewOptDuration(250 * time.Millisecond),
+		}}
+		env, err := NewEnvContext(EnvContextImplParams{
+			Identifiers:   EnvIdentifiers{ConfiguredName: envName},
+			EnvConfig:     st.EnvMain.Config,
+			AllConfig:     allConfig,
+			ClientFactory: testclient.FakeLDClientFactoryWithChannel(false, clientCh),
+			DataStoreFactory: ldcomponents.PersistentDataStore(
+				st.ExistingInstance[subsystems.PersistentDataStore](persistentStore),
+			).NoCaching(),
+			Loggers: loggers,
+		}, readyCh)
+		require.NoError(t, err)
+		return env
+	}
+	makeEnv := func(t *testing.T, staleWhileUnavailable bool, persistentStore *mockPersistentDataStore,
+		clientCh chan *testclient.FakeLDClient, readyCh chan EnvContext) EnvContext {
+		return makeEnvWithLoggers(t, staleWhileUnavailable, persistentStore, clientCh, readyCh, ldlog.NewDisabledLoggers())
+	}
+	makePopulatedStore := func(t *testing.T) *mockPersistentDataStore {
+		persistentStore := newMockPersistentDataStore()
+		require.NoError(t, persistentStore.Init(nil))
+		return persistentStore
+	}
+
+	t.Run("serves data that is already in the store", func(t *testing.T) {
+		readyCh := make(chan EnvContext, 1)
+		env := makeEnv(t, true, makePopulatedStore(t), nil, readyCh)
+		defer env.Close()
+
+		requireEnvReady(t, readyCh)
+		assert.NoError(t, env.GetInitError())
+		assert.True(t, env.IsServingStaleData())
+	})
+
+	t.Run("records initialization time once the SDK client catches up", func(t *testing.T) {
+		mockLog := ldlogtest.NewMockLog()
+		clientCh := make(chan *testclient.FakeLDClient, 1)
+		readyCh := make(chan EnvContext, 1)
+		startTime := time.Now()
+		env := makeEnvWithLoggers(t, true, makePopulatedStore(t), clientCh, readyCh, mockLog.Loggers)
+		defer env.Close()
+
+		client := requireClientReady(t, clientCh)
+		requireEnvReady(t, readyCh)
+		assert.True(t, env.IsServingStaleData())
+		_, initialized := env.GetSDKInitDuration()
+		assert.False(t, initialized)
+
+		client.SetInitialized(true)
+		require.Eventually(t, func() bool {
+			_, initialized := env.GetSDKInitDuration()
+			return initialized
+		}, time.Second, time.Millisecond*10)
+		duration, _ := env.GetSDKInitDuration()
+		assert.LessOrEqual(t, duration, time.Since(startTime))
+		assert.False(t, env.IsServingStaleData())
+		mockLog.AssertMessageMatch(t, true, ldlog.Info, "has initialized; no longer serving stale data")
+	})
+
+	t.Run("times out if the store is empty", func(t *testing.T) {
+		readyCh := make(chan EnvContext, 1)
+		env := makeEnv(t, true, newMockPersistentDataStore(), nil, readyCh)
+		defer env.Close()
+
+		requireEnvReady(t, readyCh)
+		assert.Equal(t, ld.ErrInitializationTimeout, env.GetInitError())
+		assert.False(t, env.IsServingStaleData())
+	})
+
+	t.Run("fails if the SDK client gives up", func(t *testing.T) {
+		clientCh := make(chan *testclient.FakeLDClient, 1)
+		readyCh := make(chan EnvContext, 1)
+		env := makeEnv(t, true, newMockPersistentDataStore(), clientCh, readyCh)
+		defer env.Close()
+
+		requireClientReady(t, clientCh).SetDataSourceStatus(interfaces.DataSourceStatus{State: interfaces.DataSourceStateOff})
+		requireEnvReady(t, readyCh)
+		assert.Equal(t, ld.ErrInitializationFailed, env.GetInitError())
+	})
+
+	t.Run("does not report stale data if not enabled", func(t *testing.T) {
+		readyCh := make(chan EnvContext, 1)
+		env := makeEnv(t, false, makePopulatedStore(t), nil, readyCh)
+		defer env.Close()
+
+		requireEnvReady(t, readyCh)
+		assert.False(t, env.IsServingStaleData())
+	})
+}
+
+func TestChangeFeedRecordsUpdates(t *testing.T) {
+	feed := changefeed.NewFeed(10, nil, ldlog.NewDisabledLoggers())
+	defer feed.Close()
+
+	env, err := NewEnvContext(EnvContextImplParams{
+		Identifiers:   EnvIdentifiers{ConfiguredName: st.EnvMain.Name},
+		EnvConfig:     st.EnvMain.Config,
+		ClientFactory: testclient.FakeLDClientFactory(true),
+		ChangeFeed:    feed,
+		Loggers:       ldlog.NewDisabledLoggers(),
+	}, nil)
+	require.NoError(t, err)
+	defer env.Close()
+
+	updates := env.(*envContextImpl).storeAdapter.GetUpdates()
+	f1v1 := ldbuilders.NewFlagBuilder("f1").Version(1).Build()
+	updates.SendAllDataUpdate([]ldstoretypes.Collection{
+		{Kind: ldstoreimpl.Features(), Items: []ldstoretypes.KeyedItemDescriptor{{Key: f1v1.Key, Item: st.FlagDesc(f1v1)}}},
+	})
+	f1v2 := ldbuilders.NewFlagBuilder("f1").Version(2).Build()
+	updates.SendSingleItemUpdate(ldstoreimpl.Features(), f1v2.Key, st.FlagDesc(f1v2))
+
+	changes := feed.GetChanges(0)
+	require.Len(t, changes, 1)
+	assert.Equal(t, st.EnvMain.Name, changes[0].Environment)
+	assert.Equal(t, "f1", changes[0].Key)
+	assert.Equal(t, 1, changes[0].OldVersion)
+	assert.Equal(t, 2, changes[0].NewVersion)
+	assert.Equal(t, changefeed.SourcePatch, changes[0].Source)
+}
@@ -0,0 +1,37 @@
+package relayenv
+
+import (
+	"github.com/launchdarkly/ld-relay/v8/internal/basictypes"
+	"github.com/launchdarkly/ld-relay/v8/internal/metrics"
+)
+
+// eventMetricsRecorder implements events.EventMetricsRecorder by recording OpenCensus metrics in the
+// environment's metrics context. The context is looked up on each call, because the event dispatcher is
+// created before the environment's metrics are set up.
+type eventMetricsRecorder struct {
+	env *envContextImpl
+}
+
+func (r eventMetricsRecorder) EventsReceived(sdkKind basictypes.SDKKind, count int) {
+	metrics.RecordEventsReceived(r.env.GetMetricsContext(), sdkKind, count)
+}
+
+func (r eventMetricsRecorder) EventsForwarded(count int) {
+	metrics.RecordEventsForwarded(r.env.GetMetricsContext(), count)
+}
+
+func (r eventMetricsRecorder) EventsDropped(count int) {
+	metrics.RecordEventsDropped(r.env.GetMetricsContext(), count)
+}
+
+func (r eventMetricsRecorder) EventSendFailed(statusKey string) {
+	metrics.RecordEventSendFailure(r.env.GetMetricsContext(), statusKey)
+}
+
+func (r eventMetricsRecorder) EventSendRetried(statusKey string) {
+	metrics.RecordEventSendRetry(r.env.GetMetricsContext(), statusKey)
+}
+
+func (r eventMetricsRecorder) EventQueueDepth(depth int) {
+	metrics.RecordEventQueueDepth(r.env.GetMetricsContext(), depth)
+}
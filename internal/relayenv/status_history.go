@@ -0,0 +1,138 @@
+package relayenv
+
+import (
+	"sync"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v8/internal/sdks"
+
+	"github.com/launchdarkly/go-server-sdk/v7/interfaces"
+)
+
+// statusHistorySize is the maximum number of transitions that are kept for each environment.
+const statusHistorySize = 20
+
+// StatusSource identifies which component's status changed in a StatusTransition.
+type StatusSource string
+
+const (
+	// StatusSourceDataSource means that the SDK client's data source changed state.
+	StatusSourceDataSource StatusSource = "dataSource"
+
+	// StatusSourceDataStore means that the SDK client's data store changed state.
+	StatusSourceDataStore StatusSource = "dataStore"
+)
+
+// StatusTransition describes a change in the state of an environment's data source or data store.
+type StatusTransition struct {
+	// Time is when the new state began.
+	Time time.Time
+
+	// Source is the component whose state changed.
+	Source StatusSource
+
+	// State is the new state. For the data source, this is a DataSourceState such as "VALID" or
+	// "INTERRUPTED"; for the data store, it is "VALID" or "INTERRUPTED".
+	State string
+
+	// ErrorKind is the kind of the data source's last error, if the data source is not VALID.
+	ErrorKind string
+}
+
+// EnvStatus summarizes an environment's state in the same terms as the status resource.
+type EnvStatus struct {
+	// Connected is true if the SDK client has initialized, and its data source has not been in any state
+	// other than VALID for longer than the configured disconnected status time.
+	Connected bool
+
+	// DataStoreState is "VALID" or "INTERRUPTED".
+	DataStoreState string
+}
+
+// StatusChangeHandler is called whenever an environment's EnvStatus changes. It is not called for the
+// environment's initial status.
+type StatusChangeHandler func(env EnvContext, oldStatus, newStatus EnvStatus)
+
+// statusHistory keeps the most recent status transitions for an environment in a ring buffer.
+type statusHistory struct {
+	transitions []StatusTransition // ring buffer; next is the position of the oldest transition once it is full
+	next        int
+	lastSource  *interfaces.DataSourceStatus
+	lastStore   *sdks.DataStoreStatusInfo
+	lock        sync.Mutex
+}
+
+func newStatusHistory() *statusHistory {
+	return &statusHistory{transitions: make([]StatusTransition, 0, statusHistorySize)}
+}
+
+// record compares the current statuses with the last ones, and adds a transition for each one that has
+// changed. The first statuses are also recorded, since they are the initial states.
+func (h *statusHistory) record(sourceStatus interfaces.DataSourceStatus, storeStatus sdks.DataStoreStatusInfo) {
+	h.recordDataSource(sourceStatus)
+	h.recordDataStore(storeStatus)
+}
+
+// recordDataSource adds a transition if the data source status has changed. If the status has no
+// timestamp, the current time is used.
+func (h *statusHistory) recordDataSource(sourceStatus interfaces.DataSourceStatus) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.lastSource != nil && h.lastSource.State == sourceStatus.State &&
+		h.lastSource.StateSince.Equal(sourceStatus.StateSince) {
+		return
+	}
+	t := StatusTransition{
+		Time:   timeOrNow(sourceStatus.StateSince),
+		Source: StatusSourceDataSource,
+		State:  string(sourceStatus.State),
+	}
+	if sourceStatus.State != interfaces.DataSourceStateValid {
+		t.ErrorKind = string(sourceStatus.LastError.Kind)
+	}
+	h.add(t)
+	h.lastSource = &sourceStatus
+}
+
+// recordDataStore adds a transition if the data store status has changed. If the status has no
+// timestamp, the current time is used.
+func (h *statusHistory) recordDataStore(storeStatus sdks.DataStoreStatusInfo) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.lastStore != nil && h.lastStore.Available == storeStatus.Available {
+		return
+	}
+	t := StatusTransition{Time: timeOrNow(storeStatus.LastUpdated), Source: StatusSourceDataStore, State: "VALID"}
+	if !storeStatus.Available {
+		t.State = "INTERRUPTED"
+	}
+	h.add(t)
+	h.lastStore = &storeStatus
+}
+
+func (h *statusHistory) add(t StatusTransition) {
+	if len(h.transitions) < cap(h.transitions) {
+		h.transitions = append(h.transitions, t)
+	} else {
+		h.transitions[h.next] = t
+		h.next = (h.next + 1) % len(h.transitions)
+	}
+}
+
+// get returns the transitions, oldest first.
+func (h *statusHistory) get() []StatusTransition {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	ret := make([]StatusTransition, 0, len(h.transitions))
+	for i := range h.transitions {
+		ret = append(ret, h.transitions[(h.next+i)%len(h.transitions)])
+	}
+	return ret
+}
+
+func timeOrNow(t time.Time) time.Time {
+	if t.IsZero() {
+		return time.Now()
+	}
+	return t
+}
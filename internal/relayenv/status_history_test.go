@@ -0,0 +1,57 @@
+package relayenv
+
+import (
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v8/internal/sdks"
+
+	"github.com/launchdarkly/go-server-sdk/v7/interfaces"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatusHistoryRecordsTransitions(t *testing.T) {
+	h := newStatusHistory()
+	t0 := time.Now()
+	validSource := interfaces.DataSourceStatus{State: interfaces.DataSourceStateValid, StateSince: t0}
+	availableStore := sdks.DataStoreStatusInfo{Available: true, LastUpdated: t0}
+
+	h.record(validSource, availableStore)
+	h.record(validSource, availableStore) // no change
+
+	interruptedSource := interfaces.DataSourceStatus{State: interfaces.DataSourceStateInterrupted,
+		StateSince: t0.Add(time.Second),
+		LastError:  interfaces.DataSourceErrorInfo{Kind: interfaces.DataSourceErrorKindNetworkError}}
+	unavailableStore := sdks.DataStoreStatusInfo{Available: false, LastUpdated: t0.Add(2 * time.Second)}
+	h.record(interruptedSource, unavailableStore)
+
+	validAgain := interfaces.DataSourceStatus{State: interfaces.DataSourceStateValid, StateSince: t0.Add(3 * time.Second),
+		LastError: interruptedSource.LastError}
+	h.record(validAgain, unavailableStore)
+
+	assert.Equal(t, []StatusTransition{
+		{Time: t0, Source: StatusSourceDataSource, State: "VALID"},
+		{Time: t0, Source: StatusSourceDataStore, State: "VALID"},
+		{Time: t0.Add(time.Second), Source: StatusSourceDataSource, State: "INTERRUPTED", ErrorKind: "NETWORK_ERROR"},
+		{Time: t0.Add(2 * time.Second), Source: StatusSourceDataStore, State: "INTERRUPTED"},
+		{Time: t0.Add(3 * time.Second), Source: StatusSourceDataSource, State: "VALID"},
+	}, h.get())
+}
+
+func TestStatusHistoryKeepsMostRecentTransitions(t *testing.T) {
+	h := newStatusHistory()
+	t0 := time.Now()
+	store := sdks.DataStoreStatusInfo{Available: true, LastUpdated: t0}
+	for i := 0; i < statusHistorySize+5; i++ {
+		h.record(interfaces.DataSourceStatus{State: interfaces.DataSourceStateValid,
+			StateSince: t0.Add(time.Duration(i) * time.Second)}, store)
+	}
+
+	// There were statusHistorySize+6 transitions, including the data store's initial state.
+	transitions := h.get()
+	require.Len(t, transitions, statusHistorySize)
+	assert.Equal(t, t0.Add(5*time.Second), transitions[0].Time)
+	assert.Equal(t, t0.Add(time.Duration(statusHistorySize+4)*time.Second), transitions[statusHistorySize-1].Time)
+}
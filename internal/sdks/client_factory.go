@@ -21,6 +21,12 @@ type LDClientContext interface {
 	SecureModeHash(ldcontext.Context) string
 	GetDataSourceStatus() interfaces.DataSourceStatus
 	GetDataStoreStatus() DataStoreStatusInfo
+	// AddDataSourceStatusListener returns a channel that receives the data source status whenever it
+	// changes. The channel is closed when the client is closed.
+	AddDataSourceStatusListener() <-chan interfaces.DataSourceStatus
+	// AddDataStoreStatusListener returns a channel that receives the data store status whenever it
+	// changes. The channel is closed when the client is closed.
+	AddDataStoreStatusListener() <-chan DataStoreStatusInfo
 	Close() error
 }
 
@@ -102,3 +108,19 @@ func (c *ldClientContextImpl) GetDataStoreStatus() DataStoreStatusInfo {
 		LastUpdated: statusTime,
 	}
 }
+
+func (c *ldClientContextImpl) AddDataSourceStatusListener() <-chan interfaces.DataSourceStatus {
+	return c.GetDataSourceStatusProvider().AddStatusListener()
+}
+
+func (c *ldClientContextImpl) AddDataStoreStatusListener() <-chan DataStoreStatusInfo {
+	sdkCh := c.GetDataStoreStatusProvider().AddStatusListener()
+	ch := make(chan DataStoreStatusInfo, 10)
+	go func() {
+		defer close(ch)
+		for status := range sdkCh {
+			ch <- DataStoreStatusInfo{Available: status.Available, LastUpdated: time.Now()}
+		}
+	}()
+	return ch
+}
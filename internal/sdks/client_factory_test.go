@@ -6,6 +6,8 @@ import (
 
 	"github.com/launchdarkly/ld-relay/v8/internal/sharedtest"
 
+	helpers "github.com/launchdarkly/go-test-helpers/v3"
+
 	ld "github.com/launchdarkly/go-server-sdk/v7"
 	"github.com/launchdarkly/go-server-sdk/v7/interfaces"
 	"github.com/launchdarkly/go-server-sdk/v7/ldcomponents"
@@ -133,6 +135,27 @@ func TestDataStoreStatusTracking(t *testing.T) {
 	assert.True(t, status1.LastUpdated.Before(status2.LastUpdated))
 }
 
+func TestDataStoreStatusListener(t *testing.T) {
+	store := &fakeStore{}
+	config := ld.Config{Offline: true, DataStore: fakeStoreFactory{store}, Logging: ldcomponents.NoLogging()}
+	client, err := DefaultClientFactory()("sdk-key", config, 0)
+	require.NoError(t, err)
+	require.NotNil(t, client)
+
+	statusCh := client.AddDataStoreStatusListener()
+	store.updates.UpdateStatus(interfaces.DataStoreStatus{Available: false})
+	store.updates.UpdateStatus(interfaces.DataStoreStatus{Available: true})
+
+	status1 := helpers.RequireValue(t, statusCh, time.Second)
+	assert.False(t, status1.Available)
+	status2 := helpers.RequireValue(t, statusCh, time.Second)
+	assert.True(t, status2.Available)
+	assert.False(t, status2.LastUpdated.Before(status1.LastUpdated))
+
+	require.NoError(t, client.Close())
+	helpers.AssertChannelClosed(t, statusCh, time.Second)
+}
+
 type fakeStore struct {
 	updates subsystems.DataStoreUpdateSink
 }
@@ -2,8 +2,10 @@ package sdks
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"strings"
+	"time"
 
 	"github.com/launchdarkly/ld-relay/v8/config"
 	"github.com/launchdarkly/ld-relay/v8/internal/util"
@@ -18,6 +20,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws"
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	goredis "github.com/go-redis/redis/v8"
 	redigo "github.com/gomodule/redigo/redis"
 	consul "github.com/hashicorp/consul/api"
 )
@@ -46,14 +49,33 @@ type DataStoreEnvironmentInfo struct {
 	DBTable string
 }
 
+// PersistentDataStoreDecorator is a function that ConfigureDataStore can apply to a persistent data
+// store factory, to add behavior underneath the SDK's caching layer.
+type PersistentDataStoreDecorator func(
+	subsystems.ComponentConfigurer[subsystems.PersistentDataStore],
+) subsystems.ComponentConfigurer[subsystems.PersistentDataStore]
+
 // ConfigureDataStore provides the appropriate Go SDK data store factory (in-memory, Redis, etc.) based on
 // the Relay configuration. It can return an error for some invalid configurations, but it assumes that we
 // have already done the standard validation steps defined in the config package.
+//
+// If decorator is non-nil and a persistent data store is configured, the persistent data store factory
+// is passed through decorator.
 func ConfigureDataStore(
 	allConfig config.Config,
 	envConfig config.EnvConfig,
+	decorator PersistentDataStoreDecorator,
 	loggers ldlog.Loggers,
 ) (subsystems.ComponentConfigurer[subsystems.DataStore], DataStoreEnvironmentInfo, error) {
+	decorate := func(
+		factory subsystems.ComponentConfigurer[subsystems.PersistentDataStore],
+	) subsystems.ComponentConfigurer[subsystems.PersistentDataStore] {
+		if decorator == nil {
+			return factory
+		}
+		return decorator(factory)
+	}
+
 	if allConfig.Redis.URL.IsDefined() {
 		// Our config validation already takes care of normalizing the Redis parameters so that if a
 		// host & port were specified, they are transformed into a URL.
@@ -71,7 +93,7 @@ func ConfigureDataStore(
 			storeInfo.DBPrefix = ldredis.DefaultPrefix
 		}
 
-		return ldcomponents.PersistentDataStore(redisBuilder).
+		return ldcomponents.PersistentDataStore(decorate(redisBuilder)).
 			CacheTime(allConfig.Redis.LocalTTL.GetOrElse(config.DefaultDatabaseCacheTTL)), storeInfo, nil
 	}
 
@@ -97,7 +119,7 @@ func ConfigureDataStore(
 			storeInfo.DBPrefix = ldconsul.DefaultPrefix
 		}
 
-		return ldcomponents.PersistentDataStore(builder).
+		return ldcomponents.PersistentDataStore(decorate(builder)).
 			CacheTime(dbConfig.LocalTTL.GetOrElse(config.DefaultDatabaseCacheTTL)), storeInfo, nil
 	}
 
@@ -116,13 +138,44 @@ func ConfigureDataStore(
 			DBTable:  tableName,
 		}
 
-		return ldcomponents.PersistentDataStore(builder).
+		return ldcomponents.PersistentDataStore(decorate(builder)).
 			CacheTime(allConfig.DynamoDB.LocalTTL.GetOrElse(config.DefaultDatabaseCacheTTL)), storeInfo, nil
 	}
 
 	return ldcomponents.InMemoryDataStore(), DataStoreEnvironmentInfo{}, nil
 }
 
+// IsDataStoreLeaderElectionEnabled returns true if Relay instances should elect a single writer for each
+// environment's persistent data store. This depends on the setting for whichever database is being used,
+// with the same order of precedence as in ConfigureDataStore.
+func IsDataStoreLeaderElectionEnabled(allConfig config.Config) bool {
+	switch {
+	case allConfig.Redis.URL.IsDefined():
+		return allConfig.Redis.LeaderElection
+	case allConfig.Consul.Host != "":
+		return allConfig.Consul.LeaderElection
+	case allConfig.DynamoDB.Enabled:
+		return allConfig.DynamoDB.LeaderElection
+	}
+	return false
+}
+
+// GetDataStoreConsistencyCheckSettings returns how often Relay should check that each environment's
+// persistent data store contains the data it has received, and whether it should repair the data store
+// if not. An interval of zero means there should be no periodic checks. As in IsDataStoreLeaderElectionEnabled,
+// this depends on the settings for whichever database is being used.
+func GetDataStoreConsistencyCheckSettings(allConfig config.Config) (interval time.Duration, repair bool) {
+	switch {
+	case allConfig.Redis.URL.IsDefined():
+		return allConfig.Redis.ConsistencyCheckInterval.GetOrElse(0), allConfig.Redis.ConsistencyCheckRepair
+	case allConfig.Consul.Host != "":
+		return allConfig.Consul.ConsistencyCheckInterval.GetOrElse(0), allConfig.Consul.ConsistencyCheckRepair
+	case allConfig.DynamoDB.Enabled:
+		return allConfig.DynamoDB.ConsistencyCheckInterval.GetOrElse(0), allConfig.DynamoDB.ConsistencyCheckRepair
+	}
+	return 0, false
+}
+
 // GetRedisBasicProperties transforms the configuration properties to the standard parameters
 // used for Redis. This function is exported to ensure consistency between the SDK
 // configuration and the internal big segment store for Redis.
@@ -147,6 +200,42 @@ func GetRedisBasicProperties(
 	return
 }
 
+// GetRedisClientOptions transforms the configuration properties to the options for a go-redis client.
+// This is used by Relay components that access Redis directly rather than through the SDK, such as the
+// internal big segment store for Redis.
+func GetRedisClientOptions(dbConfig config.RedisConfig) (*goredis.UniversalOptions, error) {
+	redisURL, _ := GetRedisBasicProperties(dbConfig, config.EnvConfig{})
+
+	opts := goredis.UniversalOptions{}
+
+	// Relay's Redis configuration allows setting the server address either as a URL or as a
+	// host & port, but our config validation logic simplifies this so that it is always a URL.
+	// However, it is still possible to set the Password and TLS options separately from the
+	// URL, so we still need to check for those.
+	parsed, err := goredis.ParseURL(redisURL)
+	if err != nil {
+		return nil, err
+	}
+	opts.DB = parsed.DB
+	opts.Addrs = []string{parsed.Addr}
+	opts.Username = parsed.Username
+	opts.Password = parsed.Password
+	opts.TLSConfig = parsed.TLSConfig
+	if dbConfig.Password != "" {
+		opts.Password = dbConfig.Password
+	}
+	if dbConfig.Username != "" {
+		opts.Username = dbConfig.Username
+	}
+	if dbConfig.TLS && opts.TLSConfig == nil {
+		opts.TLSConfig = &tls.Config{
+			ServerName: dbConfig.URL.Get().Hostname(),
+			MinVersion: tls.VersionTLS12,
+		}
+	}
+	return &opts, nil
+}
+
 func makeRedisDataStoreBuilder[T any](
 	constructor func() *ldredis.StoreBuilder[T],
 	allConfig config.Config,
@@ -36,7 +36,7 @@ func assertFactoryConfigured(
 	ec config.EnvConfig,
 ) *ldlogtest.MockLog {
 	mockLog := ldlogtest.NewMockLog()
-	factory, info, err := ConfigureDataStore(c, ec, mockLog.Loggers)
+	factory, info, err := ConfigureDataStore(c, ec, nil, mockLog.Loggers)
 	assert.NoError(t, err)
 	if expected != nil {
 		assert.Equal(t, expected, factory)
@@ -176,6 +176,28 @@ func TestConfigureDataStoreConsul(t *testing.T) {
 		assertFactoryConfigured(t, expected, expectedInfo, c, config.EnvConfig{})
 	})
 
+	t.Run("decorator", func(t *testing.T) {
+		c := config.Config{
+			Consul: config.ConsulConfig{
+				Host: host,
+			},
+		}
+		var decorated subsystems.ComponentConfigurer[subsystems.PersistentDataStore]
+		decorator := func(
+			f subsystems.ComponentConfigurer[subsystems.PersistentDataStore],
+		) subsystems.ComponentConfigurer[subsystems.PersistentDataStore] {
+			decorated = f
+			return ldconsul.DataStore().Address("other-host")
+		}
+		expected := ldcomponents.PersistentDataStore(
+			ldconsul.DataStore().Address("other-host"),
+		).CacheTime(config.DefaultDatabaseCacheTTL)
+		factory, _, err := ConfigureDataStore(c, config.EnvConfig{}, decorator, ldlog.NewDisabledLoggers())
+		assert.NoError(t, err)
+		assert.Equal(t, expected, factory)
+		assert.Equal(t, ldconsul.DataStore().Address(host), decorated)
+	})
+
 	t.Run("tokenFile", func(t *testing.T) {
 		c := config.Config{
 			Consul: config.ConsulConfig{
@@ -201,8 +223,64 @@ func TestConfigureDataStoreDynamoDB(t *testing.T) {
 				Enabled: true,
 			},
 		}
-		factory, _, err := ConfigureDataStore(c, config.EnvConfig{}, ldlog.NewDisabledLoggers())
+		factory, _, err := ConfigureDataStore(c, config.EnvConfig{}, nil, ldlog.NewDisabledLoggers())
 		assert.Nil(t, factory)
 		assert.Error(t, err)
 	})
 }
+
+func TestIsDataStoreLeaderElectionEnabled(t *testing.T) {
+	redisURL, _ := configtypes.NewOptURLAbsoluteFromString("redis://redishost:3000")
+
+	assert.False(t, IsDataStoreLeaderElectionEnabled(config.Config{}))
+	assert.False(t, IsDataStoreLeaderElectionEnabled(config.Config{
+		Redis: config.RedisConfig{URL: redisURL},
+	}))
+	assert.True(t, IsDataStoreLeaderElectionEnabled(config.Config{
+		Redis: config.RedisConfig{URL: redisURL, LeaderElection: true},
+	}))
+	assert.True(t, IsDataStoreLeaderElectionEnabled(config.Config{
+		Consul: config.ConsulConfig{Host: "consulhost", LeaderElection: true},
+	}))
+	assert.True(t, IsDataStoreLeaderElectionEnabled(config.Config{
+		DynamoDB: config.DynamoDBConfig{Enabled: true, LeaderElection: true},
+	}))
+	// The setting only matters for the database that is being used
+	assert.False(t, IsDataStoreLeaderElectionEnabled(config.Config{
+		Redis:  config.RedisConfig{URL: redisURL},
+		Consul: config.ConsulConfig{Host: "consulhost", LeaderElection: true},
+	}))
+}
+
+func TestGetDataStoreConsistencyCheckSettings(t *testing.T) {
+	redisURL, _ := configtypes.NewOptURLAbsoluteFromString("redis://redishost:3000")
+
+	interval, repair := GetDataStoreConsistencyCheckSettings(config.Config{})
+	assert.Equal(t, time.Duration(0), interval)
+	assert.False(t, repair)
+
+	interval, repair = GetDataStoreConsistencyCheckSettings(config.Config{
+		Redis: config.RedisConfig{URL: redisURL, ConsistencyCheckInterval: configtypes.NewOptDuration(time.Minute),
+			ConsistencyCheckRepair: true},
+	})
+	assert.Equal(t, time.Minute, interval)
+	assert.True(t, repair)
+
+	interval, repair = GetDataStoreConsistencyCheckSettings(config.Config{
+		Consul: config.ConsulConfig{Host: "consulhost", ConsistencyCheckInterval: configtypes.NewOptDuration(time.Minute)},
+	})
+	assert.Equal(t, time.Minute, interval)
+	assert.False(t, repair)
+
+	interval, _ = GetDataStoreConsistencyCheckSettings(config.Config{
+		DynamoDB: config.DynamoDBConfig{Enabled: true, ConsistencyCheckInterval: configtypes.NewOptDuration(time.Hour)},
+	})
+	assert.Equal(t, time.Hour, interval)
+
+	// The settings only matter for the database that is being used
+	interval, _ = GetDataStoreConsistencyCheckSettings(config.Config{
+		Redis:  config.RedisConfig{URL: redisURL},
+		Consul: config.ConsulConfig{Host: "consulhost", ConsistencyCheckInterval: configtypes.NewOptDuration(time.Minute)},
+	})
+	assert.Equal(t, time.Duration(0), interval)
+}
@@ -85,9 +85,15 @@ func (e *TestMetricsExporter) ExportView(viewData *view.Data) {
 		if sumData, ok := vr.Data.(*view.SumData); ok {
 			tr.Sum = sumData.Value
 		}
+		if lastValueData, ok := vr.Data.(*view.LastValueData); ok {
+			tr.Sum = lastValueData.Value
+		}
 		if countData, ok := vr.Data.(*view.CountData); ok {
 			tr.Count = countData.Value
 		}
+		if distributionData, ok := vr.Data.(*view.DistributionData); ok {
+			tr.Count = distributionData.Count // the recorded durations vary, so tests can only check how many there were
+		}
 		rows = append(rows, tr)
 	}
 
@@ -97,7 +103,19 @@ func (e *TestMetricsExporter) ExportView(viewData *view.Data) {
 		for k, v := range e.lastData {
 			dataCopy[k] = v
 		}
-		e.dataCh <- dataCopy
+		// Each value is a complete snapshot, so if the test isn't reading them fast enough we can discard
+		// the oldest one rather than blocking the OpenCensus worker.
+		for {
+			select {
+			case e.dataCh <- dataCopy:
+				return
+			default:
+				select {
+				case <-e.dataCh:
+				default:
+				}
+			}
+		}
 	}
 }
 
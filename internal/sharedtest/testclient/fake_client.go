@@ -34,6 +34,10 @@ type FakeLDClient struct {
 	Key              config.SDKKey
 	CloseCh          chan struct{}
 	dataSourceStatus *interfaces.DataSourceStatus
+	dataStoreStatus  *sdks.DataStoreStatusInfo
+	sourceListeners  []chan interfaces.DataSourceStatus
+	storeListeners   []chan sdks.DataStoreStatusInfo
+	closed           bool
 	initialized      bool
 	lock             sync.Mutex
 }
@@ -44,9 +48,22 @@ type CapturedLDClient struct {
 }
 
 func (c *FakeLDClient) Initialized() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
 	return c.initialized
 }
 
+// SetInitialized changes the value returned by Initialized, and sets the data source status to VALID if
+// it becomes true.
+func (c *FakeLDClient) SetInitialized(initialized bool) {
+	c.lock.Lock()
+	c.initialized = initialized
+	c.lock.Unlock()
+	if initialized {
+		c.SetDataSourceStatus(interfaces.DataSourceStatus{State: interfaces.DataSourceStateValid, StateSince: time.Now()})
+	}
+}
+
 func (c *FakeLDClient) SecureModeHash(context ldcontext.Context) string {
 	return FakeHashForContext(context)
 }
@@ -65,10 +82,49 @@ func (c *FakeLDClient) GetDataSourceStatus() interfaces.DataSourceStatus {
 }
 
 func (c *FakeLDClient) GetDataStoreStatus() sdks.DataStoreStatusInfo {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.dataStoreStatus != nil {
+		return *c.dataStoreStatus
+	}
 	return sdks.DataStoreStatusInfo{Available: true}
 }
 
+func (c *FakeLDClient) AddDataSourceStatusListener() <-chan interfaces.DataSourceStatus {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	ch := make(chan interfaces.DataSourceStatus, 10)
+	if c.closed {
+		close(ch)
+	} else {
+		c.sourceListeners = append(c.sourceListeners, ch)
+	}
+	return ch
+}
+
+func (c *FakeLDClient) AddDataStoreStatusListener() <-chan sdks.DataStoreStatusInfo {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	ch := make(chan sdks.DataStoreStatusInfo, 10)
+	if c.closed {
+		close(ch)
+	} else {
+		c.storeListeners = append(c.storeListeners, ch)
+	}
+	return ch
+}
+
 func (c *FakeLDClient) Close() error {
+	c.lock.Lock()
+	c.closed = true
+	for _, ch := range c.sourceListeners {
+		close(ch)
+	}
+	for _, ch := range c.storeListeners {
+		close(ch)
+	}
+	c.sourceListeners, c.storeListeners = nil, nil
+	c.lock.Unlock()
 	if c.CloseCh != nil {
 		close(c.CloseCh)
 	}
@@ -79,6 +135,18 @@ func (c *FakeLDClient) SetDataSourceStatus(newStatus interfaces.DataSourceStatus
 	c.lock.Lock()
 	defer c.lock.Unlock()
 	c.dataSourceStatus = &newStatus
+	for _, ch := range c.sourceListeners {
+		ch <- newStatus
+	}
+}
+
+func (c *FakeLDClient) SetDataStoreStatus(newStatus sdks.DataStoreStatusInfo) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.dataStoreStatus = &newStatus
+	for _, ch := range c.storeListeners {
+		ch <- newStatus
+	}
 }
 
 func (c *FakeLDClient) AwaitClose(t *testing.T, timeout time.Duration) {
@@ -95,13 +163,19 @@ func FakeLDClientFactory(shouldBeInitialized bool) sdks.ClientFactoryFunc {
 	return FakeLDClientFactoryWithChannel(shouldBeInitialized, nil)
 }
 
+// noOpDataStoreUpdateSink allows persistent data store wrappers to be built, since they report status
+// changes to the SDK.
+type noOpDataStoreUpdateSink struct{}
+
+func (noOpDataStoreUpdateSink) UpdateStatus(interfaces.DataStoreStatus) {}
+
 func FakeLDClientFactoryWithChannel(shouldBeInitialized bool, createdCh chan<- *FakeLDClient) sdks.ClientFactoryFunc {
 	return func(sdkKey config.SDKKey, config ld.Config, timeout time.Duration) (sdks.LDClientContext, error) {
 		// We're not creating a real client, but we still need to invoke the DataStoreFactory as the
 		// SDK would do, since that's how Relay obtains its shared reference to the data store.
 		if config.DataStore != nil {
 			_, err := config.DataStore.Build(
-				subsystems.BasicClientContext{},
+				subsystems.BasicClientContext{DataStoreUpdateSink: noOpDataStoreUpdateSink{}},
 			)
 			if err != nil {
 				return nil, err
@@ -0,0 +1,287 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoretypes"
+)
+
+var errConsistencyCheckNoData = errors.New("no data has been received yet, so the data store cannot be checked")
+
+// ConsistencyCheckResult describes the outcome of PersistentStoreChecker.Check.
+type ConsistencyCheckResult struct {
+	// Time is when the check was done.
+	Time time.Time
+
+	// MissingItems are the items that Relay has received, but that are not in the data store at all.
+	MissingItems []InconsistentItem
+
+	// StaleItems are the items that are in the data store with a lower version than the one Relay received.
+	StaleItems []InconsistentItem
+
+	// Repaired is true if the data store was rewritten because there were missing or stale items.
+	Repaired bool
+}
+
+// InconsistentItem describes a flag or segment that is missing or out of date in the data store.
+type InconsistentItem struct {
+	// Kind is the kind of data: "features" or "segments".
+	Kind string
+
+	// Key is the flag or segment key.
+	Key string
+
+	// ExpectedVersion is the version that Relay received.
+	ExpectedVersion int
+
+	// ActualVersion is the version in the data store, or zero if the item is missing.
+	ActualVersion int
+}
+
+// IsConsistent returns true if there were no missing or stale items.
+func (r ConsistencyCheckResult) IsConsistent() bool {
+	return len(r.MissingItems) == 0 && len(r.StaleItems) == 0
+}
+
+// PersistentStoreChecker detects whether the contents of a persistent data store have drifted from the
+// data that Relay received from LaunchDarkly- for instance, because the database was modified or restored
+// by some other process. This matters when SDKs in daemon mode are reading directly from the database.
+//
+// Its Wrap method wraps the factory for a persistent data store, underneath the SDK's caching layer. It
+// passes all operations through to the wrapped store, but keeps its own copy of the latest data that the
+// SDK has provided, so that Check can compare that with what is actually in the database.
+//
+// As with PersistentStoreWriteGate, a PersistentStoreChecker should only be used for a single client at a
+// time, except while the client is being replaced; see BeginReplacement.
+type PersistentStoreChecker struct {
+	store      *checkedPersistentDataStore
+	pending    *checkedPersistentDataStore
+	replacing  bool
+	lastResult *ConsistencyCheckResult
+	mu         sync.Mutex
+}
+
+type checkedPersistentDataStoreFactory struct {
+	checker        *PersistentStoreChecker
+	wrappedFactory subsystems.ComponentConfigurer[subsystems.PersistentDataStore]
+}
+
+// NewPersistentStoreChecker creates a new instance.
+func NewPersistentStoreChecker() *PersistentStoreChecker {
+	return &PersistentStoreChecker{}
+}
+
+// Wrap returns a persistent data store factory that delegates to the specified factory, and records the
+// data that is written to the store so that it can be checked later.
+func (c *PersistentStoreChecker) Wrap(
+	wrappedFactory subsystems.ComponentConfigurer[subsystems.PersistentDataStore],
+) subsystems.ComponentConfigurer[subsystems.PersistentDataStore] {
+	return checkedPersistentDataStoreFactory{checker: c, wrappedFactory: wrappedFactory}
+}
+
+// Build is called by the SDK when the LDClient is being created.
+func (f checkedPersistentDataStoreFactory) Build(
+	context subsystems.ClientContext,
+) (subsystems.PersistentDataStore, error) {
+	wrappedStore, err := f.wrappedFactory.Build(context)
+	if err != nil {
+		return nil, err
+	}
+	c := f.checker
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	store := &checkedPersistentDataStore{store: wrappedStore}
+	if c.replacing {
+		c.pending = store
+	} else {
+		c.store = store
+	}
+	return store, nil
+}
+
+// BeginReplacement is called before creating an SDK client that will replace the current one. Until
+// FinishReplacement is called, Check goes on using the current store.
+func (c *PersistentStoreChecker) BeginReplacement() {
+	c.mu.Lock()
+	c.replacing = true
+	c.pending = nil
+	c.mu.Unlock()
+}
+
+// FinishReplacement ends the state that was started by BeginReplacement. If keep is true, the store that
+// was created for the new client becomes the one that is checked; otherwise it is discarded.
+func (c *PersistentStoreChecker) FinishReplacement(keep bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if keep && c.pending != nil {
+		c.store = c.pending
+	}
+	c.replacing, c.pending = false, nil
+}
+
+// Check compares the items in the data store with the latest data that Relay has received. Items that
+// are missing from the data store, or that have a lower version than Relay received, are reported as
+// inconsistent; items with a higher version are not, since another Relay instance may have received a
+// newer update first.
+//
+// If repair is true and there are any inconsistencies, it rewrites all of the data that Relay has
+// received to the data store.
+//
+// It returns an error if the data store has not received any data yet, or if the data store could not
+// be queried.
+func (c *PersistentStoreChecker) Check(repair bool) (ConsistencyCheckResult, error) {
+	c.mu.Lock()
+	store := c.store
+	c.mu.Unlock()
+	if store == nil {
+		return ConsistencyCheckResult{}, errConsistencyCheckNoData
+	}
+	expected, ok := store.getExpectedData()
+	if !ok {
+		return ConsistencyCheckResult{}, errConsistencyCheckNoData
+	}
+
+	result := ConsistencyCheckResult{Time: time.Now()}
+	for _, coll := range expected {
+		actualItems, err := store.store.GetAll(coll.Kind)
+		if err != nil {
+			return ConsistencyCheckResult{}, err
+		}
+		actualVersions := make(map[string]int, len(actualItems))
+		for _, item := range actualItems {
+			version, err := getSerializedItemVersion(coll.Kind, item)
+			if err != nil {
+				return ConsistencyCheckResult{}, err
+			}
+			actualVersions[item.Key] = version
+		}
+		for _, item := range coll.Items {
+			inconsistent := InconsistentItem{Kind: coll.Kind.GetName(), Key: item.Key, ExpectedVersion: item.Item.Version}
+			actualVersion, found := actualVersions[item.Key]
+			switch {
+			case !found:
+				result.MissingItems = append(result.MissingItems, inconsistent)
+			case actualVersion < item.Item.Version:
+				inconsistent.ActualVersion = actualVersion
+				result.StaleItems = append(result.StaleItems, inconsistent)
+			}
+		}
+	}
+
+	if repair && !result.IsConsistent() {
+		if err := store.rewrite(); err != nil {
+			return ConsistencyCheckResult{}, err
+		}
+		result.Repaired = true
+	}
+
+	c.mu.Lock()
+	c.lastResult = &result
+	c.mu.Unlock()
+	return result, nil
+}
+
+// HasData returns true if the data store has received a full data set, so that Check can compare it with
+// the data store.
+func (c *PersistentStoreChecker) HasData() bool {
+	c.mu.Lock()
+	store := c.store
+	c.mu.Unlock()
+	if store == nil {
+		return false
+	}
+	_, ok := store.getExpectedData()
+	return ok
+}
+
+// GetLastResult returns the result of the last successful call to Check, or false if there has not been
+// one.
+func (c *PersistentStoreChecker) GetLastResult() (ConsistencyCheckResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.lastResult == nil {
+		return ConsistencyCheckResult{}, false
+	}
+	return *c.lastResult, true
+}
+
+// getSerializedItemVersion returns the version of an item that was read from a persistent data store. Some
+// data store implementations do not store the version separately from the serialized item, so in that
+// case we have to parse the item.
+func getSerializedItemVersion(kind ldstoretypes.DataKind, item ldstoretypes.KeyedSerializedItemDescriptor) (int, error) {
+	if item.Item.Version != 0 || item.Item.SerializedItem == nil {
+		return item.Item.Version, nil
+	}
+	parsed, err := kind.Deserialize(item.Item.SerializedItem)
+	if err != nil {
+		return 0, fmt.Errorf("unable to parse %s item %q: %w", kind.GetName(), item.Key, err)
+	}
+	return parsed.Version, nil
+}
+
+type checkedPersistentDataStore struct {
+	store    subsystems.PersistentDataStore
+	expected serializedDataSet
+	inited   bool
+	mu       sync.Mutex
+}
+
+func (s *checkedPersistentDataStore) Init(allData []ldstoretypes.SerializedCollection) error {
+	// We hold the lock while writing to the wrapped store, so that a repair cannot overwrite newer data
+	// with older data.
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expected.init(allData)
+	s.inited = true
+	return s.store.Init(allData)
+}
+
+func (s *checkedPersistentDataStore) Upsert(
+	kind ldstoretypes.DataKind,
+	key string,
+	item ldstoretypes.SerializedItemDescriptor,
+) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expected.upsert(kind, key, item)
+	return s.store.Upsert(kind, key, item)
+}
+
+func (s *checkedPersistentDataStore) Get(kind ldstoretypes.DataKind, key string) (ldstoretypes.SerializedItemDescriptor, error) {
+	return s.store.Get(kind, key)
+}
+
+func (s *checkedPersistentDataStore) GetAll(kind ldstoretypes.DataKind) ([]ldstoretypes.KeyedSerializedItemDescriptor, error) {
+	return s.store.GetAll(kind)
+}
+
+func (s *checkedPersistentDataStore) IsInitialized() bool {
+	return s.store.IsInitialized()
+}
+
+func (s *checkedPersistentDataStore) IsStoreAvailable() bool {
+	return s.store.IsStoreAvailable()
+}
+
+func (s *checkedPersistentDataStore) Close() error {
+	return s.store.Close()
+}
+
+func (s *checkedPersistentDataStore) getExpectedData() ([]ldstoretypes.SerializedCollection, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.inited {
+		return nil, false
+	}
+	return s.expected.snapshot(), true
+}
+
+func (s *checkedPersistentDataStore) rewrite() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.store.Init(s.expected.data)
+}
@@ -0,0 +1,147 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/launchdarkly/go-server-sdk-evaluation/v3/ldbuilders"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoreimpl"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoretypes"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func makeCheckedStore(t *testing.T) (*PersistentStoreChecker, subsystems.PersistentDataStore, *mockPersistentStore) {
+	mockStore := &mockPersistentStore{}
+	checker := NewPersistentStoreChecker()
+	store, err := checker.Wrap(mockPersistentStoreFactory{instance: mockStore}).Build(subsystems.BasicClientContext{})
+	require.NoError(t, err)
+	return checker, store, mockStore
+}
+
+func TestPersistentStoreCheckerPassesThroughWrites(t *testing.T) {
+	_, store, mockStore := makeCheckedStore(t)
+
+	require.NoError(t, store.Init(makeSerializedData()))
+	updated, err := store.Upsert(ldstoreimpl.Features(), "flag1", makeSerializedItem(2))
+	require.NoError(t, err)
+	assert.True(t, updated)
+	assert.Equal(t, map[ldstoretypes.DataKind]map[string]int{
+		ldstoreimpl.Segments(): {"segment1": 1},
+		ldstoreimpl.Features(): {"flag1": 2, "flag2": 2},
+	}, mockStore.getData())
+}
+
+func TestPersistentStoreCheckerReportsNoInconsistencies(t *testing.T) {
+	checker, store, _ := makeCheckedStore(t)
+	require.NoError(t, store.Init(makeSerializedData()))
+	_, err := store.Upsert(ldstoreimpl.Features(), "flag3", makeSerializedItem(1))
+	require.NoError(t, err)
+
+	result, err := checker.Check(false)
+	require.NoError(t, err)
+	assert.True(t, result.IsConsistent())
+	assert.False(t, result.Repaired)
+	assert.False(t, result.Time.IsZero())
+
+	lastResult, ok := checker.GetLastResult()
+	assert.True(t, ok)
+	assert.Equal(t, result, lastResult)
+}
+
+func TestPersistentStoreCheckerReportsMissingAndStaleItems(t *testing.T) {
+	checker, store, mockStore := makeCheckedStore(t)
+	require.NoError(t, store.Init(makeSerializedData()))
+	mockStore.setVersion(ldstoreimpl.Features(), "flag1", 0)
+	mockStore.setVersion(ldstoreimpl.Features(), "flag2", 1)
+	mockStore.setVersion(ldstoreimpl.Segments(), "segment1", 3) // a higher version is not an inconsistency
+
+	result, err := checker.Check(false)
+	require.NoError(t, err)
+	assert.Equal(t, []InconsistentItem{
+		{Kind: "features", Key: "flag1", ExpectedVersion: 1},
+	}, result.MissingItems)
+	assert.Equal(t, []InconsistentItem{
+		{Kind: "features", Key: "flag2", ExpectedVersion: 2, ActualVersion: 1},
+	}, result.StaleItems)
+	assert.False(t, result.Repaired)
+	assert.Equal(t, 1, mockStore.inits)
+}
+
+func TestPersistentStoreCheckerRepairsStore(t *testing.T) {
+	checker, store, mockStore := makeCheckedStore(t)
+	require.NoError(t, store.Init(makeSerializedData()))
+	_, err := store.Upsert(ldstoreimpl.Features(), "flag1", makeSerializedItem(5))
+	require.NoError(t, err)
+	mockStore.setVersion(ldstoreimpl.Features(), "flag1", 0)
+
+	result, err := checker.Check(true)
+	require.NoError(t, err)
+	assert.Len(t, result.MissingItems, 1)
+	assert.True(t, result.Repaired)
+	assert.Equal(t, 2, mockStore.inits)
+	assert.Equal(t, map[ldstoretypes.DataKind]map[string]int{
+		ldstoreimpl.Segments(): {"segment1": 1},
+		ldstoreimpl.Features(): {"flag1": 5, "flag2": 2},
+	}, mockStore.getData())
+
+	result, err = checker.Check(true)
+	require.NoError(t, err)
+	assert.True(t, result.IsConsistent())
+	assert.False(t, result.Repaired)
+	assert.Equal(t, 2, mockStore.inits, "should not rewrite data that is already consistent")
+}
+
+func TestPersistentStoreCheckerReturnsErrorBeforeReceivingData(t *testing.T) {
+	checker := NewPersistentStoreChecker()
+	assert.False(t, checker.HasData())
+	_, err := checker.Check(false)
+	assert.Equal(t, errConsistencyCheckNoData, err)
+
+	checker, _, _ = makeCheckedStore(t)
+	assert.False(t, checker.HasData())
+	_, err = checker.Check(false)
+	assert.Equal(t, errConsistencyCheckNoData, err)
+
+	_, ok := checker.GetLastResult()
+	assert.False(t, ok)
+}
+
+func TestGetSerializedItemVersionParsesItemIfVersionIsNotStoredSeparately(t *testing.T) {
+	flag := ldbuilders.NewFlagBuilder("flag1").Version(7).Build()
+	item := ldstoretypes.KeyedSerializedItemDescriptor{Key: "flag1", Item: ldstoretypes.SerializedItemDescriptor{
+		SerializedItem: ldstoreimpl.Features().Serialize(ldstoretypes.ItemDescriptor{Version: 7, Item: &flag}),
+	}}
+	version, err := getSerializedItemVersion(ldstoreimpl.Features(), item)
+	require.NoError(t, err)
+	assert.Equal(t, 7, version)
+
+	item.Item.SerializedItem = []byte("{no")
+	_, err = getSerializedItemVersion(ldstoreimpl.Features(), item)
+	assert.Error(t, err)
+}
+
+func TestPersistentStoreCheckerReplacement(t *testing.T) {
+	for _, keep := range []bool{true, false} {
+		checker, oldStore, oldMockStore := makeCheckedStore(t)
+		require.NoError(t, oldStore.Init(makeSerializedData()))
+
+		checker.BeginReplacement()
+		newMockStore := &mockPersistentStore{}
+		newStore, err := checker.Wrap(mockPersistentStoreFactory{instance: newMockStore}).Build(subsystems.BasicClientContext{})
+		require.NoError(t, err)
+		require.NoError(t, newStore.Init(makeSerializedData()))
+		newMockStore.setVersion(ldstoreimpl.Features(), "flag1", 0)
+
+		result, err := checker.Check(false)
+		require.NoError(t, err)
+		assert.True(t, result.IsConsistent(), "should check the current store until the replacement is kept")
+
+		checker.FinishReplacement(keep)
+		result, err = checker.Check(false)
+		require.NoError(t, err)
+		assert.Equal(t, !keep, result.IsConsistent())
+		assert.Equal(t, 1, oldMockStore.inits)
+	}
+}
@@ -0,0 +1,191 @@
+package store
+
+import (
+	"sync"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoretypes"
+)
+
+// PersistentStoreWriteGate is used when several Relay instances share a database, to make sure that only
+// one of them - the one that has been elected as the writer for an environment - updates the database.
+//
+// Its Wrap method wraps the factory for a persistent data store, underneath the SDK's caching layer. When this instance
+// is not the writer, updates go only to the SDK's cache and are not written to the database, and queries
+// that are not answered by the cache go to the database as usual. Either way, the store keeps its own copy
+// of the latest data, so that if this instance becomes the writer it can bring the database up to date.
+//
+// As with SSERelayDataStoreAdapter, a PersistentStoreWriteGate should only be used for a single client at
+// a time, except while the client is being replaced; see BeginReplacement.
+type PersistentStoreWriteGate struct {
+	store     *gatedPersistentDataStore
+	pending   *gatedPersistentDataStore
+	replacing bool
+	writer    bool
+	mu        sync.Mutex
+}
+
+type gatedPersistentDataStoreFactory struct {
+	gate           *PersistentStoreWriteGate
+	wrappedFactory subsystems.ComponentConfigurer[subsystems.PersistentDataStore]
+}
+
+// NewPersistentStoreWriteGate creates a new instance, in which this Relay instance is not yet the writer.
+func NewPersistentStoreWriteGate() *PersistentStoreWriteGate {
+	return &PersistentStoreWriteGate{}
+}
+
+// Wrap returns a persistent data store factory that delegates to the specified factory, but only writes
+// to the store while this Relay instance is the writer.
+func (g *PersistentStoreWriteGate) Wrap(
+	wrappedFactory subsystems.ComponentConfigurer[subsystems.PersistentDataStore],
+) subsystems.ComponentConfigurer[subsystems.PersistentDataStore] {
+	return gatedPersistentDataStoreFactory{gate: g, wrappedFactory: wrappedFactory}
+}
+
+// Build is called by the SDK when the LDClient is being created.
+func (f gatedPersistentDataStoreFactory) Build(
+	context subsystems.ClientContext,
+) (subsystems.PersistentDataStore, error) {
+	wrappedStore, err := f.wrappedFactory.Build(context)
+	if err != nil {
+		return nil, err
+	}
+	g := f.gate
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	store := &gatedPersistentDataStore{
+		store:   wrappedStore,
+		loggers: context.GetLogging().Loggers,
+	}
+	if g.replacing {
+		g.pending = store
+	} else {
+		store.writer = g.writer
+		g.store = store
+	}
+	return store, nil
+}
+
+// BeginReplacement is called before creating an SDK client that will replace the current one. Until
+// FinishReplacement is called, the store that is created for the new client never writes to the database,
+// and the current store goes on receiving changes to the writer state.
+func (g *PersistentStoreWriteGate) BeginReplacement() {
+	g.mu.Lock()
+	g.replacing = true
+	g.pending = nil
+	g.mu.Unlock()
+}
+
+// FinishReplacement ends the state that was started by BeginReplacement. If keep is true, the store that
+// was created for the new client becomes the current store, and if this Relay instance is the writer, it
+// writes all of its data to the database; the previous store stops writing. Otherwise the new store is
+// discarded, and the current store is unchanged.
+func (g *PersistentStoreWriteGate) FinishReplacement(keep bool) {
+	g.mu.Lock()
+	previous, pending, writer := g.store, g.pending, g.writer
+	g.replacing, g.pending = false, nil
+	if keep && pending != nil {
+		g.store = pending
+	}
+	g.mu.Unlock()
+	if keep && pending != nil {
+		if previous != nil {
+			previous.setWriter(false)
+		}
+		pending.setWriter(writer)
+	}
+}
+
+// IsWriter returns true if this Relay instance is currently the writer.
+func (g *PersistentStoreWriteGate) IsWriter() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.writer
+}
+
+// SetWriter specifies whether this Relay instance is the writer. If it has just become the writer, and
+// the store has already received data, it immediately writes all of that data to the database.
+func (g *PersistentStoreWriteGate) SetWriter(writer bool) {
+	g.mu.Lock()
+	g.writer = writer
+	store := g.store
+	g.mu.Unlock()
+	if store != nil {
+		store.setWriter(writer)
+	}
+}
+
+type gatedPersistentDataStore struct {
+	store   subsystems.PersistentDataStore
+	writer  bool
+	data    serializedDataSet
+	inited  bool
+	loggers ldlog.Loggers
+	mu      sync.Mutex
+}
+
+func (s *gatedPersistentDataStore) Init(allData []ldstoretypes.SerializedCollection) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.init(allData)
+	s.inited = true
+	if !s.writer {
+		return nil
+	}
+	return s.store.Init(allData)
+}
+
+func (s *gatedPersistentDataStore) Upsert(
+	kind ldstoretypes.DataKind,
+	key string,
+	item ldstoretypes.SerializedItemDescriptor,
+) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	newer := s.data.upsert(kind, key, item)
+	if !s.writer {
+		// Telling the SDK that the update succeeded makes it update its cache, just as it would if the
+		// writer had written the same data to the database.
+		return newer, nil
+	}
+	return s.store.Upsert(kind, key, item)
+}
+
+func (s *gatedPersistentDataStore) Get(kind ldstoretypes.DataKind, key string) (ldstoretypes.SerializedItemDescriptor, error) {
+	return s.store.Get(kind, key)
+}
+
+func (s *gatedPersistentDataStore) GetAll(kind ldstoretypes.DataKind) ([]ldstoretypes.KeyedSerializedItemDescriptor, error) {
+	return s.store.GetAll(kind)
+}
+
+func (s *gatedPersistentDataStore) IsInitialized() bool {
+	return s.store.IsInitialized()
+}
+
+func (s *gatedPersistentDataStore) IsStoreAvailable() bool {
+	return s.store.IsStoreAvailable()
+}
+
+func (s *gatedPersistentDataStore) Close() error {
+	return s.store.Close()
+}
+
+func (s *gatedPersistentDataStore) setWriter(writer bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if writer == s.writer {
+		return
+	}
+	s.writer = writer
+	if !writer || !s.inited {
+		return
+	}
+	// The previous writer may have stopped before writing everything that we have received, so we
+	// rewrite all of the data.
+	if err := s.store.Init(s.data.data); err != nil {
+		s.loggers.Errorf("Unable to write data to the persistent store after becoming its writer: %s", err)
+	}
+}
@@ -0,0 +1,240 @@
+package store
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoreimpl"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoretypes"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockPersistentStore records the data that was written to it, in a simplified form.
+type mockPersistentStore struct {
+	inits   int
+	data    map[ldstoretypes.DataKind]map[string]int // item versions
+	upserts []string
+	lock    sync.Mutex
+}
+
+type mockPersistentStoreFactory struct {
+	instance *mockPersistentStore
+}
+
+func (f mockPersistentStoreFactory) Build(subsystems.ClientContext) (subsystems.PersistentDataStore, error) {
+	return f.instance, nil
+}
+
+func (m *mockPersistentStore) Init(allData []ldstoretypes.SerializedCollection) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.inits++
+	m.data = make(map[ldstoretypes.DataKind]map[string]int)
+	for _, coll := range allData {
+		m.data[coll.Kind] = make(map[string]int)
+		for _, item := range coll.Items {
+			m.data[coll.Kind][item.Key] = item.Item.Version
+		}
+	}
+	return nil
+}
+
+func (m *mockPersistentStore) Upsert(
+	kind ldstoretypes.DataKind,
+	key string,
+	item ldstoretypes.SerializedItemDescriptor,
+) (bool, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.upserts = append(m.upserts, key)
+	if m.data == nil {
+		m.data = make(map[ldstoretypes.DataKind]map[string]int)
+	}
+	if m.data[kind] == nil {
+		m.data[kind] = make(map[string]int)
+	}
+	m.data[kind][key] = item.Version
+	return true, nil
+}
+
+func (m *mockPersistentStore) Get(ldstoretypes.DataKind, string) (ldstoretypes.SerializedItemDescriptor, error) {
+	return ldstoretypes.SerializedItemDescriptor{}.NotFound(), nil
+}
+
+func (m *mockPersistentStore) GetAll(kind ldstoretypes.DataKind) ([]ldstoretypes.KeyedSerializedItemDescriptor, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	var ret []ldstoretypes.KeyedSerializedItemDescriptor
+	for key, version := range m.data[kind] {
+		ret = append(ret, ldstoretypes.KeyedSerializedItemDescriptor{Key: key, Item: makeSerializedItem(version)})
+	}
+	return ret, nil
+}
+
+func (m *mockPersistentStore) IsInitialized() bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.inits > 0
+}
+
+func (m *mockPersistentStore) IsStoreAvailable() bool { return true }
+
+func (m *mockPersistentStore) Close() error { return nil }
+
+func (m *mockPersistentStore) getData() map[ldstoretypes.DataKind]map[string]int {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.data
+}
+
+func (m *mockPersistentStore) setVersion(kind ldstoretypes.DataKind, key string, version int) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if version == 0 {
+		delete(m.data[kind], key)
+	} else {
+		m.data[kind][key] = version
+	}
+}
+
+func makeSerializedItem(version int) ldstoretypes.SerializedItemDescriptor {
+	return ldstoretypes.SerializedItemDescriptor{Version: version, SerializedItem: []byte("{}")}
+}
+
+func makeSerializedData() []ldstoretypes.SerializedCollection {
+	return []ldstoretypes.SerializedCollection{
+		{Kind: ldstoreimpl.Segments(), Items: []ldstoretypes.KeyedSerializedItemDescriptor{
+			{Key: "segment1", Item: makeSerializedItem(1)},
+		}},
+		{Kind: ldstoreimpl.Features(), Items: []ldstoretypes.KeyedSerializedItemDescriptor{
+			{Key: "flag1", Item: makeSerializedItem(1)},
+			{Key: "flag2", Item: makeSerializedItem(2)},
+		}},
+	}
+}
+
+func makeGatedStore(t *testing.T) (*PersistentStoreWriteGate, subsystems.PersistentDataStore, *mockPersistentStore) {
+	mockStore := &mockPersistentStore{}
+	gate := NewPersistentStoreWriteGate()
+	store, err := gate.Wrap(mockPersistentStoreFactory{instance: mockStore}).Build(subsystems.BasicClientContext{})
+	require.NoError(t, err)
+	return gate, store, mockStore
+}
+
+func TestPersistentStoreWriteGateWritesOnlyWhenWriter(t *testing.T) {
+	gate, store, mockStore := makeGatedStore(t)
+	assert.False(t, gate.IsWriter())
+
+	require.NoError(t, store.Init(makeSerializedData()))
+	updated, err := store.Upsert(ldstoreimpl.Features(), "flag1", makeSerializedItem(2))
+	require.NoError(t, err)
+	assert.True(t, updated, "follower should report updates as successful so the SDK updates its cache")
+	assert.Nil(t, mockStore.getData())
+
+	gate.SetWriter(true)
+	assert.True(t, gate.IsWriter())
+	updated, err = store.Upsert(ldstoreimpl.Features(), "flag2", makeSerializedItem(3))
+	require.NoError(t, err)
+	assert.True(t, updated)
+	assert.Equal(t, []string{"flag2"}, mockStore.upserts)
+}
+
+func TestPersistentStoreWriteGateWritesAllDataWhenBecomingWriter(t *testing.T) {
+	gate, store, mockStore := makeGatedStore(t)
+
+	require.NoError(t, store.Init(makeSerializedData()))
+	_, err := store.Upsert(ldstoreimpl.Features(), "flag1", makeSerializedItem(5))
+	require.NoError(t, err)
+	_, err = store.Upsert(ldstoreimpl.Features(), "flag3", makeSerializedItem(1))
+	require.NoError(t, err)
+	updated, err := store.Upsert(ldstoreimpl.Features(), "flag2", makeSerializedItem(1))
+	require.NoError(t, err)
+	assert.False(t, updated, "an older version should not replace a newer one")
+
+	gate.SetWriter(true)
+	assert.Equal(t, 1, mockStore.inits)
+	assert.Equal(t, map[ldstoretypes.DataKind]map[string]int{
+		ldstoreimpl.Segments(): {"segment1": 1},
+		ldstoreimpl.Features(): {"flag1": 5, "flag2": 2, "flag3": 1},
+	}, mockStore.getData())
+
+	gate.SetWriter(true)
+	assert.Equal(t, 1, mockStore.inits, "should not rewrite data if already the writer")
+}
+
+func TestPersistentStoreWriteGateDoesNotWriteWhenBecomingWriterBeforeReceivingData(t *testing.T) {
+	gate, _, mockStore := makeGatedStore(t)
+	gate.SetWriter(true)
+	assert.Equal(t, 0, mockStore.inits)
+}
+
+func TestPersistentStoreWriteGateStopsWritingWhenNoLongerWriter(t *testing.T) {
+	gate, store, mockStore := makeGatedStore(t)
+	gate.SetWriter(true)
+	require.NoError(t, store.Init(makeSerializedData()))
+	assert.Equal(t, 1, mockStore.inits)
+
+	gate.SetWriter(false)
+	require.NoError(t, store.Init(makeSerializedData()))
+	_, err := store.Upsert(ldstoreimpl.Features(), "flag1", makeSerializedItem(2))
+	require.NoError(t, err)
+	assert.Equal(t, 1, mockStore.inits)
+	assert.Len(t, mockStore.upserts, 0)
+}
+
+func TestPersistentStoreWriteGateAppliesWriterStateSetBeforeStoreIsCreated(t *testing.T) {
+	mockStore := &mockPersistentStore{}
+	gate := NewPersistentStoreWriteGate()
+	gate.SetWriter(true)
+	store, err := gate.Wrap(mockPersistentStoreFactory{instance: mockStore}).Build(subsystems.BasicClientContext{})
+	require.NoError(t, err)
+
+	require.NoError(t, store.Init(makeSerializedData()))
+	assert.Equal(t, 1, mockStore.inits)
+}
+
+func TestPersistentStoreWriteGateReplacement(t *testing.T) {
+	gate, oldStore, oldMockStore := makeGatedStore(t)
+	gate.SetWriter(true)
+	require.NoError(t, oldStore.Init(makeSerializedData()))
+
+	gate.BeginReplacement()
+	newMockStore := &mockPersistentStore{}
+	newStore, err := gate.Wrap(mockPersistentStoreFactory{instance: newMockStore}).Build(subsystems.BasicClientContext{})
+	require.NoError(t, err)
+	require.NoError(t, newStore.Init(makeSerializedData()))
+	assert.Equal(t, 0, newMockStore.inits, "replacement store should not write before it is kept")
+
+	// The current store still receives changes to the writer state
+	gate.SetWriter(false)
+	_, err = oldStore.Upsert(ldstoreimpl.Features(), "flag1", makeSerializedItem(2))
+	require.NoError(t, err)
+	assert.Len(t, oldMockStore.upserts, 0)
+	gate.SetWriter(true)
+	assert.Equal(t, 2, oldMockStore.inits)
+
+	gate.FinishReplacement(true)
+	assert.Equal(t, 1, newMockStore.inits, "kept store should write all of its data if this is the writer")
+	_, err = oldStore.Upsert(ldstoreimpl.Features(), "flag1", makeSerializedItem(3))
+	require.NoError(t, err)
+	assert.Len(t, oldMockStore.upserts, 0, "previous store should stop writing")
+}
+
+func TestPersistentStoreWriteGateDiscardedReplacement(t *testing.T) {
+	gate, oldStore, oldMockStore := makeGatedStore(t)
+	require.NoError(t, oldStore.Init(makeSerializedData()))
+
+	gate.BeginReplacement()
+	newMockStore := &mockPersistentStore{}
+	newStore, err := gate.Wrap(mockPersistentStoreFactory{instance: newMockStore}).Build(subsystems.BasicClientContext{})
+	require.NoError(t, err)
+	require.NoError(t, newStore.Init(makeSerializedData()))
+	gate.FinishReplacement(false)
+
+	gate.SetWriter(true)
+	assert.Equal(t, 1, oldMockStore.inits)
+	assert.Equal(t, 0, newMockStore.inits)
+}
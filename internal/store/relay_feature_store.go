@@ -1,12 +1,19 @@
 package store
 
 import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/launchdarkly/ld-relay/v8/internal/streams"
 
 	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
 	"github.com/launchdarkly/go-server-sdk/v7/subsystems"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoreimpl"
 	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoretypes"
 )
 
@@ -46,6 +53,32 @@ func (a *SSERelayDataStoreAdapter) GetStore() subsystems.DataStore {
 	return store
 }
 
+// GetDataHash returns an opaque string that is derived from the kind, key, and version of every flag and
+// segment in the store, or an empty string if the store has not been created or has not received any data.
+// Since it depends only on the data, any Relay instance that has the same data returns the same value, so
+// clients can use it to tell whether data they have cached is still current even if their requests go to
+// a different instance.
+//
+// The store only knows about updates that pass through this Relay instance; when Relay is only reading
+// from a database that some other process updates (daemon mode), this always returns an empty string.
+func (a *SSERelayDataStoreAdapter) GetDataHash() string {
+	if sw, ok := a.GetStore().(*streamUpdatesStoreWrapper); ok {
+		return sw.getDataHash()
+	}
+	return ""
+}
+
+// GetChangesSince returns the flags and segments that have been added, updated, or deleted since the
+// store had the specified data version (as returned by a previous call to GetChangesSince), along with the
+// current data version. If the store no longer has enough history to answer that, or the version is not one that
+// this store produced, it returns false and the caller should fall back to providing all of the data.
+func (a *SSERelayDataStoreAdapter) GetChangesSince(version string) ([]DataChange, string, bool) {
+	if sw, ok := a.GetStore().(*streamUpdatesStoreWrapper); ok {
+		return sw.getChangesSince(version)
+	}
+	return nil, "", false
+}
+
 // GetUpdates returns the EnvStreamUpdates that will receive all updates sent to this store. This is
 // exposed for testing so that we can simulate receiving updates from LaunchDarkly to this component.
 func (a *SSERelayDataStoreAdapter) GetUpdates() streams.EnvStreamUpdates {
@@ -66,6 +99,28 @@ func NewSSERelayDataStoreAdapter(
 	}
 }
 
+// NewReplacement creates an instance with the same configuration, for building a data store that will
+// replace this one's store. The new store is not visible through this instance, and its updates are not
+// broadcast to stream subscribers, until ReplaceStore is called.
+func (a *SSERelayDataStoreAdapter) NewReplacement() *SSERelayDataStoreAdapter {
+	return NewSSERelayDataStoreAdapter(a.wrappedFactory, discardedUpdates{})
+}
+
+// ReplaceStore makes the data store that was created by another instance, with NewReplacement, the current
+// data store for this one. From then on, its updates are broadcast to this instance's stream subscribers,
+// starting with all of its current data. The caller is responsible for closing the previous store, which is
+// normally done by closing the SDK client that created it.
+func (a *SSERelayDataStoreAdapter) ReplaceStore(other *SSERelayDataStoreAdapter) {
+	store := other.GetStore()
+	a.mu.Lock()
+	a.store = store
+	updates := a.updates
+	a.mu.Unlock()
+	if sw, ok := store.(*streamUpdatesStoreWrapper); ok {
+		sw.attachUpdates(updates)
+	}
+}
+
 // Build is called by the SDK when the LDClient is being created.
 func (a *SSERelayDataStoreAdapter) Build(
 	context subsystems.ClientContext,
@@ -87,12 +142,51 @@ func (a *SSERelayDataStoreAdapter) Build(
 	return sw, nil
 }
 
+// DataChange identifies a flag or segment that has changed. The change could be an addition, an update,
+// or a deletion; the caller should get the item's current state from the store.
+type DataChange struct {
+	Kind ldstoretypes.DataKind
+	Key  string
+}
+
+// maxChangeHistory is the number of changes that the store remembers for GetChangesSince.
+const maxChangeHistory = 1000
+
+type dataItemID struct {
+	kind string
+	key  string
+}
+
+type dataItemVersion struct {
+	version int
+	deleted bool
+}
+
+type dataChangeRecord struct {
+	dataVersion uint64
+	change      DataChange
+}
+
 // A DataStore implementation that delegates to an underlying store but also publish
 // but also publishes stream updates when the store is modified.
 type streamUpdatesStoreWrapper struct {
-	store   subsystems.DataStore
-	updates streams.EnvStreamUpdates
-	loggers ldlog.Loggers
+	store       subsystems.DataStore
+	updates     streams.EnvStreamUpdates
+	updatesLock sync.RWMutex
+	loggers     ldlog.Loggers
+	epoch       string
+	dataVersion atomic.Uint64
+	history     []dataChangeRecord // ring buffer; historyNext is the position of the oldest record once it is full
+	historyNext int
+	// historyStart is the earliest data version that history covers all changes since.
+	historyStart uint64
+	historyLock  sync.Mutex
+	// dataHash combines the hashes of the kind, key, and version of every item that is not deleted; since
+	// it is order-independent, it can be updated for each change without rereading the data.
+	dataHash     uint64
+	hasData      bool
+	itemVersions map[dataItemID]dataItemVersion
+	hashLock     sync.RWMutex
 }
 
 func newStreamUpdatesStoreWrapper(
@@ -104,10 +198,51 @@ func newStreamUpdatesStoreWrapper(
 		store:   baseFeatureStore,
 		updates: updates,
 		loggers: loggers,
+		epoch:   strconv.FormatInt(time.Now().UnixNano(), 36),
+
+		itemVersions: make(map[dataItemID]dataItemVersion),
 	}
 	return relayStore
 }
 
+// discardedUpdates is used by a replacement store until it is attached to the current adapter.
+type discardedUpdates struct{}
+
+func (discardedUpdates) SendAllDataUpdate([]ldstoretypes.Collection) {}
+
+func (discardedUpdates) SendSingleItemUpdate(ldstoretypes.DataKind, string, ldstoretypes.ItemDescriptor) {
+}
+
+func (discardedUpdates) InvalidateClientSideState() {}
+
+func (sw *streamUpdatesStoreWrapper) getUpdates() streams.EnvStreamUpdates {
+	sw.updatesLock.RLock()
+	defer sw.updatesLock.RUnlock()
+	return sw.updates
+}
+
+// attachUpdates starts broadcasting this store's updates to the specified EnvStreamUpdates, and sends all
+// of the store's current data to it. We hold the lock while reading the data, so that any update that
+// is not included in it is sent afterward.
+func (sw *streamUpdatesStoreWrapper) attachUpdates(updates streams.EnvStreamUpdates) {
+	sw.updatesLock.Lock()
+	defer sw.updatesLock.Unlock()
+	sw.updates = updates
+	if !sw.store.IsInitialized() {
+		return
+	}
+	var allData []ldstoretypes.Collection
+	for _, kind := range ldstoreimpl.AllKinds() {
+		items, err := sw.store.GetAll(kind)
+		if err != nil {
+			sw.loggers.Errorf("Unable to read %s from the replacement data store: %s", kind.GetName(), err)
+			return
+		}
+		allData = append(allData, ldstoretypes.Collection{Kind: kind, Items: items})
+	}
+	updates.SendAllDataUpdate(allData)
+}
+
 func (sw *streamUpdatesStoreWrapper) Close() error {
 	return sw.store.Close()
 }
@@ -127,9 +262,19 @@ func (sw *streamUpdatesStoreWrapper) GetAll(kind ldstoretypes.DataKind) ([]ldsto
 func (sw *streamUpdatesStoreWrapper) Init(allData []ldstoretypes.Collection) error {
 	sw.loggers.Debug("Received all feature flags")
 	err := sw.store.Init(allData)
+	// Replacing all of the data isn't something we can describe as individual changes, so anyone who
+	// has an earlier version will need to get all of the data.
+	sw.historyLock.Lock()
+	sw.historyStart = sw.dataVersion.Add(1)
+	sw.history = sw.history[:0]
+	sw.historyNext = 0
+	sw.historyLock.Unlock()
+	if err == nil {
+		sw.resetDataHash(allData)
+	}
 
 	// See comments in Upsert for why we call SendAllDataUpdate here even if Init returned an error.
-	sw.updates.SendAllDataUpdate(allData)
+	sw.getUpdates().SendAllDataUpdate(allData)
 
 	return err
 }
@@ -141,6 +286,26 @@ func (sw *streamUpdatesStoreWrapper) Upsert(
 ) (bool, error) {
 	sw.loggers.Debugf(`Received feature flag update: %s (version %d)`, key, item.Version)
 	updated, err := sw.store.Upsert(kind, key, item)
+	// We record the change even if the store didn't change; that's harmless, it just means a client will
+	// reload the same data.
+	sw.historyLock.Lock()
+	record := dataChangeRecord{
+		dataVersion: sw.dataVersion.Add(1),
+		change:      DataChange{Kind: kind, Key: key},
+	}
+	if len(sw.history) < maxChangeHistory {
+		sw.history = append(sw.history, record)
+	} else {
+		// Overwrite the oldest record; history now covers only the changes after that one.
+		sw.historyStart = sw.history[sw.historyNext].dataVersion
+		sw.history[sw.historyNext] = record
+		sw.historyNext = (sw.historyNext + 1) % maxChangeHistory
+	}
+	sw.historyLock.Unlock()
+	if err == nil {
+		// The hash describes what the store contains, so it doesn't change if the store couldn't be updated.
+		sw.updateDataHash(kind, key, item)
+	}
 
 	// Note that Upsert returns two values; the first is a boolean which is true if it really did the update,
 	// or false if it did not because the store already contained an equal or greater version number.
@@ -163,7 +328,7 @@ func (sw *streamUpdatesStoreWrapper) Upsert(
 	// connected clients, because they may be using the stream rather than the database as their source of
 	// truth.
 
-	sw.updates.SendSingleItemUpdate(kind, key, item)
+	sw.getUpdates().SendSingleItemUpdate(kind, key, item)
 
 	return updated, err
 }
@@ -171,3 +336,98 @@ func (sw *streamUpdatesStoreWrapper) Upsert(
 func (sw *streamUpdatesStoreWrapper) IsInitialized() bool {
 	return sw.store.IsInitialized()
 }
+
+// The version is only advanced after the underlying store has been updated, so a caller that reads the
+// version before reading any data can never see a new version together with old data.
+func (sw *streamUpdatesStoreWrapper) getDataVersion() string {
+	n := sw.dataVersion.Load()
+	if n == 0 {
+		return ""
+	}
+	return sw.epoch + "." + strconv.FormatUint(n, 36)
+}
+
+// Like the data version, the hash is only updated after the underlying store has been updated; unlike the
+// version, it is not updated at all if the store returned an error.
+func (sw *streamUpdatesStoreWrapper) getDataHash() string {
+	sw.hashLock.RLock()
+	defer sw.hashLock.RUnlock()
+	if !sw.hasData {
+		return ""
+	}
+	return fmt.Sprintf("%016x", sw.dataHash)
+}
+
+func (sw *streamUpdatesStoreWrapper) resetDataHash(allData []ldstoretypes.Collection) {
+	sw.hashLock.Lock()
+	defer sw.hashLock.Unlock()
+	sw.dataHash = 0
+	sw.hasData = true
+	sw.itemVersions = make(map[dataItemID]dataItemVersion)
+	for _, coll := range allData {
+		for _, keyedItem := range coll.Items {
+			sw.applyToDataHash(coll.Kind, keyedItem.Key, keyedItem.Item)
+		}
+	}
+}
+
+func (sw *streamUpdatesStoreWrapper) updateDataHash(kind ldstoretypes.DataKind, key string, item ldstoretypes.ItemDescriptor) {
+	sw.hashLock.Lock()
+	defer sw.hashLock.Unlock()
+	sw.hasData = true
+	sw.applyToDataHash(kind, key, item)
+}
+
+// applyToDataHash follows the same versioning rule as the data store, so that the hash describes what the
+// store contains even if some updates arrive out of order. Deleted items are left out of the hash, since
+// whether a deleted item is still remembered at all depends on how this instance received its data.
+func (sw *streamUpdatesStoreWrapper) applyToDataHash(kind ldstoretypes.DataKind, key string, item ldstoretypes.ItemDescriptor) {
+	id := dataItemID{kind: kind.GetName(), key: key}
+	if prev, ok := sw.itemVersions[id]; ok {
+		if prev.version >= item.Version {
+			return
+		}
+		if !prev.deleted {
+			sw.dataHash ^= hashDataItem(id, prev.version)
+		}
+	}
+	deleted := item.Item == nil
+	sw.itemVersions[id] = dataItemVersion{version: item.Version, deleted: deleted}
+	if !deleted {
+		sw.dataHash ^= hashDataItem(id, item.Version)
+	}
+}
+
+func hashDataItem(id dataItemID, version int) uint64 {
+	hash := fnv.New64a()
+	_, _ = fmt.Fprintf(hash, "%s\x00%s\x00%d", id.kind, id.key, version)
+	return hash.Sum64()
+}
+
+func (sw *streamUpdatesStoreWrapper) getChangesSince(version string) ([]DataChange, string, bool) {
+	sw.historyLock.Lock()
+	defer sw.historyLock.Unlock()
+	current := sw.getDataVersion()
+	epoch, counter, found := strings.Cut(version, ".")
+	if !found || epoch != sw.epoch || current == "" {
+		return nil, current, false
+	}
+	since, err := strconv.ParseUint(counter, 36, 64)
+	if err != nil || since < sw.historyStart || since > sw.dataVersion.Load() {
+		return nil, current, false
+	}
+	var changes []DataChange
+	seen := make(map[[2]string]struct{}) // kind name and key
+	for i := range sw.history {
+		record := sw.history[(sw.historyNext+i)%len(sw.history)] // oldest first
+		if record.dataVersion <= since {
+			continue
+		}
+		id := [2]string{record.change.Kind.GetName(), record.change.Key}
+		if _, ok := seen[id]; !ok {
+			seen[id] = struct{}{}
+			changes = append(changes, record.change)
+		}
+	}
+	return changes, current, true
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/launchdarkly/go-server-sdk-evaluation/v3/ldbuilders"
 	"github.com/launchdarkly/go-server-sdk/v7/subsystems"
 	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoreimpl"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoretypes"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -57,6 +58,31 @@ func TestStoreAdapterReturnsErrorIfStoreCannotBeCreated(t *testing.T) {
 	assert.Nil(t, adapter.GetStore())
 }
 
+func TestStoreAdapterReplacement(t *testing.T) {
+	factory := &mockStoreFactory{instance: sharedtest.NewInMemoryStore()}
+	updates := &mockEnvStreamsUpdates{}
+
+	adapter := NewSSERelayDataStoreAdapter(factory, updates)
+	original, err := adapter.Build(subsystems.BasicClientContext{})
+	require.NoError(t, err)
+
+	replacement := adapter.NewReplacement()
+	created, err := replacement.Build(subsystems.BasicClientContext{})
+	require.NoError(t, err)
+	require.NoError(t, created.Init(allData))
+	assert.Same(t, original, adapter.GetStore())
+	assert.Empty(t, updates.allData, "the replacement's updates should not be broadcast yet")
+
+	adapter.ReplaceStore(replacement)
+	assert.Same(t, created, adapter.GetStore())
+	require.Len(t, updates.allData, 1)
+	assert.ElementsMatch(t, allData, updates.allData[0])
+
+	_, err = created.Upsert(ldstoreimpl.Features(), "flag-new", ldstoretypes.ItemDescriptor{Version: 1})
+	require.NoError(t, err)
+	assert.Len(t, updates.singleItem, 1)
+}
+
 func TestStoreInit(t *testing.T) {
 	baseStore, wrappedStore, updates := makeTestComponents()
 	err := wrappedStore.Init(allData)
@@ -74,6 +100,184 @@ func TestStoreInit(t *testing.T) {
 	)
 }
 
+func TestStoreDataVersion(t *testing.T) {
+	_, wrappedStore, _ := makeTestComponents()
+	assert.Equal(t, "", wrappedStore.getDataVersion())
+
+	require.NoError(t, wrappedStore.Init(allData))
+	v1 := wrappedStore.getDataVersion()
+	assert.NotEqual(t, "", v1)
+
+	_, _ = sharedtest.UpsertFlag(wrappedStore, testFlag1)
+	v2 := wrappedStore.getDataVersion()
+	assert.NotEqual(t, v1, v2)
+
+	_, otherStore, _ := makeTestComponents()
+	require.NoError(t, otherStore.Init(allData))
+	assert.NotEqual(t, v1, otherStore.getDataVersion())
+}
+
+func TestStoreDataHash(t *testing.T) {
+	_, wrappedStore, _ := makeTestComponents()
+	assert.Equal(t, "", wrappedStore.getDataHash())
+
+	require.NoError(t, wrappedStore.Init(allData))
+	h1 := wrappedStore.getDataHash()
+	assert.NotEqual(t, "", h1)
+
+	t.Run("same data in another store has the same hash", func(t *testing.T) {
+		_, otherStore, _ := makeTestComponents()
+		require.NoError(t, otherStore.Init(allData))
+		assert.Equal(t, h1, otherStore.getDataHash())
+	})
+
+	t.Run("same data received as individual updates has the same hash", func(t *testing.T) {
+		_, otherStore, _ := makeTestComponents()
+		require.NoError(t, otherStore.Init(nil))
+		_, _ = sharedtest.UpsertSegment(otherStore, testSegment1)
+		_, _ = sharedtest.UpsertFlag(otherStore, testFlag1)
+		assert.Equal(t, h1, otherStore.getDataHash())
+	})
+
+	t.Run("update with the same or a lower version does not change the hash", func(t *testing.T) {
+		_, _ = sharedtest.UpsertFlag(wrappedStore, testFlag1)
+		assert.Equal(t, h1, wrappedStore.getDataHash())
+	})
+
+	t.Run("update with a higher version changes the hash", func(t *testing.T) {
+		_, otherStore, _ := makeTestComponents()
+		require.NoError(t, otherStore.Init(allData))
+		_, _ = sharedtest.UpsertFlag(otherStore, ldbuilders.NewFlagBuilder(testFlag1.Key).Version(2).Build())
+		assert.NotEqual(t, h1, otherStore.getDataHash())
+	})
+
+	t.Run("deleted items are not part of the hash", func(t *testing.T) {
+		_, otherStore, _ := makeTestComponents()
+		require.NoError(t, otherStore.Init(allData))
+		_, _ = otherStore.Upsert(ldstoreimpl.Features(), testFlag2.Key, ldstoretypes.ItemDescriptor{Version: 1, Item: &testFlag2})
+		_, _ = otherStore.Upsert(ldstoreimpl.Features(), testFlag2.Key, ldstoretypes.ItemDescriptor{Version: 2, Item: nil})
+		assert.Equal(t, h1, otherStore.getDataHash())
+	})
+
+	t.Run("failed Init does not change the hash", func(t *testing.T) {
+		baseStore, otherStore, _ := makeTestComponents()
+		baseStore.fakeError = fakeError
+		assert.Equal(t, fakeError, otherStore.Init(allData))
+		assert.Equal(t, "", otherStore.getDataHash())
+	})
+
+	t.Run("failed update does not change the hash", func(t *testing.T) {
+		baseStore, otherStore, _ := makeTestComponents()
+		require.NoError(t, otherStore.Init(allData))
+		baseStore.fakeError = fakeError
+		_, err := sharedtest.UpsertFlag(otherStore, ldbuilders.NewFlagBuilder(testFlag1.Key).Version(2).Build())
+		assert.Equal(t, fakeError, err)
+		assert.Equal(t, h1, otherStore.getDataHash())
+	})
+}
+
+func TestStoreGetChangesSince(t *testing.T) {
+	t.Run("no data", func(t *testing.T) {
+		_, wrappedStore, _ := makeTestComponents()
+		_, current, ok := wrappedStore.getChangesSince("")
+		assert.False(t, ok)
+		assert.Equal(t, "", current)
+	})
+
+	t.Run("changes since a version", func(t *testing.T) {
+		_, wrappedStore, _ := makeTestComponents()
+		require.NoError(t, wrappedStore.Init(allData))
+		v1 := wrappedStore.getDataVersion()
+
+		_, _ = sharedtest.UpsertFlag(wrappedStore, testFlag1)
+		_, _ = sharedtest.UpsertSegment(wrappedStore, testSegment1)
+		v2 := wrappedStore.getDataVersion()
+		_, _ = sharedtest.UpsertFlag(wrappedStore, testFlag2)
+		_, _ = sharedtest.UpsertFlag(wrappedStore, testFlag1)
+
+		changes, current, ok := wrappedStore.getChangesSince(v1)
+		assert.True(t, ok)
+		assert.Equal(t, wrappedStore.getDataVersion(), current)
+		assert.Equal(t, []DataChange{
+			{Kind: ldstoreimpl.Features(), Key: testFlag1.Key},
+			{Kind: ldstoreimpl.Segments(), Key: testSegment1.Key},
+			{Kind: ldstoreimpl.Features(), Key: testFlag2.Key},
+		}, changes)
+
+		changes, _, ok = wrappedStore.getChangesSince(v2)
+		assert.True(t, ok)
+		assert.Equal(t, []DataChange{
+			{Kind: ldstoreimpl.Features(), Key: testFlag2.Key},
+			{Kind: ldstoreimpl.Features(), Key: testFlag1.Key},
+		}, changes)
+
+		changes, _, ok = wrappedStore.getChangesSince(current)
+		assert.True(t, ok)
+		assert.Len(t, changes, 0)
+	})
+
+	t.Run("version from before init", func(t *testing.T) {
+		_, wrappedStore, _ := makeTestComponents()
+		require.NoError(t, wrappedStore.Init(allData))
+		v1 := wrappedStore.getDataVersion()
+		require.NoError(t, wrappedStore.Init(allData))
+
+		_, current, ok := wrappedStore.getChangesSince(v1)
+		assert.False(t, ok)
+		assert.Equal(t, wrappedStore.getDataVersion(), current)
+	})
+
+	t.Run("history window exceeded", func(t *testing.T) {
+		_, wrappedStore, _ := makeTestComponents()
+		require.NoError(t, wrappedStore.Init(allData))
+		v1 := wrappedStore.getDataVersion()
+		_, _ = sharedtest.UpsertFlag(wrappedStore, testFlag1)
+		v2 := wrappedStore.getDataVersion()
+		for i := 0; i < maxChangeHistory; i++ {
+			_, _ = sharedtest.UpsertFlag(wrappedStore, testFlag2)
+		}
+
+		_, _, ok := wrappedStore.getChangesSince(v1)
+		assert.False(t, ok)
+		changes, _, ok := wrappedStore.getChangesSince(v2)
+		assert.True(t, ok)
+		assert.Equal(t, []DataChange{{Kind: ldstoreimpl.Features(), Key: testFlag2.Key}}, changes)
+	})
+
+	t.Run("history window wraps around", func(t *testing.T) {
+		_, wrappedStore, _ := makeTestComponents()
+		require.NoError(t, wrappedStore.Init(allData))
+		for i := 0; i < maxChangeHistory*2+10; i++ {
+			_, _ = sharedtest.UpsertFlag(wrappedStore, testFlag1)
+		}
+		v1 := wrappedStore.getDataVersion()
+		_, _ = sharedtest.UpsertFlag(wrappedStore, testFlag2)
+		_, _ = sharedtest.UpsertSegment(wrappedStore, testSegment1)
+		_, _ = sharedtest.UpsertFlag(wrappedStore, testFlag1)
+
+		changes, _, ok := wrappedStore.getChangesSince(v1)
+		assert.True(t, ok)
+		assert.Equal(t, []DataChange{
+			{Kind: ldstoreimpl.Features(), Key: testFlag2.Key},
+			{Kind: ldstoreimpl.Segments(), Key: testSegment1.Key},
+			{Kind: ldstoreimpl.Features(), Key: testFlag1.Key},
+		}, changes)
+		assert.Len(t, wrappedStore.history, maxChangeHistory)
+	})
+
+	t.Run("unknown version", func(t *testing.T) {
+		_, wrappedStore, _ := makeTestComponents()
+		require.NoError(t, wrappedStore.Init(allData))
+		_, otherStore, _ := makeTestComponents()
+		require.NoError(t, otherStore.Init(allData))
+
+		for _, version := range []string{"", "x", otherStore.getDataVersion(), wrappedStore.epoch + ".zzz"} {
+			_, _, ok := wrappedStore.getChangesSince(version)
+			assert.False(t, ok, "version %q", version)
+		}
+	})
+}
+
 func TestStoreGet(t *testing.T) {
 	baseStore, wrappedStore, _ := makeTestComponents()
 	_, _ = sharedtest.UpsertFlag(baseStore, testFlag1)
@@ -0,0 +1,78 @@
+package store
+
+import (
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoretypes"
+)
+
+// serializedDataSet is a copy of the latest data that the SDK has provided to a persistent data store.
+//
+// We keep the data in the same order that the SDK provided it in, since the SDK orders it so that items
+// are written after any items that they depend on.
+type serializedDataSet struct {
+	data    []ldstoretypes.SerializedCollection
+	indexes map[ldstoretypes.DataKind]map[string]int // positions of items within data
+}
+
+// init replaces all of the data.
+func (d *serializedDataSet) init(allData []ldstoretypes.SerializedCollection) {
+	d.data = make([]ldstoretypes.SerializedCollection, 0, len(allData))
+	d.indexes = make(map[ldstoretypes.DataKind]map[string]int, len(allData))
+	for _, coll := range allData {
+		index := make(map[string]int, len(coll.Items))
+		for i, item := range coll.Items {
+			index[item.Key] = i
+		}
+		d.data = append(d.data, ldstoretypes.SerializedCollection{
+			Kind:  coll.Kind,
+			Items: append([]ldstoretypes.KeyedSerializedItemDescriptor(nil), coll.Items...),
+		})
+		d.indexes[coll.Kind] = index
+	}
+}
+
+// upsert adds or updates an item, unless we already have the same or a higher version of it. It returns
+// true if the item was updated.
+func (d *serializedDataSet) upsert(
+	kind ldstoretypes.DataKind,
+	key string,
+	item ldstoretypes.SerializedItemDescriptor,
+) bool {
+	if d.indexes == nil {
+		d.indexes = make(map[ldstoretypes.DataKind]map[string]int)
+	}
+	index, ok := d.indexes[kind]
+	if !ok {
+		index = make(map[string]int)
+		d.indexes[kind] = index
+		d.data = append(d.data, ldstoretypes.SerializedCollection{Kind: kind})
+	}
+	var coll *ldstoretypes.SerializedCollection
+	for i := range d.data {
+		if d.data[i].Kind == kind {
+			coll = &d.data[i]
+			break
+		}
+	}
+	if i, ok := index[key]; ok {
+		if coll.Items[i].Item.Version >= item.Version {
+			return false
+		}
+		coll.Items[i].Item = item
+		return true
+	}
+	index[key] = len(coll.Items)
+	coll.Items = append(coll.Items, ldstoretypes.KeyedSerializedItemDescriptor{Key: key, Item: item})
+	return true
+}
+
+// snapshot returns a copy of the data that will not be affected by later updates.
+func (d *serializedDataSet) snapshot() []ldstoretypes.SerializedCollection {
+	ret := make([]ldstoretypes.SerializedCollection, 0, len(d.data))
+	for _, coll := range d.data {
+		ret = append(ret, ldstoretypes.SerializedCollection{
+			Kind:  coll.Kind,
+			Items: append([]ldstoretypes.KeyedSerializedItemDescriptor(nil), coll.Items...),
+		})
+	}
+	return ret
+}
@@ -0,0 +1,121 @@
+package streams
+
+import (
+	"container/list"
+	"context"
+	"sync"
+)
+
+// ConnectionLimiter enforces a maximum number of concurrent stream connections.
+//
+// Limiters form a two-level hierarchy: the Relay instance has a single root limiter for the whole node,
+// and each environment has a child limiter created with NewChild. A connection must fit within both its
+// own environment's limit and the node-wide limit. A limit of zero means "no limit" at that level.
+//
+// If shedding is enabled on the root limiter, a new connection that would exceed a limit causes the
+// oldest connection at the level that is full to be closed instead of rejecting the new one. Closing is
+// done by cancelling the request context of the old connection, which makes the SSE handler exit in the
+// same way that it does when the client disconnects or when MaxClientConnectionTime elapses.
+type ConnectionLimiter struct {
+	maxConns   int
+	shedOldest bool
+	parent     *ConnectionLimiter
+	root       *ConnectionLimiter
+	conns      *list.List // of *limitedConn, oldest first
+	lock       sync.Mutex // used only on the root limiter
+}
+
+type limitedConn struct {
+	cancel   context.CancelFunc
+	elements map[*ConnectionLimiter]*list.Element
+}
+
+// NewConnectionLimiter creates a root ConnectionLimiter. If maxConns is zero, there is no node-wide limit,
+// but child limiters can still have their own limits.
+func NewConnectionLimiter(maxConns int, shedOldest bool) *ConnectionLimiter {
+	l := &ConnectionLimiter{
+		maxConns:   maxConns,
+		shedOldest: shedOldest,
+		conns:      list.New(),
+	}
+	l.root = l
+	return l
+}
+
+// NewChild creates a ConnectionLimiter whose connections also count against this limiter's limit.
+func (l *ConnectionLimiter) NewChild(maxConns int) *ConnectionLimiter {
+	return &ConnectionLimiter{
+		maxConns:   maxConns,
+		shedOldest: l.root.shedOldest,
+		parent:     l,
+		root:       l.root,
+		conns:      list.New(),
+	}
+}
+
+// Acquire attempts to reserve a connection slot. The cancel function will be called if the connection
+// is later shed to make room for a newer one.
+//
+// If successful, it returns a function that must be called exactly once when the connection ends. If no
+// slot is available and shedding is disabled, it returns false.
+func (l *ConnectionLimiter) Acquire(cancel context.CancelFunc) (release func(), ok bool) {
+	if l == nil {
+		return func() {}, true
+	}
+	root := l.root
+	root.lock.Lock()
+	defer root.lock.Unlock()
+
+	var toShed []*limitedConn
+	for level := l; level != nil; level = level.parent {
+		if level.maxConns <= 0 || level.conns.Len() < level.maxConns {
+			continue
+		}
+		if !root.shedOldest || level.conns.Len() == 0 {
+			return nil, false
+		}
+		// Shed enough of this level's oldest connections to make room; each shed connection is also
+		// removed from every other level it was counted in.
+		for level.conns.Len() >= level.maxConns {
+			oldest := level.conns.Front().Value.(*limitedConn)
+			oldest.detach()
+			toShed = append(toShed, oldest)
+		}
+	}
+
+	conn := &limitedConn{cancel: cancel, elements: make(map[*ConnectionLimiter]*list.Element)}
+	for level := l; level != nil; level = level.parent {
+		conn.elements[level] = level.conns.PushBack(conn)
+	}
+	for _, c := range toShed {
+		c.cancel()
+	}
+
+	var releaseOnce sync.Once
+	return func() {
+		releaseOnce.Do(func() {
+			root.lock.Lock()
+			conn.detach()
+			root.lock.Unlock()
+		})
+	}, true
+}
+
+// Count returns the number of connections currently counted against this limiter, including those of
+// its children.
+func (l *ConnectionLimiter) Count() int {
+	if l == nil {
+		return 0
+	}
+	l.root.lock.Lock()
+	defer l.root.lock.Unlock()
+	return l.conns.Len()
+}
+
+// This must be called while holding the root lock. It is safe to call more than once.
+func (c *limitedConn) detach() {
+	for level, e := range c.elements {
+		level.conns.Remove(e)
+	}
+	c.elements = nil
+}
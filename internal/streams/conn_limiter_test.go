@@ -0,0 +1,113 @@
+package streams
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeCancel struct {
+	cancelled bool
+}
+
+func (f *fakeCancel) cancel() { f.cancelled = true }
+
+func TestConnectionLimiterWithNoLimits(t *testing.T) {
+	l := NewConnectionLimiter(0, false).NewChild(0)
+	for i := 0; i < 100; i++ {
+		_, ok := l.Acquire((&fakeCancel{}).cancel)
+		require.True(t, ok)
+	}
+	assert.Equal(t, 100, l.Count())
+}
+
+func TestConnectionLimiterNilIsUnlimited(t *testing.T) {
+	var l *ConnectionLimiter
+	release, ok := l.Acquire((&fakeCancel{}).cancel)
+	require.True(t, ok)
+	release()
+	assert.Equal(t, 0, l.Count())
+}
+
+func TestConnectionLimiterRejectsOverEnvironmentLimit(t *testing.T) {
+	root := NewConnectionLimiter(0, false)
+	env1, env2 := root.NewChild(2), root.NewChild(2)
+
+	release1, ok := env1.Acquire((&fakeCancel{}).cancel)
+	require.True(t, ok)
+	_, ok = env1.Acquire((&fakeCancel{}).cancel)
+	require.True(t, ok)
+	_, ok = env1.Acquire((&fakeCancel{}).cancel)
+	assert.False(t, ok)
+
+	_, ok = env2.Acquire((&fakeCancel{}).cancel)
+	assert.True(t, ok)
+
+	release1()
+	release1() // releasing twice has no further effect
+	assert.Equal(t, 1, env1.Count())
+	assert.Equal(t, 2, root.Count())
+
+	_, ok = env1.Acquire((&fakeCancel{}).cancel)
+	assert.True(t, ok)
+}
+
+func TestConnectionLimiterRejectsOverGlobalLimit(t *testing.T) {
+	root := NewConnectionLimiter(2, false)
+	env1, env2 := root.NewChild(0), root.NewChild(5)
+
+	_, ok := env1.Acquire((&fakeCancel{}).cancel)
+	require.True(t, ok)
+	release, ok := env2.Acquire((&fakeCancel{}).cancel)
+	require.True(t, ok)
+	_, ok = env2.Acquire((&fakeCancel{}).cancel)
+	assert.False(t, ok)
+
+	release()
+	_, ok = env2.Acquire((&fakeCancel{}).cancel)
+	assert.True(t, ok)
+}
+
+func TestConnectionLimiterShedsOldestInEnvironment(t *testing.T) {
+	root := NewConnectionLimiter(0, true)
+	env1, env2 := root.NewChild(2), root.NewChild(2)
+
+	other := &fakeCancel{}
+	_, _ = env2.Acquire(other.cancel)
+
+	first, second, third := &fakeCancel{}, &fakeCancel{}, &fakeCancel{}
+	releaseFirst, _ := env1.Acquire(first.cancel)
+	_, _ = env1.Acquire(second.cancel)
+	_, ok := env1.Acquire(third.cancel)
+	require.True(t, ok)
+
+	assert.True(t, first.cancelled)
+	assert.False(t, second.cancelled)
+	assert.False(t, third.cancelled)
+	assert.False(t, other.cancelled)
+	assert.Equal(t, 2, env1.Count())
+	assert.Equal(t, 3, root.Count())
+
+	// The shed connection's handler will still release its slot when it exits; that must not
+	// remove anything else.
+	releaseFirst()
+	assert.Equal(t, 2, env1.Count())
+	assert.Equal(t, 3, root.Count())
+}
+
+func TestConnectionLimiterShedsOldestAcrossEnvironments(t *testing.T) {
+	root := NewConnectionLimiter(2, true)
+	env1, env2 := root.NewChild(0), root.NewChild(0)
+
+	first, second, third := &fakeCancel{}, &fakeCancel{}, &fakeCancel{}
+	_, _ = env1.Acquire(first.cancel)
+	_, _ = env2.Acquire(second.cancel)
+	_, ok := env2.Acquire(third.cancel)
+	require.True(t, ok)
+
+	assert.True(t, first.cancelled)
+	assert.False(t, second.cancelled)
+	assert.Equal(t, 0, env1.Count())
+	assert.Equal(t, 2, env2.Count())
+}
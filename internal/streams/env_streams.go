@@ -57,6 +57,7 @@ type EnvStreams struct {
 
 type streamInfo struct {
 	credential        sdkauth.ScopedCredential
+	streamProvider    StreamProvider
 	envStreamProvider EnvStreamProvider
 }
 
@@ -115,7 +116,7 @@ func (es *EnvStreams) AddCredential(credential credential.SDKCredential) {
 	for _, sp := range es.streamProviders {
 		if esp := sp.Register(scopedCred, es.storeQueries, es.loggers); esp != nil {
 			es.lock.Lock()
-			es.activeStreams = append(es.activeStreams, streamInfo{scopedCred, esp})
+			es.activeStreams = append(es.activeStreams, streamInfo{scopedCred, sp, esp})
 			es.lock.Unlock()
 		}
 	}
@@ -171,6 +172,21 @@ func (es *EnvStreams) InvalidateClientSideState() {
 	}
 }
 
+// GetSubscriberCounts returns the number of clients that are currently connected to this environment's
+// streams, for each StreamProvider. Every StreamProvider is included, even if it has no connections.
+func (es *EnvStreams) GetSubscriberCounts() map[StreamProvider]int {
+	ret := make(map[StreamProvider]int, len(es.streamProviders))
+	for _, sp := range es.streamProviders {
+		ret[sp] = 0
+	}
+	es.lock.RLock()
+	defer es.lock.RUnlock()
+	for _, s := range es.activeStreams {
+		ret[s.streamProvider] += s.envStreamProvider.GetSubscriberCount()
+	}
+	return ret
+}
+
 // Close shuts down all currently active streams for this environment and releases its resources.
 func (es *EnvStreams) Close() error {
 	close(es.closeCh)
@@ -36,6 +36,7 @@ type mockEnvStreamProvider struct {
 	itemUpdates    []sharedtest.ReceivedItemUpdate
 	clientSideUps  int
 	numHeartbeats  int
+	subscribers    int
 	closed         bool
 	lock           sync.Mutex
 }
@@ -77,6 +78,12 @@ func (e *mockEnvStreamProvider) SendHeartbeat() {
 	e.numHeartbeats++
 }
 
+func (e *mockEnvStreamProvider) GetSubscriberCount() int {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.subscribers
+}
+
 func (e *mockEnvStreamProvider) Close() {
 	e.closed = true
 }
@@ -243,6 +250,26 @@ func TestInvalidateClientSideStateGoesToAllStreams(t *testing.T) {
 	assert.Equal(t, 1, esp3.clientSideUps)
 }
 
+func TestGetSubscriberCounts(t *testing.T) {
+	sp1 := &mockStreamProvider{credentialOfDesiredType: config.SDKKey("")}
+	sp2 := &mockStreamProvider{credentialOfDesiredType: config.MobileKey("")}
+
+	store := makeMockStore(nil, nil)
+	es := NewEnvStreams([]StreamProvider{sp1, sp2}, store, 0, config.DefaultFilter, ldlog.NewDisabledLoggers())
+	defer es.Close()
+
+	assert.Equal(t, map[StreamProvider]int{sp1: 0, sp2: 0}, es.GetSubscriberCounts())
+
+	es.AddCredential(config.SDKKey("sdk-key1"))
+	es.AddCredential(config.SDKKey("sdk-key2"))
+	es.AddCredential(config.MobileKey("mobile-key"))
+	require.Len(t, sp1.createdStreams, 2)
+	sp1.createdStreams[0].subscribers = 2
+	sp1.createdStreams[1].subscribers = 1
+
+	assert.Equal(t, map[StreamProvider]int{sp1: 3, sp2: 0}, es.GetSubscriberCounts())
+}
+
 func TestHeartbeatsGoToAllStreams(t *testing.T) {
 	heartbeatInterval := time.Millisecond * 20
 
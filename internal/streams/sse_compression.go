@@ -0,0 +1,243 @@
+package streams
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"hash/crc32"
+	"sync"
+)
+
+// Server-side streams can be gzip-compressed. Compressing each connection's stream separately would mean
+// compressing a multi-megabyte "put" event once for every connected SDK, so instead we compress each
+// large piece of event data just once, as a sequence of deflate blocks that doesn't refer back to any
+// earlier data. Any number of such sequences can be spliced together into a valid deflate stream, so
+// every connection can still get an ordinary single-member gzip stream: only the gzip header, the small
+// pieces of SSE framing around the data, and the checksum are produced per connection.
+//
+// Brotli streams can't be spliced together like this, so we only offer gzip for streams.
+
+const (
+	// Strings at least this long are compressed once and shared; shorter ones are compressed per connection.
+	sharedCompressionMinSize = 512
+
+	// Events are normally broadcast to all connections on a channel at once, so we only need to remember
+	// the last few for each channel.
+	compressedChunkCacheSize = 4
+)
+
+var (
+	gzipHeader     = []byte{0x1f, 0x8b, 8, 0, 0, 0, 0, 0, 0, 0xff} //nolint:gochecknoglobals
+	deflateEndMark = []byte{0x03, 0x00}                            //nolint:gochecknoglobals // an empty final block
+
+	flateWriterPools = map[int]*sync.Pool{ //nolint:gochecknoglobals
+		flate.BestSpeed:          newFlateWriterPool(flate.BestSpeed),
+		flate.DefaultCompression: newFlateWriterPool(flate.DefaultCompression),
+	}
+)
+
+func newFlateWriterPool(level int) *sync.Pool {
+	return &sync.Pool{New: func() interface{} {
+		w, _ := flate.NewWriter(nil, level) // can only fail if the level is invalid
+		return w
+	}}
+}
+
+type compressedChunk struct {
+	once sync.Once
+	data []byte
+	crc  uint32
+	size int
+}
+
+// compressedChunkCache holds recently compressed data separately for each channel. Each environment's
+// streams use different channels, so a burst of updates in one environment can't evict the data that
+// another environment's connections are about to send.
+type compressedChunkCache struct {
+	channels map[string]*channelChunks
+	lock     sync.Mutex
+}
+
+type channelChunks struct {
+	entries map[string]*compressedChunk
+	order   []string
+	conns   int // number of connections using the cache; guarded by the compressedChunkCache lock
+	lock    sync.Mutex
+}
+
+func newCompressedChunkCache() *compressedChunkCache {
+	return &compressedChunkCache{channels: make(map[string]*channelChunks)}
+}
+
+// forChannel returns the cache for a channel, creating it if necessary. The cache is kept for as long as
+// any caller is using it, so each call must be matched by a call to removeChannel.
+func (c *compressedChunkCache) forChannel(channel string) *channelChunks {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	cc := c.channels[channel]
+	if cc == nil {
+		cc = &channelChunks{entries: make(map[string]*compressedChunk)}
+		c.channels[channel] = cc
+	}
+	cc.conns++
+	return cc
+}
+
+// removeChannel is called when a connection is no longer using the cache for a channel, and discards the
+// cache if no other connection is using it.
+func (c *compressedChunkCache) removeChannel(channel string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if cc := c.channels[channel]; cc != nil {
+		cc.conns--
+		if cc.conns <= 0 {
+			delete(c.channels, channel)
+		}
+	}
+}
+
+// get returns the compressed form of s, compressing it if no other connection has already done so.
+func (cc *channelChunks) get(s string) *compressedChunk {
+	cc.lock.Lock()
+	chunk := cc.entries[s]
+	if chunk == nil {
+		chunk = &compressedChunk{}
+		if len(cc.order) == compressedChunkCacheSize {
+			delete(cc.entries, cc.order[0])
+			cc.order = cc.order[1:]
+		}
+		cc.entries[s] = chunk
+		cc.order = append(cc.order, s)
+	}
+	cc.lock.Unlock()
+	chunk.once.Do(func() {
+		data := []byte(s)
+		chunk.data = deflateChunk(data, flate.DefaultCompression)
+		chunk.crc = crc32.ChecksumIEEE(data)
+		chunk.size = len(data)
+	})
+	return chunk
+}
+
+// deflateChunk compresses data into deflate blocks that end on a byte boundary and are not marked as
+// final, so that the result can be followed by more blocks.
+func deflateChunk(data []byte, level int) []byte {
+	var buf bytes.Buffer
+	pool := flateWriterPools[level]
+	w := pool.Get().(*flate.Writer)
+	w.Reset(&buf)
+	_, _ = w.Write(data) // writing to a bytes.Buffer can't fail
+	_ = w.Flush()
+	pool.Put(w)
+	return buf.Bytes()
+}
+
+// gzipStreamBuilder produces the gzip-compressed bytes for one stream connection.
+type gzipStreamBuilder struct {
+	chunks  *channelChunks
+	started bool
+	pending []byte
+	out     [][]byte
+	crc     uint32
+	size    uint32 // the gzip format only records the size modulo 2^32
+}
+
+func (g *gzipStreamBuilder) write(data []byte) {
+	g.pending = append(g.pending, data...)
+}
+
+func (g *gzipStreamBuilder) writeString(s string) {
+	if len(s) < sharedCompressionMinSize {
+		g.pending = append(g.pending, s...)
+		return
+	}
+	g.compressPending()
+	chunk := g.chunks.get(s)
+	g.out = append(g.out, chunk.data)
+	g.crc = crc32Combine(g.crc, chunk.crc, int64(chunk.size))
+	g.size += uint32(chunk.size)
+}
+
+// take returns everything that is ready to be sent, with the stream header first if nothing has been
+// sent yet.
+func (g *gzipStreamBuilder) take() [][]byte {
+	g.compressPending()
+	out := g.out
+	if !g.started {
+		out = append([][]byte{gzipHeader}, out...)
+		g.started = true
+	}
+	g.out = nil
+	return out
+}
+
+// end returns the bytes that complete the stream.
+func (g *gzipStreamBuilder) end() []byte {
+	ret := append([]byte(nil), deflateEndMark...)
+	ret = binary.LittleEndian.AppendUint32(ret, g.crc)
+	return binary.LittleEndian.AppendUint32(ret, g.size)
+}
+
+func (g *gzipStreamBuilder) compressPending() {
+	if len(g.pending) == 0 {
+		return
+	}
+	g.out = append(g.out, deflateChunk(g.pending, flate.BestSpeed))
+	g.crc = crc32.Update(g.crc, crc32.IEEETable, g.pending)
+	g.size += uint32(len(g.pending))
+	g.pending = g.pending[:0]
+}
+
+// crc32Combine computes the CRC-32 of two concatenated pieces of data from their individual CRCs and
+// the length of the second piece, so that we don't have to checksum shared data for every connection.
+// This is the algorithm used by zlib's crc32_combine.
+func crc32Combine(crc1, crc2 uint32, len2 int64) uint32 {
+	if len2 <= 0 {
+		return crc1
+	}
+	var even, odd [32]uint32 // operators for 2^n zero bits, as GF(2) matrices
+	odd[0] = crc32.IEEE
+	row := uint32(1)
+	for n := 1; n < 32; n++ {
+		odd[n] = row
+		row <<= 1
+	}
+	gf2MatrixSquare(&even, &odd) // operator for two zero bits
+	gf2MatrixSquare(&odd, &even) // operator for four zero bits
+	for {
+		// apply len2 zero bytes to crc1
+		gf2MatrixSquare(&even, &odd)
+		if len2&1 != 0 {
+			crc1 = gf2MatrixTimes(&even, crc1)
+		}
+		len2 >>= 1
+		if len2 == 0 {
+			break
+		}
+		gf2MatrixSquare(&odd, &even)
+		if len2&1 != 0 {
+			crc1 = gf2MatrixTimes(&odd, crc1)
+		}
+		len2 >>= 1
+		if len2 == 0 {
+			break
+		}
+	}
+	return crc1 ^ crc2
+}
+
+func gf2MatrixTimes(mat *[32]uint32, vec uint32) uint32 {
+	var sum uint32
+	for i := 0; vec != 0; i, vec = i+1, vec>>1 {
+		if vec&1 != 0 {
+			sum ^= mat[i]
+		}
+	}
+	return sum
+}
+
+func gf2MatrixSquare(square, mat *[32]uint32) {
+	for n := 0; n < 32; n++ {
+		square[n] = gf2MatrixTimes(mat, mat[n])
+	}
+}
@@ -0,0 +1,148 @@
+package streams
+
+import (
+	"compress/gzip"
+	"hash/crc32"
+	"io"
+	"math/rand"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v8/internal/sharedtest"
+
+	"github.com/launchdarkly/eventsource"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCRC32Combine(t *testing.T) {
+	data := make([]byte, 10000)
+	_, _ = rand.New(rand.NewSource(0)).Read(data)
+	for _, split := range []int{0, 1, 7, 500, 9999, 10000} {
+		a, b := data[:split], data[split:]
+		assert.Equal(t, crc32.ChecksumIEEE(data),
+			crc32Combine(crc32.ChecksumIEEE(a), crc32.ChecksumIEEE(b), int64(len(b))), "split at %d", split)
+	}
+}
+
+func TestGzipStreamBuilderProducesValidStream(t *testing.T) {
+	bigData := strings.Repeat("abcdefghij", sharedCompressionMinSize)
+	g := &gzipStreamBuilder{chunks: newCompressedChunkCache().forChannel("chan")}
+
+	var compressed []byte
+	send := func() {
+		for _, data := range g.take() {
+			compressed = append(compressed, data...)
+		}
+	}
+	send()
+	g.write([]byte("event: put\n"))
+	g.writeString("data: ")
+	g.writeString(bigData)
+	g.write([]byte("\n\n"))
+	send()
+	g.writeString(":\n")
+	send()
+	g.writeString("data: ")
+	g.writeString(bigData)
+	g.write([]byte("\n\n"))
+	send()
+	compressed = append(compressed, g.end()...)
+
+	r, err := gzip.NewReader(strings.NewReader(string(compressed)))
+	require.NoError(t, err)
+	r.Multistream(false)
+	decoded, err := io.ReadAll(r)
+	require.NoError(t, err) // this also verifies the checksum and length
+	assert.Equal(t, "event: put\ndata: "+bigData+"\n\n:\ndata: "+bigData+"\n\n", string(decoded))
+	assert.Len(t, g.chunks.entries, 1)
+}
+
+func TestSSEServerSharesCompressedDataBetweenConnections(t *testing.T) {
+	s := newSSEServer(StreamProviderOptions{MaxConnTime: time.Millisecond * 300}, true)
+	defer s.Close()
+	bigData := strings.Repeat("abcdefghij", sharedCompressionMinSize)
+
+	const numConns = 2
+	results := make(chan string, numConns)
+	for i := 0; i < numConns; i++ {
+		req, _ := http.NewRequest("GET", "", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		w, body := sharedtest.NewStreamRecorder()
+		go s.Handler("chan").ServeHTTP(w, req)
+		go func() {
+			r, err := gzip.NewReader(body)
+			require.NoError(t, err)
+			assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+			r.Multistream(false)
+			decoded, err := io.ReadAll(r)
+			assert.NoError(t, err)
+			results <- string(decoded)
+		}()
+	}
+	require.Eventually(t, func() bool {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		return s.channels["chan"] != nil && len(s.channels["chan"].conns) == numConns
+	}, time.Second, time.Millisecond*10)
+
+	s.Publish([]string{"chan"}, testEvent{event: "put", data: bigData})
+
+	for i := 0; i < numConns; i++ {
+		select {
+		case result := <-results:
+			assert.Equal(t, "event: put\ndata: "+bigData+"\n\n", result)
+		case <-time.After(time.Second * 5):
+			require.Fail(t, "timed out waiting for stream to end")
+		}
+	}
+	assert.Len(t, s.chunks.channels, 0) // the cache for the channel is discarded once it has no connections
+}
+
+func TestCompressedChunkCacheIsSeparateForEachChannel(t *testing.T) {
+	c := newCompressedChunkCache()
+	cc1, cc2 := c.forChannel("chan1"), c.forChannel("chan2")
+	chunk1 := cc1.get("data1")
+	for i := 0; i < compressedChunkCacheSize; i++ {
+		_ = cc2.get(strings.Repeat("x", i+1))
+	}
+	assert.Same(t, chunk1, cc1.get("data1"))
+	assert.Len(t, cc2.entries, compressedChunkCacheSize)
+
+	c.removeChannel("chan1")
+	assert.NotSame(t, chunk1, c.forChannel("chan1").get("data1"))
+}
+
+func TestCompressedChunkCacheIsKeptUntilNoConnectionIsUsingIt(t *testing.T) {
+	c := newCompressedChunkCache()
+	chunk1 := c.forChannel("chan").get("data1")
+	cc := c.forChannel("chan")
+
+	c.removeChannel("chan")
+	assert.Same(t, chunk1, cc.get("data1"))
+	assert.Same(t, cc, c.channels["chan"])
+
+	c.removeChannel("chan")
+	assert.Len(t, c.channels, 0)
+}
+
+func TestSSEServerDoesNotCompressStreamForClientThatDoesNotAcceptGzip(t *testing.T) {
+	s := newSSEServer(StreamProviderOptions{}, true)
+	defer s.Close()
+
+	req, _ := http.NewRequest("GET", "", nil)
+	resp := sharedtest.WithStreamRequest(t, req, s.Handler("chan"), func(eventCh <-chan eventsource.Event) {
+		require.Eventually(t, func() bool {
+			s.lock.Lock()
+			defer s.lock.Unlock()
+			return s.channels["chan"] != nil
+		}, time.Second, time.Millisecond*10)
+		expected := testEvent{event: "put", data: strings.Repeat("x", sharedCompressionMinSize)}
+		s.Publish([]string{"chan"}, expected)
+		expectEvent(t, eventCh, expected)
+	})
+	assert.Equal(t, "", resp.Header.Get("Content-Encoding"))
+}
@@ -0,0 +1,367 @@
+package streams
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v8/internal/compression"
+
+	"github.com/launchdarkly/eventsource"
+)
+
+// SlowConsumerConfig specifies when a stream connection that is not keeping up with published events
+// should be disconnected. A zero value for either field disables that check.
+type SlowConsumerConfig struct {
+	// MaxQueuedEvents is the number of published events that a connection can have waiting to be
+	// written before it is disconnected.
+	MaxQueuedEvents int
+
+	// WriteTimeout is the maximum time that writing a single event to a connection can take.
+	WriteTimeout time.Duration
+}
+
+// SlowConsumerReason describes why a slow stream connection was disconnected.
+type SlowConsumerReason string
+
+const (
+	// SlowConsumerQueueFull means that too many events were waiting to be written to the connection.
+	SlowConsumerQueueFull SlowConsumerReason = "queue_full"
+
+	// SlowConsumerWriteTimeout means that writing an event to the connection took too long.
+	SlowConsumerWriteTimeout SlowConsumerReason = "write_timeout"
+)
+
+// SlowConsumerEviction describes a stream connection that was disconnected for being too slow.
+type SlowConsumerEviction struct {
+	Reason       SlowConsumerReason
+	QueuedEvents int
+	WriteLatency time.Duration // duration of the most recent event write, or of the one that timed out
+}
+
+type slowConsumerListenerKey struct{}
+
+// WithSlowConsumerListener returns a copy of the context that tells stream handlers to call the
+// listener if the connection is disconnected for being too slow.
+func WithSlowConsumerListener(ctx context.Context, listener func(SlowConsumerEviction)) context.Context {
+	return context.WithValue(ctx, slowConsumerListenerKey{}, listener)
+}
+
+// sseServer wraps eventsource.Server so that we can see how far behind each connection is.
+//
+// The eventsource package gives each connection a buffered channel and silently drops the connection
+// if that channel fills up, but it has no way to report queue depth or to stop a write that is blocked
+// on a client that has stopped reading. We count the events that are published to each channel, and
+// the events that each connection has written, and use a write deadline to bound the time that any
+// one write can take.
+//
+// It also takes care of compressing the stream, if that's enabled and the client accepts it (see
+// sse_compression.go).
+type sseServer struct {
+	*eventsource.Server
+	slowConsumers SlowConsumerConfig
+	chunks        *compressedChunkCache // nil if compression is disabled
+	channels      map[string]*sseChannelState
+	lock          sync.Mutex
+}
+
+type sseChannelState struct {
+	published uint64
+	conns     map[*sseConn]struct{}
+}
+
+type sseConn struct {
+	server       *sseServer
+	channel      string
+	cancel       context.CancelFunc
+	registered   bool
+	writing      bool
+	baseline     uint64 // value of published when the connection was registered
+	written      uint64
+	writeLatency time.Duration
+	evicted      *SlowConsumerEviction
+}
+
+func newSSEServer(options StreamProviderOptions, compress bool) *sseServer {
+	s := eventsource.NewServer()
+	s.Gzip = false // we do our own compression, which is more efficient for broadcasts
+	s.AllowCORS = true
+	s.ReplayAll = true
+	s.MaxConnTime = options.MaxConnTime
+	if options.SlowConsumers.MaxQueuedEvents > 0 {
+		// Leave a little more room in the eventsource buffer than our own limit, so that we are the
+		// ones who notice the connection falling behind and can report it.
+		s.BufferSize = options.SlowConsumers.MaxQueuedEvents + 2
+	}
+	server := &sseServer{
+		Server:        s,
+		slowConsumers: options.SlowConsumers,
+		channels:      make(map[string]*sseChannelState),
+	}
+	if compress && !options.DisableCompression {
+		server.chunks = newCompressedChunkCache()
+	}
+	return server
+}
+
+// Publish publishes an event to one or more channels, first disconnecting any connections on those
+// channels that would fall too far behind.
+func (s *sseServer) Publish(channels []string, ev eventsource.Event) {
+	s.countPublished(channels)
+	s.Server.Publish(channels, ev)
+}
+
+// PublishComment publishes a comment to one or more channels, first disconnecting any connections on
+// those channels that would fall too far behind.
+func (s *sseServer) PublishComment(channels []string, text string) {
+	s.countPublished(channels)
+	s.Server.PublishComment(channels, text)
+}
+
+// Handler returns an HTTP handler for the specified channel that tracks the connection's progress.
+func (s *sseServer) Handler(channel string) http.HandlerFunc {
+	handler := s.Server.Handler(channel)
+	return func(w http.ResponseWriter, req *http.Request) {
+		ctx, cancel := context.WithCancel(req.Context())
+		defer cancel()
+		conn := &sseConn{server: s, channel: channel, cancel: cancel}
+		cw := &sseConnWriter{ResponseWriter: w, controller: http.NewResponseController(w), conn: conn}
+		if s.chunks != nil {
+			w.Header().Add("Vary", "Accept-Encoding")
+			if compression.Negotiate(req.Header.Get("Accept-Encoding"), compression.Gzip) == compression.Gzip {
+				w.Header().Set("Content-Encoding", string(compression.Gzip))
+				// This keeps the channel's cache from being discarded if the channel's other connections
+				// all close before this one is registered.
+				cw.gzip = &gzipStreamBuilder{chunks: s.chunks.forChannel(channel)}
+			}
+		}
+
+		handler.ServeHTTP(cw, req.WithContext(ctx))
+
+		s.lock.Lock()
+		s.unregister(conn)
+		evicted := conn.evicted
+		s.lock.Unlock()
+		if cw.gzip != nil {
+			s.chunks.removeChannel(channel)
+		}
+
+		if cw.gzip != nil && evicted == nil && req.Context().Err() == nil {
+			// The stream is ending normally, so we can finish the gzip stream properly. If the client
+			// has gone away or can't keep up, there's no point.
+			if _, err := w.Write(cw.gzip.end()); err == nil {
+				_ = cw.controller.Flush()
+			}
+		}
+
+		if evicted != nil {
+			if listener, ok := req.Context().Value(slowConsumerListenerKey{}).(func(SlowConsumerEviction)); ok {
+				listener(*evicted)
+			}
+		}
+	}
+}
+
+// We count events before they are published, rather than after, so that a connection can't have written
+// an event that hasn't been counted yet.
+func (s *sseServer) countPublished(channels []string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, channel := range channels {
+		state := s.channels[channel]
+		if state == nil {
+			continue // no one is connected, so there's nothing to keep track of
+		}
+		state.published++
+		if s.slowConsumers.MaxQueuedEvents <= 0 {
+			continue
+		}
+		for conn := range state.conns {
+			if queued := conn.queuedEvents(state); queued > s.slowConsumers.MaxQueuedEvents {
+				conn.evict(SlowConsumerQueueFull, queued)
+			}
+		}
+	}
+}
+
+// getSubscriberCount returns the number of connections that have subscribed to any of the channels.
+func (s *sseServer) getSubscriberCount(channels []string) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	count := 0
+	for _, channel := range channels {
+		if state := s.channels[channel]; state != nil {
+			count += len(state.conns)
+		}
+	}
+	return count
+}
+
+// This must be called while holding the lock.
+func (s *sseServer) getChannelState(channel string) *sseChannelState {
+	state := s.channels[channel]
+	if state == nil {
+		state = &sseChannelState{conns: make(map[*sseConn]struct{})}
+		s.channels[channel] = state
+	}
+	return state
+}
+
+// This must be called while holding the lock.
+func (s *sseServer) unregister(conn *sseConn) {
+	if state := s.channels[conn.channel]; state != nil {
+		delete(state.conns, conn)
+		if len(state.conns) == 0 {
+			delete(s.channels, conn.channel)
+		}
+	}
+}
+
+// This is called when the eventsource handler has finished writing an event, or, the first time, when
+// it has subscribed to the channel and is ready to receive events.
+func (c *sseConn) onFlush(latency time.Duration) {
+	s := c.server
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if c.evicted != nil {
+		return
+	}
+	state := s.getChannelState(c.channel)
+	if !c.registered {
+		state.conns[c] = struct{}{}
+		c.registered = true
+		c.baseline = state.published
+		return
+	}
+	c.written++
+	c.writing = false
+	c.writeLatency = latency
+	// Events that the eventsource server replays to a new connection are written without having been
+	// published, so the count can get ahead of the channel; adjust the baseline so that it doesn't.
+	if c.baseline+c.written > state.published {
+		c.baseline = state.published - c.written
+	}
+}
+
+func (c *sseConn) onStartWrite() {
+	c.server.lock.Lock()
+	c.writing = c.registered
+	c.server.lock.Unlock()
+}
+
+// This must be called while holding the lock. An event that is partly written doesn't count as queued,
+// since the client may already be reading it.
+func (c *sseConn) queuedEvents(state *sseChannelState) int {
+	queued := int(state.published - c.baseline - c.written)
+	if c.writing && queued > 0 {
+		queued--
+	}
+	return queued
+}
+
+// This must be called while holding the lock.
+func (c *sseConn) evict(reason SlowConsumerReason, queued int) {
+	if c.evicted != nil {
+		return
+	}
+	c.evicted = &SlowConsumerEviction{Reason: reason, QueuedEvents: queued, WriteLatency: c.writeLatency}
+	c.cancel()
+}
+
+func (c *sseConn) onWriteTimeout(latency time.Duration) {
+	s := c.server
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	c.writeLatency = latency
+	queued := 0
+	if state := s.channels[c.channel]; state != nil && c.registered {
+		queued = c.queuedEvents(state)
+	}
+	c.evict(SlowConsumerWriteTimeout, queued)
+}
+
+// sseConnWriter is the ResponseWriter that we give to the eventsource handler. The handler writes each
+// event with one or more calls to Write or WriteString followed by one call to Flush.
+type sseConnWriter struct {
+	http.ResponseWriter
+	controller *http.ResponseController
+	conn       *sseConn
+	gzip       *gzipStreamBuilder // nil if the stream isn't compressed
+	writeStart time.Time
+}
+
+func (w *sseConnWriter) Write(data []byte) (int, error) {
+	w.startWrite()
+	if w.gzip != nil {
+		w.gzip.write(data)
+		return len(data), nil
+	}
+	return w.checkWriteResult(w.ResponseWriter.Write(data))
+}
+
+// WriteString is called by the eventsource encoder via io.WriteString. It lets us recognize event data
+// that is shared by every connection, so that it only needs to be compressed once.
+func (w *sseConnWriter) WriteString(s string) (int, error) {
+	w.startWrite()
+	if w.gzip != nil {
+		w.gzip.writeString(s)
+		return len(s), nil
+	}
+	return w.checkWriteResult(io.WriteString(w.ResponseWriter, s))
+}
+
+func (w *sseConnWriter) checkWriteResult(n int, err error) (int, error) {
+	if err != nil && isTimeout(err) {
+		w.conn.onWriteTimeout(time.Since(w.writeStart))
+	}
+	return n, err
+}
+
+func (w *sseConnWriter) Flush() {
+	w.startWrite()
+	if w.gzip != nil {
+		for _, data := range w.gzip.take() {
+			if _, err := w.ResponseWriter.Write(data); err != nil {
+				if isTimeout(err) {
+					w.conn.onWriteTimeout(time.Since(w.writeStart))
+				}
+				return
+			}
+		}
+	}
+	if err := w.controller.Flush(); err != nil && isTimeout(err) {
+		w.conn.onWriteTimeout(time.Since(w.writeStart))
+		return
+	}
+	w.conn.onFlush(time.Since(w.writeStart))
+	w.writeStart = time.Time{}
+	if w.conn.server.slowConsumers.WriteTimeout > 0 {
+		// Clear the deadline so that it doesn't affect anything written after the handler exits.
+		_ = w.controller.SetWriteDeadline(time.Time{})
+	}
+}
+
+func (w *sseConnWriter) startWrite() {
+	if !w.writeStart.IsZero() {
+		return
+	}
+	w.writeStart = time.Now()
+	w.conn.onStartWrite()
+	if timeout := w.conn.server.slowConsumers.WriteTimeout; timeout > 0 {
+		// Not every ResponseWriter supports deadlines (for instance, httptest.ResponseRecorder does not),
+		// in which case we can still measure latency but can't interrupt a blocked write.
+		_ = w.controller.SetWriteDeadline(w.writeStart.Add(timeout))
+	}
+}
+
+func (w *sseConnWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func isTimeout(err error) bool {
+	return errors.Is(err, os.ErrDeadlineExceeded)
+}
@@ -0,0 +1,203 @@
+package streams
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v8/internal/logging"
+	"github.com/launchdarkly/ld-relay/v8/internal/sharedtest"
+
+	"github.com/launchdarkly/eventsource"
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	"github.com/launchdarkly/go-sdk-common/v3/ldlogtest"
+	helpers "github.com/launchdarkly/go-test-helpers/v3"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stalledResponseWriter simulates a client that has stopped reading: once stalled, every Write blocks
+// until the write deadline passes or the test releases it.
+type stalledResponseWriter struct {
+	header   http.Header
+	stalled  chan struct{}
+	release  chan struct{}
+	deadline time.Time
+	lock     sync.Mutex
+}
+
+func newStalledResponseWriter() *stalledResponseWriter {
+	return &stalledResponseWriter{
+		header:  make(http.Header),
+		stalled: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+}
+
+func (w *stalledResponseWriter) Header() http.Header { return w.header }
+
+func (w *stalledResponseWriter) WriteHeader(int) {}
+
+func (w *stalledResponseWriter) Flush() {}
+
+func (w *stalledResponseWriter) SetWriteDeadline(t time.Time) error {
+	w.lock.Lock()
+	w.deadline = t
+	w.lock.Unlock()
+	return nil
+}
+
+func (w *stalledResponseWriter) Write(data []byte) (int, error) {
+	select {
+	case <-w.stalled:
+	default:
+		close(w.stalled) // the first write is the one that never completes
+	}
+	w.lock.Lock()
+	deadline := w.deadline
+	w.lock.Unlock()
+	var timeout <-chan time.Time
+	if !deadline.IsZero() {
+		timeout = time.After(time.Until(deadline))
+	}
+	select {
+	case <-w.release:
+		return len(data), nil
+	case <-timeout:
+		return 0, os.ErrDeadlineExceeded
+	}
+}
+
+func startSlowConsumerRequest(
+	t *testing.T,
+	s *sseServer,
+	channel string,
+	w http.ResponseWriter,
+) (<-chan SlowConsumerEviction, <-chan struct{}) {
+	return startSlowConsumerRequestWithHandler(t, s, channel, s.Handler(channel), w)
+}
+
+func startSlowConsumerRequestWithHandler(
+	t *testing.T,
+	s *sseServer,
+	channel string,
+	handler http.Handler,
+	w http.ResponseWriter,
+) (<-chan SlowConsumerEviction, <-chan struct{}) {
+	evictions := make(chan SlowConsumerEviction, 1)
+	done := make(chan struct{}, 1)
+	ctx := WithSlowConsumerListener(context.Background(), func(e SlowConsumerEviction) { evictions <- e })
+	req, _ := http.NewRequestWithContext(ctx, "GET", "", nil)
+	go func() {
+		handler.ServeHTTP(w, req)
+		done <- struct{}{}
+	}()
+	require.Eventually(t, func() bool {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		return s.channels[channel] != nil && len(s.channels[channel].conns) == 1
+	}, time.Second, time.Millisecond*10)
+	return evictions, done
+}
+
+func TestSSEServerEvictsConnectionWithTooManyQueuedEvents(t *testing.T) {
+	s := newSSEServer(StreamProviderOptions{SlowConsumers: SlowConsumerConfig{MaxQueuedEvents: 3}}, false)
+	defer s.Close()
+
+	w := newStalledResponseWriter()
+	evictions, done := startSlowConsumerRequest(t, s, "chan", w)
+
+	s.Publish([]string{"chan"}, testEvent{event: "put", data: "data"})
+	<-w.stalled
+	for i := 0; i < 3; i++ {
+		s.PublishComment([]string{"chan"}, "")
+	}
+	helpers.AssertNoMoreValues(t, evictions, time.Millisecond*50, "connection was evicted too soon")
+
+	s.PublishComment([]string{"chan"}, "")
+	close(w.release)
+	helpers.RequireValue(t, done, time.Second, "timed out waiting for handler to exit")
+	e := helpers.RequireValue(t, evictions, time.Second, "timed out waiting for eviction")
+	assert.Equal(t, SlowConsumerQueueFull, e.Reason)
+	assert.Equal(t, 4, e.QueuedEvents)
+}
+
+func TestSSEServerEvictsConnectionWhenWriteTimesOut(t *testing.T) {
+	s := newSSEServer(StreamProviderOptions{SlowConsumers: SlowConsumerConfig{WriteTimeout: time.Millisecond * 50}}, false)
+	defer s.Close()
+
+	w := newStalledResponseWriter()
+	evictions, done := startSlowConsumerRequest(t, s, "chan", w)
+
+	s.Publish([]string{"chan"}, testEvent{event: "put", data: "data"})
+	helpers.RequireValue(t, done, time.Second, "timed out waiting for handler to exit")
+	e := helpers.RequireValue(t, evictions, time.Second, "timed out waiting for eviction")
+	assert.Equal(t, SlowConsumerWriteTimeout, e.Reason)
+	assert.GreaterOrEqual(t, e.WriteLatency, time.Millisecond*50)
+}
+
+func TestSSEServerEvictsConnectionWhenWriteTimesOutWithDebugLogging(t *testing.T) {
+	// The debug-level request logger wraps the ResponseWriter; the write deadline must still reach the
+	// underlying writer.
+	s := newSSEServer(StreamProviderOptions{SlowConsumers: SlowConsumerConfig{WriteTimeout: time.Millisecond * 50}}, false)
+	defer s.Close()
+
+	mockLog := ldlogtest.NewMockLog()
+	mockLog.Loggers.SetMinLevel(ldlog.Debug)
+	handler := logging.RequestLoggerMiddleware(mockLog.Loggers)(s.Handler("chan"))
+
+	w := newStalledResponseWriter()
+	evictions, done := startSlowConsumerRequestWithHandler(t, s, "chan", handler, w)
+
+	s.Publish([]string{"chan"}, testEvent{event: "put", data: "data"})
+	helpers.RequireValue(t, done, time.Second, "timed out waiting for handler to exit")
+	e := helpers.RequireValue(t, evictions, time.Second, "timed out waiting for eviction")
+	assert.Equal(t, SlowConsumerWriteTimeout, e.Reason)
+}
+
+func TestSSEServerDoesNotEvictConnectionThatKeepsUp(t *testing.T) {
+	s := newSSEServer(StreamProviderOptions{SlowConsumers: SlowConsumerConfig{MaxQueuedEvents: 1, WriteTimeout: time.Second}}, false)
+	defer s.Close()
+
+	req, _ := http.NewRequest("GET", "", nil)
+	sharedtest.WithStreamRequest(t, req, s.Handler("chan"), func(eventCh <-chan eventsource.Event) {
+		require.Eventually(t, func() bool {
+			s.lock.Lock()
+			defer s.lock.Unlock()
+			return s.channels["chan"] != nil
+		}, time.Second, time.Millisecond*10)
+		for i := 0; i < 10; i++ {
+			expected := testEvent{event: "patch", data: "data"}
+			s.Publish([]string{"chan"}, expected)
+			expectEvent(t, eventCh, expected)
+		}
+	})
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	assert.Len(t, s.channels, 0)
+}
+
+func TestSSEServerCountsSubscribers(t *testing.T) {
+	s := newSSEServer(StreamProviderOptions{}, false)
+	defer s.Close()
+
+	assert.Equal(t, 0, s.getSubscriberCount([]string{"chan1", "chan2"}))
+
+	req1, _ := http.NewRequest("GET", "", nil)
+	sharedtest.WithStreamRequest(t, req1, s.Handler("chan1"), func(<-chan eventsource.Event) {
+		req2, _ := http.NewRequest("GET", "", nil)
+		sharedtest.WithStreamRequest(t, req2, s.Handler("chan2"), func(<-chan eventsource.Event) {
+			require.Eventually(t, func() bool {
+				return s.getSubscriberCount([]string{"chan1", "chan2"}) == 2
+			}, time.Second, time.Millisecond*10)
+			assert.Equal(t, 1, s.getSubscriberCount([]string{"chan1"}))
+		})
+	})
+
+	assert.Equal(t, 0, s.getSubscriberCount([]string{"chan1", "chan2"}))
+}
@@ -8,7 +8,6 @@ import (
 
 	"github.com/launchdarkly/ld-relay/v8/internal/basictypes"
 
-	"github.com/launchdarkly/eventsource"
 	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
 	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoretypes"
 )
@@ -42,43 +41,52 @@ type EnvStreamProvider interface {
 	// SendHeartbeat sends keep-alive data on the stream.
 	SendHeartbeat()
 
+	// GetSubscriberCount returns the number of clients that are currently connected to the stream.
+	GetSubscriberCount() int
+
 	// Close releases all resources for this EnvStreamProvider and closes all connections to it.
 	Close()
 }
 
+// StreamProviderOptions contains optional parameters for NewStreamProvider.
+type StreamProviderOptions struct {
+	// MaxConnTime, if non-zero, is the maximum time that a stream connection can stay open.
+	MaxConnTime time.Duration
+
+	// SlowConsumers specifies when connections that are not keeping up should be closed.
+	SlowConsumers SlowConsumerConfig
+
+	// DisableCompression turns off gzip compression for the kinds of streams that support it.
+	DisableCompression bool
+}
+
 // NewStreamProvider creates a StreamProvider implementation for the specified kind of stream endpoint.
-func NewStreamProvider(kind basictypes.StreamKind, maxConnTime time.Duration) StreamProvider {
+//
+// The server-side streams are gzip-compressed for clients that accept it, since their "put" events can
+// be large. The client-side streams only contain small "ping" events, so compressing them wouldn't help.
+func NewStreamProvider(kind basictypes.StreamKind, options StreamProviderOptions) StreamProvider {
 	switch kind {
 	case basictypes.ServerSideFlagsOnlyStream:
 		return &serverSideFlagsOnlyStreamProvider{
-			server: newSSEServer(maxConnTime),
+			server: newSSEServer(options, true),
 		}
 	case basictypes.MobilePingStream:
 		return &clientSidePingStreamProvider{
-			server:     newSSEServer(maxConnTime),
+			server:     newSSEServer(options, false),
 			isJSClient: false,
 		}
 	case basictypes.JSClientPingStream:
 		return &clientSidePingStreamProvider{
-			server:     newSSEServer(maxConnTime),
+			server:     newSSEServer(options, false),
 			isJSClient: true,
 		}
 	default:
 		return &serverSideStreamProvider{
-			server: newSSEServer(maxConnTime),
+			server: newSSEServer(options, true),
 		}
 	}
 }
 
-func newSSEServer(maxConnTime time.Duration) *eventsource.Server {
-	s := eventsource.NewServer()
-	s.Gzip = false
-	s.AllowCORS = true
-	s.ReplayAll = true
-	s.MaxConnTime = maxConnTime
-	return s
-}
-
 func removeDeleted(items []ldstoretypes.KeyedItemDescriptor) []ldstoretypes.KeyedItemDescriptor {
 	var ret []ldstoretypes.KeyedItemDescriptor
 	for i, keyedItem := range items {
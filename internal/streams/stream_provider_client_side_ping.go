@@ -20,13 +20,13 @@ import (
 // event on initial connection, and another "ping" every time there is a data update of any kind.
 
 type clientSidePingStreamProvider struct {
-	server     *eventsource.Server
+	server     *sseServer
 	isJSClient bool
 	closeOnce  sync.Once
 }
 
 type clientSidePingEnvStreamProvider struct {
-	server   *eventsource.Server
+	server   *sseServer
 	channels []string
 }
 
@@ -90,6 +90,10 @@ func (e *clientSidePingEnvStreamProvider) SendHeartbeat() {
 	e.server.PublishComment(e.channels, "")
 }
 
+func (e *clientSidePingEnvStreamProvider) GetSubscriberCount() int {
+	return e.server.getSubscriberCount(e.channels)
+}
+
 func (e *clientSidePingEnvStreamProvider) Close() {
 	for _, key := range e.channels {
 		e.server.Unregister(key, true)
@@ -27,7 +27,7 @@ func TestStreamProviderMobilePing(t *testing.T) {
 	invalidCredential2 := sdkauth.New(testEnvID)
 
 	withStreamProvider := func(t *testing.T, maxConnTime time.Duration, action func(StreamProvider)) {
-		sp := NewStreamProvider(basictypes.MobilePingStream, maxConnTime)
+		sp := NewStreamProvider(basictypes.MobilePingStream, StreamProviderOptions{MaxConnTime: maxConnTime})
 		require.NotNil(t, sp)
 		defer sp.Close()
 		action(sp)
@@ -70,7 +70,7 @@ func TestStreamProviderJSClientPing(t *testing.T) {
 	invalidCredential2 := sdkauth.New(testMobileKey)
 
 	withStreamProvider := func(t *testing.T, maxConnTime time.Duration, action func(StreamProvider)) {
-		sp := NewStreamProvider(basictypes.JSClientPingStream, maxConnTime)
+		sp := NewStreamProvider(basictypes.JSClientPingStream, StreamProviderOptions{MaxConnTime: maxConnTime})
 		require.NotNil(t, sp)
 		defer sp.Close()
 		action(sp)
@@ -114,7 +114,7 @@ func TestStreamProviderAllClientSidePing(t *testing.T) {
 
 	validCredential := sdkauth.New(testMobileKey)
 	withStreamProvider := func(t *testing.T, maxConnTime time.Duration, action func(StreamProvider)) {
-		sp := NewStreamProvider(basictypes.MobilePingStream, maxConnTime)
+		sp := NewStreamProvider(basictypes.MobilePingStream, StreamProviderOptions{MaxConnTime: maxConnTime})
 		require.NotNil(t, sp)
 		defer sp.Close()
 		action(sp)
@@ -18,12 +18,12 @@ import (
 // This is the standard implementation of the /all stream for server-side SDKs.
 
 type serverSideStreamProvider struct {
-	server    *eventsource.Server
+	server    *sseServer
 	closeOnce sync.Once
 }
 
 type serverSideEnvStreamProvider struct {
-	server   *eventsource.Server
+	server   *sseServer
 	channels []string
 }
 
@@ -79,6 +79,10 @@ func (e *serverSideEnvStreamProvider) SendHeartbeat() {
 	e.server.PublishComment(e.channels, "")
 }
 
+func (e *serverSideEnvStreamProvider) GetSubscriberCount() int {
+	return e.server.getSubscriberCount(e.channels)
+}
+
 func (e *serverSideEnvStreamProvider) Close() {
 	for _, key := range e.channels {
 		e.server.Unregister(key, true)
@@ -18,12 +18,12 @@ import (
 // This is the standard implementation of the /flags stream for old server-side SDKs.
 
 type serverSideFlagsOnlyStreamProvider struct {
-	server    *eventsource.Server
+	server    *sseServer
 	closeOnce sync.Once
 }
 
 type serverSideFlagsOnlyEnvStreamProvider struct {
-	server   *eventsource.Server
+	server   *sseServer
 	channels []string
 }
 
@@ -82,6 +82,10 @@ func (e *serverSideFlagsOnlyEnvStreamProvider) SendHeartbeat() {
 	e.server.PublishComment(e.channels, "")
 }
 
+func (e *serverSideFlagsOnlyEnvStreamProvider) GetSubscriberCount() int {
+	return e.server.getSubscriberCount(e.channels)
+}
+
 func (e *serverSideFlagsOnlyEnvStreamProvider) Close() {
 	for _, key := range e.channels {
 		e.server.Unregister(key, true)
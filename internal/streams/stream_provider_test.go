@@ -39,7 +39,7 @@ func (e testEvent) Retry() int64 {
 	return 0
 }
 
-func verifyServerProperties(t *testing.T, server *eventsource.Server, maxConnTime time.Duration) {
+func verifyServerProperties(t *testing.T, server *sseServer, maxConnTime time.Duration) {
 	require.NotNil(t, server)
 	assert.False(t, server.Gzip)
 	assert.True(t, server.AllowCORS)
@@ -47,7 +47,7 @@ func verifyServerProperties(t *testing.T, server *eventsource.Server, maxConnTim
 	assert.Equal(t, maxConnTime, server.MaxConnTime)
 }
 
-func verifyHandlerGetsPublishedEvent(t *testing.T, sp StreamProvider, credential sdkauth.ScopedCredential, key string, server *eventsource.Server) {
+func verifyHandlerGetsPublishedEvent(t *testing.T, sp StreamProvider, credential sdkauth.ScopedCredential, key string, server *sseServer) {
 	handler := sp.Handler(credential)
 	require.NotNil(t, handler)
 
@@ -0,0 +1,273 @@
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v8/config"
+	"github.com/launchdarkly/ld-relay/v8/internal/changefeed"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	"github.com/launchdarkly/go-sdk-common/v3/ldtime"
+)
+
+const (
+	// EventHeader is the request header that contains the event type, "change" or "status".
+	EventHeader = "X-LD-Relay-Event"
+
+	// SignatureHeader is the request header that contains "sha256=" followed by the hex-encoded HMAC-SHA256
+	// of the request body, using the webhook's secret as the key. It is omitted if there is no secret.
+	SignatureHeader = "X-LD-Relay-Signature"
+
+	eventKindChange = "change"
+	eventKindStatus = "status"
+
+	queueSize            = 100
+	defaultRetryDelay    = time.Second
+	maxRetryDelay        = 30 * time.Second
+	maxResponseBodyBytes = 1024
+)
+
+// Event is the JSON body of a webhook request. Exactly one of Change and Status is set, depending on Kind.
+type Event struct {
+	Kind   string                     `json:"kind"`
+	Time   ldtime.UnixMillisecondTime `json:"time"`
+	Change *changefeed.Change         `json:"change,omitempty"`
+	Status *StatusChange              `json:"status,omitempty"`
+}
+
+// StatusChange describes a change in an environment's status, as reported by the status resource.
+type StatusChange struct {
+	// Environment is the environment's display name.
+	Environment string `json:"env"`
+
+	// Status is the environment's new status: "connected" or "disconnected".
+	Status string `json:"status"`
+
+	// PreviousStatus is the environment's previous status.
+	PreviousStatus string `json:"previousStatus"`
+
+	// DataStoreState is the new state of the environment's data store: "VALID" or "INTERRUPTED".
+	DataStoreState string `json:"dataStoreState,omitempty"`
+
+	// PreviousDataStoreState is the previous state of the environment's data store, if any.
+	PreviousDataStoreState string `json:"previousDataStoreState,omitempty"`
+}
+
+// Dispatcher sends events to all of the configured webhooks. Each webhook has its own queue and
+// goroutine, so a slow or unavailable endpoint does not delay the others or the caller; if a webhook's
+// queue is full, new events for it are dropped and a warning is logged.
+type Dispatcher struct {
+	webhooks []*webhook
+}
+
+type queuedEvent struct {
+	kind string
+	data []byte
+}
+
+type webhook struct {
+	name       string
+	url        string
+	secret     []byte
+	events     map[string]bool
+	flagKeys   []string
+	maxRetries int
+	client     *http.Client
+	retryDelay time.Duration
+	queue      chan queuedEvent
+	closeCh    chan struct{}
+	doneCh     chan struct{}
+	loggers    ldlog.Loggers
+}
+
+// NewDispatcher creates a Dispatcher and starts a goroutine for each webhook. The configuration is assumed
+// to have been validated already. The client is used for all requests, so that Relay's proxy settings apply.
+func NewDispatcher(webhooks map[string]*config.WebhookConfig, client *http.Client, loggers ldlog.Loggers) *Dispatcher {
+	return newDispatcher(webhooks, client, defaultRetryDelay, loggers)
+}
+
+func newDispatcher(
+	webhooks map[string]*config.WebhookConfig,
+	client *http.Client,
+	retryDelay time.Duration,
+	loggers ldlog.Loggers,
+) *Dispatcher {
+	names := make([]string, 0, len(webhooks))
+	for name := range webhooks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	d := &Dispatcher{}
+	for _, name := range names {
+		wc := webhooks[name]
+		w := &webhook{
+			name:       name,
+			url:        wc.URL.String(),
+			secret:     []byte(wc.Secret),
+			events:     make(map[string]bool),
+			flagKeys:   wc.FlagKeys.Values(),
+			maxRetries: wc.MaxRetries.GetOrElse(config.DefaultWebhookMaxRetries),
+			client:     client,
+			retryDelay: retryDelay,
+			queue:      make(chan queuedEvent, queueSize),
+			closeCh:    make(chan struct{}),
+			doneCh:     make(chan struct{}),
+			loggers:    loggers,
+		}
+		events := wc.Events.Values()
+		if len(events) == 0 {
+			events = []string{config.WebhookEventChanges, config.WebhookEventStatus}
+		}
+		for _, e := range events {
+			w.events[e] = true
+		}
+		d.webhooks = append(d.webhooks, w)
+		go w.run()
+	}
+	return d
+}
+
+// WantsStatus returns true if any webhook should be notified of environment status changes.
+func (d *Dispatcher) WantsStatus() bool {
+	for _, w := range d.webhooks {
+		if w.events[config.WebhookEventStatus] {
+			return true
+		}
+	}
+	return false
+}
+
+// NotifyChange queues a flag or segment change for every webhook that wants it. It does not block.
+func (d *Dispatcher) NotifyChange(change changefeed.Change) {
+	event := makeQueuedEvent(Event{Kind: eventKindChange, Time: change.Time, Change: &change})
+	for _, w := range d.webhooks {
+		if w.events[config.WebhookEventChanges] && w.matchesKey(change.Key) {
+			w.enqueue(event)
+		}
+	}
+}
+
+// NotifyStatus queues an environment status change for every webhook that wants it. It does not block.
+func (d *Dispatcher) NotifyStatus(status StatusChange) {
+	event := makeQueuedEvent(Event{Kind: eventKindStatus, Time: ldtime.UnixMillisNow(), Status: &status})
+	for _, w := range d.webhooks {
+		if w.events[config.WebhookEventStatus] {
+			w.enqueue(event)
+		}
+	}
+}
+
+// Close stops all of the webhook goroutines, discarding any events that have not been sent yet.
+func (d *Dispatcher) Close() {
+	for _, w := range d.webhooks {
+		close(w.closeCh)
+	}
+	for _, w := range d.webhooks {
+		<-w.doneCh
+	}
+}
+
+func makeQueuedEvent(event Event) queuedEvent {
+	data, _ := json.Marshal(event)
+	return queuedEvent{kind: event.Kind, data: data}
+}
+
+func (w *webhook) matchesKey(key string) bool {
+	if len(w.flagKeys) == 0 {
+		return true
+	}
+	for _, pattern := range w.flagKeys {
+		if matched, _ := path.Match(pattern, key); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *webhook) enqueue(event queuedEvent) {
+	select {
+	case <-w.closeCh:
+	case w.queue <- event:
+	default:
+		w.loggers.Warnf("Dropped an event for webhook %q because too many events were waiting to be sent", w.name)
+	}
+}
+
+func (w *webhook) run() {
+	defer close(w.doneCh)
+	for {
+		select {
+		case <-w.closeCh:
+			return
+		case event := <-w.queue:
+			w.deliver(event)
+		}
+	}
+}
+
+// deliver sends an event, retrying with exponential backoff if there is a network error or a response
+// that indicates a temporary problem.
+func (w *webhook) deliver(event queuedEvent) {
+	delay := w.retryDelay
+	for attempt := 0; ; attempt++ {
+		retryable, err := w.send(event)
+		if err == nil {
+			return
+		}
+		if !retryable || attempt >= w.maxRetries {
+			w.loggers.Errorf("Failed to send event to webhook %q: %s", w.name, err)
+			return
+		}
+		w.loggers.Warnf("Failed to send event to webhook %q (%s); will retry in %s", w.name, err, delay)
+		select {
+		case <-w.closeCh:
+			return
+		case <-time.After(delay):
+		}
+		delay *= 2
+		if delay > maxRetryDelay {
+			delay = maxRetryDelay
+		}
+	}
+}
+
+// send makes one attempt to send an event. If it fails, the returned bool says whether to retry.
+func (w *webhook) send(event queuedEvent) (bool, error) {
+	req, err := http.NewRequest("POST", w.url, bytes.NewReader(event.data))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, event.kind)
+	if len(w.secret) > 0 {
+		req.Header.Set(SignatureHeader, "sha256="+Sign(w.secret, event.data))
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseBodyBytes))
+	_ = resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	return resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests,
+		fmt.Errorf("HTTP error %d", resp.StatusCode)
+}
+
+// Sign returns the hex-encoded HMAC-SHA256 of a request body, as used in SignatureHeader.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	_, _ = mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,171 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v8/config"
+	"github.com/launchdarkly/ld-relay/v8/internal/changefeed"
+
+	ct "github.com/launchdarkly/go-configtypes"
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	"github.com/launchdarkly/go-test-helpers/v3/httphelpers"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func makeWebhookConfig(t *testing.T, url string) *config.WebhookConfig {
+	u, err := ct.NewOptURLAbsoluteFromString(url)
+	require.NoError(t, err)
+	return &config.WebhookConfig{URL: u}
+}
+
+func withDispatcher(
+	t *testing.T,
+	handler http.Handler,
+	configure func(*config.WebhookConfig),
+	action func(*Dispatcher, <-chan httphelpers.HTTPRequestInfo),
+) {
+	recordingHandler, requestsCh := httphelpers.RecordingHandler(handler)
+	httphelpers.WithServer(recordingHandler, func(server *httptest.Server) {
+		wc := makeWebhookConfig(t, server.URL)
+		if configure != nil {
+			configure(wc)
+		}
+		d := newDispatcher(map[string]*config.WebhookConfig{"hook": wc}, http.DefaultClient, time.Millisecond,
+			ldlog.NewDisabledLoggers())
+		defer d.Close()
+		action(d, requestsCh)
+	})
+}
+
+func requireRequest(t *testing.T, requestsCh <-chan httphelpers.HTTPRequestInfo) httphelpers.HTTPRequestInfo {
+	select {
+	case r := <-requestsCh:
+		return r
+	case <-time.After(time.Second):
+		require.Fail(t, "timed out waiting for webhook request")
+		return httphelpers.HTTPRequestInfo{}
+	}
+}
+
+func requireNoMoreRequests(t *testing.T, requestsCh <-chan httphelpers.HTTPRequestInfo) {
+	select {
+	case r := <-requestsCh:
+		require.Fail(t, "received unexpected webhook request", "%s", string(r.Body))
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestChangeEventIsSentWithSignature(t *testing.T) {
+	change := changefeed.Change{ID: 1, Time: 1000, Environment: "env1", Kind: "features", Key: "flag1",
+		OldVersion: 1, NewVersion: 2, Source: changefeed.SourcePatch}
+	withDispatcher(t, httphelpers.HandlerWithStatus(200),
+		func(wc *config.WebhookConfig) { wc.Secret = "secret" },
+		func(d *Dispatcher, requestsCh <-chan httphelpers.HTTPRequestInfo) {
+			d.NotifyChange(change)
+
+			r := requireRequest(t, requestsCh)
+			assert.Equal(t, "POST", r.Request.Method)
+			assert.Equal(t, "application/json", r.Request.Header.Get("Content-Type"))
+			assert.Equal(t, "change", r.Request.Header.Get(EventHeader))
+			assert.Equal(t, "sha256="+Sign([]byte("secret"), r.Body), r.Request.Header.Get(SignatureHeader))
+
+			var event Event
+			require.NoError(t, json.Unmarshal(r.Body, &event))
+			assert.Equal(t, Event{Kind: "change", Time: 1000, Change: &change}, event)
+		})
+}
+
+func TestStatusEventIsSentWithoutSignatureIfThereIsNoSecret(t *testing.T) {
+	status := StatusChange{Environment: "env1", Status: "disconnected", PreviousStatus: "connected"}
+	withDispatcher(t, httphelpers.HandlerWithStatus(200), nil,
+		func(d *Dispatcher, requestsCh <-chan httphelpers.HTTPRequestInfo) {
+			d.NotifyStatus(status)
+
+			r := requireRequest(t, requestsCh)
+			assert.Equal(t, "status", r.Request.Header.Get(EventHeader))
+			assert.Equal(t, "", r.Request.Header.Get(SignatureHeader))
+
+			var event Event
+			require.NoError(t, json.Unmarshal(r.Body, &event))
+			assert.Equal(t, "status", event.Kind)
+			assert.Equal(t, &status, event.Status)
+			assert.Nil(t, event.Change)
+		})
+}
+
+func TestEventsAreFilteredByType(t *testing.T) {
+	withDispatcher(t, httphelpers.HandlerWithStatus(200),
+		func(wc *config.WebhookConfig) { wc.Events = ct.NewOptStringList([]string{config.WebhookEventStatus}) },
+		func(d *Dispatcher, requestsCh <-chan httphelpers.HTTPRequestInfo) {
+			assert.True(t, d.WantsStatus())
+			d.NotifyChange(changefeed.Change{Key: "flag1"})
+			d.NotifyStatus(StatusChange{Environment: "env1"})
+
+			r := requireRequest(t, requestsCh)
+			assert.Equal(t, "status", r.Request.Header.Get(EventHeader))
+			requireNoMoreRequests(t, requestsCh)
+		})
+}
+
+func TestChangesAreFilteredByKeyPattern(t *testing.T) {
+	withDispatcher(t, httphelpers.HandlerWithStatus(200),
+		func(wc *config.WebhookConfig) { wc.FlagKeys = ct.NewOptStringList([]string{"billing-*", "exact-key"}) },
+		func(d *Dispatcher, requestsCh <-chan httphelpers.HTTPRequestInfo) {
+			for _, key := range []string{"other", "billing-flag", "exact-key-2", "exact-key"} {
+				d.NotifyChange(changefeed.Change{Key: key})
+			}
+
+			for _, expectedKey := range []string{"billing-flag", "exact-key"} {
+				var event Event
+				require.NoError(t, json.Unmarshal(requireRequest(t, requestsCh).Body, &event))
+				assert.Equal(t, expectedKey, event.Change.Key)
+			}
+			requireNoMoreRequests(t, requestsCh)
+		})
+}
+
+func TestRetriesAfterRecoverableError(t *testing.T) {
+	for _, status := range []int{429, 500, 503} {
+		t.Run(http.StatusText(status), func(t *testing.T) {
+			handler := httphelpers.SequentialHandler(httphelpers.HandlerWithStatus(status),
+				httphelpers.HandlerWithStatus(200))
+			withDispatcher(t, handler, nil, func(d *Dispatcher, requestsCh <-chan httphelpers.HTTPRequestInfo) {
+				d.NotifyStatus(StatusChange{Environment: "env1"})
+
+				r1 := requireRequest(t, requestsCh)
+				r2 := requireRequest(t, requestsCh)
+				assert.Equal(t, r1.Body, r2.Body)
+				requireNoMoreRequests(t, requestsCh)
+			})
+		})
+	}
+}
+
+func TestDoesNotRetryAfterUnrecoverableError(t *testing.T) {
+	withDispatcher(t, httphelpers.HandlerWithStatus(400), nil,
+		func(d *Dispatcher, requestsCh <-chan httphelpers.HTTPRequestInfo) {
+			d.NotifyStatus(StatusChange{Environment: "env1"})
+
+			requireRequest(t, requestsCh)
+			requireNoMoreRequests(t, requestsCh)
+		})
+}
+
+func TestStopsRetryingAfterMaxRetries(t *testing.T) {
+	withDispatcher(t, httphelpers.HandlerWithStatus(503),
+		func(wc *config.WebhookConfig) { wc.MaxRetries, _ = ct.NewOptIntGreaterThanZero(2) },
+		func(d *Dispatcher, requestsCh <-chan httphelpers.HTTPRequestInfo) {
+			d.NotifyStatus(StatusChange{Environment: "env1"})
+
+			for i := 0; i < 3; i++ { // the first attempt plus two retries
+				requireRequest(t, requestsCh)
+			}
+			requireNoMoreRequests(t, requestsCh)
+		})
+}
@@ -0,0 +1,3 @@
+// Package webhooks sends HTTP notifications about flag changes and environment status changes to
+// URLs that are specified in the Relay configuration.
+package webhooks
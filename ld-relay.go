@@ -8,8 +8,11 @@ import (
 	"github.com/launchdarkly/ld-relay/v8/config"
 	"github.com/launchdarkly/ld-relay/v8/internal/application"
 	"github.com/launchdarkly/ld-relay/v8/internal/logging"
+	"github.com/launchdarkly/ld-relay/v8/internal/migration"
 	"github.com/launchdarkly/ld-relay/v8/relay"
 	"github.com/launchdarkly/ld-relay/v8/relay/version"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
 )
 
 func main() {
@@ -30,6 +33,10 @@ func main() {
 		os.Exit(0)
 	}
 
+	if opts.Command == application.CommandMigrateStore {
+		os.Exit(migrateStore(opts, loggers))
+	}
+
 	loggers.Infof(
 		"Starting LaunchDarkly relay version %s with %s\n",
 		application.DescribeRelayVersion(version.Version),
@@ -49,6 +56,10 @@ func main() {
 		}
 	}
 
+	if c.Main.LogFormat == config.LogFormatJSON {
+		loggers = logging.MakeJSONLoggers()
+	}
+
 	r, err := relay.NewRelay(c, loggers, nil)
 	if err != nil {
 		loggers.Errorf("Unable to create relay: %s", err)
@@ -59,20 +70,46 @@ func main() {
 		os.Exit(0)
 	}
 
-	port := c.Main.Port.GetOrElse(config.DefaultPort)
+	addresses, err := application.GetListenAddresses(c.Main)
+	if err != nil {
+		loggers.Errorf("Invalid listen address: %s", err)
+		os.Exit(1)
+	}
 
-	_, errs := application.StartHTTPServer(
-		port,
+	_, errs := application.StartHTTPServerOnAddresses(
+		addresses,
 		r,
-		c.Main.TLSEnabled,
-		c.Main.TLSCert,
-		c.Main.TLSKey,
+		r.GetTLSCertificate,
 		c.Main.TLSMinVersion.Get(),
+		c.Main.TLSClientCA.Values(),
+		application.GetHTTP2Options(c.Main),
 		loggers,
 	)
 
 	for err := range errs {
-		loggers.Errorf("Error starting http listener on port: %d  %s", port, err)
+		loggers.Errorf("Error starting http listener: %s", err)
 		os.Exit(1)
 	}
 }
+
+func migrateStore(opts application.Options, loggers ldlog.Loggers) int {
+	var from, to config.Config
+	if err := config.LoadConfigFile(&from, opts.MigrateFromFile, loggers); err != nil {
+		loggers.Errorf("Error loading source config file: %s", err)
+		return 1
+	}
+	if err := config.LoadConfigFile(&to, opts.MigrateToFile, loggers); err != nil {
+		loggers.Errorf("Error loading destination config file: %s", err)
+		return 1
+	}
+
+	loggers.Infof("Copying data from the data store in %s to the data store in %s",
+		opts.MigrateFromFile, opts.MigrateToFile)
+	results, err := migration.MigrateStore(from, to, loggers)
+	if err != nil {
+		loggers.Errorf("Data store migration failed: %s", err)
+		return 1
+	}
+	loggers.Infof("Copied and verified data for %d environment(s)", len(results))
+	return 0
+}
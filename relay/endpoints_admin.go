@@ -0,0 +1,228 @@
+package relay
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/launchdarkly/ld-relay/v8/config"
+	"github.com/launchdarkly/ld-relay/v8/internal/api"
+	"github.com/launchdarkly/ld-relay/v8/internal/relayenv"
+	"github.com/launchdarkly/ld-relay/v8/internal/sdks"
+	"github.com/launchdarkly/ld-relay/v8/internal/store"
+	"github.com/launchdarkly/ld-relay/v8/internal/util"
+
+	"github.com/launchdarkly/go-jsonstream/v3/jwriter"
+	"github.com/launchdarkly/go-sdk-common/v3/ldtime"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoreimpl"
+
+	"github.com/gorilla/mux"
+)
+
+// The admin endpoints are only available if an admin key is configured, and they require that key in the
+// Authorization header; see makeRouter. Environments are identified by the same names that are used in
+// the status resource.
+
+func adminEnvironmentHandler(relay *Relay, handler func(http.ResponseWriter, *http.Request, relayenv.EnvContext)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		env, ok := relay.getEnvironmentByStatusKey(mux.Vars(req)["envName"])
+		if !ok {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write(util.ErrorJSONMsg("Unknown environment"))
+			return
+		}
+		handler(w, req, env)
+	})
+}
+
+func listEnvironmentsHandler(relay *Relay) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		reps := make([]api.AdminEnvironmentRep, 0)
+		for _, env := range relay.getAllEnvironments() {
+			reps = append(reps, makeAdminEnvironmentRep(relay.getEnvironmentStatusKey(env), env))
+		}
+		sort.Slice(reps, func(i, j int) bool { return reps[i].Name < reps[j].Name })
+		w.Header().Set("Content-Type", "application/json")
+		data, _ := json.Marshal(reps)
+		_, _ = w.Write(data)
+	})
+}
+
+func makeAdminEnvironmentRep(name string, env relayenv.EnvContext) api.AdminEnvironmentRep {
+	identifiers := env.GetIdentifiers()
+	rep := api.AdminEnvironmentRep{
+		Name:         name,
+		EnvKey:       identifiers.EnvKey,
+		EnvName:      identifiers.EnvName,
+		ProjKey:      identifiers.ProjKey,
+		ProjName:     identifiers.ProjName,
+		FilterKey:    string(env.GetPayloadFilter()),
+		CreationTime: ldtime.UnixMillisFromTime(env.GetCreationTime()),
+	}
+	for _, c := range env.GetCredentials() {
+		switch c := c.(type) {
+		case config.SDKKey:
+			rep.SDKKey = sdks.ObscureKey(string(c))
+		case config.MobileKey:
+			rep.MobileKey = sdks.ObscureKey(string(c))
+		case config.EnvironmentID:
+			rep.EnvID = string(c)
+		}
+	}
+	for _, c := range env.GetDeprecatedCredentials() {
+		if key, ok := c.(config.SDKKey); ok {
+			rep.ExpiringSDKKey = sdks.ObscureKey(string(key))
+		}
+	}
+	return rep
+}
+
+// getEnvironmentDataHandler returns all of the flags and segments in the environment's data store, including
+// placeholders for deleted items, so that their versions can be compared with what LaunchDarkly has.
+func getEnvironmentDataHandler(w http.ResponseWriter, req *http.Request, env relayenv.EnvContext) {
+	w.Header().Set("Content-Type", "application/json")
+	dataStore := env.GetStore()
+	if dataStore == nil || !dataStore.IsInitialized() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write(util.ErrorJSONMsg("Environment data is not available yet"))
+		return
+	}
+	flags, err := getAllFromStore(env, dataStore, ldstoreimpl.Features())
+	if err != nil {
+		writeDataStoreReadError(w, env, err)
+		return
+	}
+	segments, err := getAllFromStore(env, dataStore, ldstoreimpl.Segments())
+	if err != nil {
+		writeDataStoreReadError(w, env, err)
+		return
+	}
+	respWriter := jwriter.NewWriter()
+	respObj := respWriter.Object()
+	writeItemsAsMap(respObj.Name("flags"), flags)
+	writeItemsAsMap(respObj.Name("segments"), segments)
+	respObj.End()
+	_, _ = w.Write(respWriter.Bytes())
+}
+
+func writeDataStoreReadError(w http.ResponseWriter, env relayenv.EnvContext, err error) {
+	env.GetLoggers().Errorf("Error reading feature store: %s", err)
+	w.WriteHeader(http.StatusInternalServerError)
+	_, _ = w.Write(util.ErrorJSONMsgf("Unable to read data store: %s", err))
+}
+
+// getEnvironmentStreamsHandler returns the number of connected stream subscribers for each kind of stream.
+func getEnvironmentStreamsHandler(relay *Relay) func(http.ResponseWriter, *http.Request, relayenv.EnvContext) {
+	return func(w http.ResponseWriter, req *http.Request, env relayenv.EnvContext) {
+		counts := make(map[string]int)
+		for sp, count := range env.GetStreamSubscriberCounts() {
+			counts[string(relay.getStreamKind(sp))] += count
+		}
+		w.Header().Set("Content-Type", "application/json")
+		data, _ := json.Marshal(counts)
+		_, _ = w.Write(data)
+	}
+}
+
+func resyncEnvironmentHandler(w http.ResponseWriter, req *http.Request, env relayenv.EnvContext) {
+	if err := env.RestartClient(); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, relayenv.ErrClientRestartInProgress) {
+			status = http.StatusConflict
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_, _ = w.Write(util.ErrorJSONMsgf("Unable to resynchronize environment: %s", err))
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func resyncBigSegmentsHandler(w http.ResponseWriter, req *http.Request, env relayenv.EnvContext) {
+	if err := env.ResyncBigSegments(); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write(util.ErrorJSONMsgf("Unable to resynchronize big segments: %s", err))
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// getChangesHandler returns the flag and segment changes in the change feed buffer, oldest first. The
+// optional "after" parameter is the ID of the last change that the caller has already seen, and the
+// optional "env" parameter selects changes for one environment.
+func getChangesHandler(relay *Relay) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		var afterID uint64
+		if after := req.URL.Query().Get("after"); after != "" {
+			var err error
+			if afterID, err = strconv.ParseUint(after, 10, 64); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write(util.ErrorJSONMsg("Invalid change ID"))
+				return
+			}
+		}
+		changes := relay.changeFeed.GetChanges(afterID)
+		if envName := req.URL.Query().Get("env"); envName != "" {
+			filtered := changes[:0]
+			for _, change := range changes {
+				if change.Environment == envName {
+					filtered = append(filtered, change)
+				}
+			}
+			changes = filtered
+		}
+		data, _ := json.Marshal(changes)
+		_, _ = w.Write(data)
+	})
+}
+
+func getStoreConsistencyHandler(w http.ResponseWriter, req *http.Request, env relayenv.EnvContext) {
+	w.Header().Set("Content-Type", "application/json")
+	result, ok := env.GetDataStoreConsistency()
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write(util.ErrorJSONMsg("No data store consistency check has been done for this environment"))
+		return
+	}
+	writeStoreConsistencyResult(w, result)
+}
+
+func checkStoreConsistencyHandler(w http.ResponseWriter, req *http.Request, env relayenv.EnvContext) {
+	w.Header().Set("Content-Type", "application/json")
+	result, err := env.CheckDataStoreConsistency(req.URL.Query().Get("repair") == "true")
+	if err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write(util.ErrorJSONMsgf("Unable to check data store consistency: %s", err))
+		return
+	}
+	writeStoreConsistencyResult(w, result)
+}
+
+func writeStoreConsistencyResult(w http.ResponseWriter, result store.ConsistencyCheckResult) {
+	rep := api.DataStoreConsistencyCheckRep{
+		Time:         ldtime.UnixMillisFromTime(result.Time),
+		MissingItems: makeInconsistentItemReps(result.MissingItems),
+		StaleItems:   makeInconsistentItemReps(result.StaleItems),
+		Repaired:     result.Repaired,
+	}
+	data, _ := json.Marshal(rep)
+	_, _ = w.Write(data)
+}
+
+func makeInconsistentItemReps(items []store.InconsistentItem) []api.InconsistentItemRep {
+	reps := make([]api.InconsistentItemRep, 0, len(items))
+	for _, item := range items {
+		reps = append(reps, api.InconsistentItemRep{
+			Kind:            item.Kind,
+			Key:             item.Key,
+			ExpectedVersion: item.ExpectedVersion,
+			ActualVersion:   item.ActualVersion,
+		})
+	}
+	return reps
+}
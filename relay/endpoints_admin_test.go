@@ -0,0 +1,266 @@
+package relay
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	c "github.com/launchdarkly/ld-relay/v8/config"
+	"github.com/launchdarkly/ld-relay/v8/internal/api"
+	"github.com/launchdarkly/ld-relay/v8/internal/changefeed"
+	"github.com/launchdarkly/ld-relay/v8/internal/sdkauth"
+	"github.com/launchdarkly/ld-relay/v8/internal/sdks"
+	st "github.com/launchdarkly/ld-relay/v8/internal/sharedtest"
+	"github.com/launchdarkly/ld-relay/v8/internal/sharedtest/testclient"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	"github.com/launchdarkly/go-sdk-common/v3/ldvalue"
+	"github.com/launchdarkly/go-server-sdk-evaluation/v3/ldbuilders"
+	ld "github.com/launchdarkly/go-server-sdk/v7"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoreimpl"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoretypes"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testAdminKey = "admin-key"
+
+func makeAdminRequest(method, path, adminKey string) *http.Request {
+	r, _ := http.NewRequest(method, "http://localhost/admin"+path, nil)
+	if adminKey != "" {
+		r.Header.Set("Authorization", adminKey)
+	}
+	return r
+}
+
+func TestAdminEndpointsAreNotAvailableWithoutAdminKey(t *testing.T) {
+	var config c.Config
+	config.Environment = st.MakeEnvConfigs(st.EnvMain)
+
+	withStartedRelay(t, config, func(p relayTestParams) {
+		path := "/environments/" + url.PathEscape(st.EnvMain.Name) + "/store/consistency"
+		result, _ := st.DoRequest(makeAdminRequest("GET", path, ""), p.relay)
+		assert.Equal(t, http.StatusNotFound, result.StatusCode)
+	})
+}
+
+func TestAdminStoreConsistencyEndpoints(t *testing.T) {
+	var config c.Config
+	config.Main.AdminKey = testAdminKey
+	config.Environment = st.MakeEnvConfigs(st.EnvMain)
+	path := "/environments/" + url.PathEscape(st.EnvMain.Name) + "/store/consistency"
+
+	withStartedRelay(t, config, func(p relayTestParams) {
+		t.Run("requires admin key", func(t *testing.T) {
+			for _, key := range []string{"", "wrong-key"} {
+				result, _ := st.DoRequest(makeAdminRequest("GET", path, key), p.relay)
+				assert.Equal(t, http.StatusUnauthorized, result.StatusCode)
+			}
+		})
+
+		t.Run("unknown environment", func(t *testing.T) {
+			result, _ := st.DoRequest(makeAdminRequest("GET", "/environments/unknown/store/consistency", testAdminKey), p.relay)
+			assert.Equal(t, http.StatusNotFound, result.StatusCode)
+		})
+
+		t.Run("no check has been done", func(t *testing.T) {
+			result, _ := st.DoRequest(makeAdminRequest("GET", path, testAdminKey), p.relay)
+			assert.Equal(t, http.StatusNotFound, result.StatusCode)
+		})
+
+		t.Run("checks are not possible without a persistent store", func(t *testing.T) {
+			result, body := st.DoRequest(makeAdminRequest("POST", path, testAdminKey), p.relay)
+			assert.Equal(t, http.StatusServiceUnavailable, result.StatusCode)
+			assert.Contains(t, ldvalue.Parse(body).GetByKey("message").StringValue(), "not enabled")
+		})
+	})
+}
+
+func TestAdminListEnvironmentsEndpoint(t *testing.T) {
+	var config c.Config
+	config.Main.AdminKey = testAdminKey
+	config.Environment = st.MakeEnvConfigs(st.EnvMain, st.EnvMobile)
+
+	withStartedRelay(t, config, func(p relayTestParams) {
+		result, body := st.DoRequest(makeAdminRequest("GET", "/environments", testAdminKey), p.relay)
+		assert.Equal(t, http.StatusOK, result.StatusCode)
+
+		var reps []api.AdminEnvironmentRep
+		require.NoError(t, json.Unmarshal(body, &reps))
+		require.Len(t, reps, 2)
+		assert.Equal(t, st.EnvMobile.Name, reps[0].Name)
+		assert.Equal(t, sdks.ObscureKey(string(st.EnvMobile.Config.SDKKey)), reps[0].SDKKey)
+		assert.Equal(t, sdks.ObscureKey(string(st.EnvMobile.Config.MobileKey)), reps[0].MobileKey)
+		assert.NotZero(t, reps[0].CreationTime)
+		assert.Equal(t, st.EnvMain.Name, reps[1].Name)
+		assert.Equal(t, sdks.ObscureKey(string(st.EnvMain.Config.SDKKey)), reps[1].SDKKey)
+		assert.Empty(t, reps[1].MobileKey)
+	})
+}
+
+func TestAdminEnvironmentEndpoints(t *testing.T) {
+	var config c.Config
+	config.Main.AdminKey = testAdminKey
+	config.Environment = st.MakeEnvConfigs(st.EnvMain)
+	basePath := "/environments/" + url.PathEscape(st.EnvMain.Name)
+
+	withStartedRelay(t, config, func(p relayTestParams) {
+		t.Run("unknown environment", func(t *testing.T) {
+			for _, path := range []string{"/data", "/streams"} {
+				result, _ := st.DoRequest(makeAdminRequest("GET", "/environments/unknown"+path, testAdminKey), p.relay)
+				assert.Equal(t, http.StatusNotFound, result.StatusCode)
+			}
+		})
+
+		t.Run("data", func(t *testing.T) {
+			result, body := st.DoRequest(makeAdminRequest("GET", basePath+"/data", testAdminKey), p.relay)
+			assert.Equal(t, http.StatusOK, result.StatusCode)
+			data := ldvalue.Parse(body)
+			assert.Equal(t, len(st.AllFlags), data.GetByKey("flags").Count())
+			assert.Equal(t, ldvalue.Int(st.Flag1ServerSide.Flag.Version),
+				data.GetByKey("flags").GetByKey(st.Flag1ServerSide.Flag.Key).GetByKey("version"))
+			assert.Equal(t, []string{st.Segment1.Key}, data.GetByKey("segments").Keys(nil))
+		})
+
+		t.Run("streams", func(t *testing.T) {
+			result, body := st.DoRequest(makeAdminRequest("GET", basePath+"/streams", testAdminKey), p.relay)
+			assert.Equal(t, http.StatusOK, result.StatusCode)
+			assert.JSONEq(t, `{"server": 0, "server-flags": 0, "mobile-ping": 0, "js-ping": 0}`, string(body))
+		})
+
+		t.Run("resync", func(t *testing.T) {
+			result, _ := st.DoRequest(makeAdminRequest("POST", basePath+"/resync", testAdminKey), p.relay)
+			assert.Equal(t, http.StatusAccepted, result.StatusCode)
+		})
+
+		t.Run("big segments resync is not possible without a big segment store", func(t *testing.T) {
+			result, body := st.DoRequest(makeAdminRequest("POST", basePath+"/big-segments/resync", testAdminKey), p.relay)
+			assert.Equal(t, http.StatusBadRequest, result.StatusCode)
+			assert.Contains(t, ldvalue.Parse(body).GetByKey("message").StringValue(), "not configured")
+		})
+	})
+}
+
+func TestAdminResyncEndpointWhileResyncIsInProgress(t *testing.T) {
+	var config c.Config
+	config.Main.AdminKey = testAdminKey
+	config.Environment = st.MakeEnvConfigs(st.EnvMain)
+	path := "/environments/" + url.PathEscape(st.EnvMain.Name) + "/resync"
+
+	// The first client is created normally, but the one created by the resync waits until we let it finish.
+	gateCh := make(chan struct{})
+	var calls atomic.Int32
+	clientFactory := func(sdkKey c.SDKKey, config ld.Config, timeout time.Duration) (sdks.LDClientContext, error) {
+		if calls.Add(1) > 1 {
+			<-gateCh
+		}
+		return testclient.CreateDummyClient(sdkKey, config, timeout)
+	}
+	relay, err := newRelayInternal(config, relayInternalOptions{
+		clientFactory: clientFactory,
+		loggers:       ldlog.NewDisabledLoggers(),
+	})
+	require.NoError(t, err)
+	defer relay.Close()
+	defer close(gateCh)
+	require.NoError(t, relay.waitForAllClients(time.Second))
+
+	result, _ := st.DoRequest(makeAdminRequest("POST", path, testAdminKey), relay)
+	assert.Equal(t, http.StatusAccepted, result.StatusCode)
+
+	result, body := st.DoRequest(makeAdminRequest("POST", path, testAdminKey), relay)
+	assert.Equal(t, http.StatusConflict, result.StatusCode)
+	assert.Contains(t, ldvalue.Parse(body).GetByKey("message").StringValue(), "already being restarted")
+}
+
+func TestAdminChangesEndpoint(t *testing.T) {
+	var config c.Config
+	config.Main.AdminKey = testAdminKey
+	config.Environment = st.MakeEnvConfigs(st.EnvMain)
+
+	withStartedRelay(t, config, func(p relayTestParams) {
+		// The initial data that Relay received is not reported as changes.
+		result, body := st.DoRequest(makeAdminRequest("GET", "/changes", testAdminKey), p.relay)
+		assert.Equal(t, http.StatusOK, result.StatusCode)
+		assert.JSONEq(t, `[]`, string(body))
+
+		env, _ := p.relay.getEnvironment(sdkauth.New(st.EnvMain.Config.SDKKey))
+		newFlag := ldbuilders.NewFlagBuilder(st.Flag1ServerSide.Flag.Key).Version(st.Flag1ServerSide.Flag.Version + 1).Build()
+		_, err := env.GetStore().Upsert(ldstoreimpl.Features(), newFlag.Key,
+			ldstoretypes.ItemDescriptor{Version: newFlag.Version, Item: &newFlag})
+		require.NoError(t, err)
+
+		result, body = st.DoRequest(makeAdminRequest("GET", "/changes", testAdminKey), p.relay)
+		assert.Equal(t, http.StatusOK, result.StatusCode)
+		var changes []changefeed.Change
+		require.NoError(t, json.Unmarshal(body, &changes))
+		require.Len(t, changes, 1)
+		assert.Equal(t, st.EnvMain.Name, changes[0].Environment)
+		assert.Equal(t, newFlag.Key, changes[0].Key)
+		assert.Equal(t, st.Flag1ServerSide.Flag.Version, changes[0].OldVersion)
+		assert.Equal(t, newFlag.Version, changes[0].NewVersion)
+		assert.Equal(t, changefeed.SourcePatch, changes[0].Source)
+
+		for _, query := range []string{"?after=1", "?env=unknown"} {
+			_, body = st.DoRequest(makeAdminRequest("GET", "/changes"+query, testAdminKey), p.relay)
+			assert.JSONEq(t, `[]`, string(body), query)
+		}
+		_, body = st.DoRequest(makeAdminRequest("GET", "/changes?env="+url.QueryEscape(st.EnvMain.Name), testAdminKey), p.relay)
+		require.NoError(t, json.Unmarshal(body, &changes))
+		assert.Len(t, changes, 1)
+
+		result, _ = st.DoRequest(makeAdminRequest("GET", "/changes?after=x", testAdminKey), p.relay)
+		assert.Equal(t, http.StatusBadRequest, result.StatusCode)
+	})
+}
+
+func TestAdminMetricsEndpoint(t *testing.T) {
+	var config c.Config
+	config.Main.AdminKey = testAdminKey
+	config.Environment = st.MakeEnvConfigs(st.EnvMain)
+	config.MetricsConfig.Prometheus = c.PrometheusConfig{Enabled: true, Native: true, UseAdminEndpoint: true}
+
+	withStartedRelay(t, config, func(p relayTestParams) {
+		t.Run("requires admin key", func(t *testing.T) {
+			result, _ := st.DoRequest(makeAdminRequest("GET", "/metrics", ""), p.relay)
+			assert.Equal(t, http.StatusUnauthorized, result.StatusCode)
+		})
+
+		t.Run("reports environment gauges", func(t *testing.T) {
+			result, body := st.DoRequest(makeAdminRequest("GET", "/metrics", testAdminKey), p.relay)
+			require.Equal(t, http.StatusOK, result.StatusCode)
+			assert.Contains(t, string(body),
+				`launchdarkly_relay_environment_data_source_state{env="`+st.EnvMain.Name+`",state="VALID"} 1`)
+			assert.Contains(t, string(body),
+				`launchdarkly_relay_environment_data_store_available{env="`+st.EnvMain.Name+`"} 1`)
+		})
+
+		t.Run("reports request metrics", func(t *testing.T) {
+			sdkResult, _ := st.DoRequest(
+				st.BuildRequestWithAuth("GET", "http://localhost/sdk/flags", st.EnvMain.Config.SDKKey, nil), p.relay)
+			require.Equal(t, http.StatusOK, sdkResult.StatusCode)
+
+			result, body := st.DoRequest(makeAdminRequest("GET", "/metrics", testAdminKey), p.relay)
+			require.Equal(t, http.StatusOK, result.StatusCode)
+			assert.Contains(t, string(body),
+				`launchdarkly_relay_requests{env="`+st.EnvMain.Name+`",method="GET",platformCategory="server",route="_sdk_flags"`)
+			assert.Contains(t, string(body),
+				`launchdarkly_relay_request_duration_count{env="`+st.EnvMain.Name+`",method="GET",platformCategory="server",route="_sdk_flags",statusCode="200"} 1`)
+		})
+	})
+}
+
+func TestAdminMetricsEndpointIsNotAvailableWithoutUseAdminEndpoint(t *testing.T) {
+	var config c.Config
+	config.Main.AdminKey = testAdminKey
+	config.Environment = st.MakeEnvConfigs(st.EnvMain)
+
+	withStartedRelay(t, config, func(p relayTestParams) {
+		result, _ := st.DoRequest(makeAdminRequest("GET", "/metrics", testAdminKey), p.relay)
+		assert.Equal(t, http.StatusNotFound, result.StatusCode)
+	})
+}
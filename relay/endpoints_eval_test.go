@@ -6,15 +6,20 @@ import (
 	"testing"
 
 	c "github.com/launchdarkly/ld-relay/v8/config"
+	"github.com/launchdarkly/ld-relay/v8/internal/sdkauth"
 	st "github.com/launchdarkly/ld-relay/v8/internal/sharedtest"
 	"github.com/launchdarkly/ld-relay/v8/internal/sharedtest/testclient"
 
 	"github.com/launchdarkly/go-sdk-common/v3/ldcontext"
 	"github.com/launchdarkly/go-sdk-common/v3/lduser"
+	"github.com/launchdarkly/go-server-sdk-evaluation/v3/ldbuilders"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoreimpl"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoretypes"
 	"github.com/launchdarkly/go-test-helpers/v3/jsonhelpers"
 	m "github.com/launchdarkly/go-test-helpers/v3/matchers"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // These user and context representations are designed to be equivalent in terms of the test flags
@@ -259,3 +264,67 @@ func TestEndpointsEvalJSClient(t *testing.T) {
 		}
 	})
 }
+
+func TestEndpointsEvalConditionalRequests(t *testing.T) {
+	env := st.EnvMain
+	sdkKey := env.Config.SDKKey
+	otherContextJSON := jsonhelpers.ToJSON(ldcontext.NewBuilder("other-key").SetString("a", "1").SetString("b", "2").Build())
+
+	makeRequest := func(path string, contextJSON []byte, ifNoneMatch string) *http.Request {
+		spec := endpointMultiTestParams{"", "REPORT", path, sdkKey,
+			makeEndpointTestPerRequestParams(contextJSON, contextJSON, st.ExpectJSONBody(st.MakeEvalBody(st.AllFlags, false)))}
+		req := spec.request(spec.requests[0])
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		return req
+	}
+
+	var config c.Config
+	config.Environment = st.MakeEnvConfigs(env)
+
+	withStartedRelay(t, config, func(p relayTestParams) {
+		result, _ := st.DoRequest(makeRequest("/sdk/evalx/context", basicContextJSON, ""), p.relay)
+		require.Equal(t, http.StatusOK, result.StatusCode)
+		etag := result.Header.Get("Etag")
+		require.NotEqual(t, "", etag)
+
+		t.Run("same context and same data", func(t *testing.T) {
+			result, body := st.DoRequest(makeRequest("/sdk/evalx/context", basicContextJSON, etag), p.relay)
+			assert.Equal(t, http.StatusNotModified, result.StatusCode)
+			assert.Equal(t, etag, result.Header.Get("Etag"))
+			assert.Len(t, body, 0)
+		})
+
+		t.Run("etag does not depend on attribute order", func(t *testing.T) {
+			first, _ := st.DoRequest(makeRequest("/sdk/evalx/context", otherContextJSON, ""), p.relay)
+			for i := 0; i < 10; i++ {
+				result, _ := st.DoRequest(makeRequest("/sdk/evalx/context", otherContextJSON, ""), p.relay)
+				assert.Equal(t, first.Header.Get("Etag"), result.Header.Get("Etag"))
+			}
+		})
+
+		t.Run("different context", func(t *testing.T) {
+			result, _ := st.DoRequest(makeRequest("/sdk/evalx/context", otherContextJSON, etag), p.relay)
+			assert.Equal(t, http.StatusOK, result.StatusCode)
+			assert.NotEqual(t, etag, result.Header.Get("Etag"))
+		})
+
+		t.Run("with reasons", func(t *testing.T) {
+			result, _ := st.DoRequest(makeRequest("/sdk/evalx/context?withReasons=true", basicContextJSON, etag), p.relay)
+			assert.Equal(t, http.StatusOK, result.StatusCode)
+		})
+
+		t.Run("data has changed", func(t *testing.T) {
+			envContext, _ := p.relay.getEnvironment(sdkauth.New(sdkKey))
+			newFlag := ldbuilders.NewFlagBuilder(st.Flag1ServerSide.Flag.Key).Version(st.Flag1ServerSide.Flag.Version + 1).Build()
+			_, err := envContext.GetStore().Upsert(ldstoreimpl.Features(), newFlag.Key,
+				ldstoretypes.ItemDescriptor{Version: newFlag.Version, Item: &newFlag})
+			require.NoError(t, err)
+
+			result, _ := st.DoRequest(makeRequest("/sdk/evalx/context", basicContextJSON, etag), p.relay)
+			assert.Equal(t, http.StatusOK, result.StatusCode)
+			assert.NotEqual(t, etag, result.Header.Get("Etag"))
+		})
+	})
+}
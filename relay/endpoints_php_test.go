@@ -1,16 +1,23 @@
 package relay
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 	"testing"
 
 	c "github.com/launchdarkly/ld-relay/v8/config"
+	"github.com/launchdarkly/ld-relay/v8/internal/sdkauth"
 	st "github.com/launchdarkly/ld-relay/v8/internal/sharedtest"
 
+	"github.com/launchdarkly/go-server-sdk-evaluation/v3/ldbuilders"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoreimpl"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoretypes"
 	m "github.com/launchdarkly/go-test-helpers/v3/matchers"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestEndpointsPHPPolling(t *testing.T) {
@@ -46,7 +53,7 @@ func TestEndpointsPHPPolling(t *testing.T) {
 						if assert.Equal(t, s.expectedStatus, result.StatusCode) {
 							st.AssertNonStreamingHeaders(t, result.Header)
 							m.In(t).Assert(body, s.bodyMatcher)
-							etag := result.Header.Get("Etag")
+							etag = result.Header.Get("Etag")
 							assert.NotEqual(t, "", etag)
 						}
 					})
@@ -83,3 +90,130 @@ func TestEndpointsPHPPolling(t *testing.T) {
 		}
 	})
 }
+
+func TestEndpointsPHPPollingAllFlagsEtagChangesWhenDataChanges(t *testing.T) {
+	spec := endpointTestParams{"get all flags", "GET", "/sdk/flags", nil, st.EnvMain.Config.SDKKey,
+		http.StatusOK, st.ExpectJSONEntity(st.FlagsMap(st.AllFlags))}
+
+	var config c.Config
+	config.Environment = st.MakeEnvConfigs(st.EnvMain)
+
+	withStartedRelay(t, config, func(p relayTestParams) {
+		result, _ := st.DoRequest(spec.request(), p.relay)
+		require.Equal(t, http.StatusOK, result.StatusCode)
+		etag := result.Header.Get("Etag")
+		require.NotEqual(t, "", etag)
+
+		req := spec.request()
+		req.Header.Set("If-None-Match", etag)
+		result, body := st.DoRequest(req, p.relay)
+		assert.Equal(t, http.StatusNotModified, result.StatusCode)
+		assert.Equal(t, etag, result.Header.Get("Etag"))
+		assert.Len(t, body, 0)
+
+		env, _ := p.relay.getEnvironment(sdkauth.New(st.EnvMain.Config.SDKKey))
+		newFlag := ldbuilders.NewFlagBuilder(st.Flag1ServerSide.Flag.Key).Version(st.Flag1ServerSide.Flag.Version + 1).Build()
+		_, err := env.GetStore().Upsert(ldstoreimpl.Features(), newFlag.Key,
+			ldstoretypes.ItemDescriptor{Version: newFlag.Version, Item: &newFlag})
+		require.NoError(t, err)
+
+		req = spec.request()
+		req.Header.Set("If-None-Match", etag)
+		result, _ = st.DoRequest(req, p.relay)
+		assert.Equal(t, http.StatusOK, result.StatusCode)
+		assert.NotEqual(t, etag, result.Header.Get("Etag"))
+	})
+}
+
+func TestEndpointsPHPPollingChanges(t *testing.T) {
+	sdkKey := st.EnvMain.Config.SDKKey
+
+	type changesResponse struct {
+		Version  string                     `json:"version"`
+		Full     bool                       `json:"full"`
+		Flags    map[string]json.RawMessage `json:"flags"`
+		Segments map[string]json.RawMessage `json:"segments"`
+	}
+	getChanges := func(t *testing.T, p relayTestParams, since string) changesResponse {
+		req := st.BuildRequestWithAuth("GET", "http://localhost/sdk/changes?since="+url.QueryEscape(since), sdkKey, nil)
+		result, body := st.DoRequest(req, p.relay)
+		require.Equal(t, http.StatusOK, result.StatusCode)
+		st.AssertNonStreamingHeaders(t, result.Header)
+		var resp changesResponse
+		require.NoError(t, json.Unmarshal(body, &resp))
+		return resp
+	}
+
+	var config c.Config
+	config.Environment = st.MakeEnvConfigs(st.EnvMain)
+
+	withStartedRelay(t, config, func(p relayTestParams) {
+		full := getChanges(t, p, "")
+		assert.True(t, full.Full)
+		assert.NotEqual(t, "", full.Version)
+		assert.Len(t, full.Flags, len(st.AllFlags))
+		assert.Contains(t, full.Segments, st.Segment1.Key)
+
+		t.Run("no changes", func(t *testing.T) {
+			resp := getChanges(t, p, full.Version)
+			assert.False(t, resp.Full)
+			assert.Equal(t, full.Version, resp.Version)
+			assert.Len(t, resp.Flags, 0)
+			assert.Len(t, resp.Segments, 0)
+		})
+
+		t.Run("updated and deleted items", func(t *testing.T) {
+			env, _ := p.relay.getEnvironment(sdkauth.New(sdkKey))
+			newFlag := ldbuilders.NewFlagBuilder(st.Flag1ServerSide.Flag.Key).Version(st.Flag1ServerSide.Flag.Version + 1).Build()
+			_, err := env.GetStore().Upsert(ldstoreimpl.Features(), newFlag.Key,
+				ldstoretypes.ItemDescriptor{Version: newFlag.Version, Item: &newFlag})
+			require.NoError(t, err)
+			_, err = env.GetStore().Upsert(ldstoreimpl.Segments(), st.Segment1.Key,
+				ldstoretypes.ItemDescriptor{Version: st.Segment1.Version + 1, Item: nil})
+			require.NoError(t, err)
+
+			resp := getChanges(t, p, full.Version)
+			assert.False(t, resp.Full)
+			assert.NotEqual(t, full.Version, resp.Version)
+			m.In(t).Assert(resp.Flags[newFlag.Key], m.JSONEqual(newFlag))
+			assert.Len(t, resp.Flags, 1)
+			m.In(t).Assert(resp.Segments[st.Segment1.Key], m.JSONStrEqual(
+				fmt.Sprintf(`{"key":%q,"version":%d,"deleted":true}`, st.Segment1.Key, st.Segment1.Version+1)))
+
+			full := getChanges(t, p, "")
+			assert.True(t, full.Full)
+			assert.Len(t, full.Flags, len(st.AllFlags))
+			assert.NotContains(t, full.Segments, st.Segment1.Key)
+		})
+
+		t.Run("unknown version", func(t *testing.T) {
+			resp := getChanges(t, p, "not-a-version")
+			assert.True(t, resp.Full)
+			assert.Len(t, resp.Flags, len(st.AllFlags))
+		})
+	})
+}
+
+func TestEndpointsPHPPollingAllFlagsEtagIsSameOnEveryRelayWithSameData(t *testing.T) {
+	spec := endpointTestParams{"get all flags", "GET", "/sdk/flags", nil, st.EnvMain.Config.SDKKey,
+		http.StatusOK, st.ExpectJSONEntity(st.FlagsMap(st.AllFlags))}
+
+	var config c.Config
+	config.Environment = st.MakeEnvConfigs(st.EnvMain)
+
+	withStartedRelay(t, config, func(p1 relayTestParams) {
+		result1, _ := st.DoRequest(spec.request(), p1.relay)
+		require.Equal(t, http.StatusOK, result1.StatusCode)
+		etag := result1.Header.Get("Etag")
+		require.NotEqual(t, "", etag)
+
+		withStartedRelay(t, config, func(p2 relayTestParams) {
+			req := spec.request()
+			req.Header.Set("If-None-Match", etag)
+			result2, body := st.DoRequest(req, p2.relay)
+			assert.Equal(t, http.StatusNotModified, result2.StatusCode)
+			assert.Equal(t, etag, result2.Header.Get("Etag"))
+			assert.Len(t, body, 0)
+		})
+	})
+}
@@ -2,12 +2,13 @@ package relay
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"sort"
 	"time"
 
 	"github.com/launchdarkly/ld-relay/v8/config"
 	"github.com/launchdarkly/ld-relay/v8/internal/api"
-	"github.com/launchdarkly/ld-relay/v8/internal/relayenv"
 	"github.com/launchdarkly/ld-relay/v8/internal/sdks"
 
 	"github.com/launchdarkly/go-sdk-common/v3/ldtime"
@@ -20,135 +21,246 @@ const (
 	statusEnvDisconnected = "disconnected"
 	statusRelayHealthy    = "healthy"
 	statusRelayDegraded   = "degraded"
+
+	degradedKindDataSource     = "dataSource"
+	degradedKindDataStore      = "dataStore"
+	degradedKindBigSegments    = "bigSegments"
+	degradedKindAutoConfig     = "autoConfig"
+	degradedKindUpstream       = "upstream"
+	degradedKindTLSCertificate = "tlsCertificate"
 )
 
 func statusHandler(relay *Relay) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
-		resp := api.StatusRep{
-			Environments:  make(map[string]api.EnvironmentStatusRep),
-			Version:       relay.version,
-			ClientVersion: ld.Version,
-		}
+		data, _ := json.Marshal(relay.getStatus())
+		_, _ = w.Write(data)
+	})
+}
 
-		relay.lock.Lock()
-		fullyConfigured := relay.fullyConfigured
-		relay.lock.Unlock()
+// getStatus computes the status resource.
+func (relay *Relay) getStatus() api.StatusRep {
+	resp := api.StatusRep{
+		Environments:  make(map[string]api.EnvironmentStatusRep),
+		Version:       relay.version,
+		ClientVersion: ld.Version,
+	}
 
-		healthy := fullyConfigured
-		for _, clientCtx := range relay.getAllEnvironments() {
-			identifiers := clientCtx.GetIdentifiers()
+	relay.lock.Lock()
+	fullyConfigured := relay.fullyConfigured
+	relay.lock.Unlock()
 
-			status := api.EnvironmentStatusRep{
-				EnvKey:   identifiers.EnvKey, // these will only be non-empty if we're in auto-configured mode
-				EnvName:  identifiers.EnvName,
-				ProjKey:  identifiers.ProjKey,
-				ProjName: identifiers.ProjName,
-			}
+	healthy := true
+	degraded := func(env, kind, message string) {
+		healthy = false
+		resp.DegradedReasons = append(resp.DegradedReasons, api.StatusReasonRep{Env: env, Kind: kind, Message: message})
+	}
+	warning := func(env, kind, message string) {
+		resp.Warnings = append(resp.Warnings, api.StatusReasonRep{Env: env, Kind: kind, Message: message})
+	}
+	if !fullyConfigured {
+		degraded("", degradedKindAutoConfig, "environments have not been received from the auto-configuration stream")
+	}
 
-			for _, c := range clientCtx.GetCredentials() {
-				switch c := c.(type) {
-				case config.SDKKey:
-					status.SDKKey = sdks.ObscureKey(string(c))
-				case config.MobileKey:
-					status.MobileKey = sdks.ObscureKey(string(c))
-				case config.EnvironmentID:
-					status.EnvID = string(c)
-				}
+	envs := relay.getAllEnvironments()
+	sort.Slice(envs, func(i, j int) bool { // so that the degraded reasons are in a predictable order
+		return relay.getEnvironmentStatusKey(envs[i]) < relay.getEnvironmentStatusKey(envs[j])
+	})
+	for _, clientCtx := range envs {
+		identifiers := clientCtx.GetIdentifiers()
+		envStatusKey := relay.getEnvironmentStatusKey(clientCtx)
+
+		status := api.EnvironmentStatusRep{
+			EnvKey:   identifiers.EnvKey, // these will only be non-empty if we're in auto-configured mode
+			EnvName:  identifiers.EnvName,
+			ProjKey:  identifiers.ProjKey,
+			ProjName: identifiers.ProjName,
+		}
+
+		for _, c := range clientCtx.GetCredentials() {
+			switch c := c.(type) {
+			case config.SDKKey:
+				status.SDKKey = sdks.ObscureKey(string(c))
+			case config.MobileKey:
+				status.MobileKey = sdks.ObscureKey(string(c))
+			case config.EnvironmentID:
+				status.EnvID = string(c)
 			}
+		}
 
-			for _, c := range clientCtx.GetDeprecatedCredentials() {
-				if key, ok := c.(config.SDKKey); ok {
-					status.ExpiringSDKKey = sdks.ObscureKey(string(key))
-				}
+		for _, c := range clientCtx.GetDeprecatedCredentials() {
+			if key, ok := c.(config.SDKKey); ok {
+				status.ExpiringSDKKey = sdks.ObscureKey(string(key))
 			}
+		}
 
-			client := clientCtx.GetClient()
-			if client == nil {
-				status.Status = statusEnvDisconnected
-				status.ConnectionStatus.State = interfaces.DataSourceStateInitializing
-				status.ConnectionStatus.StateSince = ldtime.UnixMillisFromTime(clientCtx.GetCreationTime())
-				status.DataStoreStatus.State = "INITIALIZING"
-				healthy = false
+		client := clientCtx.GetClient()
+		if client == nil {
+			status.Status = statusEnvDisconnected
+			status.ConnectionStatus.State = interfaces.DataSourceStateInitializing
+			status.ConnectionStatus.StateSince = ldtime.UnixMillisFromTime(clientCtx.GetCreationTime())
+			status.DataStoreStatus.State = "INITIALIZING"
+			if err := clientCtx.GetInitError(); err != nil {
+				degraded(envStatusKey, degradedKindDataSource, "SDK client could not be created: "+err.Error())
 			} else {
-				connected := client.Initialized()
+				degraded(envStatusKey, degradedKindDataSource, "SDK client has not been created yet")
+			}
+		} else {
+			connected := client.Initialized()
 
-				sourceStatus := client.GetDataSourceStatus()
-				status.ConnectionStatus = api.ConnectionStatusRep{
-					State:      sourceStatus.State,
-					StateSince: ldtime.UnixMillisFromTime(sourceStatus.StateSince),
-				}
-				if sourceStatus.LastError.Kind != "" {
-					status.ConnectionStatus.LastError = &api.ConnectionErrorRep{
-						Kind: sourceStatus.LastError.Kind,
-						Time: ldtime.UnixMillisFromTime(sourceStatus.LastError.Time),
-					}
-				}
-				if sourceStatus.State != interfaces.DataSourceStateValid &&
-					time.Since(sourceStatus.StateSince) >=
-						relay.config.Main.DisconnectedStatusTime.GetOrElse(config.DefaultDisconnectedStatusTime) {
-					connected = false
+			sourceStatus := client.GetDataSourceStatus()
+			status.ConnectionStatus = api.ConnectionStatusRep{
+				State:      sourceStatus.State,
+				StateSince: ldtime.UnixMillisFromTime(sourceStatus.StateSince),
+			}
+			if sourceStatus.LastError.Kind != "" {
+				status.ConnectionStatus.LastError = &api.ConnectionErrorRep{
+					Kind: sourceStatus.LastError.Kind,
+					Time: ldtime.UnixMillisFromTime(sourceStatus.LastError.Time),
 				}
+			}
+			if sourceStatus.State != interfaces.DataSourceStateValid &&
+				time.Since(sourceStatus.StateSince) >=
+					relay.config.Main.DisconnectedStatusTime.GetOrElse(config.DefaultDisconnectedStatusTime) {
+				connected = false
+			}
 
-				storeStatus := client.GetDataStoreStatus()
-				status.DataStoreStatus.State = "VALID"
-				status.DataStoreStatus.StateSince = ldtime.UnixMillisFromTime(storeStatus.LastUpdated)
-				if !storeStatus.Available {
-					status.DataStoreStatus.State = "INTERRUPTED"
-				}
+			storeStatus := client.GetDataStoreStatus()
+			status.DataStoreStatus.State = "VALID"
+			status.DataStoreStatus.StateSince = ldtime.UnixMillisFromTime(storeStatus.LastUpdated)
+			if !storeStatus.Available {
+				status.DataStoreStatus.State = "INTERRUPTED"
+				warning(envStatusKey, degradedKindDataStore, fmt.Sprintf("data store has been unavailable since %s",
+					storeStatus.LastUpdated.UTC().Format(time.RFC3339)))
+			}
 
-				if connected {
-					status.Status = statusEnvConnected
-				} else {
-					status.Status = statusEnvDisconnected
-					healthy = false
+			// If we're serving stale data because the SDK client hasn't initialized, connected is already false,
+			// so the environment is reported as disconnected and Relay as degraded.
+			status.ServingStaleData = clientCtx.IsServingStaleData()
+
+			if connected {
+				status.Status = statusEnvConnected
+			} else {
+				status.Status = statusEnvDisconnected
+				message := fmt.Sprintf("data source has been %s since %s", sourceStatus.State,
+					sourceStatus.StateSince.UTC().Format(time.RFC3339))
+				if sourceStatus.LastError.Kind != "" {
+					message += fmt.Sprintf(" (last error: %s)", sourceStatus.LastError.Kind)
 				}
+				degraded(envStatusKey, degradedKindDataSource, message)
 			}
+		}
 
-			bigSegmentStore := clientCtx.GetBigSegmentStore()
-			if bigSegmentStore != nil {
-				bigSegmentStatus := api.BigSegmentStatusRep{}
-				synchronizedOn, err := bigSegmentStore.GetSynchronizedOn()
-				if err != nil {
-					bigSegmentStatus.Available = false
-				} else {
-					bigSegmentStatus.Available = true
-					bigSegmentStatus.LastSynchronizedOn = synchronizedOn
-					now := ldtime.UnixMillisNow()
-					stalenessThreshold := relay.config.Main.BigSegmentsStaleThreshold.GetOrElse(config.DefaultBigSegmentsStaleThreshold)
-					if !synchronizedOn.IsDefined() || now > (synchronizedOn+ldtime.UnixMillisecondTime(stalenessThreshold.Milliseconds())) {
-						bigSegmentStatus.PotentiallyStale = true
-						if relay.config.Main.BigSegmentsStaleAsDegraded {
-							healthy = false
-						}
+		bigSegmentStore := clientCtx.GetBigSegmentStore()
+		if bigSegmentStore != nil {
+			bigSegmentStatus := api.BigSegmentStatusRep{}
+			synchronizedOn, err := bigSegmentStore.GetSynchronizedOn()
+			if err != nil {
+				bigSegmentStatus.Available = false
+			} else {
+				bigSegmentStatus.Available = true
+				bigSegmentStatus.LastSynchronizedOn = synchronizedOn
+				if relay.isBigSegmentDataStale(synchronizedOn) {
+					bigSegmentStatus.PotentiallyStale = true
+					if relay.config.Main.BigSegmentsStaleAsDegraded {
+						degraded(envStatusKey, degradedKindBigSegments, "big segment data is potentially stale")
 					}
 				}
-				status.BigSegmentStatus = &bigSegmentStatus
 			}
+			status.BigSegmentStatus = &bigSegmentStatus
+		}
 
-			storeInfo := clientCtx.GetDataStoreInfo()
-			status.DataStoreStatus.Database = storeInfo.DBType
-			status.DataStoreStatus.DBServer = storeInfo.DBServer
-			status.DataStoreStatus.DBPrefix = storeInfo.DBPrefix
-			status.DataStoreStatus.DBTable = storeInfo.DBTable
-
-			statusKey := identifiers.GetDisplayName()
-			if relay.envLogNameMode == relayenv.LogNameIsEnvID {
-				// If we're identifying environments by environment ID in the log (which we do if there's any
-				// chance that the environment name could change) then we should also identify them that way here.
-				statusKey = status.EnvID
+		if eventDispatcher := clientCtx.GetEventDispatcher(); eventDispatcher != nil {
+			eventStats := eventDispatcher.GetStats()
+			status.EventStats = &api.EventStatsRep{
+				Received:          make(map[string]int64, len(eventStats.Received)),
+				PayloadsForwarded: eventStats.PayloadsForwarded,
+				EventsForwarded:   eventStats.EventsForwarded,
+				Dropped:           eventStats.Dropped,
+				SendFailures:      eventStats.SendFailures,
+				Retries:           eventStats.Retries,
+				QueueDepth:        eventStats.QueueDepth,
+			}
+			for sdkKind, count := range eventStats.Received {
+				status.EventStats.Received[string(sdkKind)] = count
 			}
-			resp.Environments[statusKey] = status
 		}
 
-		if healthy {
-			resp.Status = statusRelayHealthy
-		} else {
-			resp.Status = statusRelayDegraded
+		storeInfo := clientCtx.GetDataStoreInfo()
+		status.DataStoreStatus.Database = storeInfo.DBType
+		status.DataStoreStatus.DBServer = storeInfo.DBServer
+		status.DataStoreStatus.DBPrefix = storeInfo.DBPrefix
+		status.DataStoreStatus.DBTable = storeInfo.DBTable
+
+		if consistency, ok := clientCtx.GetDataStoreConsistency(); ok {
+			status.DataStoreStatus.Consistency = &api.DataStoreConsistencyRep{
+				LastChecked:  ldtime.UnixMillisFromTime(consistency.Time),
+				MissingItems: len(consistency.MissingItems),
+				StaleItems:   len(consistency.StaleItems),
+				Repaired:     consistency.Repaired,
+			}
 		}
 
-		data, _ := json.Marshal(resp)
+		for _, t := range clientCtx.GetStatusHistory() {
+			status.History = append(status.History, api.StatusTransitionRep{
+				Time:      ldtime.UnixMillisFromTime(t.Time),
+				Source:    string(t.Source),
+				State:     t.State,
+				ErrorKind: t.ErrorKind,
+			})
+		}
 
-		_, _ = w.Write(data)
-	})
+		resp.Environments[envStatusKey] = status
+	}
+
+	if relay.autoConfigStream != nil {
+		autoConfigStatus := relay.autoConfigStream.GetStatus()
+		resp.AutoConfig = &api.AutoConfigStatusRep{
+			State:      autoConfigStatus.State,
+			StateSince: ldtime.UnixMillisFromTime(autoConfigStatus.StateSince),
+			LastError:  autoConfigStatus.LastError,
+		}
+		if fullyConfigured && autoConfigStatus.State != interfaces.DataSourceStateValid {
+			warning("", degradedKindAutoConfig, fmt.Sprintf("auto-configuration stream has been %s since %s",
+				autoConfigStatus.State, autoConfigStatus.StateSince.UTC().Format(time.RFC3339)))
+		}
+	}
+
+	if relay.tlsCertificates != nil {
+		certInfo := relay.tlsCertificates.GetCertificateInfo()
+		resp.TLSCertificate = &api.TLSCertificateStatusRep{
+			Subject:  certInfo.Subject,
+			Expires:  ldtime.UnixMillisFromTime(certInfo.NotAfter),
+			LoadedAt: ldtime.UnixMillisFromTime(certInfo.LoadedAt),
+		}
+		if time.Now().After(certInfo.NotAfter) {
+			degraded("", degradedKindTLSCertificate, "TLS certificate has expired")
+		}
+	}
+
+	if relay.upstreamStatus != nil {
+		upstreamStatus := relay.upstreamStatus.getStatus()
+		resp.Upstream = &upstreamStatus
+		// The upstream Relay's status covers environments that we might not be using, so it is only a warning;
+		// if we actually lose our data from it, our own data source status will show that.
+		if upstreamStatus.Status != statusRelayHealthy && upstreamStatus.Status != upstreamStatusUnknown {
+			warning("", degradedKindUpstream, "upstream Relay Proxy is "+upstreamStatus.Status)
+		}
+	}
+
+	if healthy {
+		resp.Status = statusRelayHealthy
+	} else {
+		resp.Status = statusRelayDegraded
+	}
+
+	return resp
+}
+
+// isBigSegmentDataStale returns true if the big segment data was last synchronized longer ago than the
+// configured staleness threshold, or has never been synchronized.
+func (relay *Relay) isBigSegmentDataStale(synchronizedOn ldtime.UnixMillisecondTime) bool {
+	stalenessThreshold := relay.config.Main.BigSegmentsStaleThreshold.GetOrElse(config.DefaultBigSegmentsStaleThreshold)
+	return !synchronizedOn.IsDefined() ||
+		ldtime.UnixMillisNow() > (synchronizedOn+ldtime.UnixMillisecondTime(stalenessThreshold.Milliseconds()))
 }
@@ -170,6 +170,8 @@ func TestAutoConfigStatusEndpoints(t *testing.T) {
 			st.AssertJSONPathMatch(t, "healthy", status, "status")
 			st.AssertJSONPathMatch(t, p.relay.version, status, "version")
 			st.AssertJSONPathMatch(t, ld.Version, status, "clientVersion")
+			st.AssertJSONPathMatch(t, "VALID", status, "autoConfig", "state")
+			assert.True(t, status.GetByKey("degradedReasons").IsNull())
 		})
 	})
 
@@ -2,6 +2,7 @@ package relay
 
 import (
 	"net/http"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -17,6 +18,7 @@ import (
 	"github.com/launchdarkly/go-sdk-common/v3/ldvalue"
 	ld "github.com/launchdarkly/go-server-sdk/v7"
 	"github.com/launchdarkly/go-server-sdk/v7/interfaces"
+	"github.com/launchdarkly/go-test-helpers/v3/httphelpers"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -55,6 +57,16 @@ func TestEndpointsStatus(t *testing.T) {
 			st.AssertJSONPathMatch(t, "healthy", status, "status")
 			st.AssertJSONPathMatch(t, p.relay.version, status, "version")
 			st.AssertJSONPathMatch(t, ld.Version, status, "clientVersion")
+			assert.True(t, status.GetByKey("tlsCertificate").IsNull())
+			assert.True(t, status.GetByKey("autoConfig").IsNull())
+			assert.True(t, status.GetByKey("degradedReasons").IsNull())
+
+			history := status.GetByKey("environments").GetByKey(st.EnvMain.Name).GetByKey("history")
+			require.Equal(t, 2, history.Count())
+			st.AssertJSONPathMatch(t, "dataSource", history.GetByIndex(0), "source")
+			st.AssertJSONPathMatch(t, "VALID", history.GetByIndex(0), "state")
+			st.AssertJSONPathMatch(t, "dataStore", history.GetByIndex(1), "source")
+			st.AssertJSONPathMatch(t, "VALID", history.GetByIndex(1), "state")
 		})
 	})
 
@@ -110,6 +122,7 @@ func TestEndpointsStatus(t *testing.T) {
 			clientMain.SetDataSourceStatus(interfaces.DataSourceStatus{
 				State:      interfaces.DataSourceStateInterrupted,
 				StateSince: interruptedSinceTime,
+				LastError:  interfaces.DataSourceErrorInfo{Kind: interfaces.DataSourceErrorKindNetworkError},
 			})
 
 			time.Sleep(threshold + (time.Millisecond * 10))
@@ -128,6 +141,93 @@ func TestEndpointsStatus(t *testing.T) {
 			st.AssertJSONPathMatch(t, "VALID", status, "environments", st.EnvMobile.Name, "connectionStatus", "state")
 
 			st.AssertJSONPathMatch(t, "degraded", status, "status")
+			reasons := status.GetByKey("degradedReasons")
+			require.Equal(t, 1, reasons.Count())
+			st.AssertJSONPathMatch(t, st.EnvMain.Name, reasons.GetByIndex(0), "env")
+			st.AssertJSONPathMatch(t, "dataSource", reasons.GetByIndex(0), "kind")
+			assert.Contains(t, reasons.GetByIndex(0).GetByKey("message").StringValue(), "NETWORK_ERROR")
+
+			history := status.GetByKey("environments").GetByKey(st.EnvMain.Name).GetByKey("history")
+			lastTransition := history.GetByIndex(history.Count() - 1)
+			st.AssertJSONPathMatch(t, "INTERRUPTED", lastTransition, "state")
+			st.AssertJSONPathMatch(t, "NETWORK_ERROR", lastTransition, "errorKind")
+			st.AssertJSONPathMatch(t, float64(ldtime.UnixMillisFromTime(interruptedSinceTime)), lastTransition, "time")
+		})
+	})
+
+	t.Run("data store interruption is reported as a warning", func(t *testing.T) {
+		var config c.Config
+		config.Environment = st.MakeEnvConfigs(st.EnvMain)
+
+		withStartedRelay(t, config, func(p relayTestParams) {
+			unavailableSinceTime := time.Now()
+
+			envMain, err := p.relay.getEnvironment(sdkauth.New(st.EnvMain.Config.SDKKey))
+			require.NotNil(t, envMain)
+			require.Nil(t, err)
+			clientMain := envMain.GetClient().(*testclient.FakeLDClient)
+			clientMain.SetDataStoreStatus(sdks.DataStoreStatusInfo{Available: false, LastUpdated: unavailableSinceTime})
+
+			r, _ := http.NewRequest("GET", "http://localhost/status", nil)
+			result, body := st.DoRequest(r, p.relay)
+			assert.Equal(t, http.StatusOK, result.StatusCode)
+			status := ldvalue.Parse(body)
+
+			st.AssertJSONPathMatch(t, "connected", status, "environments", st.EnvMain.Name, "status")
+			st.AssertJSONPathMatch(t, "INTERRUPTED", status, "environments", st.EnvMain.Name, "dataStoreStatus", "state")
+
+			st.AssertJSONPathMatch(t, "healthy", status, "status")
+			assert.True(t, status.GetByKey("degradedReasons").IsNull())
+			warnings := status.GetByKey("warnings")
+			require.Equal(t, 1, warnings.Count())
+			st.AssertJSONPathMatch(t, st.EnvMain.Name, warnings.GetByIndex(0), "env")
+			st.AssertJSONPathMatch(t, "dataStore", warnings.GetByIndex(0), "kind")
+		})
+	})
+
+	t.Run("event stats", func(t *testing.T) {
+		var config c.Config
+		config.Environment = st.MakeEnvConfigs(st.EnvMain)
+		config.Events.SendEvents = true
+
+		withStartedRelay(t, config, func(p relayTestParams) {
+			r, _ := http.NewRequest("GET", "http://localhost/status", nil)
+			result, body := st.DoRequest(r, p.relay)
+			assert.Equal(t, http.StatusOK, result.StatusCode)
+			status := ldvalue.Parse(body)
+
+			st.AssertJSONPathMatch(t, float64(0), status, "environments", st.EnvMain.Name, "eventStats", "eventsForwarded")
+			st.AssertJSONPathMatch(t, float64(0), status, "environments", st.EnvMain.Name, "eventStats", "dropped")
+			st.AssertJSONPathMatch(t, float64(0), status, "environments", st.EnvMain.Name, "eventStats", "queueDepth")
+		})
+	})
+
+	t.Run("TLS certificate", func(t *testing.T) {
+		dir := t.TempDir()
+		certPath, keyPath := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+		require.NoError(t, httphelpers.MakeSelfSignedCert(certPath, keyPath))
+
+		var config c.Config
+		config.Environment = st.MakeEnvConfigs(st.EnvMain)
+		config.Main.TLSEnabled = true
+		config.Main.TLSCert = certPath
+		config.Main.TLSKey = keyPath
+
+		withStartedRelay(t, config, func(p relayTestParams) {
+			cert, err := p.relay.GetTLSCertificate(nil)
+			require.NoError(t, err)
+			require.NotNil(t, cert)
+
+			r, _ := http.NewRequest("GET", "http://localhost/status", nil)
+			result, body := st.DoRequest(r, p.relay)
+			assert.Equal(t, http.StatusOK, result.StatusCode)
+			status := ldvalue.Parse(body)
+
+			certStatus := status.GetByKey("tlsCertificate")
+			assert.NotEqual(t, "", certStatus.GetByKey("subject").StringValue())
+			assert.Greater(t, certStatus.GetByKey("expires").Float64Value(), float64(ldtime.UnixMillisNow()))
+			assert.LessOrEqual(t, certStatus.GetByKey("loadedAt").Float64Value(), float64(ldtime.UnixMillisNow()))
+			st.AssertJSONPathMatch(t, "healthy", status, "status")
 		})
 	})
 }
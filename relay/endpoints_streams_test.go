@@ -72,6 +72,41 @@ func (s streamEndpointTestParams) runBasicStreamTests(
 		})
 	})
 
+	configWithConnLimit := baseConfig
+	configWithConnLimit.Main.MaxClientConnectionTime = ct.OptDuration{}
+	configWithConnLimit.Main.MaxEnvStreamConnections, _ = ct.NewOptIntGreaterThanZero(1)
+	configWithConnLimit.Main.StreamConnectionRetryAfter = ct.NewOptDuration(5 * time.Second)
+
+	withStartedRelay(t, configWithConnLimit, func(p relayTestParams) {
+		t.Run("connection limit rejects new connection", func(t *testing.T) {
+			st.WithStreamRequest(t, s.request(), p.relay, func(eventCh <-chan eventsource.Event) {
+				_ = helpers.RequireValue(t, eventCh, time.Second*3, "timed out waiting for initial event")
+
+				result := doStreamRequestExpectingError(s.request(), p.relay)
+				assert.Equal(t, http.StatusServiceUnavailable, result.StatusCode)
+				assert.Equal(t, "5", result.Header.Get("Retry-After"))
+			})
+		})
+	})
+
+	configWithConnShedding := configWithConnLimit
+	configWithConnShedding.Main.ShedOldestStreamConnections = true
+
+	withStartedRelay(t, configWithConnShedding, func(p relayTestParams) {
+		t.Run("connection limit sheds oldest connection", func(t *testing.T) {
+			st.WithStreamRequest(t, s.request(), p.relay, func(oldEventCh <-chan eventsource.Event) {
+				_ = helpers.RequireValue(t, oldEventCh, time.Second*3, "timed out waiting for initial event")
+
+				st.WithStreamRequest(t, s.request(), p.relay, func(newEventCh <-chan eventsource.Event) {
+					_ = helpers.RequireValue(t, newEventCh, time.Second*3, "timed out waiting for initial event")
+
+					endOfStreamMarker := helpers.RequireValue(t, oldEventCh, time.Second, "timed out waiting for old stream to be closed")
+					require.Nil(t, endOfStreamMarker)
+				})
+			})
+		})
+	})
+
 	maxConnTime := 100 * time.Millisecond
 	configWithTimeLimit := baseConfig
 	configWithTimeLimit.Main.MaxClientConnectionTime = ct.NewOptDuration(maxConnTime)
@@ -214,6 +249,23 @@ func TestEndpointsStreamingJSClient(t *testing.T) {
 		s.runBasicStreamTests(t, config, st.UndefinedEnvID, http.StatusNotFound)
 	}
 
+	configWithConnLimit := config
+	configWithConnLimit.Main.MaxEnvStreamConnections, _ = ct.NewOptIntGreaterThanZero(1)
+
+	withStartedRelay(t, configWithConnLimit, func(p relayTestParams) {
+		t.Run("CORS preflight is not counted against connection limit", func(t *testing.T) {
+			s := specs[0]
+			st.WithStreamRequest(t, s.request(), p.relay, func(eventCh <-chan eventsource.Event) {
+				_ = helpers.RequireValue(t, eventCh, time.Second*3, "timed out waiting for initial event")
+
+				preflight := s
+				preflight.method = "OPTIONS"
+				result, _ := st.DoRequest(preflight.request(), p.relay)
+				assert.Equal(t, http.StatusOK, result.StatusCode)
+			})
+		})
+	})
+
 	withStartedRelay(t, config, func(p relayTestParams) {
 		for _, spec := range specs {
 			s := spec
@@ -0,0 +1,57 @@
+package relay
+
+import (
+	"github.com/launchdarkly/ld-relay/v8/internal/metrics"
+
+	"github.com/launchdarkly/go-server-sdk/v7/interfaces"
+)
+
+// getEnvironmentMetricsStates provides the per-environment state that is reported by the native Prometheus
+// exporter. Environments are labeled with the same names that are used in the status resource.
+func (r *Relay) getEnvironmentMetricsStates() []metrics.EnvironmentState {
+	envs := r.getAllEnvironments()
+	states := make([]metrics.EnvironmentState, 0, len(envs))
+	for _, env := range envs {
+		state := metrics.EnvironmentState{
+			Name:        r.getEnvironmentStatusKey(env),
+			Connections: make(map[string]int),
+		}
+		for sp, count := range env.GetStreamSubscriberCounts() {
+			state.Connections[string(r.getStreamKind(sp))] += count
+		}
+
+		if client := env.GetClient(); client == nil {
+			initializing := interfaces.DataSourceStateInitializing
+			state.DataSourceState = &initializing
+		} else {
+			sourceState := client.GetDataSourceStatus().State
+			state.DataSourceState = &sourceState
+			available := client.GetDataStoreStatus().Available
+			state.DataStoreAvailable = &available
+		}
+
+		if bigSegmentStore := env.GetBigSegmentStore(); bigSegmentStore != nil {
+			stale := true // if we can't get the synchronization time, we can't assume the data is fresh
+			if synchronizedOn, err := bigSegmentStore.GetSynchronizedOn(); err == nil {
+				stale = r.isBigSegmentDataStale(synchronizedOn)
+			}
+			state.BigSegmentsStale = &stale
+		}
+
+		if expiry, ok := env.GetSDKKeyExpiry(); ok {
+			state.SDKKeyExpiry = &expiry
+		}
+
+		if eventDispatcher := env.GetEventDispatcher(); eventDispatcher != nil {
+			queueDepth := eventDispatcher.GetStats().QueueDepth
+			state.EventQueueDepth = &queueDepth
+		}
+
+		if duration, ok := env.GetSDKInitDuration(); ok {
+			state.SDKInitDuration = &duration
+		}
+
+		states = append(states, state)
+	}
+	return states
+}
@@ -1,7 +1,9 @@
 package relay
 
 import (
+	"crypto/tls"
 	"errors"
+	"io"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
@@ -16,19 +18,26 @@ import (
 
 	"github.com/gregjones/httpcache"
 	"github.com/launchdarkly/ld-relay/v8/config"
+	"github.com/launchdarkly/ld-relay/v8/internal/application"
 	"github.com/launchdarkly/ld-relay/v8/internal/autoconfig"
 	"github.com/launchdarkly/ld-relay/v8/internal/basictypes"
+	"github.com/launchdarkly/ld-relay/v8/internal/changefeed"
+	"github.com/launchdarkly/ld-relay/v8/internal/cluster"
 	"github.com/launchdarkly/ld-relay/v8/internal/filedata"
 	"github.com/launchdarkly/ld-relay/v8/internal/httpconfig"
+	"github.com/launchdarkly/ld-relay/v8/internal/logging"
 	"github.com/launchdarkly/ld-relay/v8/internal/metrics"
 	"github.com/launchdarkly/ld-relay/v8/internal/relayenv"
 	"github.com/launchdarkly/ld-relay/v8/internal/sdks"
+	"github.com/launchdarkly/ld-relay/v8/internal/store"
 	"github.com/launchdarkly/ld-relay/v8/internal/streams"
 	"github.com/launchdarkly/ld-relay/v8/internal/util"
+	"github.com/launchdarkly/ld-relay/v8/internal/webhooks"
 	"github.com/launchdarkly/ld-relay/v8/relay/version"
 
 	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
 	ld "github.com/launchdarkly/go-server-sdk/v7"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems"
 )
 
 var (
@@ -47,17 +56,19 @@ const rpacProtocolVersion = 2
 // It can also be referenced externally in order to embed Relay Proxy functionality into a customized
 // application; see docs/in-app.md.
 //
-// This type deliberately exports no methods other than ServeHTTP and Close. Everything else is an
-// implementation detail which is subject to change.
+// This type deliberately exports no methods other than ServeHTTP, GetTLSCertificate, and Close.
+// Everything else is an implementation detail which is subject to change.
 type Relay struct {
 	http.Handler
 	envsByCredential              *EnvironmentLookup
 	metricsManager                *metrics.Manager
+	nativePrometheus              *metrics.NativePrometheusExporter
 	clientFactory                 sdks.ClientFactoryFunc
 	serverSideStreamProvider      streams.StreamProvider
 	serverSideFlagsStreamProvider streams.StreamProvider
 	mobileStreamProvider          streams.StreamProvider
 	jsClientStreamProvider        streams.StreamProvider
+	streamConnLimiter             *streams.ConnectionLimiter
 	clientInitCh                  chan relayenv.EnvContext
 	fullyConfigured               bool
 	clientSideSDKBaseURL          url.URL
@@ -68,6 +79,18 @@ type Relay struct {
 	lock                          sync.RWMutex
 	autoConfigStream              *autoconfig.StreamManager
 	archiveManager                filedata.ArchiveManagerInterface
+	accessLog                     io.Writer
+	accessLogFile                 *logging.RotatingFile
+	changeFeed                    *changefeed.Feed
+	changeFeedFile                *logging.RotatingFile
+	tlsCertificates               *application.CertificateReloader
+	upstreamStatus                *upstreamStatusMonitor
+	webhooks                      *webhooks.Dispatcher
+	downstreamRelayHTTPConfig     httpconfig.HTTPConfig
+	clusterBackend                cluster.Backend
+	clusterNodeID                 string
+	storeLeaderElection           bool
+	storeLeaseClient              cluster.DataStoreLeaseClient
 	config                        config.Config
 	loggers                       ldlog.Loggers
 }
@@ -79,9 +102,11 @@ type ClientFactoryFunc func(sdkKey config.SDKKey, config ld.Config) (*ld.LDClien
 // Using a struct type for this instead of adding parameters to newRelayInternal helps to minimize
 // changes to test code whenever we make more things configurable.
 type relayInternalOptions struct {
-	loggers               ldlog.Loggers
-	clientFactory         sdks.ClientFactoryFunc
-	archiveManagerFactory func(path string, monitoringInterval time.Duration, environmentUpdates filedata.UpdateHandler, loggers ldlog.Loggers) (filedata.ArchiveManagerInterface, error)
+	loggers                ldlog.Loggers
+	clientFactory          sdks.ClientFactoryFunc
+	archiveManagerFactory  func(path string, monitoringInterval time.Duration, environmentUpdates filedata.UpdateHandler, loggers ldlog.Loggers) (filedata.ArchiveManagerInterface, error)
+	upstreamStatusInterval time.Duration
+	clusterBackend         cluster.Backend
 }
 
 // NewRelay creates a new Relay given a configuration and a method to create a client.
@@ -144,24 +169,136 @@ func newRelayInternal(c config.Config, options relayInternalOptions) (*Relay, er
 	}
 	thingsToCleanUp.AddFunc(metricsManager.Close)
 
+	var accessLog io.Writer
+	var accessLogFile *logging.RotatingFile
+	switch c.Main.AccessLog {
+	case "":
+	case config.AccessLogStdout:
+		accessLog = os.Stdout
+	default:
+		accessLogFile, err = logging.NewRotatingFile(
+			c.Main.AccessLog,
+			int64(c.Main.AccessLogMaxSize.GetOrElse(config.DefaultAccessLogMaxSize)),
+			c.Main.AccessLogMaxBackups.GetOrElse(config.DefaultAccessLogMaxBackups),
+		)
+		if err != nil {
+			return nil, errOpenAccessLogFailed(err)
+		}
+		thingsToCleanUp.AddCloser(accessLogFile)
+		accessLog = accessLogFile
+	}
+
+	var changeFeedFile *logging.RotatingFile
+	var changeFeedSink io.Writer
+	if c.Main.ChangeFeedFile != "" {
+		// The change feed file is rotated in the same way as the access log.
+		changeFeedFile, err = logging.NewRotatingFile(
+			c.Main.ChangeFeedFile,
+			config.DefaultAccessLogMaxSize,
+			config.DefaultAccessLogMaxBackups,
+		)
+		if err != nil {
+			return nil, errOpenChangeFeedFileFailed(err)
+		}
+		thingsToCleanUp.AddCloser(changeFeedFile)
+		changeFeedSink = changeFeedFile
+	}
+	changeFeed := changefeed.NewFeed(c.Main.ChangeFeedSize.GetOrElse(config.DefaultChangeFeedSize), changeFeedSink, loggers)
+	thingsToCleanUp.AddFunc(changeFeed.Close)
+
+	var tlsCertificates *application.CertificateReloader
+	if c.Main.TLSEnabled {
+		tlsCertificates, err = application.NewCertificateReloader(
+			c.Main.TLSCert,
+			c.Main.TLSKey,
+			0,
+			func(info application.CertificateInfo) {
+				metrics.RecordTLSCertificateExpiry(metricsManager.GetOpenCensusContext(), info.NotAfter)
+			},
+			loggers,
+		)
+		if err != nil {
+			return nil, err
+		}
+		thingsToCleanUp.AddCloser(tlsCertificates)
+	}
+
 	clientInitCh := make(chan relayenv.EnvContext, len(c.Environment))
 
-	maxConnTime := c.Main.MaxClientConnectionTime.GetOrElse(0)
+	streamOptions := streams.StreamProviderOptions{
+		MaxConnTime: c.Main.MaxClientConnectionTime.GetOrElse(0),
+		SlowConsumers: streams.SlowConsumerConfig{
+			MaxQueuedEvents: c.Main.MaxStreamQueuedEvents.GetOrElse(0),
+			WriteTimeout:    c.Main.StreamWriteTimeout.GetOrElse(0),
+		},
+		DisableCompression: c.Main.DisableStreamCompression,
+	}
+
+	streamConnLimiter := streams.NewConnectionLimiter(
+		c.Main.MaxStreamConnections.GetOrElse(0),
+		c.Main.ShedOldestStreamConnections,
+	)
 
 	userAgent := "LDRelay/" + version.Version
 
+	// The node ID identifies this Relay instance in leases for cluster mode, and for persistent store
+	// leader election.
+	var clusterNodeID string
+	storeLeaderElection := sdks.IsDataStoreLeaderElectionEnabled(c)
+	if c.Cluster.Enabled || storeLeaderElection {
+		clusterNodeID = c.Cluster.NodeID
+		if clusterNodeID == "" {
+			clusterNodeID = cluster.DefaultNodeID()
+		}
+	}
+
+	var clusterBackend cluster.Backend
+	if c.Cluster.Enabled {
+		clusterBackend = options.clusterBackend
+		if clusterBackend == nil {
+			redisConfig := c.Redis
+			if c.Cluster.RedisURL.IsDefined() {
+				redisConfig = config.RedisConfig{URL: c.Cluster.RedisURL}
+			}
+			clusterBackend, err = cluster.NewRedisBackend(redisConfig)
+			if err != nil {
+				return nil, errCreateClusterBackendFailed(err)
+			}
+		}
+		loggers.Infof("Cluster mode is enabled; this node's ID is %s", clusterNodeID)
+	}
+	// All environments share one connection for their persistent store leases, rather than each opening its own.
+	var storeLeaseClient cluster.DataStoreLeaseClient
+	if storeLeaderElection {
+		storeLeaseClient, err = cluster.NewDataStoreLeaseClient(c)
+		if err != nil {
+			return nil, errCreateStoreLeaseClientFailed(err)
+		}
+		loggers.Infof("Persistent store leader election is enabled; this node's ID is %s", clusterNodeID)
+	}
+
 	r := &Relay{
 		envsByCredential:              NewEnvironmentLookup(),
-		serverSideStreamProvider:      streams.NewStreamProvider(basictypes.ServerSideStream, maxConnTime),
-		serverSideFlagsStreamProvider: streams.NewStreamProvider(basictypes.ServerSideFlagsOnlyStream, maxConnTime),
-		mobileStreamProvider:          streams.NewStreamProvider(basictypes.MobilePingStream, maxConnTime),
-		jsClientStreamProvider:        streams.NewStreamProvider(basictypes.JSClientPingStream, maxConnTime),
+		serverSideStreamProvider:      streams.NewStreamProvider(basictypes.ServerSideStream, streamOptions),
+		serverSideFlagsStreamProvider: streams.NewStreamProvider(basictypes.ServerSideFlagsOnlyStream, streamOptions),
+		mobileStreamProvider:          streams.NewStreamProvider(basictypes.MobilePingStream, streamOptions),
+		jsClientStreamProvider:        streams.NewStreamProvider(basictypes.JSClientPingStream, streamOptions),
+		streamConnLimiter:             streamConnLimiter,
 		metricsManager:                metricsManager,
 		clientFactory:                 clientFactory,
 		clientInitCh:                  clientInitCh,
 		version:                       version.Version,
 		userAgent:                     userAgent,
 		envLogNameMode:                logNameMode,
+		accessLog:                     accessLog,
+		accessLogFile:                 accessLogFile,
+		changeFeed:                    changeFeed,
+		changeFeedFile:                changeFeedFile,
+		tlsCertificates:               tlsCertificates,
+		clusterBackend:                clusterBackend,
+		clusterNodeID:                 clusterNodeID,
+		storeLeaderElection:           storeLeaderElection,
+		storeLeaseClient:              storeLeaseClient,
 		config:                        c,
 		loggers:                       loggers,
 	}
@@ -170,6 +307,18 @@ func newRelayInternal(c config.Config, options relayInternalOptions) (*Relay, er
 
 	r.clientSideSDKBaseURL = *c.Main.ClientSideBaseURI.Get() // config.ValidateConfig has ensured that this has a value
 
+	// The webhooks must be set up before any environments are added, since the environments report their
+	// status changes to them.
+	if len(c.Webhook) != 0 {
+		// This is for requests that aren't associated with any environment, so it has no credential
+		httpConfig, err := httpconfig.NewHTTPConfig(c.Proxy, nil, userAgent, loggers)
+		if err != nil {
+			return nil, err
+		}
+		r.webhooks = webhooks.NewDispatcher(c.Webhook, httpConfig.Client(), loggers)
+		r.changeFeed.AddListener(r.webhooks.NotifyChange)
+	}
+
 	for envName, envConfig := range makeFilteredEnvironments(&c) {
 		env, resultCh, err := r.addEnvironment(relayenv.EnvIdentifiers{ConfiguredName: envName}, *envConfig, nil)
 		if err != nil {
@@ -196,6 +345,9 @@ func newRelayInternal(c config.Config, options relayInternalOptions) (*Relay, er
 		if err != nil {
 			return nil, err
 		}
+		if c.MetricsConfig.OTLP.Enabled {
+			httpConfig = httpConfig.WithTracing()
+		}
 		r.autoConfigStream = autoconfig.NewStreamManager(
 			c.AutoConfig.Key,
 			c.Main.StreamURI.Get(),
@@ -219,6 +371,24 @@ func newRelayInternal(c config.Config, options relayInternalOptions) (*Relay, er
 		}()
 	}
 
+	if c.Main.UpstreamRelayURI.IsDefined() || c.Main.AllowDownstreamRelays {
+		// This is for requests that aren't associated with any environment, so it has no credential
+		httpConfig, err := httpconfig.NewHTTPConfig(c.Proxy, nil, userAgent, loggers)
+		if err != nil {
+			return nil, err
+		}
+		if c.MetricsConfig.OTLP.Enabled {
+			httpConfig = httpConfig.WithTracing()
+		}
+		if c.Main.AllowDownstreamRelays {
+			r.downstreamRelayHTTPConfig = httpConfig
+		}
+		if c.Main.UpstreamRelayURI.IsDefined() {
+			r.upstreamStatus = newUpstreamStatusMonitor(c.Main.UpstreamRelayURI.Get(), httpConfig,
+				options.upstreamStatusInterval, loggers)
+		}
+	}
+
 	if hasFileDataSource {
 		factory := options.archiveManagerFactory
 		if factory == nil {
@@ -247,7 +417,16 @@ func newRelayInternal(c config.Config, options relayInternalOptions) (*Relay, er
 		}
 	}
 
-	r.Handler = r.makeRouter()
+	if c.MetricsConfig.Prometheus.Enabled && c.MetricsConfig.Prometheus.Native {
+		r.nativePrometheus, err = metrics.NewNativePrometheusExporter(c.MetricsConfig.Prometheus,
+			r.getEnvironmentMetricsStates, loggers)
+		if err != nil {
+			return nil, errNewMetricsManagerFailed(err)
+		}
+		thingsToCleanUp.AddFunc(func() { _ = r.nativePrometheus.Close() })
+	}
+
+	r.Handler = r.makeHandler()
 	thingsToCleanUp.Clear() // we succeeded, don't close anything
 	return r, nil
 }
@@ -297,6 +476,16 @@ func defaultArchiveManagerFactory(filePath string, monitoringInterval time.Durat
 	return am, err
 }
 
+// GetTLSCertificate returns the TLS server certificate from the configured certificate and key files,
+// if TLS is enabled. The files are monitored for changes, so this always returns the latest valid
+// certificate. Its signature matches tls.Config.GetCertificate.
+func (r *Relay) GetTLSCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	if r.tlsCertificates == nil {
+		return nil, errTLSNotEnabled
+	}
+	return r.tlsCertificates.GetCertificate(hello)
+}
+
 // Close shuts down components created by the Relay Proxy.
 //
 // This includes dropping all connections to the LaunchDarkly services and to SDK clients,
@@ -313,6 +502,9 @@ func (r *Relay) Close() error {
 	r.lock.Unlock()
 
 	r.metricsManager.Close()
+	if r.nativePrometheus != nil {
+		_ = r.nativePrometheus.Close()
+	}
 
 	if r.autoConfigStream != nil {
 		r.autoConfigStream.Close()
@@ -320,6 +512,15 @@ func (r *Relay) Close() error {
 	if r.archiveManager != nil {
 		_ = r.archiveManager.Close()
 	}
+	if r.accessLogFile != nil {
+		_ = r.accessLogFile.Close()
+	}
+	if r.tlsCertificates != nil {
+		_ = r.tlsCertificates.Close()
+	}
+	if r.upstreamStatus != nil {
+		_ = r.upstreamStatus.Close()
+	}
 
 	for _, env := range r.envsByCredential.Environments() {
 		if err := env.Close(); err != nil {
@@ -331,6 +532,23 @@ func (r *Relay) Close() error {
 		sp.Close()
 	}
 
+	r.changeFeed.Close()
+	if r.changeFeedFile != nil {
+		_ = r.changeFeedFile.Close()
+	}
+	if r.webhooks != nil {
+		r.webhooks.Close()
+	}
+
+	// The environments use the cluster backend and the store lease client to hand off leadership when
+	// they're closed, so those must be closed after them.
+	if r.clusterBackend != nil {
+		_ = r.clusterBackend.Close()
+	}
+	if r.storeLeaseClient != nil {
+		_ = r.storeLeaseClient.Close()
+	}
+
 	return nil
 }
 
@@ -343,6 +561,20 @@ func (r *Relay) allStreamProviders() []streams.StreamProvider {
 	}
 }
 
+// getStreamKind returns the kind of stream that one of the StreamProviders from allStreamProviders is for.
+func (r *Relay) getStreamKind(sp streams.StreamProvider) basictypes.StreamKind {
+	switch sp {
+	case r.serverSideFlagsStreamProvider:
+		return basictypes.ServerSideFlagsOnlyStream
+	case r.mobileStreamProvider:
+		return basictypes.MobilePingStream
+	case r.jsClientStreamProvider:
+		return basictypes.JSClientPingStream
+	default:
+		return basictypes.ServerSideStream
+	}
+}
+
 var errRelayNotReady = errors.New("relay is not yet fully configured")
 var errUnrecognizedEnvironment = errors.New("no environment corresponds to given credentials")
 var errPayloadFilterNotFound = errors.New("credential corresponds to an environment but filter is unrecognized")
@@ -389,6 +621,33 @@ func (r *Relay) getAllEnvironments() []relayenv.EnvContext {
 	return r.envsByCredential.Environments()
 }
 
+// getEnvironmentStatusKey returns the name that identifies an environment in the status resource and in
+// the admin endpoints.
+func (r *Relay) getEnvironmentStatusKey(env relayenv.EnvContext) string {
+	if r.envLogNameMode == relayenv.LogNameIsEnvID {
+		// If we're identifying environments by environment ID in the log (which we do if there's any
+		// chance that the environment name could change) then we should also identify them that way here.
+		for _, c := range env.GetCredentials() {
+			if envID, ok := c.(config.EnvironmentID); ok {
+				return string(envID)
+			}
+		}
+		return ""
+	}
+	return env.GetIdentifiers().GetDisplayName()
+}
+
+// getEnvironmentByStatusKey returns the environment that getEnvironmentStatusKey identifies with the
+// specified name, if any.
+func (r *Relay) getEnvironmentByStatusKey(name string) (relayenv.EnvContext, bool) {
+	for _, env := range r.getAllEnvironments() {
+		if r.getEnvironmentStatusKey(env) == name {
+			return env, true
+		}
+	}
+	return nil, false
+}
+
 // addEnvironment attempts to add a new environment. It returns an error only if the configuration
 // is invalid; it does not wait to see whether the connection to LaunchDarkly succeeded.
 func (r *Relay) addEnvironment(
@@ -403,10 +662,43 @@ func (r *Relay) addEnvironment(
 		return nil, nil, errAlreadyClosed
 	}
 
-	dataStoreFactory, dataStoreInfo, err := sdks.ConfigureDataStore(r.config, envConfig, r.loggers)
+	var storeWriteGate *store.PersistentStoreWriteGate
+	if r.storeLeaderElection {
+		storeWriteGate = store.NewPersistentStoreWriteGate()
+	}
+	// The checker keeps a copy of all of the environment's data, so it is only used if periodic consistency
+	// checks are enabled; on-demand checks through the admin endpoints are then also possible.
+	var storeChecker *store.PersistentStoreChecker
+	if checkInterval, _ := sdks.GetDataStoreConsistencyCheckSettings(r.config); checkInterval > 0 {
+		storeChecker = store.NewPersistentStoreChecker()
+	}
+	persistentStoreUsed := false
+	wrapPersistentStore := func(
+		factory subsystems.ComponentConfigurer[subsystems.PersistentDataStore],
+	) subsystems.ComponentConfigurer[subsystems.PersistentDataStore] {
+		persistentStoreUsed = true
+		if storeWriteGate != nil {
+			factory = storeWriteGate.Wrap(factory)
+		}
+		if storeChecker != nil {
+			// The checker is the outermost wrapper, so that it sees all of the data that the SDK provides,
+			// even if the write gate does not write it to the database.
+			factory = storeChecker.Wrap(factory)
+		}
+		return factory
+	}
+	dataStoreFactory, dataStoreInfo, err := sdks.ConfigureDataStore(r.config, envConfig, wrapPersistentStore, r.loggers)
 	if err != nil {
 		return nil, nil, err
 	}
+	if !persistentStoreUsed {
+		storeChecker = nil
+	}
+
+	var statusChangeHandler relayenv.StatusChangeHandler
+	if r.webhooks != nil && r.webhooks.WantsStatus() {
+		statusChangeHandler = r.notifyEnvStatusChange
+	}
 
 	resultCh := make(chan relayenv.EnvContext, 1)
 
@@ -451,8 +743,16 @@ func (r *Relay) addEnvironment(
 		DataStoreFactory:                 dataStoreFactory,
 		DataStoreInfo:                    dataStoreInfo,
 		StreamProviders:                  r.allStreamProviders(),
+		StreamConnectionLimiter:          r.streamConnLimiter,
 		JSClientContext:                  jsClientContext,
 		MetricsManager:                   r.metricsManager,
+		ClusterBackend:                   r.clusterBackend,
+		ClusterNodeID:                    r.clusterNodeID,
+		StoreWriteGate:                   storeWriteGate,
+		StoreLeaseClient:                 r.storeLeaseClient,
+		StoreChecker:                     storeChecker,
+		ChangeFeed:                       r.changeFeed,
+		StatusChangeHandler:              statusChangeHandler,
 		UserAgent:                        r.userAgent,
 		LogNameMode:                      r.envLogNameMode,
 		Loggers:                          r.loggers,
@@ -0,0 +1,72 @@
+package relay
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	c "github.com/launchdarkly/ld-relay/v8/config"
+	"github.com/launchdarkly/ld-relay/v8/internal/cluster"
+	st "github.com/launchdarkly/ld-relay/v8/internal/sharedtest"
+
+	ct "github.com/launchdarkly/go-configtypes"
+	"github.com/launchdarkly/go-sdk-common/v3/ldvalue"
+	"github.com/launchdarkly/go-server-sdk/v7/testhelpers/ldservices"
+	helpers "github.com/launchdarkly/go-test-helpers/v3"
+	"github.com/launchdarkly/go-test-helpers/v3/httphelpers"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClusterModeUsesOneUpstreamStreamPerEnvironment(t *testing.T) {
+	putEvent := ldservices.NewServerSDKData().Flags(&testFlag).ToPutEvent()
+	ldStreamHandler, _ := ldservices.ServerSideStreamingServiceHandler(putEvent)
+	streamHandler, streamRequestsCh := httphelpers.RecordingHandler(ldStreamHandler)
+	backend := cluster.NewInMemoryBackend()
+
+	makeConfig := func(nodeID string, streamServer, eventsServer *httptest.Server) c.Config {
+		var config c.Config
+		clusterRedisURL, _ := ct.NewOptURLAbsoluteFromString("redis://not-used-in-this-test")
+		config.Environment = st.MakeEnvConfigs(st.EnvMain)
+		config.Main.StreamURI, _ = ct.NewOptURLAbsoluteFromString(streamServer.URL)
+		config.Events.EventsURI, _ = ct.NewOptURLAbsoluteFromString(eventsServer.URL)
+		config.Cluster = c.ClusterConfig{
+			Enabled:           true,
+			NodeID:            nodeID,
+			RedisURL:          clusterRedisURL,
+			HeartbeatInterval: ct.NewOptDuration(10 * time.Millisecond),
+			LeaderTimeout:     ct.NewOptDuration(100 * time.Millisecond),
+		}
+		return config
+	}
+	getFlagKeys := func(relay *Relay) []string {
+		r := st.BuildRequest("GET", "http://localhost/sdk/flags", nil,
+			http.Header{"Authorization": []string{string(st.EnvMain.Config.SDKKey)}})
+		result, body := st.DoRequest(r, relay)
+		require.Equal(t, http.StatusOK, result.StatusCode)
+		return ldvalue.Parse(body).Keys(nil)
+	}
+
+	httphelpers.WithServer(streamHandler, func(streamServer *httptest.Server) {
+		httphelpers.WithServer(httphelpers.HandlerWithStatus(202), func(eventsServer *httptest.Server) {
+			behavior := relayTestBehavior{useRealSDKClient: true, clusterBackend: backend}
+
+			withStartedRelayCustom(t, makeConfig("node1", streamServer, eventsServer), behavior, func(p1 relayTestParams) {
+				helpers.RequireValue(t, streamRequestsCh, time.Second, "timed out waiting for leader to connect")
+
+				withStartedRelayCustom(t, makeConfig("node2", streamServer, eventsServer), behavior, func(p2 relayTestParams) {
+					assert.Equal(t, []string{testFlag.Key}, getFlagKeys(p2.relay))
+					helpers.AssertNoMoreValues(t, streamRequestsCh, 100*time.Millisecond,
+						"follower should not have connected to LaunchDarkly")
+
+					// When the leader shuts down, the follower takes over
+					require.NoError(t, p1.relay.Close())
+					helpers.RequireValue(t, streamRequestsCh, time.Second, "timed out waiting for new leader to connect")
+					assert.Equal(t, []string{testFlag.Key}, getFlagKeys(p2.relay))
+				})
+			})
+		})
+	})
+}
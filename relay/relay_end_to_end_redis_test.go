@@ -7,16 +7,22 @@ package relay
 // must be running on localhost for these tests.
 
 import (
+	"io"
 	"net/http"
+	"net/url"
 	"testing"
 	"time"
 
 	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	"github.com/launchdarkly/go-sdk-common/v3/ldvalue"
 	"github.com/launchdarkly/go-server-sdk/v7/testhelpers/ldservices"
 	c "github.com/launchdarkly/ld-relay/v8/config"
 	st "github.com/launchdarkly/ld-relay/v8/internal/sharedtest"
 
 	"github.com/launchdarkly/go-configtypes"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var basicRedisConfig = c.RedisConfig{Host: "localhost", LocalTTL: configtypes.NewOptDuration(time.Minute)}
@@ -79,3 +85,65 @@ func TestRelayEndToEndRedisInitTimeoutWithInitializedDataStore(t *testing.T) {
 		p.expectSuccessFromAllEndpoints(testEnv)
 	})
 }
+
+func TestRelayEndToEndRedisStoreConsistencyCheck(t *testing.T) {
+	putEvent := ldservices.NewServerSDKData().Flags(&testFlag).ToPutEvent()
+	streamHandler, _ := ldservices.ServerSideStreamingServiceHandler(putEvent)
+	testEnv := st.EnvWithAllCredentials
+
+	redisConfig := uncachedRedisConfig
+	redisConfig.ConsistencyCheckInterval = configtypes.NewOptDuration(time.Hour)
+	config := c.Config{
+		Main:        c.MainConfig{AdminKey: "admin-key"},
+		Environment: st.MakeEnvConfigs(testEnv),
+		Redis:       redisConfig,
+	}
+	relayEndToEndTest(t, config, relayTestBehavior{}, streamHandler, func(p relayEndToEndTestParams) {
+		p.waitForSuccessfulInit()
+
+		req, _ := http.NewRequest("POST",
+			p.relayURL+"/admin/environments/"+url.PathEscape(testEnv.Name)+"/store/consistency?repair=true", nil)
+		req.Header.Set("Authorization", "admin-key")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		body, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		result := ldvalue.Parse(body)
+		assert.Equal(t, 0, result.GetByKey("missingItems").Count())
+		assert.Equal(t, 0, result.GetByKey("staleItems").Count())
+		assert.False(t, result.GetByKey("repaired").BoolValue())
+
+		resp, err = http.Get(p.relayURL + "/status")
+		require.NoError(t, err)
+		body, _ = io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		st.AssertJSONPathMatch(t, 0, ldvalue.Parse(body),
+			"environments", testEnv.Name, "dataStoreStatus", "consistency", "missingItems")
+	})
+}
+
+func TestRelayEndToEndRedisStoreConsistencyCheckIsNotEnabledByAdminKeyAlone(t *testing.T) {
+	putEvent := ldservices.NewServerSDKData().Flags(&testFlag).ToPutEvent()
+	streamHandler, _ := ldservices.ServerSideStreamingServiceHandler(putEvent)
+	testEnv := st.EnvWithAllCredentials
+
+	config := c.Config{
+		Main:        c.MainConfig{AdminKey: "admin-key"},
+		Environment: st.MakeEnvConfigs(testEnv),
+		Redis:       uncachedRedisConfig,
+	}
+	relayEndToEndTest(t, config, relayTestBehavior{}, streamHandler, func(p relayEndToEndTestParams) {
+		p.waitForSuccessfulInit()
+
+		req, _ := http.NewRequest("POST",
+			p.relayURL+"/admin/environments/"+url.PathEscape(testEnv.Name)+"/store/consistency", nil)
+		req.Header.Set("Authorization", "admin-key")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		body, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		assert.Contains(t, ldvalue.Parse(body).GetByKey("message").StringValue(), "not enabled")
+	})
+}
@@ -9,12 +9,16 @@ import (
 	"net/http"
 	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/launchdarkly/ld-relay/v8/internal/basictypes"
+	"github.com/launchdarkly/ld-relay/v8/internal/compression"
 	"github.com/launchdarkly/ld-relay/v8/internal/logging"
+	"github.com/launchdarkly/ld-relay/v8/internal/metrics"
 	"github.com/launchdarkly/ld-relay/v8/internal/middleware"
 	"github.com/launchdarkly/ld-relay/v8/internal/relayenv"
+	"github.com/launchdarkly/ld-relay/v8/internal/store"
 	"github.com/launchdarkly/ld-relay/v8/internal/streams"
 	"github.com/launchdarkly/ld-relay/v8/internal/util"
 
@@ -22,6 +26,7 @@ import (
 	"github.com/launchdarkly/go-sdk-common/v3/ldcontext"
 	ldevents "github.com/launchdarkly/go-sdk-events/v3"
 	"github.com/launchdarkly/go-server-sdk-evaluation/v3/ldmodel"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems"
 	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoreimpl"
 	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoretypes"
 
@@ -110,21 +115,133 @@ func streamHandler(streamProvider streams.StreamProvider, logMessage string) htt
 // PHP SDK polling endpoint for all flags: app.ld.com/sdk/flags
 func pollAllFlagsHandler(w http.ResponseWriter, req *http.Request) {
 	clientCtx := middleware.GetEnvContextInfo(req.Context())
-	data, err := clientCtx.Env.GetStore().GetAll(ldstoreimpl.Features())
+	// If we already know the hash of the environment's data, the client may already have the current data, in
+	// which case we don't need to read it from the store. This must be checked before reading the data.
+	dataHash := clientCtx.Env.GetDataHash()
+	if dataHash != "" && writeNotModifiedIfEtagMatches(w, req, makeRelayEtag(dataHash)) {
+		return
+	}
+	data, err := getAllFromStore(clientCtx.Env, clientCtx.Env.GetStore(), ldstoreimpl.Features())
 	if err != nil {
 		clientCtx.Env.GetLoggers().Errorf("Error reading feature store: %s", err)
 		w.WriteHeader(500)
 		return
 	}
 	respData := serializeFlagsAsMap(data)
-	// Compute an overall Etag for the data set by hashing flag keys and versions
-	hash := sha1.New()                                                         //nolint:gas // just used for insecure hashing
-	sort.Slice(data, func(i, j int) bool { return data[i].Key < data[j].Key }) // makes the hash deterministic
-	for _, item := range data {
-		_, _ = io.WriteString(hash, fmt.Sprintf("%s:%d", item.Key, item.Item.Version))
+	etagValue := dataHash
+	if etagValue == "" {
+		// Compute an overall Etag for the data set by hashing flag keys and versions
+		hash := sha1.New()                                                         //nolint:gas // just used for insecure hashing
+		sort.Slice(data, func(i, j int) bool { return data[i].Key < data[j].Key }) // makes the hash deterministic
+		for _, item := range data {
+			_, _ = io.WriteString(hash, fmt.Sprintf("%s:%d", item.Key, item.Item.Version))
+		}
+		etagValue = hex.EncodeToString(hash.Sum(nil))[:15]
+	}
+	writeCacheableJSONResponse(w, req, clientCtx.Env, respData, etagValue)
+}
+
+// Relay-only polling endpoint for flag and segment changes since a previous request: /sdk/changes?since={version}
+//
+// The response's "version" property is the value to pass as "since" next time. If "full" is true, the response
+// contains all of the flags and segments, and the client should discard anything it already had; otherwise it
+// contains only the ones that have changed, with deleted items represented as {"key", "version", "deleted": true}.
+func pollChangesHandler(w http.ResponseWriter, req *http.Request) {
+	clientCtx := middleware.GetEnvContextInfo(req.Context())
+	dataStore := clientCtx.Env.GetStore()
+	// The version must be obtained before reading any data, so that it can't be newer than the data.
+	changes, version, ok := clientCtx.Env.GetDataChangesSince(req.URL.Query().Get("since"))
+	var flags, segments []ldstoretypes.KeyedItemDescriptor
+	var err error
+	if ok {
+		flags, segments, err = getChangedItems(dataStore, changes)
+	} else {
+		flags, segments, err = getAllItems(clientCtx.Env, dataStore)
+	}
+	if err != nil {
+		clientCtx.Env.GetLoggers().Errorf("Error reading feature store: %s", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	respWriter := jwriter.NewWriter()
+	respObj := respWriter.Object()
+	respObj.Name("version").String(version)
+	respObj.Name("full").Bool(!ok)
+	writeItemsAsMap(respObj.Name("flags"), flags)
+	writeItemsAsMap(respObj.Name("segments"), segments)
+	respObj.End()
+
+	w.Header().Set("Content-Type", "application/json")
+	compression.WriteResponse(w, req, respWriter.Bytes())
+}
+
+func getChangedItems(dataStore subsystems.DataStore, changes []store.DataChange) (
+	flags, segments []ldstoretypes.KeyedItemDescriptor, err error) {
+	for _, change := range changes {
+		item, err := dataStore.Get(change.Kind, change.Key)
+		if err != nil {
+			return nil, nil, err
+		}
+		keyedItem := ldstoretypes.KeyedItemDescriptor{Key: change.Key, Item: item}
+		if change.Kind.GetName() == ldstoreimpl.Segments().GetName() {
+			segments = append(segments, keyedItem)
+		} else {
+			flags = append(flags, keyedItem)
+		}
+	}
+	return flags, segments, nil
+}
+
+func getAllItems(env relayenv.EnvContext, dataStore subsystems.DataStore) (
+	flags, segments []ldstoretypes.KeyedItemDescriptor, err error) {
+	if flags, err = getAllFromStore(env, dataStore, ldstoreimpl.Features()); err != nil {
+		return nil, nil, err
 	}
-	etag := hex.EncodeToString(hash.Sum(nil))[:15]
-	writeCacheableJSONResponse(w, req, clientCtx.Env, respData, etag)
+	if segments, err = getAllFromStore(env, dataStore, ldstoreimpl.Segments()); err != nil {
+		return nil, nil, err
+	}
+	// A full data set doesn't need to tell the client about deleted items
+	return withoutDeletedItems(flags), withoutDeletedItems(segments), nil
+}
+
+// getAllFromStore reads all items of one kind from the data store, recording how long the read took so
+// that slow responses can be attributed to the store rather than to Relay itself.
+func getAllFromStore(env relayenv.EnvContext, dataStore subsystems.DataStore, kind ldstoretypes.DataKind) (
+	[]ldstoretypes.KeyedItemDescriptor, error) {
+	startTime := time.Now()
+	items, err := dataStore.GetAll(kind)
+	metrics.RecordStoreReadDuration(env.GetMetricsContext(), kind.GetName(), time.Since(startTime))
+	return items, err
+}
+
+func withoutDeletedItems(items []ldstoretypes.KeyedItemDescriptor) []ldstoretypes.KeyedItemDescriptor {
+	ret := make([]ldstoretypes.KeyedItemDescriptor, 0, len(items))
+	for _, item := range items {
+		if item.Item.Item != nil {
+			ret = append(ret, item)
+		}
+	}
+	return ret
+}
+
+func writeItemsAsMap(w *jwriter.Writer, items []ldstoretypes.KeyedItemDescriptor) {
+	obj := w.Object()
+	for _, item := range items {
+		switch data := item.Item.Item.(type) {
+		case *ldmodel.FeatureFlag:
+			ldmodel.MarshalFeatureFlagToJSONWriter(*data, obj.Name(item.Key))
+		case *ldmodel.Segment:
+			ldmodel.MarshalSegmentToJSONWriter(*data, obj.Name(item.Key))
+		default:
+			deletedObj := obj.Name(item.Key).Object()
+			deletedObj.Name("key").String(item.Key)
+			deletedObj.Name("version").Int(item.Item.Version)
+			deletedObj.Name("deleted").Bool(true)
+			deletedObj.End()
+		}
+	}
+	obj.End()
 }
 
 // PHP SDK polling endpoint for a flag: app.ld.com/sdk/flags/{key}
@@ -222,7 +339,17 @@ func evaluateAllShared(w http.ResponseWriter, req *http.Request, sdkKind basicty
 
 	loggers.Debugf("Application requested client-side flags (%s) for context: %s", sdkKind, ldContext.Key())
 
-	items, err := store.GetAll(ldstoreimpl.Features())
+	// The results can only be cached if nothing but the flag data and the context can affect them. Big
+	// segment membership can change without the environment's data version changing.
+	var etag string
+	if dataHash := clientCtx.Env.GetDataHash(); dataHash != "" && clientCtx.Env.GetBigSegmentStore() == nil {
+		etag = makeEvalEtag(dataHash, sdkKind, withReasons, ldContext)
+		if writeNotModifiedIfEtagMatches(w, req, etag) {
+			return
+		}
+	}
+
+	items, err := getAllFromStore(clientCtx.Env, store, ldstoreimpl.Features())
 	if err != nil {
 		loggers.Warnf("Unable to fetch flags from feature store. Returning nil map. Error: %s", err)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -234,6 +361,7 @@ func evaluateAllShared(w http.ResponseWriter, req *http.Request, sdkKind basicty
 
 	responseWriter := jwriter.NewWriter()
 	responseObj := responseWriter.Object()
+	var evalDuration time.Duration
 	for _, item := range items {
 		if flag, ok := item.Item.Item.(*ldmodel.FeatureFlag); ok {
 			switch sdkKind {
@@ -247,7 +375,9 @@ func evaluateAllShared(w http.ResponseWriter, req *http.Request, sdkKind basicty
 				}
 			}
 
+			evalStartTime := time.Now()
 			result := evaluator.Evaluate(flag, ldContext, nil)
+			evalDuration += time.Since(evalStartTime)
 			detail := result.Detail
 			isExperiment := result.IsExperiment
 
@@ -267,9 +397,12 @@ func evaluateAllShared(w http.ResponseWriter, req *http.Request, sdkKind basicty
 	}
 	responseObj.End()
 	result := responseWriter.Bytes()
+	metrics.RecordEvaluationDuration(clientCtx.Env.GetMetricsContext(), sdkKind, evalDuration)
 
-	w.WriteHeader(http.StatusOK)
-	_, _ = w.Write(result)
+	if etag != "" {
+		w.Header().Set("Etag", etag)
+	}
+	compression.WriteResponse(w, req, result)
 }
 
 func pollFlagOrSegment(clientContext relayenv.EnvContext, kind ldstoretypes.DataKind) func(http.ResponseWriter, *http.Request) {
@@ -297,12 +430,9 @@ func pollFlagOrSegment(clientContext relayenv.EnvContext, kind ldstoretypes.Data
 
 func writeCacheableJSONResponse(w http.ResponseWriter, req *http.Request, clientContext relayenv.EnvContext,
 	bytes []byte, etagValue string) {
-	etag := fmt.Sprintf("relay-%s", etagValue) // just to make it extra clear that these are relay-specific etags
-	if cachedEtag := req.Header.Get("If-None-Match"); cachedEtag != "" {
-		if cachedEtag == etag {
-			w.WriteHeader(http.StatusNotModified)
-			return
-		}
+	etag := makeRelayEtag(etagValue)
+	if writeNotModifiedIfEtagMatches(w, req, etag) {
+		return
 	}
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Etag", etag)
@@ -315,8 +445,44 @@ func writeCacheableJSONResponse(w http.ResponseWriter, req *http.Request, client
 		// HTTP cache in front of ld-relay, multiple clients hitting the cache at different times
 		// will all see the same expiration time.
 	}
-	w.WriteHeader(http.StatusOK)
-	_, _ = w.Write(bytes)
+	compression.WriteResponse(w, req, bytes)
+}
+
+func makeRelayEtag(value string) string {
+	return fmt.Sprintf("relay-%s", value) // just to make it extra clear that these are relay-specific etags
+}
+
+// The etag for client-side evaluation results covers everything that can affect them: the environment's
+// data, which flags this kind of SDK can see, whether reasons were requested, and the context.
+func makeEvalEtag(dataHash string, sdkKind basictypes.SDKKind, withReasons bool, ldContext ldcontext.Context) string {
+	hash := sha1.New() //nolint:gas // just used for insecure hashing
+	_, _ = fmt.Fprintf(hash, "%s:%s:%t:", dataHash, sdkKind, withReasons)
+	_, _ = hash.Write(canonicalContextJSON(ldContext))
+	return makeRelayEtag(hex.EncodeToString(hash.Sum(nil))[:20])
+}
+
+// The JSON encoding of a context doesn't write attributes in any particular order, so we re-encode it with
+// encoding/json, which sorts object keys; otherwise the same context could produce a different etag each time.
+func canonicalContextJSON(ldContext ldcontext.Context) []byte {
+	var parsed interface{}
+	if err := json.Unmarshal([]byte(ldContext.JSONString()), &parsed); err != nil {
+		return []byte(ldContext.JSONString())
+	}
+	bytes, _ := json.Marshal(parsed)
+	return bytes
+}
+
+// If the request's If-None-Match header includes the current etag, writeNotModifiedIfEtagMatches writes a
+// 304 status and returns true.
+func writeNotModifiedIfEtagMatches(w http.ResponseWriter, req *http.Request, etag string) bool {
+	for _, cachedEtag := range strings.Split(req.Header.Get("If-None-Match"), ",") {
+		if strings.TrimSpace(cachedEtag) == etag {
+			w.Header().Set("Etag", etag)
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
 }
 
 func serializeFlagsAsMap(coll []ldstoretypes.KeyedItemDescriptor) []byte {
@@ -9,6 +9,7 @@ var (
 	errAlreadyClosed         = errors.New("this Relay was already shut down")
 	errInitializationTimeout = errors.New("timed out waiting for environments to initialize")
 	errSomeEnvironmentFailed = errors.New("one or more environments failed to initialize")
+	errTLSNotEnabled         = errors.New("TLS is not enabled in the Relay configuration")
 )
 
 func errNewClientContextFailed(envName string, err error) error {
@@ -18,3 +19,19 @@ func errNewClientContextFailed(envName string, err error) error {
 func errNewMetricsManagerFailed(err error) error {
 	return fmt.Errorf("unable to create metrics manager: %w", err)
 }
+
+func errCreateClusterBackendFailed(err error) error {
+	return fmt.Errorf("unable to set up cluster mode: %w", err)
+}
+
+func errCreateStoreLeaseClientFailed(err error) error {
+	return fmt.Errorf("unable to set up persistent store leader election: %w", err)
+}
+
+func errOpenAccessLogFailed(err error) error {
+	return fmt.Errorf("unable to open access log: %w", err)
+}
+
+func errOpenChangeFeedFileFailed(err error) error {
+	return fmt.Errorf("unable to open change feed file: %w", err)
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/launchdarkly/ld-relay/v8/internal/sdkauth"
 
+	"github.com/launchdarkly/ld-relay/v8/config"
 	"github.com/launchdarkly/ld-relay/v8/internal/basictypes"
 	"github.com/launchdarkly/ld-relay/v8/internal/logging"
 	"github.com/launchdarkly/ld-relay/v8/internal/metrics"
@@ -22,6 +23,17 @@ const (
 	serverSideFlagsOnlyStreamLogMessage = "Application requested server-side /flags stream"
 )
 
+// makeHandler creates the top-level HTTP handler for Relay. The middleware that is applied here, rather
+// than in makeRouter, also sees requests that don't match any route, since the router does not call its
+// own middleware for those.
+func (r *Relay) makeHandler() http.Handler {
+	var handler http.Handler = r.makeRouter()
+	if r.accessLog != nil {
+		handler = logging.AccessLogMiddleware(r.accessLog, r.config.Main.AccessLogFormat != config.AccessLogFormatCommon)(handler)
+	}
+	return logging.RequestIDMiddleware(handler)
+}
+
 // makeRouter creates and configures a Router containing all of the standard routes for Relay.
 //
 // IMPORTANT: The route strings that are used here, such as "/sdk/evalx/{envId}/contexts/{context}", will appear
@@ -31,16 +43,63 @@ const (
 func (r *Relay) makeRouter() *mux.Router {
 	router := mux.NewRouter()
 	router.Use(logging.GlobalContextLoggersMiddleware(r.loggers))
-	if r.loggers.GetMinLevel() == ldlog.Debug {
+	if r.config.Main.LogRequests {
+		router.Use(logging.RequestLoggerMiddlewareAtLevel(r.loggers, ldlog.Info))
+	} else if r.loggers.GetMinLevel() == ldlog.Debug {
 		router.Use(logging.RequestLoggerMiddleware(r.loggers))
 	}
 	router.Handle("/status", statusHandler(r)).Methods("GET")
 
+	if r.config.Main.AdminKey != "" {
+		adminRouter := router.PathPrefix("/admin/").Subrouter()
+		adminRouter.Use(middleware.RequireAdminKey(r.config.Main.AdminKey))
+		adminRouter.Handle("/changes", getChangesHandler(r)).Methods("GET")
+		adminRouter.Handle("/changes/stream", r.changeFeed.Handler()).Methods("GET")
+		adminRouter.Handle("/environments", listEnvironmentsHandler(r)).Methods("GET")
+		if r.nativePrometheus != nil && r.config.MetricsConfig.Prometheus.UseAdminEndpoint {
+			adminRouter.Handle("/metrics", r.nativePrometheus.Handler()).Methods("GET")
+		}
+		adminRouter.Handle("/environments/{envName}/data",
+			adminEnvironmentHandler(r, getEnvironmentDataHandler)).Methods("GET")
+		adminRouter.Handle("/environments/{envName}/streams",
+			adminEnvironmentHandler(r, getEnvironmentStreamsHandler(r))).Methods("GET")
+		adminRouter.Handle("/environments/{envName}/resync",
+			adminEnvironmentHandler(r, resyncEnvironmentHandler)).Methods("POST")
+		adminRouter.Handle("/environments/{envName}/big-segments/resync",
+			adminEnvironmentHandler(r, resyncBigSegmentsHandler)).Methods("POST")
+		adminRouter.Handle("/environments/{envName}/store/consistency",
+			adminEnvironmentHandler(r, getStoreConsistencyHandler)).Methods("GET")
+		adminRouter.Handle("/environments/{envName}/store/consistency",
+			adminEnvironmentHandler(r, checkStoreConsistencyHandler)).Methods("POST")
+	}
+
 	environmentGetters := relayEnvironmentGetters{r}
 	sdkKeySelector := middleware.SelectEnvironmentByAuthorizationKey(basictypes.ServerSDK, environmentGetters)
 	mobileKeySelector := middleware.SelectEnvironmentByAuthorizationKey(basictypes.MobileSDK, environmentGetters)
 	jsClientSelector := middleware.SelectEnvironmentByAuthorizationKey(basictypes.JSClientSDK, environmentGetters)
 	offlineMode := r.config.OfflineMode.FileDataSource != ""
+	limitStreams := middleware.LimitStreamConnections(
+		r.config.Main.StreamConnectionRetryAfter.GetOrElse(config.DefaultStreamConnectionRetryAfter))
+
+	if r.config.Main.AllowDownstreamRelays {
+		// Endpoints that Relay doesn't implement itself, but that downstream Relays need; see makeDownstreamRelayProxy.
+		// Big segment requests must use the SDK key of one of our environments. Auto-configuration requests must
+		// use one of the configured downstream auto-configuration keys, or our own auto-configuration key; if
+		// there are none, that endpoint isn't available, so that it can't be used as an open proxy.
+		streamProxy := makeDownstreamRelayProxy(r.config.Main.StreamURI.Get(), r.downstreamRelayHTTPConfig, r.loggers)
+		pollProxy := makeDownstreamRelayProxy(r.config.Main.BaseURI.Get(), r.downstreamRelayHTTPConfig, r.loggers)
+		// We append to this list, so make our own copy rather than relying on Values() not to share the
+		// configuration's slice.
+		autoConfigKeys := append([]string(nil), r.config.Main.DownstreamAutoConfigKeys.Values()...)
+		if r.config.AutoConfig.Key != "" {
+			autoConfigKeys = append(autoConfigKeys, string(r.config.AutoConfig.Key))
+		}
+		if len(autoConfigKeys) != 0 {
+			router.Handle("/relay_auto_config", middleware.RequireAutoConfigKey(autoConfigKeys)(streamProxy)).Methods("GET")
+		}
+		router.Handle("/big-segments", sdkKeySelector(streamProxy)).Methods("GET")
+		router.Handle("/sdk/big-segments/revisions", sdkKeySelector(pollProxy)).Methods("GET")
+	}
 
 	// Client-side evaluation (for JS, not mobile)
 	jsClientSideMiddlewareStack := func(subrouter *mux.Router) mux.MiddlewareFunc {
@@ -84,6 +143,7 @@ func (r *Relay) makeRouter() *mux.Router {
 	serverSideSdkRouter.Handle("/flags", serverSideMiddlewareStack(middleware.PollingRequestCount(http.HandlerFunc(pollAllFlagsHandler)))).Methods("GET")
 	serverSideSdkRouter.Handle("/flags/{key}", serverSideMiddlewareStack(middleware.PollingRequestCount(http.HandlerFunc(pollFlagHandler)))).Methods("GET")
 	serverSideSdkRouter.Handle("/segments/{key}", serverSideMiddlewareStack(middleware.PollingRequestCount(http.HandlerFunc(pollSegmentHandler)))).Methods("GET")
+	serverSideSdkRouter.Handle("/changes", serverSideMiddlewareStack(middleware.PollingRequestCount(http.HandlerFunc(pollChangesHandler)))).Methods("GET")
 
 	// Mobile evaluation
 	mobileMiddlewareStack := middleware.Chain(
@@ -104,24 +164,24 @@ func (r *Relay) makeRouter() *mux.Router {
 	mobileStreamRouter := router.PathPrefix("/meval").Subrouter()
 	mobileStreamRouter.Use(mobileMiddlewareStack, middleware.Streaming)
 	mobilePingWithUser := pingStreamHandlerWithContext(basictypes.MobileSDK, r.mobileStreamProvider)
-	mobileStreamRouter.Handle("", middleware.CountMobileConns(mobilePingWithUser)).Methods("REPORT")
-	mobileStreamRouter.Handle("/{context}", middleware.CountMobileConns(mobilePingWithUser)).Methods("GET")
+	mobileStreamRouter.Handle("", limitStreams(middleware.CountMobileConns(mobilePingWithUser))).Methods("REPORT")
+	mobileStreamRouter.Handle("/{context}", limitStreams(middleware.CountMobileConns(mobilePingWithUser))).Methods("GET")
 
-	router.Handle("/mping", mobileKeySelector(
-		middleware.CountMobileConns(middleware.Streaming(pingStreamHandler(r.mobileStreamProvider))))).Methods("GET")
+	router.Handle("/mping", mobileKeySelector(limitStreams(
+		middleware.CountMobileConns(middleware.Streaming(pingStreamHandler(r.mobileStreamProvider)))))).Methods("GET")
 
 	jsPing := pingStreamHandler(r.jsClientStreamProvider)
 	jsPingWithUser := pingStreamHandlerWithContext(basictypes.JSClientSDK, r.jsClientStreamProvider)
 
 	clientSidePingRouter := router.PathPrefix("/ping/{envId}").Subrouter()
 	clientSidePingRouter.Use(jsClientSideMiddlewareStack(clientSidePingRouter), middleware.Streaming)
-	clientSidePingRouter.Handle("", middleware.CountBrowserConns(jsPing)).Methods("GET", "OPTIONS")
+	clientSidePingRouter.Handle("", limitStreams(middleware.CountBrowserConns(jsPing))).Methods("GET", "OPTIONS")
 
 	clientSideStreamEvalRouter := router.PathPrefix("/eval/{envId}").Subrouter()
 	clientSideStreamEvalRouter.Use(jsClientSideMiddlewareStack(clientSideStreamEvalRouter), middleware.Streaming)
 	// For now we implement eval as simply ping
-	clientSideStreamEvalRouter.Handle("/{context}", middleware.CountBrowserConns(jsPingWithUser)).Methods("GET", "OPTIONS")
-	clientSideStreamEvalRouter.Handle("", middleware.CountBrowserConns(jsPingWithUser)).Methods("REPORT", "OPTIONS")
+	clientSideStreamEvalRouter.Handle("/{context}", limitStreams(middleware.CountBrowserConns(jsPingWithUser))).Methods("GET", "OPTIONS")
+	clientSideStreamEvalRouter.Handle("", limitStreams(middleware.CountBrowserConns(jsPingWithUser))).Methods("REPORT", "OPTIONS")
 
 	mobileEventsRouter := router.PathPrefix("/mobile").Subrouter()
 	mobileEventsRouter.Use(mobileMiddlewareStack, middleware.GzipMiddleware(r.config.Events.MaxInboundPayloadSize))
@@ -150,12 +210,12 @@ func (r *Relay) makeRouter() *mux.Router {
 	serverSideBulkEventsRouter.Handle("/bulk", bulkEventHandler(basictypes.ServerSDK, ldevents.AnalyticsEventDataKind, offlineMode)).Methods("POST")
 	serverSideBulkEventsRouter.Handle("/diagnostic", bulkEventHandler(basictypes.ServerSDK, ldevents.DiagnosticEventDataKind, offlineMode)).Methods("POST")
 
-	serverSideRouter.Handle("/all", middleware.CountServerConns(middleware.Streaming(
+	serverSideRouter.Handle("/all", limitStreams(middleware.CountServerConns(middleware.Streaming(
 		streamHandler(r.serverSideStreamProvider, serverSideStreamLogMessage),
-	))).Methods("GET")
-	serverSideRouter.Handle("/flags", middleware.CountServerConns(middleware.Streaming(
+	)))).Methods("GET")
+	serverSideRouter.Handle("/flags", limitStreams(middleware.CountServerConns(middleware.Streaming(
 		streamHandler(r.serverSideFlagsStreamProvider, serverSideFlagsOnlyStreamLogMessage),
-	))).Methods("GET")
+	)))).Methods("GET")
 
 	return router
 }
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	c "github.com/launchdarkly/ld-relay/v8/config"
+	"github.com/launchdarkly/ld-relay/v8/internal/cluster"
 	"github.com/launchdarkly/ld-relay/v8/internal/sharedtest/testclient"
 
 	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
@@ -19,6 +20,8 @@ type relayTestBehavior struct {
 	skipWaitForEnvironments bool // true = we're using auto-config or expect startup to fail; false = wait for all environments
 	useRealSDKClient        bool // true = use real end-to-end HTTP; false = use a mock SDK client
 	doNotEnableDebugLogging bool // true = leave the default log level in place; false = enable debug logging
+	upstreamStatusInterval  time.Duration
+	clusterBackend          cluster.Backend // for cluster mode; if nil, the default Redis backend is used
 }
 
 // Components that are passed from withStartedRelay/withStartedRelayCustom to the test logic.
@@ -49,7 +52,11 @@ func withStartedRelayCustom(t *testing.T, config c.Config, behavior relayTestBeh
 		config.Main.LogLevel = c.NewOptLogLevel(ldlog.Debug)
 		mockLog.Loggers.SetMinLevel(ldlog.Debug)
 	}
-	options := relayInternalOptions{loggers: mockLog.Loggers}
+	options := relayInternalOptions{
+		loggers:                mockLog.Loggers,
+		upstreamStatusInterval: behavior.upstreamStatusInterval,
+		clusterBackend:         behavior.clusterBackend,
+	}
 	if !behavior.useRealSDKClient {
 		options.clientFactory = testclient.CreateDummyClient
 	}
@@ -2,16 +2,21 @@ package relay
 
 import (
 	"net/http"
+	"os"
+	"path/filepath"
 	"testing"
 
 	c "github.com/launchdarkly/ld-relay/v8/config"
 	st "github.com/launchdarkly/ld-relay/v8/internal/sharedtest"
 
 	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestRequestLogging(t *testing.T) {
-	url := "http://localhost/status" // must be a route that exists - not-found paths currently aren't logged
+	url := "http://localhost/status"
 
 	t.Run("requests are not logged by default", func(t *testing.T) {
 		config := c.Config{
@@ -37,4 +42,53 @@ func TestRequestLogging(t *testing.T) {
 			p.mockLog.AssertMessageMatch(t, true, ldlog.Debug, "method=GET url="+url)
 		})
 	})
+
+	t.Run("requests are logged at info level when logRequests is enabled", func(t *testing.T) {
+		config := c.Config{
+			Main:        c.MainConfig{LogRequests: true},
+			Environment: st.MakeEnvConfigs(st.EnvMain),
+		}
+		withStartedRelayCustom(t, config, relayTestBehavior{doNotEnableDebugLogging: true}, func(p relayTestParams) {
+			req, _ := http.NewRequest("GET", url, nil)
+			resp, _ := st.DoRequest(req, p.relay)
+
+			p.mockLog.AssertMessageMatch(t, true, ldlog.Info,
+				"method=GET url="+url+" .*requestId="+resp.Header.Get("X-Request-ID")+" route=/status")
+		})
+	})
+
+	t.Run("requests are written to access log file regardless of log level", func(t *testing.T) {
+		accessLogPath := filepath.Join(t.TempDir(), "access.log")
+		config := c.Config{
+			Main:        c.MainConfig{AccessLog: accessLogPath},
+			Environment: st.MakeEnvConfigs(st.EnvMain),
+		}
+		withStartedRelayCustom(t, config, relayTestBehavior{doNotEnableDebugLogging: true}, func(p relayTestParams) {
+			req, _ := http.NewRequest("GET", url, nil)
+			req.Header.Set("User-Agent", "my-agent")
+			resp, _ := st.DoRequest(req, p.relay)
+
+			data, err := os.ReadFile(accessLogPath)
+			require.NoError(t, err)
+			assert.Regexp(t, `"GET /status HTTP/1\.1" 200 \d+ "-" "my-agent" duration=\S+ requestId=`+resp.Header.Get("X-Request-ID")+"\n$",
+				string(data))
+		})
+	})
+	t.Run("requests for unknown paths are written to access log file", func(t *testing.T) {
+		accessLogPath := filepath.Join(t.TempDir(), "access.log")
+		config := c.Config{
+			Main:        c.MainConfig{AccessLog: accessLogPath},
+			Environment: st.MakeEnvConfigs(st.EnvMain),
+		}
+		withStartedRelayCustom(t, config, relayTestBehavior{doNotEnableDebugLogging: true}, func(p relayTestParams) {
+			req, _ := http.NewRequest("GET", "http://localhost/no-such-path", nil)
+			resp, _ := st.DoRequest(req, p.relay)
+			require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+			data, err := os.ReadFile(accessLogPath)
+			require.NoError(t, err)
+			assert.Regexp(t, `"GET /no-such-path HTTP/1\.1" 404 \d+ "-" "-" duration=\S+ requestId=`+resp.Header.Get("X-Request-ID")+"\n$",
+				string(data))
+		})
+	})
 }
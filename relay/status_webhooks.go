@@ -0,0 +1,26 @@
+package relay
+
+import (
+	"github.com/launchdarkly/ld-relay/v8/internal/relayenv"
+	"github.com/launchdarkly/ld-relay/v8/internal/webhooks"
+)
+
+// notifyEnvStatusChange sends a status event to the webhooks when an environment's status changes. The
+// environment calls this from the same SDK status listeners that it uses for its status history, so every
+// transition is reported, however brief.
+func (r *Relay) notifyEnvStatusChange(env relayenv.EnvContext, oldStatus, newStatus relayenv.EnvStatus) {
+	r.webhooks.NotifyStatus(webhooks.StatusChange{
+		Environment:            r.getEnvironmentStatusKey(env),
+		Status:                 envStatusName(newStatus),
+		PreviousStatus:         envStatusName(oldStatus),
+		DataStoreState:         newStatus.DataStoreState,
+		PreviousDataStoreState: oldStatus.DataStoreState,
+	})
+}
+
+func envStatusName(status relayenv.EnvStatus) string {
+	if status.Connected {
+		return statusEnvConnected
+	}
+	return statusEnvDisconnected
+}
@@ -0,0 +1,159 @@
+package relay
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/launchdarkly/ld-relay/v8/internal/api"
+	"github.com/launchdarkly/ld-relay/v8/internal/httpconfig"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	"github.com/launchdarkly/go-sdk-common/v3/ldtime"
+)
+
+const (
+	defaultUpstreamStatusInterval = 10 * time.Second
+
+	upstreamStatusUnknown     = "unknown"
+	upstreamStatusUnreachable = "unreachable"
+
+	logMsgUpstreamStatusChanged    = "Upstream Relay at %s is now %s"
+	logMsgUpstreamStatusError      = "Unable to get status of upstream Relay at %s: %s"
+	logMsgDownstreamRelayProxyFail = "Unable to forward downstream Relay request to %s: %s"
+)
+
+// makeDownstreamRelayProxy returns a handler that forwards requests from a downstream Relay to the same
+// path under the target URI, with the downstream Relay's credentials. This is for endpoints that Relay
+// does not implement itself, but that a downstream Relay would otherwise get from LaunchDarkly, such
+// as auto-configuration and big segment synchronization. If we have our own upstream Relay, the target
+// URI is that Relay, so the request is passed along again.
+//
+// Responses are not buffered, so streaming responses are passed along as each event arrives.
+func makeDownstreamRelayProxy(target *url.URL, httpConfig httpconfig.HTTPConfig, loggers ldlog.Loggers) http.Handler {
+	return &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(target)
+		},
+		Transport:     httpConfig.Client().Transport,
+		FlushInterval: -1,
+		ErrorHandler: func(w http.ResponseWriter, req *http.Request, err error) {
+			loggers.Warnf(logMsgDownstreamRelayProxyFail, target.Redacted(), err)
+			w.WriteHeader(http.StatusBadGateway)
+		},
+	}
+}
+
+// upstreamStatusMonitor periodically queries the status endpoint of our upstream Relay, if we are
+// getting data from another Relay rather than from LaunchDarkly, so that we can include it in our
+// own status.
+type upstreamStatusMonitor struct {
+	statusURI string
+	client    *http.Client
+	status    api.UpstreamStatusRep
+	loggers   ldlog.Loggers
+	lock      sync.RWMutex
+	closeCh   chan struct{}
+	closeOnce sync.Once
+}
+
+func newUpstreamStatusMonitor(
+	upstreamURI *url.URL,
+	httpConfig httpconfig.HTTPConfig,
+	interval time.Duration, // zero = use the default; we set a nonzero brief interval in unit tests
+	loggers ldlog.Loggers,
+) *upstreamStatusMonitor {
+	if interval == 0 {
+		interval = defaultUpstreamStatusInterval
+	}
+	m := &upstreamStatusMonitor{
+		statusURI: upstreamURI.JoinPath("status").String(),
+		client:    httpConfig.Client(),
+		status: api.UpstreamStatusRep{
+			URI:    upstreamURI.Redacted(),
+			Status: upstreamStatusUnknown,
+		},
+		loggers: loggers,
+		closeCh: make(chan struct{}),
+	}
+	go m.run(interval)
+	return m
+}
+
+func (m *upstreamStatusMonitor) getStatus() api.UpstreamStatusRep {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return m.status
+}
+
+func (m *upstreamStatusMonitor) Close() error {
+	m.closeOnce.Do(func() {
+		close(m.closeCh)
+	})
+	return nil
+}
+
+func (m *upstreamStatusMonitor) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		m.check()
+		select {
+		case <-m.closeCh:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *upstreamStatusMonitor) check() {
+	m.lock.RLock()
+	newStatus := m.status
+	m.lock.RUnlock()
+	oldStatus := newStatus.Status
+
+	newStatus.LastChecked = ldtime.UnixMillisNow()
+	upstream, err := m.query()
+	if err == nil {
+		newStatus.Status = upstream.Status
+		newStatus.Version = upstream.Version
+		newStatus.LastError = ""
+	} else {
+		newStatus.Status = upstreamStatusUnreachable
+		newStatus.LastError = err.Error()
+		m.loggers.Debugf(logMsgUpstreamStatusError, newStatus.URI, err)
+	}
+
+	m.lock.Lock()
+	m.status = newStatus
+	m.lock.Unlock()
+
+	if newStatus.Status != oldStatus {
+		if newStatus.Status == statusRelayHealthy {
+			m.loggers.Infof(logMsgUpstreamStatusChanged, newStatus.URI, newStatus.Status)
+		} else {
+			m.loggers.Warnf(logMsgUpstreamStatusChanged, newStatus.URI, newStatus.Status)
+		}
+	}
+}
+
+func (m *upstreamStatusMonitor) query() (api.StatusRep, error) {
+	var rep api.StatusRep
+	resp, err := m.client.Get(m.statusURI)
+	if err != nil {
+		return rep, err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	if resp.StatusCode != http.StatusOK {
+		return rep, fmt.Errorf("HTTP error %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&rep); err != nil {
+		return rep, fmt.Errorf("invalid status response: %w", err)
+	}
+	return rep, nil
+}
@@ -0,0 +1,230 @@
+package relay
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	c "github.com/launchdarkly/ld-relay/v8/config"
+	"github.com/launchdarkly/ld-relay/v8/internal/events"
+	st "github.com/launchdarkly/ld-relay/v8/internal/sharedtest"
+
+	ct "github.com/launchdarkly/go-configtypes"
+	"github.com/launchdarkly/go-sdk-common/v3/ldvalue"
+	helpers "github.com/launchdarkly/go-test-helpers/v3"
+	"github.com/launchdarkly/go-test-helpers/v3/httphelpers"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownstreamRelayEndpoints(t *testing.T) {
+	// The fake LaunchDarkly service writes one SSE event and then leaves the stream open, so we can
+	// verify that streamed data is passed along without waiting for the response to end.
+	ldHandler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte("data: " + req.URL.Path + "\n\n"))
+		w.(http.Flusher).Flush()
+		<-req.Context().Done()
+	})
+	handler, requestsCh := httphelpers.RecordingHandler(ldHandler)
+
+	httphelpers.WithServer(handler, func(ldServer *httptest.Server) {
+		var config c.Config
+		config.Environment = st.MakeEnvConfigs(st.EnvMain)
+		config.Main.StreamURI, _ = ct.NewOptURLAbsoluteFromString(ldServer.URL + "/stream")
+		config.Main.BaseURI, _ = ct.NewOptURLAbsoluteFromString(ldServer.URL + "/base")
+		config.Main.AllowDownstreamRelays = true
+		config.Main.DownstreamAutoConfigKeys = ct.NewOptStringList([]string{"rel-abc", "rel-xyz"})
+
+		withStartedRelay(t, config, func(p relayTestParams) {
+			httphelpers.WithServer(p.relay, func(relayServer *httptest.Server) {
+				get := func(t *testing.T, path, authKey string) *http.Response {
+					req, _ := http.NewRequest("GET", relayServer.URL+path, nil)
+					req.Header.Set("Authorization", authKey)
+					resp, err := http.DefaultClient.Do(req)
+					require.NoError(t, err)
+					t.Cleanup(func() { _ = resp.Body.Close() })
+					return resp
+				}
+				readFirstLine := func(t *testing.T, resp *http.Response) string {
+					lineCh := make(chan string, 1)
+					go func() {
+						line, _ := bufio.NewReader(resp.Body).ReadString('\n')
+						lineCh <- line
+					}()
+					return helpers.RequireValue(t, lineCh, time.Second, "timed out waiting for streamed data")
+				}
+
+				t.Run("auto-configuration stream", func(t *testing.T) {
+					resp := get(t, "/relay_auto_config?ver=2", "rel-xyz")
+					assert.Equal(t, http.StatusOK, resp.StatusCode)
+					assert.Equal(t, "data: /stream/relay_auto_config\n", readFirstLine(t, resp))
+
+					forwarded := helpers.RequireValue(t, requestsCh, time.Second)
+					assert.Equal(t, "/stream/relay_auto_config", forwarded.Request.URL.Path)
+					assert.Equal(t, "ver=2", forwarded.Request.URL.RawQuery)
+					assert.Equal(t, "rel-xyz", forwarded.Request.Header.Get("Authorization"))
+				})
+
+				t.Run("auto-configuration stream with unknown key", func(t *testing.T) {
+					for _, key := range []string{"", "rel-unknown"} {
+						resp := get(t, "/relay_auto_config", key)
+						assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+					}
+					helpers.AssertNoMoreValues(t, requestsCh, 50*time.Millisecond)
+				})
+
+				t.Run("big segments stream", func(t *testing.T) {
+					resp := get(t, "/big-segments", string(st.EnvMain.Config.SDKKey))
+					assert.Equal(t, http.StatusOK, resp.StatusCode)
+					assert.Equal(t, "data: /stream/big-segments\n", readFirstLine(t, resp))
+
+					forwarded := helpers.RequireValue(t, requestsCh, time.Second)
+					assert.Equal(t, string(st.EnvMain.Config.SDKKey), forwarded.Request.Header.Get("Authorization"))
+				})
+
+				t.Run("big segments polling", func(t *testing.T) {
+					resp := get(t, "/sdk/big-segments/revisions?after=abc", string(st.EnvMain.Config.SDKKey))
+					assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+					forwarded := helpers.RequireValue(t, requestsCh, time.Second)
+					assert.Equal(t, "/base/sdk/big-segments/revisions", forwarded.Request.URL.Path)
+					assert.Equal(t, "after=abc", forwarded.Request.URL.RawQuery)
+				})
+
+				t.Run("big segments with unknown SDK key", func(t *testing.T) {
+					resp := get(t, "/sdk/big-segments/revisions", string(st.UndefinedSDKKey))
+					assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+					helpers.AssertNoMoreValues(t, requestsCh, 50*time.Millisecond)
+				})
+			})
+		})
+	})
+
+	t.Run("auto-configuration stream not available without auto-configuration keys", func(t *testing.T) {
+		var config c.Config
+		config.Environment = st.MakeEnvConfigs(st.EnvMain)
+		config.Main.AllowDownstreamRelays = true
+
+		withStartedRelay(t, config, func(p relayTestParams) {
+			r, _ := http.NewRequest("GET", "http://localhost/relay_auto_config", nil)
+			r.Header.Set("Authorization", "rel-xyz")
+			result, _ := st.DoRequest(r, p.relay)
+			assert.Equal(t, http.StatusNotFound, result.StatusCode)
+		})
+	})
+
+	t.Run("not enabled by default", func(t *testing.T) {
+		var config c.Config
+		config.Environment = st.MakeEnvConfigs(st.EnvMain)
+
+		withStartedRelay(t, config, func(p relayTestParams) {
+			r, _ := http.NewRequest("GET", "http://localhost/relay_auto_config", nil)
+			result, _ := st.DoRequest(r, p.relay)
+			assert.Equal(t, http.StatusNotFound, result.StatusCode)
+		})
+	})
+}
+
+func TestUpstreamRelayStatus(t *testing.T) {
+	var lock sync.Mutex
+	upstreamStatus := `{"status":"healthy","version":"1.2.3"}`
+	upstreamHandler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/status" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		lock.Lock()
+		body := upstreamStatus
+		lock.Unlock()
+		_, _ = w.Write([]byte(body))
+	})
+	upstreamServer := httptest.NewServer(upstreamHandler)
+	defer upstreamServer.Close()
+
+	var config c.Config
+	config.Environment = st.MakeEnvConfigs(st.EnvMain)
+	config.Main.UpstreamRelayURI, _ = ct.NewOptURLAbsoluteFromString(upstreamServer.URL)
+
+	behavior := relayTestBehavior{upstreamStatusInterval: 10 * time.Millisecond}
+	withStartedRelayCustom(t, config, behavior, func(p relayTestParams) {
+		getStatus := func() ldvalue.Value {
+			r, _ := http.NewRequest("GET", "http://localhost/status", nil)
+			_, body := st.DoRequest(r, p.relay)
+			return ldvalue.Parse(body)
+		}
+
+		require.Eventually(t, func() bool {
+			return getStatus().GetByKey("upstream").GetByKey("status").StringValue() == "healthy"
+		}, time.Second, 10*time.Millisecond)
+		status := getStatus()
+		st.AssertJSONPathMatch(t, upstreamServer.URL, status, "upstream", "uri")
+		st.AssertJSONPathMatch(t, "1.2.3", status, "upstream", "version")
+		st.AssertJSONPathMatch(t, "healthy", status, "status")
+
+		lock.Lock()
+		upstreamStatus = `{"status":"degraded","version":"1.2.3"}`
+		lock.Unlock()
+		require.Eventually(t, func() bool {
+			return getStatus().GetByKey("upstream").GetByKey("status").StringValue() == "degraded"
+		}, time.Second, 10*time.Millisecond)
+		status = getStatus()
+		st.AssertJSONPathMatch(t, "healthy", status, "status")
+		assert.Equal(t, "upstream", status.GetByKey("warnings").GetByIndex(0).GetByKey("kind").StringValue())
+
+		upstreamServer.Close()
+		require.Eventually(t, func() bool {
+			return getStatus().GetByKey("upstream").GetByKey("status").StringValue() == "unreachable"
+		}, time.Second, 10*time.Millisecond)
+		status = getStatus()
+		assert.NotEqual(t, "", status.GetByKey("upstream").GetByKey("lastError").StringValue())
+		st.AssertJSONPathMatch(t, "healthy", status, "status")
+		assert.Equal(t, "upstream", status.GetByKey("warnings").GetByIndex(0).GetByKey("kind").StringValue())
+	})
+}
+
+func TestEventsAreForwardedThroughUpstreamRelay(t *testing.T) {
+	receivedCh := make(chan *http.Request, 10)
+	ldEventsServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, _ = io.ReadAll(req.Body)
+		receivedCh <- req
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer ldEventsServer.Close()
+
+	var regionalConfig c.Config
+	regionalConfig.Environment = st.MakeEnvConfigs(st.EnvMain)
+	regionalConfig.Events.SendEvents = true
+	regionalConfig.Events.EventsURI, _ = ct.NewOptURLAbsoluteFromString(ldEventsServer.URL)
+	regionalConfig.Events.FlushInterval = ct.NewOptDuration(10 * time.Millisecond)
+
+	withStartedRelay(t, regionalConfig, func(regional relayTestParams) {
+		httphelpers.WithServer(regional.relay, func(regionalServer *httptest.Server) {
+			var edgeConfig c.Config
+			edgeConfig.Environment = st.MakeEnvConfigs(st.EnvMain)
+			edgeConfig.Main.UpstreamRelayURI, _ = ct.NewOptURLAbsoluteFromString(regionalServer.URL)
+			edgeConfig.Events.SendEvents = true
+			edgeConfig.Events.FlushInterval = ct.NewOptDuration(10 * time.Millisecond)
+
+			withStartedRelay(t, edgeConfig, func(edge relayTestParams) {
+				header := make(http.Header)
+				header.Set("Authorization", string(st.EnvMain.Config.SDKKey))
+				header.Set(events.EventSchemaHeader, strconv.Itoa(events.SummaryEventsSchemaVersion))
+				r := st.BuildRequest("POST", "http://localhost/bulk", makeTestFeatureEventPayload("me"), header)
+				result, _ := st.DoRequest(r, edge.relay)
+				require.Equal(t, http.StatusAccepted, result.StatusCode)
+
+				received := helpers.RequireValue(t, receivedCh, 3*time.Second)
+				assert.Equal(t, "/bulk", received.URL.Path)
+				assert.Equal(t, string(st.EnvMain.Config.SDKKey), received.Header.Get("Authorization"))
+				assert.Equal(t, strconv.Itoa(events.SummaryEventsSchemaVersion), received.Header.Get(events.EventSchemaHeader))
+			})
+		})
+	})
+}
@@ -0,0 +1,98 @@
+package relay
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	c "github.com/launchdarkly/ld-relay/v8/config"
+	"github.com/launchdarkly/ld-relay/v8/internal/sdkauth"
+	"github.com/launchdarkly/ld-relay/v8/internal/sdks"
+	st "github.com/launchdarkly/ld-relay/v8/internal/sharedtest"
+	"github.com/launchdarkly/ld-relay/v8/internal/sharedtest/testclient"
+	"github.com/launchdarkly/ld-relay/v8/internal/webhooks"
+
+	ct "github.com/launchdarkly/go-configtypes"
+	"github.com/launchdarkly/go-server-sdk-evaluation/v3/ldbuilders"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoreimpl"
+	"github.com/launchdarkly/go-server-sdk/v7/subsystems/ldstoretypes"
+	"github.com/launchdarkly/go-test-helpers/v3/httphelpers"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookIsCalledForFlagChange(t *testing.T) {
+	handler, requestsCh := httphelpers.RecordingHandler(httphelpers.HandlerWithStatus(200))
+	httphelpers.WithServer(handler, func(server *httptest.Server) {
+		var config c.Config
+		config.Environment = st.MakeEnvConfigs(st.EnvMain)
+		url, err := ct.NewOptURLAbsoluteFromString(server.URL)
+		require.NoError(t, err)
+		config.Webhook = map[string]*c.WebhookConfig{
+			"hook": {URL: url, Secret: "secret", Events: ct.NewOptStringList([]string{c.WebhookEventChanges})},
+		}
+
+		withStartedRelay(t, config, func(p relayTestParams) {
+			env, _ := p.relay.getEnvironment(sdkauth.New(st.EnvMain.Config.SDKKey))
+			newFlag := ldbuilders.NewFlagBuilder(st.Flag1ServerSide.Flag.Key).Version(st.Flag1ServerSide.Flag.Version + 1).Build()
+			_, err := env.GetStore().Upsert(ldstoreimpl.Features(), newFlag.Key,
+				ldstoretypes.ItemDescriptor{Version: newFlag.Version, Item: &newFlag})
+			require.NoError(t, err)
+
+			select {
+			case r := <-requestsCh:
+				assert.Equal(t, "change", r.Request.Header.Get(webhooks.EventHeader))
+				assert.Equal(t, "sha256="+webhooks.Sign([]byte("secret"), r.Body), r.Request.Header.Get(webhooks.SignatureHeader))
+				var event webhooks.Event
+				require.NoError(t, json.Unmarshal(r.Body, &event))
+				require.NotNil(t, event.Change)
+				assert.Equal(t, st.EnvMain.Name, event.Change.Environment)
+				assert.Equal(t, newFlag.Key, event.Change.Key)
+				assert.Equal(t, newFlag.Version, event.Change.NewVersion)
+			case <-time.After(time.Second):
+				require.Fail(t, "timed out waiting for webhook request")
+			}
+		})
+	})
+}
+
+func TestWebhookIsCalledForEnvironmentStatusChange(t *testing.T) {
+	handler, requestsCh := httphelpers.RecordingHandler(httphelpers.HandlerWithStatus(200))
+	httphelpers.WithServer(handler, func(server *httptest.Server) {
+		var config c.Config
+		config.Environment = st.MakeEnvConfigs(st.EnvMain)
+		url, err := ct.NewOptURLAbsoluteFromString(server.URL)
+		require.NoError(t, err)
+		config.Webhook = map[string]*c.WebhookConfig{
+			"hook": {URL: url, Events: ct.NewOptStringList([]string{c.WebhookEventStatus})},
+		}
+
+		withStartedRelay(t, config, func(p relayTestParams) {
+			env, _ := p.relay.getEnvironment(sdkauth.New(st.EnvMain.Config.SDKKey))
+			client := env.GetClient().(*testclient.FakeLDClient)
+
+			// Even an outage that is over before anyone could check the status is reported.
+			client.SetDataStoreStatus(sdks.DataStoreStatusInfo{Available: false, LastUpdated: time.Now()})
+			client.SetDataStoreStatus(sdks.DataStoreStatusInfo{Available: true, LastUpdated: time.Now()})
+
+			for _, expected := range []webhooks.StatusChange{
+				{Environment: st.EnvMain.Name, Status: "connected", PreviousStatus: "connected",
+					DataStoreState: "INTERRUPTED", PreviousDataStoreState: "VALID"},
+				{Environment: st.EnvMain.Name, Status: "connected", PreviousStatus: "connected",
+					DataStoreState: "VALID", PreviousDataStoreState: "INTERRUPTED"},
+			} {
+				select {
+				case r := <-requestsCh:
+					assert.Equal(t, "status", r.Request.Header.Get(webhooks.EventHeader))
+					var event webhooks.Event
+					require.NoError(t, json.Unmarshal(r.Body, &event))
+					assert.Equal(t, &expected, event.Status)
+				case <-time.After(time.Second):
+					require.Fail(t, "timed out waiting for webhook request")
+				}
+			}
+		})
+	})
+}